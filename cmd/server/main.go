@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"time"
 
 	_ "github.com/cccvno1/nova/docs" // Swagger docs
-	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/migration"
+	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/internal/router"
 	"github.com/cccvno1/nova/internal/server"
+	"github.com/cccvno1/nova/internal/service"
 	"github.com/cccvno1/nova/pkg/auth"
 	"github.com/cccvno1/nova/pkg/cache"
 	"github.com/cccvno1/nova/pkg/casbin"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/encrypt"
 	"github.com/cccvno1/nova/pkg/logger"
 	"github.com/cccvno1/nova/pkg/queue"
+	"github.com/cccvno1/nova/pkg/retry"
 	"github.com/cccvno1/nova/pkg/scheduler"
+	"github.com/cccvno1/nova/pkg/storage"
 )
 
 // @title Nova API
@@ -50,6 +56,27 @@ import (
 
 var configFile = flag.String("config", "", "config file path")
 
+// startupRetry 启动时连接数据库与 Redis 的重试策略
+// 容器编排中依赖服务可能晚于应用就绪，约 30 秒内仍连不上才退出
+var startupRetry = retry.Policy{
+	MaxAttempts:  6,
+	Strategy:     retry.StrategyExponential,
+	InitialDelay: time.Second,
+	MaxDelay:     10 * time.Second,
+	Jitter:       0.2,
+}
+
+// connectWithRetry 按 startupRetry 执行连接，失败的尝试记录警告日志
+func connectWithRetry(name string, connect func() error) error {
+	return retry.Do(context.Background(), startupRetry, func(_ context.Context, attempt int) error {
+		err := connect()
+		if err != nil && attempt < startupRetry.MaxAttempts {
+			logger.Warn("dependency not ready, retrying", "dependency", name, "attempt", attempt, "error", err)
+		}
+		return err
+	})
+}
+
 func main() {
 	flag.Parse()
 
@@ -57,43 +84,67 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	loggerCfg := &logger.Config{
-		Level:      cfg.Logger.Level,
-		Format:     cfg.Logger.Format,
-		Output:     cfg.Logger.Output,
-		FilePath:   cfg.Logger.FilePath,
-		MaxSize:    cfg.Logger.MaxSize,
-		MaxBackups: cfg.Logger.MaxBackups,
-		MaxAge:     cfg.Logger.MaxAge,
+		Level:        cfg.Logger.Level,
+		Format:       cfg.Logger.Format,
+		StdoutFormat: cfg.Logger.StdoutFormat,
+		FileFormat:   cfg.Logger.FileFormat,
+		Output:       cfg.Logger.Output,
+		FilePath:     cfg.Logger.FilePath,
+		MaxSize:      cfg.Logger.MaxSize,
+		MaxBackups:   cfg.Logger.MaxBackups,
+		MaxAge:       cfg.Logger.MaxAge,
 	}
 
 	if err := logger.Init(loggerCfg); err != nil {
 		log.Fatalf("failed to initialize logger: %v", err)
 	}
 
-	if err := database.Init(&cfg.DB); err != nil {
+	// 检查上传临时目录可写
+	if err := storage.CheckWritableDir(cfg.Upload.TempDir); err != nil {
+		log.Fatalf("upload temp dir check failed: %v", err)
+	}
+
+	// 字段加密：配置密钥后可读取已加密的列，开启 audit_log.encrypt_bodies 时审计请求/响应体加密写入
+	if cfg.Security.EncryptionKey != "" {
+		keyRing, err := encrypt.NewKeyRing(cfg.Security.EncryptionKeyID, cfg.Security.EncryptionKey, cfg.Security.OldEncryptionKeys)
+		if err != nil {
+			log.Fatalf("failed to load encryption keys: %v", err)
+		}
+		database.SetFieldEncryption(keyRing, cfg.AuditLog.EncryptBodies)
+	}
+
+	if err := connectWithRetry("database", func() error { return database.Init(&cfg.DB) }); err != nil {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
 	defer database.Close()
 
-	if err := cache.Init(&cfg.Redis); err != nil {
+	if err := connectWithRetry("redis", func() error { return cache.Init(&cfg.Redis) }); err != nil {
 		log.Fatalf("failed to initialize redis: %v", err)
 	}
 	defer cache.Close()
 
-	// 自动迁移数据库表
-	if err := database.AutoMigrate(
-		&model.User{},
-		&model.Role{},
-		&model.Permission{},
-		&model.RolePermission{},
-		&model.UserRole{},
-		&model.File{},
-		&model.Task{},
-		&model.AuditLog{},
-	); err != nil {
-		log.Fatalf("failed to auto migrate: %v", err)
+	// 执行数据库迁移（关闭 auto_migrate 时由 cmd/migrate 执行，未完成前 /ready 返回 503）
+	if cfg.DB.AutoMigrate {
+		migrator := database.NewMigrator(database.GetDB(), migration.All())
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+
+	// 检查热点查询依赖的索引，缺失时只告警（避免静默的全表扫描）
+	if cfg.DB.CheckIndexes {
+		for _, idx := range database.MissingIndexes(context.Background(), database.GetDB(), migration.RequiredIndexes()) {
+			logger.Warn("missing database index",
+				"table", idx.Table,
+				"index", idx.Name,
+				"purpose", idx.Purpose,
+			)
+		}
 	}
 
 	// 初始化 Casbin enforcer
@@ -101,9 +152,13 @@ func main() {
 		database.GetDB(),
 		casbin.Config{
 			ModelPath:    cfg.Casbin.ModelPath,
+			ModelText:    cfg.Casbin.Model,
+			TableName:    cfg.Casbin.TableName,
 			AutoSave:     cfg.Casbin.AutoSave,
 			AutoLoad:     cfg.Casbin.AutoLoad,
 			AutoLoadTick: time.Duration(cfg.Casbin.AutoLoadTick) * time.Second,
+
+			ReloadOnChange: cfg.Casbin.ReloadOnChange,
 		},
 		logger.Logger(),
 	)
@@ -112,28 +167,49 @@ func main() {
 	}
 	defer enforcer.Close()
 
+	signingKeys := make([]auth.SigningKey, 0, len(cfg.Auth.Keys))
+	for _, key := range cfg.Auth.Keys {
+		signingKeys = append(signingKeys, auth.SigningKey{ID: key.ID, Secret: key.Secret, Active: key.Active})
+	}
 	jwtAuth := auth.NewJWTAuth(&auth.Config{
 		SecretKey:            cfg.Auth.JWTSecret,
 		AccessTokenDuration:  time.Duration(cfg.Auth.AccessTokenDuration) * time.Second,
 		RefreshTokenDuration: time.Duration(cfg.Auth.RefreshTokenDuration) * time.Second,
 		Issuer:               cfg.Auth.Issuer,
+		Keys:                 signingKeys,
 	})
 
-	blacklist := auth.NewTokenBlacklist(jwtAuth)
+	// 令牌黑名单存储：默认 Redis，未部署 Redis 时可使用数据库表
+	var blacklistStore auth.BlacklistStore
+	var dbBlacklistStore *auth.DBBlacklistStore
+	if cfg.Auth.BlacklistStore == auth.BlacklistStoreDatabase {
+		// token_blacklist 表由迁移 0002 创建
+		dbBlacklistStore = auth.NewDBBlacklistStore(database.GetDB())
+		blacklistStore = dbBlacklistStore
+	}
+	blacklist := auth.NewTokenBlacklist(jwtAuth, blacklistStore, cfg.Auth.BlacklistFailureMode)
 
 	// 初始化队列 Worker（如果启用）
 	var queueWorker *queue.Worker
 	if cfg.Queue.Enabled {
 		queueWorker = queue.NewWorker(&cfg.Queue)
-		// 可以在这里注册任务处理器
+		// 可以在这里注册任务处理器（推荐使用类型化 API，载荷自动解码）
+		// type WelcomeEmail struct {
+		// 	UserID   uint   `json:"user_id"`
+		// 	Username string `json:"username"`
+		// 	Email    string `json:"email"`
+		// }
+		// queue.RegisterTyped(queueWorker, "send_welcome_email", func(ctx context.Context, p WelcomeEmail) error {
+		// 	// 处理任务逻辑
+		// 	return nil
+		// })
+		//
+		// 需要访问任务元信息时使用原始 API：
 		// queueWorker.Register("example_task", func(task *queue.Task) error {
 		// 	// 处理任务逻辑
 		// 	return nil
 		// })
-		if err := queueWorker.Start(); err != nil {
-			log.Fatalf("failed to start queue worker: %v", err)
-		}
-		defer queueWorker.Stop()
+		// 路由层（router.Setup）还会注册业务任务处理器（如异步用户导入），Worker 在其之后启动
 	}
 
 	// 初始化调度器
@@ -145,12 +221,124 @@ func main() {
 	// taskScheduler.AddInterval(30*time.Second, func() {
 	// 	// 每30秒执行的任务
 	// })
+	// 发件箱轮询：将业务事务中写入的事件投递到任务队列（多实例时仅主节点执行）
+	if cfg.Queue.Enabled {
+		outboxPoller := service.NewOutboxPoller(
+			repository.NewOutboxRepository(database.DB()),
+			queue.NewClient(cfg.Queue.RedisPrefix),
+			&cfg.Queue,
+		)
+		if _, err := taskScheduler.AddInterval(time.Duration(cfg.Queue.OutboxPollInterval)*time.Second, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if _, err := outboxPoller.Poll(ctx); err != nil {
+				logger.Warn("outbox poll failed", "error", err)
+			}
+		}); err != nil {
+			log.Fatalf("failed to schedule outbox poller: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_ = outboxPoller.Resign(ctx)
+		}()
+	}
+	// 队列积压采样：持续超过告警阈值时记录 warn 日志，健康检查据此报告 degraded
+	if cfg.Queue.Enabled && cfg.Queue.AlertThreshold > 0 {
+		depthMonitor := queue.NewDepthMonitor(
+			queue.NewClient(cfg.Queue.RedisPrefix),
+			cfg.Queue.AlertThreshold,
+			time.Duration(cfg.Queue.AlertDuration)*time.Second,
+		)
+		if _, err := taskScheduler.AddInterval(time.Duration(cfg.Queue.AlertCheckInterval)*time.Second, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			status, err := depthMonitor.Observe(ctx)
+			if err != nil {
+				logger.Warn("queue depth check failed", "error", err)
+				return
+			}
+			if status.Status == queue.DepthStatusDegraded {
+				logger.Warn("queue backlog over threshold",
+					"length", status.Length,
+					"threshold", status.Threshold,
+					"over_threshold_seconds", status.OverThresholdSeconds,
+					"high_water", status.HighWater,
+				)
+			}
+		}); err != nil {
+			log.Fatalf("failed to schedule queue depth check: %v", err)
+		}
+	}
+	// RBAC 服务：路由与定时任务共用同一实例
+	// 启用队列时角色/权限变更经发件箱投递 rbac_changed 事件（发件箱轮询器只在启用队列时运行）
+	var rbacOutbox repository.OutboxRepository
+	if cfg.Queue.Enabled {
+		rbacOutbox = repository.NewOutboxRepository(database.DB())
+	}
+	rbacService := service.NewRBACService(enforcer,
+		repository.NewRoleRepository(database.DB()),
+		repository.NewPermissionRepository(database.DB()),
+		repository.NewUserRoleRepository(database.DB()),
+		rbacOutbox, database.DB(), logger.Logger(), &cfg.RBAC)
+	// 临时角色分配过期清理：权限解析已忽略过期分配，这里删除记录并同步移除 Casbin 分组规则
+	if _, err := taskScheduler.AddInterval(time.Duration(cfg.RBAC.ExpiredRolePurgeInterval)*time.Second, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := rbacService.PurgeExpiredUserRoles(ctx); err != nil {
+			logger.Warn("failed to purge expired user roles", "error", err)
+		}
+	}); err != nil {
+		log.Fatalf("failed to schedule expired user role purge: %v", err)
+	}
+	// 审计日志自动清理：按保留天数与最大行数物理删除（多实例时仅主节点执行）
+	if cfg.AuditLog.RetentionDays > 0 || cfg.AuditLog.MaxRows > 0 {
+		auditPruner := service.NewAuditPruner(repository.NewAuditLogRepository(database.DB()), &cfg.AuditLog)
+		if _, err := taskScheduler.AddInterval(time.Duration(cfg.AuditLog.PruneInterval)*time.Second, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			if _, err := auditPruner.Prune(ctx); err != nil {
+				logger.Warn("failed to prune audit logs", "error", err)
+			}
+		}); err != nil {
+			log.Fatalf("failed to schedule audit log pruning: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_ = auditPruner.Resign(ctx)
+		}()
+	}
+	if dbBlacklistStore != nil {
+		interval := time.Duration(cfg.Auth.BlacklistCleanupInterval) * time.Second
+		if _, err := taskScheduler.AddInterval(interval, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			removed, err := dbBlacklistStore.CleanupExpired(ctx)
+			if err != nil {
+				logger.Warn("failed to clean up token blacklist", "error", err)
+				return
+			}
+			if removed > 0 {
+				logger.Info("token blacklist cleaned up", "removed", removed)
+			}
+		}); err != nil {
+			log.Fatalf("failed to schedule token blacklist cleanup: %v", err)
+		}
+	}
 	taskScheduler.Start()
 	defer taskScheduler.Stop()
 
 	srv := server.New(cfg)
 
-	router.Setup(srv.Echo(), cfg, jwtAuth, blacklist, enforcer)
+	router.Setup(srv.Echo(), cfg, jwtAuth, blacklist, enforcer, rbacService, queueWorker)
+
+	if queueWorker != nil {
+		if err := queueWorker.Start(); err != nil {
+			log.Fatalf("failed to start queue worker: %v", err)
+		}
+		defer queueWorker.Stop()
+	}
 
 	if err := srv.Start(); err != nil {
 		log.Fatalf("server error: %v", err)
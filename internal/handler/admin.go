@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	adminRoleLevel      = 80                        // 管理员角色等级
+	superAdminRoleLevel = model.SuperAdminRoleLevel // 超级管理员角色等级
+
+	// platformDomain 平台域：缓存清理、缩略图重建等跨域的全局操作只认可该域的超级管理员，
+	// 其他域（租户）的超级管理员不能执行影响全部域的操作
+	platformDomain = "default"
+)
+
+// requireRoleLevel 检查当前用户在指定域下的最高角色等级是否达到要求
+func requireRoleLevel(c echo.Context, rbacService service.RBACService, domain string, minLevel int, message string) error {
+	userID := middleware.GetUserID(c)
+	level, err := rbacService.GetUserMaxRoleLevel(c.Request().Context(), userID, domain)
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+	if level < minLevel {
+		return errors.New(errors.ErrForbidden, message)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/casbin"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/response"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler 系统管理处理器
+type AdminHandler struct {
+	rbacService service.RBACService
+	fileService service.FileService
+	enforcer    *casbin.Enforcer
+	maintenance *middleware.Maintenance
+	cache       *cache.CacheManager
+	logger      *slog.Logger
+}
+
+// NewAdminHandler 创建系统管理处理器
+func NewAdminHandler(rbacService service.RBACService, fileService service.FileService, enforcer *casbin.Enforcer, maintenance *middleware.Maintenance, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{
+		rbacService: rbacService,
+		fileService: fileService,
+		enforcer:    enforcer,
+		maintenance: maintenance,
+		cache:       cache.NewCacheManager("admin"),
+		logger:      logger,
+	}
+}
+
+// FlushCache 清空缓存（仅超级管理员）
+// POST /api/v1/admin/cache/flush?pattern=rbac:*&confirm=true
+// pattern 为空时清空应用前缀下的全部键（包括会话、黑名单等），必须携带 confirm=true
+func (h *AdminHandler) FlushCache(c echo.Context) error {
+	// 🔒 安全检查：仅超级管理员可清空缓存
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可清空缓存"); err != nil {
+		return err
+	}
+
+	pattern := c.QueryParam("pattern")
+	if pattern == "" || pattern == "*" {
+		if c.QueryParam("confirm") != "true" {
+			return errors.New(errors.ErrInvalidParams, "full cache flush requires confirm=true")
+		}
+		pattern = "*"
+	}
+
+	deleted, err := h.cache.DeleteByPatternCount(c.Request().Context(), pattern)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	h.logger.Warn("cache flushed by admin",
+		"operator_id", middleware.GetUserID(c),
+		"pattern", cache.BuildKey(pattern),
+		"deleted", deleted,
+	)
+
+	return response.SuccessWithMessage(c, "缓存已清空", map[string]interface{}{
+		"pattern": cache.BuildKey(pattern),
+		"deleted": deleted,
+	})
+}
+
+// GetCacheMetrics 缓存命中统计（仅超级管理员）
+// GET /api/v1/admin/cache/metrics
+// 按逻辑缓存名返回本实例自启动以来的命中/未命中次数与命中率
+func (h *AdminHandler) GetCacheMetrics(c echo.Context) error {
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可查看缓存统计"); err != nil {
+		return err
+	}
+
+	return response.Success(c, cache.Stats())
+}
+
+// GetCasbinMetrics Casbin 策略版本与自动加载统计（仅超级管理员）
+// GET /api/v1/admin/casbin/metrics
+// 返回本实例内存中策略的版本、最近一次全量加载的时间与耗时，以及加载/跳过次数
+func (h *AdminHandler) GetCasbinMetrics(c echo.Context) error {
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可查看策略加载统计"); err != nil {
+		return err
+	}
+
+	return response.Success(c, h.enforcer.Stats())
+}
+
+// RegenerateThumbnails 按当前配置重新生成图片缩略图（仅超级管理员）
+// POST /api/v1/admin/files/thumbnails/regenerate?category=image&force=false
+// 任务在后台执行，立即返回 202；完成后记录重新生成的文件数
+func (h *AdminHandler) RegenerateThumbnails(c echo.Context) error {
+	// 🔒 安全检查：仅超级管理员可执行批量任务
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可重新生成缩略图"); err != nil {
+		return err
+	}
+
+	var filter service.FileFilter
+	if err := c.Bind(&filter); err != nil {
+		return errors.New(errors.ErrBindQuery, "")
+	}
+
+	operatorID := middleware.GetUserID(c)
+	go func() {
+		count, err := h.fileService.RegenerateThumbnails(context.Background(), filter)
+		if err != nil {
+			h.logger.Error("thumbnail regeneration failed",
+				"operator_id", operatorID,
+				"regenerated", count,
+				"error", err,
+			)
+			return
+		}
+		h.logger.Info("thumbnail regeneration completed",
+			"operator_id", operatorID,
+			"regenerated", count,
+		)
+	}()
+
+	return c.JSON(http.StatusAccepted, response.Response{
+		Code:    errors.Success,
+		Message: "缩略图重新生成任务已开始",
+		Data:    filter,
+	})
+}
+
+// SetMaintenanceRequest 切换维护模式请求
+type SetMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" validate:"required"`
+	Message string `json:"message" validate:"max=500"` // 提示信息，为空时使用配置的默认提示
+}
+
+// GetMaintenance 查看维护模式状态（仅超级管理员）
+// GET /api/v1/admin/maintenance
+func (h *AdminHandler) GetMaintenance(c echo.Context) error {
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可查看维护模式"); err != nil {
+		return err
+	}
+
+	return response.Success(c, h.maintenance.State(c.Request().Context()))
+}
+
+// SetMaintenance 开启或关闭维护模式（仅超级管理员）
+// PUT /api/v1/admin/maintenance
+// 设置写入 Redis，优先于配置中的 maintenance.enabled，所有实例在 maintenance.check_interval 内生效
+func (h *AdminHandler) SetMaintenance(c echo.Context) error {
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, superAdminRoleLevel, "仅超级管理员可切换维护模式"); err != nil {
+		return err
+	}
+
+	var req SetMaintenanceRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	operatorID := middleware.GetUserID(c)
+	state, err := h.maintenance.Set(c.Request().Context(), *req.Enabled, req.Message, operatorID)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	h.logger.Warn("maintenance mode changed by admin",
+		"operator_id", operatorID,
+		"enabled", state.Enabled,
+		"message", state.Message,
+	)
+
+	return response.Success(c, state)
+}
@@ -1,26 +1,44 @@
 package handler
 
 import (
+	"context"
+	"iter"
 	"strconv"
 	"time"
 
+	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
 )
 
 // AuditLogHandler 审计日志处理器
 type AuditLogHandler struct {
-	auditRepo repository.AuditLogRepository
+	auditRepo        repository.AuditLogRepository
+	statsConcurrency int // 综合统计的并发查询数上限
+	exportBatchSize  int // 导出时每批读取的记录数
+	exportMaxRows    int // 单次导出的最大行数，0 表示不限制
 }
 
 // NewAuditLogHandler 创建审计日志处理器
-func NewAuditLogHandler(auditRepo repository.AuditLogRepository) *AuditLogHandler {
-	return &AuditLogHandler{
-		auditRepo: auditRepo,
-	}
+// stats_concurrency <= 0 时退化为串行查询
+func NewAuditLogHandler(auditRepo repository.AuditLogRepository, cfg *config.AuditLogConfig) *AuditLogHandler {
+	h := &AuditLogHandler{
+		auditRepo:        auditRepo,
+		statsConcurrency: max(cfg.StatsConcurrency, 1),
+		exportBatchSize:  cfg.ExportBatchSize,
+		exportMaxRows:    cfg.ExportMaxRows,
+	}
+	if h.exportBatchSize <= 0 {
+		h.exportBatchSize = response.DefaultStreamFlushEvery
+	}
+	return h
 }
 
 // GetByID 根据 ID 获取审计日志
@@ -62,6 +80,7 @@ func (h *AuditLogHandler) GetByID(c echo.Context) error {
 // @Security BearerAuth
 // @Param user_id query int false "用户ID"
 // @Param action query string false "操作类型"
+// @Param exports query bool false "仅查询数据导出记录（等同 action=export）"
 // @Param resource query string false "资源类型"
 // @Param ip query string false "IP地址"
 // @Param method query string false "HTTP方法"
@@ -83,7 +102,91 @@ func (h *AuditLogHandler) List(c echo.Context) error {
 		return errors.New(errors.ErrBindQuery, "")
 	}
 
-	// 获取过滤条件
+	filters := parseAuditLogFilters(c)
+
+	// 查询审计日志
+	var logs interface{}
+	var queryErr error
+
+	if len(filters) > 0 {
+		logs, queryErr = h.auditRepo.Search(c.Request().Context(), filters, pagination)
+	} else {
+		logs, queryErr = h.auditRepo.List(c.Request().Context(), pagination)
+	}
+
+	if queryErr != nil {
+		return errors.Wrap(errors.ErrDatabase, queryErr)
+	}
+
+	return response.SuccessWithPagination(c, logs, pagination)
+}
+
+// Export 导出审计日志
+// @Summary 导出审计日志
+// @Description 按与列表相同的过滤条件导出审计日志（不含请求体、响应体），按ID升序以分块传输流式返回 JSON 数组；
+// @Description 最多导出 audit_log.export_max_rows 行。中途出错时数组不完整，错误信息见 X-Stream-Error trailer
+// @Tags 审计日志
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "用户ID"
+// @Param action query string false "操作类型"
+// @Param resource query string false "资源类型"
+// @Param ip query string false "IP地址"
+// @Param method query string false "HTTP方法"
+// @Param path query string false "请求路径"
+// @Param status_code query int false "HTTP状态码"
+// @Param start_time query string false "开始时间(RFC3339格式)"
+// @Param end_time query string false "结束时间(RFC3339格式)"
+// @Success 200 {array} model.AuditLog "审计日志"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /audit-logs/export [get]
+func (h *AuditLogHandler) Export(c echo.Context) error {
+	filters := parseAuditLogFilters(c)
+
+	count, err := response.StreamJSONArray(c, h.exportLogs(c.Request().Context(), filters), h.exportBatchSize)
+	middleware.RecordExport(c, "audit_log", count)
+	if err != nil && !c.Response().Committed {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+	return err
+}
+
+// exportLogs 按ID键集分页逐批读取审计日志，达到导出上限时停止
+func (h *AuditLogHandler) exportLogs(ctx context.Context, filters map[string]interface{}) iter.Seq2[model.AuditLog, error] {
+	return func(yield func(model.AuditLog, error) bool) {
+		var afterID uint
+		remaining := h.exportMaxRows
+		for {
+			limit := h.exportBatchSize
+			if h.exportMaxRows > 0 {
+				limit = min(limit, remaining)
+				if limit == 0 {
+					return
+				}
+			}
+
+			logs, err := h.auditRepo.ListAfter(ctx, filters, afterID, limit)
+			if err != nil {
+				yield(model.AuditLog{}, err)
+				return
+			}
+			for _, log := range logs {
+				if !yield(log, nil) {
+					return
+				}
+			}
+			if len(logs) < limit {
+				return
+			}
+			afterID = logs[len(logs)-1].ID
+			remaining -= len(logs)
+		}
+	}
+}
+
+// parseAuditLogFilters 解析列表与导出共用的查询参数，格式错误的数值与时间参数忽略
+func parseAuditLogFilters(c echo.Context) map[string]interface{} {
 	filters := make(map[string]interface{})
 
 	if userIDStr := c.QueryParam("user_id"); userIDStr != "" {
@@ -96,6 +199,11 @@ func (h *AuditLogHandler) List(c echo.Context) error {
 		filters["action"] = action
 	}
 
+	// 数据导出专用过滤
+	if c.QueryParam("exports") == "true" {
+		filters["action"] = model.AuditActionExport
+	}
+
 	if resource := c.QueryParam("resource"); resource != "" {
 		filters["resource"] = resource
 	}
@@ -131,21 +239,7 @@ func (h *AuditLogHandler) List(c echo.Context) error {
 		}
 	}
 
-	// 查询审计日志
-	var logs interface{}
-	var queryErr error
-
-	if len(filters) > 0 {
-		logs, queryErr = h.auditRepo.Search(c.Request().Context(), filters, pagination)
-	} else {
-		logs, queryErr = h.auditRepo.List(c.Request().Context(), pagination)
-	}
-
-	if queryErr != nil {
-		return errors.Wrap(errors.ErrDatabase, queryErr)
-	}
-
-	return response.SuccessWithPagination(c, logs, pagination)
+	return filters
 }
 
 // ListByUser 查询指定用户的审计日志
@@ -217,21 +311,52 @@ func (h *AuditLogHandler) GetStats(c echo.Context) error {
 		}
 	}
 
+	// 各项统计相互独立，并发查询（受 statsConcurrency 限制），任一失败即取消其余查询
+	var (
+		totalCount, successCount, errorCount  int64
+		actionStats, userStats, resourceStats []map[string]interface{}
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(h.statsConcurrency)
+
 	// 获取总数
-	totalCount, _ := h.auditRepo.CountByTimeRange(ctx, startTime, endTime)
+	g.Go(func() (err error) {
+		totalCount, err = h.auditRepo.CountByTimeRange(gctx, startTime, endTime)
+		return err
+	})
 
 	// 获取成功/失败数量
-	successCount, _ := h.auditRepo.CountByStatus(ctx, 200)
-	errorCount, _ := h.auditRepo.CountByStatus(ctx, 500)
+	g.Go(func() (err error) {
+		successCount, err = h.auditRepo.CountByStatus(gctx, 200)
+		return err
+	})
+	g.Go(func() (err error) {
+		errorCount, err = h.auditRepo.CountByStatus(gctx, 500)
+		return err
+	})
 
 	// 获取操作统计
-	actionStats, _ := h.auditRepo.GetActionStats(ctx, startTime, endTime)
+	g.Go(func() (err error) {
+		actionStats, err = h.auditRepo.GetActionStats(gctx, startTime, endTime)
+		return err
+	})
 
 	// 获取用户统计（Top 10）
-	userStats, _ := h.auditRepo.GetUserStats(ctx, startTime, endTime, 10)
+	g.Go(func() (err error) {
+		userStats, err = h.auditRepo.GetUserStats(gctx, startTime, endTime, 10)
+		return err
+	})
 
 	// 获取资源统计
-	resourceStats, _ := h.auditRepo.GetResourceStats(ctx, startTime, endTime)
+	g.Go(func() (err error) {
+		resourceStats, err = h.auditRepo.GetResourceStats(gctx, startTime, endTime)
+		return err
+	})
+
+	if err := g.Wait(); err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
 
 	stats := map[string]interface{}{
 		"time_range": map[string]interface{}{
@@ -416,3 +541,21 @@ func (h *AuditLogHandler) CleanOldLogs(c echo.Context) error {
 		"before_time":   beforeTime.Format(time.RFC3339),
 	})
 }
+
+// GetPruneMetrics 获取自动清理指标
+// @Summary 审计日志自动清理指标
+// @Description 按保留天数与最大行数自动清理的累计轮数、删除行数及最近一次错误（多实例共享）
+// @Tags 审计日志
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=service.AuditPruneMetrics} "清理指标"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /audit-logs/prune/metrics [get]
+func (h *AuditLogHandler) GetPruneMetrics(c echo.Context) error {
+	metrics, err := service.GetAuditPruneMetrics(c.Request().Context())
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return response.Success(c, metrics)
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/response"
+	customValidator "github.com/cccvno1/nova/pkg/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestEcho 创建与服务端相同校验器与错误处理的 Echo 实例
+func newTestEcho() *echo.Echo {
+	e := echo.New()
+	e.Validator = customValidator.New()
+	e.HTTPErrorHandler = middleware.ErrorHandler()
+	return e
+}
+
+// statsQueryLatency 模拟单条统计查询的数据库耗时
+const statsQueryLatency = 2 * time.Millisecond
+
+// slowAuditRepo 每条统计查询固定耗时的审计日志仓储，等待期间响应 ctx 取消
+type slowAuditRepo struct {
+	repository.AuditLogRepository
+	latency   time.Duration
+	failOn    string // 返回错误的方法名
+	inFlight  atomic.Int32
+	peak      atomic.Int32
+	cancelled atomic.Int32
+}
+
+func (r *slowAuditRepo) query(ctx context.Context, name string) error {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		p := r.peak.Load()
+		if n <= p || r.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+
+	if name == r.failOn {
+		return errors.New("query failed")
+	}
+	select {
+	case <-ctx.Done():
+		r.cancelled.Add(1)
+		return ctx.Err()
+	case <-time.After(r.latency):
+		return nil
+	}
+}
+
+func (r *slowAuditRepo) CountByTimeRange(ctx context.Context, _, _ time.Time) (int64, error) {
+	return 10, r.query(ctx, "CountByTimeRange")
+}
+
+func (r *slowAuditRepo) CountByStatus(ctx context.Context, status int) (int64, error) {
+	return int64(status / 100), r.query(ctx, "CountByStatus")
+}
+
+func (r *slowAuditRepo) GetActionStats(ctx context.Context, _, _ time.Time) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{{"action": "login", "count": 3}}, r.query(ctx, "GetActionStats")
+}
+
+func (r *slowAuditRepo) GetUserStats(ctx context.Context, _, _ time.Time, _ int) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{{"user_id": 1, "count": 5}}, r.query(ctx, "GetUserStats")
+}
+
+func (r *slowAuditRepo) GetResourceStats(ctx context.Context, _, _ time.Time) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{{"resource": "user", "count": 2}}, r.query(ctx, "GetResourceStats")
+}
+
+// getStats 调用统计接口
+func getStats(h *AuditLogHandler) *httptest.ResponseRecorder {
+	e := newTestEcho()
+	e.GET("/audit-logs/stats", h.GetStats)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit-logs/stats", nil))
+	return rec
+}
+
+func TestAuditLogGetStats_Concurrent(t *testing.T) {
+	repo := &slowAuditRepo{latency: 20 * time.Millisecond}
+	h := NewAuditLogHandler(repo, &config.AuditLogConfig{StatsConcurrency: 3})
+
+	start := time.Now()
+	rec := getStats(h)
+	elapsed := time.Since(start)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			TotalCount    int64            `json:"total_count"`
+			SuccessCount  int64            `json:"success_count"`
+			ErrorCount    int64            `json:"error_count"`
+			ActionStats   []map[string]any `json:"action_stats"`
+			UserStats     []map[string]any `json:"user_stats"`
+			ResourceStats []map[string]any `json:"resource_stats"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	d := resp.Data
+	if d.TotalCount != 10 || d.SuccessCount != 2 || d.ErrorCount != 5 ||
+		len(d.ActionStats) != 1 || len(d.UserStats) != 1 || len(d.ResourceStats) != 1 {
+		t.Fatalf("stats = %+v, want every query assembled", d)
+	}
+
+	// 六条查询以 3 的并发执行，约为两轮查询的耗时
+	if peak := repo.peak.Load(); peak != 3 {
+		t.Fatalf("peak concurrency = %d, want 3", peak)
+	}
+	if elapsed >= 6*repo.latency {
+		t.Fatalf("GetStats took %v, want less than sequential %v", elapsed, 6*repo.latency)
+	}
+}
+
+func TestAuditLogGetStats_FailureCancelsOthers(t *testing.T) {
+	repo := &slowAuditRepo{latency: time.Second, failOn: "GetActionStats"}
+	h := NewAuditLogHandler(repo, &config.AuditLogConfig{StatsConcurrency: 6})
+
+	start := time.Now()
+	rec := getStats(h)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("GetStats took %v, want the other queries cancelled", elapsed)
+	}
+	if repo.cancelled.Load() == 0 {
+		t.Fatal("no query observed cancellation")
+	}
+}
+
+func BenchmarkAuditLogGetStats(b *testing.B) {
+	for _, concurrency := range []int{1, 3, 6} {
+		name := "sequential"
+		if concurrency > 1 {
+			name = "concurrency=" + strconv.Itoa(concurrency)
+		}
+		b.Run(name, func(b *testing.B) {
+			h := NewAuditLogHandler(&slowAuditRepo{latency: statsQueryLatency}, &config.AuditLogConfig{StatsConcurrency: concurrency})
+			for i := 0; i < b.N; i++ {
+				if rec := getStats(h); rec.Code != http.StatusOK {
+					b.Fatalf("status = %d", rec.Code)
+				}
+			}
+		})
+	}
+}
+
+// exportAuditRepo 内存中的审计日志，按 ListAfter 分批返回
+type exportAuditRepo struct {
+	repository.AuditLogRepository
+	logs      []model.AuditLog
+	failBatch int // 第几次 ListAfter 调用返回错误（从 1 开始），0 表示不出错
+	calls     int
+}
+
+func (r *exportAuditRepo) ListAfter(_ context.Context, _ map[string]interface{}, afterID uint, limit int) ([]model.AuditLog, error) {
+	r.calls++
+	if r.calls == r.failBatch {
+		return nil, errors.New("connection reset")
+	}
+	var batch []model.AuditLog
+	for _, log := range r.logs {
+		if log.ID > afterID && len(batch) < limit {
+			batch = append(batch, log)
+		}
+	}
+	return batch, nil
+}
+
+func newExportRepo(n int) *exportAuditRepo {
+	repo := &exportAuditRepo{}
+	for i := 1; i <= n; i++ {
+		log := model.AuditLog{Action: "read", Resource: "user", Path: "/api/v1/users"}
+		log.ID = uint(i)
+		repo.logs = append(repo.logs, log)
+	}
+	return repo
+}
+
+// exportLogs 调用导出接口
+func exportLogs(h *AuditLogHandler) *httptest.ResponseRecorder {
+	e := newTestEcho()
+	e.GET("/audit-logs/export", h.Export)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit-logs/export", nil))
+	return rec
+}
+
+func TestAuditLogExport_StreamsInBatches(t *testing.T) {
+	repo := newExportRepo(7)
+	h := NewAuditLogHandler(repo, &config.AuditLogConfig{ExportBatchSize: 3})
+
+	rec := exportLogs(h)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var logs []model.AuditLog
+	if err := json.Unmarshal(rec.Body.Bytes(), &logs); err != nil {
+		t.Fatalf("body is not a JSON array: %v\n%s", err, rec.Body.String())
+	}
+	if len(logs) != 7 || logs[0].ID != 1 || logs[6].ID != 7 {
+		t.Fatalf("exported %d logs, want IDs 1..7 in order", len(logs))
+	}
+	if repo.calls != 3 {
+		t.Fatalf("ListAfter called %d times, want 3 batches", repo.calls)
+	}
+	if trailer := rec.Result().Trailer.Get(response.HeaderStreamError); trailer != "" {
+		t.Fatalf("unexpected stream error trailer %q", trailer)
+	}
+}
+
+func TestAuditLogExport_StopsAtMaxRows(t *testing.T) {
+	h := NewAuditLogHandler(newExportRepo(10), &config.AuditLogConfig{ExportBatchSize: 3, ExportMaxRows: 5})
+
+	var logs []model.AuditLog
+	if err := json.Unmarshal(exportLogs(h).Body.Bytes(), &logs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(logs) != 5 {
+		t.Fatalf("exported %d logs, want export_max_rows 5", len(logs))
+	}
+}
+
+func TestAuditLogExport_ErrorBeforeFirstItem(t *testing.T) {
+	repo := newExportRepo(5)
+	repo.failBatch = 1
+	h := NewAuditLogHandler(repo, &config.AuditLogConfig{ExportBatchSize: 3})
+
+	rec := exportLogs(h)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var resp response.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code == 0 {
+		t.Fatalf("want a regular error response, got %s", rec.Body.String())
+	}
+}
+
+func TestAuditLogExport_ErrorMidStream(t *testing.T) {
+	repo := newExportRepo(7)
+	repo.failBatch = 2
+	h := NewAuditLogHandler(repo, &config.AuditLogConfig{ExportBatchSize: 3})
+
+	rec := exportLogs(h)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 already sent", rec.Code)
+	}
+	var logs []model.AuditLog
+	if err := json.Unmarshal(rec.Body.Bytes(), &logs); err == nil {
+		t.Fatalf("truncated export parsed as a complete array: %s", rec.Body.String())
+	}
+	if trailer := rec.Result().Trailer.Get(response.HeaderStreamError); trailer != "connection reset" {
+		t.Fatalf("%s trailer = %q, want the query error", response.HeaderStreamError, trailer)
+	}
+}
@@ -1,12 +1,20 @@
 package handler
 
 import (
+	stderrors "errors"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cccvno1/nova/internal/service"
 	"github.com/cccvno1/nova/pkg/auth"
+	"github.com/cccvno1/nova/pkg/captcha"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/queue"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
 )
@@ -16,16 +24,37 @@ import (
 type AuthHandler struct {
 	userService *service.UserService
 	blacklist   *auth.TokenBlacklist
+	sessions    *auth.SessionManager
+	rotator     *auth.RefreshRotator // 为 nil 时不轮换刷新令牌
+	captcha     *captcha.Guard       // 为 nil 时不启用登录验证码
+	throttle    *auth.LoginThrottle  // 为 nil 时不启用登录失败延迟
+	magicLinks  *auth.MagicLinkStore // 为 nil 时不启用免密登录链接（路由层同时关闭）
+	queueClient *queue.Client        // 发送免密登录邮件
+	config      *config.AuthConfig
 }
 
 // NewAuthHandler 创建认证处理器实例
-func NewAuthHandler(userService *service.UserService, blacklist *auth.TokenBlacklist) *AuthHandler {
+func NewAuthHandler(userService *service.UserService, blacklist *auth.TokenBlacklist, sessions *auth.SessionManager, rotator *auth.RefreshRotator, captchaGuard *captcha.Guard, loginThrottle *auth.LoginThrottle, magicLinks *auth.MagicLinkStore, queueClient *queue.Client, cfg *config.AuthConfig) *AuthHandler {
 	return &AuthHandler{
 		userService: userService,
 		blacklist:   blacklist,
+		sessions:    sessions,
+		rotator:     rotator,
+		captcha:     captchaGuard,
+		throttle:    loginThrottle,
+		magicLinks:  magicLinks,
+		queueClient: queueClient,
+		config:      cfg,
 	}
 }
 
+// SessionListResponse 活跃会话列表
+type SessionListResponse struct {
+	Count    int            `json:"count"`    // 活跃会话数量
+	Current  string         `json:"current"`  // 当前请求所属会话ID
+	Sessions []auth.Session `json:"sessions"` // 会话列表
+}
+
 // RegisterRequest 用户注册请求参数
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"` // 用户名：3-50字符
@@ -36,13 +65,41 @@ type RegisterRequest struct {
 
 // LoginRequest 用户登录请求参数
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"` // 用户名
-	Password string `json:"password" validate:"required"` // 密码
+	Username     string `json:"username" validate:"required"` // 用户名
+	Password     string `json:"password" validate:"required"` // 密码
+	CaptchaToken string `json:"captcha_token"`                // 验证码令牌（多次登录失败后必填）
 }
 
 // RefreshTokenRequest 刷新令牌请求参数
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"` // 刷新令牌
+	RefreshToken string `json:"refresh_token"` // 刷新令牌（Cookie 模式下可省略，从 Cookie 读取）
+}
+
+// MagicLinkRequest 免密登录链接申请参数
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"` // 账号邮箱
+}
+
+// IntrospectRequest 令牌校验请求参数
+type IntrospectRequest struct {
+	Token string `json:"token" validate:"required"` // 待校验的令牌
+}
+
+// IntrospectResponse 令牌校验结果
+// 令牌无效、过期或已撤销时仅返回 active=false
+type IntrospectResponse struct {
+	Active      bool   `json:"active"`               // 令牌当前是否有效
+	Sub         string `json:"sub,omitempty"`        // 主体（用户ID）
+	Exp         int64  `json:"exp,omitempty"`        // 过期时间（Unix 秒）
+	Iat         int64  `json:"iat,omitempty"`        // 签发时间（Unix 秒）
+	Iss         string `json:"iss,omitempty"`        // 签发者
+	Jti         string `json:"jti,omitempty"`        // 会话ID
+	UserID      uint   `json:"user_id,omitempty"`    // 用户ID
+	Username    string `json:"username,omitempty"`   // 用户名
+	TokenType   string `json:"token_type,omitempty"` // 令牌类型：access/refresh
+	Blacklisted bool   `json:"blacklisted"`          // 是否已被加入黑名单（令牌、用户或会话）
+
+	Ext map[string]any `json:"ext,omitempty"` // 自定义声明
 }
 
 // Register godoc
@@ -79,6 +136,9 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		return err
 	}
 
+	h.recordSession(c, tokenPair)
+	tokenPair = h.issueTokens(c, tokenPair)
+
 	return c.JSON(http.StatusCreated, response.Response{
 		Code:    errors.Success,
 		Message: "success",
@@ -94,7 +154,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 // @Produce json
 // @Param request body LoginRequest true "登录信息"
 // @Success 200 {object} response.Response{data=auth.TokenPair} "登录成功，返回访问令牌和刷新令牌"
-// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 400 {object} response.Response "请求参数错误、需要验证码(code=4005)或验证码无效(code=4006)"
 // @Failure 401 {object} response.Response "用户名或密码错误"
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /auth/login [post]
@@ -108,24 +168,57 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return err
 	}
 
-	tokenPair, err := h.userService.Login(c.Request().Context(), req.Username, req.Password)
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+
+	// 同一 IP 多次登录失败后需要验证码
+	if h.captcha != nil && h.captcha.Required(ctx, ip) {
+		if req.CaptchaToken == "" {
+			return errors.New(errors.ErrCaptchaRequired, "")
+		}
+		ok, err := h.captcha.Verify(ctx, req.CaptchaToken, ip)
+		if err != nil {
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+		if !ok {
+			return errors.New(errors.ErrCaptchaInvalid, "")
+		}
+	}
+
+	tokenPair, err := h.userService.Login(ctx, req.Username, req.Password)
 	if err != nil {
+		if h.captcha != nil {
+			h.captcha.RecordFailure(ctx, ip)
+		}
+		// 同一用户名连续失败时逐次放慢响应；客户端断开时等待随之结束
+		if h.throttle != nil {
+			_ = h.throttle.Failure(ctx, req.Username)
+		}
 		return err
 	}
 
-	return response.Success(c, tokenPair)
+	if h.captcha != nil {
+		h.captcha.Reset(ctx, ip)
+	}
+	if h.throttle != nil {
+		h.throttle.Reset(ctx, req.Username)
+	}
+
+	h.recordSession(c, tokenPair)
+
+	return response.Success(c, h.issueTokens(c, tokenPair))
 }
 
 // RefreshToken godoc
 // @Summary 刷新访问令牌
-// @Description 使用刷新令牌获取新的访问令牌
+// @Description 使用刷新令牌获取新的访问令牌；启用 auth.refresh_token_rotation 时同时返回新的刷新令牌，旧令牌立即失效
 // @Tags 认证
 // @Accept json
 // @Produce json
 // @Param request body RefreshTokenRequest true "刷新令牌"
 // @Success 200 {object} response.Response{data=object} "刷新成功，返回新的访问令牌"
 // @Failure 400 {object} response.Response "请求参数错误"
-// @Failure 401 {object} response.Response "刷新令牌无效或已过期"
+// @Failure 401 {object} response.Response "刷新令牌无效或已过期；code=4007 表示检测到已轮换令牌被重放，需重新登录"
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
@@ -138,16 +231,171 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 		return err
 	}
 
+	// Cookie 模式下从 Cookie 读取刷新令牌
+	if req.RefreshToken == "" && h.config.UseCookies {
+		if cookie, err := c.Cookie(middleware.RefreshTokenCookie); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
+	if req.RefreshToken == "" {
+		return errors.New(errors.ErrInvalidParams, "refresh_token is required")
+	}
+
+	if h.rotator != nil {
+		return h.rotateRefreshToken(c, req.RefreshToken)
+	}
+
+	// 已撤销会话的刷新令牌不可再使用
+	revoked, err := h.sessions.IsTokenRevoked(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if revoked {
+		return errors.New(errors.ErrUnauthorized, "session has been revoked")
+	}
+
 	accessToken, err := h.userService.RefreshToken(c.Request().Context(), req.RefreshToken)
 	if err != nil {
 		return err
 	}
 
+	if h.config.UseCookies {
+		h.setCookie(c, middleware.AccessTokenCookie, accessToken, "/", time.Duration(h.config.AccessTokenDuration)*time.Second)
+		return response.Success(c, nil)
+	}
+
 	return response.Success(c, map[string]string{
 		"access_token": accessToken,
 	})
 }
 
+// rotateRefreshToken 轮换刷新令牌：返回新的令牌对并记录新会话
+// 已轮换的令牌被重放时整个令牌族已撤销，返回 code=4007，客户端应清除本地令牌并重新登录
+func (h *AuthHandler) rotateRefreshToken(c echo.Context, refreshToken string) error {
+	tokenPair, err := h.rotator.Rotate(c.Request().Context(), refreshToken)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, auth.ErrRefreshTokenReused):
+			if h.config.UseCookies {
+				h.clearTokenCookies(c)
+			}
+			return errors.New(errors.ErrTokenReused, "refresh token reuse detected, please log in again")
+		case stderrors.Is(err, auth.ErrRefreshTokenRotated):
+			return errors.New(errors.ErrUnauthorized, "refresh token has already been used")
+		case stderrors.Is(err, auth.ErrSessionRevoked):
+			return errors.New(errors.ErrUnauthorized, "session has been revoked")
+		case stderrors.Is(err, auth.ErrExpiredToken):
+			return errors.New(errors.ErrTokenExpired, "")
+		case stderrors.Is(err, auth.ErrInvalidToken), stderrors.Is(err, auth.ErrTokenClaims):
+			return errors.New(errors.ErrTokenInvalid, "")
+		default:
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+	}
+
+	h.recordSession(c, tokenPair)
+
+	return response.Success(c, h.issueTokens(c, tokenPair))
+}
+
+// RequestMagicLink godoc
+// @Summary 申请免密登录链接
+// @Description 向账号邮箱发送一次性登录链接。为防止探测账号是否存在，邮箱未注册或已禁用时同样返回成功
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body MagicLinkRequest true "账号邮箱"
+// @Success 200 {object} response.Response "已受理"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 429 {object} response.Response "申请过于频繁"
+// @Failure 501 {object} response.Response "功能未启用"
+// @Router /auth/magic-link [post]
+func (h *AuthHandler) RequestMagicLink(c echo.Context) error {
+	var req MagicLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	allowed, err := h.magicLinks.Allow(ctx, req.Email)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if !allowed {
+		return errors.New(errors.ErrTooManyRequests, "too many magic link requests")
+	}
+
+	user, err := h.userService.FindActiveByEmail(ctx, req.Email)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		token, err := h.magicLinks.Issue(ctx, user.ID)
+		if err != nil {
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+		if _, err := h.queueClient.Submit(ctx, service.MagicLinkEmailTaskName, map[string]interface{}{
+			"user_id":    user.ID,
+			"username":   user.Username,
+			"email":      user.Email,
+			"link":       buildMagicLink(h.config.MagicLinkURL, token),
+			"expires_in": int(h.magicLinks.TTL().Seconds()),
+		}, 3); err != nil {
+			return err
+		}
+	}
+
+	return response.Success(c, nil)
+}
+
+// VerifyMagicLink godoc
+// @Summary 免密登录链接校验
+// @Description 校验一次性登录令牌，有效时签发访问令牌和刷新令牌；令牌使用后立即失效
+// @Tags 认证
+// @Produce json
+// @Param token query string true "登录令牌"
+// @Success 200 {object} response.Response{data=auth.TokenPair} "登录成功"
+// @Failure 401 {object} response.Response "令牌无效、已过期或已使用"
+// @Failure 501 {object} response.Response "功能未启用"
+// @Router /auth/magic-link/verify [get]
+func (h *AuthHandler) VerifyMagicLink(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok, err := h.magicLinks.Consume(ctx, c.QueryParam("token"))
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if !ok {
+		return errors.New(errors.ErrUnauthorized, "magic link is invalid or expired")
+	}
+
+	tokenPair, err := h.userService.LoginByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	h.recordSession(c, tokenPair)
+
+	return response.Success(c, h.issueTokens(c, tokenPair))
+}
+
+// buildMagicLink 在链接地址上附加 token 查询参数
+func buildMagicLink(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
 // Logout godoc
 // @Summary 用户登出
 // @Description 登出当前用户，将访问令牌加入黑名单
@@ -160,13 +408,8 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(c echo.Context) error {
-	// 从Authorization头提取token
-	authHeader := c.Request().Header.Get("Authorization")
-	if authHeader == "" {
-		return errors.New(errors.ErrUnauthorized, "")
-	}
-
-	token := strings.TrimPrefix(authHeader, "Bearer ")
+	// 从Authorization头（或 Cookie）提取token
+	token := middleware.ExtractToken(c)
 	if token == "" {
 		return errors.New(errors.ErrUnauthorized, "")
 	}
@@ -176,5 +419,195 @@ func (h *AuthHandler) Logout(c echo.Context) error {
 		return errors.Wrap(errors.ErrInternalServer, err)
 	}
 
+	// 从活跃会话列表中移除
+	if sessionID := middleware.GetSessionID(c); sessionID != "" {
+		_ = h.sessions.Remove(c.Request().Context(), middleware.GetUserID(c), sessionID)
+	}
+
+	if h.config.UseCookies {
+		h.clearTokenCookies(c)
+	}
+
 	return response.Success(c, nil)
 }
+
+// Introspect godoc
+// @Summary 校验令牌
+// @Description 供网关等内部服务校验 Nova 签发的令牌，返回令牌声明及有效性（含黑名单状态）。需 X-Service-Token 或来源 IP 在白名单内
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param X-Service-Token header string false "服务间调用凭证"
+// @Param request body IntrospectRequest true "待校验的令牌"
+// @Success 200 {object} response.Response{data=IntrospectResponse} "校验结果"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "调用方未授权"
+// @Router /auth/introspect [post]
+func (h *AuthHandler) Introspect(c echo.Context) error {
+	var req IntrospectRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	claims, err := h.sessions.ParseClaims(req.Token)
+	if err != nil {
+		return response.Success(c, IntrospectResponse{Active: false})
+	}
+
+	result := IntrospectResponse{
+		Sub:       strconv.FormatUint(uint64(claims.UserID), 10),
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		TokenType: string(claims.Type),
+		Ext:       claims.Extra,
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+
+	if h.blacklist != nil {
+		ctx := c.Request().Context()
+		blacklisted, err := h.blacklist.IsInBlacklist(ctx, req.Token)
+		if err != nil {
+			return errors.New(errors.ErrInternalServer, "failed to check token status")
+		}
+		if !blacklisted {
+			if blacklisted, err = h.blacklist.IsUserInBlacklist(ctx, claims.UserID); err != nil {
+				return errors.New(errors.ErrInternalServer, "failed to check token status")
+			}
+		}
+		if !blacklisted {
+			if blacklisted, err = h.blacklist.IsSessionInBlacklist(ctx, claims.ID); err != nil {
+				return errors.New(errors.ErrInternalServer, "failed to check token status")
+			}
+		}
+		result.Blacklisted = blacklisted
+	}
+	result.Active = !result.Blacklisted
+
+	return response.Success(c, result)
+}
+
+// ListSessions godoc
+// @Summary 获取活跃会话
+// @Description 获取当前用户已登录的设备（会话）列表及数量
+// @Tags 认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=SessionListResponse} "活跃会话列表"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/sessions [get]
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	sessions, err := h.sessions.ListActiveSessions(c.Request().Context(), userID)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	return response.Success(c, SessionListResponse{
+		Count:    len(sessions),
+		Current:  middleware.GetSessionID(c),
+		Sessions: sessions,
+	})
+}
+
+// RevokeSession godoc
+// @Summary 撤销会话
+// @Description 根据会话ID（JTI）撤销当前用户的某个会话，该设备需重新登录
+// @Tags 认证
+// @Produce json
+// @Security BearerAuth
+// @Param jti path string true "会话ID"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 404 {object} response.Response "会话不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/sessions/{jti} [delete]
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	sessionID := c.Param("jti")
+
+	ok, err := h.sessions.RevokeSession(c.Request().Context(), userID, sessionID)
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if !ok {
+		return errors.New(errors.ErrNotFound, "session not found")
+	}
+
+	return response.Success(c, nil)
+}
+
+// recordSession 记录登录会话（失败不影响登录）
+func (h *AuthHandler) recordSession(c echo.Context, tokenPair *auth.TokenPair) {
+	claims, err := h.sessions.ParseClaims(tokenPair.AccessToken)
+	if err != nil {
+		return
+	}
+	_ = h.sessions.Record(c.Request().Context(), claims.UserID, tokenPair.SessionID, c.Request().UserAgent(), c.RealIP())
+}
+
+// issueTokens Cookie 模式下将令牌写入 HttpOnly Cookie，并从响应体中移除令牌
+func (h *AuthHandler) issueTokens(c echo.Context, tokenPair *auth.TokenPair) *auth.TokenPair {
+	if !h.config.UseCookies {
+		return tokenPair
+	}
+
+	h.setCookie(c, middleware.AccessTokenCookie, tokenPair.AccessToken, "/", time.Duration(h.config.AccessTokenDuration)*time.Second)
+	// 刷新令牌只在认证接口下发送
+	h.setCookie(c, middleware.RefreshTokenCookie, tokenPair.RefreshToken, h.config.RefreshCookiePath, time.Duration(h.config.RefreshTokenDuration)*time.Second)
+
+	return &auth.TokenPair{
+		ExpiresIn: tokenPair.ExpiresIn,
+		SessionID: tokenPair.SessionID,
+	}
+}
+
+// clearTokenCookies 清除令牌 Cookie
+func (h *AuthHandler) clearTokenCookies(c echo.Context) {
+	h.setCookie(c, middleware.AccessTokenCookie, "", "/", -1)
+	h.setCookie(c, middleware.RefreshTokenCookie, "", h.config.RefreshCookiePath, -1)
+}
+
+// setCookie 写入 HttpOnly 令牌 Cookie，maxAge < 0 表示删除
+func (h *AuthHandler) setCookie(c echo.Context, name, value, path string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   h.config.CookieDomain,
+		HttpOnly: true,
+		Secure:   h.config.CookieSecure,
+		SameSite: parseSameSite(h.config.CookieSameSite),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(0, 0)
+	} else {
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	}
+	c.SetCookie(cookie)
+}
+
+// parseSameSite 解析 SameSite 配置，默认 Strict
+func parseSameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
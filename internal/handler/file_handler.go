@@ -1,29 +1,62 @@
 package handler
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
 	"github.com/cccvno1/nova/pkg/middleware"
 	"github.com/cccvno1/nova/pkg/response"
+	"github.com/cccvno1/nova/pkg/storage"
 	"github.com/labstack/echo/v4"
 )
 
+// maxBatchGetFiles 单次批量获取文件的最大ID数
+const maxBatchGetFiles = 100
+
+// BatchGetFilesRequest 批量获取文件请求
+type BatchGetFilesRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// FileListData 文件列表分页数据，附带筛选结果（全部页）的总大小
+type FileListData struct {
+	response.PageData
+	TotalSize int64 `json:"total_size"` // 筛选条件下全部文件的总大小（字节）
+}
+
 // FileHandler 文件上传处理器
 type FileHandler struct {
 	fileService service.FileService
+	rbacService service.RBACService
+	downloads   *cache.Semaphore // 单用户并发下载限制，为 nil 时不限制
+
+	publicBaseURL string // 公开分享链接的对外访问地址
 }
 
 // NewFileHandler 创建文件上传处理器
-func NewFileHandler(fileService service.FileService) *FileHandler {
-	return &FileHandler{
-		fileService: fileService,
+func NewFileHandler(fileService service.FileService, rbacService service.RBACService, cfg *config.UploadConfig) *FileHandler {
+	h := &FileHandler{
+		fileService:   fileService,
+		rbacService:   rbacService,
+		publicBaseURL: strings.TrimRight(cfg.PublicLinkBaseURL, "/"),
 	}
+	if cfg.MaxConcurrentDownloadsPerUser > 0 {
+		h.downloads = cache.NewSemaphore("download_slots", cfg.MaxConcurrentDownloadsPerUser,
+			time.Duration(cfg.DownloadSlotTimeout)*time.Second)
+	}
+	return h
 }
 
 // Upload godoc
@@ -69,6 +102,133 @@ func (h *FileHandler) Upload(c echo.Context) error {
 	return response.Success(c, fileResp)
 }
 
+// InitChunkUpload 初始化分片上传
+// @Summary 初始化分片上传
+// @Description 创建分片上传会话，返回上传ID；total_size、total_chunks 可选，用于进度展示与合并校验
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.InitChunkUploadRequest true "上传信息"
+// @Success 200 {object} response.Response{data=service.UploadStatus} "上传会话"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 401 {object} response.Response "未授权"
+// @Router /files/upload/chunked [post]
+func (h *FileHandler) InitChunkUpload(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		return errors.New(errors.ErrUnauthorized, "user not authenticated")
+	}
+
+	var req service.InitChunkUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	status, err := h.fileService.InitChunkUpload(c.Request().Context(), req, userID)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, status)
+}
+
+// UploadChunk 上传分片
+// @Summary 上传分片
+// @Description 上传指定序号的分片（序号从0开始），重复上传同一序号会覆盖旧分片；返回最新进度
+// @Tags 文件管理
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "上传ID"
+// @Param index path int true "分片序号"
+// @Param chunk formData file true "分片内容"
+// @Success 200 {object} response.Response{data=service.UploadStatus} "上传进度"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 404 {object} response.Response "上传不存在或已过期"
+// @Router /files/upload/{id}/chunks/{index} [put]
+func (h *FileHandler) UploadChunk(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		return errors.New(errors.ErrUnauthorized, "user not authenticated")
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid chunk index")
+	}
+
+	fileHeader, err := c.FormFile("chunk")
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "chunk is required")
+	}
+	chunk, err := fileHeader.Open()
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalidParams, err)
+	}
+	defer chunk.Close()
+
+	status, err := h.fileService.UploadChunk(c.Request().Context(), c.Param("id"), index, chunk, fileHeader.Size, userID)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, status)
+}
+
+// GetUploadStatus 获取分片上传进度
+// @Summary 获取分片上传进度
+// @Description 返回已接收字节数、总字节数（已知时）与已接收的分片序号，用于展示进度与断点续传
+// @Tags 文件管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "上传ID"
+// @Success 200 {object} response.Response{data=service.UploadStatus} "上传进度"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 404 {object} response.Response "上传不存在或已过期"
+// @Router /files/upload/{id}/status [get]
+func (h *FileHandler) GetUploadStatus(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		return errors.New(errors.ErrUnauthorized, "user not authenticated")
+	}
+
+	status, err := h.fileService.GetUploadStatus(c.Request().Context(), c.Param("id"), userID)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, status)
+}
+
+// CompleteChunkUpload 完成分片上传
+// @Summary 完成分片上传
+// @Description 校验分片完整后按序合并并创建文件记录，成功后清理分片与上传进度
+// @Tags 文件管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "上传ID"
+// @Success 200 {object} response.Response{data=service.FileResponse} "文件信息"
+// @Failure 400 {object} response.Response "分片不完整"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 404 {object} response.Response "上传不存在或已过期"
+// @Failure 409 {object} response.Response "上传正在合并"
+// @Router /files/upload/{id}/complete [post]
+func (h *FileHandler) CompleteChunkUpload(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		return errors.New(errors.ErrUnauthorized, "user not authenticated")
+	}
+
+	file, err := h.fileService.CompleteChunkUpload(c.Request().Context(), c.Param("id"), userID)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, file)
+}
+
 // Download 下载文件
 // @Summary 下载文件
 // @Description 根据文件ID下载文件，支持断点续传
@@ -81,6 +241,7 @@ func (h *FileHandler) Upload(c echo.Context) error {
 // @Failure 400 {object} response.Response "请求参数错误"
 // @Failure 401 {object} response.Response "未授权"
 // @Failure 404 {object} response.Response "文件不存在"
+// @Failure 429 {object} response.Response "同时进行的下载过多"
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /files/{id}/download [get]
 func (h *FileHandler) Download(c echo.Context) error {
@@ -93,6 +254,24 @@ func (h *FileHandler) Download(c echo.Context) error {
 	// 获取当前用户 ID
 	userID := middleware.GetUserID(c)
 
+	// 占用下载槽位，流式传输结束（含客户端中途断开）后释放
+	if h.downloads != nil {
+		slot := strconv.FormatUint(uint64(userID), 10)
+		token, ok, err := h.downloads.Acquire(c.Request().Context(), slot)
+		if err != nil {
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+		if !ok {
+			return errors.New(errors.ErrTooManyRequests, "too many concurrent downloads")
+		}
+		defer func() {
+			// 请求上下文可能已因客户端断开而取消，使用独立上下文释放
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_ = h.downloads.Release(ctx, slot, token)
+		}()
+	}
+
 	// 下载文件
 	reader, file, err := h.fileService.Download(c.Request().Context(), uint(id), userID)
 	if err != nil {
@@ -100,9 +279,30 @@ func (h *FileHandler) Download(c echo.Context) error {
 	}
 	defer reader.Close()
 
+	return streamFile(c, reader, file)
+}
+
+// streamFile 以附件形式返回文件内容
+func streamFile(c echo.Context, reader io.ReadCloser, file *model.File) error {
 	// 设置响应头
-	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.OriginalName))
+	c.Response().Header().Set("Content-Disposition", contentDisposition(file.OriginalName))
 	c.Response().Header().Set("Content-Type", file.MimeType)
+
+	// 压缩存储的文件：客户端支持 gzip 时直接返回压缩内容，否则解压后返回
+	if file.ContentEncoding == storage.EncodingGzip {
+		c.Response().Header().Add("Vary", "Accept-Encoding")
+		if strings.Contains(c.Request().Header.Get("Accept-Encoding"), storage.EncodingGzip) {
+			c.Response().Header().Set("Content-Encoding", storage.EncodingGzip)
+			return c.Stream(http.StatusOK, file.MimeType, reader)
+		}
+
+		decompressed, err := storage.NewDecompressReader(reader)
+		if err != nil {
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+		reader = decompressed // 底层读取器由调用方关闭
+	}
+
 	c.Response().Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
 
 	// 返回文件内容
@@ -142,6 +342,181 @@ func (h *FileHandler) Delete(c echo.Context) error {
 	return response.SuccessWithMessage(c, "file deleted successfully", nil)
 }
 
+// CreatePublicLinkRequest 创建公开分享链接请求
+type CreatePublicLinkRequest struct {
+	ExpiresIn int `json:"expires_in" validate:"required,min=1"` // 有效期（秒），不超过 upload.public_link_max_ttl
+}
+
+// PublicLinkResponse 公开分享链接
+type PublicLinkResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"` // 无需登录即可访问的下载地址
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreatePublicLink 创建公开分享链接
+// @Summary 创建公开分享链接
+// @Description 为文件生成签名的限时下载链接，持有链接者无需登录即可下载（仅上传者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件ID"
+// @Param request body CreatePublicLinkRequest true "有效期"
+// @Success 200 {object} response.Response{data=PublicLinkResponse} "分享链接"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "无权限分享该文件"
+// @Failure 404 {object} response.Response "文件不存在"
+// @Failure 501 {object} response.Response "未启用公开分享链接"
+// @Router /files/{id}/public-links [post]
+func (h *FileHandler) CreatePublicLink(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid file id")
+	}
+
+	var req CreatePublicLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	expiry := time.Duration(req.ExpiresIn) * time.Second
+	token, err := h.fileService.CreatePublicLink(c.Request().Context(), uint(id), expiry, middleware.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, PublicLinkResponse{
+		Token:     token,
+		URL:       fmt.Sprintf("%s/api/v1/public/files/%s", h.publicBaseURL, token),
+		ExpiresAt: time.Now().Add(expiry),
+	})
+}
+
+// RevokePublicLink 撤销公开分享链接
+// @Summary 撤销公开分享链接
+// @Description 使分享链接在到期前失效（仅文件上传者）
+// @Tags 文件管理
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "分享令牌"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 403 {object} response.Response "无权限撤销该链接"
+// @Failure 404 {object} response.Response "链接无效或已过期"
+// @Router /files/public-links/{token} [delete]
+func (h *FileHandler) RevokePublicLink(c echo.Context) error {
+	if err := h.fileService.RevokePublicLink(c.Request().Context(), c.Param("token"), middleware.GetUserID(c)); err != nil {
+		return err
+	}
+	return response.SuccessWithMessage(c, "链接已撤销", nil)
+}
+
+// PublicDownload 通过公开分享链接下载文件
+// @Summary 公开下载文件
+// @Description 校验分享令牌的签名、有效期与撤销状态后返回文件内容，无需登录，按 IP 限流
+// @Tags 文件管理
+// @Produce application/octet-stream
+// @Param token path string true "分享令牌"
+// @Success 200 {file} binary "文件流"
+// @Failure 404 {object} response.Response "链接无效、已过期或已撤销"
+// @Failure 429 {object} response.Response "访问过于频繁"
+// @Router /public/files/{token} [get]
+func (h *FileHandler) PublicDownload(c echo.Context) error {
+	reader, file, err := h.fileService.OpenPublicLink(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return streamFile(c, reader, file)
+}
+
+// FileTagsRequest 文件标签请求
+type FileTagsRequest struct {
+	Tags []string `json:"tags" validate:"max=20,dive,max=50"`
+}
+
+// SetTags 替换文件标签
+// @Summary 设置文件标签
+// @Description 整体替换文件的标签（仅上传者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件ID"
+// @Param request body FileTagsRequest true "标签列表"
+// @Success 200 {object} response.Response{data=service.FileResponse} "更新后的文件信息"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "无权限修改该文件"
+// @Failure 404 {object} response.Response "文件不存在"
+// @Router /files/{id}/tags [put]
+func (h *FileHandler) SetTags(c echo.Context) error {
+	return h.updateTags(c, service.TagOperationSet)
+}
+
+// AddTags 追加文件标签
+// @Summary 添加文件标签
+// @Description 为文件追加标签，已存在的标签会被忽略（仅上传者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件ID"
+// @Param request body FileTagsRequest true "标签列表"
+// @Success 200 {object} response.Response{data=service.FileResponse} "更新后的文件信息"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "无权限修改该文件"
+// @Failure 404 {object} response.Response "文件不存在"
+// @Router /files/{id}/tags [post]
+func (h *FileHandler) AddTags(c echo.Context) error {
+	return h.updateTags(c, service.TagOperationAdd)
+}
+
+// RemoveTags 移除文件标签
+// @Summary 移除文件标签
+// @Description 从文件中移除指定标签（仅上传者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件ID"
+// @Param request body FileTagsRequest true "标签列表"
+// @Success 200 {object} response.Response{data=service.FileResponse} "更新后的文件信息"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "无权限修改该文件"
+// @Failure 404 {object} response.Response "文件不存在"
+// @Router /files/{id}/tags [delete]
+func (h *FileHandler) RemoveTags(c echo.Context) error {
+	return h.updateTags(c, service.TagOperationRemove)
+}
+
+// updateTags 解析请求并执行标签操作
+func (h *FileHandler) updateTags(c echo.Context, op service.TagOperation) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid file id")
+	}
+
+	var req FileTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	userID := middleware.GetUserID(c)
+	fileResp, err := h.fileService.UpdateTags(c.Request().Context(), uint(id), userID, req.Tags, op)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, fileResp)
+}
+
 // GetByID 获取文件信息
 // @Summary 获取文件详情
 // @Description 根据ID获取文件的详细信息
@@ -150,9 +525,12 @@ func (h *FileHandler) Delete(c echo.Context) error {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "文件ID"
-// @Success 200 {object} response.Response{data=model.File} "文件详情"
+// @Param include_deleted query bool false "是否包含已删除的文件（仅管理员）"
+// @Param expand query string false "附带关联信息，逗号分隔（支持 uploader）"
+// @Success 200 {object} response.Response{data=service.FileResponse} "文件详情"
 // @Failure 400 {object} response.Response "请求参数错误"
 // @Failure 401 {object} response.Response "未授权"
+// @Failure 403 {object} response.Response "无权限查看已删除文件"
 // @Failure 404 {object} response.Response "文件不存在"
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /files/{id} [get]
@@ -163,8 +541,26 @@ func (h *FileHandler) GetByID(c echo.Context) error {
 		return errors.New(errors.ErrInvalidParams, "invalid file id")
 	}
 
-	// 获取文件信息
-	file, err := h.fileService.GetByID(c.Request().Context(), uint(id))
+	// 管理员（当前域）可查看已删除的文件
+	if c.QueryParam("include_deleted") == "true" {
+		if err := requireRoleLevel(c, h.rbacService, middleware.GetDomain(c), adminRoleLevel, "only admin can view deleted files"); err != nil {
+			return err
+		}
+
+		file, err := h.fileService.GetByIDWithDeleted(c.Request().Context(), uint(id))
+		if err != nil {
+			return err
+		}
+		return response.Success(c, file)
+	}
+
+	// 获取文件信息（expand=uploader 时附带上传者信息）
+	var file *service.FileResponse
+	if hasExpand(c, "uploader") {
+		file, err = h.fileService.GetByIDWithUploader(c.Request().Context(), uint(id))
+	} else {
+		file, err = h.fileService.GetByID(c.Request().Context(), uint(id))
+	}
 	if err != nil {
 		return err
 	}
@@ -172,17 +568,64 @@ func (h *FileHandler) GetByID(c echo.Context) error {
 	return response.Success(c, file)
 }
 
+// BatchGet 批量获取文件信息
+// @Summary 批量获取文件信息
+// @Description 根据ID列表批量获取文件信息，按请求顺序返回；不存在或无权访问的文件直接省略
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BatchGetFilesRequest true "文件ID列表（最多100个）"
+// @Success 200 {object} response.Response{data=[]service.FileResponse} "文件列表"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /files/batch-get [post]
+func (h *FileHandler) BatchGet(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		return errors.New(errors.ErrUnauthorized, "user not authenticated")
+	}
+
+	var req BatchGetFilesRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if len(req.IDs) == 0 {
+		return response.Success(c, []service.FileResponse{})
+	}
+	if len(req.IDs) > maxBatchGetFiles {
+		return errors.New(errors.ErrInvalidParams, fmt.Sprintf("at most %d ids per request", maxBatchGetFiles))
+	}
+
+	// 管理员（当前域）可获取任意用户的文件，普通用户仅返回自己上传的文件
+	level, err := h.rbacService.GetUserMaxRoleLevel(c.Request().Context(), userID, middleware.GetDomain(c))
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	files, err := h.fileService.GetByIDs(c.Request().Context(), req.IDs, userID, level >= adminRoleLevel)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, files)
+}
+
 // List 获取文件列表
 // @Summary 获取文件列表
-// @Description 获取当前用户的文件列表，支持分类过滤和分页
+// @Description 获取当前用户的文件列表，支持分类/标签过滤、按大小或上传时间排序和分页，并返回筛选结果的总大小
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param category query string false "文件分类" Enums(avatar, document, image, video, audio, other)
+// @Param tag query string false "按标签过滤"
+// @Param order_by query string false "排序字段" Enums(size, created_at)
+// @Param order query string false "排序方向" Enums(asc, desc) default(desc)
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10)
-// @Success 200 {object} response.Response{data=[]model.File} "文件列表"
+// @Success 200 {object} response.Response{data=FileListData} "文件列表"
 // @Failure 400 {object} response.Response "请求参数错误"
 // @Failure 401 {object} response.Response "未授权"
 // @Failure 500 {object} response.Response "服务器内部错误"
@@ -194,8 +637,13 @@ func (h *FileHandler) List(c echo.Context) error {
 		return errors.New(errors.ErrUnauthorized, "user not authenticated")
 	}
 
-	// 获取分类过滤（可选）
-	category := c.QueryParam("category")
+	// 获取过滤与排序参数（可选）
+	opts := service.FileListOptions{
+		Category: c.QueryParam("category"),
+		Tag:      c.QueryParam("tag"),
+		OrderBy:  c.QueryParam("order_by"),
+		Order:    c.QueryParam("order"),
+	}
 
 	// 分页参数
 	pagination := &database.Pagination{}
@@ -204,12 +652,20 @@ func (h *FileHandler) List(c echo.Context) error {
 	}
 
 	// 查询文件列表
-	files, err := h.fileService.List(c.Request().Context(), userID, category, pagination)
+	files, totalSize, err := h.fileService.List(c.Request().Context(), userID, opts, pagination)
 	if err != nil {
 		return err
 	}
 
-	return response.SuccessWithPagination(c, files, pagination)
+	return response.Success(c, FileListData{
+		PageData: response.PageData{
+			List:  files,
+			Total: pagination.Total,
+			Page:  pagination.Page,
+			Size:  pagination.PageSize,
+		},
+		TotalSize: totalSize,
+	})
 }
 
 // Search 搜索文件
@@ -321,3 +777,44 @@ func (h *FileHandler) UploadAvatar(c echo.Context) error {
 
 	return response.Success(c, fileResp)
 }
+
+// contentDisposition 构造附件下载的 Content-Disposition 头
+// filename 为仅含可打印 ASCII 的回退名（其余字符替换为 _），filename* 按 RFC 5987 以 UTF-8 百分号编码完整文件名，
+// 文件名中的控制字符、引号等不会原样进入响应头
+func contentDisposition(name string) string {
+	var fallback, encoded strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(name) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback.String(), encoded.String())
+}
+
+// isAttrChar 判断字节是否为 RFC 5987 attr-char，可不经编码出现在 filename* 中
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// hasExpand 判断 expand 查询参数（逗号分隔）是否包含指定字段
+func hasExpand(c echo.Context, field string) bool {
+	for _, item := range strings.Split(c.QueryParam("expand"), ",") {
+		if strings.TrimSpace(item) == field {
+			return true
+		}
+	}
+	return false
+}
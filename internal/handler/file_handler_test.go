@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// gzipFile 返回压缩存储的文件记录与其存储内容
+func gzipFile(t *testing.T, original []byte) (*model.File, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := storage.Compress(&buf, bytes.NewReader(original)); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	return &model.File{
+		OriginalName:    "app.log",
+		MimeType:        "text/plain",
+		Size:            int64(len(original)),
+		ContentEncoding: storage.EncodingGzip,
+	}, buf.Bytes()
+}
+
+func TestStreamFile_DecompressesForPlainClients(t *testing.T) {
+	original := []byte(strings.Repeat("line of log output\n", 200))
+	file, stored := gzipFile(t, original)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	if err := streamFile(c, io.NopCloser(bytes.NewReader(stored)), file); err != nil {
+		t.Fatalf("streamFile: %v", err)
+	}
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("Content-Encoding = %q, want none", enc)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), original) {
+		t.Fatalf("body is %d bytes, want the original %d bytes", rec.Body.Len(), len(original))
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != strconv.Itoa(len(original)) {
+		t.Fatalf("Content-Length = %q, want original size", cl)
+	}
+}
+
+func TestStreamFile_PassesGzipThrough(t *testing.T) {
+	original := []byte(strings.Repeat("line of log output\n", 200))
+	file, stored := gzipFile(t, original)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if err := streamFile(c, io.NopCloser(bytes.NewReader(stored)), file); err != nil {
+		t.Fatalf("streamFile: %v", err)
+	}
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", enc)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), stored) {
+		t.Fatal("body differs from stored gzip content")
+	}
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if data, _ := io.ReadAll(gr); !bytes.Equal(data, original) {
+		t.Fatal("decompressed body differs from original")
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"ascii", "report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"quotes", `say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"backslash", `a\b.txt`, `attachment; filename="a_b.txt"; filename*=UTF-8''a%5Cb.txt`},
+		{"crlf", "a.txt\r\nSet-Cookie: x=1", `attachment; filename="a.txt__Set-Cookie: x=1"; filename*=UTF-8''a.txt%0D%0ASet-Cookie%3A%20x%3D1`},
+		{"unicode", "报告.pdf", `attachment; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contentDisposition(tt.input)
+			if got != tt.want {
+				t.Fatalf("contentDisposition(%q) =\n%s\nwant\n%s", tt.input, got, tt.want)
+			}
+			if strings.ContainsAny(got, "\r\n") {
+				t.Fatalf("header value contains CR/LF: %q", got)
+			}
+		})
+	}
+}
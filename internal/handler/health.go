@@ -1,21 +1,164 @@
 package handler
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/queue"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
 )
 
-type HealthHandler struct{}
+const (
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded" // 可用但需要关注（如队列积压）
+	healthStatusDown     = "down"     // 依赖不可用
+
+	// healthCheckTimeout 单次健康检查的超时时间
+	healthCheckTimeout = 3 * time.Second
+)
+
+// ComponentHealth 单个依赖组件的健康状态
+type ComponentHealth struct {
+	Status  string      `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// HealthReport 健康检查汇总结果，status 取各组件中最差的状态
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Service    string                     `json:"service"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+type HealthHandler struct {
+	queueDepth  *queue.DepthMonitor
+	concurrency *middleware.ConcurrencyLimiter
+	migrator    *database.Migrator
+	migrated    atomic.Pointer[database.MigrationStatus] // 迁移全部完成后的状态（进程内不会再出现新的待执行迁移，确认后不再查询）
+	logger      *slog.Logger
+}
+
+// NewHealthHandler 创建健康检查处理器
+// queueDepth 为 nil（未启用队列）时不检查队列积压；concurrency 用于报告当前处理中的请求数；
+// migrator 用于就绪检查中的迁移状态
+func NewHealthHandler(queueDepth *queue.DepthMonitor, concurrency *middleware.ConcurrencyLimiter, migrator *database.Migrator, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		queueDepth:  queueDepth,
+		concurrency: concurrency,
+		migrator:    migrator,
+		logger:      logger,
+	}
+}
+
+// Ready 就绪检查：数据库迁移全部完成前返回 503，供发布时的就绪探针使用
+// 响应中包含当前迁移版本与待执行的迁移
+func (h *HealthHandler) Ready(c echo.Context) error {
+	if status := h.migrated.Load(); status != nil {
+		return response.Success(c, status)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), healthCheckTimeout)
+	defer cancel()
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+	status, err := h.migrator.Status(ctx)
+	if err != nil {
+		h.logger.Warn("migration status check failed", "error", err)
+		return c.JSON(http.StatusServiceUnavailable, response.Response{
+			Code:    errors.ErrServiceUnavailable,
+			Message: "migration status unavailable",
+		})
+	}
+	if !status.UpToDate() {
+		return c.JSON(http.StatusServiceUnavailable, response.Response{
+			Code:    errors.ErrServiceUnavailable,
+			Message: "database migrations pending",
+			Data:    status,
+		})
+	}
+
+	h.migrated.Store(status)
+	return response.Success(c, status)
 }
 
+// Check 汇总数据库、Redis 与队列积压的健康状态
+// 任一依赖不可用时返回 503，队列积压（degraded）仍返回 200，便于负载均衡继续转发流量
 func (h *HealthHandler) Check(c echo.Context) error {
-	return response.Success(c, echo.Map{
-		"status":  "ok",
-		"service": "nova",
-	})
+	ctx, cancel := context.WithTimeout(c.Request().Context(), healthCheckTimeout)
+	defer cancel()
+
+	report := &HealthReport{
+		Status:     healthStatusOK,
+		Service:    "nova",
+		Components: make(map[string]ComponentHealth),
+	}
+
+	report.add("database", h.checkError("database", database.HealthCheck()))
+	report.add("redis", h.checkError("redis", cache.HealthCheck()))
+	if h.queueDepth != nil {
+		report.add("queue", h.checkQueue(ctx))
+	}
+	if h.concurrency != nil {
+		report.add("concurrency", ComponentHealth{Status: healthStatusOK, Details: h.concurrency.Stats()})
+	}
+
+	if report.Status == healthStatusDown {
+		return c.JSON(http.StatusServiceUnavailable, response.Response{
+			Code:    errors.ErrInternalServer,
+			Message: "service unhealthy",
+			Data:    report,
+		})
+	}
+	return response.Success(c, report)
+}
+
+// checkQueue 采样队列长度，持续超过告警阈值时报告 degraded
+func (h *HealthHandler) checkQueue(ctx context.Context) ComponentHealth {
+	status, err := h.queueDepth.Observe(ctx)
+	if err != nil {
+		h.logger.Warn("queue depth check failed", "error", err)
+		return ComponentHealth{Status: healthStatusDown, Error: "queue depth unavailable"}
+	}
+	if status.Status == queue.DepthStatusDegraded {
+		return ComponentHealth{Status: healthStatusDegraded, Details: status}
+	}
+	return ComponentHealth{Status: healthStatusOK, Details: status}
+}
+
+// add 记录组件状态并按 ok < degraded < down 更新汇总状态
+func (r *HealthReport) add(name string, component ComponentHealth) {
+	r.Components[name] = component
+	if healthSeverity(component.Status) > healthSeverity(r.Status) {
+		r.Status = component.Status
+	}
+}
+
+// checkError 将依赖检查的错误转换为组件状态（不向外暴露具体错误信息）
+func (h *HealthHandler) checkError(component string, err error) ComponentHealth {
+	if err != nil {
+		h.logger.Warn("health check failed", "component", component, "error", err)
+		return ComponentHealth{Status: healthStatusDown, Error: "unavailable"}
+	}
+	return ComponentHealth{Status: healthStatusOK}
+}
+
+func healthSeverity(status string) int {
+	switch status {
+	case healthStatusDegraded:
+		return 1
+	case healthStatusDown:
+		return 2
+	default:
+		return 0
+	}
 }
 
 type PingRequest struct {
@@ -0,0 +1,11 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/cccvno1/nova/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
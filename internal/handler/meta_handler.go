@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/response"
+	"github.com/labstack/echo/v4"
+)
+
+// MetaHandler 系统元信息处理器
+type MetaHandler struct {
+	cfg *config.Config
+}
+
+// NewMetaHandler 创建系统元信息处理器
+func NewMetaHandler(cfg *config.Config) *MetaHandler {
+	return &MetaHandler{
+		cfg: cfg,
+	}
+}
+
+// FeaturesResponse 功能开关状态
+type FeaturesResponse struct {
+	Queue     bool `json:"queue"`      // 任务队列
+	AuditLog  bool `json:"audit_log"`  // 审计日志
+	RateLimit bool `json:"rate_limit"` // 限流
+	Thumbnail bool `json:"thumbnail"`  // 图片缩略图
+}
+
+// Features godoc
+// @Summary 获取功能开关
+// @Description 返回服务端已启用的功能，客户端可据此隐藏不可用的界面
+// @Tags 系统
+// @Produce json
+// @Success 200 {object} response.Response{data=FeaturesResponse} "功能开关状态"
+// @Router /meta/features [get]
+func (h *MetaHandler) Features(c echo.Context) error {
+	return response.Success(c, FeaturesResponse{
+		Queue:     h.cfg.Queue.Enabled,
+		AuditLog:  h.cfg.AuditLog.Enabled,
+		RateLimit: h.cfg.RateLimit.Enabled,
+		Thumbnail: h.cfg.Upload.EnableThumbnail,
+	})
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	stderrors "errors"
 	"net/http"
 	"strconv"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
 	"github.com/cccvno1/nova/pkg/response"
@@ -15,12 +17,14 @@ import (
 // PermissionHandler 权限管理处理器
 type PermissionHandler struct {
 	rbacService service.RBACService
+	config      *config.PermissionConfig
 }
 
 // NewPermissionHandler 创建权限处理器
-func NewPermissionHandler(rbacService service.RBACService) *PermissionHandler {
+func NewPermissionHandler(rbacService service.RBACService, cfg *config.PermissionConfig) *PermissionHandler {
 	return &PermissionHandler{
 		rbacService: rbacService,
+		config:      cfg,
 	}
 }
 
@@ -86,6 +90,9 @@ func (h *PermissionHandler) CreatePermission(c echo.Context) error {
 	}
 
 	if err := h.rbacService.CreatePermission(c.Request().Context(), permission); err != nil {
+		if stderrors.Is(err, service.ErrInvalidPermissionParent) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
@@ -133,6 +140,9 @@ func (h *PermissionHandler) UpdatePermission(c echo.Context) error {
 	}
 
 	if err := h.rbacService.UpdatePermission(c.Request().Context(), permission); err != nil {
+		if stderrors.Is(err, service.ErrInvalidPermissionParent) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
@@ -214,8 +224,12 @@ func (h *PermissionHandler) ListPermissions(c echo.Context) error {
 }
 
 // ListPermissionsByType 根据类型获取权限列表
+// 结果超过 permission.max_list_size 时截断，并通过 X-Result-Truncated / X-Total-Count 响应头提示调用方改用分页接口
 func (h *PermissionHandler) ListPermissionsByType(c echo.Context) error {
-	permType := model.PermissionType(c.QueryParam("type"))
+	permType := model.PermissionType(c.Param("type"))
+	if permType == "" {
+		permType = model.PermissionType(c.QueryParam("type"))
+	}
 	domain := c.QueryParam("domain")
 
 	if permType == "" {
@@ -227,21 +241,81 @@ func (h *PermissionHandler) ListPermissionsByType(c echo.Context) error {
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
+	if limit := h.config.MaxListSize; limit > 0 && len(permissions) > limit {
+		c.Response().Header().Set("X-Result-Truncated", "true")
+		c.Response().Header().Set("X-Total-Count", strconv.Itoa(len(permissions)))
+		permissions = permissions[:limit]
+	}
+
 	return response.Success(c, permissions)
 }
 
+// ListPermissionsGrouped 获取按分类分组的权限（供权限管理界面直接渲染）
+// 未设置分类的权限归入 uncategorized 分组
+func (h *PermissionHandler) ListPermissionsGrouped(c echo.Context) error {
+	domain := c.QueryParam("domain")
+
+	groups, err := h.rbacService.ListPermissionsGrouped(c.Request().Context(), domain)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.Success(c, groups)
+}
+
 // ListPermissionsTree 获取树形权限结构
+// 树形结构整体返回，不做截断；可通过 depth 参数限制返回的层级（不超过 permission.max_tree_depth）
 func (h *PermissionHandler) ListPermissionsTree(c echo.Context) error {
 	domain := c.QueryParam("domain")
 
+	depth := h.config.MaxTreeDepth
+	if v := c.QueryParam("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 1 {
+			return errors.New(errors.ErrInvalidParams, "depth must be a positive integer")
+		}
+		if depth <= 0 || d < depth {
+			depth = d
+		}
+	}
+
 	permissions, err := h.rbacService.ListPermissionsTree(c.Request().Context(), domain)
 	if err != nil {
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
+	if depth > 0 {
+		permissions = limitPermissionTreeDepth(permissions, depth)
+	}
+
 	return response.Success(c, permissions)
 }
 
+// ValidatePermissionHierarchy 校验权限层级
+// 报告自引用、孤儿节点、环以及超过 permission.max_tree_depth 的节点
+func (h *PermissionHandler) ValidatePermissionHierarchy(c echo.Context) error {
+	domain := c.QueryParam("domain")
+
+	report, err := h.rbacService.ValidatePermissionHierarchy(c.Request().Context(), domain, h.config.MaxTreeDepth)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.Success(c, report)
+}
+
+// limitPermissionTreeDepth 裁剪超过指定深度的子节点（根节点为第1层）
+func limitPermissionTreeDepth(nodes []model.Permission, depth int) []model.Permission {
+	for i := range nodes {
+		if depth <= 1 {
+			nodes[i].Children = nil
+			continue
+		}
+		nodes[i].Children = limitPermissionTreeDepth(nodes[i].Children, depth-1)
+	}
+	return nodes
+}
+
 // SearchPermissions 搜索权限
 func (h *PermissionHandler) SearchPermissions(c echo.Context) error {
 	keyword := c.QueryParam("keyword")
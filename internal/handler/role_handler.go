@@ -1,6 +1,7 @@
 package handler
 
 import (
+	stderrors "errors"
 	"net/http"
 	"strconv"
 
@@ -32,6 +33,7 @@ type CreateRoleRequest struct {
 	Description string `json:"description" validate:"max=500"`
 	Domain      string `json:"domain" validate:"required,min=1,max=100"`
 	Category    string `json:"category" validate:"omitempty,max=50"`
+	Level       int    `json:"level" validate:"omitempty,min=1"` // 角色等级，不传时为默认等级；须低于操作者等级且不超过 rbac.max_role_level
 	Sort        int    `json:"sort"`
 }
 
@@ -40,6 +42,7 @@ type UpdateRoleRequest struct {
 	DisplayName string `json:"display_name" validate:"required,min=2,max=100"`
 	Description string `json:"description" validate:"max=500"`
 	Category    string `json:"category" validate:"omitempty,max=50"`
+	Level       *int   `json:"level" validate:"omitempty,min=1"` // 角色等级，不传时保持不变；须低于操作者等级且不超过 rbac.max_role_level
 	Sort        int    `json:"sort"`
 	Status      *int8  `json:"status" validate:"omitempty,oneof=0 1"`
 }
@@ -55,23 +58,19 @@ type UpdatePermissionsRequest struct {
 	Preview       bool   `json:"preview"` // true=仅预览，false=执行更新
 }
 
-// PermissionDiff 权限差异
-type PermissionDiff struct {
-	Added   []model.Permission `json:"added"`   // 将要添加的权限
-	Removed []model.Permission `json:"removed"` // 将要删除的权限
-	Kept    []model.Permission `json:"kept"`    // 将要保留的权限
+// SimulatePermissionsRequest 权限变更模拟请求
+type SimulatePermissionsRequest struct {
+	PermissionIDs []uint `json:"permission_ids"` // 变更后的完整权限ID列表
 }
 
-// UpdatePermissionsResponse 更新权限响应
-type UpdatePermissionsResponse struct {
-	Preview *PermissionDiff `json:"preview,omitempty"` // 预览模式返回差异
-	Result  *ChangeResult   `json:"result,omitempty"`  // 执行模式返回结果
+// ReplacePoliciesRequest 替换角色策略请求
+type ReplacePoliciesRequest struct {
+	Policies [][2]string `json:"policies"` // 策略列表，每项为 [资源, 操作]，如 ["/api/v1/users", "GET"]
 }
 
-// ChangeResult 变更结果
-type ChangeResult struct {
-	AddedCount   int `json:"added_count"`   // 添加的权限数量
-	RemovedCount int `json:"removed_count"` // 删除的权限数量
+// RoleParentRequest 角色继承请求
+type RoleParentRequest struct {
+	ParentID uint `json:"parent_id" validate:"required"` // 父角色ID（当前角色将继承其权限）
 }
 
 // CreateRole 创建角色
@@ -85,7 +84,18 @@ func (h *RoleHandler) CreateRole(c echo.Context) error {
 		return err
 	}
 
-	// 获取当前用户ID
+	level := req.Level
+	if level == 0 {
+		level = model.DefaultRoleLevel
+	}
+
+	// 🔒 安全检查：只能创建比自己等级低的角色
+	operatorID := middleware.GetUserID(c)
+	if operatorID > 0 {
+		if err := h.rbacService.CheckRoleLevelAssignable(c.Request().Context(), operatorID, level, req.Domain); err != nil {
+			return errors.New(errors.ErrForbidden, err.Error())
+		}
+	}
 
 	role := &model.Role{
 		Name:        req.Name,
@@ -93,11 +103,15 @@ func (h *RoleHandler) CreateRole(c echo.Context) error {
 		Description: req.Description,
 		Domain:      req.Domain,
 		Category:    req.Category,
+		Level:       level,
 		Sort:        req.Sort,
 		Status:      1,
 	}
 
 	if err := h.rbacService.CreateRole(c.Request().Context(), role); err != nil {
+		if stderrors.Is(err, service.ErrRoleLevelOutOfRange) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
@@ -126,24 +140,35 @@ func (h *RoleHandler) UpdateRole(c echo.Context) error {
 		return errors.New(errors.ErrNotFound, "角色不存在")
 	}
 
-	// 🔒 安全检查：只能修改比自己等级低的角色
+	// 🔒 安全检查：只能修改比自己等级低的角色，调整后的等级同样须低于自己
 	operatorID := middleware.GetUserID(c)
 	if operatorID > 0 {
 		if err := h.rbacService.CheckRoleLevelPermission(c.Request().Context(), operatorID, uint(id), role.Domain); err != nil {
 			return errors.New(errors.ErrForbidden, err.Error())
 		}
+		if req.Level != nil && *req.Level != role.Level {
+			if err := h.rbacService.CheckRoleLevelAssignable(c.Request().Context(), operatorID, *req.Level, role.Domain); err != nil {
+				return errors.New(errors.ErrForbidden, err.Error())
+			}
+		}
 	}
 
 	// 更新字段
 	role.DisplayName = req.DisplayName
 	role.Description = req.Description
 	role.Category = req.Category
+	if req.Level != nil {
+		role.Level = *req.Level
+	}
 	role.Sort = req.Sort
 	if req.Status != nil {
 		role.Status = *req.Status
 	}
 
 	if err := h.rbacService.UpdateRole(c.Request().Context(), role); err != nil {
+		if stderrors.Is(err, service.ErrRoleLevelOutOfRange) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
@@ -246,6 +271,28 @@ func (h *RoleHandler) ListRoles(c echo.Context) error {
 	})
 }
 
+// roleExportBatchSize 角色导出每批读取的角色数
+const roleExportBatchSize = 200
+
+// ExportRoles 流式导出当前域内可管理的角色（含权限）
+// 与列表相同，只导出等级低于操作者的角色；响应为 JSON 数组，中途出错时数组不闭合，错误信息见 X-Stream-Error trailer
+func (h *RoleHandler) ExportRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+	domain := middleware.GetDomain(c)
+
+	operatorLevel, err := h.rbacService.GetUserMaxRoleLevel(ctx, middleware.GetUserID(c), domain)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	count, err := response.StreamJSONArray(c, h.rbacService.ExportRoles(ctx, domain, operatorLevel, roleExportBatchSize), response.DefaultStreamFlushEvery)
+	middleware.RecordExport(c, "role", count)
+	if err != nil && !c.Response().Committed {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+	return err
+}
+
 // SearchRoles 搜索角色
 func (h *RoleHandler) SearchRoles(c echo.Context) error {
 	keyword := c.QueryParam("keyword")
@@ -296,6 +343,7 @@ func (h *RoleHandler) SearchRoles(c echo.Context) error {
 }
 
 // GetRolePermissions 获取角色的权限列表
+// effective=true 时返回包含继承权限的完整权限集合（直接权限与继承权限分开列出）
 func (h *RoleHandler) GetRolePermissions(c echo.Context) error {
 	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -308,6 +356,14 @@ func (h *RoleHandler) GetRolePermissions(c echo.Context) error {
 		return errors.New(errors.ErrNotFound, "角色不存在")
 	}
 
+	if c.QueryParam("effective") == "true" {
+		effective, err := h.rbacService.GetRoleEffectivePermissions(c.Request().Context(), uint(roleID), role.Domain)
+		if err != nil {
+			return errors.New(errors.ErrDatabase, err.Error())
+		}
+		return response.Success(c, effective)
+	}
+
 	permissions, err := h.rbacService.GetRolePermissions(c.Request().Context(), uint(roleID), role.Domain)
 	if err != nil {
 		return errors.New(errors.ErrDatabase, err.Error())
@@ -346,8 +402,8 @@ func (h *RoleHandler) UpdatePermissions(c echo.Context) error {
 		}
 	}
 
-	// 调用Service层处理
-	result, err := h.rbacService.UpdateRolePermissions(
+	// 调用Service层处理：预览模式返回 diff，执行模式返回 result
+	diff, result, err := h.rbacService.UpdateRolePermissions(
 		c.Request().Context(),
 		uint(roleID),
 		req.PermissionIDs,
@@ -355,16 +411,89 @@ func (h *RoleHandler) UpdatePermissions(c echo.Context) error {
 		req.Preview,
 	)
 	if err != nil {
+		var invalid *service.InvalidPermissionIDsError
+		if stderrors.As(err, &invalid) {
+			return errors.NewWithDetails(errors.ErrInvalidParams, "权限ID无效", invalid)
+		}
+		if stderrors.Is(err, service.ErrTooManyPermissions) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
-	// 根据是否预览返回不同的响应
 	if req.Preview {
-		return response.SuccessWithMessage(c, "权限变更预览", result)
+		return response.SuccessWithMessage(c, "权限变更预览", diff)
 	}
 	return response.SuccessWithMessage(c, "权限更新成功", result)
 }
 
+// SimulatePermissions 模拟角色权限变更对已分配用户的影响（不落库）
+func (h *RoleHandler) SimulatePermissions(c echo.Context) error {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid role id")
+	}
+
+	var req SimulatePermissionsRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	role, err := h.rbacService.GetRole(c.Request().Context(), uint(roleID))
+	if err != nil {
+		return errors.New(errors.ErrNotFound, "角色不存在")
+	}
+
+	// 🔒 安全检查：与实际修改权限保持一致，只能模拟比自己等级低的角色
+	operatorID := middleware.GetUserID(c)
+	if operatorID > 0 {
+		if err := h.rbacService.CheckRoleLevelPermission(c.Request().Context(), operatorID, uint(roleID), role.Domain); err != nil {
+			return errors.New(errors.ErrForbidden, err.Error())
+		}
+	}
+
+	result, err := h.rbacService.SimulateRolePermissionChange(c.Request().Context(), uint(roleID), req.PermissionIDs, role.Domain)
+	if err != nil {
+		var invalid *service.InvalidPermissionIDsError
+		if stderrors.As(err, &invalid) {
+			return errors.NewWithDetails(errors.ErrInvalidParams, "权限ID无效", invalid)
+		}
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.SuccessWithMessage(c, "权限变更模拟结果", result)
+}
+
+// ReplacePolicies 替换角色的原始 Casbin 策略（仅超级管理员）
+// 方案A下实际鉴权基于权限表，此接口仅用于直接管理 Casbin 策略的高级场景
+func (h *RoleHandler) ReplacePolicies(c echo.Context) error {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid role id")
+	}
+
+	var req ReplacePoliciesRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	role, err := h.rbacService.GetRole(c.Request().Context(), uint(roleID))
+	if err != nil {
+		return errors.New(errors.ErrNotFound, "角色不存在")
+	}
+
+	// 🔒 安全检查：仅超级管理员可直接管理策略
+	if err := requireRoleLevel(c, h.rbacService, role.Domain, superAdminRoleLevel, "仅超级管理员可管理角色策略"); err != nil {
+		return err
+	}
+
+	if err := h.rbacService.ReplaceRolePolicies(c.Request().Context(), uint(roleID), role.Domain, req.Policies); err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.SuccessWithMessage(c, "角色策略更新成功", nil)
+}
+
 // AssignPermissions 给角色分配权限（已废弃，保留向后兼容）
 // @Deprecated 请使用 UpdatePermissions 替代
 func (h *RoleHandler) AssignPermissions(c echo.Context) error {
@@ -389,7 +518,7 @@ func (h *RoleHandler) AssignPermissions(c echo.Context) error {
 	}
 
 	// 使用新的UpdatePermissions方法，不预览直接执行
-	_, err = h.rbacService.UpdateRolePermissions(
+	_, _, err = h.rbacService.UpdateRolePermissions(
 		c.Request().Context(),
 		uint(roleID),
 		req.PermissionIDs,
@@ -397,6 +526,9 @@ func (h *RoleHandler) AssignPermissions(c echo.Context) error {
 		false,
 	)
 	if err != nil {
+		if stderrors.Is(err, service.ErrTooManyPermissions) {
+			return errors.New(errors.ErrInvalidParams, err.Error())
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
@@ -421,13 +553,57 @@ func (h *RoleHandler) RevokePermission(c echo.Context) error {
 		return errors.New(errors.ErrNotFound, "角色不存在")
 	}
 
-	if err := h.rbacService.RevokePermissionsFromRole(c.Request().Context(), uint(roleID), []uint{uint(permissionID)}, role.Domain); err != nil {
+	if _, err := h.rbacService.RevokePermissionsFromRole(c.Request().Context(), uint(roleID), []uint{uint(permissionID)}, role.Domain); err != nil {
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
 	return response.SuccessWithMessage(c, "权限撤销成功", nil)
 }
 
+// RevokePermissionsResponse 批量撤销权限结果
+type RevokePermissionsResponse struct {
+	Revoked int `json:"revoked"` // 实际撤销的数量，未分配给角色的权限ID不计入
+}
+
+// RevokePermissions 批量撤销角色的权限
+// 只能修改比自己等级低的角色；缓存在全部撤销后统一清理一次
+func (h *RoleHandler) RevokePermissions(c echo.Context) error {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid role id")
+	}
+
+	var req AssignPermissionsRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	// 获取角色信息
+	role, err := h.rbacService.GetRole(c.Request().Context(), uint(roleID))
+	if err != nil {
+		return errors.New(errors.ErrNotFound, "角色不存在")
+	}
+
+	// 🔒 安全检查：只能修改比自己等级低的角色的权限
+	operatorID := middleware.GetUserID(c)
+	if operatorID > 0 {
+		if err := h.rbacService.CheckRoleLevelPermission(c.Request().Context(), operatorID, uint(roleID), role.Domain); err != nil {
+			return errors.New(errors.ErrForbidden, err.Error())
+		}
+	}
+
+	revoked, err := h.rbacService.RevokePermissionsFromRole(c.Request().Context(), uint(roleID), req.PermissionIDs, role.Domain)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.SuccessWithMessage(c, "权限撤销成功", RevokePermissionsResponse{Revoked: revoked})
+}
+
 // GetRoleUsers 获取拥有某个角色的用户列表
 func (h *RoleHandler) GetRoleUsers(c echo.Context) error {
 	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -442,3 +618,82 @@ func (h *RoleHandler) GetRoleUsers(c echo.Context) error {
 
 	return response.Success(c, userRoles)
 }
+
+// AddParent 添加角色继承关系（仅超级管理员）
+// 会形成继承环时返回 409
+func (h *RoleHandler) AddParent(c echo.Context) error {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid role id")
+	}
+
+	var req RoleParentRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	role, err := h.rbacService.GetRole(c.Request().Context(), uint(roleID))
+	if err != nil {
+		return errors.New(errors.ErrNotFound, "角色不存在")
+	}
+
+	// 🔒 安全检查：仅超级管理员可管理角色继承
+	if err := requireRoleLevel(c, h.rbacService, role.Domain, superAdminRoleLevel, "仅超级管理员可管理角色继承"); err != nil {
+		return err
+	}
+
+	if err := h.rbacService.AddRoleParent(c.Request().Context(), uint(roleID), req.ParentID, role.Domain); err != nil {
+		if stderrors.Is(err, service.ErrRoleInheritanceCycle) {
+			return errors.New(errors.ErrConflict, err.Error())
+		}
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.SuccessWithMessage(c, "角色继承添加成功", nil)
+}
+
+// RemoveParent 删除角色继承关系（仅超级管理员）
+func (h *RoleHandler) RemoveParent(c echo.Context) error {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid role id")
+	}
+	parentID, err := strconv.ParseUint(c.Param("parent_id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid parent role id")
+	}
+
+	role, err := h.rbacService.GetRole(c.Request().Context(), uint(roleID))
+	if err != nil {
+		return errors.New(errors.ErrNotFound, "角色不存在")
+	}
+
+	// 🔒 安全检查：仅超级管理员可管理角色继承
+	if err := requireRoleLevel(c, h.rbacService, role.Domain, superAdminRoleLevel, "仅超级管理员可管理角色继承"); err != nil {
+		return err
+	}
+
+	if err := h.rbacService.RemoveRoleParent(c.Request().Context(), uint(roleID), uint(parentID), role.Domain); err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.SuccessWithMessage(c, "角色继承删除成功", nil)
+}
+
+// DetectCycles 诊断域内已存在的角色继承环
+func (h *RoleHandler) DetectCycles(c echo.Context) error {
+	domain := middleware.GetDomain(c)
+
+	cycles, err := h.rbacService.DetectCycles(c.Request().Context(), domain)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.Success(c, map[string]interface{}{
+		"domain": domain,
+		"cycles": cycles,
+	})
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/casbin"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// roleTestEnv 角色接口测试环境
+type roleTestEnv struct {
+	e        *echo.Echo
+	db       *database.Database
+	rbac     service.RBACService
+	enforcer *casbin.Enforcer
+}
+
+// newRoleTestEnv 创建挂载角色接口的 Echo 实例，请求头 X-Test-User 模拟已认证的操作者
+func newRoleTestEnv(t *testing.T) *roleTestEnv {
+	t.Helper()
+
+	testutil.NewRedis(t)
+	db := &database.Database{DB: testutil.NewDB(t, &model.Role{}, &model.Permission{}, &model.UserRole{}, &casbin.PolicyVersion{})}
+	if err := db.DB.Create(&casbin.PolicyVersion{ID: 1, UpdatedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("create policy version: %v", err)
+	}
+
+	log := slog.New(slog.DiscardHandler)
+	enforcer, err := casbin.NewEnforcer(db.DB, casbin.Config{AutoSave: true}, log)
+	if err != nil {
+		t.Fatalf("NewEnforcer: %v", err)
+	}
+	rbac := service.NewRBACService(enforcer, repository.NewRoleRepository(db), repository.NewPermissionRepository(db),
+		repository.NewUserRoleRepository(db), nil, db, log, &config.RBACConfig{MaxRoleLevel: model.SuperAdminRoleLevel})
+
+	h := NewRoleHandler(rbac)
+	e := newTestEcho()
+	g := e.Group("/roles", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id, err := strconv.ParseUint(c.Request().Header.Get("X-Test-User"), 10, 64); err == nil {
+				c.Set(middleware.UserIDKey, uint(id))
+			}
+			return next(c)
+		}
+	})
+	g.POST("", h.CreateRole)
+	g.PUT("/:id", h.UpdateRole)
+	g.GET("", h.ListRoles)
+	g.GET("/export", h.ExportRoles)
+
+	return &roleTestEnv{e: e, db: db, rbac: rbac, enforcer: enforcer}
+}
+
+// grantRole 创建指定等级的角色并分配给用户
+func (env *roleTestEnv) grantRole(t *testing.T, userID uint, name string, level int) *model.Role {
+	t.Helper()
+
+	role := &model.Role{Name: name, DisplayName: name, Domain: "default", Level: level}
+	if err := env.db.DB.Create(role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if _, err := env.rbac.AssignRolesToUser(t.Context(), userID, []uint{role.ID}, "default", 0, nil); err != nil {
+		t.Fatalf("assign role: %v", err)
+	}
+	if _, err := env.enforcer.AddRoleForUser(strconv.FormatUint(uint64(userID), 10), strconv.FormatUint(uint64(role.ID), 10), "default"); err != nil {
+		t.Fatalf("add grouping policy: %v", err)
+	}
+	return role
+}
+
+// do 以指定操作者发送 JSON 请求，返回响应与解析后的 data
+func (env *roleTestEnv) do(t *testing.T, method, path string, operator uint, body string) (*httptest.ResponseRecorder, json.RawMessage) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Test-User", strconv.FormatUint(uint64(operator), 10))
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp.Data
+}
+
+func TestCreateRole_DefaultLevelIsManageable(t *testing.T) {
+	env := newRoleTestEnv(t)
+	env.grantRole(t, 1, "manager", 50)
+
+	rec, data := env.do(t, http.MethodPost, "/roles", 1, `{"name":"editor","display_name":"编辑","domain":"default"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created model.Role
+	if err := json.Unmarshal(data, &created); err != nil {
+		t.Fatalf("decode role: %v", err)
+	}
+	if created.Level != model.DefaultRoleLevel {
+		t.Fatalf("level = %d, want default %d", created.Level, model.DefaultRoleLevel)
+	}
+
+	// 未传等级的角色不再是等级 0：创建者能在列表中看到并继续管理它
+	rec, data = env.do(t, http.MethodGet, "/roles?domain=default", 1, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Items []model.Role `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Fatalf("list = %+v, want the created role", list.Items)
+	}
+
+	path := "/roles/" + strconv.FormatUint(uint64(created.ID), 10)
+	if rec, _ := env.do(t, http.MethodPut, path, 1, `{"display_name":"内容编辑"}`); rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCreateRole_LevelBelowOperator(t *testing.T) {
+	env := newRoleTestEnv(t)
+	env.grantRole(t, 1, "manager", 50)
+
+	tests := []struct {
+		level int
+		want  int
+	}{
+		{49, http.StatusOK},
+		{50, http.StatusForbidden},
+		{80, http.StatusForbidden},
+		{-1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		body := `{"name":"role` + strconv.Itoa(tt.level+10) + `","display_name":"角色","domain":"default","level":` + strconv.Itoa(tt.level) + `}`
+		if rec, _ := env.do(t, http.MethodPost, "/roles", 1, body); rec.Code != tt.want {
+			t.Errorf("level %d: status = %d, want %d: %s", tt.level, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestUpdateRole_Level(t *testing.T) {
+	env := newRoleTestEnv(t)
+	env.grantRole(t, 1, "manager", 50)
+
+	role := &model.Role{Name: "editor", DisplayName: "编辑", Domain: "default", Level: 20}
+	if err := env.db.DB.Create(role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	path := "/roles/" + strconv.FormatUint(uint64(role.ID), 10)
+
+	if rec, _ := env.do(t, http.MethodPut, path, 1, `{"display_name":"编辑","level":50}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("raise to operator level: status = %d, want 403", rec.Code)
+	}
+
+	rec, data := env.do(t, http.MethodPut, path, 1, `{"display_name":"编辑","level":30}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated model.Role
+	if err := json.Unmarshal(data, &updated); err != nil || updated.Level != 30 {
+		t.Fatalf("updated = %+v (%v), want level 30", updated, err)
+	}
+
+	// 不传等级时保持不变
+	rec, data = env.do(t, http.MethodPut, path, 1, `{"display_name":"编辑组"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(data, &updated); err != nil || updated.Level != 30 {
+		t.Fatalf("updated = %+v (%v), want level kept at 30", updated, err)
+	}
+}
+
+func TestExportRoles_StreamsManageableRolesAcrossBatches(t *testing.T) {
+	env := newRoleTestEnv(t)
+	env.grantRole(t, 1, "manager", 50)
+
+	// 超过一批的可管理角色，外加一个等级高于操作者的角色
+	roles := make([]model.Role, 0, roleExportBatchSize+50)
+	for i := range roleExportBatchSize + 50 {
+		name := "role" + strconv.Itoa(i)
+		roles = append(roles, model.Role{Name: name, DisplayName: name, Domain: "default", Level: 20})
+	}
+	roles = append(roles, model.Role{Name: "director", DisplayName: "director", Domain: "default", Level: 60})
+	if err := env.db.DB.CreateInBatches(roles, 100).Error; err != nil {
+		t.Fatalf("create roles: %v", err)
+	}
+	perm := &model.Permission{Name: "user:read", DisplayName: "查看用户", Resource: "user", Action: "read", Domain: "default"}
+	if err := env.db.DB.Create(perm).Error; err != nil {
+		t.Fatalf("create permission: %v", err)
+	}
+	if err := env.db.DB.Model(&roles[0]).Association("Permissions").Append(perm); err != nil {
+		t.Fatalf("attach permission: %v", err)
+	}
+
+	rec, _ := env.do(t, http.MethodGet, "/roles/export?domain=default", 1, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var exported []model.Role
+	if err := json.Unmarshal(rec.Body.Bytes(), &exported); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if len(exported) != roleExportBatchSize+50 {
+		t.Fatalf("exported %d roles, want %d", len(exported), roleExportBatchSize+50)
+	}
+	for i, role := range exported {
+		if role.Level >= 50 {
+			t.Fatalf("exported role %q at level %d, operator level is 50", role.Name, role.Level)
+		}
+		if i > 0 && role.ID <= exported[i-1].ID {
+			t.Fatalf("roles not in id order at %d", i)
+		}
+	}
+	if len(exported[0].Permissions) != 1 || exported[0].Permissions[0].ID != perm.ID {
+		t.Fatalf("first role permissions = %+v, want the attached permission", exported[0].Permissions)
+	}
+}
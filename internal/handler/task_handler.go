@@ -1,27 +1,57 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/internal/service"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/queue"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
 )
 
 // TaskHandler 任务处理器
 type TaskHandler struct {
-	taskRepo repository.TaskRepository
+	taskRepo    repository.TaskRepository
+	queue       *queue.Client // 重新排队任务用，队列未启用时为 nil
+	metrics     *queue.Metrics
+	depth       *queue.DepthMonitor
+	rbacService service.RBACService
 }
 
 // NewTaskHandler 创建任务处理器
-func NewTaskHandler(taskRepo repository.TaskRepository) *TaskHandler {
+func NewTaskHandler(taskRepo repository.TaskRepository, queueClient *queue.Client, metrics *queue.Metrics, depth *queue.DepthMonitor, rbacService service.RBACService) *TaskHandler {
 	return &TaskHandler{
-		taskRepo: taskRepo,
+		taskRepo:    taskRepo,
+		queue:       queueClient,
+		metrics:     metrics,
+		depth:       depth,
+		rbacService: rbacService,
 	}
 }
 
+// BulkStatusRequest 批量更新任务状态请求
+type BulkStatusRequest struct {
+	FromStatus       string `json:"from_status" validate:"required,oneof=pending processing success failed cancelled"` // 原状态
+	ToStatus         string `json:"to_status" validate:"required,oneof=pending processing success failed cancelled"`   // 目标状态
+	Type             string `json:"type" validate:"omitempty,oneof=async scheduled"`                                   // 任务类型（可选）
+	OlderThanMinutes int    `json:"older_than_minutes" validate:"omitempty,min=1"`                                     // 仅更新超过 N 分钟未变化的任务（可选）
+}
+
+// BulkStatusResponse 批量更新任务状态结果
+type BulkStatusResponse struct {
+	Affected      int      `json:"affected"`        // 状态被更新的任务数
+	Requeued      int      `json:"requeued"`        // 重新推入队列的任务数（仅目标状态为 pending 时）
+	FailedTaskIDs []string `json:"failed_task_ids"` // 重新推入队列失败、已标记为 failed 的任务
+}
+
 // GetByID 根据 ID 获取任务
 // @Summary 获取任务详情
 // @Description 根据数据库ID获取任务的详细信息
@@ -158,3 +188,125 @@ func (h *TaskHandler) GetStats(c echo.Context) error {
 
 	return response.Success(c, stats)
 }
+
+// GetMetrics 获取按任务名统计的队列指标
+// @Summary 获取队列任务指标
+// @Description 按任务名返回成功、失败、重试、放弃次数及最近执行的平均耗时
+// @Tags 任务管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=map[string]queue.TaskMetrics} "任务指标"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /tasks/metrics [get]
+func (h *TaskHandler) GetMetrics(c echo.Context) error {
+	metrics, err := h.metrics.All(c.Request().Context())
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	return response.Success(c, metrics)
+}
+
+// GetQueueDepth 获取队列积压状态
+// @Summary 获取队列积压状态
+// @Description 返回当前队列长度、最高水位及持续超过告警阈值（queue.alert_threshold）的时间，持续超过 queue.alert_duration 时 status 为 degraded
+// @Tags 任务管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=queue.DepthStatus} "队列积压状态"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /tasks/metrics/depth [get]
+func (h *TaskHandler) GetQueueDepth(c echo.Context) error {
+	status, err := h.depth.Observe(c.Request().Context())
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	return response.Success(c, status)
+}
+
+// BulkUpdateStatus 批量更新任务状态（管理员）
+// @Summary 批量更新任务状态
+// @Description 将指定状态的任务批量改为目标状态，如将失败任务重置为 pending，或将卡住超过 N 分钟的 processing 任务标记为失败。
+// @Description 目标状态为 pending 时，被更新的任务会以原任务 ID、名称和载荷重新推入队列（重试计数清零）；推入失败的任务标记为 failed 并在 failed_task_ids 中返回。操作人由审计日志中间件记录。
+// @Tags 任务管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkStatusRequest true "批量更新条件"
+// @Success 200 {object} response.Response{data=BulkStatusResponse} "更新结果"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 401 {object} response.Response "未授权"
+// @Failure 403 {object} response.Response "需要管理员权限"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /tasks/bulk-status [post]
+func (h *TaskHandler) BulkUpdateStatus(c echo.Context) error {
+	// 任务队列不区分域，只认可平台域的管理员
+	if err := requireRoleLevel(c, h.rbacService, platformDomain, adminRoleLevel, "admin permission required"); err != nil {
+		return err
+	}
+
+	var req BulkStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	if req.FromStatus == req.ToStatus {
+		return errors.New(errors.ErrInvalidParams, "from_status and to_status must be different")
+	}
+
+	var before time.Time
+	if req.OlderThanMinutes > 0 {
+		before = time.Now().Add(-time.Duration(req.OlderThanMinutes) * time.Minute)
+	}
+
+	ctx := c.Request().Context()
+	tasks, err := h.taskRepo.UpdateStatusBulkBefore(ctx, req.FromStatus, req.ToStatus, req.Type, before)
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	resp := BulkStatusResponse{Affected: len(tasks), FailedTaskIDs: []string{}}
+	if req.ToStatus == model.TaskStatusPending {
+		for i := range tasks {
+			if err := h.requeue(ctx, &tasks[i]); err != nil {
+				_ = h.taskRepo.UpdateStatus(ctx, tasks[i].TaskID, model.TaskStatusFailed, "requeue failed: "+err.Error())
+				resp.FailedTaskIDs = append(resp.FailedTaskIDs, tasks[i].TaskID)
+				continue
+			}
+			resp.Requeued++
+		}
+	}
+
+	return response.Success(c, resp)
+}
+
+// requeue 将数据库中的任务重新推入队列
+func (h *TaskHandler) requeue(ctx context.Context, task *model.Task) error {
+	if h.queue == nil {
+		return fmt.Errorf("queue is not enabled")
+	}
+
+	var payload map[string]interface{}
+	if task.Payload != "" {
+		if err := json.Unmarshal([]byte(task.Payload), &payload); err != nil {
+			return fmt.Errorf("invalid task payload: %w", err)
+		}
+	}
+
+	return h.queue.Enqueue(ctx, &queue.Task{
+		ID:        task.TaskID,
+		Name:      task.Name,
+		Payload:   payload,
+		MaxRetry:  task.MaxRetry,
+		CreatedAt: task.CreatedAt,
+	})
+}
@@ -1,21 +1,43 @@
 package handler
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/cccvno1/nova/internal/service"
 	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/queue"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
 )
 
+// maxImportRows 单次导入的最大行数
+const maxImportRows = 1000
+
+// welcomeEmailTask 欢迎邮件队列任务名（需在 Worker 中注册对应处理器）
+const welcomeEmailTask = "send_welcome_email"
+
 type UserHandler struct {
-	userService *service.UserService
+	userService    *service.UserService
+	rbacService    service.RBACService
+	queueClient    *queue.Client              // 为 nil 时不发送欢迎邮件，也不支持修改邮箱
+	importer       *service.UserImportService // 为 nil 时不支持异步导入
+	emailChangeURL string                     // 邮箱修改确认链接地址
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(userService *service.UserService, rbacService service.RBACService, queueClient *queue.Client, importer *service.UserImportService, emailChangeURL string) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:    userService,
+		rbacService:    rbacService,
+		queueClient:    queueClient,
+		importer:       importer,
+		emailChangeURL: emailChangeURL,
 	}
 }
 
@@ -69,6 +91,89 @@ func (h *UserHandler) List(c echo.Context) error {
 	return response.Page(c, users, pagination.Total, pagination.Page, pagination.PageSize)
 }
 
+// Search 按用户名、邮箱、昵称搜索用户
+// GET /api/v1/users/search?keyword=&page=&page_size=
+// 调用者在当前域没有 user:read_pii 权限时不按邮箱匹配（避免通过搜索结果推断邮箱），且响应中的邮箱与电话部分脱敏
+func (h *UserHandler) Search(c echo.Context) error {
+	pagination := &database.Pagination{}
+	if err := c.Bind(pagination); err != nil {
+		return errors.New(errors.ErrBindQuery, "")
+	}
+	if err := c.Validate(pagination); err != nil {
+		return err
+	}
+
+	keyword := strings.TrimSpace(c.QueryParam("keyword"))
+	if keyword == "" {
+		return errors.New(errors.ErrInvalidParams, "keyword is required")
+	}
+	if len([]rune(keyword)) > 100 {
+		return errors.New(errors.ErrInvalidParams, "keyword is too long")
+	}
+
+	ctx := c.Request().Context()
+	canReadPII, err := h.rbacService.CheckPermission(ctx, middleware.GetUserID(c), middleware.GetDomain(c), "user", "read_pii")
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	users, err := h.userService.Search(ctx, keyword, canReadPII, pagination)
+	if err != nil {
+		return err
+	}
+	if !canReadPII {
+		for i := range users {
+			users[i].Email = maskEmail(users[i].Email)
+			users[i].Phone = maskPhone(users[i].Phone)
+		}
+	}
+
+	return response.Page(c, users, pagination.Total, pagination.Page, pagination.PageSize)
+}
+
+// maskEmail 邮箱脱敏：保留本地部分首字符与域名，如 a***@example.com
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return string([]rune(local)[0]) + "***@" + domain
+}
+
+// maskPhone 电话脱敏：只保留末 4 位
+func maskPhone(phone string) string {
+	if phone == "" {
+		return ""
+	}
+	runes := []rune(phone)
+	if len(runes) <= 4 {
+		return "***"
+	}
+	return "***" + string(runes[len(runes)-4:])
+}
+
+// ListWithRoles 分页查询用户及其角色
+// 查询参数：domain（默认 default）、role（按角色标识过滤）
+func (h *UserHandler) ListWithRoles(c echo.Context) error {
+	pagination := &database.Pagination{}
+	if err := c.Bind(pagination); err != nil {
+		return errors.New(errors.ErrBindQuery, "")
+	}
+
+	if err := c.Validate(pagination); err != nil {
+		return err
+	}
+
+	domain := middleware.GetDomain(c)
+
+	users, err := h.userService.ListWithRoles(c.Request().Context(), domain, c.QueryParam("role"), pagination)
+	if err != nil {
+		return err
+	}
+
+	return response.Page(c, users, pagination.Total, pagination.Page, pagination.PageSize)
+}
+
 func (h *UserHandler) Update(c echo.Context) error {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -84,11 +189,44 @@ func (h *UserHandler) Update(c echo.Context) error {
 		return err
 	}
 
-	if err := h.userService.Update(c.Request().Context(), uint(id), req); err != nil {
+	if req.Email != "" && h.queueClient == nil {
+		return errors.New(errors.ErrServiceDisabled, "email change requires queue")
+	}
+
+	ctx := c.Request().Context()
+	result, err := h.userService.Update(ctx, uint(id), req)
+	if err != nil {
 		return err
 	}
+	if len(result.Changes) > 0 {
+		middleware.RecordChanges(c, result.Changes)
+	}
 
-	return response.Success(c, nil)
+	// 确认邮件发送到新邮箱，确认后邮箱才生效
+	if change := result.EmailChange; change != nil {
+		if _, err := h.queueClient.Submit(ctx, service.EmailChangeTaskName, map[string]interface{}{
+			"user_id":    id,
+			"email":      change.Email,
+			"link":       buildMagicLink(h.emailChangeURL, change.Token),
+			"expires_in": int(change.ExpiresIn.Seconds()),
+		}, 3); err != nil {
+			return err
+		}
+	}
+
+	return response.Success(c, result)
+}
+
+// ConfirmEmailChange 确认邮箱修改
+// 令牌来自发送到新邮箱的确认链接，单次有效
+func (h *UserHandler) ConfirmEmailChange(c echo.Context) error {
+	user, changes, err := h.userService.ConfirmEmailChange(c.Request().Context(), c.QueryParam("token"))
+	if err != nil {
+		return err
+	}
+	middleware.RecordChanges(c, changes)
+
+	return response.Success(c, user)
 }
 
 func (h *UserHandler) Delete(c echo.Context) error {
@@ -103,3 +241,240 @@ func (h *UserHandler) Delete(c echo.Context) error {
 
 	return response.Success(c, nil)
 }
+
+// Restore 恢复已删除的用户
+// 删除时撤销的角色不会恢复，需要重新分配
+func (h *UserHandler) Restore(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.userService.RestoreUser(c.Request().Context(), uint(id))
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, user)
+}
+
+// SetDefaultDomainRequest 设置默认域请求
+type SetDefaultDomainRequest struct {
+	Domain string `json:"domain" validate:"max=100"` // 为空表示清除
+}
+
+// SetDefaultDomain 设置用户的默认域
+// 请求未携带 X-Domain 头或 domain 参数时使用该域；只能设置为用户拥有角色的域
+func (h *UserHandler) SetDefaultDomain(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid user id")
+	}
+
+	var req SetDefaultDomainRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := h.userService.SetDefaultDomain(c.Request().Context(), uint(id), req.Domain)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, user)
+}
+
+// Import 批量导入用户
+// 支持 JSON 数组（application/json）或 CSV（text/csv 请求体或 multipart 的 file 字段），
+// CSV 表头：username,email,password,nickname,roles，多个角色用 ";" 分隔。
+// 每行在独立事务中创建用户并分配角色，返回逐行结果；send_welcome=true 时为成功的用户投递欢迎邮件任务。
+func (h *UserHandler) Import(c echo.Context) error {
+	rows, err := parseImportRows(c)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return errors.New(errors.ErrInvalidParams, "no users to import")
+	}
+	if len(rows) > maxImportRows {
+		return errors.New(errors.ErrInvalidParams, "too many rows, max "+strconv.Itoa(maxImportRows))
+	}
+
+	ctx := c.Request().Context()
+	domain := middleware.GetDomain(c)
+
+	operatorID := middleware.GetUserID(c)
+	operatorLevel, err := h.rbacService.GetUserMaxRoleLevel(ctx, operatorID, domain)
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	sendWelcome := c.QueryParam("send_welcome") == "true" && h.queueClient != nil
+
+	results := make([]service.ImportUserResult, 0, len(rows))
+	succeeded := 0
+	for i := range rows {
+		row := &rows[i]
+		if err := c.Validate(row); err != nil {
+			results = append(results, service.ImportUserResult{
+				Row:      i + 1,
+				Username: row.Username,
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		result, err := h.userService.ImportUser(ctx, row, domain, operatorID, operatorLevel)
+		if err != nil {
+			return errors.Wrap(errors.ErrInternalServer, err)
+		}
+		result.Row = i + 1
+
+		if result.Success {
+			succeeded++
+			if sendWelcome {
+				_, _ = h.queueClient.Submit(ctx, welcomeEmailTask, map[string]interface{}{
+					"user_id":  result.UserID,
+					"username": row.Username,
+					"email":    row.Email,
+				}, 3)
+			}
+		}
+		results = append(results, *result)
+	}
+
+	return response.Success(c, map[string]interface{}{
+		"total":     len(rows),
+		"succeeded": succeeded,
+		"failed":    len(rows) - succeeded,
+		"results":   results,
+	})
+}
+
+// ImportAsync 异步导入大批量用户
+// 接收 CSV（text/csv 请求体或 multipart 的 file 字段，表头同 Import），校验表头后流式保存并投递队列任务，
+// 立即返回 202 与 task_id，客户端通过 GET /tasks/task/:taskId 轮询进度（progress）与结果摘要（result）。
+// max_errors 为失败行数上限（超过后中止，缺省使用 queue.import_max_errors）
+func (h *UserHandler) ImportAsync(c echo.Context) error {
+	if h.importer == nil {
+		return errors.New(errors.ErrServiceDisabled, "async import requires queue")
+	}
+
+	maxErrors := -1
+	if v := c.QueryParam("max_errors"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return errors.New(errors.ErrInvalidParams, "max_errors must be a non-negative integer")
+		}
+		maxErrors = n
+	}
+
+	ctx := c.Request().Context()
+	domain := middleware.GetDomain(c)
+	operatorID := middleware.GetUserID(c)
+	operatorLevel, err := h.rbacService.GetUserMaxRoleLevel(ctx, operatorID, domain)
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	var body io.Reader
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMEMultipartForm):
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return errors.New(errors.ErrInvalidParams, "file is required")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalidParams, err)
+		}
+		defer file.Close()
+		body = file
+	case strings.HasPrefix(contentType, "text/csv"):
+		body = c.Request().Body
+	default:
+		return errors.New(errors.ErrInvalidParams, "content type must be text/csv or multipart/form-data")
+	}
+
+	opts := service.UserImportOptions{
+		Domain:        domain,
+		OperatorID:    operatorID,
+		OperatorLevel: operatorLevel,
+		MaxErrors:     maxErrors,
+	}
+	if c.QueryParam("send_welcome") == "true" {
+		opts.WelcomeTask = welcomeEmailTask
+	}
+
+	taskID, err := h.importer.Start(ctx, body, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, response.Response{
+		Code:    errors.Success,
+		Message: "导入任务已提交",
+		Data:    map[string]string{"task_id": taskID},
+	})
+}
+
+// parseImportRows 解析导入数据（JSON 或 CSV）
+func parseImportRows(c echo.Context) ([]service.ImportUserRow, error) {
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New(errors.ErrInvalidParams, "file is required")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalidParams, err)
+		}
+		defer file.Close()
+		return parseImportCSV(file)
+	}
+
+	if strings.HasPrefix(contentType, "text/csv") {
+		return parseImportCSV(c.Request().Body)
+	}
+
+	var rows []service.ImportUserRow
+	if err := json.NewDecoder(c.Request().Body).Decode(&rows); err != nil {
+		return nil, errors.New(errors.ErrBindJSON, "")
+	}
+	return rows, nil
+}
+
+// parseImportCSV 解析 CSV 导入数据
+func parseImportCSV(r io.Reader) ([]service.ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidParams, "invalid csv header")
+	}
+	columns, err := service.ParseImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []service.ImportUserRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WrapWithMessage(errors.ErrInvalidParams, "invalid csv", err)
+		}
+		rows = append(rows, columns.Row(record))
+	}
+
+	return rows, nil
+}
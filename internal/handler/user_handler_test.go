@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/mailer"
+	"github.com/cccvno1/nova/pkg/queue"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// capturingMailer 将发送的邮件写入通道，供测试等待并读取
+type capturingMailer struct {
+	sent chan *mailer.Message
+}
+
+func (m *capturingMailer) Send(_ context.Context, msg *mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func TestEmailChange_EndToEnd(t *testing.T) {
+	testutil.NewRedis(t)
+	db := testutil.NewDB(t, &model.User{})
+
+	alice := model.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if err := db.Create(&alice).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	userService := service.NewUserService(db, nil, nil, nil, nil, false, nil, &config.UserConfig{
+		ProfileMaxSize: 4096,
+		EmailChangeURL: "https://app.example.com/account/email/confirm",
+		EmailChangeTTL: 3600,
+	})
+
+	// Worker 与路由中的注册方式一致，邮件由记录型发送器接收
+	queueCfg := &config.QueueConfig{
+		Workers:           1,
+		MaxRetry:          3,
+		RetryDelay:        1,
+		RedisPrefix:       "test_queue",
+		BackoffStrategy:   "fixed",
+		RetryMaxDelay:     60,
+		DelayedBatchSize:  100,
+		DelayedMaxPerTick: 1000,
+	}
+	worker := queue.NewWorker(queueCfg)
+	sent := &capturingMailer{sent: make(chan *mailer.Message, 1)}
+	emailService := service.NewEmailService(sent)
+	queue.RegisterTyped(worker, service.EmailChangeTaskName, emailService.SendEmailChangeConfirmation)
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start worker: %v", err)
+	}
+	defer worker.Stop()
+
+	h := NewUserHandler(userService, nil, queue.NewClient(queueCfg.RedisPrefix), nil, "https://app.example.com/account/email/confirm")
+	e := newTestEcho()
+	e.PUT("/users/:id", h.Update)
+	e.GET("/users/email/confirm", h.ConfirmEmailChange)
+
+	// 1. 申请修改邮箱：确认前邮箱不变
+	req := httptest.NewRequest(http.MethodPut, "/users/"+strconv.Itoa(int(alice.ID)), strings.NewReader(`{"email":"alice@new.example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if email := currentEmail(t, db, alice.ID); email != "alice@example.com" {
+		t.Fatalf("email changed before confirmation: %q", email)
+	}
+
+	// 2. Worker 将确认邮件发送到新邮箱
+	var msg *mailer.Message
+	select {
+	case msg = <-sent.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirmation email was not sent")
+	}
+	if msg.To != "alice@new.example.com" {
+		t.Fatalf("confirmation sent to %q, want the new address", msg.To)
+	}
+	token := extractToken(t, msg.Body)
+
+	// 3. 通过邮件中的令牌确认，邮箱生效
+	confirm := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/email/confirm?token="+url.QueryEscape(token), nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := confirm(); rec.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if email := currentEmail(t, db, alice.ID); email != "alice@new.example.com" {
+		t.Fatalf("email after confirmation = %q", email)
+	}
+
+	// 4. 令牌单次有效
+	if rec := confirm(); rec.Code == http.StatusOK {
+		t.Fatalf("second confirm succeeded, body = %s", rec.Body.String())
+	}
+}
+
+// currentEmail 从数据库读取用户当前邮箱（绕过缓存）
+func currentEmail(t *testing.T, db *gorm.DB, id uint) string {
+	t.Helper()
+
+	var user model.User
+	if err := db.First(&user, id).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	return user.Email
+}
+
+// extractToken 从邮件正文的确认链接中提取令牌
+func extractToken(t *testing.T, body string) string {
+	t.Helper()
+
+	for _, field := range strings.Fields(body) {
+		if !strings.HasPrefix(field, "https://") {
+			continue
+		}
+		u, err := url.Parse(field)
+		if err != nil {
+			t.Fatalf("parse link %q: %v", field, err)
+		}
+		if token := u.Query().Get("token"); token != "" {
+			return token
+		}
+	}
+	t.Fatalf("no confirmation link in body: %q", body)
+	return ""
+}
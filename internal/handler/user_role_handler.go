@@ -1,16 +1,23 @@
 package handler
 
 import (
+	stderrors "errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/cccvno1/nova/internal/service"
+	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
 	"github.com/cccvno1/nova/pkg/middleware"
 	"github.com/cccvno1/nova/pkg/response"
 	"github.com/labstack/echo/v4"
 )
 
+// maxBatchUserRoles 单次批量查询角色的最大用户数
+const maxBatchUserRoles = 100
+
 // UserRoleHandler 用户角色管理处理器
 type UserRoleHandler struct {
 	rbacService service.RBACService
@@ -25,8 +32,9 @@ func NewUserRoleHandler(rbacService service.RBACService) *UserRoleHandler {
 
 // AssignRolesRequest 分配角色请求
 type AssignRolesRequest struct {
-	RoleIDs []uint `json:"role_ids" validate:"required,min=1"`
-	Domain  string `json:"domain" validate:"required,min=1,max=100"`
+	RoleIDs   []uint     `json:"role_ids" validate:"required,min=1"`
+	Domain    string     `json:"domain" validate:"required,min=1,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"` // 可选，临时授权的过期时间（RFC3339），须晚于当前时间
 }
 
 // AssignRolesToUser 给用户分配角色
@@ -45,6 +53,9 @@ func (h *UserRoleHandler) AssignRolesToUser(c echo.Context) error {
 	if err := c.Validate(&req); err != nil {
 		return err
 	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return errors.New(errors.ErrInvalidParams, "expires_at must be in the future")
+	}
 
 	// 获取当前操作用户ID
 	operatorID := middleware.GetUserID(c)
@@ -54,11 +65,19 @@ func (h *UserRoleHandler) AssignRolesToUser(c echo.Context) error {
 		return errors.New(errors.ErrForbidden, err.Error())
 	}
 
-	if err := h.rbacService.AssignRolesToUser(c.Request().Context(), uint(userID), req.RoleIDs, req.Domain, operatorID); err != nil {
+	result, err := h.rbacService.AssignRolesToUser(c.Request().Context(), uint(userID), req.RoleIDs, req.Domain, operatorID, req.ExpiresAt)
+	if err != nil {
+		if stderrors.Is(err, service.ErrPartialAssignment) {
+			return errors.NewWithDetails(errors.ErrInvalidParams, "部分角色不存在或不属于该域，未分配任何角色", result)
+		}
 		return errors.New(errors.ErrDatabase, err.Error())
 	}
 
-	return response.SuccessWithMessage(c, "角色分配成功", nil)
+	// 部分角色未分配时仍返回成功，明细见 not_found/wrong_domain
+	if result.Partial() {
+		return response.SuccessWithMessage(c, "部分角色分配成功", result)
+	}
+	return response.SuccessWithMessage(c, "角色分配成功", result)
 }
 
 // RevokeRolesRequest 撤销角色请求
@@ -98,6 +117,20 @@ func (h *UserRoleHandler) RevokeRolesFromUser(c echo.Context) error {
 	return response.SuccessWithMessage(c, "角色撤销成功", nil)
 }
 
+// ListAssignableRoles 获取当前用户可分配的角色列表（等级严格低于自己的角色）
+// GET /api/v1/user-roles/assignable
+func (h *UserRoleHandler) ListAssignableRoles(c echo.Context) error {
+	domain := middleware.GetDomain(c)
+
+	operatorID := middleware.GetUserID(c)
+	roles, err := h.rbacService.ListAssignableRoles(c.Request().Context(), operatorID, domain)
+	if err != nil {
+		return errors.New(errors.ErrDatabase, err.Error())
+	}
+
+	return response.Success(c, roles)
+}
+
 // GetUserRoles 获取用户的角色列表
 // GET /api/v1/user-roles/user/:userId
 func (h *UserRoleHandler) GetUserRoles(c echo.Context) error {
@@ -106,10 +139,7 @@ func (h *UserRoleHandler) GetUserRoles(c echo.Context) error {
 		return errors.New(errors.ErrInvalidParams, "invalid user id")
 	}
 
-	domain := c.QueryParam("domain")
-	if domain == "" {
-		domain = "default"
-	}
+	domain := middleware.GetDomain(c)
 
 	roles, err := h.rbacService.GetUserRoles(c.Request().Context(), uint(userID), domain)
 	if err != nil {
@@ -119,6 +149,31 @@ func (h *UserRoleHandler) GetUserRoles(c echo.Context) error {
 	return response.Success(c, roles)
 }
 
+// BatchGetUserRolesRequest 批量查询用户角色请求
+type BatchGetUserRolesRequest struct {
+	UserIDs []uint `json:"user_ids"`
+}
+
+// BatchGetUserRoles 批量获取多个用户在当前域的角色，用于用户列表展示角色标签
+// POST /api/v1/user-roles/batch
+// 返回用户ID到角色列表的映射，每个请求的用户都有对应条目（没有角色时为空数组）
+func (h *UserRoleHandler) BatchGetUserRoles(c echo.Context) error {
+	var req BatchGetUserRolesRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.New(errors.ErrBindJSON, "")
+	}
+	if len(req.UserIDs) > maxBatchUserRoles {
+		return errors.New(errors.ErrInvalidParams, fmt.Sprintf("at most %d user ids per request", maxBatchUserRoles))
+	}
+
+	roles, err := h.rbacService.GetRolesForUsers(c.Request().Context(), req.UserIDs, middleware.GetDomain(c))
+	if err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	return response.Success(c, roles)
+}
+
 // GetUserPermissions 获取用户的权限列表（包括通过角色继承的权限）
 // GET /api/v1/user-roles/user/:userId/permissions
 // 重要：此接口供前端调用，用于生成动态路由和菜单
@@ -128,10 +183,7 @@ func (h *UserRoleHandler) GetUserPermissions(c echo.Context) error {
 		return errors.New(errors.ErrInvalidParams, "invalid user id")
 	}
 
-	domain := c.QueryParam("domain")
-	if domain == "" {
-		domain = "default"
-	}
+	domain := middleware.GetDomain(c)
 
 	permissions, err := h.rbacService.GetUserPermissions(c.Request().Context(), uint(userID), domain)
 	if err != nil {
@@ -141,6 +193,37 @@ func (h *UserRoleHandler) GetUserPermissions(c echo.Context) error {
 	return response.Success(c, permissions)
 }
 
+// GetUserPolicies 分页获取用户在指定域生效的 Casbin 策略（区分显式与隐式）
+// GET /api/v1/user-roles/user/:userId/policies?domain=&page=&page_size=
+func (h *UserRoleHandler) GetUserPolicies(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		return errors.New(errors.ErrInvalidParams, "invalid user id")
+	}
+
+	domain := middleware.GetDomain(c)
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	pagination := &database.Pagination{
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	policies, err := h.rbacService.ListUserPolicies(c.Request().Context(), uint(userID), domain, pagination)
+	if err != nil {
+		return errors.New(errors.ErrInternalServer, err.Error())
+	}
+
+	return response.SuccessWithPagination(c, policies, pagination)
+}
+
 // CheckUserPermission 检查用户是否拥有指定权限
 // POST /api/v1/user-roles/check
 func (h *UserRoleHandler) CheckUserPermission(c echo.Context) error {
@@ -149,10 +232,7 @@ func (h *UserRoleHandler) CheckUserPermission(c echo.Context) error {
 		return errors.New(errors.ErrUnauthorized, "user not authenticated")
 	}
 
-	domain := c.QueryParam("domain")
-	if domain == "" {
-		domain = "default"
-	}
+	domain := middleware.GetDomain(c)
 	resource := c.QueryParam("resource")
 	action := c.QueryParam("action")
 
@@ -0,0 +1,18 @@
+package migration
+
+import "github.com/cccvno1/nova/pkg/database"
+
+// RequiredIndexes 热点查询依赖的索引，启动时检查（database.check_indexes），缺失时告警
+// 索引由迁移创建；新增依赖索引的查询路径时在此登记，并追加迁移版本创建索引
+func RequiredIndexes() []database.IndexSpec {
+	return []database.IndexSpec{
+		{Table: "audit_logs", Name: "idx_audit_logs_created_at", Purpose: "audit log time-range queries, newest-first paging and stats"},
+		{Table: "audit_logs", Name: "idx_audit_logs_user_id", Purpose: "audit logs by user"},
+		{Table: "files", Name: "idx_files_hash", Purpose: "file lookup by content hash (instant upload, content-addressed storage)"},
+		{Table: "files", Name: "idx_files_uploaded_by", Purpose: "file lists and quota usage by uploader"},
+		{Table: "user_roles", Name: "idx_user_roles_user_id", Purpose: "user role and permission resolution"},
+		{Table: "user_roles", Name: "idx_user_roles_expires_at", Purpose: "expired role assignment purge"},
+		{Table: "users", Name: "idx_users_username_trgm", Purpose: "user keyword search"},
+		{Table: "users", Name: "idx_users_email_trgm", Purpose: "user keyword search"},
+	}
+}
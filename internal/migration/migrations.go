@@ -0,0 +1,167 @@
+// Package migration 定义应用的数据库迁移版本
+package migration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cccvno1/nova/internal/migration/schema0001"
+	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// All 返回全部迁移（按版本升序）
+// 已发布的迁移不可修改：模型新增字段、索引等结构变化需追加新的版本。
+// 迁移中不得引用 internal/model 等会继续变化的模型，需使用显式 DDL 或该版本的结构快照
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			Version:     "0001",
+			Description: "initial schema",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&schema0001.User{},
+					&schema0001.Role{},
+					&schema0001.Permission{},
+					&schema0001.RolePermission{},
+					&schema0001.UserRole{},
+					&schema0001.File{},
+					&schema0001.Task{},
+					&schema0001.AuditLog{},
+					&schema0001.OutboxEvent{},
+				)
+			},
+		},
+		{
+			Version:     "0002",
+			Description: "token blacklist",
+			Up: func(tx *gorm.DB) error {
+				// 令牌黑名单使用数据库存储（auth.blacklist_store=database）时使用；
+				// 此前由服务启动时单独建表，已存在时跳过
+				return tx.AutoMigrate(&tokenBlacklist0002{})
+			},
+		},
+		{
+			Version:     "0003",
+			Description: "user phone and profile",
+			Up: func(tx *gorm.DB) error {
+				statements := []string{
+					"ALTER TABLE users ADD COLUMN IF NOT EXISTS phone varchar(20)",
+					"ALTER TABLE users ADD COLUMN IF NOT EXISTS profile text",
+				}
+				for _, stmt := range statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version:     "0004",
+			Description: "casbin policy version",
+			Up: func(tx *gorm.DB) error {
+				// 此前由 Casbin enforcer 初始化时建表，已存在时跳过
+				if err := tx.AutoMigrate(&casbinPolicyVersion0004{}); err != nil {
+					return err
+				}
+				return tx.Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&casbinPolicyVersion0004{ID: 1, UpdatedAt: time.Now()}).Error
+			},
+		},
+		{
+			Version:     "0005",
+			Description: "trigram indexes for user search",
+			Up: func(tx *gorm.DB) error {
+				// 用户搜索使用 LOWER(column) LIKE '%keyword%'，普通 B-tree 索引无法命中
+				statements := []string{
+					"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+					"CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (LOWER(username) gin_trgm_ops)",
+					"CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (LOWER(email) gin_trgm_ops)",
+					"CREATE INDEX IF NOT EXISTS idx_users_nickname_trgm ON users USING gin (LOWER(nickname) gin_trgm_ops)",
+				}
+				for _, stmt := range statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version:     "0006",
+			Description: "user role expiry",
+			Up: func(tx *gorm.DB) error {
+				statements := []string{
+					"ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expires_at timestamptz",
+					"CREATE INDEX IF NOT EXISTS idx_user_roles_expires_at ON user_roles (expires_at)",
+				}
+				for _, stmt := range statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version:     "0007",
+			Description: "hot path indexes",
+			// 在已有大量数据的表上建索引，使用 CONCURRENTLY 不阻塞写入，不能在事务中执行
+			NoTransaction: true,
+			Up: func(db *gorm.DB) error {
+				// 审计日志按时间范围查询、按时间倒序分页，created_at 来自公共模型，模型中没有索引；
+				// files.hash 的索引由 0001 创建，这里补建手工建表或删除过索引的库
+				if err := createIndexConcurrently(db, "idx_audit_logs_created_at", "audit_logs", "created_at"); err != nil {
+					return err
+				}
+				return createIndexConcurrently(db, "idx_files_hash", "files", "hash")
+			},
+		},
+	}
+}
+
+// createIndexConcurrently 在 PostgreSQL 上以 CREATE INDEX CONCURRENTLY IF NOT EXISTS 建索引，建索引期间不阻塞表的写入；
+// 之前中断的并发建索引会留下无效索引（IF NOT EXISTS 会跳过它），先删除再重建。其他数据库（测试使用的 SQLite）直接建索引
+func createIndexConcurrently(db *gorm.DB, name, table, columns string) error {
+	if db.Dialector.Name() != "postgres" {
+		return db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, table, columns)).Error
+	}
+
+	var invalid bool
+	if err := db.Raw(`SELECT EXISTS (
+		SELECT 1 FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE c.relname = ? AND c.relnamespace = current_schema()::regnamespace AND NOT i.indisvalid)`, name).
+		Scan(&invalid).Error; err != nil {
+		return err
+	}
+	if invalid {
+		if err := db.Exec(fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", name)).Error; err != nil {
+			return err
+		}
+	}
+	return db.Exec(fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)", name, table, columns)).Error
+}
+
+// tokenBlacklist0002 迁移 0002 的令牌黑名单表结构快照（对应 auth.BlacklistEntry）
+type tokenBlacklist0002 struct {
+	KeyHash   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (tokenBlacklist0002) TableName() string {
+	return "token_blacklist"
+}
+
+// casbinPolicyVersion0004 迁移 0004 的策略版本表结构快照（对应 casbin.PolicyVersion），ID 固定为 1
+type casbinPolicyVersion0004 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Version   int64     `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+func (casbinPolicyVersion0004) TableName() string {
+	return "casbin_policy_version"
+}
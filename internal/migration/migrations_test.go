@@ -0,0 +1,183 @@
+package migration
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/migration/schema0001"
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// migrationByVersion 返回指定版本的迁移
+func migrationByVersion(t *testing.T, version string) func(tx *gorm.DB) error {
+	t.Helper()
+
+	for _, m := range All() {
+		if m.Version == version {
+			return m.Up
+		}
+	}
+	t.Fatalf("migration %s not found", version)
+	return nil
+}
+
+// 0001 的快照使用 GIN 索引等 PostgreSQL 语法，这里只解析结构，校验表名、列与关联表外键列与当时一致
+func TestSchema0001Snapshot(t *testing.T) {
+	cache := &sync.Map{}
+	parse := func(v interface{}) *schema.Schema {
+		s, err := schema.Parse(v, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("parse %T: %v", v, err)
+		}
+		return s
+	}
+
+	users := parse(&schema0001.User{})
+	if users.Table != "users" {
+		t.Fatalf("users table = %q", users.Table)
+	}
+	// phone、profile 由后续迁移添加，不属于 0001
+	for _, column := range []string{"phone", "profile"} {
+		if users.LookUpField(column) != nil {
+			t.Errorf("users.%s in 0001 snapshot", column)
+		}
+	}
+
+	roles := parse(&schema0001.Role{})
+	rel, ok := roles.Relationships.Relations["Permissions"]
+	if !ok || rel.JoinTable == nil {
+		t.Fatal("roles.Permissions many2many relation missing")
+	}
+	if rel.JoinTable.Table != "role_permissions" {
+		t.Errorf("join table = %q, want role_permissions", rel.JoinTable.Table)
+	}
+	for _, column := range []string{"role_id", "permission_id"} {
+		if rel.JoinTable.LookUpField(column) == nil {
+			t.Errorf("join table column %s missing", column)
+		}
+	}
+	if _, ok := rel.JoinTable.Relationships.Relations["Role"]; !ok {
+		t.Error("join table relation Role missing (constraint name fk_role_permissions_role)")
+	}
+
+	userRoles := parse(&schema0001.UserRole{})
+	if c := userRoles.Relationships.Relations["Role"].ParseConstraint(); c == nil || c.Name != "fk_user_roles_role" {
+		t.Errorf("user_roles role constraint = %+v", c)
+	}
+}
+
+func TestTokenBlacklistMigration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	up := migrationByVersion(t, "0002")
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0002: %v", err)
+	}
+	// 此前由服务启动时建表，已存在时再次执行不报错
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0002 on existing table: %v", err)
+	}
+
+	for _, column := range []string{"key_hash", "expires_at", "created_at"} {
+		if !db.Migrator().HasColumn("token_blacklist", column) {
+			t.Errorf("token_blacklist.%s missing", column)
+		}
+	}
+	if !db.Migrator().HasIndex("token_blacklist", "idx_token_blacklist_expires_at") {
+		t.Error("token_blacklist.idx_token_blacklist_expires_at missing")
+	}
+}
+
+func TestCasbinPolicyVersionMigration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	up := migrationByVersion(t, "0004")
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0004: %v", err)
+	}
+	// 已有版本行（此前由 enforcer 初始化创建）时保留原版本
+	if err := db.Exec("UPDATE casbin_policy_version SET version = 7 WHERE id = 1").Error; err != nil {
+		t.Fatalf("update version: %v", err)
+	}
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0004 on existing table: %v", err)
+	}
+
+	var rows []struct {
+		ID      uint
+		Version int64
+	}
+	if err := db.Table("casbin_policy_version").Find(&rows).Error; err != nil {
+		t.Fatalf("query versions: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ID != 1 || rows[0].Version != 7 {
+		t.Fatalf("policy version rows = %+v, want single row id=1 version=7", rows)
+	}
+}
+
+func TestHotPathIndexMigration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 只建索引涉及的列；users、user_roles 表不存在，检查时跳过
+	for _, stmt := range []string{
+		"CREATE TABLE audit_logs (id integer primary key, created_at datetime, user_id integer)",
+		"CREATE INDEX idx_audit_logs_user_id ON audit_logs (user_id)",
+		"CREATE TABLE files (id integer primary key, hash varchar(64), uploaded_by integer)",
+		"CREATE INDEX idx_files_uploaded_by ON files (uploaded_by)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	missing := database.MissingIndexes(t.Context(), db, RequiredIndexes())
+	names := make([]string, len(missing))
+	for i, idx := range missing {
+		names[i] = idx.Name
+	}
+	if len(names) != 2 || names[0] != "idx_audit_logs_created_at" || names[1] != "idx_files_hash" {
+		t.Fatalf("missing before migration = %v, want [idx_audit_logs_created_at idx_files_hash]", names)
+	}
+
+	up := migrationByVersion(t, "0007")
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0007: %v", err)
+	}
+	// 索引已存在时再次执行不报错
+	if err := up(db); err != nil {
+		t.Fatalf("migration 0007 on existing indexes: %v", err)
+	}
+
+	if missing := database.MissingIndexes(t.Context(), db, RequiredIndexes()); len(missing) > 0 {
+		t.Fatalf("missing after migration = %+v", missing)
+	}
+}
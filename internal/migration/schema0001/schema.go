@@ -0,0 +1,181 @@
+// Package schema0001 迁移 0001 的表结构快照
+//
+// 类型名、字段与关联与该版本发布时的模型定义一致，使生成的列、索引、外键约束及
+// role_permissions 关联表与当时相同；之后模型的变化不会影响已发布迁移创建的表结构，
+// 因此这里的定义不可修改
+package schema0001
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Model 公共字段（与当时的 database.Model 相同）
+type Model struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// User 用户表
+type User struct {
+	Model
+	Username      string `gorm:"uniqueIndex;not null;size:50"`
+	Email         string `gorm:"uniqueIndex;not null;size:100"`
+	Password      string `gorm:"not null;size:255"`
+	Nickname      string `gorm:"size:50"`
+	Avatar        string `gorm:"size:255"`
+	Status        int    `gorm:"default:1;not null"`
+	DefaultDomain string `gorm:"size:100"`
+}
+
+func (User) TableName() string { return "users" }
+
+// Role 角色表
+type Role struct {
+	Model
+	Name        string `gorm:"size:100;not null;uniqueIndex:idx_role_domain"`
+	DisplayName string `gorm:"size:100;not null"`
+	Description string `gorm:"size:500"`
+	Domain      string `gorm:"size:100;not null;uniqueIndex:idx_role_domain;index"`
+	Category    string `gorm:"size:50"`
+	IsSystem    bool   `gorm:"default:false;index"`
+	Level       int    `gorm:"default:10;index"`
+	Sort        int    `gorm:"default:0"`
+	Status      int8   `gorm:"default:1;index"`
+
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+func (Role) TableName() string { return "roles" }
+
+// Permission 权限表
+type Permission struct {
+	Model
+	Name        string `gorm:"size:100;not null;uniqueIndex:idx_permission_domain"`
+	DisplayName string `gorm:"size:100;not null"`
+	Description string `gorm:"size:500"`
+	Type        string `gorm:"size:20;not null;index"`
+	Domain      string `gorm:"size:100;not null;uniqueIndex:idx_permission_domain;index"`
+	Resource    string `gorm:"size:200;not null"`
+	Action      string `gorm:"size:50;not null"`
+	Category    string `gorm:"size:50;index"`
+	ParentID    uint   `gorm:"default:0;index"`
+	Path        string `gorm:"size:200"`
+	Component   string `gorm:"size:200"`
+	Icon        string `gorm:"size:50"`
+	IsSystem    bool   `gorm:"default:false;index"`
+	Sort        int    `gorm:"default:0"`
+	Status      int8   `gorm:"default:1;index"`
+
+	Children []Permission `gorm:"foreignKey:ParentID"`
+}
+
+func (Permission) TableName() string { return "permissions" }
+
+// RolePermission 角色-权限关联表
+type RolePermission struct {
+	Model
+	RoleID       uint `gorm:"not null;index"`
+	PermissionID uint `gorm:"not null;index"`
+}
+
+func (RolePermission) TableName() string { return "role_permissions" }
+
+// UserRole 用户-角色关联表
+type UserRole struct {
+	Model
+	UserID     uint   `gorm:"not null;index"`
+	RoleID     uint   `gorm:"not null;index"`
+	Domain     string `gorm:"size:100;not null;index"`
+	AssignedBy uint   `gorm:"default:0"`
+
+	Role *Role `gorm:"foreignKey:RoleID"`
+	User *User `gorm:"foreignKey:UserID"`
+}
+
+func (UserRole) TableName() string { return "user_roles" }
+
+// File 文件表
+type File struct {
+	Model
+	OriginalName    string   `gorm:"not null;size:255"`
+	SavedName       string   `gorm:"not null;size:255;index"`
+	Path            string   `gorm:"not null;size:500"`
+	URL             string   `gorm:"size:500"`
+	Size            int64    `gorm:"not null"`
+	MimeType        string   `gorm:"not null;size:100"`
+	Extension       string   `gorm:"size:20;index"`
+	Hash            string   `gorm:"size:64;index"`
+	StorageType     string   `gorm:"not null;size:20;default:'local'"`
+	BucketName      string   `gorm:"size:100"`
+	Category        string   `gorm:"size:50;index"`
+	UploadedBy      uint     `gorm:"not null;index"`
+	Status          int      `gorm:"default:1;not null;index"`
+	Tags            []string `gorm:"type:jsonb;serializer:json;index:idx_files_tags,type:gin"`
+	ContentEncoding string   `gorm:"size:20"`
+	ThumbnailPath   string   `gorm:"size:500"`
+	ThumbnailURL    string   `gorm:"size:500"`
+	ThumbnailSpec   string   `gorm:"size:100"`
+	Width           int      `gorm:"default:0"`
+	Height          int      `gorm:"default:0"`
+
+	Uploader *User `gorm:"foreignKey:UploadedBy"`
+}
+
+func (File) TableName() string { return "files" }
+
+// Task 任务记录表
+type Task struct {
+	Model
+	TaskID     string `gorm:"not null;size:100;uniqueIndex"`
+	Name       string `gorm:"not null;size:100;index"`
+	Type       string `gorm:"not null;size:50;index"`
+	Payload    string `gorm:"type:text"`
+	Status     string `gorm:"not null;size:20;index;default:'pending'"`
+	RetryCount int    `gorm:"default:0"`
+	MaxRetry   int    `gorm:"default:3"`
+	Error      string `gorm:"type:text"`
+	UserID     uint   `gorm:"index"`
+	Progress   int    `gorm:"default:0"`
+	Result     string `gorm:"type:text"`
+}
+
+func (Task) TableName() string { return "tasks" }
+
+// AuditLog 审计日志表
+type AuditLog struct {
+	Model
+	UserID     uint   `gorm:"index"`
+	Username   string `gorm:"size:100;index"`
+	Action     string `gorm:"not null;size:100;index"`
+	Resource   string `gorm:"not null;size:100;index"`
+	ResourceID string `gorm:"size:100;index"`
+	Method     string `gorm:"not null;size:10"`
+	Path       string `gorm:"not null;size:500;index"`
+	IP         string `gorm:"not null;size:50;index"`
+	UserAgent  string `gorm:"size:500"`
+	Request    string `gorm:"type:text"`
+	Response   string `gorm:"type:text"`
+	StatusCode int    `gorm:"not null;index"`
+	Duration   int64  `gorm:"not null"`
+	Error      string `gorm:"type:text"`
+	Extra      string `gorm:"type:jsonb"`
+}
+
+func (AuditLog) TableName() string { return "audit_logs" }
+
+// OutboxEvent 事务性发件箱表
+type OutboxEvent struct {
+	Model
+	Topic       string     `gorm:"not null;size:100;index"`
+	Payload     string     `gorm:"type:text"`
+	Status      string     `gorm:"not null;size:20;index;default:'pending'"`
+	Attempts    int        `gorm:"default:0"`
+	LastError   string     `gorm:"type:text"`
+	ProcessedAt *time.Time `gorm:"index"`
+}
+
+func (OutboxEvent) TableName() string { return "outbox_events" }
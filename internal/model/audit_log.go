@@ -9,21 +9,21 @@ import (
 // AuditLog 审计日志模型
 type AuditLog struct {
 	database.Model
-	UserID     uint   `gorm:"index" json:"user_id"`                    // 用户ID
-	Username   string `gorm:"size:100;index" json:"username"`          // 用户名
-	Action     string `gorm:"not null;size:100;index" json:"action"`   // 操作动作（如：create, update, delete, login）
-	Resource   string `gorm:"not null;size:100;index" json:"resource"` // 操作资源（如：user, file, role）
-	ResourceID string `gorm:"size:100;index" json:"resource_id"`       // 资源ID
-	Method     string `gorm:"not null;size:10" json:"method"`          // HTTP 方法
-	Path       string `gorm:"not null;size:500;index" json:"path"`     // 请求路径
-	IP         string `gorm:"not null;size:50;index" json:"ip"`        // 客户端 IP
-	UserAgent  string `gorm:"size:500" json:"user_agent"`              // User Agent
-	Request    string `gorm:"type:text" json:"request,omitempty"`      // 请求体（可选）
-	Response   string `gorm:"type:text" json:"response,omitempty"`     // 响应体（可选）
-	StatusCode int    `gorm:"not null;index" json:"status_code"`       // HTTP 状态码
-	Duration   int64  `gorm:"not null" json:"duration"`                // 请求耗时（毫秒）
-	Error      string `gorm:"type:text" json:"error,omitempty"`        // 错误信息
-	Extra      string `gorm:"type:jsonb" json:"extra,omitempty"`       // 额外信息（JSON）
+	UserID     uint   `gorm:"index" json:"user_id"`                                     // 用户ID
+	Username   string `gorm:"size:100;index" json:"username"`                           // 用户名
+	Action     string `gorm:"not null;size:100;index" json:"action"`                    // 操作动作（如：create, update, delete, login）
+	Resource   string `gorm:"not null;size:100;index" json:"resource"`                  // 操作资源（如：user, file, role）
+	ResourceID string `gorm:"size:100;index" json:"resource_id"`                        // 资源ID
+	Method     string `gorm:"not null;size:10" json:"method"`                           // HTTP 方法
+	Path       string `gorm:"not null;size:500;index" json:"path"`                      // 请求路径
+	IP         string `gorm:"not null;size:50;index" json:"ip"`                         // 客户端 IP
+	UserAgent  string `gorm:"size:500" json:"user_agent"`                               // User Agent
+	Request    string `gorm:"type:text;serializer:encrypted" json:"request,omitempty"`  // 请求体（可选，开启 audit_log.encrypt_bodies 时加密存储）
+	Response   string `gorm:"type:text;serializer:encrypted" json:"response,omitempty"` // 响应体（可选，同上）
+	StatusCode int    `gorm:"not null;index" json:"status_code"`                        // HTTP 状态码
+	Duration   int64  `gorm:"not null" json:"duration"`                                 // 请求耗时（毫秒）
+	Error      string `gorm:"type:text" json:"error,omitempty"`                         // 错误信息
+	Extra      string `gorm:"type:jsonb" json:"extra,omitempty"`                        // 额外信息（JSON）
 }
 
 func (AuditLog) TableName() string {
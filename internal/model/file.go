@@ -12,20 +12,30 @@ type File struct {
 	Size         int64  `gorm:"not null" json:"size"`                                 // 文件大小（字节）
 	MimeType     string `gorm:"not null;size:100" json:"mime_type"`                   // MIME 类型
 	Extension    string `gorm:"size:20;index" json:"extension"`                       // 文件扩展名
-	Hash         string `gorm:"size:64;index" json:"hash"`                            // 文件 Hash（MD5/SHA256）
+	Hash         string `gorm:"size:64;index" json:"hash"`                            // 文件 Hash（MD5/SHA256），基于原始内容计算
 	StorageType  string `gorm:"not null;size:20;default:'local'" json:"storage_type"` // 存储类型: local, oss, s3
 	BucketName   string `gorm:"size:100" json:"bucket_name,omitempty"`                // 存储桶名称（OSS/S3）
 	Category     string `gorm:"size:50;index" json:"category"`                        // 文件分类: avatar, document, image, video, other
 	UploadedBy   uint   `gorm:"not null;index" json:"uploaded_by"`                    // 上传用户ID
 	Status       int    `gorm:"default:1;not null;index" json:"status"`               // 状态: 1=正常, 2=已删除, 3=审核中
 
+	// 标签（JSON 数组，PostgreSQL 下使用 GIN 索引支持包含查询）
+	Tags []string `gorm:"type:jsonb;serializer:json;index:idx_files_tags,type:gin" json:"tags,omitempty"`
+
+	// 可选：压缩存储
+	ContentEncoding string `gorm:"size:20" json:"content_encoding,omitempty"` // 存储内容编码（gzip 表示压缩存储，Size 仍为原始大小）
+
 	// 可选：缩略图相关
 	ThumbnailPath string `gorm:"size:500" json:"thumbnail_path,omitempty"` // 缩略图路径
 	ThumbnailURL  string `gorm:"size:500" json:"thumbnail_url,omitempty"`  // 缩略图 URL
+	ThumbnailSpec string `gorm:"size:100" json:"-"`                        // 生成缩略图时的配置指纹（尺寸/模式/质量），配置变更后用于判断是否需要重新生成
 
 	// 可选：元数据
 	Width  int `gorm:"default:0" json:"width,omitempty"`  // 图片宽度
 	Height int `gorm:"default:0" json:"height,omitempty"` // 图片高度
+
+	// 关联关系（按需预加载）
+	Uploader *User `json:"uploader,omitempty" gorm:"foreignKey:UploadedBy"`
 }
 
 func (File) TableName() string {
@@ -42,7 +52,12 @@ const (
 	FileCategoryOther    = "other"
 )
 
+// MaxFileTags 单个文件的最大标签数量
+const MaxFileTags = 20
+
 // FileStatus 文件状态常量
+// 是否已删除以 GORM 软删除（deleted_at）为准，删除时 status 同步置为 FileStatusDeleted；
+// 仓储层的所有查找方法同时排除两者，已删除文件对普通查询一致不可见
 const (
 	FileStatusNormal  = 1
 	FileStatusDeleted = 2
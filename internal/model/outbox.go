@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/cccvno1/nova/pkg/database"
+)
+
+// OutboxEvent 事务性发件箱事件
+// 与业务数据在同一数据库事务中写入，由后台轮询器投递到任务队列，保证至少一次投递
+type OutboxEvent struct {
+	database.Model
+	Topic       string     `gorm:"not null;size:100;index" json:"topic"`                   // 事件主题（投递时作为队列任务名称）
+	Payload     string     `gorm:"type:text" json:"payload"`                               // 事件载荷（JSON）
+	Status      string     `gorm:"not null;size:20;index;default:'pending'" json:"status"` // 投递状态
+	Attempts    int        `gorm:"default:0" json:"attempts"`                              // 已尝试投递次数
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`                  // 最近一次投递失败原因
+	ProcessedAt *time.Time `gorm:"index" json:"processed_at,omitempty"`                    // 投递成功时间
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// OutboxStatus 发件箱事件状态常量
+const (
+	OutboxStatusPending = "pending" // 待投递
+	OutboxStatusDone    = "done"    // 已投递到队列
+	OutboxStatusFailed  = "failed"  // 超过最大尝试次数，放弃投递
+)
@@ -1,9 +1,18 @@
 package model
 
 import (
+	"time"
+
 	"github.com/cccvno1/nova/pkg/database"
 )
 
+const (
+	// DefaultRoleLevel 未指定等级时角色的默认等级（与 level 列默认值一致）
+	DefaultRoleLevel = 10
+	// SuperAdminRoleLevel 超级管理员角色等级
+	SuperAdminRoleLevel = 100
+)
+
 // Role 角色模型（用于 UI 管理和元数据存储）
 // 实际权限验证由 Casbin 处理，这个模型主要用于：
 // 1. 提供友好的 UI 展示（中文名称、描述）
@@ -55,6 +64,9 @@ type UserRole struct {
 	Domain     string `json:"domain" gorm:"size:100;not null;index"` // 域/租户
 	AssignedBy uint   `json:"assigned_by" gorm:"default:0"`          // 分配人ID
 
+	// 临时授权：过期后不再参与权限解析，由定时任务清理
+	ExpiresAt *time.Time `json:"expires_at,omitempty" gorm:"index"` // 过期时间，为空表示长期有效
+
 	// 关联关系
 	Role *Role `json:"role,omitempty" gorm:"foreignKey:RoleID"`
 	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
@@ -14,6 +14,9 @@ type Task struct {
 	MaxRetry   int    `gorm:"default:3" json:"max_retry"`                             // 最大重试次数
 	Error      string `gorm:"type:text" json:"error,omitempty"`                       // 错误信息
 	UserID     uint   `gorm:"index" json:"user_id,omitempty"`                         // 关联用户ID（可选）
+
+	Progress int    `gorm:"default:0" json:"progress"`         // 处理进度（0-100）
+	Result   string `gorm:"type:text" json:"result,omitempty"` // 处理结果摘要（JSON，由任务处理器写入）
 }
 
 func (Task) TableName() string {
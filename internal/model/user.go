@@ -9,7 +9,11 @@ type User struct {
 	Password string `gorm:"not null;size:255" json:"-"`
 	Nickname string `gorm:"size:50" json:"nickname"`
 	Avatar   string `gorm:"size:255" json:"avatar"`
+	Phone    string `gorm:"size:20" json:"phone"`
+	Profile  string `gorm:"type:text" json:"-"`               // 自定义资料（JSON 对象），允许的字段由 user.profile_fields 配置
 	Status   int    `gorm:"default:1;not null" json:"status"` // 1: active, 2: disabled
+
+	DefaultDomain string `gorm:"size:100" json:"default_domain"` // 未显式指定域时使用的默认域，为空时使用 "default"
 }
 
 func (User) TableName() string {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
 )
 
 // AuditLogRepository 审计日志仓储接口
@@ -22,6 +23,7 @@ type AuditLogRepository interface {
 	ListByIP(ctx context.Context, ip string, pagination *database.Pagination) ([]model.AuditLog, error)
 	ListByTimeRange(ctx context.Context, startTime, endTime time.Time, pagination *database.Pagination) ([]model.AuditLog, error)
 	Search(ctx context.Context, filters map[string]interface{}, pagination *database.Pagination) ([]model.AuditLog, error)
+	ListAfter(ctx context.Context, filters map[string]interface{}, afterID uint, limit int) ([]model.AuditLog, error)
 
 	// 统计方法
 	CountByUser(ctx context.Context, userID uint) (int64, error)
@@ -34,6 +36,15 @@ type AuditLogRepository interface {
 
 	// 清理方法
 	DeleteBefore(ctx context.Context, beforeTime time.Time) (int64, error)
+	CountAll(ctx context.Context) (int64, error)                                     // 表中的物理行数（含已软删除）
+	PurgeBefore(ctx context.Context, beforeTime time.Time, limit int) (int64, error) // 物理删除指定时间之前最旧的至多 limit 条
+	PurgeOldest(ctx context.Context, limit int) (int64, error)                       // 物理删除最旧的 limit 条
+}
+
+// auditLogListColumns 列表查询的列：不含请求体、响应体大字段，完整记录通过 FindByID 获取
+var auditLogListColumns = []string{
+	"id", "created_at", "updated_at", "user_id", "username", "action", "resource", "resource_id",
+	"method", "path", "ip", "user_agent", "status_code", "duration", "error", "extra",
 }
 
 // auditLogRepository 审计日志仓储实现
@@ -50,31 +61,31 @@ func NewAuditLogRepository(db *database.Database) AuditLogRepository {
 
 // List 查询审计日志列表
 func (r *auditLogRepository) List(ctx context.Context, pagination *database.Pagination) ([]model.AuditLog, error) {
-	return r.Repository.FindWithPagination(ctx, pagination, "")
+	return r.Repository.FindColumnsWithPagination(ctx, auditLogListColumns, pagination, nil)
 }
 
 // ListByUser 根据用户ID查询审计日志
 func (r *auditLogRepository) ListByUser(ctx context.Context, userID uint, pagination *database.Pagination) ([]model.AuditLog, error) {
 	query := "user_id = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, userID)
+	return r.Repository.FindColumnsWithPagination(ctx, auditLogListColumns, pagination, query, userID)
 }
 
 // ListByAction 根据操作动作查询审计日志
 func (r *auditLogRepository) ListByAction(ctx context.Context, action string, pagination *database.Pagination) ([]model.AuditLog, error) {
 	query := "action = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, action)
+	return r.Repository.FindColumnsWithPagination(ctx, auditLogListColumns, pagination, query, action)
 }
 
 // ListByResource 根据资源类型查询审计日志
 func (r *auditLogRepository) ListByResource(ctx context.Context, resource string, pagination *database.Pagination) ([]model.AuditLog, error) {
 	query := "resource = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, resource)
+	return r.Repository.FindColumnsWithPagination(ctx, auditLogListColumns, pagination, query, resource)
 }
 
 // ListByIP 根据IP地址查询审计日志
 func (r *auditLogRepository) ListByIP(ctx context.Context, ip string, pagination *database.Pagination) ([]model.AuditLog, error) {
 	query := "ip = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, ip)
+	return r.Repository.FindColumnsWithPagination(ctx, auditLogListColumns, pagination, query, ip)
 }
 
 // ListByTimeRange 根据时间范围查询审计日志
@@ -95,8 +106,8 @@ func (r *auditLogRepository) ListByTimeRange(ctx context.Context, startTime, end
 		db = db.Offset(offset).Limit(pagination.PageSize)
 	}
 
-	// 默认按创建时间倒序
-	db = db.Order("created_at DESC")
+	// 默认按创建时间倒序，列表不加载请求体、响应体
+	db = db.Select(auditLogListColumns).Order("created_at DESC")
 
 	if err := db.Find(&logs).Error; err != nil {
 		return nil, err
@@ -107,11 +118,29 @@ func (r *auditLogRepository) ListByTimeRange(ctx context.Context, startTime, end
 
 // Search 复合条件搜索审计日志
 func (r *auditLogRepository) Search(ctx context.Context, filters map[string]interface{}, pagination *database.Pagination) ([]model.AuditLog, error) {
-	var logs []model.AuditLog
+	// 默认按创建时间倒序，列表不加载请求体、响应体
+	query := database.NewQueryBuilder().
+		Scopes(func(db *gorm.DB) *gorm.DB { return applyAuditLogFilters(db, filters) }).
+		Select(auditLogListColumns...).
+		Order("created_at DESC")
+	return r.Repository.FindQuery(ctx, query, pagination)
+}
 
-	db := r.Repository.DB().WithContext(ctx).Model(&model.AuditLog{})
+// ListAfter 按ID升序返回 afterID 之后的最多 limit 条记录（键集分页），用于导出时分批读取
+// 与列表相同，不加载请求体、响应体
+func (r *auditLogRepository) ListAfter(ctx context.Context, filters map[string]interface{}, afterID uint, limit int) ([]model.AuditLog, error) {
+	var logs []model.AuditLog
+	db := applyAuditLogFilters(r.Repository.DB().WithContext(ctx).Model(&model.AuditLog{}), filters)
+	err := db.Select(auditLogListColumns).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
 
-	// 应用过滤条件
+// applyAuditLogFilters 应用 Search/ListAfter 共用的过滤条件
+func applyAuditLogFilters(db *gorm.DB, filters map[string]interface{}) *gorm.DB {
 	if userID, ok := filters["user_id"].(uint); ok && userID > 0 {
 		db = db.Where("user_id = ?", userID)
 	}
@@ -139,26 +168,7 @@ func (r *auditLogRepository) Search(ctx context.Context, filters map[string]inte
 	if endTime, ok := filters["end_time"].(time.Time); ok && !endTime.IsZero() {
 		db = db.Where("created_at <= ?", endTime)
 	}
-
-	// 统计总数
-	if err := db.Count(&pagination.Total).Error; err != nil {
-		return nil, err
-	}
-
-	// 分页查询
-	if pagination.PageSize > 0 {
-		offset := (pagination.Page - 1) * pagination.PageSize
-		db = db.Offset(offset).Limit(pagination.PageSize)
-	}
-
-	// 默认按创建时间倒序
-	db = db.Order("created_at DESC")
-
-	if err := db.Find(&logs).Error; err != nil {
-		return nil, err
-	}
-
-	return logs, nil
+	return db
 }
 
 // CountByUser 统计用户的操作次数
@@ -308,3 +318,32 @@ func (r *auditLogRepository) DeleteBefore(ctx context.Context, beforeTime time.T
 
 	return result.RowsAffected, result.Error
 }
+
+// CountAll 统计表中的物理行数（含已软删除的记录），用于按行数上限清理
+func (r *auditLogRepository) CountAll(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.Repository.DB().WithContext(ctx).Unscoped().Model(&model.AuditLog{}).Count(&count).Error
+	return count, err
+}
+
+// PurgeBefore 物理删除指定时间之前最旧的至多 limit 条审计日志（含已软删除的记录）
+// 按ID升序分批删除，每次只锁定一批行，避免一次删除大量数据长时间占用锁
+func (r *auditLogRepository) PurgeBefore(ctx context.Context, beforeTime time.Time, limit int) (int64, error) {
+	db := r.Repository.DB().WithContext(ctx)
+	oldest := db.Unscoped().Model(&model.AuditLog{}).Select("id").
+		Where("created_at < ?", beforeTime).
+		Order("id ASC").
+		Limit(limit)
+
+	result := db.Unscoped().Where("id IN (?)", oldest).Delete(&model.AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeOldest 物理删除ID最小（最早写入）的 limit 条审计日志（含已软删除的记录）
+func (r *auditLogRepository) PurgeOldest(ctx context.Context, limit int) (int64, error) {
+	db := r.Repository.DB().WithContext(ctx)
+	oldest := db.Unscoped().Model(&model.AuditLog{}).Select("id").Order("id ASC").Limit(limit)
+
+	result := db.Unscoped().Where("id IN (?)", oldest).Delete(&model.AuditLog{})
+	return result.RowsAffected, result.Error
+}
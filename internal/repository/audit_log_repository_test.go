@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/encrypt"
+)
+
+// useFieldEncryption 为 serializer:encrypted 列设置密钥环，测试结束后恢复
+func useFieldEncryption(t *testing.T, encryptWrites bool) *encrypt.KeyRing {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	kr, err := encrypt.NewKeyRing("v1", base64.StdEncoding.EncodeToString(key), nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	database.SetFieldEncryption(kr, encryptWrites)
+	t.Cleanup(func() { database.SetFieldEncryption(nil, false) })
+	return kr
+}
+
+func newAuditLog(request, response string) *model.AuditLog {
+	return &model.AuditLog{
+		Action: "create", Resource: "user", Method: "POST", Path: "/api/v1/users", IP: "127.0.0.1",
+		Request: request, Response: response, StatusCode: 201,
+	}
+}
+
+// rawBodies 绕过序列化器读取数据库中实际存储的请求体与响应体
+func rawBodies(t *testing.T, db *database.Database, id uint) (string, string) {
+	t.Helper()
+
+	var row struct{ Request, Response string }
+	if err := db.DB.Table("audit_logs").Select("request", "response").Where("id = ?", id).Scan(&row).Error; err != nil {
+		t.Fatalf("read raw row: %v", err)
+	}
+	return row.Request, row.Response
+}
+
+func TestAuditLogRepository_EncryptsBodies(t *testing.T) {
+	useFieldEncryption(t, true)
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+	repo := NewAuditLogRepository(db)
+	ctx := context.Background()
+
+	log := newAuditLog(`{"email":"alice@example.com"}`, `{"code":0}`)
+	if err := repo.Create(ctx, log); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	rawReq, rawResp := rawBodies(t, db, log.ID)
+	if !strings.HasPrefix(rawReq, "enc:1:v1:") || !strings.HasPrefix(rawResp, "enc:1:v1:") {
+		t.Fatalf("stored bodies not encrypted: request=%q response=%q", rawReq, rawResp)
+	}
+	if strings.Contains(rawReq, "alice") {
+		t.Fatal("stored request contains plaintext")
+	}
+
+	got, err := repo.FindByID(ctx, log.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if got.Request != `{"email":"alice@example.com"}` || got.Response != `{"code":0}` {
+		t.Fatalf("decrypted bodies = %q / %q", got.Request, got.Response)
+	}
+}
+
+func TestAuditLogRepository_ReadsPlaintextAfterEnablingEncryption(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+	repo := NewAuditLogRepository(db)
+	ctx := context.Background()
+
+	legacy := newAuditLog(`{"name":"legacy"}`, "")
+	if err := repo.Create(ctx, legacy); err != nil {
+		t.Fatalf("Create legacy: %v", err)
+	}
+
+	useFieldEncryption(t, true)
+	got, err := repo.FindByID(ctx, legacy.ID)
+	if err != nil {
+		t.Fatalf("FindByID legacy: %v", err)
+	}
+	if got.Request != `{"name":"legacy"}` || got.Response != "" {
+		t.Fatalf("legacy bodies = %q / %q", got.Request, got.Response)
+	}
+}
+
+func TestAuditLogRepository_WritesDisabledStillDecrypts(t *testing.T) {
+	kr := useFieldEncryption(t, true)
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+	repo := NewAuditLogRepository(db)
+	ctx := context.Background()
+
+	encrypted := newAuditLog("secret", "")
+	if err := repo.Create(ctx, encrypted); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// 关闭 encrypt_bodies 但保留密钥：新记录写明文，历史密文仍可读
+	database.SetFieldEncryption(kr, false)
+	plain := newAuditLog("visible", "")
+	if err := repo.Create(ctx, plain); err != nil {
+		t.Fatalf("Create plain: %v", err)
+	}
+	if rawReq, _ := rawBodies(t, db, plain.ID); rawReq != "visible" {
+		t.Fatalf("stored request = %q, want plaintext", rawReq)
+	}
+
+	got, err := repo.FindByID(ctx, encrypted.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if got.Request != "secret" {
+		t.Fatalf("Request = %q, want secret", got.Request)
+	}
+
+	// 完全移除密钥后密文无法读取，应报错而不是返回密文
+	database.SetFieldEncryption(nil, false)
+	if _, err := repo.FindByID(ctx, encrypted.ID); err == nil {
+		t.Fatal("FindByID without key succeeded, want error")
+	}
+}
+
+// 以 "enc:" 开头但不是密文的明文原样读取，不会因解密失败而报错
+func TestAuditLogRepository_ReadsPlaintextWithEncPrefix(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+	repo := NewAuditLogRepository(db)
+	ctx := context.Background()
+
+	plaintexts := []string{"enc:", "enc:v1:hello", "enc:1:v1:not base64!", "enc:1:v1:c2hvcnQ"}
+	ids := make([]uint, len(plaintexts))
+	for i, body := range plaintexts {
+		log := newAuditLog(body, "")
+		if err := repo.Create(ctx, log); err != nil {
+			t.Fatalf("Create %q: %v", body, err)
+		}
+		ids[i] = log.ID
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		for i, id := range ids {
+			got, err := repo.FindByID(ctx, id)
+			if err != nil {
+				t.Fatalf("%s: FindByID %q: %v", stage, plaintexts[i], err)
+			}
+			if got.Request != plaintexts[i] {
+				t.Fatalf("%s: Request = %q, want %q", stage, got.Request, plaintexts[i])
+			}
+		}
+	}
+	check("without key")
+	useFieldEncryption(t, true)
+	check("with key")
+}
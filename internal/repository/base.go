@@ -50,7 +50,7 @@ func WithCache[T any](baseRepo *database.Repository[T], config *CacheConfig, str
 
 	return &CachedRepository[T]{
 		Repository: baseRepo,
-		cache:      cache.NewCacheManager(),
+		cache:      cache.NewCacheManager("repo:" + config.KeyPrefix),
 		config:     config,
 		strategy:   strategy,
 	}
@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
 )
 
 // FileRepository 文件仓储接口
@@ -14,16 +16,34 @@ type FileRepository interface {
 	Update(ctx context.Context, file *model.File) error
 	Delete(ctx context.Context, id uint) error
 	FindByID(ctx context.Context, id uint) (*model.File, error)
+	FindByIDUnscoped(ctx context.Context, id uint) (*model.File, error)
+	FindByIDWithUploader(ctx context.Context, id uint) (*model.File, error)
+	ListByIDs(ctx context.Context, ids []uint) ([]model.File, error)
 
 	// 业务特定查询方法
 	FindByHash(ctx context.Context, hash string) (*model.File, error)
 	FindBySavedName(ctx context.Context, savedName string) (*model.File, error)
-	ListByUser(ctx context.Context, userID uint, pagination *database.Pagination) ([]model.File, error)
 	ListByCategory(ctx context.Context, category string, pagination *database.Pagination) ([]model.File, error)
-	ListByUserAndCategory(ctx context.Context, userID uint, category string, pagination *database.Pagination) ([]model.File, error)
+	ListFiltered(ctx context.Context, filter FileListFilter, pagination *database.Pagination) ([]model.File, error)
+	SumSize(ctx context.Context, filter FileListFilter) (int64, error)
+	UpdateTags(ctx context.Context, id uint, tags []string) error
+	ListImagesAfter(ctx context.Context, afterID uint, userID uint, category string, limit int) ([]model.File, error)
+	UpdateThumbnail(ctx context.Context, file *model.File) error
 	Search(ctx context.Context, keyword string, pagination *database.Pagination) ([]model.File, error)
 	CountByUser(ctx context.Context, userID uint) (int64, error)
+	CountByPath(ctx context.Context, path string) (int64, error)
 	GetUserStorageUsage(ctx context.Context, userID uint) (int64, error)
+	GetUserStorageUsageByCategory(ctx context.Context, userID uint) (map[string]int64, error)
+}
+
+// FileListFilter 文件列表筛选与排序条件
+// OrderBy 须由调用方按白名单校验后传入列名，为空时按 ID 倒序
+type FileListFilter struct {
+	UserID   uint   // 上传用户ID
+	Category string // 分类，为空不过滤
+	Tag      string // 标签，为空不过滤
+	OrderBy  string // 排序列
+	Desc     bool   // 是否倒序
 }
 
 // fileRepository 文件仓储实现
@@ -38,6 +58,51 @@ func NewFileRepository(db *database.Database) FileRepository {
 	}
 }
 
+// 删除语义：GORM 软删除（deleted_at）是文件是否已删除的唯一依据，
+// status 描述文件生命周期（正常/审核中），删除时同步置为 FileStatusDeleted 以兼容按状态过滤的查询。
+// 所有查找方法都同时排除 deleted_at 非空与 status = FileStatusDeleted 的记录，保证已删除文件一致不可见；
+// 只有显式的 Unscoped 方法（管理员查看）才能读到已删除文件。
+
+// FindByID 根据ID查找未删除的文件
+func (r *fileRepository) FindByID(ctx context.Context, id uint) (*model.File, error) {
+	return r.Repository.FindOne(ctx, "id = ? AND status <> ?", id, model.FileStatusDeleted)
+}
+
+// Delete 软删除文件，同时将状态置为已删除
+func (r *fileRepository) Delete(ctx context.Context, id uint) error {
+	return r.Repository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.File{}).Where("id = ?", id).Update("status", model.FileStatusDeleted).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.File{}, id).Error
+	})
+}
+
+// FindByIDWithUploader 根据ID查找文件并预加载上传者
+// 上传者已被删除时仍会加载（Unscoped），由调用方标记为已删除
+func (r *fileRepository) FindByIDWithUploader(ctx context.Context, id uint) (*model.File, error) {
+	var file model.File
+	err := r.Repository.DB().WithContext(ctx).
+		Preload("Uploader", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id", "username", "nickname", "avatar", "deleted_at")
+		}).
+		Where("status <> ?", model.FileStatusDeleted).
+		First(&file, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListByIDs 根据ID列表批量查询未删除的文件
+// 结果顺序不保证与 ids 一致，由调用方按需重排
+func (r *fileRepository) ListByIDs(ctx context.Context, ids []uint) ([]model.File, error) {
+	if len(ids) == 0 {
+		return []model.File{}, nil
+	}
+	return r.Repository.FindByCondition(ctx, "id IN ? AND status <> ?", ids, model.FileStatusDeleted)
+}
+
 // FindByHash 根据 Hash 查找文件（用于秒传功能）
 func (r *fileRepository) FindByHash(ctx context.Context, hash string) (*model.File, error) {
 	return r.Repository.FindOne(ctx, "hash = ? AND status = ?", hash, model.FileStatusNormal)
@@ -48,55 +113,107 @@ func (r *fileRepository) FindBySavedName(ctx context.Context, savedName string)
 	return r.Repository.FindOne(ctx, "saved_name = ? AND status = ?", savedName, model.FileStatusNormal)
 }
 
-// ListByUser 查询用户的文件列表
-func (r *fileRepository) ListByUser(ctx context.Context, userID uint, pagination *database.Pagination) ([]model.File, error) {
-	query := "uploaded_by = ? AND status = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, userID, model.FileStatusNormal)
-}
-
 // ListByCategory 根据分类查询文件列表
 func (r *fileRepository) ListByCategory(ctx context.Context, category string, pagination *database.Pagination) ([]model.File, error) {
 	query := "category = ? AND status = ?"
 	return r.Repository.FindWithPagination(ctx, pagination, query, category, model.FileStatusNormal)
 }
 
-// ListByUserAndCategory 查询用户特定分类的文件
-func (r *fileRepository) ListByUserAndCategory(ctx context.Context, userID uint, category string, pagination *database.Pagination) ([]model.File, error) {
-	query := "uploaded_by = ? AND category = ? AND status = ?"
-	return r.Repository.FindWithPagination(ctx, pagination, query, userID, category, model.FileStatusNormal)
-}
-
-// Search 搜索文件
-func (r *fileRepository) Search(ctx context.Context, keyword string, pagination *database.Pagination) ([]model.File, error) {
-	var files []model.File
-
-	db := r.Repository.DB().WithContext(ctx).Model(&model.File{})
-	db = db.Where("status = ?", model.FileStatusNormal)
-
-	if keyword != "" {
-		db = db.Where("original_name LIKE ? OR saved_name LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%")
+// ListFiltered 按筛选条件分页查询用户的正常文件
+func (r *fileRepository) ListFiltered(ctx context.Context, filter FileListFilter, pagination *database.Pagination) ([]model.File, error) {
+	db, err := r.filtered(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	// 统计总数
 	if err := db.Count(&pagination.Total).Error; err != nil {
 		return nil, err
 	}
 
-	// 分页查询
-	if pagination.PageSize > 0 {
-		offset := (pagination.Page - 1) * pagination.PageSize
-		db = db.Offset(offset).Limit(pagination.PageSize)
+	var files []model.File
+	if filter.OrderBy != "" {
+		db = db.Scopes(database.OrderBy(filter.OrderBy, filter.Desc))
 	}
+	// 以 ID 作为次级排序，保证相同大小/时间的文件分页稳定
+	err = db.Scopes(database.OrderBy("id", filter.Desc || filter.OrderBy == "")).
+		Scopes(database.Paginate(pagination)).
+		Find(&files).Error
+	return files, err
+}
 
-	// 默认按创建时间倒序
-	db = db.Order("created_at DESC")
+// SumSize 统计筛选条件下全部文件（不分页）的总大小
+func (r *fileRepository) SumSize(ctx context.Context, filter FileListFilter) (int64, error) {
+	db, err := r.filtered(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
 
-	if err := db.Find(&files).Error; err != nil {
-		return nil, err
+	var total int64
+	err = db.Select("COALESCE(SUM(size), 0)").Scan(&total).Error
+	return total, err
+}
+
+// filtered 构建文件列表的公共筛选条件
+// 标签使用 JSONB 包含查询（tags @> '["tag"]'），可命中 idx_files_tags GIN 索引
+func (r *fileRepository) filtered(ctx context.Context, filter FileListFilter) (*gorm.DB, error) {
+	db := r.Repository.DB().WithContext(ctx).Model(&model.File{}).
+		Where("uploaded_by = ? AND status = ?", filter.UserID, model.FileStatusNormal)
+	if filter.Category != "" {
+		db = db.Where("category = ?", filter.Category)
+	}
+	if filter.Tag != "" {
+		tagJSON, err := json.Marshal([]string{filter.Tag})
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("tags @> ?::jsonb", string(tagJSON))
+	}
+	return db, nil
+}
+
+// UpdateTags 更新文件标签
+func (r *fileRepository) UpdateTags(ctx context.Context, id uint, tags []string) error {
+	return r.Repository.DB().WithContext(ctx).
+		Model(&model.File{Model: database.Model{ID: id}}).
+		Select("tags").
+		Updates(&model.File{Tags: tags}).Error
+}
+
+// ListImagesAfter 按 ID 顺序分批获取图片文件（游标分页，用于批量任务）
+// userID 为 0 或 category 为空时不按该条件过滤
+func (r *fileRepository) ListImagesAfter(ctx context.Context, afterID uint, userID uint, category string, limit int) ([]model.File, error) {
+	var files []model.File
+	query := r.Repository.DB().WithContext(ctx).
+		Where("id > ? AND status = ? AND mime_type LIKE ?", afterID, model.FileStatusNormal, "image/%")
+	if userID > 0 {
+		query = query.Where("uploaded_by = ?", userID)
 	}
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// UpdateThumbnail 更新缩略图相关字段
+func (r *fileRepository) UpdateThumbnail(ctx context.Context, file *model.File) error {
+	return r.Repository.DB().WithContext(ctx).
+		Model(&model.File{Model: database.Model{ID: file.ID}}).
+		Select("thumbnail_path", "thumbnail_url", "thumbnail_spec", "width", "height").
+		Updates(file).Error
+}
 
-	return files, nil
+// fileSearchColumns 文件关键词搜索匹配的列
+var fileSearchColumns = []string{"original_name", "saved_name"}
+
+// Search 搜索文件
+// 只返回正常状态的文件，默认按创建时间倒序
+func (r *fileRepository) Search(ctx context.Context, keyword string, pagination *database.Pagination) ([]model.File, error) {
+	query := database.NewQueryBuilder().
+		Where(map[string]interface{}{"status": model.FileStatusNormal}).
+		Keyword(keyword, fileSearchColumns...).
+		Order("created_at DESC")
+	return r.Repository.FindQuery(ctx, query, pagination)
 }
 
 // CountByUser 统计用户文件数量
@@ -109,6 +226,16 @@ func (r *fileRepository) CountByUser(ctx context.Context, userID uint) (int64, e
 	return count, err
 }
 
+// CountByPath 统计引用同一物理文件的正常文件记录数（内容寻址存储判断能否删除对象）
+func (r *fileRepository) CountByPath(ctx context.Context, path string) (int64, error) {
+	var count int64
+	err := r.Repository.DB().WithContext(ctx).
+		Model(&model.File{}).
+		Where("path = ? AND status = ?", path, model.FileStatusNormal).
+		Count(&count).Error
+	return count, err
+}
+
 // GetUserStorageUsage 获取用户存储空间使用量（字节）
 func (r *fileRepository) GetUserStorageUsage(ctx context.Context, userID uint) (int64, error) {
 	var total int64
@@ -119,3 +246,26 @@ func (r *fileRepository) GetUserStorageUsage(ctx context.Context, userID uint) (
 		Scan(&total).Error
 	return total, err
 }
+
+// GetUserStorageUsageByCategory 按分类统计用户存储空间使用量（字节）
+func (r *fileRepository) GetUserStorageUsageByCategory(ctx context.Context, userID uint) (map[string]int64, error) {
+	var rows []struct {
+		Category string
+		Total    int64
+	}
+	err := r.Repository.DB().WithContext(ctx).
+		Model(&model.File{}).
+		Where("uploaded_by = ? AND status = ?", userID, model.FileStatusNormal).
+		Select("category, COALESCE(SUM(size), 0) AS total").
+		Group("category").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		usage[row.Category] = row.Total
+	}
+	return usage, nil
+}
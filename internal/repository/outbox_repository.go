@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
+)
+
+// OutboxRepository 发件箱事件仓储接口
+type OutboxRepository interface {
+	// Add 写入事件；tx 为业务事务时与业务数据一同提交或回滚，为 nil 时独立写入
+	Add(ctx context.Context, tx *gorm.DB, topic string, payload map[string]interface{}) error
+	ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	MarkDone(ctx context.Context, id uint) error
+	MarkAttemptFailed(ctx context.Context, id uint, errMsg string, giveUp bool) error
+}
+
+// outboxRepository 发件箱事件仓储实现
+type outboxRepository struct {
+	*database.Repository[model.OutboxEvent]
+}
+
+// NewOutboxRepository 创建发件箱事件仓储
+func NewOutboxRepository(db *database.Database) OutboxRepository {
+	return &outboxRepository{
+		Repository: database.NewRepository[model.OutboxEvent](db.DB),
+	}
+}
+
+// Add 写入待投递事件
+func (r *outboxRepository) Add(ctx context.Context, tx *gorm.DB, topic string, payload map[string]interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if tx == nil {
+		tx = r.Repository.DB()
+	}
+	return tx.WithContext(ctx).Create(&model.OutboxEvent{
+		Topic:   topic,
+		Payload: string(data),
+		Status:  model.OutboxStatusPending,
+	}).Error
+}
+
+// ListPending 按写入顺序获取待投递事件
+func (r *outboxRepository) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.Repository.DB().WithContext(ctx).
+		Where("status = ?", model.OutboxStatusPending).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// MarkDone 标记事件已投递
+func (r *outboxRepository) MarkDone(ctx context.Context, id uint) error {
+	return r.Repository.DB().WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       model.OutboxStatusDone,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"processed_at": time.Now(),
+		}).Error
+}
+
+// MarkAttemptFailed 记录一次投递失败；giveUp 为 true 时标记为最终失败，不再重试
+func (r *outboxRepository) MarkAttemptFailed(ctx context.Context, id uint, errMsg string, giveUp bool) error {
+	updates := map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": errMsg,
+	}
+	if giveUp {
+		updates["status"] = model.OutboxStatusFailed
+	}
+	return r.Repository.DB().WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
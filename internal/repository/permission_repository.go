@@ -28,6 +28,7 @@ type PermissionRepository interface {
 	ListByCategory(ctx context.Context, category, domain string) ([]model.Permission, error)                         // 按分类查询
 	Search(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Permission, error) // 关键词搜索
 	ListTree(ctx context.Context, domain string) ([]model.Permission, error)                                         // 树形结构查询
+	ListActive(ctx context.Context, domain string) ([]model.Permission, error)                                       // 启用的权限（平铺，domain 为空时查询全部域）
 	ExistsByName(ctx context.Context, name, domain string, excludeID uint) (bool, error)                             // 检查名称是否存在
 }
 
@@ -85,75 +86,78 @@ func (r *permissionRepository) ListByCategory(ctx context.Context, category, dom
 	return r.Repository.FindByCondition(ctx, "category = ? AND domain = ? AND status = ?", category, domain, 1)
 }
 
+// permissionSearchColumns 权限关键词搜索匹配的列
+var permissionSearchColumns = []string{"name", "display_name", "description"}
+
 // Search 根据关键词搜索权限
-// 支持按名称、显示名称、描述模糊查询
+// 支持按名称、显示名称、描述模糊查询（不区分大小写）
 func (r *permissionRepository) Search(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Permission, error) {
-	var permissions []model.Permission
-
-	db := r.Repository.DB().WithContext(ctx).Model(&model.Permission{})
-	if domain != "" {
-		db = db.Where("domain = ?", domain)
-	}
-	if keyword != "" {
-		db = db.Where("name LIKE ? OR display_name LIKE ? OR description LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
-	}
-
-	// 统计总数
-	if err := db.Count(&pagination.Total).Error; err != nil {
-		return nil, err
-	}
-
-	// 分页查询
-	err := db.Order("sort DESC, id DESC").
-		Scopes(database.Paginate(pagination)).
-		Find(&permissions).Error
-	return permissions, err
+	query := database.NewQueryBuilder().
+		WhereIfSet(map[string]interface{}{"domain": domain}).
+		Keyword(keyword, permissionSearchColumns...).
+		Order("sort DESC, id DESC")
+	return r.Repository.FindQuery(ctx, query, pagination)
 }
 
 // ListTree 查询树形权限结构
 // 根据parent_id构建父子关系的树形结构
 func (r *permissionRepository) ListTree(ctx context.Context, domain string) ([]model.Permission, error) {
-	var permissions []model.Permission
-
-	if domain == "" {
-		permissions, err := r.Repository.FindByCondition(ctx, "status = ?", 1)
-		if err != nil {
-			return nil, err
-		}
-		return buildPermissionTree(permissions), nil
-	}
-
-	permissions, err := r.Repository.FindByCondition(ctx, "domain = ? AND status = ?", domain, 1)
+	permissions, err := r.ListActive(ctx, domain)
 	if err != nil {
 		return nil, err
 	}
 	return buildPermissionTree(permissions), nil
 }
 
+// ListActive 查询启用的权限（平铺）
+func (r *permissionRepository) ListActive(ctx context.Context, domain string) ([]model.Permission, error) {
+	if domain == "" {
+		return r.Repository.FindByCondition(ctx, "status = ?", 1)
+	}
+	return r.Repository.FindByCondition(ctx, "domain = ? AND status = ?", domain, 1)
+}
+
 // buildPermissionTree 构建权限树形结构
-// 使用两次遍历算法：
-// 1. 第一次遍历建立ID到权限的映射
-// 2. 第二次遍历建立父子关系
+// 先按 parent_id 分组，再从根节点递归组装子树：
+// - parent_id 为 0、指向自身或指向不存在（已删除/禁用）的权限时作为根节点返回
+// - 环上的节点无法从根节点到达，同样作为根节点返回，已访问的节点不会重复展开，避免死循环
 func buildPermissionTree(permissions []model.Permission) []model.Permission {
-	// 创建ID到权限的映射
-	permMap := make(map[uint]*model.Permission)
-	var roots []model.Permission
-
-	// 第一遍遍历，建立映射
+	index := make(map[uint]int, len(permissions))
 	for i := range permissions {
-		permissions[i].Children = []model.Permission{}
-		permMap[permissions[i].ID] = &permissions[i]
+		index[permissions[i].ID] = i
 	}
 
-	// 第二遍遍历，构建树形结构
+	children := make(map[uint][]int)
+	var rootIdx []int
+	for i, p := range permissions {
+		if _, ok := index[p.ParentID]; p.ParentID == 0 || p.ParentID == p.ID || !ok {
+			rootIdx = append(rootIdx, i)
+			continue
+		}
+		children[p.ParentID] = append(children[p.ParentID], i)
+	}
+
+	visited := make([]bool, len(permissions))
+	var build func(i int) model.Permission
+	build = func(i int) model.Permission {
+		visited[i] = true
+		node := permissions[i]
+		node.Children = []model.Permission{}
+		for _, c := range children[node.ID] {
+			if !visited[c] {
+				node.Children = append(node.Children, build(c))
+			}
+		}
+		return node
+	}
+
+	var roots []model.Permission
+	for _, i := range rootIdx {
+		roots = append(roots, build(i))
+	}
 	for i := range permissions {
-		if permissions[i].ParentID == 0 {
-			// 根节点
-			roots = append(roots, permissions[i])
-		} else if parent, ok := permMap[permissions[i].ParentID]; ok {
-			// 添加到父节点
-			parent.Children = append(parent.Children, permissions[i])
+		if !visited[i] {
+			roots = append(roots, build(i))
 		}
 	}
 
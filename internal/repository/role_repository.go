@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // RoleRepository 角色仓储接口
@@ -20,12 +25,13 @@ type RoleRepository interface {
 	FindByID(ctx context.Context, id uint) (*model.Role, error)
 
 	// 业务查询方法
-	FindByName(ctx context.Context, name, domain string) (*model.Role, error)                                  // 按名称查询
-	List(ctx context.Context, domain string, pagination *database.Pagination) ([]model.Role, error)            // 分页查询
-	ListByIDs(ctx context.Context, ids []uint) ([]model.Role, error)                                           // 批量查询
-	Search(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Role, error) // 关键词搜索
-	ListByDomain(ctx context.Context, domain string) ([]model.Role, error)                                     // 按域查询所有启用角色
-	ExistsByName(ctx context.Context, name, domain string, excludeID uint) (bool, error)                       // 检查名称是否存在
+	FindByName(ctx context.Context, name, domain string) (*model.Role, error)                                                            // 按名称查询
+	List(ctx context.Context, domain string, pagination *database.Pagination, scopes ...database.Scope) ([]model.Role, error)            // 分页查询，scopes 为附加过滤条件
+	ListByIDs(ctx context.Context, ids []uint) ([]model.Role, error)                                                                     // 批量查询
+	Search(ctx context.Context, keyword, domain string, pagination *database.Pagination, scopes ...database.Scope) ([]model.Role, error) // 关键词搜索，scopes 为附加过滤条件
+	ListByDomain(ctx context.Context, domain string) ([]model.Role, error)                                                               // 按域查询所有启用角色
+	ExistsByName(ctx context.Context, name, domain string, excludeID uint) (bool, error)                                                 // 检查名称是否存在
+	GetOrCreate(ctx context.Context, role *model.Role) (*model.Role, bool, error)                                                        // 按 (name, domain) 获取或创建
 }
 
 // roleRepository 角色仓储实现
@@ -46,15 +52,48 @@ func (r *roleRepository) FindByName(ctx context.Context, name, domain string) (*
 	return r.Repository.FindOne(ctx, "name = ? AND domain = ?", name, domain)
 }
 
-// List 分页查询角色列表
-// 支持按域过滤，domain为空则查询所有域
-func (r *roleRepository) List(ctx context.Context, domain string, pagination *database.Pagination) ([]model.Role, error) {
-	query := "domain = ?"
-	if domain == "" {
-		query = "1=1"
-		return r.Repository.FindWithPagination(ctx, pagination, query)
+// GetOrCreate 按 (name, domain) 获取角色，不存在时创建，返回角色及是否由本次调用创建
+// 使用 INSERT ... ON CONFLICT (name, domain) DO NOTHING，并发调用时只有一个插入成功，
+// 其余调用读取已存在的角色，避免先查询再插入之间的竞争
+func (r *roleRepository) GetOrCreate(ctx context.Context, role *model.Role) (*model.Role, bool, error) {
+	result := r.Repository.Conn(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}, {Name: "domain"}},
+			DoNothing: true,
+		}).
+		Create(role)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return role, true, nil
+	}
+
+	existing, err := r.FindByName(ctx, role.Name, role.Domain)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 唯一索引包含已软删除的行
+			return nil, false, fmt.Errorf("role %s in domain %s exists but has been deleted", role.Name, role.Domain)
+		}
+		return nil, false, err
 	}
-	return r.Repository.FindWithPagination(ctx, pagination, query, domain)
+	return existing, false, nil
+}
+
+// RoleLevelBelow 只保留等级严格低于 level 的角色，供按操作者等级过滤的分页查询使用
+func RoleLevelBelow(level int) database.Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("roles.level < ?", level)
+	}
+}
+
+// List 分页查询角色列表
+// 支持按域过滤，domain为空则查询所有域；scopes 在计数与分页前应用
+func (r *roleRepository) List(ctx context.Context, domain string, pagination *database.Pagination, scopes ...database.Scope) ([]model.Role, error) {
+	query := database.NewQueryBuilder().
+		WhereIfSet(map[string]interface{}{"domain": domain}).
+		Scopes(scopes...)
+	return r.Repository.FindQuery(ctx, query, pagination)
 }
 
 // ListByIDs 根据ID列表批量查询角色
@@ -66,30 +105,18 @@ func (r *roleRepository) ListByIDs(ctx context.Context, ids []uint) ([]model.Rol
 	return r.Repository.FindByCondition(ctx, "id IN ?", ids)
 }
 
-// Search 根据关键词搜索角色
-// 支持按名称、显示名称、描述模糊查询
-func (r *roleRepository) Search(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Role, error) {
-	var roles []model.Role
-
-	db := r.Repository.DB().WithContext(ctx).Model(&model.Role{})
-	if domain != "" {
-		db = db.Where("domain = ?", domain)
-	}
-	if keyword != "" {
-		db = db.Where("name LIKE ? OR display_name LIKE ? OR description LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
-	}
+// roleSearchColumns 角色关键词搜索匹配的列
+var roleSearchColumns = []string{"name", "display_name", "description"}
 
-	// 统计总数
-	if err := db.Count(&pagination.Total).Error; err != nil {
-		return nil, err
-	}
-
-	// 分页查询
-	err := db.Order("sort DESC, id DESC").
-		Scopes(database.Paginate(pagination)).
-		Find(&roles).Error
-	return roles, err
+// Search 根据关键词搜索角色
+// 支持按名称、显示名称、描述模糊查询（不区分大小写）；scopes 在计数与分页前应用
+func (r *roleRepository) Search(ctx context.Context, keyword, domain string, pagination *database.Pagination, scopes ...database.Scope) ([]model.Role, error) {
+	query := database.NewQueryBuilder().
+		WhereIfSet(map[string]interface{}{"domain": domain}).
+		Keyword(keyword, roleSearchColumns...).
+		Scopes(scopes...).
+		Order("sort DESC, id DESC")
+	return r.Repository.FindQuery(ctx, query, pagination)
 }
 
 // ListByDomain 根据域查询所有启用的角色（status=1）
@@ -119,13 +146,22 @@ func (r *roleRepository) ExistsByName(ctx context.Context, name, domain string,
 // UserRoleRepository 用户角色关联仓储接口
 // 管理用户和角色之间的多对多关系
 type UserRoleRepository interface {
-	Assign(ctx context.Context, userRole *model.UserRole) error                           // 分配单个角色
-	Revoke(ctx context.Context, userID, roleID uint, domain string) error                 // 撤销单个角色
-	RevokeAll(ctx context.Context, userID uint, domain string) error                      // 撤销用户在某域的所有角色
-	FindByUser(ctx context.Context, userID uint, domain string) ([]model.UserRole, error) // 查询用户的角色列表
-	FindByRole(ctx context.Context, roleID uint) ([]model.UserRole, error)                // 查询拥有某角色的用户列表
-	HasRole(ctx context.Context, userID, roleID uint, domain string) (bool, error)        // 检查用户是否拥有角色
-	BatchAssign(ctx context.Context, userRoles []model.UserRole) error                    // 批量分配角色
+	Assign(ctx context.Context, userRole *model.UserRole) error                               // 分配单个角色
+	Revoke(ctx context.Context, userID, roleID uint, domain string) error                     // 撤销单个角色
+	RevokeAll(ctx context.Context, userID uint, domain string) error                          // 撤销用户在某域的所有角色
+	FindByUser(ctx context.Context, userID uint, domain string) ([]model.UserRole, error)     // 查询用户的角色列表
+	FindByUsers(ctx context.Context, userIDs []uint, domain string) ([]model.UserRole, error) // 批量查询多个用户的角色列表
+	FindByRole(ctx context.Context, roleID uint) ([]model.UserRole, error)                    // 查询拥有某角色的用户列表
+	HasRole(ctx context.Context, userID, roleID uint, domain string) (bool, error)            // 检查用户是否拥有角色
+	BatchAssign(ctx context.Context, userRoles []model.UserRole) error                        // 批量分配角色
+	DeleteExpired(ctx context.Context, now time.Time) ([]model.UserRole, error)               // 删除已过期的分配，返回被删除的记录
+}
+
+// ActiveUserRoles 只保留未过期的用户角色分配（expires_at 为空或晚于 now）
+func ActiveUserRoles(now time.Time) database.Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(user_roles.expires_at IS NULL OR user_roles.expires_at > ?)", now)
+	}
 }
 
 // userRoleRepository 用户角色关联仓储实现
@@ -140,29 +176,30 @@ func NewUserRoleRepository(db *database.Database) UserRoleRepository {
 
 // Assign 分配角色给用户
 func (r *userRoleRepository) Assign(ctx context.Context, userRole *model.UserRole) error {
-	return r.db.DB.WithContext(ctx).Create(userRole).Error
+	return r.db.Conn(ctx).Create(userRole).Error
 }
 
 // Revoke 撤销用户的角色
 func (r *userRoleRepository) Revoke(ctx context.Context, userID, roleID uint, domain string) error {
-	return r.db.DB.WithContext(ctx).
+	return r.db.Conn(ctx).
 		Where("user_id = ? AND role_id = ? AND domain = ?", userID, roleID, domain).
 		Delete(&model.UserRole{}).Error
 }
 
 // RevokeAll 撤销用户在某个域的所有角色
 func (r *userRoleRepository) RevokeAll(ctx context.Context, userID uint, domain string) error {
-	return r.db.DB.WithContext(ctx).
+	return r.db.Conn(ctx).
 		Where("user_id = ? AND domain = ?", userID, domain).
 		Delete(&model.UserRole{}).Error
 }
 
-// FindByUser 查询用户的所有角色
+// FindByUser 查询用户的所有有效角色（已过期的分配不返回）
 // 使用Preload预加载角色详情，避免N+1查询
 func (r *userRoleRepository) FindByUser(ctx context.Context, userID uint, domain string) ([]model.UserRole, error) {
 	var userRoles []model.UserRole
-	query := r.db.DB.WithContext(ctx).
+	query := r.db.Conn(ctx).
 		Preload("Role").
+		Scopes(ActiveUserRoles(time.Now())).
 		Where("user_id = ?", userID)
 
 	if domain != "" {
@@ -173,21 +210,43 @@ func (r *userRoleRepository) FindByUser(ctx context.Context, userID uint, domain
 	return userRoles, err
 }
 
+// FindByUsers 批量查询多个用户的有效角色（已过期的分配不返回）
+// 一次查询关联表并预加载角色详情，用于列表页批量展示，避免逐个用户查询
+func (r *userRoleRepository) FindByUsers(ctx context.Context, userIDs []uint, domain string) ([]model.UserRole, error) {
+	if len(userIDs) == 0 {
+		return []model.UserRole{}, nil
+	}
+
+	var userRoles []model.UserRole
+	query := r.db.Conn(ctx).
+		Preload("Role").
+		Scopes(ActiveUserRoles(time.Now())).
+		Where("user_id IN ?", userIDs)
+
+	if domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	err := query.Order("user_id ASC, role_id ASC").Find(&userRoles).Error
+	return userRoles, err
+}
+
 // FindByRole 查询拥有某个角色的所有用户
 // 使用Preload预加载用户详情，避免N+1查询
 func (r *userRoleRepository) FindByRole(ctx context.Context, roleID uint) ([]model.UserRole, error) {
 	var userRoles []model.UserRole
-	err := r.db.DB.WithContext(ctx).
+	err := r.db.Conn(ctx).
 		Preload("User").
 		Where("role_id = ?", roleID).
 		Find(&userRoles).Error
 	return userRoles, err
 }
 
-// HasRole 检查用户是否拥有某个角色
+// HasRole 检查用户是否拥有某个未过期的角色
 func (r *userRoleRepository) HasRole(ctx context.Context, userID, roleID uint, domain string) (bool, error) {
 	var count int64
-	err := r.db.DB.WithContext(ctx).Model(&model.UserRole{}).
+	err := r.db.Conn(ctx).Model(&model.UserRole{}).
+		Scopes(ActiveUserRoles(time.Now())).
 		Where("user_id = ? AND role_id = ? AND domain = ?", userID, roleID, domain).
 		Count(&count).Error
 	return count > 0, err
@@ -199,5 +258,27 @@ func (r *userRoleRepository) BatchAssign(ctx context.Context, userRoles []model.
 	if len(userRoles) == 0 {
 		return nil
 	}
-	return r.db.DB.WithContext(ctx).Create(&userRoles).Error
+	return r.db.Conn(ctx).Create(&userRoles).Error
+}
+
+// DeleteExpired 删除 expires_at 不晚于 now 的分配，返回被删除的记录（用于同步 Casbin 与清理缓存）
+func (r *userRoleRepository) DeleteExpired(ctx context.Context, now time.Time) ([]model.UserRole, error) {
+	var expired []model.UserRole
+	if err := r.db.Conn(ctx).
+		Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Find(&expired).Error; err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(expired))
+	for i, ur := range expired {
+		ids[i] = ur.ID
+	}
+	if err := r.db.Conn(ctx).Delete(&model.UserRole{}, ids).Error; err != nil {
+		return nil, err
+	}
+	return expired, nil
 }
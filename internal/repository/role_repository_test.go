@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/testutil"
+)
+
+func TestRoleRepository_GetOrCreate_Concurrent(t *testing.T) {
+	repo := NewRoleRepository(testutil.NewDatabase(t, &model.Role{}))
+	ctx := context.Background()
+
+	const workers = 8
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		created = make([]bool, workers)
+		ids     = make([]uint, workers)
+		errs    = make([]error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			role, ok, err := repo.GetOrCreate(ctx, &model.Role{
+				Name:        "editor",
+				DisplayName: "编辑",
+				Domain:      "default",
+			})
+			errs[i], created[i] = err, ok
+			if role != nil {
+				ids[i] = role.ID
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	createdCount := 0
+	for i := 0; i < workers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("worker %d: %v", i, errs[i])
+		}
+		if created[i] {
+			createdCount++
+		}
+		if ids[i] == 0 || ids[i] != ids[0] {
+			t.Fatalf("worker %d got role id %d, want %d", i, ids[i], ids[0])
+		}
+	}
+	if createdCount != 1 {
+		t.Fatalf("created reported %d times, want exactly 1", createdCount)
+	}
+
+	roles, err := repo.ListByDomain(ctx, "default")
+	if err != nil {
+		t.Fatalf("list roles: %v", err)
+	}
+	if len(roles) != 1 {
+		t.Fatalf("got %d roles, want 1", len(roles))
+	}
+}
+
+func TestRoleRepository_GetOrCreate_ExistingNotOverwritten(t *testing.T) {
+	repo := NewRoleRepository(testutil.NewDatabase(t, &model.Role{}))
+	ctx := context.Background()
+
+	first, created, err := repo.GetOrCreate(ctx, &model.Role{Name: "viewer", DisplayName: "访客", Domain: "default"})
+	if err != nil || !created {
+		t.Fatalf("first GetOrCreate: created=%v err=%v", created, err)
+	}
+
+	second, created, err := repo.GetOrCreate(ctx, &model.Role{Name: "viewer", DisplayName: "changed", Domain: "default"})
+	if err != nil {
+		t.Fatalf("second GetOrCreate: %v", err)
+	}
+	if created {
+		t.Fatal("second GetOrCreate reported created")
+	}
+	if second.ID != first.ID || second.DisplayName != "访客" {
+		t.Fatalf("got %+v, want existing role %d unchanged", second, first.ID)
+	}
+}
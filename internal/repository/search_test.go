@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/database"
+)
+
+// 角色、权限、文件搜索统一使用 database.SearchKeyword，大小写不敏感且通配符按字面匹配
+
+func TestRoleRepository_Search_CaseInsensitive(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.Role{})
+	repo := NewRoleRepository(db)
+	ctx := context.Background()
+
+	roles := []model.Role{
+		{Name: "Admin", DisplayName: "系统管理员", Domain: "default"},
+		{Name: "editor", DisplayName: "Content EDITOR", Domain: "default"},
+		{Name: "viewer", DisplayName: "只读", Description: "100% read only", Domain: "default"},
+		{Name: "admin", DisplayName: "租户管理员", Domain: "tenant"},
+	}
+	if err := db.DB.Create(&roles).Error; err != nil {
+		t.Fatalf("create roles: %v", err)
+	}
+
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"ADMIN", []string{"Admin"}},
+		{"aDmIn", []string{"Admin"}},
+		{"editor", []string{"editor"}},
+		{"100%", []string{"viewer"}},
+		{"%", []string{"viewer"}}, // 字面匹配，而非匹配全部
+		{"_", nil},
+	}
+	for _, tt := range tests {
+		got, err := repo.Search(ctx, tt.keyword, "default", &database.Pagination{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", tt.keyword, err)
+		}
+		names := make([]string, len(got))
+		for i, r := range got {
+			names[i] = r.Name
+		}
+		assertNames(t, tt.keyword, names, tt.want)
+	}
+}
+
+func TestPermissionRepository_Search_CaseInsensitive(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.Permission{})
+	repo := NewPermissionRepository(db)
+	ctx := context.Background()
+
+	permissions := []model.Permission{
+		{Name: "user:read", DisplayName: "查看用户", Domain: "default", Resource: "user", Action: "read"},
+		{Name: "User:Write", DisplayName: "编辑用户", Domain: "default", Resource: "user", Action: "write"},
+		{Name: "file:read", DisplayName: "Read Files", Domain: "default", Resource: "file", Action: "read"},
+	}
+	if err := db.DB.Create(&permissions).Error; err != nil {
+		t.Fatalf("create permissions: %v", err)
+	}
+
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"USER", []string{"User:Write", "user:read"}},
+		{"read files", []string{"file:read"}},
+		{"user_", nil},
+	}
+	for _, tt := range tests {
+		got, err := repo.Search(ctx, tt.keyword, "default", &database.Pagination{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", tt.keyword, err)
+		}
+		names := make([]string, len(got))
+		for i, p := range got {
+			names[i] = p.Name
+		}
+		assertNames(t, tt.keyword, names, tt.want)
+	}
+}
+
+func TestFileRepository_Search_CaseInsensitive(t *testing.T) {
+	// model.File 的标签列使用 GIN 索引，SQLite 不支持，这里只建搜索涉及的列
+	db := testutil.NewDatabase(t, &searchFile{})
+	repo := NewFileRepository(db)
+	ctx := context.Background()
+
+	files := []searchFile{
+		{OriginalName: "Report-Q1.PDF", SavedName: "a.pdf", Status: model.FileStatusNormal},
+		{OriginalName: "notes.txt", SavedName: "b.txt", Status: model.FileStatusNormal},
+		{OriginalName: "report-old.pdf", SavedName: "c.pdf", Status: model.FileStatusDeleted},
+	}
+	if err := db.DB.Create(&files).Error; err != nil {
+		t.Fatalf("create files: %v", err)
+	}
+
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"report", []string{"Report-Q1.PDF"}},
+		{"REPORT-q1.pdf", []string{"Report-Q1.PDF"}},
+		{"NOTES", []string{"notes.txt"}},
+	}
+	for _, tt := range tests {
+		got, err := repo.Search(ctx, tt.keyword, &database.Pagination{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", tt.keyword, err)
+		}
+		names := make([]string, len(got))
+		for i, f := range got {
+			names[i] = f.OriginalName
+		}
+		assertNames(t, tt.keyword, names, tt.want)
+	}
+}
+
+// 空域条件被忽略（搜索所有域），总数按过滤后的结果统计，分页与排序在计数之后应用
+func TestRoleRepository_Search_TotalAndPaging(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.Role{})
+	repo := NewRoleRepository(db)
+	ctx := context.Background()
+
+	roles := []model.Role{
+		{Name: "admin", Domain: "default", Sort: 3},
+		{Name: "admin-lite", Domain: "tenant", Sort: 2},
+		{Name: "auditor", Domain: "default", Sort: 1},
+		{Name: "super-admin", Domain: "tenant", Sort: 1},
+	}
+	if err := db.DB.Create(&roles).Error; err != nil {
+		t.Fatalf("create roles: %v", err)
+	}
+
+	pagination := &database.Pagination{Page: 2, PageSize: 2}
+	got, err := repo.Search(ctx, "admin", "", pagination)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if pagination.Total != 3 {
+		t.Fatalf("Total = %d, want 3", pagination.Total)
+	}
+	if len(got) != 1 || got[0].Name != "super-admin" {
+		t.Fatalf("page 2 = %+v, want [super-admin]", got)
+	}
+}
+
+// Where 的零值条件照常过滤，WhereIfSet 的零值视为未设置
+func TestQueryBuilder_WhereZeroValue(t *testing.T) {
+	db := testutil.NewDatabase(t, &searchFile{})
+	repo := database.NewRepository[searchFile](db.DB)
+	ctx := context.Background()
+
+	files := []searchFile{
+		{OriginalName: "pending.txt", Status: 0},
+		{OriginalName: "normal.txt", Status: model.FileStatusNormal},
+	}
+	if err := db.DB.Create(&files).Error; err != nil {
+		t.Fatalf("create files: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query *database.QueryBuilder
+		want  []string
+	}{
+		{"where zero", database.NewQueryBuilder().Where(map[string]interface{}{"status": 0}), []string{"pending.txt"}},
+		{"where if set zero", database.NewQueryBuilder().WhereIfSet(map[string]interface{}{"status": 0}), []string{"normal.txt", "pending.txt"}},
+		{"where if set value", database.NewQueryBuilder().WhereIfSet(map[string]interface{}{"status": model.FileStatusNormal}), []string{"normal.txt"}},
+	}
+	for _, tt := range tests {
+		pagination := &database.Pagination{Page: 1, PageSize: 10}
+		got, err := repo.FindQuery(ctx, tt.query, pagination)
+		if err != nil {
+			t.Fatalf("%s: FindQuery: %v", tt.name, err)
+		}
+		if pagination.Total != int64(len(tt.want)) {
+			t.Fatalf("%s: Total = %d, want %d", tt.name, pagination.Total, len(tt.want))
+		}
+		names := make([]string, len(got))
+		for i, f := range got {
+			names[i] = f.OriginalName
+		}
+		assertNames(t, tt.name, names, tt.want)
+	}
+}
+
+// searchFile files 表中与搜索相关的列
+type searchFile struct {
+	database.Model
+	OriginalName string
+	SavedName    string
+	Status       int
+}
+
+func (searchFile) TableName() string {
+	return "files"
+}
+
+// assertNames 忽略顺序比较搜索结果
+func assertNames(t *testing.T, keyword string, got, want []string) {
+	t.Helper()
+
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("Search(%q) = %v, want %v", keyword, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Search(%q) = %v, want %v", keyword, got, want)
+		}
+	}
+}
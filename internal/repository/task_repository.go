@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm/clause"
 )
 
 // TaskRepository 任务仓储接口
@@ -24,6 +26,8 @@ type TaskRepository interface {
 	CountByStatus(ctx context.Context, status string) (int64, error)
 	CountByUser(ctx context.Context, userID uint) (int64, error)
 	UpdateStatus(ctx context.Context, taskID string, status string, err string) error
+	UpdateProgress(ctx context.Context, taskID string, progress int, result string) error
+	UpdateStatusBulkBefore(ctx context.Context, fromStatus, toStatus, taskType string, before time.Time) ([]model.Task, error)
 }
 
 // taskRepository 任务仓储实现
@@ -100,3 +104,37 @@ func (r *taskRepository) UpdateStatus(ctx context.Context, taskID string, status
 		Where("task_id = ?", taskID).
 		Updates(updates).Error
 }
+
+// UpdateProgress 更新任务进度与结果摘要
+func (r *taskRepository) UpdateProgress(ctx context.Context, taskID string, progress int, result string) error {
+	return r.Repository.DB().WithContext(ctx).
+		Model(&model.Task{}).
+		Where("task_id = ?", taskID).
+		Updates(map[string]interface{}{
+			"progress": progress,
+			"result":   result,
+		}).Error
+}
+
+// UpdateStatusBulkBefore 批量更新最后更新时间早于 before 的任务状态，返回被更新的任务
+// 用于重置长时间卡在 processing 的任务，taskType 为空时不按类型过滤，before 为零值时不按时间过滤；
+// 使用 UPDATE ... RETURNING 在同一条语句中取回被更新的行，调用方可据此重新投递任务
+func (r *taskRepository) UpdateStatusBulkBefore(ctx context.Context, fromStatus, toStatus, taskType string, before time.Time) ([]model.Task, error) {
+	var tasks []model.Task
+	db := r.Repository.Conn(ctx).
+		Model(&tasks).
+		Clauses(clause.Returning{}).
+		Where("status = ?", fromStatus)
+
+	if taskType != "" {
+		db = db.Where("type = ?", taskType)
+	}
+	if !before.IsZero() {
+		db = db.Where("updated_at < ?", before)
+	}
+
+	if err := db.Update("status", toStatus).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/testutil"
+)
+
+func TestTaskRepository_UpdateStatusBulkBefore_ReturnsUpdatedTasks(t *testing.T) {
+	repo := NewTaskRepository(testutil.NewDatabase(t, &model.Task{}))
+	ctx := context.Background()
+
+	for _, task := range []model.Task{
+		{TaskID: "t1", Name: "user_import", Type: model.TaskTypeAsync, Status: model.TaskStatusFailed, Payload: `{"a":1}`},
+		{TaskID: "t2", Name: "user_import", Type: model.TaskTypeAsync, Status: model.TaskStatusFailed},
+		{TaskID: "t3", Name: "cleanup", Type: model.TaskTypeScheduled, Status: model.TaskStatusFailed},
+		{TaskID: "t4", Name: "user_import", Type: model.TaskTypeAsync, Status: model.TaskStatusSuccess},
+	} {
+		if err := repo.Create(ctx, &task); err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+	}
+
+	tasks, err := repo.UpdateStatusBulkBefore(ctx, model.TaskStatusFailed, model.TaskStatusPending, model.TaskTypeAsync, time.Time{})
+	if err != nil {
+		t.Fatalf("UpdateStatusBulkBefore: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d updated tasks, want 2", len(tasks))
+	}
+	for _, task := range tasks {
+		if task.TaskID != "t1" && task.TaskID != "t2" {
+			t.Fatalf("unexpected task %s updated", task.TaskID)
+		}
+		if task.Name != "user_import" || task.Status != model.TaskStatusPending {
+			t.Fatalf("returned task not fully populated: %+v", task)
+		}
+	}
+
+	pending, err := repo.CountByStatus(ctx, model.TaskStatusPending)
+	if err != nil {
+		t.Fatalf("CountByStatus: %v", err)
+	}
+	if pending != 2 {
+		t.Fatalf("pending = %d, want 2", pending)
+	}
+}
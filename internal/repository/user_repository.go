@@ -74,6 +74,17 @@ func (r *UserRepository) FindWithPagination(ctx context.Context, pagination *dat
 	return r.repo.FindWithPagination(ctx, pagination, query, args...)
 }
 
+// InvalidateCache 失效指定用户的缓存（未启用缓存时为空操作）
+// 在事务中直接写库后调用，避免读到旧数据
+func (r *UserRepository) InvalidateCache(ctx context.Context, id uint) error {
+	if cached, ok := r.repo.(interface {
+		InvalidateCacheByID(ctx context.Context, id uint) error
+	}); ok {
+		return cached.InvalidateCacheByID(ctx, id)
+	}
+	return nil
+}
+
 // === 业务专属方法 ===
 
 func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
@@ -99,3 +110,24 @@ func (r *UserRepository) FindActiveUsers(ctx context.Context, pagination *databa
 func (r *UserRepository) UpdateStatus(ctx context.Context, id uint, status int) error {
 	return r.repo.UpdateFields(ctx, id, map[string]interface{}{"status": status})
 }
+
+// Search 按用户名、昵称（includeEmail 为 true 时含邮箱）不区分大小写模糊搜索（已软删除的用户自动排除）
+// 三列均有 pg_trgm 表达式索引（见迁移 0005），支持 %keyword% 形式的匹配
+func (r *UserRepository) Search(ctx context.Context, keyword string, includeEmail bool, pagination *database.Pagination) ([]model.User, error) {
+	columns := []string{"username", "nickname"}
+	if includeEmail {
+		columns = append(columns, "email")
+	}
+	db := r.db.WithContext(ctx).Model(&model.User{}).
+		Scopes(database.SearchKeyword(keyword, columns...))
+
+	if err := db.Count(&pagination.Total).Error; err != nil {
+		return nil, err
+	}
+
+	var users []model.User
+	if err := db.Scopes(database.Paginate(pagination)).Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/database"
+)
+
+func TestUserRepository_Search_EmailOnlyWithPII(t *testing.T) {
+	db := testutil.NewDatabase(t, &model.User{})
+	repo := NewUserRepository(db.DB, false)
+	ctx := context.Background()
+
+	users := []model.User{
+		{Username: "alice", Email: "secret.alice@corp.example", Nickname: "Alice", Password: "x"},
+		{Username: "bob", Email: "bob@mail.example", Nickname: "Corporal", Password: "x"},
+	}
+	for i := range users {
+		if err := db.DB.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		keyword      string
+		includeEmail bool
+		want         []string
+	}{
+		{"email match with pii", "secret", true, []string{"alice"}},
+		{"email match without pii", "secret", false, nil},
+		{"nickname match without pii", "corp", false, []string{"bob"}},
+		{"email and nickname with pii", "corp", true, []string{"alice", "bob"}},
+		{"case insensitive username", "ALI", false, []string{"alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := &database.Pagination{Page: 1, PageSize: 10}
+			got, err := repo.Search(ctx, tt.keyword, tt.includeEmail, pagination)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			names := make([]string, len(got))
+			for i, u := range got {
+				names[i] = u.Username
+			}
+			if len(names) != len(tt.want) || int(pagination.Total) != len(tt.want) {
+				t.Fatalf("Search(%q, %v) = %v (total %d), want %v", tt.keyword, tt.includeEmail, names, pagination.Total, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("Search(%q, %v) = %v, want %v", tt.keyword, tt.includeEmail, names, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,65 +1,196 @@
 package router
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/cccvno1/nova/internal/handler"
+	"github.com/cccvno1/nova/internal/migration"
+	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/internal/service"
 	"github.com/cccvno1/nova/pkg/auth"
+	"github.com/cccvno1/nova/pkg/captcha"
 	"github.com/cccvno1/nova/pkg/casbin"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/mailer"
 	"github.com/cccvno1/nova/pkg/middleware"
+	"github.com/cccvno1/nova/pkg/queue"
 	"github.com/cccvno1/nova/pkg/storage"
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
-func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *auth.TokenBlacklist, enforcer *casbin.Enforcer) {
+// Setup 注册中间件与路由
+// rbacService 由调用方创建，与定时任务（如过期角色清理）共用同一实例
+// queueWorker 为 nil（未启用队列）时不注册任务处理器，依赖队列的功能不可用
+func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *auth.TokenBlacklist, enforcer *casbin.Enforcer, rbacService service.RBACService, queueWorker *queue.Worker) {
+	// 安全响应头
+	e.Use(middleware.SecureHeaders(&cfg.Server.SecurityHeaders))
+
+	// 维护模式：在并发限制与限流之前拒绝，健康检查与维护模式管理接口始终放行
+	maintenance := middleware.NewMaintenance(middleware.MaintenanceConfig{
+		Enabled:       cfg.Maintenance.Enabled,
+		Message:       cfg.Maintenance.Message,
+		RetryAfter:    cfg.Maintenance.RetryAfter,
+		AllowIPs:      cfg.Maintenance.AllowIPs,
+		AllowPaths:    cfg.Maintenance.AllowPaths,
+		CheckInterval: time.Duration(cfg.Maintenance.CheckInterval) * time.Millisecond,
+		Logger:        logger.Logger(),
+	})
+	e.Use(maintenance.Middleware("/api/v1/health", "/api/v1/ready", "/api/v1/admin/maintenance"))
+
+	// 全局并发限制（位于按 IP/用户限流之外），健康检查不占用槽位，负载高时探针仍可响应
+	concurrency := middleware.NewConcurrencyLimiter(
+		cfg.Server.MaxConcurrentRequests,
+		time.Duration(cfg.Server.ConcurrencyQueueMs)*time.Millisecond,
+		cfg.Server.ConcurrencyRetryAfter,
+	)
+	e.Use(concurrency.Middleware("/api/v1/health", "/api/v1/ready"))
+
 	// Swagger UI 路由
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	healthHandler := handler.NewHealthHandler()
+	// 健康检查：启用队列时同时检查队列积压
+	var queueDepth *queue.DepthMonitor
+	if cfg.Queue.Enabled {
+		queueDepth = queue.NewDepthMonitor(queue.NewClient(cfg.Queue.RedisPrefix), cfg.Queue.AlertThreshold, time.Duration(cfg.Queue.AlertDuration)*time.Second)
+	}
+	healthHandler := handler.NewHealthHandler(queueDepth, concurrency, database.NewMigrator(database.GetDB(), migration.All()), logger.Logger())
+	metaHandler := handler.NewMetaHandler(cfg)
 
 	db := database.GetDB()
 
+	// 文件存储
+	fileStorage, err := storage.NewLocalStorage(cfg.Upload.LocalPath, cfg.Upload.LocalURL)
+	if err != nil {
+		panic("failed to initialize file storage: " + err.Error())
+	}
+
 	// 用户服务和处理器
-	userService := service.NewUserService(db, jwtAuth)
-	userHandler := handler.NewUserHandler(userService)
-	authHandler := handler.NewAuthHandler(userService, blacklist)
+	var avatarService service.AvatarService
+	if cfg.Upload.GenerateDefaultAvatar {
+		avatarService = service.NewAvatarService(fileStorage, &cfg.Upload)
+	}
+	sessionManager := auth.NewSessionManager(jwtAuth, blacklist)
+	avatarURLs := service.NewAvatarURLResolver(fileStorage)
+	userService := service.NewUserService(db, jwtAuth, avatarService, avatarURLs, sessionManager, cfg.RBAC.CascadeUserDelete, cfg.RBAC.DefaultRoles, &cfg.User)
+	if err := userService.ValidateDefaultRoles(context.Background()); err != nil {
+		panic("invalid rbac.default_roles: " + err.Error())
+	}
+	var captchaGuard *captcha.Guard
+	if cfg.Captcha.Enabled {
+		verifier, err := captcha.NewVerifier(&cfg.Captcha)
+		if err != nil {
+			panic("failed to initialize captcha: " + err.Error())
+		}
+		captchaGuard = captcha.NewGuard(&cfg.Captcha, verifier)
+	}
 
-	// RBAC 服务和处理器
-	roleRepo := repository.NewRoleRepository(database.DB())
-	permRepo := repository.NewPermissionRepository(database.DB())
-	userRoleRepo := repository.NewUserRoleRepository(database.DB())
-	// 方案A：传入database.DB()实例用于直接操作RBAC表
-	rbacService := service.NewRBACService(enforcer, roleRepo, permRepo, userRoleRepo, database.DB(), logger.Logger())
+	// 启动时核对 Casbin 策略与权限表，差异汇总由服务层记录日志
+	if mode := cfg.Casbin.ReconcileOnStart; mode == "log" || mode == "repair" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if _, err := rbacService.ReconcilePolicies(ctx, mode == "repair"); err != nil {
+			logger.Error("failed to reconcile casbin policies", "error", err)
+		}
+		cancel()
+	}
 
 	roleHandler := handler.NewRoleHandler(rbacService)
-	permissionHandler := handler.NewPermissionHandler(rbacService)
+	permissionHandler := handler.NewPermissionHandler(rbacService, &cfg.Permission)
 	userRoleHandler := handler.NewUserRoleHandler(rbacService)
 
-	// 文件上传服务和处理器
-	fileStorage, err := storage.NewLocalStorage(cfg.Upload.LocalPath, cfg.Upload.LocalURL)
-	if err != nil {
-		panic("failed to initialize file storage: " + err.Error())
+	var queueClient *queue.Client
+	if cfg.Queue.Enabled {
+		queueClient = queue.NewClient(cfg.Queue.RedisPrefix)
+	}
+	// 免密登录链接依赖队列发送邮件
+	magicLinkEnabled := cfg.Auth.MagicLinkEnabled && queueClient != nil
+	var magicLinks *auth.MagicLinkStore
+	if magicLinkEnabled {
+		magicLinks = auth.NewMagicLinkStore(
+			time.Duration(cfg.Auth.MagicLinkTTL)*time.Second,
+			cfg.Auth.MagicLinkRateLimit,
+			time.Duration(cfg.Auth.MagicLinkRateWindow)*time.Second,
+		)
 	}
+	var loginThrottle *auth.LoginThrottle
+	if cfg.Auth.LoginThrottleEnabled {
+		loginThrottle = auth.NewLoginThrottle(
+			time.Duration(cfg.Auth.LoginThrottleBaseDelay)*time.Millisecond,
+			time.Duration(cfg.Auth.LoginThrottleMaxDelay)*time.Millisecond,
+			cfg.Auth.LoginThrottleMultiplier,
+			time.Duration(cfg.Auth.LoginThrottleWindow)*time.Second,
+		)
+	}
+	var refreshRotator *auth.RefreshRotator
+	if cfg.Auth.RefreshTokenRotation {
+		refreshRotator = auth.NewRefreshRotator(jwtAuth, blacklist, sessionManager, time.Duration(cfg.Auth.RefreshReuseGrace)*time.Second)
+	}
+	authHandler := handler.NewAuthHandler(userService, blacklist, sessionManager, refreshRotator, captchaGuard, loginThrottle, magicLinks, queueClient, &cfg.Auth)
+	// 任务仓储（异步导入与任务查询共用）
+	taskRepo := repository.NewTaskRepository(database.DB())
+
+	// 大批量用户异步导入：CSV 保存到文件存储，由队列 Worker 分批处理
+	var userImporter *service.UserImportService
+	if queueClient != nil {
+		userImporter = service.NewUserImportService(userService, fileStorage, taskRepo, queueClient, &cfg.Queue, cfg.Upload.TempDir)
+		if queueWorker != nil {
+			queue.RegisterTyped(queueWorker, service.UserImportTaskName, userImporter.Run)
+		}
+	}
+	// 邮件任务处理器：配置校验保证免密登录、邮箱修改启用时已启用队列与邮件
+	if queueWorker != nil && cfg.Mail.Enabled {
+		smtpMailer, err := mailer.NewSMTPMailer(&cfg.Mail)
+		if err != nil {
+			panic("failed to initialize mailer: " + err.Error())
+		}
+		emailService := service.NewEmailService(smtpMailer)
+		queue.RegisterTyped(queueWorker, service.MagicLinkEmailTaskName, emailService.SendMagicLink)
+		queue.RegisterTyped(queueWorker, service.EmailChangeTaskName, emailService.SendEmailChangeConfirmation)
+	}
+	// RBAC 变更事件默认处理器只记录日志，接入 webhook 等外部通知时替换
+	if queueWorker != nil {
+		queue.RegisterTyped(queueWorker, service.RBACChangedTaskName, service.HandleRBACChanged)
+	}
+	userHandler := handler.NewUserHandler(userService, rbacService, queueClient, userImporter, cfg.User.EmailChangeURL)
+
+	// 文件上传服务和处理器
 	fileRepo := repository.NewFileRepository(database.DB())
 	fileService := service.NewFileService(fileRepo, fileStorage, &cfg.Upload)
-	fileHandler := handler.NewFileHandler(fileService)
+	fileHandler := handler.NewFileHandler(fileService, rbacService, &cfg.Upload)
 
 	// 任务服务和处理器
-	taskRepo := repository.NewTaskRepository(database.DB())
-	taskHandler := handler.NewTaskHandler(taskRepo)
+	taskMetrics := queue.NewClient(cfg.Queue.RedisPrefix).GetMetricsKey()
+	taskHandler := handler.NewTaskHandler(taskRepo, queueClient, queue.NewMetrics(taskMetrics), queueDepth, rbacService)
 
 	// 审计日志服务和处理器
 	auditRepo := repository.NewAuditLogRepository(database.DB())
-	auditHandler := handler.NewAuditLogHandler(auditRepo)
+	auditHandler := handler.NewAuditLogHandler(auditRepo, &cfg.AuditLog)
+
+	// 系统管理处理器
+	adminHandler := handler.NewAdminHandler(rbacService, fileService, enforcer, maintenance, logger.Logger())
 
 	// 审计日志中间件
 	auditMiddleware := middleware.NewAuditLogMiddleware(&cfg.AuditLog, database.DB())
+	// 非 CRUD 路由的动作/资源无法从方法和路径推断，在此显式登记（配置 audit_log.action_overrides 可覆盖）
+	auditMiddleware.Override("POST", "/api/v1/users/import", model.AuditActionImport, "user")
+	auditMiddleware.Override("POST", "/api/v1/users/import/async", model.AuditActionImport, "user")
+	auditMiddleware.Override("POST", "/api/v1/users/:id/restore", model.AuditActionUpdate, "user")
+	auditMiddleware.Override("GET", "/api/v1/users/email/confirm", model.AuditActionUpdate, "user")
+	auditMiddleware.Override("POST", "/api/v1/roles/:id/permissions/update", model.AuditActionUpdate, "role_permission")
+	auditMiddleware.Override("POST", "/api/v1/roles/:id/permissions/simulate", model.AuditActionRead, "role_permission")
+	auditMiddleware.Override("POST", "/api/v1/roles/:id/permissions", model.AuditActionCreate, "role_permission")
+	auditMiddleware.Override("DELETE", "/api/v1/roles/:id/permissions", model.AuditActionDelete, "role_permission")
+	auditMiddleware.Override("DELETE", "/api/v1/roles/:id/permissions/batch", model.AuditActionDelete, "role_permission")
+	auditMiddleware.Override("POST", "/api/v1/user-roles/check", model.AuditActionRead, "permission")
+	auditMiddleware.Override("POST", "/api/v1/user-roles/batch", model.AuditActionRead, "user_role")
+	auditMiddleware.Override("POST", "/api/v1/files/batch-get", model.AuditActionRead, "file")
+	auditMiddleware.Override("POST", "/api/v1/tasks/bulk-status", model.AuditActionUpdate, "task")
+	auditMiddleware.Override("PUT", "/api/v1/admin/maintenance", model.AuditActionUpdate, "maintenance")
 
 	api := e.Group("/api")
 	{
@@ -75,7 +206,26 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 			}))
 			{
 				publicGroup.GET("/health", healthHandler.Check)
+				publicGroup.GET("/ready", healthHandler.Ready)
 				publicGroup.POST("/ping", healthHandler.Ping)
+				publicGroup.GET("/meta/features", metaHandler.Features)
+
+				// 公开分享链接：无需登录，按 IP 单独限流（不受 ratelimit.enabled 影响）
+				publicGroup.GET("/public/files/:token", fileHandler.PublicDownload,
+					middleware.RequireFeature(cfg.Upload.PublicLinkEnabled, "public link"),
+					middleware.RateLimit(&middleware.RateLimitConfig{
+						Enabled:   true,
+						Algorithm: cfg.RateLimit.Algorithm,
+						Limit:     cfg.Upload.PublicLinkRateLimit,
+						Window:    cfg.Upload.PublicLinkRateWindow,
+						Dimension: "ip",
+						KeyPrefix: "public_file",
+					}))
+
+				// 邮箱修改确认：令牌即凭证，无需登录；邮箱变更同样记录审计日志
+				publicGroup.GET("/users/email/confirm", userHandler.ConfirmEmailChange,
+					middleware.RequireFeature(cfg.User.EmailChangeURL != "" && queueClient != nil, "email change"),
+					auditMiddleware.Handler())
 
 				// 认证相关路由
 				authGroup := publicGroup.Group("/auth")
@@ -83,13 +233,25 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 					authGroup.POST("/register", authHandler.Register)
 					authGroup.POST("/login", authHandler.Login)
 					authGroup.POST("/refresh", authHandler.RefreshToken)
+					authGroup.POST("/magic-link", authHandler.RequestMagicLink, middleware.RequireFeature(magicLinkEnabled, "magic link"))
+					authGroup.GET("/magic-link/verify", authHandler.VerifyMagicLink, middleware.RequireFeature(magicLinkEnabled, "magic link"))
+					authGroup.POST("/introspect", authHandler.Introspect, middleware.ServiceAuth(cfg.Auth.IntrospectToken, cfg.Auth.IntrospectAllowedIPs))
 					authGroup.POST("/logout", authHandler.Logout, middleware.Auth(jwtAuth, blacklist))
+					authGroup.GET("/sessions", authHandler.ListSessions, middleware.Auth(jwtAuth, blacklist))
+					authGroup.DELETE("/sessions/:jti", authHandler.RevokeSession, middleware.Auth(jwtAuth, blacklist))
 				}
 			}
 
 			// 需要认证的路由（用户限流：每分钟 1000 次）
 			// 应用审计日志中间件
 			authGroup := v1.Group("",
+				// 请求内权限缓存：域成员校验、requireRoleLevel 与处理器中的权限/角色等级查询对同一用户、域只加载一次
+				func(next echo.HandlerFunc) echo.HandlerFunc {
+					return func(c echo.Context) error {
+						c.SetRequest(c.Request().WithContext(service.WithRequestMemo(c.Request().Context())))
+						return next(c)
+					}
+				},
 				middleware.Auth(jwtAuth, blacklist),
 				middleware.RateLimit(&middleware.RateLimitConfig{
 					Enabled:   cfg.RateLimit.Enabled,
@@ -98,17 +260,53 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 					Window:    cfg.RateLimit.UserWindow,
 					Dimension: "user",
 				}),
+				// 路由级限流规则（如某用户每小时最多调用导出接口 5 次）
+				middleware.RouteRateLimit(cfg.RateLimit.Enabled, cfg.RateLimit.Algorithm, cfg.RateLimit.Rules),
+				// 多步写入的路由在请求级事务中执行，审计日志与业务写入一起提交或回滚
+				middleware.Transactional(database.DB(),
+					"POST /api/v1/user-roles",
+					"DELETE /api/v1/user-roles",
+				),
 				auditMiddleware.Handler(), // 添加审计日志中间件
+				// 域成员校验：显式指定的域必须是用户拥有角色的域（超级管理员可跨域）
+				middleware.DomainMembership(middleware.DomainMembershipConfig{
+					IsMember: func(ctx context.Context, userID uint, domain string) (bool, error) {
+						roles, err := rbacService.GetUserRoles(ctx, userID, domain)
+						if err != nil {
+							return false, err
+						}
+						return len(roles) > 0, nil
+					},
+					CanBypass: func(ctx context.Context, userID uint, domain string) (bool, error) {
+						level, err := rbacService.GetUserMaxRoleLevel(ctx, userID, domain)
+						if err != nil {
+							return false, err
+						}
+						return level >= model.SuperAdminRoleLevel, nil
+					},
+					Logger: logger.Logger(),
+				}),
+				// 未显式指定域时使用用户的默认域（经用户缓存读取）
+				middleware.UserDefaultDomain(middleware.UserDefaultDomainConfig{
+					Lookup: userService.GetDefaultDomain,
+					Logger: logger.Logger(),
+				}),
 			)
 			{
 				// 用户管理路由
 				users := authGroup.Group("/users")
 				{
 					users.POST("", userHandler.Create)
+					users.POST("/import", userHandler.Import)            // 批量导入用户（JSON 或 CSV）
+					users.POST("/import/async", userHandler.ImportAsync) // 大批量 CSV 异步导入，返回任务ID
 					users.GET("", userHandler.List)
+					users.GET("/with-roles", userHandler.ListWithRoles) // 用户及其角色（?domain=&role=）
+					users.GET("/search", userHandler.Search)            // 按用户名/邮箱/昵称搜索（?keyword=），无 user:read_pii 权限时邮箱脱敏
 					users.GET("/:id", userHandler.GetByID)
 					users.PUT("/:id", userHandler.Update)
 					users.DELETE("/:id", userHandler.Delete)
+					users.POST("/:id/restore", userHandler.Restore)                // 恢复已删除用户（不恢复角色）
+					users.PUT("/:id/default-domain", userHandler.SetDefaultDomain) // 设置默认域（未指定域的请求使用）
 				}
 
 				// 角色管理路由
@@ -117,16 +315,23 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 					roles.POST("", roleHandler.CreateRole)
 					roles.GET("", roleHandler.ListRoles)
 					roles.GET("/search", roleHandler.SearchRoles)
+					roles.GET("/export", roleHandler.ExportRoles)              // 流式导出可管理的角色
+					roles.GET("/inheritance/cycles", roleHandler.DetectCycles) // 诊断角色继承环
 					roles.GET("/:id", roleHandler.GetRole)
 					roles.PUT("/:id", roleHandler.UpdateRole)
 					roles.DELETE("/:id", roleHandler.DeleteRole)
 
 					// 角色权限管理
-					roles.POST("/:id/permissions/update", roleHandler.UpdatePermissions) // 新API：支持预览和执行
-					roles.POST("/:id/permissions", roleHandler.AssignPermissions)        // 旧API：保留向后兼容
+					roles.POST("/:id/permissions/update", roleHandler.UpdatePermissions)     // 新API：支持预览和执行
+					roles.POST("/:id/permissions/simulate", roleHandler.SimulatePermissions) // 模拟变更对已分配用户的影响
+					roles.POST("/:id/permissions", roleHandler.AssignPermissions)            // 旧API：保留向后兼容
 					roles.DELETE("/:id/permissions", roleHandler.RevokePermission)
+					roles.DELETE("/:id/permissions/batch", roleHandler.RevokePermissions) // 批量撤销 {"permission_ids": [...]}
 					roles.GET("/:id/permissions", roleHandler.GetRolePermissions)
 					roles.GET("/:id/users", roleHandler.GetRoleUsers)
+					roles.PUT("/:id/policies", roleHandler.ReplacePolicies) // 高级：直接替换 Casbin 策略（仅超级管理员）
+					roles.POST("/:id/parents", roleHandler.AddParent)       // 添加角色继承（拒绝形成环）
+					roles.DELETE("/:id/parents/:parent_id", roleHandler.RemoveParent)
 				}
 
 				// 权限管理路由
@@ -134,7 +339,9 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 				{
 					permissions.POST("", permissionHandler.CreatePermission)
 					permissions.GET("", permissionHandler.ListPermissions)
+					permissions.GET("/grouped", permissionHandler.ListPermissionsGrouped) // 按分类分组
 					permissions.GET("/tree", permissionHandler.ListPermissionsTree)
+					permissions.GET("/tree/validate", permissionHandler.ValidatePermissionHierarchy)
 					permissions.GET("/search", permissionHandler.SearchPermissions)
 					permissions.GET("/type/:type", permissionHandler.ListPermissionsByType)
 					permissions.GET("/:id", permissionHandler.GetPermission)
@@ -147,8 +354,11 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 				{
 					userRoles.POST("", userRoleHandler.AssignRolesToUser)
 					userRoles.DELETE("", userRoleHandler.RevokeRolesFromUser)
+					userRoles.GET("/assignable", userRoleHandler.ListAssignableRoles)
 					userRoles.GET("/user/:userId", userRoleHandler.GetUserRoles)
+					userRoles.POST("/batch", userRoleHandler.BatchGetUserRoles) // 批量查询多个用户的角色
 					userRoles.GET("/user/:userId/permissions", userRoleHandler.GetUserPermissions)
+					userRoles.GET("/user/:userId/policies", userRoleHandler.GetUserPolicies)
 					userRoles.POST("/check", userRoleHandler.CheckUserPermission)
 				}
 
@@ -157,34 +367,60 @@ func Setup(e *echo.Echo, cfg *config.Config, jwtAuth *auth.JWTAuth, blacklist *a
 				{
 					files.POST("/upload", fileHandler.Upload)
 					files.POST("/upload/avatar", fileHandler.UploadAvatar)
+					files.POST("/upload/chunked", fileHandler.InitChunkUpload)
+					files.PUT("/upload/:id/chunks/:index", fileHandler.UploadChunk)
+					files.GET("/upload/:id/status", fileHandler.GetUploadStatus)
+					files.POST("/upload/:id/complete", fileHandler.CompleteChunkUpload)
 					files.GET("", fileHandler.List)
 					files.GET("/search", fileHandler.Search)
 					files.GET("/storage-info", fileHandler.GetStorageInfo)
+					files.POST("/batch-get", fileHandler.BatchGet)
 					files.GET("/:id", fileHandler.GetByID)
 					files.GET("/:id/download", fileHandler.Download)
+					files.POST("/:id/public-links", fileHandler.CreatePublicLink)
+					files.DELETE("/public-links/:token", fileHandler.RevokePublicLink)
 					files.DELETE("/:id", fileHandler.Delete)
+					files.PUT("/:id/tags", fileHandler.SetTags)
+					files.POST("/:id/tags", fileHandler.AddTags)
+					files.DELETE("/:id/tags", fileHandler.RemoveTags)
 				}
 
-				// 任务管理路由
-				tasks := authGroup.Group("/tasks")
+				// 任务管理路由（队列未启用时返回 501）
+				tasks := authGroup.Group("/tasks", middleware.RequireFeature(cfg.Queue.Enabled, "queue"))
 				{
 					tasks.GET("", taskHandler.List)
 					tasks.GET("/stats", taskHandler.GetStats)
+					tasks.GET("/metrics", taskHandler.GetMetrics)
+					tasks.GET("/metrics/depth", taskHandler.GetQueueDepth)   // 队列积压状态（最高水位、超阈值时长）
+					tasks.POST("/bulk-status", taskHandler.BulkUpdateStatus) // 批量更新任务状态（需要管理员权限）
 					tasks.GET("/:id", taskHandler.GetByID)
 					tasks.GET("/task/:taskId", taskHandler.GetByTaskID)
 				}
 
-				// 审计日志路由
-				auditLogs := authGroup.Group("/audit-logs")
+				// 审计日志路由（审计未启用时返回 501）
+				auditLogs := authGroup.Group("/audit-logs", middleware.RequireFeature(cfg.AuditLog.Enabled, "audit log"))
 				{
 					auditLogs.GET("", auditHandler.List)
+					auditLogs.GET("/export", auditHandler.Export) // 流式导出 JSON 数组
 					auditLogs.GET("/stats", auditHandler.GetStats)
 					auditLogs.GET("/stats/actions", auditHandler.GetActionStats)
 					auditLogs.GET("/stats/users", auditHandler.GetUserStats)
 					auditLogs.GET("/stats/resources", auditHandler.GetResourceStats)
 					auditLogs.GET("/user/:userId", auditHandler.ListByUser)
 					auditLogs.GET("/:id", auditHandler.GetByID)
-					auditLogs.DELETE("/clean", auditHandler.CleanOldLogs) // 清理旧日志（需要管理员权限）
+					auditLogs.DELETE("/clean", auditHandler.CleanOldLogs)         // 清理旧日志（需要管理员权限）
+					auditLogs.GET("/prune/metrics", auditHandler.GetPruneMetrics) // 自动清理指标
+				}
+
+				// 系统管理路由（仅超级管理员）
+				admin := authGroup.Group("/admin")
+				{
+					admin.POST("/cache/flush", adminHandler.FlushCache)                           // 清空缓存（可按 pattern 限定范围）
+					admin.GET("/cache/metrics", adminHandler.GetCacheMetrics)                     // 缓存命中率（按逻辑缓存名）
+					admin.GET("/casbin/metrics", adminHandler.GetCasbinMetrics)                   // 策略版本与自动加载统计
+					admin.POST("/files/thumbnails/regenerate", adminHandler.RegenerateThumbnails) // 按当前配置重新生成缩略图
+					admin.GET("/maintenance", adminHandler.GetMaintenance)                        // 维护模式状态
+					admin.PUT("/maintenance", adminHandler.SetMaintenance)                        // 开启/关闭维护模式
 				}
 			}
 		}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -29,9 +30,13 @@ func New(cfg *config.Config) *Server {
 	e.HidePort = true
 	e.Validator = customValidator.New()
 	e.HTTPErrorHandler = middleware.ErrorHandler()
+	e.IPExtractor = middleware.IPExtractor(cfg.Server.TrustedProxies)
 
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Recovery())
 	e.Use(middleware.Logger())
+	e.Use(middleware.SlowRequest(time.Duration(cfg.Server.SlowRequestMs) * time.Millisecond))
+	e.Use(middleware.ServerTiming(cfg.Server.TimingHeader, cfg.Server.TimingLog))
 	e.Use(middleware.CORS())
 
 	return &Server{
@@ -44,6 +49,29 @@ func (s *Server) Echo() *echo.Echo {
 	return s.echo
 }
 
+// startTLS 以 HTTPS 方式监听，最低 TLS 版本由 server.tls_min_version 控制
+// 使用 echo 内置的 TLSServer，确保 Shutdown 时能够优雅关闭
+func (s *Server) startTLS(addr string) error {
+	cert, err := tls.LoadX509KeyPair(s.config.Server.TLSCertFile, s.config.Server.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if s.config.Server.TLSMinVersion == "1.3" {
+		minVersion = tls.VersionTLS13
+	}
+
+	srv := s.echo.TLSServer
+	srv.Addr = addr
+	srv.TLSConfig = &tls.Config{
+		MinVersion:   minVersion,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	return s.echo.StartServer(srv)
+}
+
 func (s *Server) Start() error {
 	addr := s.config.GetServerAddr()
 
@@ -52,7 +80,13 @@ func (s *Server) Start() error {
 		slog.String("mode", s.config.Server.Mode))
 
 	go func() {
-		if err := s.echo.Start(addr); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.config.Server.TLSCertFile != "" && s.config.Server.TLSKeyFile != "" {
+			err = s.startTLS(addr)
+		} else {
+			err = s.echo.Start(addr)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("failed to start server", slog.Any("error", err))
 			os.Exit(1)
 		}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/logger"
+)
+
+// auditPruneMetricsKey 审计日志清理指标（Redis 哈希，多实例共享）
+const auditPruneMetricsKey = "audit_log:prune:metrics"
+
+// AuditPruneResult 一轮清理的删除数
+type AuditPruneResult struct {
+	DeletedByAge  int64 `json:"deleted_by_age"`  // 超过保留天数而删除的行数
+	DeletedByRows int64 `json:"deleted_by_rows"` // 超过最大行数而删除的行数
+}
+
+// AuditPruneMetrics 审计日志清理累计指标
+type AuditPruneMetrics struct {
+	Runs          int64  `json:"runs"`                 // 主节点执行清理的轮数
+	DeletedByAge  int64  `json:"deleted_by_age"`       // 累计按保留天数删除的行数
+	DeletedByRows int64  `json:"deleted_by_rows"`      // 累计按最大行数删除的行数
+	Failures      int64  `json:"failures"`             // 失败的轮数
+	LastRunAt     string `json:"last_run_at"`          // 最近一轮的时间（RFC3339），未执行过时为空
+	LastError     string `json:"last_error,omitempty"` // 最近一次失败的错误
+}
+
+// AuditPruner 审计日志自动清理
+// 先物理删除超过保留天数的记录，再在总行数超过上限时从最旧的记录删除到上限，两个条件任一触发即删除；
+// 均按批删除，每批一个语句。多实例部署时通过 Redis 租约选举，仅主节点执行，删除数记入共享指标
+type AuditPruner struct {
+	repo          repository.AuditLogRepository
+	leader        *cache.Leader
+	retentionDays int
+	maxRows       int64
+	batchSize     int
+	running       atomic.Bool // 防止上一轮未结束时重叠执行
+}
+
+// NewAuditPruner 创建审计日志清理器
+func NewAuditPruner(repo repository.AuditLogRepository, cfg *config.AuditLogConfig) *AuditPruner {
+	// 租约为执行间隔的 3 倍，主节点偶发一次延迟不会导致切换
+	lease := 3 * time.Duration(cfg.PruneInterval) * time.Second
+	return &AuditPruner{
+		repo:          repo,
+		leader:        cache.NewLeader("audit_pruner", lease),
+		retentionDays: cfg.RetentionDays,
+		maxRows:       int64(cfg.MaxRows),
+		batchSize:     cfg.PruneBatchSize,
+	}
+}
+
+// Prune 执行一轮清理，返回本轮删除数；非主节点或上一轮仍在执行时直接返回
+// 出错时已删除的行数同样计入结果与指标
+func (p *AuditPruner) Prune(ctx context.Context) (*AuditPruneResult, error) {
+	result := &AuditPruneResult{}
+	if !p.running.CompareAndSwap(false, true) {
+		return result, nil
+	}
+	defer p.running.Store(false)
+
+	isLeader, err := p.leader.IsLeader(ctx)
+	if err != nil {
+		return result, fmt.Errorf("audit pruner leader election failed: %w", err)
+	}
+	if !isLeader {
+		return result, nil
+	}
+
+	err = p.prune(ctx, result)
+	p.record(ctx, result, err)
+	if result.DeletedByAge > 0 || result.DeletedByRows > 0 {
+		logger.Info("audit logs pruned",
+			"deleted_by_age", result.DeletedByAge,
+			"deleted_by_rows", result.DeletedByRows,
+		)
+	}
+	return result, err
+}
+
+// prune 依次按保留天数、最大行数删除
+func (p *AuditPruner) prune(ctx context.Context, result *AuditPruneResult) error {
+	if p.retentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -p.retentionDays)
+		for {
+			n, err := p.repo.PurgeBefore(ctx, cutoff, p.batchSize)
+			result.DeletedByAge += n
+			if err != nil {
+				return fmt.Errorf("failed to purge audit logs before %s: %w", cutoff.Format(time.RFC3339), err)
+			}
+			if n < int64(p.batchSize) {
+				break
+			}
+		}
+	}
+
+	if p.maxRows > 0 {
+		total, err := p.repo.CountAll(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count audit logs: %w", err)
+		}
+		for excess := total - p.maxRows; excess > 0; {
+			n, err := p.repo.PurgeOldest(ctx, int(min(excess, int64(p.batchSize))))
+			result.DeletedByRows += n
+			if err != nil {
+				return fmt.Errorf("failed to purge oldest audit logs: %w", err)
+			}
+			if n == 0 {
+				break
+			}
+			excess -= n
+		}
+	}
+	return nil
+}
+
+// record 累加清理指标，写入失败只记录日志
+func (p *AuditPruner) record(ctx context.Context, result *AuditPruneResult, pruneErr error) {
+	pipe := cache.Pipeline()
+	key := cache.BuildKey(auditPruneMetricsKey)
+	pipe.HIncrBy(ctx, key, "runs", 1)
+	pipe.HIncrBy(ctx, key, "deleted_by_age", result.DeletedByAge)
+	pipe.HIncrBy(ctx, key, "deleted_by_rows", result.DeletedByRows)
+	pipe.HSet(ctx, key, "last_run_at", time.Now().Format(time.RFC3339))
+	if pruneErr != nil {
+		pipe.HIncrBy(ctx, key, "failures", 1)
+		pipe.HSet(ctx, key, "last_error", pruneErr.Error())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("failed to record audit prune metrics", "error", err)
+	}
+}
+
+// Resign 释放主节点租约（服务退出时调用）
+func (p *AuditPruner) Resign(ctx context.Context) error {
+	return p.leader.Resign(ctx)
+}
+
+// GetAuditPruneMetrics 读取审计日志清理累计指标
+func GetAuditPruneMetrics(ctx context.Context) (*AuditPruneMetrics, error) {
+	values, err := cache.HGetAll(ctx, auditPruneMetricsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(field string) int64 {
+		n, _ := strconv.ParseInt(values[field], 10, 64)
+		return n
+	}
+	return &AuditPruneMetrics{
+		Runs:          parse("runs"),
+		DeletedByAge:  parse("deleted_by_age"),
+		DeletedByRows: parse("deleted_by_rows"),
+		Failures:      parse("failures"),
+		LastRunAt:     values["last_run_at"],
+		LastError:     values["last_error"],
+	}, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/database"
+)
+
+// seedAuditLogs 按顺序写入审计日志，ages 为每条记录距今的天数
+func seedAuditLogs(t *testing.T, db *database.Database, ages ...int) []model.AuditLog {
+	t.Helper()
+
+	logs := make([]model.AuditLog, len(ages))
+	for i, age := range ages {
+		logs[i] = model.AuditLog{Action: "update", Resource: "user", Method: "PUT", Path: "/api/v1/users/1", StatusCode: 200}
+		logs[i].CreatedAt = time.Now().AddDate(0, 0, -age)
+	}
+	if err := db.DB.Create(&logs).Error; err != nil {
+		t.Fatalf("seed audit logs: %v", err)
+	}
+	return logs
+}
+
+// remainingAuditLogIDs 表中剩余记录的ID（含已软删除）
+func remainingAuditLogIDs(t *testing.T, db *database.Database) []uint {
+	t.Helper()
+
+	var ids []uint
+	if err := db.DB.Unscoped().Model(&model.AuditLog{}).Order("id ASC").Pluck("id", &ids).Error; err != nil {
+		t.Fatalf("list audit log ids: %v", err)
+	}
+	return ids
+}
+
+func TestAuditPruner_AgeThenRowCap(t *testing.T) {
+	testutil.NewRedis(t)
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+	ctx := context.Background()
+
+	// 5 条超过保留期，15 条在保留期内；按时间清理后仍超过行数上限 6
+	ages := []int{40, 39, 38, 37, 36}
+	for i := 0; i < 15; i++ {
+		ages = append(ages, 15-i)
+	}
+	logs := seedAuditLogs(t, db, ages...)
+	// 软删除的记录同样占用存储，计入行数并被物理删除
+	if err := db.DB.Delete(&logs[10]).Error; err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	pruner := NewAuditPruner(repository.NewAuditLogRepository(db), &config.AuditLogConfig{
+		RetentionDays: 30, MaxRows: 6, PruneInterval: 60, PruneBatchSize: 4,
+	})
+	result, err := pruner.Prune(ctx)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if result.DeletedByAge != 5 || result.DeletedByRows != 9 {
+		t.Fatalf("result = %+v, want 5 by age and 9 by rows", result)
+	}
+
+	ids := remainingAuditLogIDs(t, db)
+	if len(ids) != 6 || ids[0] != logs[14].ID {
+		t.Fatalf("remaining ids = %v, want the newest 6 starting at %d", ids, logs[14].ID)
+	}
+
+	// 已在上限内时不再删除，指标累计两轮
+	if result, err := pruner.Prune(ctx); err != nil || result.DeletedByAge+result.DeletedByRows != 0 {
+		t.Fatalf("second Prune = %+v, %v, want nothing deleted", result, err)
+	}
+	metrics, err := GetAuditPruneMetrics(ctx)
+	if err != nil {
+		t.Fatalf("GetAuditPruneMetrics: %v", err)
+	}
+	if metrics.Runs != 2 || metrics.DeletedByAge != 5 || metrics.DeletedByRows != 9 || metrics.Failures != 0 || metrics.LastRunAt == "" {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestAuditPruner_RowCapOnly(t *testing.T) {
+	testutil.NewRedis(t)
+	db := testutil.NewDatabase(t, &model.AuditLog{})
+
+	// 未配置保留天数时很旧的记录也只按行数清理
+	logs := seedAuditLogs(t, db, 400, 300, 200, 1, 0)
+	pruner := NewAuditPruner(repository.NewAuditLogRepository(db), &config.AuditLogConfig{
+		MaxRows: 4, PruneInterval: 60, PruneBatchSize: 100,
+	})
+	result, err := pruner.Prune(context.Background())
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if result.DeletedByAge != 0 || result.DeletedByRows != 1 {
+		t.Fatalf("result = %+v, want 1 by rows", result)
+	}
+	if ids := remainingAuditLogIDs(t, db); len(ids) != 4 || ids[0] != logs[1].ID {
+		t.Fatalf("remaining ids = %v, want all but the oldest", ids)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/storage"
+)
+
+const (
+	avatarGridSize = 5  // 头像网格大小（5x5，左右对称）
+	avatarCellSize = 48 // 每个格子的像素大小
+	avatarPadding  = 24 // 四周留白像素
+	avatarDir      = "avatar/default"
+)
+
+// AvatarService 默认头像服务接口
+type AvatarService interface {
+	// Generate 根据种子（用户名）生成确定性的 identicon 头像，返回访问 URL
+	Generate(ctx context.Context, seed string) (string, error)
+}
+
+type avatarService struct {
+	storage storage.Storage
+	config  *config.UploadConfig
+}
+
+// NewAvatarService 创建默认头像服务
+func NewAvatarService(storage storage.Storage, cfg *config.UploadConfig) AvatarService {
+	return &avatarService{
+		storage: storage,
+		config:  cfg,
+	}
+}
+
+// Generate 生成默认头像
+// 同一个种子总是生成相同的图片和路径，已存在时直接复用
+func (s *avatarService) Generate(ctx context.Context, seed string) (string, error) {
+	hash := sha256.Sum256([]byte(seed))
+	path := fmt.Sprintf("%s/%x.png", avatarDir, hash[:16])
+
+	exists, err := s.storage.Exists(ctx, path)
+	if err == nil && exists {
+		return s.storage.GetURL(path), nil
+	}
+
+	img := renderIdenticon(hash)
+
+	// 编码到临时文件后上传
+	f, err := os.CreateTemp(s.config.TempDir, "avatar_*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return "", fmt.Errorf("failed to encode avatar: %w", err)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	url, err := s.storage.Upload(ctx, f, fmt.Sprintf("%x.png", hash[:16]), path)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload avatar: %w", err)
+	}
+
+	return url, nil
+}
+
+// renderIdenticon 根据哈希绘制左右对称的 identicon
+// 前 3 字节决定前景色，后续字节的奇偶决定格子是否填充
+func renderIdenticon(hash [32]byte) image.Image {
+	size := avatarGridSize*avatarCellSize + avatarPadding*2
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	background := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+	foreground := color.RGBA{R: hash[0], G: hash[1], B: hash[2], A: 255}
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	half := (avatarGridSize + 1) / 2
+	for row := 0; row < avatarGridSize; row++ {
+		for col := 0; col < half; col++ {
+			if hash[3+row*half+col]%2 == 0 {
+				continue
+			}
+			for _, c := range []int{col, avatarGridSize - 1 - col} {
+				x := avatarPadding + c*avatarCellSize
+				y := avatarPadding + row*avatarCellSize
+				rect := image.Rect(x, y, x+avatarCellSize, y+avatarCellSize)
+				draw.Draw(img, rect, &image.Uniform{C: foreground}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return img
+}
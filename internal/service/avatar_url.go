@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/cccvno1/nova/pkg/storage"
+)
+
+// AvatarURLResolver 将用户记录中的头像解析为客户端可直接访问的地址
+// 头像可能保存为存储中的相对路径（如 avatar/1/a.png），原样返回时客户端无法访问；
+// 外部地址（带 scheme，或以 / 开头的站内地址）原样返回
+type AvatarURLResolver struct {
+	storage storage.Storage
+}
+
+// NewAvatarURLResolver 创建头像地址解析器，存储中的相对路径按 GetURL 解析
+func NewAvatarURLResolver(store storage.Storage) *AvatarURLResolver {
+	return &AvatarURLResolver{storage: store}
+}
+
+// Resolve 解析头像地址
+func (r *AvatarURLResolver) Resolve(ctx context.Context, avatar string) string {
+	if r == nil || !isStoragePath(avatar) {
+		return avatar
+	}
+	return r.storage.GetURL(avatar)
+}
+
+// isStoragePath 判断头像是否为存储中的相对路径：非空、不以 / 开头且不带 scheme
+func isStoragePath(avatar string) bool {
+	if avatar == "" || strings.HasPrefix(avatar, "/") {
+		return false
+	}
+	u, err := url.Parse(avatar)
+	return err == nil && u.Scheme == ""
+}
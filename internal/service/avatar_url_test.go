@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccvno1/nova/pkg/storage"
+)
+
+func TestAvatarURLResolver_Resolve(t *testing.T) {
+	local, err := storage.NewLocalStorage(t.TempDir(), "https://cdn.example.com/uploads")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		resolver *AvatarURLResolver
+		avatar   string
+		want     string
+	}{
+		{"nil resolver", nil, "avatar/a.png", "avatar/a.png"},
+		{"empty", NewAvatarURLResolver(local), "", ""},
+		{"relative path", NewAvatarURLResolver(local), "avatar/a.png", "https://cdn.example.com/uploads/avatar/a.png"},
+		{"external url", NewAvatarURLResolver(local), "https://img.example.com/a.png", "https://img.example.com/a.png"},
+		{"site path", NewAvatarURLResolver(local), "/static/a.png", "/static/a.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resolver.Resolve(ctx, tt.avatar); got != tt.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tt.avatar, got, tt.want)
+			}
+		})
+	}
+}
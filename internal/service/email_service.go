@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/mailer"
+)
+
+// MagicLinkEmailTaskName 免密登录邮件的队列任务名
+const MagicLinkEmailTaskName = "send_magic_link_email"
+
+// MagicLinkEmail 免密登录邮件任务载荷
+type MagicLinkEmail struct {
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Link      string `json:"link"`
+	ExpiresIn int    `json:"expires_in"` // 链接有效期（秒）
+}
+
+// EmailChangeTaskName 邮箱修改确认邮件的队列任务名
+const EmailChangeTaskName = "send_email_change_confirmation"
+
+// EmailChangeConfirmation 邮箱修改确认邮件任务载荷，邮件发送到待确认的新邮箱
+type EmailChangeConfirmation struct {
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Link      string `json:"link"`
+	ExpiresIn int    `json:"expires_in"` // 链接有效期（秒）
+}
+
+// EmailService 发送账户相关邮件，由队列 Worker 调用
+// 发送失败返回错误，由队列按重试策略重新投递
+type EmailService struct {
+	mailer mailer.Mailer
+}
+
+// NewEmailService 创建邮件服务
+func NewEmailService(m mailer.Mailer) *EmailService {
+	return &EmailService{mailer: m}
+}
+
+// SendMagicLink 发送免密登录链接
+func (s *EmailService) SendMagicLink(ctx context.Context, p MagicLinkEmail) error {
+	body := fmt.Sprintf("%s，您好：\n\n请点击以下链接登录，链接在 %s 内有效且只能使用一次：\n\n%s\n\n如果这不是您本人的操作，请忽略本邮件。\n",
+		p.Username, formatExpiry(p.ExpiresIn), p.Link)
+	return s.mailer.Send(ctx, &mailer.Message{To: p.Email, Subject: "登录链接", Body: body})
+}
+
+// SendEmailChangeConfirmation 向新邮箱发送邮箱修改确认链接
+func (s *EmailService) SendEmailChangeConfirmation(ctx context.Context, p EmailChangeConfirmation) error {
+	body := fmt.Sprintf("您好：\n\n您的账户申请将登录邮箱修改为 %s。请点击以下链接确认，链接在 %s 内有效且只能使用一次：\n\n%s\n\n确认前原邮箱保持不变；如果这不是您本人的操作，请忽略本邮件。\n",
+		p.Email, formatExpiry(p.ExpiresIn), p.Link)
+	return s.mailer.Send(ctx, &mailer.Message{To: p.Email, Subject: "确认修改邮箱", Body: body})
+}
+
+// formatExpiry 将有效期秒数格式化为便于阅读的时长
+func formatExpiry(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d 小时", int(d/time.Hour))
+	case d >= time.Minute:
+		return fmt.Sprintf("%d 分钟", int(d/time.Minute))
+	default:
+		return fmt.Sprintf("%d 秒", seconds)
+	}
+}
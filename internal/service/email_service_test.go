@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cccvno1/nova/pkg/mailer"
+)
+
+// recordingMailer 记录发送的邮件，err 非 nil 时返回该错误
+type recordingMailer struct {
+	sent []*mailer.Message
+	err  error
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg *mailer.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestEmailService_SendMagicLink(t *testing.T) {
+	m := &recordingMailer{}
+	svc := NewEmailService(m)
+
+	err := svc.SendMagicLink(context.Background(), MagicLinkEmail{
+		UserID:    1,
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Link:      "https://app.example.com/login/magic?token=abc",
+		ExpiresIn: 900,
+	})
+	if err != nil {
+		t.Fatalf("SendMagicLink: %v", err)
+	}
+	if len(m.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(m.sent))
+	}
+	msg := m.sent[0]
+	if msg.To != "alice@example.com" {
+		t.Errorf("To = %q", msg.To)
+	}
+	for _, want := range []string{"alice", "https://app.example.com/login/magic?token=abc", "15 分钟"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("body missing %q:\n%s", want, msg.Body)
+		}
+	}
+}
+
+func TestEmailService_ReturnsMailerError(t *testing.T) {
+	sendErr := errors.New("smtp down")
+	svc := NewEmailService(&recordingMailer{err: sendErr})
+
+	err := svc.SendMagicLink(context.Background(), MagicLinkEmail{Email: "alice@example.com", Link: "x", ExpiresIn: 60})
+	if !errors.Is(err, sendErr) {
+		t.Fatalf("SendMagicLink error = %v, want %v (queue retries on error)", err, sendErr)
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	tests := map[int]string{30: "30 秒", 900: "15 分钟", 3600: "1 小时", 5400: "90 分钟", 86400: "24 小时"}
+	for seconds, want := range tests {
+		if got := formatExpiry(seconds); got != want {
+			t.Errorf("formatExpiry(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// 分片上传在 Redis 中的状态：
+//   - upload:{id}         元数据哈希（user_id、filename、mime_type、category、total_size、total_chunks、received、expires_at）
+//   - upload:{id}:chunks  分片序号 -> 分片大小
+//   - upload:{id}:paths   分片序号 -> 分片在存储中的路径（比元数据多保留一个 TTL，供过期清理删除分片文件）
+//   - upload:active       有序集合，成员为上传ID，分值为过期时间戳
+const (
+	chunkUploadKeyPrefix  = "upload"
+	chunkUploadActiveKey  = "active"
+	chunkUploadLockTTL    = 5 * time.Minute
+	chunkUploadSweepLimit = 20 // 每次初始化上传时顺带清理的过期上传数上限
+)
+
+// recordChunkScript 原子记录分片并累计已接收字节数
+// 同一分片重复上传时按新旧大小差值修正累计值，并返回被替换的旧分片路径供调用方删除
+// 返回 {received, old_path}；received 为 -1 表示上传不存在或已过期，-2 表示超出总大小上限
+var recordChunkScript = redis.NewScript(`
+local meta = KEYS[1]
+local chunks = KEYS[2]
+local paths = KEYS[3]
+local active = KEYS[4]
+local index = ARGV[1]
+local size = tonumber(ARGV[2])
+local path = ARGV[3]
+local ttl = tonumber(ARGV[4])
+local max_bytes = tonumber(ARGV[5])
+local expires_at = tonumber(ARGV[6])
+local id = ARGV[7]
+
+if redis.call('exists', meta) == 0 then
+	return {-1, ''}
+end
+
+local prev_size = tonumber(redis.call('hget', chunks, index) or '0')
+local prev_path = redis.call('hget', paths, index) or ''
+local received = tonumber(redis.call('hget', meta, 'received') or '0') - prev_size + size
+if max_bytes > 0 and received > max_bytes then
+	return {-2, ''}
+end
+
+redis.call('hset', chunks, index, size)
+redis.call('hset', paths, index, path)
+redis.call('hset', meta, 'received', received, 'expires_at', expires_at)
+redis.call('expire', meta, ttl)
+redis.call('expire', chunks, ttl)
+redis.call('expire', paths, ttl * 2)
+redis.call('zadd', active, expires_at, id)
+return {received, prev_path}
+`)
+
+// InitChunkUploadRequest 初始化分片上传请求
+type InitChunkUploadRequest struct {
+	Filename    string `json:"filename"`
+	MimeType    string `json:"mime_type"`
+	Category    string `json:"category"`
+	TotalSize   int64  `json:"total_size"`   // 文件总大小（字节），0 表示未知
+	TotalChunks int    `json:"total_chunks"` // 分片总数，0 表示未知（合并时以最大分片序号为准）
+}
+
+// UploadStatus 分片上传进度
+type UploadStatus struct {
+	UploadID      string    `json:"upload_id"`
+	Filename      string    `json:"filename"`
+	ReceivedBytes int64     `json:"received_bytes"`
+	TotalBytes    int64     `json:"total_bytes,omitempty"`  // 初始化时未提供则不返回
+	TotalChunks   int       `json:"total_chunks,omitempty"` // 初始化时未提供则不返回
+	Chunks        []int     `json:"chunks"`                 // 已接收的分片序号（升序）
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// chunkUploadKey 构建分片上传键（不含全局前缀，直接访问 Redis 时需再经 cache.BuildKey）
+func chunkUploadKey(id string, parts ...string) string {
+	return strings.Join(append([]string{chunkUploadKeyPrefix, id}, parts...), ":")
+}
+
+func (s *fileService) chunkUploadTTL() time.Duration {
+	return time.Duration(s.config.ChunkUploadTTL) * time.Second
+}
+
+// InitChunkUpload 初始化分片上传，返回上传进度（含上传ID）
+func (s *fileService) InitChunkUpload(ctx context.Context, req InitChunkUploadRequest, userID uint) (*UploadStatus, error) {
+	if req.Filename == "" {
+		return nil, errors.New(errors.ErrInvalidParams, "filename is required")
+	}
+	if req.TotalSize < 0 || req.TotalChunks < 0 {
+		return nil, errors.New(errors.ErrInvalidParams, "total_size and total_chunks must not be negative")
+	}
+	if err := s.validateUpload(req.Filename, req.MimeType, req.TotalSize); err != nil {
+		return nil, err
+	}
+	if req.Category == "" {
+		req.Category = "other"
+	}
+	// 提前按声明的总大小检查配额，合并时按实际大小再检查一次
+	if err := s.checkQuota(ctx, userID, req.Category, req.TotalSize); err != nil {
+		return nil, err
+	}
+
+	s.sweepExpiredUploads(ctx)
+
+	id := uuid.New().String()
+	ttl := s.chunkUploadTTL()
+	expiresAt := time.Now().Add(ttl)
+	filename := sanitizeFilename(req.Filename, s.config.MaxFilenameLength)
+
+	pipe := cache.TxPipeline()
+	pipe.HSet(ctx, cache.BuildKey(chunkUploadKey(id)),
+		"user_id", userID,
+		"filename", filename,
+		"mime_type", req.MimeType,
+		"category", req.Category,
+		"total_size", req.TotalSize,
+		"total_chunks", req.TotalChunks,
+		"received", 0,
+		"expires_at", expiresAt.Unix(),
+	)
+	pipe.Expire(ctx, cache.BuildKey(chunkUploadKey(id)), ttl)
+	pipe.ZAdd(ctx, cache.BuildKey(chunkUploadKey(chunkUploadActiveKey)), redis.Z{Score: float64(expiresAt.Unix()), Member: id})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	return &UploadStatus{
+		UploadID:    id,
+		Filename:    filename,
+		TotalBytes:  req.TotalSize,
+		TotalChunks: req.TotalChunks,
+		Chunks:      []int{},
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// UploadChunk 上传一个分片
+// 分片先写入存储中的唯一路径，再通过 Lua 脚本原子登记，同一分片并发或重复上传时以最后登记的为准
+func (s *fileService) UploadChunk(ctx context.Context, id string, index int, chunk multipart.File, size int64, userID uint) (*UploadStatus, error) {
+	meta, err := s.loadChunkUpload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || (meta.totalChunks > 0 && index >= meta.totalChunks) {
+		return nil, errors.New(errors.ErrInvalidParams, fmt.Sprintf("invalid chunk index: %d", index))
+	}
+
+	chunkPath := path.Join("chunks", id, fmt.Sprintf("%d-%s", index, uuid.New().String()))
+	if _, err := s.storage.Upload(ctx, chunk, path.Base(chunkPath), chunkPath); err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, fmt.Errorf("failed to store chunk: %w", err))
+	}
+
+	maxBytes := s.config.MaxSize * 1024 * 1024
+	if meta.totalSize > 0 {
+		maxBytes = meta.totalSize
+	}
+	ttl := s.chunkUploadTTL()
+	result, err := recordChunkScript.Run(ctx, cache.GetClient(),
+		[]string{
+			cache.BuildKey(chunkUploadKey(id)),
+			cache.BuildKey(chunkUploadKey(id, "chunks")),
+			cache.BuildKey(chunkUploadKey(id, "paths")),
+			cache.BuildKey(chunkUploadKey(chunkUploadActiveKey)),
+		},
+		index, size, chunkPath, int(ttl.Seconds()), maxBytes, time.Now().Add(ttl).Unix(), id,
+	).Slice()
+	if err != nil {
+		_ = s.storage.Delete(ctx, chunkPath)
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	switch received, _ := result[0].(int64); received {
+	case -1:
+		_ = s.storage.Delete(ctx, chunkPath)
+		return nil, errors.New(errors.ErrNotFound, "upload not found or expired")
+	case -2:
+		_ = s.storage.Delete(ctx, chunkPath)
+		return nil, errors.New(errors.ErrInvalidParams, "upload size exceeds limit")
+	}
+	if oldPath, _ := result[1].(string); oldPath != "" {
+		_ = s.storage.Delete(ctx, oldPath)
+	}
+
+	return s.GetUploadStatus(ctx, id, userID)
+}
+
+// GetUploadStatus 获取分片上传进度
+func (s *fileService) GetUploadStatus(ctx context.Context, id string, userID uint) (*UploadStatus, error) {
+	meta, err := s.loadChunkUpload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := cache.HGetAll(ctx, chunkUploadKey(id, "chunks"))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	indexes := make([]int, 0, len(chunks))
+	for field := range chunks {
+		if index, err := strconv.Atoi(field); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	return &UploadStatus{
+		UploadID:      id,
+		Filename:      meta.filename,
+		ReceivedBytes: meta.received,
+		TotalBytes:    meta.totalSize,
+		TotalChunks:   meta.totalChunks,
+		Chunks:        indexes,
+		ExpiresAt:     meta.expiresAt,
+	}, nil
+}
+
+// CompleteChunkUpload 合并全部分片并创建文件记录，成功后清理分片与进度
+func (s *fileService) CompleteChunkUpload(ctx context.Context, id string, userID uint) (*FileResponse, error) {
+	meta, err := s.loadChunkUpload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 防止同一上传被并发合并
+	lockKey := chunkUploadKey(id, "completing")
+	locked, err := cache.SetNX(ctx, lockKey, 1, chunkUploadLockTTL)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if !locked {
+		return nil, errors.New(errors.ErrConflict, "upload is being completed")
+	}
+	defer func() { _ = cache.Del(context.WithoutCancel(ctx), lockKey) }()
+
+	paths, err := cache.HGetAll(ctx, chunkUploadKey(id, "paths"))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	count := meta.totalChunks
+	if count == 0 {
+		for field := range paths {
+			if index, err := strconv.Atoi(field); err == nil && index+1 > count {
+				count = index + 1
+			}
+		}
+	}
+	if count == 0 {
+		return nil, errors.New(errors.ErrInvalidParams, "no chunks uploaded")
+	}
+	var missing []int
+	for i := 0; i < count; i++ {
+		if _, ok := paths[strconv.Itoa(i)]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.NewWithDetails(errors.ErrInvalidParams, "upload is incomplete", map[string]interface{}{
+			"missing_chunks": missing,
+		})
+	}
+	if meta.totalSize > 0 && meta.received != meta.totalSize {
+		return nil, errors.New(errors.ErrInvalidParams,
+			fmt.Sprintf("received %d bytes, expected %d", meta.received, meta.totalSize))
+	}
+	if err := s.validateUpload(meta.filename, meta.mimeType, meta.received); err != nil {
+		return nil, err
+	}
+
+	// 按序拼接到临时文件，再走普通上传的保存流程（秒传、压缩、缩略图）
+	tmp, err := os.CreateTemp(s.config.TempDir, "nova_chunk_*")
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	var size int64
+	for i := 0; i < count; i++ {
+		n, err := s.appendChunk(ctx, tmp, paths[strconv.Itoa(i)])
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInternalServer, fmt.Errorf("failed to assemble chunk %d: %w", i, err))
+		}
+		size += n
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	resp, err := s.store(ctx, tmp, meta.filename, meta.mimeType, size, meta.category, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.removeChunkUpload(ctx, id, paths)
+	return resp, nil
+}
+
+// appendChunk 将存储中的分片追加写入目标文件
+func (s *fileService) appendChunk(ctx context.Context, dst io.Writer, chunkPath string) (int64, error) {
+	reader, err := s.storage.Download(ctx, chunkPath)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return io.Copy(dst, reader)
+}
+
+// removeChunkUpload 删除分片文件与全部进度键
+func (s *fileService) removeChunkUpload(ctx context.Context, id string, paths map[string]string) {
+	for _, p := range paths {
+		if err := s.storage.Delete(ctx, p); err != nil {
+			logger.Warn("failed to delete upload chunk", "upload_id", id, "path", p, "error", err)
+		}
+	}
+	_ = cache.Del(ctx, chunkUploadKey(id), chunkUploadKey(id, "chunks"), chunkUploadKey(id, "paths"))
+	_ = cache.ZRem(ctx, chunkUploadKey(chunkUploadActiveKey), id)
+}
+
+// sweepExpiredUploads 清理已过期上传遗留的分片文件
+// 进度键由 Redis TTL 自动过期，分片路径多保留一个 TTL，在此按路径删除分片文件
+func (s *fileService) sweepExpiredUploads(ctx context.Context) {
+	ids, err := cache.ZRangeByScore(ctx, chunkUploadKey(chunkUploadActiveKey), "-inf",
+		strconv.FormatInt(time.Now().Unix(), 10), chunkUploadSweepLimit)
+	if err != nil {
+		logger.Warn("failed to list expired uploads", "error", err)
+		return
+	}
+	for _, id := range ids {
+		paths, err := cache.HGetAll(ctx, chunkUploadKey(id, "paths"))
+		if err != nil {
+			continue
+		}
+		s.removeChunkUpload(ctx, id, paths)
+	}
+}
+
+// chunkUploadMeta 分片上传元数据
+type chunkUploadMeta struct {
+	filename    string
+	mimeType    string
+	category    string
+	totalSize   int64
+	totalChunks int
+	received    int64
+	expiresAt   time.Time
+}
+
+// loadChunkUpload 读取分片上传元数据并校验归属
+func (s *fileService) loadChunkUpload(ctx context.Context, id string, userID uint) (*chunkUploadMeta, error) {
+	values, err := cache.HGetAll(ctx, chunkUploadKey(id))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if len(values) == 0 {
+		return nil, errors.New(errors.ErrNotFound, "upload not found or expired")
+	}
+	if values["user_id"] != strconv.FormatUint(uint64(userID), 10) {
+		return nil, errors.New(errors.ErrNotFound, "upload not found or expired")
+	}
+
+	meta := &chunkUploadMeta{
+		filename: values["filename"],
+		mimeType: values["mime_type"],
+		category: values["category"],
+	}
+	meta.totalSize, _ = strconv.ParseInt(values["total_size"], 10, 64)
+	meta.totalChunks, _ = strconv.Atoi(values["total_chunks"])
+	meta.received, _ = strconv.ParseInt(values["received"], 10, 64)
+	expiresAt, _ := strconv.ParseInt(values["expires_at"], 10, 64)
+	meta.expiresAt = time.Unix(expiresAt, 0)
+	return meta, nil
+}
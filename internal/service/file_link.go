@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/errors"
+)
+
+// publicLinkRevokedPrefix 已撤销的公开链接（键为令牌摘要，有效期与链接剩余时间一致）
+const publicLinkRevokedPrefix = "file:public_link:revoked"
+
+// errPublicLinkInvalid 签名错误、已过期与已撤销统一返回 404，不向访问者区分原因
+var errPublicLinkInvalid = errors.New(errors.ErrNotFound, "link is invalid or expired")
+
+// CreatePublicLink 为文件生成公开分享令牌（仅上传者可操作）
+// 令牌格式为 base64url(文件ID:过期时间戳).base64url(HMAC-SHA256)，服务端不保存令牌本身
+func (s *fileService) CreatePublicLink(ctx context.Context, fileID uint, expiry time.Duration, ownerID uint) (string, error) {
+	if !s.config.PublicLinkEnabled {
+		return "", errors.New(errors.ErrServiceDisabled, "public link service disabled")
+	}
+	maxTTL := time.Duration(s.config.PublicLinkMaxTTL) * time.Second
+	if expiry <= 0 || expiry > maxTTL {
+		return "", errors.New(errors.ErrInvalidParams, fmt.Sprintf("expiry must be between 1 and %d seconds", s.config.PublicLinkMaxTTL))
+	}
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		return "", errors.New(errors.ErrRecordNotFound, "file not found")
+	}
+	if file.UploadedBy != ownerID {
+		return "", errors.New(errors.ErrForbidden, "no permission to share this file")
+	}
+
+	expiresAt := time.Now().Add(expiry).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", file.ID, expiresAt)))
+	return payload + "." + s.signPublicLink(payload), nil
+}
+
+// RevokePublicLink 撤销公开分享令牌（仅文件上传者可操作），撤销标记保留到链接原定过期时间
+func (s *fileService) RevokePublicLink(ctx context.Context, token string, ownerID uint) error {
+	fileID, expiresAt, err := s.parsePublicLink(token)
+	if err != nil {
+		return err
+	}
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		return errors.New(errors.ErrRecordNotFound, "file not found")
+	}
+	if file.UploadedBy != ownerID {
+		return errors.New(errors.ErrForbidden, "no permission to revoke this link")
+	}
+
+	if err := cache.Set(ctx, publicLinkRevokedKey(token), 1, time.Until(expiresAt)); err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return nil
+}
+
+// OpenPublicLink 校验公开分享令牌（签名、过期时间、撤销状态）并返回文件内容
+// 与 Download 一样返回存储中的原始内容，压缩存储的文件由调用方处理
+func (s *fileService) OpenPublicLink(ctx context.Context, token string) (io.ReadCloser, *model.File, error) {
+	if !s.config.PublicLinkEnabled {
+		return nil, nil, errors.New(errors.ErrServiceDisabled, "public link service disabled")
+	}
+
+	fileID, _, err := s.parsePublicLink(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked, err := cache.Exists(ctx, publicLinkRevokedKey(token))
+	if err != nil {
+		// 无法确认撤销状态时拒绝访问
+		return nil, nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if revoked > 0 {
+		return nil, nil, errPublicLinkInvalid
+	}
+
+	// 文件已删除时链接随之失效
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		return nil, nil, errPublicLinkInvalid
+	}
+
+	reader, err := s.storage.Download(ctx, file.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return reader, file, nil
+}
+
+// parsePublicLink 校验签名与过期时间，返回文件ID与过期时间
+func (s *fileService) parsePublicLink(token string) (uint, time.Time, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signPublicLink(payload))) {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+	idPart, expPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+	fileID, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+	exp, err := strconv.ParseInt(expPart, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+
+	expiresAt := time.Unix(exp, 0)
+	if !time.Now().Before(expiresAt) {
+		return 0, time.Time{}, errPublicLinkInvalid
+	}
+	return uint(fileID), expiresAt, nil
+}
+
+// signPublicLink 计算令牌载荷的 HMAC-SHA256 签名
+func (s *fileService) signPublicLink(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.PublicLinkSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// publicLinkRevokedKey 撤销标记键，以令牌摘要代替令牌原文
+func publicLinkRevokedKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%s:%s", publicLinkRevokedPrefix, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/storage"
+)
+
+// objectPathPrefix 内容寻址存储的对象目录
+const objectPathPrefix = "objects"
+
+// objectPath 内容寻址的对象路径：objects/ab/cd/<hash>，压缩存储的对象附加 .gz 后缀，
+// 同一内容的压缩与未压缩版本互不覆盖
+func objectPath(hash string, compressed bool) string {
+	name := hash
+	if compressed {
+		name += "." + storage.EncodingGzip
+	}
+	return filepath.Join(objectPathPrefix, hash[:2], hash[2:4], name)
+}
+
+// isObjectPath 判断物理路径是否为内容寻址对象（关闭 content_addressed 后旧对象仍按引用计数删除）
+func isObjectPath(path string) bool {
+	return strings.HasPrefix(path, objectPathPrefix+string(filepath.Separator))
+}
+
+// objectLockKey 同一对象的记录创建与删除互斥
+func objectLockKey(path string) string {
+	return "file_object:" + path
+}
+
+// putObject 写入物理文件，返回访问 URL
+// 内容寻址的对象已存在时直接复用（内容相同，覆盖写入会截断正在被读取的文件）
+func (s *fileService) putObject(ctx context.Context, file multipart.File, savedName, path string, compress bool) (string, error) {
+	if isObjectPath(path) {
+		exists, err := s.storage.Exists(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return s.storage.GetURL(path), nil
+		}
+	}
+
+	if compress {
+		return s.uploadCompressed(ctx, file, savedName, path)
+	}
+	return s.storage.Upload(ctx, file, savedName, path)
+}
+
+// createFileRecord 保存文件记录
+// 内容寻址对象在锁内创建记录后确认对象仍然存在：若对象恰好被最后一个引用的删除清理，则重新写入
+func (s *fileService) createFileRecord(ctx context.Context, fileModel *model.File, file multipart.File) error {
+	if !isObjectPath(fileModel.Path) {
+		if err := s.fileRepo.Create(ctx, fileModel); err != nil {
+			return errors.Wrap(errors.ErrDatabase, err)
+		}
+		return nil
+	}
+
+	lock, err := s.locks.AcquireLock(ctx, objectLockKey(fileModel.Path))
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	defer lock.Release(context.Background())
+
+	if err := s.fileRepo.Create(ctx, fileModel); err != nil {
+		return errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	exists, err := s.storage.Exists(ctx, fileModel.Path)
+	if err == nil && !exists {
+		if _, err = file.Seek(0, 0); err == nil {
+			_, err = s.putObject(ctx, file, fileModel.SavedName, fileModel.Path, fileModel.ContentEncoding == storage.EncodingGzip)
+		}
+	}
+	if err != nil {
+		_ = s.fileRepo.Delete(ctx, fileModel.ID)
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return nil
+}
+
+// releaseObject 文件记录删除后，若已没有正常记录引用该对象，删除对象及其缩略图
+// 清理失败只记录日志，不影响删除结果（遗留对象可由定时任务按引用计数清理）
+func (s *fileService) releaseObject(ctx context.Context, file *model.File) {
+	if !isObjectPath(file.Path) {
+		return
+	}
+
+	lock, err := s.locks.AcquireLock(ctx, objectLockKey(file.Path))
+	if err != nil {
+		logger.Warn("failed to lock file object for cleanup", "path", file.Path, "error", err)
+		return
+	}
+	defer lock.Release(context.Background())
+
+	refs, err := s.fileRepo.CountByPath(ctx, file.Path)
+	if err != nil {
+		logger.Warn("failed to count file object references", "path", file.Path, "error", err)
+		return
+	}
+	if refs > 0 {
+		return
+	}
+
+	if err := s.storage.Delete(ctx, file.Path); err != nil {
+		logger.Warn("failed to delete file object", "path", file.Path, "error", err)
+		return
+	}
+	if file.ThumbnailPath != "" {
+		if err := s.storage.Delete(ctx, file.ThumbnailPath); err != nil {
+			logger.Warn("failed to delete file object thumbnail", "path", file.ThumbnailPath, "error", err)
+		}
+	}
+}
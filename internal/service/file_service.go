@@ -6,23 +6,32 @@ import (
 	"encoding/hex"
 	"fmt"
 	"image"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/cache"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
 	"github.com/cccvno1/nova/pkg/storage"
 	"github.com/google/uuid"
 	"github.com/nfnt/resize"
+	"golang.org/x/sync/errgroup"
 )
 
 // FileService 文件服务接口
@@ -31,23 +40,68 @@ type FileService interface {
 	Download(ctx context.Context, id uint, userID uint) (io.ReadCloser, *model.File, error)
 	Delete(ctx context.Context, id uint, userID uint) error
 	GetByID(ctx context.Context, id uint) (*FileResponse, error)
-	List(ctx context.Context, userID uint, category string, pagination *database.Pagination) ([]FileResponse, error)
+	GetByIDWithDeleted(ctx context.Context, id uint) (*FileResponse, error)
+	GetByIDWithUploader(ctx context.Context, id uint) (*FileResponse, error)
+	GetByIDs(ctx context.Context, ids []uint, userID uint, all bool) ([]FileResponse, error)
+	List(ctx context.Context, userID uint, opts FileListOptions, pagination *database.Pagination) ([]FileResponse, int64, error)
+	UpdateTags(ctx context.Context, id uint, userID uint, tags []string, op TagOperation) (*FileResponse, error)
 	Search(ctx context.Context, keyword string, pagination *database.Pagination) ([]FileResponse, error)
 	GetUserStorageInfo(ctx context.Context, userID uint) (*StorageInfo, error)
+	RegenerateThumbnails(ctx context.Context, filter FileFilter) (int, error)
+
+	// 公开分享链接
+	CreatePublicLink(ctx context.Context, fileID uint, expiry time.Duration, ownerID uint) (token string, err error)
+	RevokePublicLink(ctx context.Context, token string, ownerID uint) error
+	OpenPublicLink(ctx context.Context, token string) (io.ReadCloser, *model.File, error)
+
+	// 分片上传
+	InitChunkUpload(ctx context.Context, req InitChunkUploadRequest, userID uint) (*UploadStatus, error)
+	UploadChunk(ctx context.Context, id string, index int, chunk multipart.File, size int64, userID uint) (*UploadStatus, error)
+	GetUploadStatus(ctx context.Context, id string, userID uint) (*UploadStatus, error)
+	CompleteChunkUpload(ctx context.Context, id string, userID uint) (*FileResponse, error)
+}
+
+// FileListOptions 文件列表筛选与排序参数
+type FileListOptions struct {
+	Category string // 分类，为空表示全部分类
+	Tag      string // 标签，为空表示不过滤
+	OrderBy  string // 排序字段：size、created_at，为空按上传顺序
+	Order    string // 排序方向：asc、desc（默认 desc）
+}
+
+// fileListOrderColumns 文件列表允许排序的字段（参数值 -> 列名）
+var fileListOrderColumns = map[string]string{
+	"size":       "size",
+	"created_at": "created_at",
+}
+
+// FileFilter 批量处理文件的筛选条件
+type FileFilter struct {
+	UserID   uint   `json:"user_id" query:"user_id"`   // 上传用户ID，0 表示全部用户
+	Category string `json:"category" query:"category"` // 文件分类，为空表示全部分类
+	Force    bool   `json:"force" query:"force"`       // 为 true 时忽略配置指纹，全部重新生成
 }
 
 type fileService struct {
-	fileRepo repository.FileRepository
-	storage  storage.Storage
-	config   *config.UploadConfig
+	fileRepo       repository.FileRepository
+	storage        storage.Storage
+	config         *config.UploadConfig
+	thumbnailSlots chan struct{}       // 缩略图生成信号量（全局限制并发解码/缩放）
+	locks          *cache.CacheManager // 内容寻址对象的引用计数锁
 }
 
 // NewFileService 创建文件服务
 func NewFileService(fileRepo repository.FileRepository, storage storage.Storage, cfg *config.UploadConfig) FileService {
+	workers := cfg.ThumbnailWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
 	return &fileService{
-		fileRepo: fileRepo,
-		storage:  storage,
-		config:   cfg,
+		fileRepo:       fileRepo,
+		storage:        storage,
+		config:         cfg,
+		thumbnailSlots: make(chan struct{}, workers),
+		locks:          cache.NewCacheManager("file"),
 	}
 }
 
@@ -65,14 +119,47 @@ type FileResponse struct {
 	UploadedBy   uint      `json:"uploaded_by"`
 	Width        int       `json:"width,omitempty"`
 	Height       int       `json:"height,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	Uploader *FileUploader `json:"uploader,omitempty"` // 上传者信息（?expand=uploader 时返回）
 }
 
+// FileUploader 文件上传者信息
+type FileUploader struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"` // 上传者账号已删除
+}
+
+// TagOperation 标签操作类型
+type TagOperation string
+
+const (
+	TagOperationSet    TagOperation = "set"    // 整体替换
+	TagOperationAdd    TagOperation = "add"    // 追加
+	TagOperationRemove TagOperation = "remove" // 移除
+)
+
 // StorageInfo 存储信息
 type StorageInfo struct {
-	FileCount int64 `json:"file_count"` // 文件数量
-	TotalSize int64 `json:"total_size"` // 总大小（字节）
-	UsedMB    int64 `json:"used_mb"`    // 已使用空间（MB）
+	FileCount int64  `json:"file_count"`          // 文件数量
+	TotalSize int64  `json:"total_size"`          // 总大小（字节）
+	UsedMB    int64  `json:"used_mb"`             // 已使用空间（MB）
+	Quota     int64  `json:"quota"`               // 总配额（字节），0 表示不限制
+	Remaining *int64 `json:"remaining,omitempty"` // 剩余总配额（字节），不限制时省略
+
+	Categories []CategoryStorageInfo `json:"categories"` // 按分类的使用量与配额
+}
+
+// CategoryStorageInfo 单个分类的存储信息
+type CategoryStorageInfo struct {
+	Category  string `json:"category"`            // 分类
+	Used      int64  `json:"used"`                // 已使用空间（字节）
+	Quota     int64  `json:"quota"`               // 分类配额（字节），0 表示不限制
+	Remaining *int64 `json:"remaining,omitempty"` // 剩余分类配额（字节），不限制时省略
 }
 
 // Upload 上传文件
@@ -82,6 +169,9 @@ func (s *fileService) Upload(ctx context.Context, fileHeader *multipart.FileHead
 		return nil, err
 	}
 
+	// 原始文件名会回显到下载响应头，先清洗控制字符与路径并限制长度
+	originalName := sanitizeFilename(fileHeader.Filename, s.config.MaxFilenameLength)
+
 	// 2. 打开文件
 	file, err := fileHeader.Open()
 	if err != nil {
@@ -89,6 +179,19 @@ func (s *fileService) Upload(ctx context.Context, fileHeader *multipart.FileHead
 	}
 	defer file.Close()
 
+	// 按 upload.max_upload_bps 限制读取速率（每个上传单独计速）
+	throttled := newThrottledFile(ctx, file, s.config.MaxUploadBPS)
+
+	return s.store(ctx, throttled, originalName, fileHeader.Header.Get("Content-Type"), fileHeader.Size, category, userID)
+}
+
+// store 保存已通过校验的文件内容并创建文件记录（普通上传与分片合并共用）
+func (s *fileService) store(ctx context.Context, file multipart.File, originalName, mimeType string, size int64, category string, userID uint) (*FileResponse, error) {
+	// 检查总配额与分类配额
+	if err := s.checkQuota(ctx, userID, category, size); err != nil {
+		return nil, err
+	}
+
 	// 3. 计算文件 Hash（用于秒传）
 	hash, err := s.calculateHash(file)
 	if err != nil {
@@ -100,70 +203,83 @@ func (s *fileService) Upload(ctx context.Context, fileHeader *multipart.FileHead
 	if err == nil && existingFile != nil {
 		// 文件已存在，创建新的元数据记录（引用相同的物理文件）
 		newFile := &model.File{
-			OriginalName:  fileHeader.Filename,
-			SavedName:     existingFile.SavedName,
-			Path:          existingFile.Path,
-			URL:           existingFile.URL,
-			Size:          existingFile.Size,
-			MimeType:      existingFile.MimeType,
-			Extension:     existingFile.Extension,
-			Hash:          hash,
-			StorageType:   existingFile.StorageType,
-			Category:      category,
-			UploadedBy:    userID,
-			Status:        model.FileStatusNormal,
-			ThumbnailPath: existingFile.ThumbnailPath,
-			ThumbnailURL:  existingFile.ThumbnailURL,
-			Width:         existingFile.Width,
-			Height:        existingFile.Height,
+			OriginalName:    originalName,
+			SavedName:       existingFile.SavedName,
+			Path:            existingFile.Path,
+			URL:             existingFile.URL,
+			Size:            existingFile.Size,
+			MimeType:        existingFile.MimeType,
+			Extension:       existingFile.Extension,
+			Hash:            hash,
+			StorageType:     existingFile.StorageType,
+			ContentEncoding: existingFile.ContentEncoding,
+			Category:        category,
+			UploadedBy:      userID,
+			Status:          model.FileStatusNormal,
+			ThumbnailPath:   existingFile.ThumbnailPath,
+			ThumbnailURL:    existingFile.ThumbnailURL,
+			ThumbnailSpec:   existingFile.ThumbnailSpec,
+			Width:           existingFile.Width,
+			Height:          existingFile.Height,
 		}
 
-		if err := s.fileRepo.Create(ctx, newFile); err != nil {
-			return nil, errors.Wrap(errors.ErrDatabase, err)
+		if err := s.createFileRecord(ctx, newFile, file); err != nil {
+			return nil, err
 		}
 
 		return s.toResponse(newFile), nil
 	}
 
 	// 5. 生成保存文件名
-	ext := filepath.Ext(fileHeader.Filename)
+	ext := filepath.Ext(originalName)
 	savedName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 
-	// 6. 构建存储路径（按日期分目录）
-	now := time.Now()
-	relativePath := filepath.Join(
-		category,
-		now.Format("2006"),
-		now.Format("01"),
-		now.Format("02"),
-		savedName,
-	)
+	// 6. 构建存储路径：内容寻址时由 Hash 决定，否则按日期分目录
+	compress := s.shouldCompress(mimeType)
+	var relativePath string
+	if s.config.ContentAddressed {
+		relativePath = objectPath(hash, compress)
+	} else {
+		now := time.Now()
+		relativePath = filepath.Join(
+			category,
+			now.Format("2006"),
+			now.Format("01"),
+			now.Format("02"),
+			savedName,
+		)
+	}
 
 	// 7. 重置文件指针
 	if _, err := file.Seek(0, 0); err != nil {
 		return nil, errors.Wrap(errors.ErrInternalServer, fmt.Errorf("failed to seek file: %w", err))
 	}
 
-	// 8. 上传到存储
-	url, err := s.storage.Upload(ctx, file, savedName, relativePath)
+	// 8. 上传到存储（匹配压缩类型的文件先 gzip 压缩）
+	var contentEncoding string
+	if compress {
+		contentEncoding = storage.EncodingGzip
+	}
+	url, err := s.putObject(ctx, file, savedName, relativePath, compress)
 	if err != nil {
 		return nil, errors.Wrap(errors.ErrInternalServer, fmt.Errorf("failed to upload file: %w", err))
 	}
 
 	// 9. 创建文件记录
 	fileModel := &model.File{
-		OriginalName: fileHeader.Filename,
-		SavedName:    savedName,
-		Path:         relativePath,
-		URL:          url,
-		Size:         fileHeader.Size,
-		MimeType:     fileHeader.Header.Get("Content-Type"),
-		Extension:    ext,
-		Hash:         hash,
-		StorageType:  s.config.StorageType,
-		Category:     category,
-		UploadedBy:   userID,
-		Status:       model.FileStatusNormal,
+		OriginalName:    originalName,
+		SavedName:       savedName,
+		Path:            relativePath,
+		URL:             url,
+		Size:            size,
+		MimeType:        mimeType,
+		Extension:       ext,
+		Hash:            hash,
+		StorageType:     s.config.StorageType,
+		ContentEncoding: contentEncoding,
+		Category:        category,
+		UploadedBy:      userID,
+		Status:          model.FileStatusNormal,
 	}
 
 	// 10. 如果是图片，处理缩略图和获取尺寸
@@ -175,16 +291,21 @@ func (s *fileService) Upload(ctx context.Context, fileHeader *multipart.FileHead
 	}
 
 	// 11. 保存到数据库
-	if err := s.fileRepo.Create(ctx, fileModel); err != nil {
-		// 数据库保存失败，删除已上传的文件
-		_ = s.storage.Delete(ctx, relativePath)
-		return nil, errors.Wrap(errors.ErrDatabase, err)
+	if err := s.createFileRecord(ctx, fileModel, file); err != nil {
+		// 数据库保存失败，删除已上传的文件（内容寻址对象仅在无其他引用时删除）
+		if isObjectPath(relativePath) {
+			s.releaseObject(ctx, fileModel)
+		} else {
+			_ = s.storage.Delete(ctx, relativePath)
+		}
+		return nil, err
 	}
 
 	return s.toResponse(fileModel), nil
 }
 
 // Download 下载文件
+// 返回存储中的原始内容，压缩存储的文件（file.ContentEncoding 为 gzip）需由调用方决定是否解压
 func (s *fileService) Download(ctx context.Context, id uint, userID uint) (io.ReadCloser, *model.File, error) {
 	// 查询文件信息
 	file, err := s.fileRepo.FindByID(ctx, id)
@@ -226,12 +347,75 @@ func (s *fileService) Delete(ctx context.Context, id uint, userID uint) error {
 		return errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	// 注意：这里不立即删除物理文件，因为可能有其他记录引用（秒传）
-	// 可以通过定时任务清理没有引用的文件
+	// 按日期存储的物理文件可能被秒传记录引用，不立即删除，可通过定时任务清理没有引用的文件；
+	// 内容寻址对象在最后一个引用删除后随即删除
+	s.releaseObject(ctx, file)
 
 	return nil
 }
 
+// UpdateTags 更新文件标签（仅上传者可操作）
+// 标签去除首尾空白并去重，操作后数量不能超过 model.MaxFileTags
+func (s *fileService) UpdateTags(ctx context.Context, id uint, userID uint, tags []string, op TagOperation) (*FileResponse, error) {
+	file, err := s.fileRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrRecordNotFound, "file not found")
+	}
+
+	if file.UploadedBy != userID {
+		return nil, errors.New(errors.ErrForbidden, "no permission to modify this file")
+	}
+
+	var result []string
+	switch op {
+	case TagOperationSet:
+		result = normalizeTags(tags)
+	case TagOperationAdd:
+		result = normalizeTags(append(append([]string{}, file.Tags...), tags...))
+	case TagOperationRemove:
+		remove := make(map[string]bool, len(tags))
+		for _, tag := range normalizeTags(tags) {
+			remove[tag] = true
+		}
+		for _, tag := range file.Tags {
+			if !remove[tag] {
+				result = append(result, tag)
+			}
+		}
+	default:
+		return nil, errors.New(errors.ErrInvalidParams, "invalid tag operation")
+	}
+
+	if len(result) > model.MaxFileTags {
+		return nil, errors.New(errors.ErrInvalidParams, fmt.Sprintf("too many tags, max %d", model.MaxFileTags))
+	}
+	if result == nil {
+		result = []string{}
+	}
+
+	if err := s.fileRepo.UpdateTags(ctx, id, result); err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	file.Tags = result
+	return s.toResponse(file), nil
+}
+
+// normalizeTags 去除空白、空标签与重复标签（保持原有顺序）
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
 // GetByID 根据 ID 获取文件信息
 func (s *fileService) GetByID(ctx context.Context, id uint) (*FileResponse, error) {
 	file, err := s.fileRepo.FindByID(ctx, id)
@@ -242,27 +426,106 @@ func (s *fileService) GetByID(ctx context.Context, id uint) (*FileResponse, erro
 	return s.toResponse(file), nil
 }
 
-// List 获取文件列表
-func (s *fileService) List(ctx context.Context, userID uint, category string, pagination *database.Pagination) ([]FileResponse, error) {
-	var files []model.File
-	var err error
+// GetByIDWithDeleted 根据 ID 获取文件信息（包含已删除的文件，供管理员使用）
+func (s *fileService) GetByIDWithDeleted(ctx context.Context, id uint) (*FileResponse, error) {
+	file, err := s.fileRepo.FindByIDUnscoped(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrRecordNotFound, "file not found")
+	}
 
-	if category != "" {
-		files, err = s.fileRepo.ListByUserAndCategory(ctx, userID, category, pagination)
-	} else {
-		files, err = s.fileRepo.ListByUser(ctx, userID, pagination)
+	return s.toResponse(file), nil
+}
+
+// GetByIDWithUploader 根据 ID 获取文件信息，并附带上传者信息
+// 上传者账号已删除时返回 deleted=true，记录已不存在时仅返回上传者ID
+func (s *fileService) GetByIDWithUploader(ctx context.Context, id uint) (*FileResponse, error) {
+	file, err := s.fileRepo.FindByIDWithUploader(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrRecordNotFound, "file not found")
 	}
 
+	resp := s.toResponse(file)
+	resp.Uploader = &FileUploader{ID: file.UploadedBy, Deleted: true}
+	if file.Uploader != nil {
+		resp.Uploader = &FileUploader{
+			ID:       file.Uploader.ID,
+			Username: file.Uploader.Username,
+			Nickname: file.Uploader.Nickname,
+			Avatar:   file.Uploader.Avatar,
+			Deleted:  file.Uploader.DeletedAt.Valid,
+		}
+	}
+
+	return resp, nil
+}
+
+// GetByIDs 批量获取文件信息
+// 单次查询取回全部记录，按 ids 的顺序返回；不存在、已删除或无权访问的文件直接省略。
+// all 为 true（管理员）时不校验上传者
+func (s *fileService) GetByIDs(ctx context.Context, ids []uint, userID uint, all bool) ([]FileResponse, error) {
+	files, err := s.fileRepo.ListByIDs(ctx, ids)
 	if err != nil {
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
+	byID := make(map[uint]*model.File, len(files))
+	for i := range files {
+		if all || files[i].UploadedBy == userID {
+			byID[files[i].ID] = &files[i]
+		}
+	}
+
+	result := make([]FileResponse, 0, len(byID))
+	for _, id := range ids {
+		if file, ok := byID[id]; ok {
+			result = append(result, *s.toResponse(file))
+			delete(byID, id) // 重复的ID只返回一次
+		}
+	}
+
+	return result, nil
+}
+
+// List 获取文件列表
+// 同时返回筛选条件下全部文件（不仅当前页）的总大小
+func (s *fileService) List(ctx context.Context, userID uint, opts FileListOptions, pagination *database.Pagination) ([]FileResponse, int64, error) {
+	filter := repository.FileListFilter{
+		UserID:   userID,
+		Category: opts.Category,
+		Tag:      opts.Tag,
+		Desc:     true,
+	}
+	if opts.OrderBy != "" {
+		column, ok := fileListOrderColumns[opts.OrderBy]
+		if !ok {
+			return nil, 0, errors.New(errors.ErrInvalidParams, fmt.Sprintf("invalid order_by: %s", opts.OrderBy))
+		}
+		filter.OrderBy = column
+	}
+	switch strings.ToLower(opts.Order) {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return nil, 0, errors.New(errors.ErrInvalidParams, fmt.Sprintf("invalid order: %s", opts.Order))
+	}
+
+	files, err := s.fileRepo.ListFiltered(ctx, filter, pagination)
+	if err != nil {
+		return nil, 0, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	totalSize, err := s.fileRepo.SumSize(ctx, filter)
+	if err != nil {
+		return nil, 0, errors.Wrap(errors.ErrDatabase, err)
+	}
+
 	result := make([]FileResponse, len(files))
 	for i, file := range files {
 		result[i] = *s.toResponse(&file)
 	}
 
-	return result, nil
+	return result, totalSize, nil
 }
 
 // Search 搜索文件
@@ -292,23 +555,94 @@ func (s *fileService) GetUserStorageInfo(ctx context.Context, userID uint) (*Sto
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	return &StorageInfo{
-		FileCount: count,
-		TotalSize: totalSize,
-		UsedMB:    totalSize / 1024 / 1024,
-	}, nil
+	usage, err := s.fileRepo.GetUserStorageUsageByCategory(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	// 列出已配置配额的分类和已有文件的分类
+	categories := make([]string, 0, len(usage)+len(s.config.CategoryQuotas))
+	for category := range usage {
+		categories = append(categories, category)
+	}
+	for category := range s.config.CategoryQuotas {
+		if _, ok := usage[category]; !ok {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	info := &StorageInfo{
+		FileCount:  count,
+		TotalSize:  totalSize,
+		UsedMB:     totalSize / 1024 / 1024,
+		Quota:      s.config.UserQuota * 1024 * 1024,
+		Categories: make([]CategoryStorageInfo, 0, len(categories)),
+	}
+	info.Remaining = remainingQuota(info.Quota, totalSize)
+	for _, category := range categories {
+		quota := s.config.CategoryQuotaFor(category) * 1024 * 1024
+		info.Categories = append(info.Categories, CategoryStorageInfo{
+			Category:  category,
+			Used:      usage[category],
+			Quota:     quota,
+			Remaining: remainingQuota(quota, usage[category]),
+		})
+	}
+
+	return info, nil
+}
+
+// checkQuota 检查保存 size 字节后是否超出用户总配额或分类配额
+// 用量与写入之间不加锁，并发上传时可能略微超出配额
+func (s *fileService) checkQuota(ctx context.Context, userID uint, category string, size int64) error {
+	if s.config.UserQuota > 0 {
+		used, err := s.fileRepo.GetUserStorageUsage(ctx, userID)
+		if err != nil {
+			return errors.Wrap(errors.ErrDatabase, err)
+		}
+		if used+size > s.config.UserQuota*1024*1024 {
+			return errors.New(errors.ErrForbidden, fmt.Sprintf("storage quota exceeded: %dMB", s.config.UserQuota))
+		}
+	}
+
+	if quota := s.config.CategoryQuotaFor(category); quota > 0 {
+		used, err := s.fileRepo.SumSize(ctx, repository.FileListFilter{UserID: userID, Category: category})
+		if err != nil {
+			return errors.Wrap(errors.ErrDatabase, err)
+		}
+		if used+size > quota*1024*1024 {
+			return errors.New(errors.ErrForbidden, fmt.Sprintf("storage quota for category %s exceeded: %dMB", category, quota))
+		}
+	}
+
+	return nil
+}
+
+// remainingQuota 计算剩余配额，quota 为 0（不限制）时返回 nil
+func remainingQuota(quota, used int64) *int64 {
+	if quota <= 0 {
+		return nil
+	}
+	remaining := max(quota-used, 0)
+	return &remaining
 }
 
 // validateFile 验证文件
 func (s *fileService) validateFile(fileHeader *multipart.FileHeader) error {
+	return s.validateUpload(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), fileHeader.Size)
+}
+
+// validateUpload 按文件名、MIME 类型与大小校验上传
+func (s *fileService) validateUpload(filename, mimeType string, size int64) error {
 	// 1. 检查文件大小
 	maxSize := s.config.MaxSize * 1024 * 1024 // 转换为字节
-	if fileHeader.Size > maxSize {
+	if size > maxSize {
 		return errors.New(errors.ErrInvalidParams, fmt.Sprintf("file size exceeds limit: %dMB", s.config.MaxSize))
 	}
 
 	// 2. 检查文件扩展名
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	ext := strings.ToLower(filepath.Ext(filename))
 	if len(s.config.AllowedExts) > 0 {
 		allowed := false
 		for _, allowedExt := range s.config.AllowedExts {
@@ -323,7 +657,6 @@ func (s *fileService) validateFile(fileHeader *multipart.FileHeader) error {
 	}
 
 	// 3. 检查 MIME 类型
-	mimeType := fileHeader.Header.Get("Content-Type")
 	if len(s.config.AllowedTypes) > 0 {
 		allowed := false
 		for _, allowedType := range s.config.AllowedTypes {
@@ -340,6 +673,39 @@ func (s *fileService) validateFile(fileHeader *multipart.FileHeader) error {
 	return nil
 }
 
+// sanitizeFilename 清洗上传的原始文件名
+// 去除路径部分与控制字符（含 \r\n，防止响应头注入），按字符数截断到 maxLen 并尽量保留扩展名，
+// 清洗后为空时返回 "file"
+func sanitizeFilename(name string, maxLen int) string {
+	// 兼容 Windows 客户端上传的完整路径
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+
+	if maxLen > 0 && utf8.RuneCountInString(name) > maxLen {
+		ext := filepath.Ext(name)
+		extLen := utf8.RuneCountInString(ext)
+		if extLen >= maxLen {
+			ext, extLen = "", 0
+		}
+		base := []rune(strings.TrimSuffix(name, ext))
+		name = string(base[:maxLen-extLen]) + ext
+	}
+
+	return name
+}
+
 // calculateHash 计算文件 Hash（SHA256）
 func (s *fileService) calculateHash(file multipart.File) (string, error) {
 	hasher := sha256.New()
@@ -361,6 +727,21 @@ func (s *fileService) processImage(ctx context.Context, file multipart.File, fil
 		return err
 	}
 
+	// 未启用缩略图时只读取图片头获取尺寸，无需完整解码
+	if !s.config.EnableThumbnail {
+		return readImageSize(file, fileModel)
+	}
+
+	// 获取缩略图处理槽位（限制同时进行的解码/缩放数量），超时则跳过缩略图
+	if !s.acquireThumbnailSlot(ctx) {
+		logger.Warn("thumbnail skipped: no free slot",
+			"path", originalPath,
+			"workers", cap(s.thumbnailSlots),
+		)
+		return readImageSize(file, fileModel)
+	}
+	defer s.releaseThumbnailSlot()
+
 	// 解码图片
 	img, format, err := image.Decode(file)
 	if err != nil {
@@ -373,27 +754,205 @@ func (s *fileService) processImage(ctx context.Context, file multipart.File, fil
 	fileModel.Height = bounds.Dy()
 
 	// 生成缩略图
-	if s.config.EnableThumbnail {
-		thumbnail := resize.Thumbnail(
-			uint(s.config.ThumbnailWidth),
-			uint(s.config.ThumbnailHeight),
-			img,
-			resize.Lanczos3,
-		)
+	thumbnail := makeThumbnail(img, s.config.ThumbnailWidth, s.config.ThumbnailHeight, s.config.ThumbnailMode)
+
+	// 保存缩略图
+	thumbnailPath := s.getThumbnailPath(originalPath)
+	if err := s.saveThumbnail(ctx, thumbnail, thumbnailPath, format); err != nil {
+		return fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+
+	fileModel.ThumbnailPath = thumbnailPath
+	fileModel.ThumbnailURL = s.storage.GetURL(thumbnailPath)
+	fileModel.ThumbnailSpec = s.thumbnailSpec()
+
+	return nil
+}
+
+// thumbnailSpec 当前缩略图配置指纹
+func (s *fileService) thumbnailSpec() string {
+	return fmt.Sprintf("%dx%d:%s:q%d", s.config.ThumbnailWidth, s.config.ThumbnailHeight, s.config.ThumbnailMode, s.config.ThumbnailQuality)
+}
+
+// regenerateBatchSize 重新生成缩略图时每批读取的文件数
+const regenerateBatchSize = 100
 
-		// 保存缩略图
-		thumbnailPath := s.getThumbnailPath(originalPath)
-		if err := s.saveThumbnail(ctx, thumbnail, thumbnailPath, format); err != nil {
-			return fmt.Errorf("failed to save thumbnail: %w", err)
+// RegenerateThumbnails 按当前配置重新生成图片缩略图
+// 已按当前配置生成（指纹一致）的文件跳过，除非 filter.Force 为 true；
+// 与上传共用缩略图处理槽位，并发数不超过 thumbnail_workers。返回重新生成的文件数
+func (s *fileService) RegenerateThumbnails(ctx context.Context, filter FileFilter) (int, error) {
+	if !s.config.EnableThumbnail {
+		return 0, errors.New(errors.ErrServiceDisabled, "thumbnail disabled")
+	}
+
+	spec := s.thumbnailSpec()
+	var (
+		afterID     uint
+		regenerated atomic.Int64
+	)
+
+	for {
+		files, err := s.fileRepo.ListImagesAfter(ctx, afterID, filter.UserID, filter.Category, regenerateBatchSize)
+		if err != nil {
+			return int(regenerated.Load()), errors.Wrap(errors.ErrDatabase, err)
+		}
+		if len(files) == 0 {
+			break
 		}
+		afterID = files[len(files)-1].ID
 
-		fileModel.ThumbnailPath = thumbnailPath
-		fileModel.ThumbnailURL = s.storage.GetURL(thumbnailPath)
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(cap(s.thumbnailSlots))
+		for i := range files {
+			file := &files[i]
+			if !filter.Force && file.ThumbnailSpec == spec {
+				continue
+			}
+			g.Go(func() error {
+				if err := s.regenerateThumbnail(gctx, file); err != nil {
+					// 单个文件失败不中断批量任务
+					logger.Warn("failed to regenerate thumbnail",
+						"file_id", file.ID,
+						"path", file.Path,
+						"error", err,
+					)
+					return gctx.Err()
+				}
+				regenerated.Add(1)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return int(regenerated.Load()), err
+		}
 	}
 
+	return int(regenerated.Load()), nil
+}
+
+// regenerateThumbnail 重新下载原图并按当前配置生成缩略图
+func (s *fileService) regenerateThumbnail(ctx context.Context, file *model.File) error {
+	select {
+	case s.thumbnailSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer s.releaseThumbnailSlot()
+
+	reader, err := s.storage.Download(ctx, file.Path)
+	if err != nil {
+		return fmt.Errorf("failed to download image: %w", err)
+	}
+	defer reader.Close()
+
+	var src io.Reader = reader
+	if file.ContentEncoding == storage.EncodingGzip {
+		decompressed, err := storage.NewDecompressReader(reader)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		src = decompressed
+	}
+
+	img, format, err := image.Decode(src)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	thumbnailPath := file.ThumbnailPath
+	if thumbnailPath == "" {
+		thumbnailPath = s.getThumbnailPath(file.Path)
+	}
+	thumbnail := makeThumbnail(img, s.config.ThumbnailWidth, s.config.ThumbnailHeight, s.config.ThumbnailMode)
+	if err := s.saveThumbnail(ctx, thumbnail, thumbnailPath, format); err != nil {
+		return fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+
+	bounds := img.Bounds()
+	file.Width = bounds.Dx()
+	file.Height = bounds.Dy()
+	file.ThumbnailPath = thumbnailPath
+	file.ThumbnailURL = s.storage.GetURL(thumbnailPath)
+	file.ThumbnailSpec = s.thumbnailSpec()
+
+	return s.fileRepo.UpdateThumbnail(ctx, file)
+}
+
+// readImageSize 仅读取图片头获取尺寸
+func readImageSize(file multipart.File, fileModel *model.File) error {
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image config: %w", err)
+	}
+	fileModel.Width = cfg.Width
+	fileModel.Height = cfg.Height
 	return nil
 }
 
+// acquireThumbnailSlot 获取缩略图处理槽位，等待超过 thumbnail_wait_timeout 或请求取消时返回 false
+func (s *fileService) acquireThumbnailSlot(ctx context.Context) bool {
+	wait := time.Duration(s.config.ThumbnailWaitTimeout) * time.Millisecond
+	if wait <= 0 {
+		wait = 2 * time.Second
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case s.thumbnailSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseThumbnailSlot 释放缩略图处理槽位
+func (s *fileService) releaseThumbnailSlot() {
+	<-s.thumbnailSlots
+}
+
+// makeThumbnail 按模式生成缩略图
+// fit: 等比缩放至 width x height 范围内，极端宽高比时输出会很窄或很扁
+// fill: 等比缩放后居中放置于白色背景，输出固定为 width x height
+// crop: 等比缩放至覆盖 width x height 后居中裁剪，输出固定为 width x height
+func makeThumbnail(img image.Image, width, height int, mode string) image.Image {
+	switch mode {
+	case "fill":
+		fitted := resize.Thumbnail(uint(width), uint(height), img, resize.Lanczos3)
+		canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+		fb := fitted.Bounds()
+		offset := image.Pt((width-fb.Dx())/2, (height-fb.Dy())/2)
+		draw.Draw(canvas, fb.Sub(fb.Min).Add(offset), fitted, fb.Min, draw.Over)
+		return canvas
+	case "crop":
+		bounds := img.Bounds()
+		srcW, srcH := bounds.Dx(), bounds.Dy()
+		if srcW == 0 || srcH == 0 {
+			return img
+		}
+		// 按较大的缩放比例缩放，保证覆盖目标区域
+		var resized image.Image
+		if srcW*height > srcH*width {
+			resized = resize.Resize(0, uint(height), img, resize.Lanczos3)
+		} else {
+			resized = resize.Resize(uint(width), 0, img, resize.Lanczos3)
+		}
+		rb := resized.Bounds()
+		cropW, cropH := min(width, rb.Dx()), min(height, rb.Dy())
+		x0 := rb.Min.X + (rb.Dx()-cropW)/2
+		y0 := rb.Min.Y + (rb.Dy()-cropH)/2
+		canvas := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+		draw.Draw(canvas, canvas.Bounds(), resized, image.Pt(x0, y0), draw.Src)
+		return canvas
+	default:
+		return resize.Thumbnail(uint(width), uint(height), img, resize.Lanczos3)
+	}
+}
+
 // getThumbnailPath 获取缩略图路径
 func (s *fileService) getThumbnailPath(originalPath string) string {
 	dir := filepath.Dir(originalPath)
@@ -404,13 +963,12 @@ func (s *fileService) getThumbnailPath(originalPath string) string {
 
 // saveThumbnail 保存缩略图
 func (s *fileService) saveThumbnail(ctx context.Context, img image.Image, path string, format string) error {
-	// 创建临时文件
-	tmpFile := fmt.Sprintf("/tmp/thumb_%s_%d", uuid.New().String(), time.Now().Unix())
-	f, err := os.Create(tmpFile)
+	// 在配置的临时目录中创建唯一临时文件
+	f, err := os.CreateTemp(s.config.TempDir, "thumb_*")
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tmpFile)
+	defer os.Remove(f.Name())
 	defer f.Close()
 
 	// 编码图片
@@ -437,6 +995,37 @@ func (s *fileService) saveThumbnail(ctx context.Context, img image.Image, path s
 	return err
 }
 
+// shouldCompress 判断 MIME 类型是否需要压缩存储
+func (s *fileService) shouldCompress(mimeType string) bool {
+	for _, prefix := range s.config.CompressTypes {
+		if prefix != "" && strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadCompressed 将文件 gzip 压缩到临时文件后上传
+func (s *fileService) uploadCompressed(ctx context.Context, file multipart.File, savedName, path string) (string, error) {
+	f, err := os.CreateTemp(s.config.TempDir, "gzip_*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := storage.Compress(f, file); err != nil {
+		return "", err
+	}
+
+	// 重置文件指针
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	return s.storage.Upload(ctx, f, savedName, path)
+}
+
 // toResponse 转换为响应对象
 func (s *fileService) toResponse(file *model.File) *FileResponse {
 	return &FileResponse{
@@ -452,6 +1041,7 @@ func (s *fileService) toResponse(file *model.File) *FileResponse {
 		UploadedBy:   file.UploadedBy,
 		Width:        file.Width,
 		Height:       file.Height,
+		Tags:         file.Tags,
 		CreatedAt:    file.CreatedAt,
 	}
 }
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// memFileRepo 内存文件仓储，只实现上传与下载用到的方法
+type memFileRepo struct {
+	repository.FileRepository
+	mu    sync.Mutex
+	files []*model.File
+}
+
+func (r *memFileRepo) Create(_ context.Context, file *model.File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file.ID = uint(len(r.files) + 1)
+	r.files = append(r.files, file)
+	return nil
+}
+
+func (r *memFileRepo) FindByID(_ context.Context, id uint) (*model.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || int(id) > len(r.files) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.files[id-1], nil
+}
+
+func (r *memFileRepo) FindByHash(_ context.Context, hash string) (*model.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.files {
+		if f.Hash == hash {
+			return f, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// newTestFileService 创建使用本地临时目录存储与内存仓储的文件服务
+func newTestFileService(t testing.TB, cfg config.UploadConfig) (*fileService, *memFileRepo, storage.Storage) {
+	t.Helper()
+
+	store, err := storage.NewLocalStorage(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = 10
+	}
+	if cfg.TempDir == "" {
+		cfg.TempDir = t.TempDir()
+	}
+	repo := &memFileRepo{}
+	return NewFileService(repo, store, &cfg).(*fileService), repo, store
+}
+
+// newFileHeader 构造 multipart 上传文件
+func newFileHeader(t testing.TB, filename, contentType string, data []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="upload"`)
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+	part.Write(data)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("read form: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	fh := form.File["file"][0]
+	fh.Filename = filename // 直接设置，保留引号、换行等原始字符
+	return fh
+}
+
+// solidImage 创建指定尺寸的纯色图片
+func solidImage(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	return img
+}
+
+func TestMakeThumbnail_Dimensions(t *testing.T) {
+	inputs := map[string]image.Image{
+		"portrait":  solidImage(200, 400, color.Black),
+		"landscape": solidImage(400, 200, color.Black),
+		"square":    solidImage(300, 300, color.Black),
+	}
+	tests := []struct {
+		mode  string
+		input string
+		w, h  int
+	}{
+		{"fit", "portrait", 50, 100},
+		{"fit", "landscape", 100, 50},
+		{"fit", "square", 100, 100},
+		{"fill", "portrait", 100, 100},
+		{"fill", "landscape", 100, 100},
+		{"fill", "square", 100, 100},
+		{"crop", "portrait", 100, 100},
+		{"crop", "landscape", 100, 100},
+		{"crop", "square", 100, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.input, func(t *testing.T) {
+			b := makeThumbnail(inputs[tt.input], 100, 100, tt.mode).Bounds()
+			if b.Dx() != tt.w || b.Dy() != tt.h {
+				t.Fatalf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestMakeThumbnail_FillLetterboxes(t *testing.T) {
+	thumb := makeThumbnail(solidImage(200, 400, color.Black), 100, 100, "fill")
+
+	// 竖图等比缩放到 50x100 后居中，左右两侧以白色补齐
+	if r, g, b, _ := thumb.At(5, 50).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+		t.Fatalf("edge pixel = (%d,%d,%d), want white", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := thumb.At(50, 50).RGBA(); r>>8 > 0x10 || g>>8 > 0x10 || b>>8 > 0x10 {
+		t.Fatalf("center pixel = (%d,%d,%d), want black", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestMakeThumbnail_CropKeepsCenter(t *testing.T) {
+	// 横图：左右各四分之一为白色，中间为黑色；居中裁剪后只剩黑色部分
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	draw.Draw(src, src.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(100, 0, 300, 200), image.Black, image.Point{}, draw.Src)
+
+	thumb := makeThumbnail(src, 100, 100, "crop")
+	for _, x := range []int{5, 50, 94} {
+		if r, _, _, _ := thumb.At(x, 50).RGBA(); r>>8 > 0x20 {
+			t.Fatalf("pixel at x=%d has red %d, want the black center", x, r>>8)
+		}
+	}
+}
+
+func TestUpload_CompressedRoundTrip(t *testing.T) {
+	svc, repo, store := newTestFileService(t, config.UploadConfig{CompressTypes: []string{"text/", "application/json"}})
+	ctx := context.Background()
+
+	original := []byte(strings.Repeat(`{"level":"info","msg":"request handled"}`+"\n", 500))
+	resp, err := svc.Upload(ctx, newFileHeader(t, "app.log", "text/plain", original), "document", 1)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	file := repo.files[resp.ID-1]
+	if file.ContentEncoding != storage.EncodingGzip {
+		t.Fatalf("ContentEncoding = %q, want gzip", file.ContentEncoding)
+	}
+	if file.Size != int64(len(original)) {
+		t.Fatalf("Size = %d, want original size %d", file.Size, len(original))
+	}
+	// Hash 基于原始内容，秒传去重不受压缩影响
+	sum := sha256.Sum256(original)
+	if file.Hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Hash = %s, want hash of original bytes", file.Hash)
+	}
+
+	// 存储中为压缩内容
+	raw, err := store.Download(ctx, file.Path)
+	if err != nil {
+		t.Fatalf("storage download: %v", err)
+	}
+	stored, _ := io.ReadAll(raw)
+	raw.Close()
+	if len(stored) >= len(original) {
+		t.Fatalf("stored %d bytes, want fewer than original %d", len(stored), len(original))
+	}
+
+	// 下载后解压得到原始内容
+	reader, got, err := svc.Download(ctx, resp.ID, 1)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	decompressed, err := storage.NewDecompressReader(reader)
+	if err != nil {
+		t.Fatalf("NewDecompressReader: %v", err)
+	}
+	defer decompressed.Close()
+	data, err := io.ReadAll(decompressed)
+	if err != nil {
+		t.Fatalf("read decompressed: %v", err)
+	}
+	if !bytes.Equal(data, original) || got.ContentEncoding != storage.EncodingGzip {
+		t.Fatalf("round trip mismatch: %d bytes, encoding %q", len(data), got.ContentEncoding)
+	}
+
+	// 相同内容再次上传走秒传，沿用压缩编码
+	dup, err := svc.Upload(ctx, newFileHeader(t, "copy.log", "text/plain", original), "document", 1)
+	if err != nil {
+		t.Fatalf("Upload duplicate: %v", err)
+	}
+	if f := repo.files[dup.ID-1]; f.Path != file.Path || f.ContentEncoding != storage.EncodingGzip {
+		t.Fatalf("duplicate upload = %+v, want shared gzip object", f)
+	}
+}
+
+func TestUpload_UncompressedTypeStoredAsIs(t *testing.T) {
+	svc, repo, store := newTestFileService(t, config.UploadConfig{CompressTypes: []string{"text/"}})
+	ctx := context.Background()
+
+	original := []byte(strings.Repeat("binary", 100))
+	resp, err := svc.Upload(ctx, newFileHeader(t, "data.bin", "application/octet-stream", original), "other", 1)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	file := repo.files[resp.ID-1]
+	if file.ContentEncoding != "" {
+		t.Fatalf("ContentEncoding = %q, want none", file.ContentEncoding)
+	}
+	raw, err := store.Download(ctx, file.Path)
+	if err != nil {
+		t.Fatalf("storage download: %v", err)
+	}
+	defer raw.Close()
+	if stored, _ := io.ReadAll(raw); !bytes.Equal(stored, original) {
+		t.Fatal("stored content differs from original")
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		maxLen int
+		want   string
+	}{
+		{"plain", "report.pdf", 255, "report.pdf"},
+		{"crlf injection", "a.txt\r\nSet-Cookie: x=1", 255, "a.txtSet-Cookie: x=1"},
+		{"other control chars", "tab\there\x00nul\x7f.txt", 255, "tabherenul.txt"},
+		{"quotes kept", `say "hi".txt`, 255, `say "hi".txt`},
+		{"unicode kept", "报告 résumé ✓.pdf", 255, "报告 résumé ✓.pdf"},
+		{"invalid utf-8 dropped", "bad\xffname.txt", 255, "badname.txt"},
+		{"unix path", "../../etc/passwd", 255, "passwd"},
+		{"windows path", `C:\Users\alice\notes.txt`, 255, "notes.txt"},
+		{"surrounding spaces", "  notes.txt  ", 255, "notes.txt"},
+		{"empty", "", 255, "file"},
+		{"only control chars", "\r\n", 255, "file"},
+		{"dot dot", "..", 255, "file"},
+		{"truncated keeps extension", "abcdefghij.pdf", 8, "abcd.pdf"},
+		{"truncated by runes", "文件名很长很长.txt", 6, "文件.txt"},
+		{"extension longer than limit", "a.verylongext", 4, "a.ve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.input, tt.maxLen); got != tt.want {
+				t.Fatalf("sanitizeFilename(%q, %d) = %q, want %q", tt.input, tt.maxLen, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpload_SanitizesOriginalName(t *testing.T) {
+	svc, repo, _ := newTestFileService(t, config.UploadConfig{MaxFilenameLength: 255})
+
+	resp, err := svc.Upload(context.Background(), newFileHeader(t, "evil\r\nX-Injected: 1.txt", "text/plain", []byte("data")), "document", 1)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if name := repo.files[resp.ID-1].OriginalName; strings.ContainsAny(name, "\r\n") {
+		t.Fatalf("OriginalName = %q, want control characters stripped", name)
+	}
+}
+
+// pngBytes 编码指定尺寸的 PNG 图片
+func pngBytes(t testing.TB, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solidImage(w, h, color.RGBA{R: 0x40, G: 0x80, B: 0xc0, A: 0xff})); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// thumbnailConfig 启用缩略图的上传配置
+func thumbnailConfig(workers, waitMs int) config.UploadConfig {
+	return config.UploadConfig{
+		EnableThumbnail:      true,
+		ThumbnailWidth:       100,
+		ThumbnailHeight:      100,
+		ThumbnailQuality:     80,
+		ThumbnailMode:        "fit",
+		ThumbnailWorkers:     workers,
+		ThumbnailWaitTimeout: waitMs,
+	}
+}
+
+func TestUpload_ThumbnailSkippedWhenNoSlot(t *testing.T) {
+	svc, repo, _ := newTestFileService(t, thumbnailConfig(1, 20))
+	data := pngBytes(t, 400, 200)
+
+	// 占满唯一的槽位，上传等待超时后跳过缩略图
+	svc.thumbnailSlots <- struct{}{}
+	resp, err := svc.Upload(context.Background(), newFileHeader(t, "busy.png", "image/png", data), "image", 1)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	file := repo.files[resp.ID-1]
+	if file.ThumbnailPath != "" {
+		t.Fatalf("ThumbnailPath = %q, want skipped", file.ThumbnailPath)
+	}
+	if file.Width != 400 || file.Height != 200 {
+		t.Fatalf("size = %dx%d, want 400x200 read from the header", file.Width, file.Height)
+	}
+
+	// 槽位释放后正常生成
+	<-svc.thumbnailSlots
+	resp, err = svc.Upload(context.Background(), newFileHeader(t, "free.png", "image/png", pngBytes(t, 300, 300)), "image", 1)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if repo.files[resp.ID-1].ThumbnailPath == "" {
+		t.Fatal("thumbnail not generated with a free slot")
+	}
+	if len(svc.thumbnailSlots) != 0 {
+		t.Fatalf("%d slots still held after upload", len(svc.thumbnailSlots))
+	}
+}
+
+func BenchmarkProcessImage_Concurrent(b *testing.B) {
+	data := pngBytes(b, 1600, 1200)
+	for _, workers := range []int{1, 2, 4} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			svc, _, _ := newTestFileService(b, thumbnailConfig(workers, 60000))
+			fh := newFileHeader(b, "photo.png", "image/png", data)
+			var seq atomic.Int64
+
+			b.SetParallelism(4) // 并发上传数多于槽位，超出部分排队等待
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					file, err := fh.Open()
+					if err != nil {
+						b.Errorf("open: %v", err)
+						return
+					}
+					var m model.File
+					path := "bench/" + strconv.FormatInt(seq.Add(1), 10) + ".png"
+					err = svc.processImage(context.Background(), file, &m, path)
+					file.Close()
+					if err != nil || m.ThumbnailPath == "" {
+						b.Errorf("processImage: %v, thumbnail %q", err, m.ThumbnailPath)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"mime/multipart"
+	"time"
+)
+
+// throttledFile 按字节速率限制读取的上传文件
+// 只对首次读到的字节计速：计算 Hash 后 Seek 回开头再写入存储时，重复读取的部分不会再次等待，
+// 因此单个上传的整体处理时间约为 文件大小 / bytesPerSec
+type throttledFile struct {
+	multipart.File
+	ctx         context.Context
+	bytesPerSec int64
+	start       time.Time
+	pos         int64 // 当前读取位置
+	ingested    int64 // 已计速的最远位置
+}
+
+// newThrottledFile 包装上传文件，bytesPerSec <= 0 时原样返回
+func newThrottledFile(ctx context.Context, file multipart.File, bytesPerSec int64) multipart.File {
+	if bytesPerSec <= 0 {
+		return file
+	}
+	return &throttledFile{
+		File:        file,
+		ctx:         ctx,
+		bytesPerSec: bytesPerSec,
+		start:       time.Now(),
+	}
+}
+
+func (f *throttledFile) Read(p []byte) (int, error) {
+	// 单次读取不超过每秒配额，避免一次读入大块后长时间停顿
+	if int64(len(p)) > f.bytesPerSec {
+		p = p[:f.bytesPerSec]
+	}
+	n, err := f.File.Read(p)
+	f.pos += int64(n)
+	if waitErr := f.wait(f.pos); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+func (f *throttledFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	if waitErr := f.wait(off + int64(n)); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+func (f *throttledFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	if err == nil {
+		f.pos = pos
+	}
+	return pos, err
+}
+
+// wait 读取位置超过已计速的范围时，按配额等待到新字节对应的时间点
+func (f *throttledFile) wait(end int64) error {
+	if end <= f.ingested {
+		return nil
+	}
+	f.ingested = end
+
+	due := f.start.Add(time.Duration(float64(f.ingested) / float64(f.bytesPerSec) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/config"
+)
+
+func TestUpload_ThrottledTakesExpectedTime(t *testing.T) {
+	const (
+		size = 200 << 10 // 200 KB
+		bps  = 400 << 10 // 400 KB/s，预期约 0.5 秒
+	)
+	svc, repo, _ := newTestFileService(t, config.UploadConfig{MaxUploadBPS: bps})
+	data := bytes.Repeat([]byte("x"), size)
+
+	start := time.Now()
+	resp, err := svc.Upload(context.Background(), newFileHeader(t, "big.bin", "application/octet-stream", data), "other", 1)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	// 计算 Hash 与写入存储读取两遍，重复读取部分不重复计速
+	want := time.Duration(float64(size) / float64(bps) * float64(time.Second))
+	if elapsed < want*9/10 || elapsed > want*3 {
+		t.Fatalf("throttled upload took %v, want about %v", elapsed, want)
+	}
+	if f := repo.files[resp.ID-1]; f.Size != size {
+		t.Fatalf("Size = %d, want %d", f.Size, size)
+	}
+}
+
+func TestUpload_UnthrottledByDefault(t *testing.T) {
+	svc, _, _ := newTestFileService(t, config.UploadConfig{})
+	data := bytes.Repeat([]byte("x"), 200<<10)
+
+	start := time.Now()
+	if _, err := svc.Upload(context.Background(), newFileHeader(t, "big.bin", "application/octet-stream", data), "other", 1); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("unthrottled upload took %v", elapsed)
+	}
+}
+
+func TestThrottledFile_StopsOnContextCancel(t *testing.T) {
+	fh := newFileHeader(t, "big.bin", "application/octet-stream", bytes.Repeat([]byte("x"), 64<<10))
+	file, err := fh.Open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	// 1 KB/s 读完需约一分钟，取消后应立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = io.Copy(io.Discard, newThrottledFile(ctx, file, 1<<10))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("copy err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("copy took %v after cancel", elapsed)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/queue"
+)
+
+// OutboxPoller 发件箱轮询器
+// 定期读取待投递的发件箱事件并提交到任务队列，提交成功后标记为已投递。
+// 提交成功但标记前进程崩溃时事件会被再次投递（至少一次语义），任务处理器需保证幂等。
+// 多实例部署时通过 Redis 租约选举，仅主节点执行投递
+type OutboxPoller struct {
+	repo         repository.OutboxRepository
+	queue        *queue.Client
+	leader       *cache.Leader
+	batchSize    int
+	maxAttempts  int
+	taskMaxRetry int
+	running      atomic.Bool // 防止上一轮未结束时重叠执行
+}
+
+// NewOutboxPoller 创建发件箱轮询器
+func NewOutboxPoller(repo repository.OutboxRepository, queueClient *queue.Client, cfg *config.QueueConfig) *OutboxPoller {
+	// 租约为轮询间隔的 3 倍，主节点偶发一次检查延迟不会导致切换
+	lease := 3 * time.Duration(cfg.OutboxPollInterval) * time.Second
+	return &OutboxPoller{
+		repo:         repo,
+		queue:        queueClient,
+		leader:       cache.NewLeader("outbox_poller", lease),
+		batchSize:    cfg.OutboxBatchSize,
+		maxAttempts:  cfg.OutboxMaxAttempts,
+		taskMaxRetry: cfg.MaxRetry,
+	}
+}
+
+// Poll 执行一轮投递，返回成功投递的事件数
+func (p *OutboxPoller) Poll(ctx context.Context) (int, error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return 0, nil
+	}
+	defer p.running.Store(false)
+
+	isLeader, err := p.leader.IsLeader(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("outbox leader election failed: %w", err)
+	}
+	if !isLeader {
+		return 0, nil
+	}
+
+	events, err := p.repo.ListPending(ctx, p.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list outbox events: %w", err)
+	}
+
+	delivered := 0
+	for _, event := range events {
+		if err := p.dispatch(ctx, event.Topic, event.Payload); err != nil {
+			giveUp := event.Attempts+1 >= p.maxAttempts
+			logger.Warn("failed to dispatch outbox event",
+				"event_id", event.ID,
+				"topic", event.Topic,
+				"attempts", event.Attempts+1,
+				"give_up", giveUp,
+				"error", err,
+			)
+			if err := p.repo.MarkAttemptFailed(ctx, event.ID, err.Error(), giveUp); err != nil {
+				return delivered, fmt.Errorf("failed to update outbox event: %w", err)
+			}
+			continue
+		}
+
+		if err := p.repo.MarkDone(ctx, event.ID); err != nil {
+			return delivered, fmt.Errorf("failed to mark outbox event done: %w", err)
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// Resign 释放主节点租约（服务退出时调用）
+func (p *OutboxPoller) Resign(ctx context.Context) error {
+	return p.leader.Resign(ctx)
+}
+
+// dispatch 将事件提交到任务队列，主题即任务名称
+func (p *OutboxPoller) dispatch(ctx context.Context, topic, payload string) error {
+	var data map[string]interface{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &data); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+	_, err := p.queue.Submit(ctx, topic, data, p.taskMaxRetry)
+	return err
+}
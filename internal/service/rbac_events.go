@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cccvno1/nova/pkg/logger"
+)
+
+// RBACChangedTaskName 角色/权限变更事件的队列任务名，事件经发件箱投递
+const RBACChangedTaskName = "rbac_changed"
+
+// RBAC 变更事件类型
+const (
+	RBACRoleCreated             = "role.created"
+	RBACRoleUpdated             = "role.updated"
+	RBACRoleDeleted             = "role.deleted"
+	RBACRolePermissionsChanged  = "role.permissions_changed"  // 角色新增/移除部分权限
+	RBACRolePermissionsReplaced = "role.permissions_replaced" // 角色权限整体替换，added 为替换后的完整集合
+	RBACPermissionCreated       = "permission.created"
+	RBACPermissionUpdated       = "permission.updated"
+	RBACPermissionDeleted       = "permission.deleted"
+	RBACUserRolesAssigned       = "user.roles_assigned"
+	RBACUserRolesRevoked        = "user.roles_revoked"
+)
+
+// RBACChangeEvent 角色/权限变更事件载荷
+type RBACChangeEvent struct {
+	Action       string `json:"action"` // 变更类型，见 RBAC 变更事件类型常量
+	Domain       string `json:"domain"`
+	RoleID       uint   `json:"role_id,omitempty"`
+	PermissionID uint   `json:"permission_id,omitempty"`
+	UserID       uint   `json:"user_id,omitempty"`
+	Added        []uint `json:"added,omitempty"`   // 新增的权限或角色ID
+	Removed      []uint `json:"removed,omitempty"` // 移除的权限或角色ID
+}
+
+// payload 转换为发件箱载荷，零值字段省略
+func (e RBACChangeEvent) payload() map[string]interface{} {
+	p := map[string]interface{}{
+		"action": e.Action,
+		"domain": e.Domain,
+	}
+	if e.RoleID != 0 {
+		p["role_id"] = e.RoleID
+	}
+	if e.PermissionID != 0 {
+		p["permission_id"] = e.PermissionID
+	}
+	if e.UserID != 0 {
+		p["user_id"] = e.UserID
+	}
+	if len(e.Added) > 0 {
+		p["added"] = e.Added
+	}
+	if len(e.Removed) > 0 {
+		p["removed"] = e.Removed
+	}
+	return p
+}
+
+// publishChange 将变更事件写入发件箱，使用 context 中的事务，与业务变更一同提交或回滚
+// 未配置发件箱（队列未启用）时忽略。同时清空本请求内的权限缓存，之后的检查按变更后的数据重新加载
+func (s *rbacService) publishChange(ctx context.Context, event RBACChangeEvent) error {
+	requestMemoFrom(ctx).reset()
+
+	if s.outbox == nil {
+		return nil
+	}
+	if err := s.outbox.Add(ctx, s.db.Conn(ctx), RBACChangedTaskName, event.payload()); err != nil {
+		return fmt.Errorf("failed to write rbac change event: %w", err)
+	}
+	return nil
+}
+
+// HandleRBACChanged 默认的变更事件处理器，只记录日志
+// 需要推送 webhook 或同步外部系统时替换为自定义处理器；投递语义为至少一次，处理器需保证幂等
+func HandleRBACChanged(ctx context.Context, event RBACChangeEvent) error {
+	logger.InfoContext(ctx, "rbac change event",
+		"action", event.Action,
+		"domain", event.Domain,
+		"role_id", event.RoleID,
+		"permission_id", event.PermissionID,
+		"user_id", event.UserID,
+		"added", event.Added,
+		"removed", event.Removed,
+	)
+	return nil
+}
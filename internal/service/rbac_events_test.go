@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
+)
+
+// outboxEvents 读取发件箱中的全部事件
+func (env *rbacTestEnv) outboxEvents(t *testing.T) []model.OutboxEvent {
+	t.Helper()
+
+	var events []model.OutboxEvent
+	if err := env.db.DB.Order("id ASC").Find(&events).Error; err != nil {
+		t.Fatalf("list outbox events: %v", err)
+	}
+	return events
+}
+
+func TestRBACChange_WritesOutboxEvent(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	role := &model.Role{Name: "editor", DisplayName: "editor", Domain: "default", Level: 10}
+	if err := env.svc.CreateRole(ctx, role); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if _, err := env.svc.AssignRolesToUser(ctx, 7, []uint{role.ID}, "default", 0, nil); err != nil {
+		t.Fatalf("AssignRolesToUser: %v", err)
+	}
+
+	events := env.outboxEvents(t)
+	if len(events) != 2 {
+		t.Fatalf("outbox has %d events, want 2", len(events))
+	}
+	for _, e := range events {
+		if e.Topic != RBACChangedTaskName || e.Status != model.OutboxStatusPending {
+			t.Fatalf("event topic=%q status=%q, want %q pending", e.Topic, e.Status, RBACChangedTaskName)
+		}
+	}
+
+	var assigned RBACChangeEvent
+	if err := json.Unmarshal([]byte(events[1].Payload), &assigned); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if assigned.Action != RBACUserRolesAssigned || assigned.UserID != 7 || len(assigned.Added) != 1 || assigned.Added[0] != role.ID {
+		t.Fatalf("payload = %+v, want user.roles_assigned for user 7 role %d", assigned, role.ID)
+	}
+}
+
+// 请求事务回滚时，变更与事件一同回滚
+func TestRBACChange_RollbackDiscardsOutboxEvent(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	ids := env.permissionIDs(t, "default", 2)
+	errRollback := errors.New("rollback")
+	err := env.db.DB.Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(context.Background(), tx)
+
+		role := &model.Role{Name: "editor", DisplayName: "editor", Domain: "default", Level: 10}
+		if err := env.svc.CreateRole(ctx, role); err != nil {
+			return err
+		}
+		if _, _, err := env.svc.UpdateRolePermissions(ctx, role.ID, ids, "default", false); err != nil {
+			return err
+		}
+		if _, err := env.svc.AssignRolesToUser(ctx, 7, []uint{role.ID}, "default", 0, nil); err != nil {
+			return err
+		}
+		if got := len(env.outboxEventsIn(t, tx)); got != 3 {
+			t.Errorf("outbox has %d events inside transaction, want 3", got)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction err = %v, want rollback", err)
+	}
+
+	if events := env.outboxEvents(t); len(events) != 0 {
+		t.Fatalf("outbox has %d events after rollback, want 0", len(events))
+	}
+	var roles int64
+	if err := env.db.DB.Model(&model.Role{}).Count(&roles).Error; err != nil {
+		t.Fatalf("count roles: %v", err)
+	}
+	if roles != 0 {
+		t.Fatalf("%d roles after rollback, want 0", roles)
+	}
+}
+
+// outboxEventsIn 在给定事务内读取发件箱事件
+func (env *rbacTestEnv) outboxEventsIn(t *testing.T, tx *gorm.DB) []model.OutboxEvent {
+	t.Helper()
+
+	var events []model.OutboxEvent
+	if err := tx.Find(&events).Error; err != nil {
+		t.Fatalf("list outbox events: %v", err)
+	}
+	return events
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+// grantTemporaryRole 直接写入带过期时间的分配及其 Casbin 分组规则（模拟清理任务尚未执行的状态）
+func (env *rbacTestEnv) grantTemporaryRole(t *testing.T, userID uint, role *model.Role, expiresAt time.Time) *model.UserRole {
+	t.Helper()
+
+	ur := &model.UserRole{UserID: userID, RoleID: role.ID, Domain: role.Domain, ExpiresAt: &expiresAt}
+	if err := env.db.DB.Create(ur).Error; err != nil {
+		t.Fatalf("create user role: %v", err)
+	}
+	if _, err := env.enforcer.AddRoleForUser(casbinKey(userID), casbinKey(role.ID), role.Domain); err != nil {
+		t.Fatalf("add grouping policy: %v", err)
+	}
+	return ur
+}
+
+// roleWithPermission 创建拥有一个权限的角色
+func (env *rbacTestEnv) roleWithPermission(t *testing.T, name string) (*model.Role, *model.Permission) {
+	t.Helper()
+
+	role := env.createRole(t, name, "default", 10)
+	perm := env.createPermission(t, name+"_perm", 0)
+	if _, err := env.svc.AssignPermissionsToRole(context.Background(), role.ID, []uint{perm.ID}, "default"); err != nil {
+		t.Fatalf("assign permission: %v", err)
+	}
+	return role, perm
+}
+
+func TestExpiredAssignment_GrantsNoPermissions(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+	role, perm := env.roleWithPermission(t, "contractor")
+	ur := env.grantTemporaryRole(t, 7, role, time.Now().Add(-time.Minute))
+
+	perms, err := env.svc.GetUserPermissions(ctx, 7, "default")
+	if err != nil {
+		t.Fatalf("GetUserPermissions: %v", err)
+	}
+	if len(perms) != 0 {
+		t.Fatalf("expired assignment granted %d permissions", len(perms))
+	}
+	allowed, err := env.svc.CheckPermission(ctx, 7, "default", perm.Resource, perm.Action)
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+	if allowed {
+		t.Fatal("CheckPermission allowed via an expired assignment")
+	}
+	if has, _ := env.svc.userRoleRepo.HasRole(ctx, 7, role.ID, "default"); has {
+		t.Fatal("HasRole reports an expired assignment")
+	}
+
+	// 行仍然存在，只是不再生效
+	var count int64
+	env.db.DB.Model(&model.UserRole{}).Where("id = ?", ur.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expired row count = %d, want 1 before purge", count)
+	}
+}
+
+func TestTemporaryAssignment_GrantsUntilExpiry(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+	role, perm := env.roleWithPermission(t, "contractor")
+
+	expiresAt := time.Now().Add(30 * time.Second)
+	if _, err := env.svc.AssignRolesToUser(ctx, 7, []uint{role.ID}, "default", 1, &expiresAt); err != nil {
+		t.Fatalf("AssignRolesToUser: %v", err)
+	}
+
+	allowed, err := env.svc.CheckPermission(ctx, 7, "default", perm.Resource, perm.Action)
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+	if !allowed {
+		t.Fatal("CheckPermission denied before the assignment expired")
+	}
+
+	// 缓存时长不超过剩余有效期（含 20% 抖动），过期后不会继续命中旧缓存
+	ttl, err := cache.TTL(ctx, fmt.Sprintf(cacheKeyUserPermissions, 7, "default"))
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl <= 0 || ttl > 36*time.Second {
+		t.Fatalf("permissions cached for %v, want at most the remaining 30s plus jitter", ttl)
+	}
+}
+
+func TestPurgeExpiredUserRoles(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+	role, _ := env.roleWithPermission(t, "contractor")
+	other := env.createRole(t, "staff", "default", 10)
+
+	expired := env.grantTemporaryRole(t, 7, role, time.Now().Add(-time.Minute))
+	env.grantTemporaryRole(t, 8, role, time.Now().Add(time.Hour))
+	env.assignRole(t, 7, other)
+
+	userCacheKey := fmt.Sprintf(cacheKeyUserPermissions, 7, "default")
+	if err := cache.Set(ctx, userCacheKey, "[]", time.Hour); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	purged, err := env.svc.PurgeExpiredUserRoles(ctx)
+	if err != nil {
+		t.Fatalf("PurgeExpiredUserRoles: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d assignments, want 1", purged)
+	}
+
+	var count int64
+	env.db.DB.Model(&model.UserRole{}).Where("id = ?", expired.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("expired assignment still present after purge")
+	}
+	if n, _ := cache.Exists(ctx, userCacheKey); n != 0 {
+		t.Fatal("permissions cache of the affected user not cleared")
+	}
+
+	roles7, _ := env.enforcer.GetRolesForUser(casbinKey(7), "default")
+	if len(roles7) != 1 || roles7[0] != casbinKey(other.ID) {
+		t.Fatalf("user 7 grouping policies = %v, want only the permanent role", roles7)
+	}
+	roles8, _ := env.enforcer.GetRolesForUser(casbinKey(8), "default")
+	if len(roles8) != 1 {
+		t.Fatalf("unexpired assignment lost its grouping policy: %v", roles8)
+	}
+
+	if purged, _ := env.svc.PurgeExpiredUserRoles(ctx); purged != 0 {
+		t.Fatalf("second purge removed %d assignments, want 0", purged)
+	}
+}
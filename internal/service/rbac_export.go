@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"iter"
+
+	"github.com/cccvno1/nova/internal/model"
+)
+
+// ExportRoles 按ID键集分页逐批读取域内等级低于 maxLevel 的角色（含权限），用于流式导出
+// 每批查询一次角色与其权限，内存占用与 batchSize 成正比，与角色总数无关
+func (s *rbacService) ExportRoles(ctx context.Context, domain string, maxLevel, batchSize int) iter.Seq2[model.Role, error] {
+	return func(yield func(model.Role, error) bool) {
+		var afterID uint
+		for {
+			var roles []model.Role
+			err := s.db.DB.WithContext(ctx).
+				Preload("Permissions").
+				Where("domain = ? AND level < ? AND id > ?", domain, maxLevel, afterID).
+				Order("id ASC").
+				Limit(batchSize).
+				Find(&roles).Error
+			if err != nil {
+				yield(model.Role{}, err)
+				return
+			}
+			for _, role := range roles {
+				if !yield(role, nil) {
+					return
+				}
+			}
+			if len(roles) < batchSize {
+				return
+			}
+			afterID = roles[len(roles)-1].ID
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/internal/repository"
+)
+
+// PolicyReconcileReport Casbin 策略与权限表的核对结果
+// 期望的 p 规则来自 role_permissions（[角色ID, 域, 资源, 操作]），期望的 g 规则来自 user_roles（[用户ID, 角色ID, 域]）
+type PolicyReconcileReport struct {
+	Repaired          bool       `json:"repaired"`           // 是否已修复差异
+	MissingPolicies   [][]string `json:"missing_policies"`   // 权限表中存在但 Casbin 缺失的 p 规则
+	StalePolicies     [][]string `json:"stale_policies"`     // Casbin 中存在、资源/操作对应已登记的权限但角色未关联该权限的 p 规则
+	UnmanagedPolicies int        `json:"unmanaged_policies"` // 资源/操作不在权限表中的 p 规则数（如 ReplaceRolePolicies 直接写入），不做处理
+	MissingGroupings  [][]string `json:"missing_groupings"`  // user_roles 中存在但 Casbin 缺失的 g 规则
+	StaleGroupings    [][]string `json:"stale_groupings"`    // Casbin 中存在但 user_roles 中没有对应分配的 g 规则
+}
+
+// Consistent 是否不存在需要修复的差异
+func (r *PolicyReconcileReport) Consistent() bool {
+	return len(r.MissingPolicies) == 0 && len(r.StalePolicies) == 0 &&
+		len(r.MissingGroupings) == 0 && len(r.StaleGroupings) == 0
+}
+
+// policyRow 角色-权限关联对应的 p 规则
+type policyRow struct {
+	RoleID   uint
+	Domain   string
+	Resource string
+	Action   string
+}
+
+// groupingRow 用户-角色分配对应的 g 规则
+type groupingRow struct {
+	UserID uint
+	RoleID uint
+	Domain string
+}
+
+// ReconcilePolicies 核对 Casbin 的 p/g 规则与 role_permissions、user_roles 表
+// repair 为 false 时只返回差异；为 true 时补齐缺失规则并删除过期规则。
+// 只有资源/操作对应权限表中某个启用权限的 p 规则才会被视为过期，其余规则计入 UnmanagedPolicies 且保持不变
+func (s *rbacService) ReconcilePolicies(ctx context.Context, repair bool) (*PolicyReconcileReport, error) {
+	var policyRows []policyRow
+	err := s.db.DB.WithContext(ctx).
+		Table("role_permissions").
+		Select("role_permissions.role_id, permissions.domain, permissions.resource, permissions.action").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_at IS NULL").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id AND permissions.deleted_at IS NULL").
+		Where("roles.domain = permissions.domain AND permissions.status = ?", 1).
+		Scan(&policyRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load role permissions: %w", err)
+	}
+
+	var groupingRows []groupingRow
+	err = s.db.DB.WithContext(ctx).
+		Table("user_roles").
+		Select("user_roles.user_id, user_roles.role_id, user_roles.domain").
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+		Where("user_roles.deleted_at IS NULL").
+		Scopes(repository.ActiveUserRoles(time.Now())).
+		Scan(&groupingRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user roles: %w", err)
+	}
+
+	// 受管理的资源/操作：权限表中登记过的 (域, 资源, 操作)
+	var managedRows []policyRow
+	err = s.db.DB.WithContext(ctx).
+		Table("permissions").
+		Select("domain, resource, action").
+		Where("deleted_at IS NULL AND status = ?", 1).
+		Scan(&managedRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+	managed := make(map[string]bool, len(managedRows))
+	for _, row := range managedRows {
+		managed[ruleKey(row.Domain, row.Resource, row.Action)] = true
+	}
+
+	report := &PolicyReconcileReport{}
+
+	// p 规则
+	expectedPolicies := make(map[string][]string, len(policyRows))
+	for _, row := range policyRows {
+		rule := []string{strconv.FormatUint(uint64(row.RoleID), 10), row.Domain, row.Resource, row.Action}
+		expectedPolicies[ruleKey(rule...)] = rule
+	}
+	policies, err := s.enforcer.GetPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policies: %w", err)
+	}
+	actualPolicies := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		if len(policy) < 4 {
+			continue
+		}
+		key := ruleKey(policy[:4]...)
+		actualPolicies[key] = true
+		if _, ok := expectedPolicies[key]; ok {
+			continue
+		}
+		if managed[ruleKey(policy[1], policy[2], policy[3])] {
+			report.StalePolicies = append(report.StalePolicies, policy[:4])
+		} else {
+			report.UnmanagedPolicies++
+		}
+	}
+	for key, rule := range expectedPolicies {
+		if !actualPolicies[key] {
+			report.MissingPolicies = append(report.MissingPolicies, rule)
+		}
+	}
+
+	// g 规则
+	expectedGroupings := make(map[string][]string, len(groupingRows))
+	for _, row := range groupingRows {
+		rule := []string{
+			strconv.FormatUint(uint64(row.UserID), 10),
+			strconv.FormatUint(uint64(row.RoleID), 10),
+			row.Domain,
+		}
+		expectedGroupings[ruleKey(rule...)] = rule
+	}
+	groupings, err := s.enforcer.GetGroupingPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grouping policies: %w", err)
+	}
+	actualGroupings := make(map[string]bool, len(groupings))
+	for _, grouping := range groupings {
+		if len(grouping) < 3 {
+			continue
+		}
+		key := ruleKey(grouping[:3]...)
+		actualGroupings[key] = true
+		if _, ok := expectedGroupings[key]; !ok {
+			report.StaleGroupings = append(report.StaleGroupings, grouping[:3])
+		}
+	}
+	for key, rule := range expectedGroupings {
+		if !actualGroupings[key] {
+			report.MissingGroupings = append(report.MissingGroupings, rule)
+		}
+	}
+
+	if repair && !report.Consistent() {
+		if err := s.applyReconcile(report); err != nil {
+			return report, err
+		}
+		report.Repaired = true
+	}
+
+	logArgs := []any{
+		"missing_policies", len(report.MissingPolicies),
+		"stale_policies", len(report.StalePolicies),
+		"unmanaged_policies", report.UnmanagedPolicies,
+		"missing_groupings", len(report.MissingGroupings),
+		"stale_groupings", len(report.StaleGroupings),
+		"repaired", report.Repaired,
+	}
+	if report.Consistent() {
+		s.logger.Info("casbin policies match permission tables", logArgs...)
+	} else {
+		s.logger.Warn("casbin policies diverge from permission tables", logArgs...)
+	}
+
+	return report, nil
+}
+
+// applyReconcile 按核对结果补齐缺失规则、删除过期规则
+func (s *rbacService) applyReconcile(report *PolicyReconcileReport) error {
+	if len(report.MissingPolicies) > 0 {
+		if _, err := s.enforcer.AddPolicies(report.MissingPolicies); err != nil {
+			return fmt.Errorf("failed to add missing policies: %w", err)
+		}
+	}
+	if len(report.StalePolicies) > 0 {
+		if _, err := s.enforcer.RemovePolicies(report.StalePolicies); err != nil {
+			return fmt.Errorf("failed to remove stale policies: %w", err)
+		}
+	}
+	if len(report.MissingGroupings) > 0 {
+		if _, err := s.enforcer.AddGroupingPolicies(report.MissingGroupings); err != nil {
+			return fmt.Errorf("failed to add missing groupings: %w", err)
+		}
+	}
+	if len(report.StaleGroupings) > 0 {
+		if _, err := s.enforcer.RemoveGroupingPolicies(report.StaleGroupings); err != nil {
+			return fmt.Errorf("failed to remove stale groupings: %w", err)
+		}
+	}
+	return nil
+}
+
+// ruleKey 将规则字段拼接为集合键
+func ruleKey(fields ...string) string {
+	return strings.Join(fields, "\x00")
+}
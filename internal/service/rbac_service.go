@@ -2,17 +2,23 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
+	"math"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/pkg/cache"
 	"github.com/cccvno1/nova/pkg/casbin"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
-	"gorm.io/gorm"
 )
 
 // RBACService RBAC服务接口
@@ -26,6 +32,7 @@ import (
 type RBACService interface {
 	// 角色管理
 	CreateRole(ctx context.Context, role *model.Role) error
+	GetOrCreateRole(ctx context.Context, role *model.Role) (*model.Role, bool, error)
 	UpdateRole(ctx context.Context, role *model.Role) error
 	DeleteRole(ctx context.Context, id uint) error
 	GetRole(ctx context.Context, id uint) (*model.Role, error)
@@ -33,6 +40,8 @@ type RBACService interface {
 	ListRolesFiltered(ctx context.Context, operatorID uint, domain string, pagination *database.Pagination) ([]model.Role, error) // 新增：带等级过滤
 	SearchRoles(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Role, error)
 	SearchRolesFiltered(ctx context.Context, operatorID uint, keyword, domain string, pagination *database.Pagination) ([]model.Role, error) // 新增：带等级过滤
+	ListAssignableRoles(ctx context.Context, operatorID uint, domain string) ([]model.Role, error)                                           // 操作者可分配的角色
+	ExportRoles(ctx context.Context, domain string, maxLevel, batchSize int) iter.Seq2[model.Role, error]                                    // 流式导出等级低于 maxLevel 的角色
 
 	// 权限管理
 	CreatePermission(ctx context.Context, permission *model.Permission) error
@@ -43,18 +52,29 @@ type RBACService interface {
 	ListPermissionsByType(ctx context.Context, permType model.PermissionType, domain string) ([]model.Permission, error)
 	ListPermissionsTree(ctx context.Context, domain string) ([]model.Permission, error)
 	SearchPermissions(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Permission, error)
+	ValidatePermissionHierarchy(ctx context.Context, domain string, maxDepth int) (*PermissionHierarchyReport, error)
+	ListPermissionsGrouped(ctx context.Context, domain string) ([]PermissionCategoryGroup, error)
 
 	// 角色-权限管理
-	UpdateRolePermissions(ctx context.Context, roleID uint, permissionIDs []uint, domain string, preview bool) (interface{}, error)
-	AssignPermissionsToRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) error
-	RevokePermissionsFromRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) error
+	UpdateRolePermissions(ctx context.Context, roleID uint, permissionIDs []uint, domain string, preview bool) (*PermissionDiff, *ChangeResult, error)
+	AssignPermissionsToRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) (*AssignResult, error)
+	RevokePermissionsFromRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) (int, error)
 	GetRolePermissions(ctx context.Context, roleID uint, domain string) ([]model.Permission, error)
+	GetRoleEffectivePermissions(ctx context.Context, roleID uint, domain string) (*RoleEffectivePermissions, error)
+	SimulateRolePermissionChange(ctx context.Context, roleID uint, newPermissionIDs []uint, domain string) (SimulationResult, error)
+
+	// 角色继承管理（g2）
+	AddRoleParent(ctx context.Context, roleID, parentID uint, domain string) error
+	RemoveRoleParent(ctx context.Context, roleID, parentID uint, domain string) error
+	DetectCycles(ctx context.Context, domain string) ([][]string, error)
 
 	// 用户-角色管理
-	AssignRolesToUser(ctx context.Context, userID uint, roleIDs []uint, domain string, assignedBy uint) error
+	AssignRolesToUser(ctx context.Context, userID uint, roleIDs []uint, domain string, assignedBy uint, expiresAt *time.Time) (*AssignResult, error)
 	RevokeRolesFromUser(ctx context.Context, userID uint, roleIDs []uint, domain string) error
 	GetUserRoles(ctx context.Context, userID uint, domain string) ([]model.Role, error)
+	GetRolesForUsers(ctx context.Context, userIDs []uint, domain string) (map[uint][]model.Role, error)
 	GetRoleUsers(ctx context.Context, roleID uint) ([]model.UserRole, error)
+	PurgeExpiredUserRoles(ctx context.Context) (int, error)
 
 	// 权限验证
 	CheckPermission(ctx context.Context, userID uint, domain, resource, action string) (bool, error)
@@ -64,11 +84,158 @@ type RBACService interface {
 	AddPolicy(ctx context.Context, sub, dom, obj, act string) error
 	RemovePolicy(ctx context.Context, sub, dom, obj, act string) error
 	ListPolicies(ctx context.Context, domain string) ([][]string, error)
+	ListUserPolicies(ctx context.Context, userID uint, domain string, pagination *database.Pagination) ([]UserPolicy, error)
+	ReplaceRolePolicies(ctx context.Context, roleID uint, domain string, policies [][2]string) error
+	ReconcilePolicies(ctx context.Context, repair bool) (*PolicyReconcileReport, error)
 
 	// 安全检查（权限越级保护）
 	GetUserMaxRoleLevel(ctx context.Context, userID uint, domain string) (int, error)
 	CheckRoleLevelPermission(ctx context.Context, operatorID uint, targetRoleID uint, domain string) error
 	CheckRolesLevelPermission(ctx context.Context, operatorID uint, targetRoleIDs []uint, domain string) error
+	CheckRoleLevelAssignable(ctx context.Context, operatorID uint, level int, domain string) error
+}
+
+// ErrRoleInheritanceCycle 添加的角色继承关系会形成环
+var ErrRoleInheritanceCycle = errors.New("role inheritance would create a cycle")
+
+// ErrRoleLevelOutOfRange 角色等级不在 1 到所在域的等级上限之间
+var ErrRoleLevelOutOfRange = errors.New("role level out of range")
+
+// ErrInvalidPermissionParent 权限的父节点无效（指向自身、不存在或会形成环）
+var ErrInvalidPermissionParent = errors.New("invalid permission parent")
+
+// ErrTooManyPermissions 分配后角色的权限数超过所在域的上限
+var ErrTooManyPermissions = errors.New("too many permissions for role")
+
+// ErrPartialAssignment 部分ID不存在或不属于目标域，未做任何修改
+// 开启 rbac.reject_partial_assignment 或全部ID均无效时返回，同时返回 *AssignResult 列出问题ID
+var ErrPartialAssignment = errors.New("some ids cannot be assigned")
+
+// 权限层级问题类型
+const (
+	HierarchyIssueSelfReference = "self_reference" // parent_id 指向自身
+	HierarchyIssueOrphan        = "orphan"         // parent_id 指向不存在、已删除或已禁用的权限，树中作为根节点返回
+	HierarchyIssueCycle         = "cycle"          // 父子链形成环，树中从环上首个未访问的节点断开作为根节点返回
+	HierarchyIssueTooDeep       = "too_deep"       // 层级超过最大深度，树接口返回时会被裁剪
+)
+
+// PermissionHierarchyIssue 权限层级问题
+type PermissionHierarchyIssue struct {
+	Type         string `json:"type"`
+	PermissionID uint   `json:"permission_id"`
+	Name         string `json:"name"`
+	ParentID     uint   `json:"parent_id"`
+	Depth        int    `json:"depth,omitempty"` // too_deep 时为实际深度
+	Cycle        []uint `json:"cycle,omitempty"` // cycle 时为环上的权限ID（从子到父）
+}
+
+// SuperAdminBypassLevel 配置指定的超级管理员的等级，高于任何角色等级
+// 无论是否分配角色都可查看、分配和操作所有角色（角色等级上限校验仍然生效）
+const SuperAdminBypassLevel = math.MaxInt32
+
+// PermissionDiff 角色权限变更预览（UpdateRolePermissions 预览模式）
+type PermissionDiff struct {
+	Added   []model.Permission `json:"added"`   // 将要添加的权限
+	Removed []model.Permission `json:"removed"` // 将要删除的权限
+	Kept    []model.Permission `json:"kept"`    // 将要保留的权限
+}
+
+// ChangeResult 角色权限变更结果（UpdateRolePermissions 执行模式）
+type ChangeResult struct {
+	AddedCount   int `json:"added_count"`   // 添加的权限数量
+	RemovedCount int `json:"removed_count"` // 删除的权限数量
+}
+
+// UncategorizedPermissionCategory 未设置分类的权限归入的分组
+const UncategorizedPermissionCategory = "uncategorized"
+
+// PermissionCategoryGroup 按分类分组的权限
+type PermissionCategoryGroup struct {
+	Category    string             `json:"category"`
+	Permissions []model.Permission `json:"permissions"`
+}
+
+// PermissionHierarchyReport 权限层级校验结果
+type PermissionHierarchyReport struct {
+	Domain   string                     `json:"domain"`
+	Total    int                        `json:"total"`     // 参与校验的启用权限数
+	MaxDepth int                        `json:"max_depth"` // 实际最大深度（根节点为第1层）
+	Valid    bool                       `json:"valid"`
+	Issues   []PermissionHierarchyIssue `json:"issues"`
+}
+
+// InvalidPermissionIDsError 请求的权限ID校验失败
+// 列出不存在、不属于目标域或重复出现的权限ID，调用方可通过 errors.As 获取明细
+type InvalidPermissionIDsError struct {
+	NotFound    []uint `json:"not_found,omitempty"`    // 不存在的权限ID
+	WrongDomain []uint `json:"wrong_domain,omitempty"` // 不属于目标域的权限ID
+	Duplicates  []uint `json:"duplicates,omitempty"`   // 重复出现的权限ID
+}
+
+func (e *InvalidPermissionIDsError) Error() string {
+	return fmt.Sprintf("invalid permission ids: not_found=%v wrong_domain=%v duplicates=%v", e.NotFound, e.WrongDomain, e.Duplicates)
+}
+
+// AssignResult 批量分配的结果
+// 不存在或不属于目标域的ID不会分配，按原因列出，调用方据此判断是否部分成功
+type AssignResult struct {
+	Assigned    []uint `json:"assigned"`               // 已分配的ID
+	NotFound    []uint `json:"not_found,omitempty"`    // 不存在的ID
+	WrongDomain []uint `json:"wrong_domain,omitempty"` // 不属于目标域的ID
+}
+
+// Partial 是否有ID未分配
+func (r *AssignResult) Partial() bool {
+	return len(r.NotFound) > 0 || len(r.WrongDomain) > 0
+}
+
+// splitByDomain 按请求顺序拆分出属于 domain 的条目，其余ID按原因记入结果；重复ID只处理一次
+func splitByDomain[T any](requested []uint, items []T, key func(T) (uint, string), domain string) ([]T, *AssignResult) {
+	byID := make(map[uint]T, len(items))
+	for _, item := range items {
+		id, _ := key(item)
+		byID[id] = item
+	}
+
+	result := &AssignResult{Assigned: []uint{}}
+	matched := make([]T, 0, len(items))
+	seen := make(map[uint]bool, len(requested))
+	for _, id := range requested {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		item, ok := byID[id]
+		if !ok {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
+		if _, itemDomain := key(item); itemDomain != domain {
+			result.WrongDomain = append(result.WrongDomain, id)
+			continue
+		}
+		matched = append(matched, item)
+		result.Assigned = append(result.Assigned, id)
+	}
+	return matched, result
+}
+
+// checkPartialAssignment 存在未分配的ID时记录告警；开启 reject_partial_assignment 或没有任何可分配的ID时拒绝整个请求
+func (s *rbacService) checkPartialAssignment(result *AssignResult, kind, domain string) error {
+	if !result.Partial() {
+		return nil
+	}
+	s.logger.Warn(kind+" skipped in assignment",
+		"domain", domain,
+		"not_found", result.NotFound,
+		"wrong_domain", result.WrongDomain,
+	)
+	if s.config.RejectPartialAssignment || len(result.Assigned) == 0 {
+		result.Assigned = []uint{}
+		return fmt.Errorf("%w: not_found=%v wrong_domain=%v", ErrPartialAssignment, result.NotFound, result.WrongDomain)
+	}
+	return nil
 }
 
 // rbacService RBAC服务实现
@@ -77,9 +244,11 @@ type rbacService struct {
 	roleRepo     repository.RoleRepository       // 角色数据仓储
 	permRepo     repository.PermissionRepository // 权限数据仓储
 	userRoleRepo repository.UserRoleRepository   // 用户角色关联仓储
+	outbox       repository.OutboxRepository     // 变更事件发件箱，为 nil 时不写入事件
 	db           *database.Database              // 数据库实例（用于直接操作关联表）
 	cache        *cache.CacheManager             // Redis缓存管理器
 	logger       *slog.Logger                    // 日志记录器
+	config       *config.RBACConfig              // 角色等级上限等规则
 }
 
 const (
@@ -91,22 +260,27 @@ const (
 )
 
 // NewRBACService 创建RBAC服务实例
+// outbox 不为 nil 时，角色/权限变更会在同一事务中写入 RBACChangedTaskName 事件
 func NewRBACService(
 	enforcer *casbin.Enforcer,
 	roleRepo repository.RoleRepository,
 	permRepo repository.PermissionRepository,
 	userRoleRepo repository.UserRoleRepository,
+	outbox repository.OutboxRepository,
 	db *database.Database,
 	logger *slog.Logger,
+	cfg *config.RBACConfig,
 ) RBACService {
 	return &rbacService{
 		enforcer:     enforcer,
 		roleRepo:     roleRepo,
 		permRepo:     permRepo,
 		userRoleRepo: userRoleRepo,
+		outbox:       outbox,
 		db:           db,
-		cache:        cache.NewCacheManager(),
+		cache:        cache.NewCacheManager("rbac"),
 		logger:       logger,
+		config:       cfg,
 	}
 }
 
@@ -125,9 +299,22 @@ func (s *rbacService) CreateRole(ctx context.Context, role *model.Role) error {
 		return fmt.Errorf("role name %s already exists in domain %s", role.Name, role.Domain)
 	}
 
-	// 创建角色
-	if err := s.roleRepo.Create(ctx, role); err != nil {
-		return fmt.Errorf("failed to create role: %w", err)
+	// 未指定等级时使用默认等级，避免等级为 0 的角色被等级过滤隐藏且无法管理
+	if role.Level == 0 {
+		role.Level = model.DefaultRoleLevel
+	}
+	if err := s.checkRoleLevelCeiling(role.Level, role.Domain); err != nil {
+		return err
+	}
+
+	// 创建角色并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.Create(ctx, role); err != nil {
+			return fmt.Errorf("failed to create role: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRoleCreated, Domain: role.Domain, RoleID: role.ID})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("role created",
@@ -139,14 +326,61 @@ func (s *rbacService) CreateRole(ctx context.Context, role *model.Role) error {
 	return nil
 }
 
+// GetOrCreateRole 按 (name, domain) 获取角色，不存在时创建，返回角色及是否由本次调用创建
+// 基于唯一索引的 upsert 实现，可安全地在种子脚本、导入等场景中并发调用；
+// 角色已存在时原样返回，不会用 role 中的字段覆盖
+func (s *rbacService) GetOrCreateRole(ctx context.Context, role *model.Role) (*model.Role, bool, error) {
+	if role.Level == 0 {
+		role.Level = model.DefaultRoleLevel
+	}
+	if err := s.checkRoleLevelCeiling(role.Level, role.Domain); err != nil {
+		return nil, false, err
+	}
+
+	var (
+		result  *model.Role
+		created bool
+	)
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, created, err = s.roleRepo.GetOrCreate(ctx, role)
+		if err != nil {
+			return fmt.Errorf("failed to get or create role: %w", err)
+		}
+		if !created {
+			return nil
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRoleCreated, Domain: result.Domain, RoleID: result.ID})
+	}); err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		s.logger.Info("role created",
+			"role_id", result.ID,
+			"role_name", result.Name,
+			"domain", result.Domain,
+		)
+	}
+
+	return result, created, nil
+}
+
 // UpdateRole 更新角色
 func (s *rbacService) UpdateRole(ctx context.Context, role *model.Role) error {
 	// 检查角色是否存在
-	_, err := s.roleRepo.FindByID(ctx, role.ID)
+	oldRole, err := s.roleRepo.FindByID(ctx, role.ID)
 	if err != nil {
 		return fmt.Errorf("role not found: %w", err)
 	}
 
+	// 等级未变化时不校验，上限调低后已有的高等级角色仍可修改其他字段
+	if role.Level != oldRole.Level {
+		if err := s.checkRoleLevelCeiling(role.Level, role.Domain); err != nil {
+			return err
+		}
+	}
+
 	// 检查角色名称是否重复
 	exists, err := s.roleRepo.ExistsByName(ctx, role.Name, role.Domain, role.ID)
 	if err != nil {
@@ -156,9 +390,14 @@ func (s *rbacService) UpdateRole(ctx context.Context, role *model.Role) error {
 		return fmt.Errorf("role name %s already exists in domain %s", role.Name, role.Domain)
 	}
 
-	// 更新角色
-	if err := s.roleRepo.Update(ctx, role); err != nil {
-		return fmt.Errorf("failed to update role: %w", err)
+	// 更新角色并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.Update(ctx, role); err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRoleUpdated, Domain: role.Domain, RoleID: role.ID})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("role updated",
@@ -204,9 +443,14 @@ func (s *rbacService) DeleteRole(ctx context.Context, id uint) error {
 		}
 	}
 
-	// 删除角色记录
-	if err := s.roleRepo.Delete(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete role: %w", err)
+	// 删除角色记录并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete role: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRoleDeleted, Domain: role.Domain, RoleID: id})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("role deleted",
@@ -238,44 +482,44 @@ func (s *rbacService) ListRolesFiltered(ctx context.Context, operatorID uint, do
 		return nil, fmt.Errorf("failed to get operator level: %w", err)
 	}
 
-	s.logger.Info("ListRolesFiltered: 开始过滤",
+	// 2. 等级过滤下推到 SQL：计数与分页都只针对可见角色，总数与各页数据一致
+	roles, err := s.roleRepo.List(ctx, domain, pagination, repository.RoleLevelBelow(operatorLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("ListRolesFiltered: 过滤完成",
 		"operatorID", operatorID,
 		"operatorLevel", operatorLevel,
 		"domain", domain,
+		"total", pagination.Total,
+		"count", len(roles),
 	)
 
-	// 2. 查询所有角色
-	roles, err := s.roleRepo.List(ctx, domain, pagination)
+	return roles, nil
+}
+
+// ListAssignableRoles 获取操作者可分配的角色
+// 与 ListRolesFiltered 规则一致：只返回等级严格低于操作者的启用角色（不分页，供"分配角色"界面使用）
+func (s *rbacService) ListAssignableRoles(ctx context.Context, operatorID uint, domain string) ([]model.Role, error) {
+	operatorLevel, err := s.GetUserMaxRoleLevel(ctx, operatorID, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operator level: %w", err)
+	}
+
+	roles, err := s.roleRepo.ListByDomain(ctx, domain)
 	if err != nil {
 		return nil, err
 	}
 
-	s.logger.Info("ListRolesFiltered: 查询到角色",
-		"totalRoles", len(roles),
-	)
-
-	// 3. 过滤：只返回等级严格低于操作者的角色
-	filteredRoles := make([]model.Role, 0)
+	assignable := make([]model.Role, 0, len(roles))
 	for _, role := range roles {
-		s.logger.Info("ListRolesFiltered: 检查角色",
-			"roleName", role.Name,
-			"roleLevel", role.Level,
-			"operatorLevel", operatorLevel,
-			"willInclude", role.Level < operatorLevel,
-		)
 		if role.Level < operatorLevel {
-			filteredRoles = append(filteredRoles, role)
+			assignable = append(assignable, role)
 		}
 	}
 
-	s.logger.Info("ListRolesFiltered: 过滤完成",
-		"filteredCount", len(filteredRoles),
-	)
-
-	// 4. 更新分页信息（过滤后的总数）
-	pagination.Total = int64(len(filteredRoles))
-
-	return filteredRoles, nil
+	return assignable, nil
 }
 
 // SearchRoles 搜索角色（无权限过滤，仅供内部使用）
@@ -292,45 +536,22 @@ func (s *rbacService) SearchRolesFiltered(ctx context.Context, operatorID uint,
 		return nil, fmt.Errorf("failed to get operator level: %w", err)
 	}
 
-	s.logger.Info("SearchRolesFiltered: 开始过滤",
-		"operatorID", operatorID,
-		"operatorLevel", operatorLevel,
-		"keyword", keyword,
-		"domain", domain,
-	)
-
-	// 2. 搜索所有匹配的角色
-	roles, err := s.roleRepo.Search(ctx, keyword, domain, pagination)
+	// 2. 等级过滤下推到 SQL，与关键词条件一起参与计数与分页
+	roles, err := s.roleRepo.Search(ctx, keyword, domain, pagination, repository.RoleLevelBelow(operatorLevel))
 	if err != nil {
 		return nil, err
 	}
 
-	s.logger.Info("SearchRolesFiltered: 查询到角色",
-		"totalRoles", len(roles),
-	)
-
-	// 3. 过滤：只返回等级严格低于操作者的角色
-	filteredRoles := make([]model.Role, 0)
-	for _, role := range roles {
-		s.logger.Info("SearchRolesFiltered: 检查角色",
-			"roleName", role.Name,
-			"roleLevel", role.Level,
-			"operatorLevel", operatorLevel,
-			"willInclude", role.Level < operatorLevel,
-		)
-		if role.Level < operatorLevel {
-			filteredRoles = append(filteredRoles, role)
-		}
-	}
-
 	s.logger.Info("SearchRolesFiltered: 过滤完成",
-		"filteredCount", len(filteredRoles),
+		"operatorID", operatorID,
+		"operatorLevel", operatorLevel,
+		"keyword", keyword,
+		"domain", domain,
+		"total", pagination.Total,
+		"count", len(roles),
 	)
 
-	// 4. 更新分页信息（过滤后的总数）
-	pagination.Total = int64(len(filteredRoles))
-
-	return filteredRoles, nil
+	return roles, nil
 }
 
 // ============================
@@ -348,9 +569,18 @@ func (s *rbacService) CreatePermission(ctx context.Context, permission *model.Pe
 		return fmt.Errorf("permission name %s already exists in domain %s", permission.Name, permission.Domain)
 	}
 
-	// 创建权限
-	if err := s.permRepo.Create(ctx, permission); err != nil {
-		return fmt.Errorf("failed to create permission: %w", err)
+	if err := s.checkPermissionParent(ctx, permission); err != nil {
+		return err
+	}
+
+	// 创建权限并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.permRepo.Create(ctx, permission); err != nil {
+			return fmt.Errorf("failed to create permission: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACPermissionCreated, Domain: permission.Domain, PermissionID: permission.ID})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("permission created",
@@ -379,6 +609,12 @@ func (s *rbacService) UpdatePermission(ctx context.Context, permission *model.Pe
 		return fmt.Errorf("permission name %s already exists in domain %s", permission.Name, permission.Domain)
 	}
 
+	if permission.ParentID != oldPerm.ParentID {
+		if err := s.checkPermissionParent(ctx, permission); err != nil {
+			return err
+		}
+	}
+
 	// 如果资源或操作发生变化，需要更新 Casbin 策略
 	if oldPerm.Resource != permission.Resource || oldPerm.Action != permission.Action {
 		// 获取所有使用该权限的角色
@@ -403,9 +639,14 @@ func (s *rbacService) UpdatePermission(ctx context.Context, permission *model.Pe
 		}
 	}
 
-	// 更新权限记录
-	if err := s.permRepo.Update(ctx, permission); err != nil {
-		return fmt.Errorf("failed to update permission: %w", err)
+	// 更新权限记录并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.permRepo.Update(ctx, permission); err != nil {
+			return fmt.Errorf("failed to update permission: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACPermissionUpdated, Domain: permission.Domain, PermissionID: permission.ID})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("permission updated",
@@ -417,6 +658,137 @@ func (s *rbacService) UpdatePermission(ctx context.Context, permission *model.Pe
 	return nil
 }
 
+// checkPermissionParent 校验父权限：不能指向自身、必须存在且属于同一域、不能形成环
+func (s *rbacService) checkPermissionParent(ctx context.Context, permission *model.Permission) error {
+	if permission.ParentID == 0 {
+		return nil
+	}
+	if permission.ID != 0 && permission.ParentID == permission.ID {
+		return fmt.Errorf("%w: permission cannot be its own parent", ErrInvalidPermissionParent)
+	}
+
+	parent, err := s.permRepo.FindByID(ctx, permission.ParentID)
+	if err != nil {
+		return fmt.Errorf("%w: parent permission %d not found", ErrInvalidPermissionParent, permission.ParentID)
+	}
+	if parent.Domain != permission.Domain {
+		return fmt.Errorf("%w: parent permission %d belongs to domain %s", ErrInvalidPermissionParent, parent.ID, parent.Domain)
+	}
+	if permission.ID == 0 {
+		return nil
+	}
+
+	// 沿父链向上查找，遇到自身说明会形成环；已有的环由 seen 截断
+	seen := map[uint]bool{permission.ID: true}
+	for cur := parent; cur.ParentID != 0; {
+		if cur.ParentID == permission.ID {
+			return fmt.Errorf("%w: permission %d is an ancestor of %d", ErrInvalidPermissionParent, permission.ID, parent.ID)
+		}
+		if seen[cur.ParentID] {
+			break
+		}
+		seen[cur.ParentID] = true
+		next, err := s.permRepo.FindByID(ctx, cur.ParentID)
+		if err != nil {
+			break
+		}
+		cur = next
+	}
+	return nil
+}
+
+// ValidatePermissionHierarchy 校验域内启用权限的父子层级
+// 报告自引用、孤儿节点（父节点不存在/已删除/已禁用）、环以及超过 maxDepth 的节点（maxDepth <= 0 时不检查深度）
+func (s *rbacService) ValidatePermissionHierarchy(ctx context.Context, domain string, maxDepth int) (*PermissionHierarchyReport, error) {
+	permissions, err := s.permRepo.ListActive(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].ID < permissions[j].ID })
+
+	byID := make(map[uint]*model.Permission, len(permissions))
+	for i := range permissions {
+		byID[permissions[i].ID] = &permissions[i]
+	}
+
+	report := &PermissionHierarchyReport{
+		Domain: domain,
+		Total:  len(permissions),
+		Issues: []PermissionHierarchyIssue{},
+	}
+	issue := func(typ string, p *model.Permission) PermissionHierarchyIssue {
+		return PermissionHierarchyIssue{Type: typ, PermissionID: p.ID, Name: p.Name, ParentID: p.ParentID}
+	}
+
+	for i := range permissions {
+		p := &permissions[i]
+		if p.ParentID == p.ID {
+			report.Issues = append(report.Issues, issue(HierarchyIssueSelfReference, p))
+		} else if _, ok := byID[p.ParentID]; p.ParentID != 0 && !ok {
+			report.Issues = append(report.Issues, issue(HierarchyIssueOrphan, p))
+		}
+	}
+
+	// 沿父链向上计算深度：state 1 表示在当前链上（再次遇到即为环），2 表示深度已确定
+	state := make(map[uint]int8, len(permissions))
+	depth := make(map[uint]int, len(permissions))
+	for i := range permissions {
+		if state[permissions[i].ID] == 2 {
+			continue
+		}
+
+		var chain []uint
+		base := 0
+		for cur := permissions[i].ID; ; {
+			if state[cur] == 2 {
+				base = depth[cur]
+				break
+			}
+			if state[cur] == 1 {
+				at := slices.Index(chain, cur)
+				cycle := slices.Clone(chain[at:])
+				c := issue(HierarchyIssueCycle, byID[slices.Min(cycle)])
+				c.Cycle = cycle
+				report.Issues = append(report.Issues, c)
+				for _, id := range cycle {
+					state[id] = 2
+					depth[id] = 1
+				}
+				chain = chain[:at]
+				base = 1
+				break
+			}
+
+			node := byID[cur]
+			state[cur] = 1
+			chain = append(chain, cur)
+			if _, ok := byID[node.ParentID]; node.ParentID == 0 || node.ParentID == node.ID || !ok {
+				break
+			}
+			cur = node.ParentID
+		}
+		for j := len(chain) - 1; j >= 0; j-- {
+			base++
+			depth[chain[j]] = base
+			state[chain[j]] = 2
+		}
+	}
+
+	for i := range permissions {
+		p := &permissions[i]
+		d := depth[p.ID]
+		report.MaxDepth = max(report.MaxDepth, d)
+		if maxDepth > 0 && d > maxDepth {
+			tooDeep := issue(HierarchyIssueTooDeep, p)
+			tooDeep.Depth = d
+			report.Issues = append(report.Issues, tooDeep)
+		}
+	}
+
+	report.Valid = len(report.Issues) == 0
+	return report, nil
+}
+
 // DeletePermission 删除权限
 func (s *rbacService) DeletePermission(ctx context.Context, id uint) error {
 	// 检查权限是否存在
@@ -445,9 +817,14 @@ func (s *rbacService) DeletePermission(ctx context.Context, id uint) error {
 		}
 	}
 
-	// 删除权限记录
-	if err := s.permRepo.Delete(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete permission: %w", err)
+	// 删除权限记录并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.permRepo.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete permission: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACPermissionDeleted, Domain: permission.Domain, PermissionID: id})
+	}); err != nil {
+		return err
 	}
 
 	s.logger.Info("permission deleted",
@@ -484,6 +861,52 @@ func (s *rbacService) ListPermissionsTree(ctx context.Context, domain string) ([
 	return s.permRepo.ListTree(ctx, domain)
 }
 
+// ListPermissionsGrouped 按分类分组返回启用的权限
+// 一次查询后在内存中分组：组内按 sort、id 升序，分组按组内最小 sort 排序（相同时按分类名），未分类的权限放在最后
+func (s *rbacService) ListPermissionsGrouped(ctx context.Context, domain string) ([]PermissionCategoryGroup, error) {
+	permissions, err := s.permRepo.ListActive(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	sort.SliceStable(permissions, func(i, j int) bool {
+		if permissions[i].Sort != permissions[j].Sort {
+			return permissions[i].Sort < permissions[j].Sort
+		}
+		return permissions[i].ID < permissions[j].ID
+	})
+
+	index := make(map[string]int)
+	var groups []PermissionCategoryGroup
+	for _, permission := range permissions {
+		category := strings.TrimSpace(permission.Category)
+		if category == "" {
+			category = UncategorizedPermissionCategory
+		}
+		i, ok := index[category]
+		if !ok {
+			i = len(groups)
+			index[category] = i
+			groups = append(groups, PermissionCategoryGroup{Category: category})
+		}
+		groups[i].Permissions = append(groups[i].Permissions, permission)
+	}
+
+	// 权限已按 sort 排序，组内第一个即最小 sort
+	sort.SliceStable(groups, func(i, j int) bool {
+		gi, gj := groups[i], groups[j]
+		if (gi.Category == UncategorizedPermissionCategory) != (gj.Category == UncategorizedPermissionCategory) {
+			return gj.Category == UncategorizedPermissionCategory
+		}
+		if gi.Permissions[0].Sort != gj.Permissions[0].Sort {
+			return gi.Permissions[0].Sort < gj.Permissions[0].Sort
+		}
+		return gi.Category < gj.Category
+	})
+
+	return groups, nil
+}
+
 // SearchPermissions 搜索权限
 func (s *rbacService) SearchPermissions(ctx context.Context, keyword, domain string, pagination *database.Pagination) ([]model.Permission, error) {
 	return s.permRepo.Search(ctx, keyword, domain, pagination)
@@ -496,23 +919,33 @@ func (s *rbacService) SearchPermissions(ctx context.Context, keyword, domain str
 // ============================
 
 // UpdateRolePermissions 更新角色权限（支持预览和执行）
-// preview=true: 返回PermissionDiff（预览变更）
-// preview=false: 返回ChangeResult（执行变更）
-func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, permissionIDs []uint, domain string, preview bool) (interface{}, error) {
+// preview=true: 返回PermissionDiff（预览变更），ChangeResult 为 nil
+// preview=false: 返回ChangeResult（执行变更），PermissionDiff 为 nil
+func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, permissionIDs []uint, domain string, preview bool) (*PermissionDiff, *ChangeResult, error) {
 	// 1. 检查角色是否存在
 	role, err := s.roleRepo.FindByID(ctx, roleID)
 	if err != nil {
-		return nil, fmt.Errorf("role not found: %w", err)
+		return nil, nil, fmt.Errorf("role not found: %w", err)
 	}
 
 	if role.Domain != domain {
-		return nil, fmt.Errorf("role domain mismatch")
+		return nil, nil, fmt.Errorf("role domain mismatch")
+	}
+
+	// 2. 校验请求的权限ID全部存在且属于该域，避免部分生效
+	if err := s.validatePermissionIDs(ctx, permissionIDs, domain); err != nil {
+		return nil, nil, err
 	}
 
-	// 2. 获取当前权限列表
+	// 请求即变更后的完整权限集合，预览时同样校验数量上限
+	if err := s.checkRolePermissionLimit(permissionIDs, domain); err != nil {
+		return nil, nil, err
+	}
+
+	// 获取当前权限列表
 	currentPerms, err := s.GetRolePermissions(ctx, roleID, domain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current permissions: %w", err)
+		return nil, nil, fmt.Errorf("failed to get current permissions: %w", err)
 	}
 
 	// 3. 构建当前权限ID集合
@@ -552,12 +985,12 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 		if len(toAddIDs) > 0 {
 			added, err = s.permRepo.ListByIDs(ctx, toAddIDs)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get added permissions: %w", err)
+				return nil, nil, fmt.Errorf("failed to get added permissions: %w", err)
 			}
 			// 验证域匹配
 			for _, perm := range added {
 				if perm.Domain != domain {
-					return nil, fmt.Errorf("permission domain mismatch: %s (expected: %s, got: %s)", perm.Name, domain, perm.Domain)
+					return nil, nil, fmt.Errorf("permission domain mismatch: %s (expected: %s, got: %s)", perm.Name, domain, perm.Domain)
 				}
 			}
 		}
@@ -566,7 +999,7 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 		if len(toRemoveIDs) > 0 {
 			removed, err = s.permRepo.ListByIDs(ctx, toRemoveIDs)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get removed permissions: %w", err)
+				return nil, nil, fmt.Errorf("failed to get removed permissions: %w", err)
 			}
 		}
 
@@ -577,10 +1010,10 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 			}
 		}
 
-		diff := map[string]interface{}{
-			"added":   added,
-			"removed": removed,
-			"kept":    kept,
+		diff := &PermissionDiff{
+			Added:   added,
+			Removed: removed,
+			Kept:    kept,
 		}
 
 		s.logger.Info("preview permission changes",
@@ -591,19 +1024,18 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 			"kept_count", len(kept),
 		)
 
-		return diff, nil
+		return diff, nil, nil
 	}
 
 	// 7. 执行模式：应用变更
 	if len(toAddIDs) == 0 && len(toRemoveIDs) == 0 {
-		return map[string]interface{}{
-			"added_count":   0,
-			"removed_count": 0,
-		}, nil
+		return nil, &ChangeResult{}, nil
 	}
 
-	// 使用事务确保原子性
-	err = s.db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	// 使用事务确保原子性（存在请求事务时复用），变更事件在同一事务中写入
+	err = s.db.RunInTx(ctx, func(ctx context.Context) error {
+		tx := s.db.Conn(ctx)
+
 		// 删除权限
 		if len(toRemoveIDs) > 0 {
 			removePerms, err := s.permRepo.ListByIDs(ctx, toRemoveIDs)
@@ -632,24 +1064,26 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 			}
 		}
 
-		return nil
+		return s.publishChange(ctx, RBACChangeEvent{
+			Action:  RBACRolePermissionsChanged,
+			Domain:  domain,
+			RoleID:  roleID,
+			Added:   toAddIDs,
+			Removed: toRemoveIDs,
+		})
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// 8. 清理缓存
-	roleCacheKey := fmt.Sprintf(cacheKeyRolePermissions, roleID, domain)
-	if err := cache.Del(ctx, roleCacheKey); err != nil {
-		s.logger.Warn("failed to delete role permissions cache", "error", err)
-	}
-	s.clearUserPermissionsCacheByRole(ctx, roleID, domain)
+	// 8. 清理缓存（处于请求事务中时在提交后执行）
+	s.invalidateRolePermissionsAfterCommit(ctx, roleID, domain)
 
 	// 9. 返回变更结果
-	result := map[string]interface{}{
-		"added_count":   len(toAddIDs),
-		"removed_count": len(toRemoveIDs),
+	result := &ChangeResult{
+		AddedCount:   len(toAddIDs),
+		RemovedCount: len(toRemoveIDs),
 	}
 
 	s.logger.Info("permissions updated for role",
@@ -660,109 +1094,299 @@ func (s *rbacService) UpdateRolePermissions(ctx context.Context, roleID uint, pe
 		"domain", domain,
 	)
 
-	return result, nil
+	return nil, result, nil
 }
 
-// AssignPermissionsToRole 给角色分配权限（已废弃，保留向后兼容）
-// @Deprecated 请使用 UpdateRolePermissions 替代
-// 方案A实现：直接操作RBAC表（role_permissions），Casbin从RBAC表自动同步
-func (s *rbacService) AssignPermissionsToRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) error {
-	// 检查角色是否存在
+// SimulationResult 角色权限变更模拟结果
+type SimulationResult struct {
+	RoleID        uint                   `json:"role_id"`
+	Added         []model.Permission     `json:"added"`          // 角色将新增的权限
+	Removed       []model.Permission     `json:"removed"`        // 角色将移除的权限
+	AffectedUsers []UserPermissionChange `json:"affected_users"` // 有效权限实际发生变化的用户
+	TotalUsers    int                    `json:"total_users"`    // 持有该角色的用户总数
+}
+
+// UserPermissionChange 单个用户的有效权限变化
+type UserPermissionChange struct {
+	UserID   uint               `json:"user_id"`
+	Username string             `json:"username"`
+	Gained   []model.Permission `json:"gained"` // 变更后新获得的权限
+	Lost     []model.Permission `json:"lost"`   // 变更后失去的权限
+}
+
+// SimulateRolePermissionChange 模拟角色权限变更对已分配用户的影响（不落库）
+// 与 UpdateRolePermissions 的预览不同，这里会计入用户其他角色提供的权限：
+// 若某权限同时由其他角色授予，则移除时用户不会失去，新增时用户也不算获得
+func (s *rbacService) SimulateRolePermissionChange(ctx context.Context, roleID uint, newPermissionIDs []uint, domain string) (SimulationResult, error) {
+	result := SimulationResult{
+		RoleID:        roleID,
+		Added:         []model.Permission{},
+		Removed:       []model.Permission{},
+		AffectedUsers: []UserPermissionChange{},
+	}
+
 	role, err := s.roleRepo.FindByID(ctx, roleID)
 	if err != nil {
-		return fmt.Errorf("role not found: %w", err)
+		return result, fmt.Errorf("role not found: %w", err)
 	}
-
 	if role.Domain != domain {
-		return fmt.Errorf("role domain mismatch")
+		return result, fmt.Errorf("role domain mismatch")
 	}
 
-	// 查询权限列表（用于验证）
-	permissions, err := s.permRepo.ListByIDs(ctx, permissionIDs)
-	if err != nil {
-		return fmt.Errorf("failed to get permissions: %w", err)
+	if err := s.validatePermissionIDs(ctx, newPermissionIDs, domain); err != nil {
+		return result, err
 	}
 
-	// 验证权限域是否匹配
-	for _, perm := range permissions {
-		if perm.Domain != domain {
-			s.logger.Warn("permission domain mismatch, skipped",
-				"permission_id", perm.ID,
-				"expected_domain", domain,
-				"actual_domain", perm.Domain,
-			)
-			return fmt.Errorf("permission domain mismatch: %s", perm.Name)
-		}
+	// 1. 计算角色自身的权限差异
+	currentPerms, err := s.GetRolePermissions(ctx, roleID, domain)
+	if err != nil {
+		return result, fmt.Errorf("failed to get current permissions: %w", err)
 	}
-
-	// 直接使用GORM关联更新role_permissions表
-	// 使用Association.Replace替换现有的权限关联
-	if err := s.db.DB.WithContext(ctx).Model(role).Association("Permissions").Replace(permissions); err != nil {
-		return fmt.Errorf("failed to assign permissions: %w", err)
+	currentIDs := make(map[uint]bool, len(currentPerms))
+	for _, p := range currentPerms {
+		currentIDs[p.ID] = true
 	}
 
-	// 清理角色权限缓存
-	roleCacheKey := fmt.Sprintf(cacheKeyRolePermissions, roleID, domain)
-	if err := cache.Del(ctx, roleCacheKey); err != nil {
-		s.logger.Warn("failed to delete role permissions cache", "error", err)
+	var newPerms []model.Permission
+	if len(newPermissionIDs) > 0 {
+		newPerms, err = s.permRepo.ListByIDs(ctx, newPermissionIDs)
+		if err != nil {
+			return result, fmt.Errorf("failed to get permissions: %w", err)
+		}
 	}
-
-	// 清理所有拥有该角色的用户的权限缓存
-	s.clearUserPermissionsCacheByRole(ctx, roleID, domain)
-
-	s.logger.Info("permissions assigned to role in RBAC table",
-		"role_id", roleID,
-		"role_name", role.Name,
-		"permission_count", len(permissions),
-		"domain", domain,
-	)
-
-	return nil
-}
-
-// RevokePermissionsFromRole 撤销角色的权限
-// 方案A实现：直接从RBAC表删除关联，Casbin自动同步
-func (s *rbacService) RevokePermissionsFromRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) error {
-	// 检查角色是否存在
-	role, err := s.roleRepo.FindByID(ctx, roleID)
-	if err != nil {
-		return fmt.Errorf("role not found: %w", err)
+	newIDs := make(map[uint]bool, len(newPerms))
+	for _, p := range newPerms {
+		newIDs[p.ID] = true
+		if !currentIDs[p.ID] {
+			result.Added = append(result.Added, p)
+		}
 	}
-
-	if role.Domain != domain {
-		return fmt.Errorf("role domain mismatch")
+	for _, p := range currentPerms {
+		if !newIDs[p.ID] {
+			result.Removed = append(result.Removed, p)
+		}
 	}
 
-	// 查询权限列表
-	permissions, err := s.permRepo.ListByIDs(ctx, permissionIDs)
+	// 2. 逐个用户扣除其他角色已提供的权限
+	userRoles, err := s.userRoleRepo.FindByRole(ctx, roleID)
 	if err != nil {
-		return fmt.Errorf("failed to get permissions: %w", err)
+		return result, fmt.Errorf("failed to get role users: %w", err)
 	}
+	result.TotalUsers = len(userRoles)
 
-	// 从GORM关联删除role_permissions表中的记录
-	if err := s.db.DB.WithContext(ctx).Model(role).Association("Permissions").Delete(permissions); err != nil {
-		return fmt.Errorf("failed to revoke permissions: %w", err)
+	if len(result.Added) == 0 && len(result.Removed) == 0 {
+		return result, nil
 	}
 
-	// 清理角色权限缓存
-	roleCacheKey := fmt.Sprintf(cacheKeyRolePermissions, roleID, domain)
-	if err := cache.Del(ctx, roleCacheKey); err != nil {
-		s.logger.Warn("failed to delete role permissions cache", "error", err)
-	}
+	// 同一角色可能被多个用户持有，缓存其权限集合避免重复查询
+	rolePermIDs := make(map[uint]map[uint]bool)
+	for _, ur := range userRoles {
+		others, err := s.userRoleRepo.FindByUser(ctx, ur.UserID, domain)
+		if err != nil {
+			return result, fmt.Errorf("failed to get user roles: %w", err)
+		}
 
-	// 清理所有拥有该角色的用户的权限缓存
-	s.clearUserPermissionsCacheByRole(ctx, roleID, domain)
+		covered := make(map[uint]bool)
+		for _, other := range others {
+			if other.RoleID == roleID {
+				continue
+			}
+			ids, ok := rolePermIDs[other.RoleID]
+			if !ok {
+				perms, err := s.GetRolePermissions(ctx, other.RoleID, domain)
+				if err != nil {
+					return result, fmt.Errorf("failed to get role permissions: %w", err)
+				}
+				ids = make(map[uint]bool, len(perms))
+				for _, p := range perms {
+					ids[p.ID] = true
+				}
+				rolePermIDs[other.RoleID] = ids
+			}
+			for id := range ids {
+				covered[id] = true
+			}
+		}
 
-	s.logger.Info("permissions revoked from role in RBAC table",
-		"role_id", roleID,
-		"role_name", role.Name,
-		"permission_count", len(permissions),
-		"domain", domain,
-	)
+		change := UserPermissionChange{
+			UserID: ur.UserID,
+			Gained: []model.Permission{},
+			Lost:   []model.Permission{},
+		}
+		if ur.User != nil {
+			change.Username = ur.User.Username
+		}
+		for _, p := range result.Added {
+			if !covered[p.ID] {
+				change.Gained = append(change.Gained, p)
+			}
+		}
+		for _, p := range result.Removed {
+			if !covered[p.ID] {
+				change.Lost = append(change.Lost, p)
+			}
+		}
+
+		if len(change.Gained) > 0 || len(change.Lost) > 0 {
+			result.AffectedUsers = append(result.AffectedUsers, change)
+		}
+	}
+
+	return result, nil
+}
+
+// validatePermissionIDs 批量校验权限ID
+// 任一ID重复、不存在或不属于目标域时返回 *InvalidPermissionIDsError，列出全部问题ID
+func (s *rbacService) validatePermissionIDs(ctx context.Context, permissionIDs []uint, domain string) error {
+	invalid := &InvalidPermissionIDsError{}
+
+	seen := make(map[uint]bool, len(permissionIDs))
+	uniqueIDs := make([]uint, 0, len(permissionIDs))
+	for _, id := range permissionIDs {
+		if seen[id] {
+			invalid.Duplicates = append(invalid.Duplicates, id)
+			continue
+		}
+		seen[id] = true
+		uniqueIDs = append(uniqueIDs, id)
+	}
+
+	if len(uniqueIDs) > 0 {
+		perms, err := s.permRepo.ListByIDs(ctx, uniqueIDs)
+		if err != nil {
+			return fmt.Errorf("failed to get permissions: %w", err)
+		}
 
+		found := make(map[uint]bool, len(perms))
+		for _, perm := range perms {
+			found[perm.ID] = true
+			if perm.Domain != domain {
+				invalid.WrongDomain = append(invalid.WrongDomain, perm.ID)
+			}
+		}
+		for _, id := range uniqueIDs {
+			if !found[id] {
+				invalid.NotFound = append(invalid.NotFound, id)
+			}
+		}
+	}
+
+	if len(invalid.NotFound) > 0 || len(invalid.WrongDomain) > 0 || len(invalid.Duplicates) > 0 {
+		return invalid
+	}
 	return nil
 }
 
+// AssignPermissionsToRole 给角色分配权限（已废弃，保留向后兼容）
+// @Deprecated 请使用 UpdateRolePermissions 替代
+// 方案A实现：直接操作RBAC表（role_permissions），Casbin从RBAC表自动同步
+// 不属于目标域的权限拒绝整个请求；不存在的权限ID跳过并在返回结果中列出
+func (s *rbacService) AssignPermissionsToRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) (*AssignResult, error) {
+	// 检查角色是否存在
+	role, err := s.roleRepo.FindByID(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("role not found: %w", err)
+	}
+
+	if role.Domain != domain {
+		return nil, fmt.Errorf("role domain mismatch")
+	}
+
+	// 查询权限列表（用于验证）
+	found, err := s.permRepo.ListByIDs(ctx, permissionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	// 验证权限域是否匹配：跨域权限始终拒绝，跳过并列出只适用于不存在的ID
+	permissions, result := splitByDomain(permissionIDs, found, func(p model.Permission) (uint, string) { return p.ID, p.Domain }, domain)
+	if len(result.WrongDomain) > 0 {
+		return nil, fmt.Errorf("permission domain mismatch: %v", result.WrongDomain)
+	}
+	if err := s.checkPartialAssignment(result, "permissions", domain); err != nil {
+		return result, err
+	}
+
+	// Replace 语义：分配后的权限集合即本次有效的权限
+	if err := s.checkRolePermissionLimit(result.Assigned, domain); err != nil {
+		return nil, err
+	}
+
+	// 直接使用GORM关联更新role_permissions表
+	// 使用Association.Replace替换现有的权限关联，存在请求事务时在事务内执行
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.db.Conn(ctx).Model(role).Association("Permissions").Replace(permissions); err != nil {
+			return fmt.Errorf("failed to assign permissions: %w", err)
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRolePermissionsReplaced, Domain: domain, RoleID: roleID, Added: result.Assigned})
+	}); err != nil {
+		return nil, err
+	}
+
+	// 清理角色及所有拥有该角色的用户的权限缓存（处于请求事务中时在提交后执行）
+	s.invalidateRolePermissionsAfterCommit(ctx, roleID, domain)
+
+	s.logger.Info("permissions assigned to role in RBAC table",
+		"role_id", roleID,
+		"role_name", role.Name,
+		"permission_count", len(permissions),
+		"domain", domain,
+	)
+
+	return result, nil
+}
+
+// RevokePermissionsFromRole 撤销角色的权限，返回实际撤销的数量（未分配给角色的权限ID忽略）
+// 方案A实现：直接从RBAC表删除关联，Casbin自动同步；缓存在全部撤销后统一清理一次
+func (s *rbacService) RevokePermissionsFromRole(ctx context.Context, roleID uint, permissionIDs []uint, domain string) (int, error) {
+	// 检查角色是否存在
+	role, err := s.roleRepo.FindByID(ctx, roleID)
+	if err != nil {
+		return 0, fmt.Errorf("role not found: %w", err)
+	}
+
+	if role.Domain != domain {
+		return 0, fmt.Errorf("role domain mismatch")
+	}
+
+	// 只撤销当前已分配给角色的权限
+	var assigned []model.Permission
+	if err := s.db.Conn(ctx).Model(role).
+		Where("permissions.id IN ?", permissionIDs).
+		Association("Permissions").Find(&assigned); err != nil {
+		return 0, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	if len(assigned) == 0 {
+		return 0, nil
+	}
+
+	// 从GORM关联删除role_permissions表中的记录，并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := s.db.Conn(ctx).Model(role).Association("Permissions").Delete(assigned); err != nil {
+			return fmt.Errorf("failed to revoke permissions: %w", err)
+		}
+		removed := make([]uint, len(assigned))
+		for i, p := range assigned {
+			removed[i] = p.ID
+		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACRolePermissionsChanged, Domain: domain, RoleID: roleID, Removed: removed})
+	}); err != nil {
+		return 0, err
+	}
+
+	// 清理角色及所有拥有该角色的用户的权限缓存（处于请求事务中时在提交后执行）
+	s.invalidateRolePermissionsAfterCommit(ctx, roleID, domain)
+
+	s.logger.Info("permissions revoked from role in RBAC table",
+		"role_id", roleID,
+		"role_name", role.Name,
+		"permission_count", len(assigned),
+		"domain", domain,
+	)
+
+	return len(assigned), nil
+}
+
 // GetRolePermissions 获取角色的所有权限
 // 方案A实现：直接从RBAC表（role_permissions）读取
 func (s *rbacService) GetRolePermissions(ctx context.Context, roleID uint, domain string) ([]model.Permission, error) {
@@ -778,7 +1402,7 @@ func (s *rbacService) GetRolePermissions(ctx context.Context, roleID uint, domai
 
 	// 直接从RBAC表读取角色的权限（通过GORM Preload）
 	var roleWithPerms model.Role
-	if err := s.db.DB.WithContext(ctx).
+	if err := s.db.Conn(ctx).
 		Preload("Permissions").
 		Where("id = ?", roleID).
 		First(&roleWithPerms).Error; err != nil {
@@ -793,83 +1417,308 @@ func (s *rbacService) GetRolePermissions(ctx context.Context, roleID uint, domai
 	return roleWithPerms.Permissions, nil
 }
 
+// RoleEffectivePermissions 角色的完整权限集合（直接权限 + 继承权限）
+type RoleEffectivePermissions struct {
+	RoleID    uint                  `json:"role_id"`
+	Direct    []model.Permission    `json:"direct"`    // 直接关联的权限
+	Inherited []InheritedPermission `json:"inherited"` // 通过 g2 继承的权限（已排除直接权限）
+}
+
+// InheritedPermission 继承得到的权限及其来源角色
+type InheritedPermission struct {
+	model.Permission
+	FromRoleID uint `json:"from_role_id"` // 提供该权限的祖先角色ID
+}
+
+// GetRoleEffectivePermissions 获取角色的完整权限集合
+// 通过 GetRoleInheritance 沿 g2 继承链向上遍历所有祖先角色并合并权限，
+// 使用 visited 集合防止继承环导致死循环
+func (s *rbacService) GetRoleEffectivePermissions(ctx context.Context, roleID uint, domain string) (*RoleEffectivePermissions, error) {
+	direct, err := s.GetRolePermissions(ctx, roleID, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RoleEffectivePermissions{
+		RoleID:    roleID,
+		Direct:    direct,
+		Inherited: []InheritedPermission{},
+	}
+
+	seen := make(map[uint]bool, len(direct))
+	for _, p := range direct {
+		seen[p.ID] = true
+	}
+
+	visited := map[uint]bool{roleID: true}
+	queue := []uint{roleID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		parents, err := s.enforcer.GetRoleInheritance(strconv.FormatUint(uint64(current), 10), domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get role inheritance: %w", err)
+		}
+
+		for _, parent := range parents {
+			parentID, err := strconv.ParseUint(parent, 10, 64)
+			if err != nil {
+				s.logger.Warn("invalid parent role subject, skipped", "role_id", current, "parent", parent)
+				continue
+			}
+			if visited[uint(parentID)] {
+				continue
+			}
+			visited[uint(parentID)] = true
+			queue = append(queue, uint(parentID))
+
+			perms, err := s.GetRolePermissions(ctx, uint(parentID), domain)
+			if err != nil {
+				s.logger.Warn("failed to load parent role permissions, skipped",
+					"role_id", current,
+					"parent_id", parentID,
+					"error", err,
+				)
+				continue
+			}
+			for _, p := range perms {
+				if seen[p.ID] {
+					continue
+				}
+				seen[p.ID] = true
+				result.Inherited = append(result.Inherited, InheritedPermission{
+					Permission: p,
+					FromRoleID: uint(parentID),
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ============================
+// 角色继承管理（g2）
+// ============================
+
+// AddRoleParent 添加角色继承关系（roleID 继承 parentID 的权限）
+// 添加前沿现有继承链从 parentID 向上遍历，若能到达 roleID 则新边会形成环，返回 ErrRoleInheritanceCycle
+func (s *rbacService) AddRoleParent(ctx context.Context, roleID, parentID uint, domain string) error {
+	if roleID == parentID {
+		return fmt.Errorf("%w: role %d cannot inherit itself", ErrRoleInheritanceCycle, roleID)
+	}
+
+	for _, id := range []uint{roleID, parentID} {
+		role, err := s.roleRepo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("role not found: %w", err)
+		}
+		if role.Domain != domain {
+			return fmt.Errorf("role domain mismatch")
+		}
+	}
+
+	graph, err := s.loadInheritanceGraph(domain)
+	if err != nil {
+		return err
+	}
+
+	child := strconv.FormatUint(uint64(roleID), 10)
+	parent := strconv.FormatUint(uint64(parentID), 10)
+	if path := findInheritancePath(graph, parent, child); path != nil {
+		return fmt.Errorf("%w: %s -> %s", ErrRoleInheritanceCycle, child, strings.Join(path, " -> "))
+	}
+
+	if _, err := s.enforcer.AddRoleInheritance(child, parent, domain); err != nil {
+		return fmt.Errorf("failed to add role inheritance: %w", err)
+	}
+
+	s.logger.Info("role inheritance added",
+		"role_id", roleID,
+		"parent_id", parentID,
+		"domain", domain,
+	)
+	return nil
+}
+
+// RemoveRoleParent 删除角色继承关系
+func (s *rbacService) RemoveRoleParent(ctx context.Context, roleID, parentID uint, domain string) error {
+	child := strconv.FormatUint(uint64(roleID), 10)
+	parent := strconv.FormatUint(uint64(parentID), 10)
+	if _, err := s.enforcer.DeleteRoleInheritance(child, parent, domain); err != nil {
+		return fmt.Errorf("failed to delete role inheritance: %w", err)
+	}
+	return nil
+}
+
+// DetectCycles 诊断域内已存在的角色继承环
+// 返回每个环经过的角色ID序列（首尾相同），无环时返回空列表
+func (s *rbacService) DetectCycles(ctx context.Context, domain string) ([][]string, error) {
+	graph, err := s.loadInheritanceGraph(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(graph))
+	var stack []string
+	cycles := [][]string{}
+
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range graph[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				// 回边：从栈中 next 的位置到当前节点构成一个环
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == next {
+						cycle := append([]string{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, next))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = done
+	}
+
+	for node := range graph {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	return cycles, nil
+}
+
+// loadInheritanceGraph 加载域内继承关系，构建 子角色 -> 父角色列表 的邻接表
+func (s *rbacService) loadInheritanceGraph(domain string) (map[string][]string, error) {
+	edges, err := s.enforcer.GetRoleInheritanceEdges(domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role inheritance: %w", err)
+	}
+
+	graph := make(map[string][]string)
+	for _, edge := range edges {
+		graph[edge[0]] = append(graph[edge[0]], edge[1])
+	}
+	return graph, nil
+}
+
+// findInheritancePath 沿继承关系查找 from 到 to 的路径（BFS），不可达时返回 nil
+func findInheritancePath(graph map[string][]string, from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == to {
+			var path []string
+			for n := to; n != ""; n = prev[n] {
+				path = append([]string{n}, path...)
+			}
+			return path
+		}
+		for _, next := range graph[node] {
+			if _, ok := prev[next]; !ok {
+				prev[next] = node
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
 // 继续下一部分...
 // ============================
 // 用户-角色管理
 // ============================
 
-// AssignRolesToUser 给用户分配角色
+// AssignRolesToUser 给用户分配角色，expiresAt 不为空时为临时授权，过期后不再生效
 // 方案A实现：只在user_roles表中记录，Casbin从RBAC表自动同步
-func (s *rbacService) AssignRolesToUser(ctx context.Context, userID uint, roleIDs []uint, domain string, assignedBy uint) error {
+// 不存在或不属于目标域的角色不会分配，在返回结果中列出
+func (s *rbacService) AssignRolesToUser(ctx context.Context, userID uint, roleIDs []uint, domain string, assignedBy uint, expiresAt *time.Time) (*AssignResult, error) {
 	// 查询角色列表
-	roles, err := s.roleRepo.ListByIDs(ctx, roleIDs)
+	found, err := s.roleRepo.ListByIDs(ctx, roleIDs)
 	if err != nil {
-		return fmt.Errorf("failed to get roles: %w", err)
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	roles, result := splitByDomain(roleIDs, found, func(r model.Role) (uint, string) { return r.ID, r.Domain }, domain)
+	if err := s.checkPartialAssignment(result, "roles", domain); err != nil {
+		return result, err
 	}
 
 	// 构建用户-角色关系
-	var userRoles []model.UserRole
+	userRoles := make([]model.UserRole, 0, len(roles))
 	for _, role := range roles {
-		if role.Domain != domain {
-			s.logger.Warn("role domain mismatch, skipped",
-				"role_id", role.ID,
-				"expected_domain", domain,
-				"actual_domain", role.Domain,
-			)
-			continue
-		}
-
 		userRoles = append(userRoles, model.UserRole{
 			UserID:     userID,
 			RoleID:     role.ID,
 			Domain:     domain,
 			AssignedBy: assignedBy,
+			ExpiresAt:  expiresAt,
 		})
 	}
 
-	// 批量写入user_roles表
+	// 批量写入user_roles表并写入变更事件
 	if len(userRoles) > 0 {
-		if err := s.userRoleRepo.BatchAssign(ctx, userRoles); err != nil {
-			return fmt.Errorf("failed to assign roles: %w", err)
+		if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+			if err := s.userRoleRepo.BatchAssign(ctx, userRoles); err != nil {
+				return fmt.Errorf("failed to assign roles: %w", err)
+			}
+			return s.publishChange(ctx, RBACChangeEvent{Action: RBACUserRolesAssigned, Domain: domain, UserID: userID, Added: result.Assigned})
+		}); err != nil {
+			return nil, err
 		}
 	}
 
-	// 清理用户权限缓存
-	userCacheKey := fmt.Sprintf(cacheKeyUserPermissions, userID, domain)
-	if err := cache.Del(ctx, userCacheKey); err != nil {
-		s.logger.Warn("failed to delete user permissions cache", "error", err)
-	}
+	// 清理用户权限缓存（处于请求事务中时在提交后执行）
+	s.invalidateUserPermissionsAfterCommit(ctx, userID, domain)
 
 	s.logger.Info("roles assigned to user in RBAC table",
 		"user_id", userID,
 		"role_count", len(userRoles),
 		"domain", domain,
 		"assigned_by", assignedBy,
+		"expires_at", expiresAt,
 	)
 
-	return nil
+	return result, nil
 }
 
 // RevokeRolesFromUser 撤销用户的角色
 // 方案A实现：只从user_roles表删除，自动清理缓存
 func (s *rbacService) RevokeRolesFromUser(ctx context.Context, userID uint, roleIDs []uint, domain string) error {
-	// 从user_roles表批量删除
-	for _, roleID := range roleIDs {
-		if err := s.userRoleRepo.Revoke(ctx, userID, roleID, domain); err != nil {
-			s.logger.Error("failed to revoke role from user",
-				"user_id", userID,
-				"role_id", roleID,
-				"error", err,
-			)
-			return fmt.Errorf("failed to revoke role: %w", err)
+	// 从user_roles表批量删除并写入变更事件
+	if err := s.db.RunInTx(ctx, func(ctx context.Context) error {
+		for _, roleID := range roleIDs {
+			if err := s.userRoleRepo.Revoke(ctx, userID, roleID, domain); err != nil {
+				s.logger.Error("failed to revoke role from user",
+					"user_id", userID,
+					"role_id", roleID,
+					"error", err,
+				)
+				return fmt.Errorf("failed to revoke role: %w", err)
+			}
 		}
+		return s.publishChange(ctx, RBACChangeEvent{Action: RBACUserRolesRevoked, Domain: domain, UserID: userID, Removed: roleIDs})
+	}); err != nil {
+		return err
 	}
 
-	// 清理用户权限缓存
-	userCacheKey := fmt.Sprintf(cacheKeyUserPermissions, userID, domain)
-	if err := cache.Del(ctx, userCacheKey); err != nil {
-		s.logger.Warn("failed to delete user permissions cache", "error", err)
-	}
+	// 清理用户权限缓存（处于请求事务中时在提交后执行）
+	s.invalidateUserPermissionsAfterCommit(ctx, userID, domain)
 
 	s.logger.Info("roles revoked from user in RBAC table",
 		"user_id", userID,
@@ -880,6 +1729,92 @@ func (s *rbacService) RevokeRolesFromUser(ctx context.Context, userID uint, role
 	return nil
 }
 
+// PurgeExpiredUserRoles 删除已过期的临时角色分配，返回删除数量
+// 过期分配在查询时已被忽略，这里同步移除对应的 Casbin 分组规则并清理受影响用户的缓存
+func (s *rbacService) PurgeExpiredUserRoles(ctx context.Context) (int, error) {
+	expired, err := s.userRoleRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired user roles: %w", err)
+	}
+
+	affected := make(map[string]bool)
+	for _, ur := range expired {
+		// 同一角色仍有其他有效分配时保留分组规则
+		stillAssigned, err := s.userRoleRepo.HasRole(ctx, ur.UserID, ur.RoleID, ur.Domain)
+		if err != nil {
+			s.logger.Warn("failed to check remaining user role", "user_id", ur.UserID, "role_id", ur.RoleID, "error", err)
+		} else if !stillAssigned {
+			userKey := strconv.FormatUint(uint64(ur.UserID), 10)
+			roleKey := strconv.FormatUint(uint64(ur.RoleID), 10)
+			if _, err := s.enforcer.DeleteRoleForUser(userKey, roleKey, ur.Domain); err != nil {
+				s.logger.Warn("failed to remove expired grouping policy", "user_id", ur.UserID, "role_id", ur.RoleID, "error", err)
+			}
+		}
+
+		userCacheKey := fmt.Sprintf(cacheKeyUserPermissions, ur.UserID, ur.Domain)
+		if affected[userCacheKey] {
+			continue
+		}
+		affected[userCacheKey] = true
+		if err := cache.Del(ctx, userCacheKey, cache.DomainMembershipKey(ur.UserID, ur.Domain)); err != nil {
+			s.logger.Warn("failed to delete user permissions cache", "user_id", ur.UserID, "error", err)
+		}
+	}
+
+	if len(expired) > 0 {
+		s.logger.Info("expired user roles purged", "count", len(expired), "users", len(affected))
+	}
+	return len(expired), nil
+}
+
+// invalidateUserPermissionsAfterCommit 在当前事务提交后清理用户权限缓存和域成员关系缓存
+// 若在事务内立即删除，并发请求可能在提交前用旧数据重新填充缓存
+func (s *rbacService) invalidateUserPermissionsAfterCommit(ctx context.Context, userID uint, domain string) {
+	database.AfterCommit(ctx, func() {
+		userCacheKey := fmt.Sprintf(cacheKeyUserPermissions, userID, domain)
+		if err := cache.Del(context.WithoutCancel(ctx), userCacheKey, cache.DomainMembershipKey(userID, domain)); err != nil {
+			s.logger.Warn("failed to delete user permissions cache", "error", err)
+		}
+	})
+}
+
+// invalidateRolePermissionsAfterCommit 在当前事务提交后清理角色权限缓存及所有拥有该角色的用户的权限缓存
+func (s *rbacService) invalidateRolePermissionsAfterCommit(ctx context.Context, roleID uint, domain string) {
+	database.AfterCommit(ctx, func() {
+		cacheCtx := context.WithoutCancel(ctx)
+		roleCacheKey := fmt.Sprintf(cacheKeyRolePermissions, roleID, domain)
+		if err := cache.Del(cacheCtx, roleCacheKey); err != nil {
+			s.logger.Warn("failed to delete role permissions cache", "error", err)
+		}
+		s.clearUserPermissionsCacheByRole(cacheCtx, roleID, domain)
+	})
+}
+
+// GetRolesForUsers 批量获取多个用户在域内的有效角色，按用户ID分组
+// 对 user_roles 做一次批量查询（预加载角色），代替逐个用户调用 GetUserRoles；
+// 每个请求的用户在结果中都有对应的条目，没有角色的用户为空切片，已删除的角色不返回
+func (s *rbacService) GetRolesForUsers(ctx context.Context, userIDs []uint, domain string) (map[uint][]model.Role, error) {
+	result := make(map[uint][]model.Role, len(userIDs))
+	for _, id := range userIDs {
+		result[id] = []model.Role{}
+	}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	userRoles, err := s.userRoleRepo.FindByUsers(ctx, userIDs, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles for users: %w", err)
+	}
+	for _, ur := range userRoles {
+		if ur.Role == nil {
+			continue
+		}
+		result[ur.UserID] = append(result[ur.UserID], *ur.Role)
+	}
+	return result, nil
+}
+
 // GetUserRoles 获取用户的所有角色
 func (s *rbacService) GetUserRoles(ctx context.Context, userID uint, domain string) ([]model.Role, error) {
 	userIDStr := strconv.FormatUint(uint64(userID), 10)
@@ -928,18 +1863,8 @@ func (s *rbacService) CheckPermission(ctx context.Context, userID uint, domain,
 	}
 
 	// 2. 检查是否有匹配的权限
-	// 支持精确匹配和通配符匹配（*）
-	for _, perm := range permissions {
-		// 精确匹配
-		if perm.Resource == resource && perm.Action == action {
-			return true, nil
-		}
-
-		// 通配符匹配：resource或action为*表示匹配所有
-		if (perm.Resource == "*" || perm.Resource == resource) &&
-			(perm.Action == "*" || perm.Action == action) {
-			return true, nil
-		}
+	if MatchPermission(permissions, resource, action) {
+		return true, nil
 	}
 
 	s.logger.Debug("permission check failed",
@@ -952,10 +1877,38 @@ func (s *rbacService) CheckPermission(ctx context.Context, userID uint, domain,
 	return false, nil
 }
 
+// MatchPermission 判断权限集中是否有匹配 resource/action 的权限
+// 支持精确匹配和通配符匹配：权限的 resource 或 action 为 * 时匹配所有
+func MatchPermission(permissions []model.Permission, resource, action string) bool {
+	for _, perm := range permissions {
+		if (perm.Resource == "*" || perm.Resource == resource) &&
+			(perm.Action == "*" || perm.Action == action) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetUserPermissions 获取用户的所有权限（包括通过角色继承的）
 // 重要：此方法供前端调用，用于生成动态路由和菜单
 // 方案A实现：从RBAC表（user_roles + role_permissions + permissions）联表查询 + Redis缓存
+// context 开启了请求内缓存（WithRequestMemo）时，同一请求内对同一用户、域只加载一次
 func (s *rbacService) GetUserPermissions(ctx context.Context, userID uint, domain string) ([]model.Permission, error) {
+	memo := requestMemoFrom(ctx)
+	if permissions, ok := memo.getPermissions(userID, domain); ok {
+		return permissions, nil
+	}
+
+	permissions, err := s.loadUserPermissions(ctx, userID, domain)
+	if err != nil {
+		return nil, err
+	}
+	memo.setPermissions(userID, domain, permissions)
+	return permissions, nil
+}
+
+// loadUserPermissions 从 Redis 缓存或数据库加载用户权限
+func (s *rbacService) loadUserPermissions(ctx context.Context, userID uint, domain string) ([]model.Permission, error) {
 	// 1. 尝试从缓存获取
 	cacheKey := fmt.Sprintf(cacheKeyUserPermissions, userID, domain)
 	var cachedPermissions []model.Permission
@@ -971,36 +1924,47 @@ func (s *rbacService) GetUserPermissions(ctx context.Context, userID uint, domai
 	}
 
 	// 2. 缓存未命中，从数据库查询
-	permissions, err := s.loadUserPermissionsFromDB(ctx, userID, domain)
+	permissions, nextExpiry, err := s.loadUserPermissionsFromDB(ctx, userID, domain)
 	if err != nil {
 		return nil, err
 	}
 
-	// 3. 写入缓存
-	if err := s.cache.SetObject(ctx, cacheKey, permissions, cacheTTLPermissions); err != nil {
+	// 3. 写入缓存：存在临时授权时缓存不超过其过期时间，过期后重新计算
+	ttl := cacheTTLPermissions
+	if nextExpiry != nil {
+		if remaining := time.Until(*nextExpiry); remaining < ttl {
+			ttl = max(remaining, time.Second)
+		}
+	}
+	if err := s.cache.SetObject(ctx, cacheKey, permissions, ttl); err != nil {
 		s.logger.Warn("failed to cache user permissions", "error", err)
 	}
 
 	return permissions, nil
 }
 
-// loadUserPermissionsFromDB 从数据库加载用户权限
-func (s *rbacService) loadUserPermissionsFromDB(ctx context.Context, userID uint, domain string) ([]model.Permission, error) {
-	// 1. 查询用户的所有角色（从user_roles表）
+// loadUserPermissionsFromDB 从数据库加载用户权限，已过期的角色分配不计入
+// 同时返回有效分配中最早的过期时间（没有临时授权时为 nil），用于限制缓存时长
+func (s *rbacService) loadUserPermissionsFromDB(ctx context.Context, userID uint, domain string) ([]model.Permission, *time.Time, error) {
+	// 1. 查询用户的所有有效角色（从user_roles表）
 	userRoles, err := s.userRoleRepo.FindByUser(ctx, userID, domain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user roles: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user roles: %w", err)
 	}
 
 	if len(userRoles) == 0 {
 		s.logger.Debug("user has no roles", "user_id", userID, "domain", domain)
-		return []model.Permission{}, nil
+		return []model.Permission{}, nil, nil
 	}
 
 	// 2. 提取角色ID列表
 	roleIDs := make([]uint, 0, len(userRoles))
+	var nextExpiry *time.Time
 	for _, ur := range userRoles {
 		roleIDs = append(roleIDs, ur.RoleID)
+		if ur.ExpiresAt != nil && (nextExpiry == nil || ur.ExpiresAt.Before(*nextExpiry)) {
+			nextExpiry = ur.ExpiresAt
+		}
 	}
 
 	// 3. 联表查询所有权限（去重）
@@ -1008,13 +1972,13 @@ func (s *rbacService) loadUserPermissionsFromDB(ctx context.Context, userID uint
 	//      INNER JOIN role_permissions ON permissions.id = role_permissions.permission_id
 	//      WHERE role_permissions.role_id IN (?) AND permissions.domain = ?
 	var permissions []model.Permission
-	if err := s.db.DB.WithContext(ctx).
+	if err := s.db.Conn(ctx).
 		Distinct().
 		Table("permissions").
 		Joins("INNER JOIN role_permissions ON permissions.id = role_permissions.permission_id").
 		Where("role_permissions.role_id IN ? AND permissions.domain = ?", roleIDs, domain).
 		Find(&permissions).Error; err != nil {
-		return nil, fmt.Errorf("failed to query user permissions: %w", err)
+		return nil, nil, fmt.Errorf("failed to query user permissions: %w", err)
 	}
 
 	s.logger.Debug("loaded user permissions from RBAC tables",
@@ -1023,7 +1987,7 @@ func (s *rbacService) loadUserPermissionsFromDB(ctx context.Context, userID uint
 		"permission_count", len(permissions),
 	)
 
-	return permissions, nil
+	return permissions, nextExpiry, nil
 }
 
 // clearUserPermissionsCacheByRole 清理拥有指定角色的所有用户的权限缓存
@@ -1113,13 +2077,138 @@ func (s *rbacService) ListPolicies(ctx context.Context, domain string) ([][]stri
 	return policies, nil
 }
 
+// UserPolicy 用户在某个域生效的 Casbin 策略
+type UserPolicy struct {
+	Subject  string `json:"subject"`  // 策略主体（显式策略为用户ID，隐式策略为角色ID）
+	Domain   string `json:"domain"`   // 域
+	Resource string `json:"resource"` // 资源
+	Action   string `json:"action"`   // 操作
+	Implicit bool   `json:"implicit"` // 是否通过角色（含角色继承）间接获得
+}
+
+// ListUserPolicies 分页列出用户在指定域的策略
+// 显式策略来自 GetPermissionsForUser（主体直接为用户），隐式策略来自 GetImplicitPermissionsForUser 且不含显式策略；
+// 结果按 显式优先、资源、操作、主体 排序后分页，pagination.Total 为去重后的策略总数
+func (s *rbacService) ListUserPolicies(ctx context.Context, userID uint, domain string, pagination *database.Pagination) ([]UserPolicy, error) {
+	user := strconv.FormatUint(uint64(userID), 10)
+
+	explicit, err := s.enforcer.GetPermissionsForUser(user, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user policies: %w", err)
+	}
+	implicit, err := s.enforcer.GetImplicitPermissionsForUser(user, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get implicit user policies: %w", err)
+	}
+
+	seen := make(map[string]bool, len(implicit))
+	policies := make([]UserPolicy, 0, len(implicit))
+	add := func(rules [][]string, isImplicit bool) {
+		for _, rule := range rules {
+			if len(rule) < 4 {
+				continue
+			}
+			key := ruleKey(rule[:4]...)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			policies = append(policies, UserPolicy{
+				Subject:  rule[0],
+				Domain:   rule[1],
+				Resource: rule[2],
+				Action:   rule[3],
+				Implicit: isImplicit,
+			})
+		}
+	}
+	add(explicit, false)
+	add(implicit, true)
+
+	sort.Slice(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if a.Implicit != b.Implicit {
+			return !a.Implicit
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.Subject < b.Subject
+	})
+
+	pagination.Total = int64(len(policies))
+	offset := pagination.GetOffset()
+	if offset >= len(policies) {
+		return []UserPolicy{}, nil
+	}
+	end := min(offset+pagination.GetLimit(), len(policies))
+	return policies[offset:end], nil
+}
+
+// ReplaceRolePolicies 替换角色的全部原始 Casbin 策略
+// 清空旧策略与写入新策略在同一个数据库事务中完成，任一步失败时原有策略保持不变
+// 注意：方案A下权限校验基于 role_permissions 表，这里写入的策略不会同步到权限表，
+// 仅供直接使用 Casbin 策略的高级场景
+func (s *rbacService) ReplaceRolePolicies(ctx context.Context, roleID uint, domain string, policies [][2]string) error {
+	if _, err := s.roleRepo.FindByID(ctx, roleID); err != nil {
+		return fmt.Errorf("role not found: %w", err)
+	}
+	roleName := strconv.FormatUint(uint64(roleID), 10)
+
+	oldPolicies, err := s.enforcer.GetPoliciesForRole(roleName, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get role policies: %w", err)
+	}
+
+	rules := make([][]string, 0, len(policies))
+	for _, p := range policies {
+		rules = append(rules, []string{p[0], p[1]})
+	}
+
+	if err := s.enforcer.ReplacePoliciesForRole(roleName, domain, rules); err != nil {
+		return err
+	}
+
+	s.logger.Info("role policies replaced",
+		"roleID", roleID,
+		"domain", domain,
+		"old_count", len(oldPolicies),
+		"new_count", len(rules),
+	)
+
+	return nil
+}
+
 // ============================
 // 安全辅助函数
 // ============================
 
 // GetUserMaxRoleLevel 获取用户在指定域下的最高角色等级
 // 用于权限越级检查：操作者只能管理比自己等级低的角色
+// 配置 rbac.super_admin_user_ids 中的用户直接返回 SuperAdminBypassLevel，不受角色等级过滤；
+// context 开启了请求内缓存（WithRequestMemo）时，同一请求内对同一用户、域只计算一次
 func (s *rbacService) GetUserMaxRoleLevel(ctx context.Context, userID uint, domain string) (int, error) {
+	if s.config.IsSuperAdminUser(userID) {
+		return SuperAdminBypassLevel, nil
+	}
+
+	memo := requestMemoFrom(ctx)
+	if level, ok := memo.getMaxLevel(userID, domain); ok {
+		return level, nil
+	}
+	level, err := s.computeUserMaxRoleLevel(ctx, userID, domain)
+	if err != nil {
+		return 0, err
+	}
+	memo.setMaxLevel(userID, domain, level)
+	return level, nil
+}
+
+// computeUserMaxRoleLevel 根据用户在域内的有效角色计算最高等级
+func (s *rbacService) computeUserMaxRoleLevel(ctx context.Context, userID uint, domain string) (int, error) {
 	roles, err := s.GetUserRoles(ctx, userID, domain)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user roles: %w", err)
@@ -1130,22 +2219,26 @@ func (s *rbacService) GetUserMaxRoleLevel(ctx context.Context, userID uint, doma
 			"userID", userID,
 			"domain", domain,
 		)
-		return 0, nil // 用户没有任何角色
+		return 0, nil // 用户没有任何角色：等级为 0，按等级过滤时看不到任何角色
 	}
 
+	// 该方法在每次带等级过滤的请求中都会调用，逐角色日志仅在 Debug 级别输出
 	maxLevel := 0
+	debug := s.logger.Enabled(ctx, slog.LevelDebug)
 	for _, role := range roles {
-		s.logger.Info("GetUserMaxRoleLevel: 检查角色",
-			"userID", userID,
-			"roleName", role.Name,
-			"roleLevel", role.Level,
-		)
+		if debug {
+			s.logger.Debug("GetUserMaxRoleLevel: 检查角色",
+				"userID", userID,
+				"roleName", role.Name,
+				"roleLevel", role.Level,
+			)
+		}
 		if role.Level > maxLevel {
 			maxLevel = role.Level
 		}
 	}
 
-	s.logger.Info("GetUserMaxRoleLevel: 计算完成",
+	s.logger.Debug("GetUserMaxRoleLevel: 计算完成",
 		"userID", userID,
 		"maxLevel", maxLevel,
 		"rolesCount", len(roles),
@@ -1178,6 +2271,42 @@ func (s *rbacService) CheckRoleLevelPermission(ctx context.Context, operatorID u
 	return nil
 }
 
+// checkRoleLevelCeiling 校验角色等级在 1 到所在域的等级上限之间
+func (s *rbacService) checkRoleLevelCeiling(level int, domain string) error {
+	ceiling := s.config.MaxRoleLevelFor(domain)
+	if level < 1 || level > ceiling {
+		return fmt.Errorf("%w: level must be between 1 and %d in domain %s, got %d", ErrRoleLevelOutOfRange, ceiling, domain, level)
+	}
+	return nil
+}
+
+// checkRolePermissionLimit 校验分配后的角色权限集合不超过所在域的上限，重复ID只计一次
+func (s *rbacService) checkRolePermissionLimit(permissionIDs []uint, domain string) error {
+	limit := s.config.MaxPermissionsPerRoleFor(domain)
+	if limit <= 0 {
+		return nil
+	}
+	if count := len(slices.Compact(slices.Sorted(slices.Values(permissionIDs)))); count > limit {
+		return fmt.Errorf("%w: role would have %d permissions, limit is %d in domain %s", ErrTooManyPermissions, count, limit, domain)
+	}
+	return nil
+}
+
+// CheckRoleLevelAssignable 检查操作者能否将角色设置为指定等级
+// 规则：设置的等级必须严格低于操作者自身的最高等级，避免创建与自己同级或更高的角色
+func (s *rbacService) CheckRoleLevelAssignable(ctx context.Context, operatorID uint, level int, domain string) error {
+	operatorLevel, err := s.GetUserMaxRoleLevel(ctx, operatorID, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get operator level: %w", err)
+	}
+
+	if level >= operatorLevel {
+		return fmt.Errorf("权限不足：无法设置等级为 %d 的角色（您的等级为 %d），只能设置比您等级低的角色", level, operatorLevel)
+	}
+
+	return nil
+}
+
 // CheckRolesLevelPermission 批量检查用户是否有权限操作指定的多个角色
 // 规则：只有当操作者等级严格高于所有目标角色时才允许操作
 func (s *rbacService) CheckRolesLevelPermission(ctx context.Context, operatorID uint, targetRoleIDs []uint, domain string) error {
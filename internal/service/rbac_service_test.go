@@ -0,0 +1,1032 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/casbin"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/database"
+	"gorm.io/gorm"
+)
+
+// rbacTestEnv RBAC 服务测试环境：SQLite + 内存 Redis + 真实 Casbin enforcer
+type rbacTestEnv struct {
+	svc      *rbacService
+	db       *database.Database
+	enforcer *casbin.Enforcer
+	logs     *bytes.Buffer // 服务日志输出（Info 级别）
+}
+
+func newTestRBACService(t *testing.T, cfg *config.RBACConfig) *rbacTestEnv {
+	t.Helper()
+
+	testutil.NewRedis(t)
+	db := testutil.NewDatabase(t, &model.Role{}, &model.Permission{}, &model.UserRole{}, &model.OutboxEvent{}, &casbin.PolicyVersion{})
+	// 策略版本表在生产环境由数据库迁移创建
+	if err := db.DB.Create(&casbin.PolicyVersion{ID: 1, UpdatedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("create policy version: %v", err)
+	}
+
+	logs := &bytes.Buffer{}
+	log := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	enforcer, err := casbin.NewEnforcer(db.DB, casbin.Config{AutoSave: true}, log)
+	if err != nil {
+		t.Fatalf("NewEnforcer: %v", err)
+	}
+	if cfg == nil {
+		cfg = &config.RBACConfig{MaxRoleLevel: model.SuperAdminRoleLevel}
+	}
+
+	svc := NewRBACService(enforcer, repository.NewRoleRepository(db), repository.NewPermissionRepository(db),
+		repository.NewUserRoleRepository(db), repository.NewOutboxRepository(db), db, log, cfg).(*rbacService)
+	logs.Reset()
+	return &rbacTestEnv{svc: svc, db: db, enforcer: enforcer, logs: logs}
+}
+
+// createRole 直接写入角色记录
+func (env *rbacTestEnv) createRole(t *testing.T, name, domain string, level int) *model.Role {
+	t.Helper()
+
+	role := &model.Role{Name: name, DisplayName: name, Domain: domain, Level: level}
+	if err := env.db.DB.Create(role).Error; err != nil {
+		t.Fatalf("create role %s: %v", name, err)
+	}
+	return role
+}
+
+// assignRole 为用户分配角色（关联表与 Casbin 分组策略）
+func (env *rbacTestEnv) assignRole(t *testing.T, userID uint, role *model.Role) {
+	t.Helper()
+
+	if _, err := env.svc.AssignRolesToUser(context.Background(), userID, []uint{role.ID}, role.Domain, 0, nil); err != nil {
+		t.Fatalf("assign role %s to user %d: %v", role.Name, userID, err)
+	}
+	if _, err := env.enforcer.AddRoleForUser(casbinKey(userID), casbinKey(role.ID), role.Domain); err != nil {
+		t.Fatalf("add grouping policy: %v", err)
+	}
+}
+
+// casbinKey 用户或角色ID在 Casbin 策略中的表示
+func casbinKey(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func TestAddRoleParent_RejectsCycles(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	a := env.createRole(t, "a", "default", 10)
+	b := env.createRole(t, "b", "default", 10)
+	c := env.createRole(t, "c", "default", 10)
+
+	// a 继承 b，b 继承 c
+	if err := env.svc.AddRoleParent(ctx, a.ID, b.ID, "default"); err != nil {
+		t.Fatalf("AddRoleParent(a, b): %v", err)
+	}
+	if err := env.svc.AddRoleParent(ctx, b.ID, c.ID, "default"); err != nil {
+		t.Fatalf("AddRoleParent(b, c): %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		role, parent uint
+	}{
+		{"self", a.ID, a.ID},
+		{"direct", b.ID, a.ID},
+		{"transitive", c.ID, a.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.svc.AddRoleParent(ctx, tt.role, tt.parent, "default")
+			if !errors.Is(err, ErrRoleInheritanceCycle) {
+				t.Fatalf("AddRoleParent error = %v, want ErrRoleInheritanceCycle", err)
+			}
+		})
+	}
+
+	// 被拒绝的边未写入
+	cycles, err := env.svc.DetectCycles(ctx, "default")
+	if err != nil {
+		t.Fatalf("DetectCycles: %v", err)
+	}
+	if len(cycles) != 0 {
+		t.Fatalf("DetectCycles = %v, want none", cycles)
+	}
+}
+
+func TestAddRoleParent_AllowsDiamond(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	top := env.createRole(t, "top", "default", 10)
+	left := env.createRole(t, "left", "default", 10)
+	right := env.createRole(t, "right", "default", 10)
+	bottom := env.createRole(t, "bottom", "default", 10)
+
+	// 菱形继承没有环，应全部成功
+	for _, edge := range [][2]*model.Role{{bottom, left}, {bottom, right}, {left, top}, {right, top}} {
+		if err := env.svc.AddRoleParent(ctx, edge[0].ID, edge[1].ID, "default"); err != nil {
+			t.Fatalf("AddRoleParent(%s, %s): %v", edge[0].Name, edge[1].Name, err)
+		}
+	}
+}
+
+func TestDetectCycles_ReportsExistingCycles(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	a := env.createRole(t, "a", "default", 10)
+	b := env.createRole(t, "b", "default", 10)
+	c := env.createRole(t, "c", "default", 10)
+	other := env.createRole(t, "x", "tenant", 10)
+
+	// 绕过 AddRoleParent 的检查直接写入环：a -> b -> c -> a
+	for _, edge := range [][2]uint{{a.ID, b.ID}, {b.ID, c.ID}, {c.ID, a.ID}} {
+		if _, err := env.enforcer.AddRoleInheritance(casbinKey(edge[0]), casbinKey(edge[1]), "default"); err != nil {
+			t.Fatalf("AddRoleInheritance: %v", err)
+		}
+	}
+	if _, err := env.enforcer.AddRoleInheritance(casbinKey(other.ID), casbinKey(other.ID), "tenant"); err != nil {
+		t.Fatalf("AddRoleInheritance: %v", err)
+	}
+
+	cycles, err := env.svc.DetectCycles(ctx, "default")
+	if err != nil {
+		t.Fatalf("DetectCycles: %v", err)
+	}
+	if len(cycles) != 1 {
+		t.Fatalf("DetectCycles = %v, want one cycle", cycles)
+	}
+	cycle := cycles[0]
+	if len(cycle) != 4 || cycle[0] != cycle[3] {
+		t.Fatalf("cycle = %v, want three roles closed on the first", cycle)
+	}
+	members := map[string]bool{}
+	for _, id := range cycle[:3] {
+		members[id] = true
+	}
+	for _, id := range []uint{a.ID, b.ID, c.ID} {
+		if !members[casbinKey(id)] {
+			t.Fatalf("cycle = %v, missing role %d", cycle, id)
+		}
+	}
+
+	// 其他域的环不影响 default 域，且按域单独报告
+	tenantCycles, err := env.svc.DetectCycles(ctx, "tenant")
+	if err != nil {
+		t.Fatalf("DetectCycles(tenant): %v", err)
+	}
+	if len(tenantCycles) != 1 {
+		t.Fatalf("DetectCycles(tenant) = %v, want the self-loop", tenantCycles)
+	}
+}
+
+func TestListRolesFiltered_NoPerRoleInfoLogs(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	admin := env.createRole(t, "admin", "default", 50)
+	for i := 0; i < 20; i++ {
+		env.createRole(t, "role"+strconv.Itoa(i), "default", 10+i)
+	}
+	env.assignRole(t, 1, admin)
+	env.logs.Reset()
+
+	roles, err := env.svc.ListRolesFiltered(ctx, 1, "default", &database.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("ListRolesFiltered: %v", err)
+	}
+	if len(roles) != 20 {
+		t.Fatalf("ListRolesFiltered returned %d roles, want 20", len(roles))
+	}
+	if _, err := env.svc.SearchRolesFiltered(ctx, 1, "role", "default", &database.Pagination{Page: 1, PageSize: 100}); err != nil {
+		t.Fatalf("SearchRolesFiltered: %v", err)
+	}
+
+	// Info 级别下每次调用只输出一行汇总日志
+	lines := strings.Split(strings.TrimSpace(env.logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2 summaries:\n%s", len(lines), env.logs.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "level=INFO") || !strings.Contains(line, "过滤完成") {
+			t.Fatalf("unexpected log line: %s", line)
+		}
+	}
+}
+
+func TestListRolesFiltered_PaginatesAfterLevelFilter(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	admin := env.createRole(t, "admin", "default", 50)
+	env.assignRole(t, 1, admin)
+	// 可见与不可见的角色交错创建，分页后过滤会让每页混入不可见角色
+	for i := 0; i < 25; i++ {
+		env.createRole(t, "low"+strconv.Itoa(i), "default", 10+i%30)
+		env.createRole(t, "high"+strconv.Itoa(i), "default", 60+i%30)
+	}
+
+	for _, list := range []struct {
+		name string
+		page func(p *database.Pagination) ([]model.Role, error)
+	}{
+		{"list", func(p *database.Pagination) ([]model.Role, error) {
+			return env.svc.ListRolesFiltered(ctx, 1, "default", p)
+		}},
+		{"search", func(p *database.Pagination) ([]model.Role, error) {
+			return env.svc.SearchRolesFiltered(ctx, 1, "", "default", p)
+		}},
+	} {
+		seen := make(map[uint]bool)
+		for page := 1; page <= 3; page++ {
+			p := &database.Pagination{Page: page, PageSize: 10}
+			roles, err := list.page(p)
+			if err != nil {
+				t.Fatalf("%s page %d: %v", list.name, page, err)
+			}
+			if p.Total != 25 {
+				t.Fatalf("%s page %d: total = %d, want 25", list.name, page, p.Total)
+			}
+			want := 10
+			if page == 3 {
+				want = 5
+			}
+			if len(roles) != want {
+				t.Fatalf("%s page %d: got %d roles, want %d", list.name, page, len(roles), want)
+			}
+			for _, role := range roles {
+				if role.Level >= 50 {
+					t.Fatalf("%s page %d: role %s at level %d is not below the operator", list.name, page, role.Name, role.Level)
+				}
+				seen[role.ID] = true
+			}
+		}
+		if len(seen) != 25 {
+			t.Fatalf("%s: %d distinct roles across pages, want 25", list.name, len(seen))
+		}
+	}
+}
+
+// createPermission 直接写入权限记录，parentID 可指向任意ID（用于构造异常层级）
+func (env *rbacTestEnv) createPermission(t *testing.T, name string, parentID uint) *model.Permission {
+	t.Helper()
+
+	perm := &model.Permission{Name: name, DisplayName: name, Domain: "default", Type: model.PermissionTypeAPI,
+		Resource: "/api/" + name, Action: "GET", ParentID: parentID}
+	if err := env.db.DB.Create(perm).Error; err != nil {
+		t.Fatalf("create permission %s: %v", name, err)
+	}
+	return perm
+}
+
+// setParent 绕过服务层校验直接修改父节点
+func (env *rbacTestEnv) setParent(t *testing.T, perm *model.Permission, parentID uint) {
+	t.Helper()
+
+	if err := env.db.DB.Model(perm).Update("parent_id", parentID).Error; err != nil {
+		t.Fatalf("update parent of %s: %v", perm.Name, err)
+	}
+	perm.ParentID = parentID
+}
+
+// issuesOf 按类型收集层级问题涉及的权限ID
+func issuesOf(report *PermissionHierarchyReport, typ string) []PermissionHierarchyIssue {
+	var issues []PermissionHierarchyIssue
+	for _, issue := range report.Issues {
+		if issue.Type == typ {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func TestValidatePermissionHierarchy_Valid(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	root := env.createPermission(t, "system", 0)
+	users := env.createPermission(t, "users", root.ID)
+	env.createPermission(t, "users_read", users.ID)
+
+	report, err := env.svc.ValidatePermissionHierarchy(context.Background(), "default", 3)
+	if err != nil {
+		t.Fatalf("ValidatePermissionHierarchy: %v", err)
+	}
+	if !report.Valid || len(report.Issues) != 0 || report.Total != 3 || report.MaxDepth != 3 {
+		t.Fatalf("report = %+v, want valid with 3 permissions at depth 3", report)
+	}
+}
+
+func TestValidatePermissionHierarchy_Orphans(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	root := env.createPermission(t, "system", 0)
+	missing := env.createPermission(t, "missing_parent", 9999)
+
+	deleted := env.createPermission(t, "deleted", 0)
+	underDeleted := env.createPermission(t, "under_deleted", deleted.ID)
+	if err := env.db.DB.Delete(deleted).Error; err != nil {
+		t.Fatalf("delete permission: %v", err)
+	}
+
+	disabled := env.createPermission(t, "disabled", root.ID)
+	underDisabled := env.createPermission(t, "under_disabled", disabled.ID)
+	// status 列默认值为 1，零值不会写入，需单独更新
+	if err := env.db.DB.Model(disabled).Update("status", 0).Error; err != nil {
+		t.Fatalf("disable permission: %v", err)
+	}
+
+	report, err := env.svc.ValidatePermissionHierarchy(context.Background(), "default", 0)
+	if err != nil {
+		t.Fatalf("ValidatePermissionHierarchy: %v", err)
+	}
+	if report.Valid {
+		t.Fatal("report is valid, want orphan issues")
+	}
+
+	orphans := issuesOf(report, HierarchyIssueOrphan)
+	got := map[uint]uint{}
+	for _, o := range orphans {
+		got[o.PermissionID] = o.ParentID
+	}
+	want := map[uint]uint{missing.ID: 9999, underDeleted.ID: deleted.ID, underDisabled.ID: disabled.ID}
+	if len(got) != len(want) || len(orphans) != len(want) {
+		t.Fatalf("orphans = %+v, want %v", orphans, want)
+	}
+	for id, parent := range want {
+		if got[id] != parent {
+			t.Fatalf("orphans = %+v, want %v", orphans, want)
+		}
+	}
+
+	// 孤儿节点在权限树中作为根节点返回
+	tree, err := env.svc.ListPermissionTree(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("ListPermissionTree: %v", err)
+	}
+	roots := map[uint]bool{}
+	for _, p := range tree {
+		roots[p.ID] = true
+	}
+	for id := range want {
+		if !roots[id] {
+			t.Fatalf("orphan %d is not a root in the tree", id)
+		}
+	}
+}
+
+func TestValidatePermissionHierarchy_SelfReferenceAndCycle(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	self := env.createPermission(t, "self", 0)
+	env.setParent(t, self, self.ID)
+
+	a := env.createPermission(t, "a", 0)
+	b := env.createPermission(t, "b", a.ID)
+	c := env.createPermission(t, "c", b.ID)
+	env.setParent(t, a, c.ID) // a -> c -> b -> a
+	tail := env.createPermission(t, "tail", c.ID)
+
+	report, err := env.svc.ValidatePermissionHierarchy(context.Background(), "default", 0)
+	if err != nil {
+		t.Fatalf("ValidatePermissionHierarchy: %v", err)
+	}
+
+	selfRefs := issuesOf(report, HierarchyIssueSelfReference)
+	if len(selfRefs) != 1 || selfRefs[0].PermissionID != self.ID {
+		t.Fatalf("self references = %+v, want permission %d", selfRefs, self.ID)
+	}
+
+	cycles := issuesOf(report, HierarchyIssueCycle)
+	if len(cycles) != 1 {
+		t.Fatalf("cycles = %+v, want one", cycles)
+	}
+	if cycles[0].PermissionID != a.ID || len(cycles[0].Cycle) != 3 {
+		t.Fatalf("cycle = %+v, want three members reported on permission %d", cycles[0], a.ID)
+	}
+	members := map[uint]bool{}
+	for _, id := range cycles[0].Cycle {
+		members[id] = true
+	}
+	if !members[a.ID] || !members[b.ID] || !members[c.ID] || members[tail.ID] {
+		t.Fatalf("cycle members = %v, want a, b, c only", cycles[0].Cycle)
+	}
+
+	// 环上的节点不会导致权限树构建死循环，每个权限只出现一次
+	tree, err := env.svc.ListPermissionTree(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("ListPermissionTree: %v", err)
+	}
+	seen := map[uint]int{}
+	var walk func([]model.Permission)
+	walk = func(nodes []model.Permission) {
+		for _, n := range nodes {
+			seen[n.ID]++
+			walk(n.Children)
+		}
+	}
+	walk(tree)
+	if len(seen) != 5 {
+		t.Fatalf("tree contains %d permissions, want 5", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf("permission %d appears %d times in the tree", id, n)
+		}
+	}
+}
+
+func TestValidatePermissionHierarchy_TooDeep(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	var parent uint
+	var chain []*model.Permission
+	for i := 0; i < 5; i++ {
+		p := env.createPermission(t, "level"+strconv.Itoa(i+1), parent)
+		chain = append(chain, p)
+		parent = p.ID
+	}
+
+	report, err := env.svc.ValidatePermissionHierarchy(context.Background(), "default", 3)
+	if err != nil {
+		t.Fatalf("ValidatePermissionHierarchy: %v", err)
+	}
+	tooDeep := issuesOf(report, HierarchyIssueTooDeep)
+	if report.MaxDepth != 5 || len(tooDeep) != 2 {
+		t.Fatalf("report = %+v, want depth 5 with two nodes beyond 3", report)
+	}
+	if tooDeep[0].PermissionID != chain[3].ID || tooDeep[0].Depth != 4 || tooDeep[1].Depth != 5 {
+		t.Fatalf("too deep = %+v, want levels 4 and 5", tooDeep)
+	}
+}
+
+func TestPermissionParent_Rejected(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	a := env.createPermission(t, "a", 0)
+	b := env.createPermission(t, "b", a.ID)
+	c := env.createPermission(t, "c", b.ID)
+
+	orphan := &model.Permission{Name: "orphan", DisplayName: "orphan", Domain: "default", Type: model.PermissionTypeAPI,
+		Resource: "/api/orphan", Action: "GET", ParentID: 9999}
+	if err := env.svc.CreatePermission(ctx, orphan); !errors.Is(err, ErrInvalidPermissionParent) {
+		t.Fatalf("CreatePermission with missing parent: err = %v, want ErrInvalidPermissionParent", err)
+	}
+
+	tests := []struct {
+		name   string
+		perm   *model.Permission
+		parent uint
+	}{
+		{"self", a, a.ID},
+		{"direct cycle", a, b.ID},
+		{"transitive cycle", a, c.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := *tt.perm
+			update.ParentID = tt.parent
+			if err := env.svc.UpdatePermission(ctx, &update); !errors.Is(err, ErrInvalidPermissionParent) {
+				t.Fatalf("UpdatePermission: err = %v, want ErrInvalidPermissionParent", err)
+			}
+		})
+	}
+}
+
+func TestCreateRole_LevelCeiling(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{
+		MaxRoleLevel:        100,
+		DomainMaxRoleLevels: map[string]int{"tenant": 50},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		domain  string
+		level   int
+		wantErr bool
+	}{
+		{"at ceiling", "default", 100, false},
+		{"above ceiling", "default", 101, true},
+		{"negative", "default", -1, true},
+		{"domain ceiling", "tenant", 50, false},
+		{"above domain ceiling", "tenant", 51, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &model.Role{Name: "role" + strconv.Itoa(i), DisplayName: "role", Domain: tt.domain, Level: tt.level}
+			err := env.svc.CreateRole(ctx, role)
+			if tt.wantErr && !errors.Is(err, ErrRoleLevelOutOfRange) {
+				t.Fatalf("CreateRole(level %d) err = %v, want ErrRoleLevelOutOfRange", tt.level, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("CreateRole(level %d): %v", tt.level, err)
+			}
+		})
+	}
+}
+
+func TestUpdateRole_LevelCeiling(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: 100})
+	ctx := context.Background()
+
+	role := env.createRole(t, "editor", "default", 10)
+	role.Level = 101
+	if err := env.svc.UpdateRole(ctx, role); !errors.Is(err, ErrRoleLevelOutOfRange) {
+		t.Fatalf("UpdateRole(level 101) err = %v, want ErrRoleLevelOutOfRange", err)
+	}
+
+	// 上限调低后，已有高等级角色在等级不变时仍可修改其他字段
+	env.svc.config.MaxRoleLevel = 5
+	role.Level = 10
+	role.DisplayName = "Editor"
+	if err := env.svc.UpdateRole(ctx, role); err != nil {
+		t.Fatalf("UpdateRole with unchanged level: %v", err)
+	}
+}
+
+func TestCheckRoleLevelAssignable_StrictlyBelowOperator(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	manager := env.createRole(t, "manager", "default", 50)
+	env.assignRole(t, 1, manager)
+
+	tests := []struct {
+		level   int
+		allowed bool
+	}{
+		{10, true},
+		{49, true},
+		{50, false}, // 同级不可创建
+		{51, false},
+	}
+	for _, tt := range tests {
+		err := env.svc.CheckRoleLevelAssignable(ctx, 1, tt.level, "default")
+		if tt.allowed && err != nil {
+			t.Errorf("level %d: %v, want allowed", tt.level, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("level %d allowed, want rejected", tt.level)
+		}
+	}
+
+	// 等级只在所在域内生效：在其他域没有角色的用户不能创建任何角色
+	if err := env.svc.CheckRoleLevelAssignable(ctx, 1, 1, "tenant"); err == nil {
+		t.Fatal("level 1 in a domain without roles allowed, want rejected")
+	}
+}
+
+func TestRolelessOperator_SeesNothing(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	env.createRole(t, "viewer", "default", 1)
+	editor := env.createRole(t, "editor", "default", 10)
+
+	level, err := env.svc.GetUserMaxRoleLevel(ctx, 7, "default")
+	if err != nil || level != 0 {
+		t.Fatalf("GetUserMaxRoleLevel = %d, %v, want 0", level, err)
+	}
+	roles, err := env.svc.ListRolesFiltered(ctx, 7, "default", &database.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("ListRolesFiltered: %v", err)
+	}
+	if len(roles) != 0 {
+		t.Fatalf("roleless operator sees %d roles, want none", len(roles))
+	}
+	if err := env.svc.CheckRoleLevelPermission(ctx, 7, editor.ID, "default"); err == nil {
+		t.Fatal("roleless operator can manage editor, want rejected")
+	}
+	if err := env.svc.CheckRoleLevelAssignable(ctx, 7, 1, "default"); err == nil {
+		t.Fatal("roleless operator can create level 1 role, want rejected")
+	}
+}
+
+func TestSuperAdminUser_BypassesLevelFiltering(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: 100, SuperAdminUserIDs: []uint{1}})
+	ctx := context.Background()
+
+	superAdmin := env.createRole(t, "super_admin", "default", 100)
+	env.createRole(t, "editor", "default", 10)
+
+	// 未分配任何角色的指定超级管理员在任意域都不受等级过滤
+	for _, domain := range []string{"default", "tenant"} {
+		level, err := env.svc.GetUserMaxRoleLevel(ctx, 1, domain)
+		if err != nil || level != SuperAdminBypassLevel {
+			t.Fatalf("GetUserMaxRoleLevel(%s) = %d, %v, want SuperAdminBypassLevel", domain, level, err)
+		}
+	}
+	roles, err := env.svc.ListRolesFiltered(ctx, 1, "default", &database.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("ListRolesFiltered: %v", err)
+	}
+	if len(roles) != 2 {
+		t.Fatalf("super admin sees %d roles, want all 2", len(roles))
+	}
+	assignable, err := env.svc.ListAssignableRoles(ctx, 1, "default")
+	if err != nil || len(assignable) != 2 {
+		t.Fatalf("ListAssignableRoles = %d roles, %v, want 2", len(assignable), err)
+	}
+	if err := env.svc.CheckRoleLevelPermission(ctx, 1, superAdmin.ID, "default"); err != nil {
+		t.Fatalf("CheckRoleLevelPermission(super_admin): %v", err)
+	}
+
+	// 等级上限仍然生效
+	if err := env.svc.CreateRole(ctx, &model.Role{Name: "root", DisplayName: "root", Domain: "default", Level: 101}); !errors.Is(err, ErrRoleLevelOutOfRange) {
+		t.Fatalf("CreateRole(level 101) err = %v, want ErrRoleLevelOutOfRange", err)
+	}
+
+	// 未列入配置的无角色用户仍然看不到任何角色
+	if roles, err := env.svc.ListRolesFiltered(ctx, 2, "default", &database.Pagination{Page: 1, PageSize: 100}); err != nil || len(roles) != 0 {
+		t.Fatalf("ListRolesFiltered(user 2) = %d roles, %v, want none", len(roles), err)
+	}
+}
+
+func TestGetOrCreateRole_ConcurrentSeeds(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	// 模拟多个实例同时执行种子脚本
+	const workers = 8
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		roles   = make([]*model.Role, workers)
+		created = make([]bool, workers)
+		errs    = make([]error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			roles[i], created[i], errs[i] = env.svc.GetOrCreateRole(ctx, &model.Role{Name: "editor", DisplayName: "编辑", Domain: "default"})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	createdCount := 0
+	for i := 0; i < workers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("worker %d: %v", i, errs[i])
+		}
+		if roles[i].ID != roles[0].ID {
+			t.Fatalf("worker %d got role %d, want %d", i, roles[i].ID, roles[0].ID)
+		}
+		if created[i] {
+			createdCount++
+		}
+	}
+	if createdCount != 1 {
+		t.Fatalf("created reported %d times, want exactly 1", createdCount)
+	}
+	// 未指定等级时使用默认等级
+	if roles[0].Level != model.DefaultRoleLevel {
+		t.Fatalf("level = %d, want default %d", roles[0].Level, model.DefaultRoleLevel)
+	}
+
+	var count int64
+	env.db.DB.Model(&model.Role{}).Where("name = ? AND domain = ?", "editor", "default").Count(&count)
+	if count != 1 {
+		t.Fatalf("got %d editor roles, want 1", count)
+	}
+}
+
+func TestGetOrCreateRole_Errors(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	if _, _, err := env.svc.GetOrCreateRole(ctx, &model.Role{Name: "root", DisplayName: "root", Domain: "default", Level: 101}); !errors.Is(err, ErrRoleLevelOutOfRange) {
+		t.Fatalf("level above ceiling err = %v, want ErrRoleLevelOutOfRange", err)
+	}
+
+	// 软删除的角色仍占用唯一索引，不会被静默恢复
+	deleted := env.createRole(t, "legacy", "default", 10)
+	if err := env.db.DB.Delete(deleted).Error; err != nil {
+		t.Fatalf("delete role: %v", err)
+	}
+	if _, _, err := env.svc.GetOrCreateRole(ctx, &model.Role{Name: "legacy", DisplayName: "legacy", Domain: "default"}); err == nil {
+		t.Fatal("GetOrCreateRole on a soft-deleted role succeeded, want error")
+	}
+}
+
+// permissionIDs 创建 n 个指定域的权限并返回其ID
+func (env *rbacTestEnv) permissionIDs(t *testing.T, domain string, n int) []uint {
+	t.Helper()
+
+	ids := make([]uint, 0, n)
+	for i := 0; i < n; i++ {
+		name := domain + "_perm" + strconv.Itoa(i)
+		perm := &model.Permission{Name: name, DisplayName: name, Domain: domain, Type: model.PermissionTypeAPI,
+			Resource: "/api/" + name, Action: "GET"}
+		if err := env.db.DB.Create(perm).Error; err != nil {
+			t.Fatalf("create permission %s: %v", name, err)
+		}
+		ids = append(ids, perm.ID)
+	}
+	return ids
+}
+
+// rolePermissionCount 关联表中角色当前的权限数
+func (env *rbacTestEnv) rolePermissionCount(t *testing.T, role *model.Role) int64 {
+	t.Helper()
+
+	return env.db.DB.Model(role).Association("Permissions").Count()
+}
+
+func TestUpdateRolePermissions_PermissionCap(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{
+		MaxRoleLevel:                100,
+		MaxPermissionsPerRole:       3,
+		DomainMaxPermissionsPerRole: map[string]int{"tenant": 2},
+	})
+	ctx := context.Background()
+	defaultIDs := env.permissionIDs(t, "default", 4)
+	tenantIDs := env.permissionIDs(t, "tenant", 3)
+
+	tests := []struct {
+		name    string
+		domain  string
+		ids     []uint
+		wantErr string // 错误中应包含的数量与上限，空表示应成功
+	}{
+		{"below cap", "default", defaultIDs[:2], ""},
+		{"at cap", "default", defaultIDs[:3], ""},
+		{"above cap", "default", defaultIDs, "4 permissions, limit is 3"},
+		{"at domain cap", "tenant", tenantIDs[:2], ""},
+		{"above domain cap", "tenant", tenantIDs, "3 permissions, limit is 2"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := env.createRole(t, "capped"+strconv.Itoa(i), tt.domain, 10)
+
+			for _, preview := range []bool{true, false} {
+				_, _, err := env.svc.UpdateRolePermissions(ctx, role.ID, tt.ids, tt.domain, preview)
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Fatalf("UpdateRolePermissions(preview=%v): %v", preview, err)
+					}
+					continue
+				}
+				if !errors.Is(err, ErrTooManyPermissions) {
+					t.Fatalf("UpdateRolePermissions(preview=%v) err = %v, want ErrTooManyPermissions", preview, err)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %q does not report %q", err, tt.wantErr)
+				}
+			}
+
+			want := int64(len(tt.ids))
+			if tt.wantErr != "" {
+				want = 0
+			}
+			if got := env.rolePermissionCount(t, role); got != want {
+				t.Fatalf("role has %d permissions, want %d", got, want)
+			}
+		})
+	}
+}
+
+// 重复ID只计一次：UpdateRolePermissions 将重复ID作为无效参数拒绝，AssignPermissionsToRole 去重后按上限校验
+func TestPermissionCap_IgnoresDuplicates(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: 100, MaxPermissionsPerRole: 3})
+	ctx := context.Background()
+	ids := env.permissionIDs(t, "default", 3)
+	role := env.createRole(t, "capped", "default", 10)
+	requested := append(slices.Clone(ids), ids[0], ids[2])
+
+	_, _, err := env.svc.UpdateRolePermissions(ctx, role.ID, requested, "default", false)
+	var invalid *InvalidPermissionIDsError
+	if !errors.As(err, &invalid) || len(invalid.Duplicates) != 2 {
+		t.Fatalf("UpdateRolePermissions with duplicates err = %v, want duplicate ids reported", err)
+	}
+
+	if _, err := env.svc.AssignPermissionsToRole(ctx, role.ID, requested, "default"); err != nil {
+		t.Fatalf("AssignPermissionsToRole with duplicates: %v", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 3 {
+		t.Fatalf("role has %d permissions, want 3", got)
+	}
+}
+
+func TestUpdateRolePermissions_UnlimitedWhenCapZero(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: 100})
+	ids := env.permissionIDs(t, "default", 5)
+	role := env.createRole(t, "uncapped", "default", 10)
+
+	if _, _, err := env.svc.UpdateRolePermissions(context.Background(), role.ID, ids, "default", false); err != nil {
+		t.Fatalf("UpdateRolePermissions: %v", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 5 {
+		t.Fatalf("role has %d permissions, want 5", got)
+	}
+}
+
+func TestAssignPermissionsToRole_PermissionCap(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: 100, MaxPermissionsPerRole: 3})
+	ctx := context.Background()
+	ids := env.permissionIDs(t, "default", 4)
+	role := env.createRole(t, "legacy", "default", 10)
+
+	if _, err := env.svc.AssignPermissionsToRole(ctx, role.ID, ids[:3], "default"); err != nil {
+		t.Fatalf("AssignPermissionsToRole at cap: %v", err)
+	}
+
+	_, err := env.svc.AssignPermissionsToRole(ctx, role.ID, ids, "default")
+	if !errors.Is(err, ErrTooManyPermissions) {
+		t.Fatalf("AssignPermissionsToRole above cap err = %v, want ErrTooManyPermissions", err)
+	}
+	if !strings.Contains(err.Error(), "4 permissions, limit is 3") {
+		t.Fatalf("error %q does not report count and cap", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 3 {
+		t.Fatalf("role has %d permissions after rejected assignment, want 3", got)
+	}
+}
+
+func TestGetRolesForUsers_GroupsByUser(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: model.SuperAdminRoleLevel})
+	ctx := context.Background()
+
+	admin := env.createRole(t, "admin", "default", 50)
+	editor := env.createRole(t, "editor", "default", 10)
+	tenantAdmin := env.createRole(t, "admin", "tenant", 50)
+	env.assignRole(t, 1, admin)
+	env.assignRole(t, 1, editor)
+	env.assignRole(t, 2, editor)
+	env.assignRole(t, 2, tenantAdmin)
+
+	// 已过期的分配不返回
+	expired := time.Now().Add(-time.Hour)
+	if err := env.db.DB.Create(&model.UserRole{UserID: 3, RoleID: admin.ID, Domain: "default", ExpiresAt: &expired}).Error; err != nil {
+		t.Fatalf("create expired assignment: %v", err)
+	}
+
+	got, err := env.svc.GetRolesForUsers(ctx, []uint{1, 2, 3, 4}, "default")
+	if err != nil {
+		t.Fatalf("GetRolesForUsers: %v", err)
+	}
+
+	want := map[uint][]string{1: {"admin", "editor"}, 2: {"editor"}, 3: {}, 4: {}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d users, want %d", len(got), len(want))
+	}
+	for userID, names := range want {
+		roles, ok := got[userID]
+		if !ok {
+			t.Fatalf("user %d missing from result", userID)
+		}
+		if len(roles) != len(names) {
+			t.Fatalf("user %d roles = %+v, want %v", userID, roles, names)
+		}
+		for i, role := range roles {
+			if role.Name != names[i] || role.Domain != "default" {
+				t.Fatalf("user %d role %d = %s@%s, want %s@default", userID, i, role.Name, role.Domain, names[i])
+			}
+		}
+	}
+}
+
+// userRoleCount 用户的角色分配记录数
+func (env *rbacTestEnv) userRoleCount(t *testing.T, userID uint) int64 {
+	t.Helper()
+
+	var count int64
+	if err := env.db.DB.Model(&model.UserRole{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		t.Fatalf("count user roles: %v", err)
+	}
+	return count
+}
+
+// 混合域的角色ID：属于目标域的分配，其余按原因列出
+func TestAssignRolesToUser_MixedDomains(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	editor := env.createRole(t, "editor", "default", 10)
+	viewer := env.createRole(t, "viewer", "default", 5)
+	tenantAdmin := env.createRole(t, "admin", "tenant", 50)
+
+	result, err := env.svc.AssignRolesToUser(ctx, 1, []uint{editor.ID, tenantAdmin.ID, 999, viewer.ID, editor.ID}, "default", 0, nil)
+	if err != nil {
+		t.Fatalf("AssignRolesToUser: %v", err)
+	}
+	if !result.Partial() {
+		t.Fatalf("result %+v is not partial", result)
+	}
+	if !slices.Equal(result.Assigned, []uint{editor.ID, viewer.ID}) ||
+		!slices.Equal(result.WrongDomain, []uint{tenantAdmin.ID}) ||
+		!slices.Equal(result.NotFound, []uint{999}) {
+		t.Fatalf("result = %+v, want assigned [%d %d], wrong_domain [%d], not_found [999]", result, editor.ID, viewer.ID, tenantAdmin.ID)
+	}
+	if got := env.userRoleCount(t, 1); got != 2 {
+		t.Fatalf("user has %d role assignments, want 2", got)
+	}
+
+	// 全部ID无效时拒绝
+	result, err = env.svc.AssignRolesToUser(ctx, 2, []uint{tenantAdmin.ID}, "default", 0, nil)
+	if !errors.Is(err, ErrPartialAssignment) {
+		t.Fatalf("all wrong domain err = %v, want ErrPartialAssignment", err)
+	}
+	if len(result.Assigned) != 0 || !slices.Equal(result.WrongDomain, []uint{tenantAdmin.ID}) {
+		t.Fatalf("result = %+v, want nothing assigned", result)
+	}
+}
+
+func TestAssignRolesToUser_RejectPartial(t *testing.T) {
+	env := newTestRBACService(t, &config.RBACConfig{MaxRoleLevel: model.SuperAdminRoleLevel, RejectPartialAssignment: true})
+	ctx := context.Background()
+
+	editor := env.createRole(t, "editor", "default", 10)
+	tenantAdmin := env.createRole(t, "admin", "tenant", 50)
+
+	result, err := env.svc.AssignRolesToUser(ctx, 1, []uint{editor.ID, tenantAdmin.ID}, "default", 0, nil)
+	if !errors.Is(err, ErrPartialAssignment) {
+		t.Fatalf("err = %v, want ErrPartialAssignment", err)
+	}
+	if len(result.Assigned) != 0 || !slices.Equal(result.WrongDomain, []uint{tenantAdmin.ID}) {
+		t.Fatalf("result = %+v, want nothing assigned and wrong_domain [%d]", result, tenantAdmin.ID)
+	}
+	if got := env.userRoleCount(t, 1); got != 0 {
+		t.Fatalf("user has %d role assignments after rejected request, want 0", got)
+	}
+
+	if _, err := env.svc.AssignRolesToUser(ctx, 1, []uint{editor.ID}, "default", 0, nil); err != nil {
+		t.Fatalf("AssignRolesToUser without invalid ids: %v", err)
+	}
+}
+
+func TestAssignPermissionsToRole_MixedDomains(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	ctx := context.Background()
+
+	ids := env.permissionIDs(t, "default", 2)
+	tenantIDs := env.permissionIDs(t, "tenant", 1)
+	role := env.createRole(t, "legacy", "default", 10)
+
+	// 不存在的ID跳过并列出
+	result, err := env.svc.AssignPermissionsToRole(ctx, role.ID, []uint{ids[0], 999, ids[1]}, "default")
+	if err != nil {
+		t.Fatalf("AssignPermissionsToRole: %v", err)
+	}
+	if !slices.Equal(result.Assigned, ids) || !slices.Equal(result.NotFound, []uint{999}) || len(result.WrongDomain) != 0 {
+		t.Fatalf("result = %+v, want assigned %v, not_found [999]", result, ids)
+	}
+	if got := env.rolePermissionCount(t, role); got != 2 {
+		t.Fatalf("role has %d permissions, want 2", got)
+	}
+
+	// 跨域权限拒绝整个请求，不修改已有权限
+	if _, err := env.svc.AssignPermissionsToRole(ctx, role.ID, []uint{ids[0], tenantIDs[0]}, "default"); err == nil || errors.Is(err, ErrPartialAssignment) {
+		t.Fatalf("mixed domain err = %v, want domain mismatch", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 2 {
+		t.Fatalf("role has %d permissions after rejected assignment, want 2", got)
+	}
+
+	// 全部ID不存在时拒绝，不会清空已有权限
+	if _, err := env.svc.AssignPermissionsToRole(ctx, role.ID, []uint{998, 999}, "default"); !errors.Is(err, ErrPartialAssignment) {
+		t.Fatalf("all not found err = %v, want ErrPartialAssignment", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 2 {
+		t.Fatalf("role has %d permissions after rejected assignment, want 2", got)
+	}
+}
+
+// 请求事务回滚后，分配的权限不会保留
+func TestAssignPermissionsToRole_UsesRequestTx(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	ids := env.permissionIDs(t, "default", 2)
+	role := env.createRole(t, "legacy", "default", 10)
+
+	errRollback := errors.New("rollback")
+	err := env.db.DB.Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(context.Background(), tx)
+		if _, err := env.svc.AssignPermissionsToRole(ctx, role.ID, ids, "default"); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction err = %v, want rollback", err)
+	}
+	if got := env.rolePermissionCount(t, role); got != 0 {
+		t.Fatalf("role has %d permissions after rollback, want 0", got)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cccvno1/nova/internal/model"
+)
+
+// requestMemoKey 请求内权限缓存在 context 中的键
+type requestMemoKey struct{}
+
+// memoScope 缓存条目的归属：用户 + 域
+type memoScope struct {
+	userID uint
+	domain string
+}
+
+// requestMemo 单个请求内已加载的权限集与最高角色等级
+type requestMemo struct {
+	mu          sync.Mutex
+	permissions map[memoScope][]model.Permission
+	maxLevels   map[memoScope]int
+}
+
+// WithRequestMemo 为 context 开启请求内权限缓存
+// 之后使用该 context 对同一用户、域调用 GetUserPermissions / CheckPermission / GetUserMaxRoleLevel 时只加载一次，
+// 不再访问 Redis 或数据库。缓存随 context 丢弃，不会跨请求；加载失败不缓存；
+// 同一 context 内修改角色分配或角色权限后整体清空，之后的检查重新加载
+func WithRequestMemo(ctx context.Context) context.Context {
+	if requestMemoFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestMemoKey{}, &requestMemo{
+		permissions: make(map[memoScope][]model.Permission),
+		maxLevels:   make(map[memoScope]int),
+	})
+}
+
+// requestMemoFrom 获取 context 中的请求内缓存，未开启时返回 nil（nil 上的方法均为空操作）
+func requestMemoFrom(ctx context.Context) *requestMemo {
+	memo, _ := ctx.Value(requestMemoKey{}).(*requestMemo)
+	return memo
+}
+
+func (m *requestMemo) getPermissions(userID uint, domain string) ([]model.Permission, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	permissions, ok := m.permissions[memoScope{userID: userID, domain: domain}]
+	return permissions, ok
+}
+
+func (m *requestMemo) setPermissions(userID uint, domain string, permissions []model.Permission) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.permissions[memoScope{userID: userID, domain: domain}] = permissions
+}
+
+func (m *requestMemo) getMaxLevel(userID uint, domain string) (int, bool) {
+	if m == nil {
+		return 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	level, ok := m.maxLevels[memoScope{userID: userID, domain: domain}]
+	return level, ok
+}
+
+func (m *requestMemo) setMaxLevel(userID uint, domain string, level int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxLevels[memoScope{userID: userID, domain: domain}] = level
+}
+
+// reset 清空全部条目，角色分配或角色权限变更后调用
+func (m *requestMemo) reset() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.permissions)
+	clear(m.maxLevels)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+// dropUserRoles 绕过服务层删除用户的角色分配（关联表与 Casbin 分组策略）并清空 Redis，之后未缓存的查询看不到任何角色
+func (env *rbacTestEnv) dropUserRoles(t *testing.T, userID uint, domain string) {
+	t.Helper()
+
+	if err := env.db.DB.Where("user_id = ?", userID).Delete(&model.UserRole{}).Error; err != nil {
+		t.Fatalf("delete user roles: %v", err)
+	}
+	if _, err := env.enforcer.DeleteRolesForUser(casbinKey(userID), domain); err != nil {
+		t.Fatalf("delete grouping policies: %v", err)
+	}
+	if err := cache.GetClient().FlushAll(context.Background()).Err(); err != nil {
+		t.Fatalf("flush redis: %v", err)
+	}
+}
+
+func TestRequestMemo_ReusesWithinContext(t *testing.T) {
+	env := newTestRBACService(t, nil)
+
+	ids := env.permissionIDs(t, "default", 1)
+	role := env.createRole(t, "editor", "default", 50)
+	if _, err := env.svc.AssignPermissionsToRole(context.Background(), role.ID, ids, "default"); err != nil {
+		t.Fatalf("AssignPermissionsToRole: %v", err)
+	}
+	env.assignRole(t, 1, role)
+
+	ctx := WithRequestMemo(context.Background())
+	if perms, err := env.svc.GetUserPermissions(ctx, 1, "default"); err != nil || len(perms) != 1 {
+		t.Fatalf("GetUserPermissions = %d permissions, %v; want 1", len(perms), err)
+	}
+	if level, err := env.svc.GetUserMaxRoleLevel(ctx, 1, "default"); err != nil || level != 50 {
+		t.Fatalf("GetUserMaxRoleLevel = %d, %v; want 50", level, err)
+	}
+
+	env.dropUserRoles(t, 1, "default")
+
+	// 同一请求内沿用已加载的结果
+	if perms, _ := env.svc.GetUserPermissions(ctx, 1, "default"); len(perms) != 1 {
+		t.Fatalf("memoized GetUserPermissions = %d permissions, want 1", len(perms))
+	}
+	if allowed, _ := env.svc.CheckPermission(ctx, 1, "default", "/api/default_perm0", "GET"); !allowed {
+		t.Fatal("memoized CheckPermission = false, want true")
+	}
+	if level, _ := env.svc.GetUserMaxRoleLevel(ctx, 1, "default"); level != 50 {
+		t.Fatalf("memoized GetUserMaxRoleLevel = %d, want 50", level)
+	}
+	// 其他域与其他请求各自加载
+	if level, _ := env.svc.GetUserMaxRoleLevel(ctx, 1, "tenant"); level != 0 {
+		t.Fatalf("GetUserMaxRoleLevel in tenant = %d, want 0", level)
+	}
+	next := WithRequestMemo(context.Background())
+	if perms, _ := env.svc.GetUserPermissions(next, 1, "default"); len(perms) != 0 {
+		t.Fatalf("next request GetUserPermissions = %d permissions, want 0", len(perms))
+	}
+	if level, _ := env.svc.GetUserMaxRoleLevel(next, 1, "default"); level != 0 {
+		t.Fatalf("next request GetUserMaxRoleLevel = %d, want 0", level)
+	}
+}
+
+// 同一请求内修改角色分配后，之后的检查按新数据重新加载
+func TestRequestMemo_ResetByRoleChange(t *testing.T) {
+	env := newTestRBACService(t, nil)
+	role := env.createRole(t, "editor", "default", 50)
+
+	ctx := WithRequestMemo(context.Background())
+	if level, _ := env.svc.GetUserMaxRoleLevel(ctx, 1, "default"); level != 0 {
+		t.Fatalf("GetUserMaxRoleLevel before assignment = %d, want 0", level)
+	}
+	if _, err := env.svc.AssignRolesToUser(ctx, 1, []uint{role.ID}, "default", 0, nil); err != nil {
+		t.Fatalf("AssignRolesToUser: %v", err)
+	}
+	if _, err := env.enforcer.AddRoleForUser(casbinKey(1), casbinKey(role.ID), "default"); err != nil {
+		t.Fatalf("add grouping policy: %v", err)
+	}
+	if level, _ := env.svc.GetUserMaxRoleLevel(ctx, 1, "default"); level != 50 {
+		t.Fatalf("GetUserMaxRoleLevel after assignment = %d, want 50", level)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ImportUserRow 批量导入的单行用户数据
+type ImportUserRow struct {
+	Username string   `json:"username" validate:"required,min=3,max=50"`
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"omitempty,min=6,max=32"` // 为空时自动生成随机密码
+	Nickname string   `json:"nickname" validate:"omitempty,max=50"`
+	Roles    []string `json:"roles"` // 角色标识（name）列表
+}
+
+// ImportUserResult 单行导入结果
+type ImportUserResult struct {
+	Row      int    `json:"row"` // 行号（从 1 开始）
+	Username string `json:"username"`
+	Success  bool   `json:"success"`
+	UserID   uint   `json:"user_id,omitempty"`
+	Password string `json:"password,omitempty"` // 自动生成的密码（仅生成时返回）
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportUser 导入单个用户并分配角色（同一事务内完成）
+// operatorLevel 为操作者最高角色等级，只能分配比自己等级低的角色
+func (s *UserService) ImportUser(ctx context.Context, row *ImportUserRow, domain string, operatorID uint, operatorLevel int) (*ImportUserResult, error) {
+	result, user, err := s.prepareImportUser(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return insertImportUser(tx, user, row.Roles, domain, operatorID, operatorLevel)
+	})
+	if err != nil {
+		result.Password = ""
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	result.Success = true
+	result.UserID = user.ID
+	return result, nil
+}
+
+// prepareImportUser 生成密码哈希与默认头像，构建待写入的用户（耗时操作放在事务外）
+func (s *UserService) prepareImportUser(ctx context.Context, row *ImportUserRow) (*ImportUserResult, *model.User, error) {
+	result := &ImportUserResult{Username: row.Username}
+
+	password := row.Password
+	if password == "" {
+		generated, err := generatePassword()
+		if err != nil {
+			return nil, nil, err
+		}
+		password = generated
+		result.Password = generated
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &model.User{
+		Username: row.Username,
+		Email:    row.Email,
+		Password: string(hashed),
+		Nickname: row.Nickname,
+		Status:   1,
+	}
+	s.fillDefaultAvatar(ctx, user)
+
+	return result, user, nil
+}
+
+// insertImportUser 在事务中创建用户并分配角色
+func insertImportUser(tx *gorm.DB, user *model.User, roleNames []string, domain string, operatorID uint, operatorLevel int) error {
+	var count int64
+	if err := tx.Model(&model.User{}).Where("username = ? OR email = ?", user.Username, user.Email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("username or email already exists")
+	}
+
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+
+	if len(roleNames) == 0 {
+		return nil
+	}
+
+	var roles []model.Role
+	if err := tx.Where("name IN ? AND domain = ?", roleNames, domain).Find(&roles).Error; err != nil {
+		return err
+	}
+	if len(roles) != len(roleNames) {
+		return fmt.Errorf("some roles not found in domain %s", domain)
+	}
+
+	userRoles := make([]model.UserRole, 0, len(roles))
+	for _, role := range roles {
+		if operatorLevel <= role.Level {
+			return fmt.Errorf("权限不足：无法分配等级为 %d 的角色 '%s'（您的等级为 %d）", role.Level, role.Name, operatorLevel)
+		}
+		userRoles = append(userRoles, model.UserRole{
+			UserID:     user.ID,
+			RoleID:     role.ID,
+			Domain:     domain,
+			AssignedBy: operatorID,
+		})
+	}
+
+	return tx.Create(&userRoles).Error
+}
+
+// ImportColumns CSV 导入表头的列位置
+type ImportColumns map[string]int
+
+// ParseImportHeader 校验并解析 CSV 导入表头，必须包含 username 与 email 列（不区分大小写）
+func ParseImportHeader(header []string) (ImportColumns, error) {
+	columns := make(ImportColumns, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New(errors.ErrInvalidParams, "csv header must contain username")
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New(errors.ErrInvalidParams, "csv header must contain email")
+	}
+	return columns, nil
+}
+
+// Row 将一行 CSV 记录转换为导入数据，roles 列中的多个角色用 ";" 分隔
+func (c ImportColumns) Row(record []string) ImportUserRow {
+	get := func(name string) string {
+		if i, ok := c[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := ImportUserRow{
+		Username: get("username"),
+		Email:    get("email"),
+		Password: get("password"),
+		Nickname: get("nickname"),
+	}
+	if roles := get("roles"); roles != "" {
+		for _, role := range strings.Split(roles, ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				row.Roles = append(row.Roles, role)
+			}
+		}
+	}
+	return row
+}
+
+// generatePassword 生成随机密码（16 个字符）
+func generatePassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/queue"
+	"github.com/cccvno1/nova/pkg/storage"
+	customValidator "github.com/cccvno1/nova/pkg/validator"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserImportTaskName 异步用户导入的队列任务名
+const UserImportTaskName = "user_import"
+
+// maxReportedImportErrors 结果摘要中保留的失败行数上限，避免大文件的结果过大
+const maxReportedImportErrors = 100
+
+// UserImportOptions 异步导入参数
+type UserImportOptions struct {
+	Domain        string // 分配角色的域
+	OperatorID    uint   // 操作者ID（写入 assigned_by 与任务记录）
+	OperatorLevel int    // 操作者最高角色等级，只能分配比自己等级低的角色
+	MaxErrors     int    // 失败行数超过该值时中止，0 表示不中止
+	WelcomeTask   string // 成功后投递的欢迎邮件任务名，为空不投递
+}
+
+// UserImportJob 异步导入的队列载荷
+type UserImportJob struct {
+	TaskID        string `json:"task_id"`        // 任务记录ID（客户端轮询 GET /tasks/task/:taskId）
+	Path          string `json:"path"`           // CSV 在存储中的路径
+	Size          int64  `json:"size"`           // CSV 字节数（用于计算进度）
+	Domain        string `json:"domain"`         // 分配角色的域
+	OperatorID    uint   `json:"operator_id"`    // 操作者ID
+	OperatorLevel int    `json:"operator_level"` // 操作者最高角色等级
+	MaxErrors     int    `json:"max_errors"`     // 失败行数上限，0 表示不中止
+	WelcomeTask   string `json:"welcome_task"`   // 欢迎邮件任务名
+}
+
+// UserImportSummary 异步导入的结果摘要（写入任务记录的 result）
+type UserImportSummary struct {
+	Processed int                `json:"processed"`         // 已处理行数
+	Succeeded int                `json:"succeeded"`         // 成功行数
+	Failed    int                `json:"failed"`            // 失败行数
+	Aborted   bool               `json:"aborted,omitempty"` // 是否因失败行数超限而中止
+	Errors    []ImportUserResult `json:"errors,omitempty"`  // 失败行（最多保留 100 条）
+}
+
+// UserImportService 大文件异步导入
+// Start 流式保存上传的 CSV 并投递队列任务，Run 在 Worker 中逐行读取并按批次提交，
+// 内存占用只与批大小有关，与文件行数无关
+type UserImportService struct {
+	users       *UserService
+	storage     storage.Storage
+	taskRepo    repository.TaskRepository
+	queueClient *queue.Client
+	validator   *customValidator.CustomValidator
+	tempDir     string
+	batchSize   int
+	maxErrors   int
+}
+
+// NewUserImportService 创建异步导入服务
+func NewUserImportService(users *UserService, storage storage.Storage, taskRepo repository.TaskRepository, queueClient *queue.Client, queueCfg *config.QueueConfig, tempDir string) *UserImportService {
+	return &UserImportService{
+		users:       users,
+		storage:     storage,
+		taskRepo:    taskRepo,
+		queueClient: queueClient,
+		validator:   customValidator.New(),
+		tempDir:     tempDir,
+		batchSize:   queueCfg.ImportBatchSize,
+		maxErrors:   queueCfg.ImportMaxErrors,
+	}
+}
+
+// Start 校验表头后将 CSV 流式写入临时文件并保存到存储，创建任务记录并投递队列任务，返回任务ID
+// opts.MaxErrors < 0 时使用 queue.import_max_errors
+func (s *UserImportService) Start(ctx context.Context, r io.Reader, opts UserImportOptions) (string, error) {
+	// 先读取并校验表头，表头无效时不落盘
+	br := bufio.NewReader(r)
+	headerLine, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.WrapWithMessage(errors.ErrInvalidParams, "invalid csv header", err)
+	}
+	header, err := csv.NewReader(strings.NewReader(headerLine)).Read()
+	if err != nil {
+		return "", errors.New(errors.ErrInvalidParams, "invalid csv header")
+	}
+	if _, err := ParseImportHeader(header); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(s.tempDir, "import_*.csv")
+	if err != nil {
+		return "", errors.Wrap(errors.ErrInternalServer, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, io.MultiReader(strings.NewReader(headerLine), br))
+	if err != nil {
+		return "", errors.WrapWithMessage(errors.ErrInvalidParams, "failed to read csv", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	taskID := uuid.New().String()
+	savedName := taskID + ".csv"
+	storagePath := path.Join("imports", time.Now().Format("2006/01/02"), savedName)
+	if _, err := s.storage.Upload(ctx, f, savedName, storagePath); err != nil {
+		return "", errors.Wrap(errors.ErrInternalServer, fmt.Errorf("failed to store import file: %w", err))
+	}
+
+	maxErrors := opts.MaxErrors
+	if maxErrors < 0 {
+		maxErrors = s.maxErrors
+	}
+	job := UserImportJob{
+		TaskID:        taskID,
+		Path:          storagePath,
+		Size:          size,
+		Domain:        opts.Domain,
+		OperatorID:    opts.OperatorID,
+		OperatorLevel: opts.OperatorLevel,
+		MaxErrors:     maxErrors,
+		WelcomeTask:   opts.WelcomeTask,
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrInternalServer, err)
+	}
+
+	task := &model.Task{
+		TaskID:   taskID,
+		Name:     UserImportTaskName,
+		Type:     model.TaskTypeAsync,
+		Payload:  string(payload),
+		Status:   model.TaskStatusPending,
+		MaxRetry: 0,
+		UserID:   opts.OperatorID,
+	}
+	if err := s.taskRepo.Create(ctx, task); err != nil {
+		_ = s.storage.Delete(ctx, storagePath)
+		return "", errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	var queuePayload map[string]interface{}
+	if err := json.Unmarshal(payload, &queuePayload); err != nil {
+		return "", errors.Wrap(errors.ErrInternalServer, err)
+	}
+	// 不重试：重跑会把已提交的行再次报告为重复
+	if _, err := s.queueClient.Submit(ctx, UserImportTaskName, queuePayload, 0); err != nil {
+		_ = s.taskRepo.UpdateStatus(ctx, taskID, model.TaskStatusFailed, err.Error())
+		_ = s.storage.Delete(ctx, storagePath)
+		return "", err
+	}
+
+	return taskID, nil
+}
+
+// Run 执行导入任务（由队列 Worker 调用）
+// 逐行读取 CSV，每 batchSize 行在一个事务中提交（行与行之间使用保存点，单行失败不影响同批其他行），
+// 每批提交后更新任务进度；失败行数超过上限时中止，已提交的批次保留
+func (s *UserImportService) Run(ctx context.Context, job UserImportJob) error {
+	if err := s.taskRepo.UpdateStatus(ctx, job.TaskID, model.TaskStatusProcessing, ""); err != nil {
+		logger.Warn("failed to mark import task processing", "task_id", job.TaskID, "error", err)
+	}
+	defer func() {
+		if err := s.storage.Delete(context.Background(), job.Path); err != nil {
+			logger.Warn("failed to delete import file", "task_id", job.TaskID, "path", job.Path, "error", err)
+		}
+	}()
+
+	state, runErr := s.run(ctx, job)
+
+	result, _ := json.Marshal(state.summary)
+	progress := 100
+	status := model.TaskStatusSuccess
+	errMsg := ""
+	if runErr != nil {
+		status = model.TaskStatusFailed
+		errMsg = runErr.Error()
+		progress = job.progress(state.read)
+	}
+	if err := s.taskRepo.UpdateProgress(ctx, job.TaskID, progress, string(result)); err != nil {
+		logger.Warn("failed to update import task progress", "task_id", job.TaskID, "error", err)
+	}
+	if err := s.taskRepo.UpdateStatus(ctx, job.TaskID, status, errMsg); err != nil {
+		logger.Warn("failed to update import task status", "task_id", job.TaskID, "error", err)
+	}
+
+	return runErr
+}
+
+// importProgress 读取进度（按已读取的字节数估算）
+type importProgress struct {
+	summary UserImportSummary
+	read    int64
+}
+
+// run 逐批导入，返回结果摘要与读取进度
+func (s *UserImportService) run(ctx context.Context, job UserImportJob) (*importProgress, error) {
+	state := &importProgress{}
+
+	file, err := s.storage.Download(ctx, job.Path)
+	if err != nil {
+		return state, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(&countingReader{r: file, n: &state.read})
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return state, fmt.Errorf("invalid csv header: %w", err)
+	}
+	columns, err := ParseImportHeader(header)
+	if err != nil {
+		return state, err
+	}
+
+	batch := make([]ImportUserRow, 0, s.batchSize)
+	line := 1 // 数据行号（从 1 开始，不含表头）
+	firstLine := line
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return state, fmt.Errorf("invalid csv at row %d: %w", line, err)
+		}
+		if len(batch) == 0 {
+			firstLine = line
+		}
+		batch = append(batch, columns.Row(record))
+		line++
+
+		if len(batch) >= s.batchSize {
+			if err := s.importBatch(ctx, job, batch, firstLine, state); err != nil {
+				return state, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := s.importBatch(ctx, job, batch, firstLine, state); err != nil {
+			return state, err
+		}
+	}
+
+	return state, nil
+}
+
+// importBatch 在一个事务中导入一批行并更新进度
+func (s *UserImportService) importBatch(ctx context.Context, job UserImportJob, rows []ImportUserRow, firstLine int, state *importProgress) error {
+	type pending struct {
+		result *ImportUserResult
+		user   *model.User
+		row    *ImportUserRow
+	}
+
+	summary := &state.summary
+	fail := func(result ImportUserResult) {
+		summary.Failed++
+		if len(summary.Errors) < maxReportedImportErrors {
+			summary.Errors = append(summary.Errors, result)
+		}
+	}
+
+	// 校验与密码哈希在事务外完成，缩短事务持有时间
+	prepared := make([]pending, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		rowNum := firstLine + i
+		if err := s.validator.Validate(row); err != nil {
+			fail(ImportUserResult{Row: rowNum, Username: row.Username, Error: err.Error()})
+			continue
+		}
+		result, user, err := s.users.prepareImportUser(ctx, row)
+		if err != nil {
+			return err
+		}
+		result.Row = rowNum
+		// 异步导入不返回自动生成的密码，用户需通过重置密码登录
+		result.Password = ""
+		prepared = append(prepared, pending{result: result, user: user, row: row})
+	}
+
+	err := s.users.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, p := range prepared {
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				return insertImportUser(sp, p.user, p.row.Roles, job.Domain, job.OperatorID, job.OperatorLevel)
+			})
+			if err != nil {
+				p.result.Error = err.Error()
+				continue
+			}
+			p.result.Success = true
+			p.result.UserID = p.user.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit import batch at row %d: %w", firstLine, err)
+	}
+
+	for _, p := range prepared {
+		if !p.result.Success {
+			fail(*p.result)
+			continue
+		}
+		summary.Succeeded++
+		if job.WelcomeTask != "" {
+			_, _ = s.queueClient.Submit(ctx, job.WelcomeTask, map[string]interface{}{
+				"user_id":  p.user.ID,
+				"username": p.user.Username,
+				"email":    p.user.Email,
+			}, 3)
+		}
+	}
+	summary.Processed += len(rows)
+
+	if job.MaxErrors > 0 && summary.Failed > job.MaxErrors {
+		summary.Aborted = true
+	}
+
+	result, _ := json.Marshal(summary)
+	if err := s.taskRepo.UpdateProgress(ctx, job.TaskID, job.progress(state.read), string(result)); err != nil {
+		logger.Warn("failed to update import task progress", "task_id", job.TaskID, "error", err)
+	}
+
+	if summary.Aborted {
+		return fmt.Errorf("import aborted: %d rows failed, exceeds max_errors %d", summary.Failed, job.MaxErrors)
+	}
+	return nil
+}
+
+// progress 按已读取字节数估算进度（0-99，完成时由 Run 置为 100）
+func (j UserImportJob) progress(read int64) int {
+	if j.Size <= 0 {
+		return 0
+	}
+	return int(min(read*100/j.Size, 99))
+}
+
+// countingReader 统计已读取字节数
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
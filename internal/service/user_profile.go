@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// emailChangePrefix 邮箱修改确认令牌（键为令牌摘要，值为用户ID与新邮箱）
+const emailChangePrefix = "user:email_change"
+
+// FieldChange 字段的新旧值
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// EmailChange 待确认的邮箱修改，确认令牌需发送到新邮箱
+type EmailChange struct {
+	Email     string        `json:"email"`
+	Token     string        `json:"-"`
+	ExpiresIn time.Duration `json:"-"`
+}
+
+// UpdateUserResult 更新用户资料的结果
+type UpdateUserResult struct {
+	Changes     map[string]FieldChange `json:"changes"`                // 已生效的变更，自定义资料按 profile.<字段> 记录
+	EmailChange *EmailChange           `json:"email_change,omitempty"` // 修改邮箱时的待确认信息，确认前邮箱不变
+}
+
+// emailChangeRecord 邮箱修改令牌对应的内容
+type emailChangeRecord struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// mergeProfile 校验并合并自定义资料，值为 null 的字段表示删除
+// 只允许 user.profile_fields 中配置的字段，合并后的 JSON 不超过 user.profile_max_size
+func (s *UserService) mergeProfile(current string, patch map[string]interface{}) (string, map[string]FieldChange, error) {
+	profile := map[string]interface{}{}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &profile); err != nil {
+			return "", nil, errors.Wrap(errors.ErrInternalServer, err)
+		}
+	}
+
+	var unknown []string
+	for key := range patch {
+		if !slices.Contains(s.userConfig.ProfileFields, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", nil, errors.New(errors.ErrInvalidParams, "unsupported profile fields: "+strings.Join(unknown, ", "))
+	}
+
+	changes := make(map[string]FieldChange)
+	for key, value := range patch {
+		old, exists := profile[key]
+		if value == nil {
+			if exists {
+				delete(profile, key)
+				changes["profile."+key] = FieldChange{Old: old, New: nil}
+			}
+			continue
+		}
+		if !exists || !reflect.DeepEqual(old, value) {
+			profile[key] = value
+			changes["profile."+key] = FieldChange{Old: old, New: value}
+		}
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return "", nil, errors.New(errors.ErrInvalidParams, "invalid profile")
+	}
+	if len(data) > s.userConfig.ProfileMaxSize {
+		return "", nil, errors.New(errors.ErrInvalidParams, fmt.Sprintf("profile exceeds %d bytes", s.userConfig.ProfileMaxSize))
+	}
+	return string(data), changes, nil
+}
+
+// requestEmailChange 校验新邮箱并签发确认令牌，邮箱在确认后才生效
+func (s *UserService) requestEmailChange(ctx context.Context, user *model.User, email string) (*EmailChange, error) {
+	if s.userConfig.EmailChangeURL == "" {
+		return nil, errors.New(errors.ErrServiceDisabled, "email change disabled")
+	}
+
+	exists, err := s.userRepo.ExistsByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+	if exists {
+		return nil, errors.New(errors.ErrRecordExists, "email already exists")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	record, err := json.Marshal(emailChangeRecord{UserID: user.ID, Email: email})
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	ttl := time.Duration(s.userConfig.EmailChangeTTL) * time.Second
+	if err := cache.Set(ctx, emailChangeKey(token), record, ttl); err != nil {
+		return nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return &EmailChange{Email: email, Token: token, ExpiresIn: ttl}, nil
+}
+
+// ConfirmEmailChange 校验确认令牌（单次有效）并将邮箱修改为申请时的新邮箱
+// 返回修改后的用户与邮箱变更明细（用于审计）
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) (*UserResponse, map[string]FieldChange, error) {
+	if token == "" {
+		return nil, nil, errors.New(errors.ErrInvalidParams, "token is required")
+	}
+
+	val, err := cache.GetDel(ctx, emailChangeKey(token))
+	if err == redis.Nil {
+		return nil, nil, errors.New(errors.ErrInvalidParams, "confirmation link is invalid or expired")
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.ErrInternalServer, err)
+	}
+	var record emailChangeRecord
+	if err := json.Unmarshal([]byte(val), &record); err != nil {
+		return nil, nil, errors.New(errors.ErrInvalidParams, "confirmation link is invalid or expired")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, record.UserID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, errors.New(errors.ErrRecordNotFound, "user not found")
+		}
+		return nil, nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	// 申请后邮箱可能已被其他用户占用
+	exists, err := s.userRepo.ExistsByEmail(ctx, record.Email)
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+	if exists {
+		return nil, nil, errors.New(errors.ErrRecordExists, "email already exists")
+	}
+
+	changes := map[string]FieldChange{"email": {Old: user.Email, New: record.Email}}
+	user.Email = record.Email
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	logger.Info("user email changed", "user_id", user.ID)
+	return s.toResponse(ctx, user), changes, nil
+}
+
+// emailChangeKey 确认令牌键，以令牌摘要代替令牌原文
+func emailChangeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%s:%s", emailChangePrefix, hex.EncodeToString(sum[:]))
+}
@@ -4,27 +4,84 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/cccvno1/nova/internal/model"
 	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/pkg/auth"
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
-	jwtAuth  *auth.JWTAuth
+	db            *gorm.DB
+	userRepo      *repository.UserRepository
+	jwtAuth       *auth.JWTAuth
+	avatarService AvatarService
+	avatarURLs    *AvatarURLResolver
+	sessions      *auth.SessionManager
+	cascadeDelete bool
+	defaultRoles  []string           // 注册时自动分配的 default 域角色名
+	userConfig    *config.UserConfig // 自定义资料字段与邮箱修改确认配置
 }
 
-func NewUserService(db *gorm.DB, jwtAuth *auth.JWTAuth) *UserService {
+// NewUserService 创建用户服务
+// avatarService 为 nil 时不生成默认头像；avatarURLs 为 nil 时头像原样返回；cascadeDelete 为 true 时删除用户会级联撤销角色、会话与权限缓存；
+// defaultRoles 为注册时自动分配的 default 域角色名，为空时新用户没有任何角色；userConfig 控制资料更新规则
+func NewUserService(db *gorm.DB, jwtAuth *auth.JWTAuth, avatarService AvatarService, avatarURLs *AvatarURLResolver, sessions *auth.SessionManager, cascadeDelete bool, defaultRoles []string, userConfig *config.UserConfig) *UserService {
 	return &UserService{
-		userRepo: repository.NewUserRepository(db, true), // true 表示启用缓存
-		jwtAuth:  jwtAuth,
+		db:            db,
+		userRepo:      repository.NewUserRepository(db, true), // true 表示启用缓存
+		jwtAuth:       jwtAuth,
+		avatarService: avatarService,
+		avatarURLs:    avatarURLs,
+		sessions:      sessions,
+		cascadeDelete: cascadeDelete,
+		defaultRoles:  defaultRoles,
+		userConfig:    userConfig,
 	}
 }
 
+// ValidateDefaultRoles 检查配置的注册默认角色是否都存在于 default 域，供启动时调用
+func (s *UserService) ValidateDefaultRoles(ctx context.Context) error {
+	if len(s.defaultRoles) == 0 {
+		return nil
+	}
+	_, err := findDefaultRoles(s.db.WithContext(ctx), s.defaultRoles)
+	return err
+}
+
+// findDefaultRoles 按名称查询 default 域的角色，有任一角色不存在时返回错误
+func findDefaultRoles(tx *gorm.DB, names []string) ([]model.Role, error) {
+	var roles []model.Role
+	if err := tx.Where("name IN ? AND domain = ?", names, "default").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("default roles not found in domain default: %s", strings.Join(missing, ", "))
+	}
+	return roles, nil
+}
+
 type CreateUserRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
@@ -32,9 +89,17 @@ type CreateUserRequest struct {
 	Nickname string `json:"nickname" validate:"omitempty,max=50"`
 }
 
+// UpdateUserRequest 更新用户资料，未携带的字段保持不变
+// 邮箱修改需确认后生效；username 与 id 不可修改，携带时拒绝请求
 type UpdateUserRequest struct {
-	Nickname string `json:"nickname" validate:"omitempty,max=50"`
-	Avatar   string `json:"avatar" validate:"omitempty,url"`
+	Nickname string                 `json:"nickname" validate:"omitempty,max=50"`
+	Avatar   string                 `json:"avatar" validate:"omitempty,url"`
+	Email    string                 `json:"email" validate:"omitempty,email,max=100"`
+	Phone    *string                `json:"phone" validate:"omitempty,max=20"` // 空字符串表示清除
+	Profile  map[string]interface{} `json:"profile"`                           // 按字段合并，值为 null 表示删除该字段
+
+	Username string `json:"username"` // 不可修改
+	ID       uint   `json:"id"`       // 不可修改
 }
 
 type UserResponse struct {
@@ -43,7 +108,11 @@ type UserResponse struct {
 	Email    string `json:"email"`
 	Nickname string `json:"nickname"`
 	Avatar   string `json:"avatar"`
+	Phone    string `json:"phone,omitempty"`
 	Status   int    `json:"status"`
+
+	Profile       json.RawMessage `json:"profile,omitempty"`        // 自定义资料
+	DefaultDomain string          `json:"default_domain,omitempty"` // 默认域
 }
 
 func (s *UserService) Create(ctx context.Context, req *CreateUserRequest) (*UserResponse, error) {
@@ -70,12 +139,13 @@ func (s *UserService) Create(ctx context.Context, req *CreateUserRequest) (*User
 		Nickname: req.Nickname,
 		Status:   1,
 	}
+	s.fillDefaultAvatar(ctx, user)
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	return s.toResponse(user), nil
+	return s.toResponse(ctx, user), nil
 }
 
 func (s *UserService) GetByID(ctx context.Context, id uint) (*UserResponse, error) {
@@ -87,7 +157,7 @@ func (s *UserService) GetByID(ctx context.Context, id uint) (*UserResponse, erro
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	return s.toResponse(user), nil
+	return s.toResponse(ctx, user), nil
 }
 
 func (s *UserService) List(ctx context.Context, pagination *database.Pagination) ([]UserResponse, error) {
@@ -98,54 +168,336 @@ func (s *UserService) List(ctx context.Context, pagination *database.Pagination)
 
 	result := make([]UserResponse, len(users))
 	for i, user := range users {
-		result[i] = *s.toResponse(&user)
+		result[i] = *s.toResponse(ctx, &user)
+	}
+
+	return result, nil
+}
+
+// Search 按用户名、昵称（includeEmail 为 true 时含邮箱）不区分大小写模糊搜索用户，不包含已删除的用户
+// 是否允许按邮箱匹配由调用方根据 user:read_pii 权限决定
+func (s *UserService) Search(ctx context.Context, keyword string, includeEmail bool, pagination *database.Pagination) ([]UserResponse, error) {
+	users, err := s.userRepo.Search(ctx, strings.TrimSpace(keyword), includeEmail, pagination)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	result := make([]UserResponse, len(users))
+	for i, user := range users {
+		result[i] = *s.toResponse(ctx, &user)
+	}
+
+	return result, nil
+}
+
+// UserRoleBrief 用户列表中附带的角色摘要
+type UserRoleBrief struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Level       int    `json:"level"`
+}
+
+// UserWithRolesResponse 带角色的用户信息
+type UserWithRolesResponse struct {
+	UserResponse
+	Roles []UserRoleBrief `json:"roles"`
+}
+
+// userRoleRow 批量查询用户角色时的扫描行
+type userRoleRow struct {
+	UserID uint
+	UserRoleBrief
+}
+
+// ListWithRoles 分页查询用户及其在指定域下的角色
+// roleName 不为空时只返回拥有该角色的用户；角色通过一次 IN 查询批量加载，避免 N+1
+func (s *UserService) ListWithRoles(ctx context.Context, domain, roleName string, pagination *database.Pagination) ([]UserWithRolesResponse, error) {
+	query := s.db.WithContext(ctx).Model(&model.User{})
+	if roleName != "" {
+		holders := s.db.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("INNER JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+			Where("user_roles.domain = ? AND roles.name = ? AND user_roles.deleted_at IS NULL", domain, roleName).
+			Scopes(repository.ActiveUserRoles(time.Now()))
+		query = query.Where("id IN (?)", holders)
+	}
+
+	if err := query.Count(&pagination.Total).Error; err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	var users []model.User
+	if err := query.Scopes(database.Paginate(pagination)).Order("id ASC").Find(&users).Error; err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	result := make([]UserWithRolesResponse, len(users))
+	if len(users) == 0 {
+		return result, nil
+	}
+
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+
+	var rows []userRoleRow
+	if err := s.db.WithContext(ctx).Table("user_roles").
+		Select("user_roles.user_id, roles.id, roles.name, roles.display_name, roles.level").
+		Joins("INNER JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+		Where("user_roles.user_id IN ? AND user_roles.domain = ? AND user_roles.deleted_at IS NULL", userIDs, domain).
+		Scopes(repository.ActiveUserRoles(time.Now())).
+		Order("roles.level DESC, roles.id ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	rolesByUser := make(map[uint][]UserRoleBrief, len(users))
+	for _, row := range rows {
+		rolesByUser[row.UserID] = append(rolesByUser[row.UserID], row.UserRoleBrief)
+	}
+
+	for i, user := range users {
+		roles := rolesByUser[user.ID]
+		if roles == nil {
+			roles = []UserRoleBrief{}
+		}
+		result[i] = UserWithRolesResponse{
+			UserResponse: *s.toResponse(ctx, &user),
+			Roles:        roles,
+		}
 	}
 
 	return result, nil
 }
 
-func (s *UserService) Update(ctx context.Context, id uint, req *UpdateUserRequest) error {
+// Update 更新用户资料，返回已生效的字段变更
+// 修改邮箱时只签发确认令牌（由调用方发送到新邮箱），邮箱在 ConfirmEmailChange 后才生效
+func (s *UserService) Update(ctx context.Context, id uint, req *UpdateUserRequest) (*UpdateUserResult, error) {
+	if req.Username != "" || req.ID != 0 {
+		return nil, errors.New(errors.ErrInvalidParams, "username and id are immutable")
+	}
+
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return errors.New(errors.ErrRecordNotFound, "user not found")
+			return nil, errors.New(errors.ErrRecordNotFound, "user not found")
 		}
-		return errors.Wrap(errors.ErrDatabase, err)
+		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	if req.Nickname != "" {
+	result := &UpdateUserResult{Changes: make(map[string]FieldChange)}
+	if req.Nickname != "" && req.Nickname != user.Nickname {
+		result.Changes["nickname"] = FieldChange{Old: user.Nickname, New: req.Nickname}
 		user.Nickname = req.Nickname
 	}
-	if req.Avatar != "" {
+	if req.Avatar != "" && req.Avatar != user.Avatar {
+		result.Changes["avatar"] = FieldChange{Old: user.Avatar, New: req.Avatar}
 		user.Avatar = req.Avatar
 	}
+	if req.Phone != nil {
+		phone := strings.TrimSpace(*req.Phone)
+		if phone != "" && !isPhoneNumber(phone) {
+			return nil, errors.New(errors.ErrInvalidParams, "invalid phone number")
+		}
+		if phone != user.Phone {
+			result.Changes["phone"] = FieldChange{Old: user.Phone, New: phone}
+			user.Phone = phone
+		}
+	}
+	if req.Profile != nil {
+		profile, changes, err := s.mergeProfile(user.Profile, req.Profile)
+		if err != nil {
+			return nil, err
+		}
+		for field, change := range changes {
+			result.Changes[field] = change
+		}
+		user.Profile = profile
+	}
 
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return errors.Wrap(errors.ErrDatabase, err)
+	if req.Email != "" && !strings.EqualFold(req.Email, user.Email) {
+		result.EmailChange, err = s.requestEmailChange(ctx, user, req.Email)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	if len(result.Changes) > 0 {
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, errors.Wrap(errors.ErrDatabase, err)
+		}
+	}
+
+	return result, nil
 }
 
+// Delete 软删除用户
+// 开启级联时，用户行与其在所有域的角色分配在同一事务内删除；提交后撤销全部会话并清理权限缓存
 func (s *UserService) Delete(ctx context.Context, id uint) error {
-	if err := s.userRepo.Delete(ctx, id); err != nil {
+	if !s.cascadeDelete {
+		if err := s.userRepo.Delete(ctx, id); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.New(errors.ErrRecordNotFound, "user not found")
+			}
+			return errors.Wrap(errors.ErrDatabase, err)
+		}
+		return nil
+	}
+
+	var domains []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Model(&model.UserRole{}).Where("user_id = ?", id).Distinct().Pluck("domain", &domains).Error; err != nil {
+			return err
+		}
+		userRoleRepo := repository.NewUserRoleRepository(&database.Database{DB: tx})
+		for _, domain := range domains {
+			if err := userRoleRepo.RevokeAll(ctx, id, domain); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return errors.New(errors.ErrRecordNotFound, "user not found")
 		}
 		return errors.Wrap(errors.ErrDatabase, err)
 	}
+
+	// 以下清理在事务提交后执行，失败只记录日志：用户已删除，认证中间件与权限查询均会拒绝该用户
+	if err := s.userRepo.InvalidateCache(ctx, id); err != nil {
+		logger.Warn("failed to invalidate user cache", "user_id", id, "error", err)
+	}
+	if s.sessions != nil {
+		if _, err := s.sessions.RevokeAll(ctx, id); err != nil {
+			logger.Warn("failed to revoke sessions of deleted user", "user_id", id, "error", err)
+		}
+	}
+	for _, domain := range domains {
+		if err := cache.Del(ctx, fmt.Sprintf(cacheKeyUserPermissions, id, domain)); err != nil {
+			logger.Warn("failed to delete user permissions cache", "user_id", id, "domain", domain, "error", err)
+		}
+	}
+
 	return nil
 }
 
-func (s *UserService) toResponse(user *model.User) *UserResponse {
-	return &UserResponse{
+// RestoreUser 恢复已软删除的用户
+// 删除时撤销的角色不会自动恢复，需要重新分配
+func (s *UserService) RestoreUser(ctx context.Context, id uint) (*UserResponse, error) {
+	result := s.db.WithContext(ctx).Unscoped().Model(&model.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New(errors.ErrRecordNotFound, "deleted user not found")
+	}
+
+	if err := s.userRepo.InvalidateCache(ctx, id); err != nil {
+		logger.Warn("failed to invalidate user cache", "user_id", id, "error", err)
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+// GetDefaultDomain 获取用户的默认域（经用户缓存读取），未设置时返回空字符串
+func (s *UserService) GetDefaultDomain(ctx context.Context, id uint) (string, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return user.DefaultDomain, nil
+}
+
+// SetDefaultDomain 设置用户的默认域，domain 为空时清除
+// 只能设置为用户拥有角色的域
+func (s *UserService) SetDefaultDomain(ctx context.Context, id uint, domain string) (*UserResponse, error) {
+	domain = strings.TrimSpace(domain)
+	if domain != "" {
+		var count int64
+		err := s.db.WithContext(ctx).Model(&model.UserRole{}).
+			Scopes(repository.ActiveUserRoles(time.Now())).
+			Where("user_id = ? AND domain = ?", id, domain).
+			Count(&count).Error
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrDatabase, err)
+		}
+		if count == 0 {
+			return nil, errors.New(errors.ErrInvalidParams, "user has no role in domain: "+domain)
+		}
+	}
+
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Update("default_domain", domain)
+	if result.Error != nil {
+		return nil, errors.Wrap(errors.ErrDatabase, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New(errors.ErrRecordNotFound, "user not found")
+	}
+
+	if err := s.userRepo.InvalidateCache(ctx, id); err != nil {
+		logger.Warn("failed to invalidate user cache", "user_id", id, "error", err)
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+// fillDefaultAvatar 用户未设置头像时生成默认头像（失败不影响创建用户）
+func (s *UserService) fillDefaultAvatar(ctx context.Context, user *model.User) {
+	if s.avatarService == nil || user.Avatar != "" {
+		return
+	}
+	if url, err := s.avatarService.Generate(ctx, user.Username); err == nil {
+		user.Avatar = url
+	}
+}
+
+// toResponse 转换为用户响应，头像按存储解析为可访问的地址
+func (s *UserService) toResponse(ctx context.Context, user *model.User) *UserResponse {
+	resp := &UserResponse{
 		ID:       user.ID,
 		Username: user.Username,
 		Email:    user.Email,
 		Nickname: user.Nickname,
-		Avatar:   user.Avatar,
+		Avatar:   s.avatarURLs.Resolve(ctx, user.Avatar),
+		Phone:    user.Phone,
 		Status:   user.Status,
+
+		DefaultDomain: user.DefaultDomain,
+	}
+	if user.Profile != "" {
+		resp.Profile = json.RawMessage(user.Profile)
 	}
+	return resp
+}
+
+// isPhoneNumber 校验电话号码：可选的 + 前缀，其余为数字、空格或连字符，至少包含 5 位数字
+func isPhoneNumber(phone string) bool {
+	digits := 0
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0, r == ' ', r == '-':
+		default:
+			return false
+		}
+	}
+	return digits >= 5
 }
 
 func hashPassword(password string) string {
@@ -154,6 +506,14 @@ func hashPassword(password string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// checkPassword 校验密码，兼容 bcrypt 与旧的 md5 哈希
+func checkPassword(hashed, password string) bool {
+	if strings.HasPrefix(hashed, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+	}
+	return hashed == hashPassword(password)
+}
+
 func (s *UserService) Register(ctx context.Context, req *CreateUserRequest) (*auth.TokenPair, error) {
 	exists, err := s.userRepo.ExistsByUsername(ctx, req.Username)
 	if err != nil {
@@ -178,12 +538,49 @@ func (s *UserService) Register(ctx context.Context, req *CreateUserRequest) (*au
 		Nickname: req.Nickname,
 		Status:   1,
 	}
+	s.fillDefaultAvatar(ctx, user)
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	if len(s.defaultRoles) == 0 {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return nil, errors.Wrap(errors.ErrDatabase, err)
+		}
+		return s.jwtAuth.GenerateTokenPair(user.ID, user.Username, nil)
+	}
+
+	// 用户与默认角色在同一事务中写入，角色分配失败时不会留下无角色的用户
+	var roles []model.Role
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		roles, err = findDefaultRoles(tx, s.defaultRoles)
+		if err != nil {
+			return err
+		}
+
+		userRoles := make([]model.UserRole, 0, len(roles))
+		for _, role := range roles {
+			userRoles = append(userRoles, model.UserRole{
+				UserID: user.ID,
+				RoleID: role.ID,
+				Domain: "default",
+			})
+		}
+		return tx.Create(&userRoles).Error
+	})
+	if err != nil {
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	return s.jwtAuth.GenerateTokenPair(user.ID, user.Username)
+	logger.Info("default roles assigned on registration",
+		"user_id", user.ID,
+		"username", user.Username,
+		"roles", s.defaultRoles,
+		"domain", "default",
+	)
+
+	return s.jwtAuth.GenerateTokenPair(user.ID, user.Username, nil)
 }
 
 func (s *UserService) Login(ctx context.Context, username, password string) (*auth.TokenPair, error) {
@@ -195,7 +592,7 @@ func (s *UserService) Login(ctx context.Context, username, password string) (*au
 		return nil, errors.Wrap(errors.ErrDatabase, err)
 	}
 
-	if user.Password != hashPassword(password) {
+	if !checkPassword(user.Password, password) {
 		return nil, errors.New(errors.ErrUnauthorized, "invalid username or password")
 	}
 
@@ -203,7 +600,39 @@ func (s *UserService) Login(ctx context.Context, username, password string) (*au
 		return nil, errors.New(errors.ErrForbidden, "user is disabled")
 	}
 
-	return s.jwtAuth.GenerateTokenPair(user.ID, user.Username)
+	return s.jwtAuth.GenerateTokenPair(user.ID, user.Username, nil)
+}
+
+// FindActiveByEmail 根据邮箱查找启用状态的用户，不存在或已禁用时返回 nil
+func (s *UserService) FindActiveByEmail(ctx context.Context, email string) (*UserResponse, error) {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+	if user.Status != 1 {
+		return nil, nil
+	}
+	return s.toResponse(ctx, user), nil
+}
+
+// LoginByUserID 为已通过其他方式验证身份的用户签发令牌（如免密登录链接）
+func (s *UserService) LoginByUserID(ctx context.Context, userID uint) (*auth.TokenPair, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New(errors.ErrUnauthorized, "user not found")
+		}
+		return nil, errors.Wrap(errors.ErrDatabase, err)
+	}
+
+	if user.Status != 1 {
+		return nil, errors.New(errors.ErrForbidden, "user is disabled")
+	}
+
+	return s.jwtAuth.GenerateTokenPair(user.ID, user.Username, nil)
 }
 
 func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (string, error) {
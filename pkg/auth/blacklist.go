@@ -5,25 +5,49 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/logger"
 )
 
 const (
 	tokenBlacklistPrefix = "token:blacklist"
 )
 
+// 黑名单写入失败时的处理策略
+const (
+	BlacklistFailClosed = "closed" // 返回错误，登出失败，由客户端重试（默认）
+	BlacklistFailOpen   = "open"   // 记录日志后视为成功，令牌在过期前仍然有效
+)
+
 // TokenBlacklist Token 黑名单管理
 type TokenBlacklist struct {
-	jwtAuth *JWTAuth
+	jwtAuth  *JWTAuth
+	store    BlacklistStore
+	failOpen bool
 }
 
 // NewTokenBlacklist 创建 Token 黑名单管理器
-func NewTokenBlacklist(jwtAuth *JWTAuth) *TokenBlacklist {
+// store 为 nil 时使用 Redis 存储；failureMode 为 BlacklistFailOpen 时写入失败仅记录日志
+func NewTokenBlacklist(jwtAuth *JWTAuth, store BlacklistStore, failureMode string) *TokenBlacklist {
+	if store == nil {
+		store = NewRedisBlacklistStore()
+	}
 	return &TokenBlacklist{
-		jwtAuth: jwtAuth,
+		jwtAuth:  jwtAuth,
+		store:    store,
+		failOpen: failureMode == BlacklistFailOpen,
 	}
 }
 
+// set 写入黑名单，按失败策略处理存储错误
+func (tb *TokenBlacklist) set(ctx context.Context, key string, ttl time.Duration) error {
+	err := tb.store.Set(ctx, key, ttl)
+	if err != nil && tb.failOpen {
+		logger.Warn("failed to write token blacklist, continuing (fail-open)", "error", err)
+		return nil
+	}
+	return err
+}
+
 // AddToBlacklist 将 token 加入黑名单
 func (tb *TokenBlacklist) AddToBlacklist(ctx context.Context, token string) error {
 	// 解析 token 获取过期时间
@@ -50,43 +74,50 @@ func (tb *TokenBlacklist) AddToBlacklist(ctx context.Context, token string) erro
 
 	// 加入黑名单
 	key := fmt.Sprintf("%s:%s", tokenBlacklistPrefix, token)
-	return cache.Set(ctx, key, "1", ttl)
+	return tb.set(ctx, key, ttl)
 }
 
 // IsInBlacklist 检查 token 是否在黑名单中
 func (tb *TokenBlacklist) IsInBlacklist(ctx context.Context, token string) (bool, error) {
 	key := fmt.Sprintf("%s:%s", tokenBlacklistPrefix, token)
-	count, err := cache.Exists(ctx, key)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+	return tb.store.Exists(ctx, key)
 }
 
 // RemoveFromBlacklist 从黑名单中移除 token（管理功能）
 func (tb *TokenBlacklist) RemoveFromBlacklist(ctx context.Context, token string) error {
 	key := fmt.Sprintf("%s:%s", tokenBlacklistPrefix, token)
-	return cache.Del(ctx, key)
+	return tb.store.Delete(ctx, key)
 }
 
 // AddUserToBlacklist 将用户的所有 token 加入黑名单（强制下线）
 func (tb *TokenBlacklist) AddUserToBlacklist(ctx context.Context, userID uint, duration time.Duration) error {
 	key := fmt.Sprintf("%s:user:%d", tokenBlacklistPrefix, userID)
-	return cache.Set(ctx, key, "1", duration)
+	return tb.set(ctx, key, duration)
 }
 
 // IsUserInBlacklist 检查用户是否被强制下线
 func (tb *TokenBlacklist) IsUserInBlacklist(ctx context.Context, userID uint) (bool, error) {
 	key := fmt.Sprintf("%s:user:%d", tokenBlacklistPrefix, userID)
-	count, err := cache.Exists(ctx, key)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+	return tb.store.Exists(ctx, key)
 }
 
 // RemoveUserFromBlacklist 解除用户强制下线
 func (tb *TokenBlacklist) RemoveUserFromBlacklist(ctx context.Context, userID uint) error {
 	key := fmt.Sprintf("%s:user:%d", tokenBlacklistPrefix, userID)
-	return cache.Del(ctx, key)
+	return tb.store.Delete(ctx, key)
+}
+
+// AddSessionToBlacklist 将会话（JTI）加入黑名单，该会话签发的所有令牌失效
+func (tb *TokenBlacklist) AddSessionToBlacklist(ctx context.Context, sessionID string, duration time.Duration) error {
+	key := fmt.Sprintf("%s:session:%s", tokenBlacklistPrefix, sessionID)
+	return tb.set(ctx, key, duration)
+}
+
+// IsSessionInBlacklist 检查会话是否已被撤销
+func (tb *TokenBlacklist) IsSessionInBlacklist(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	key := fmt.Sprintf("%s:session:%s", tokenBlacklistPrefix, sessionID)
+	return tb.store.Exists(ctx, key)
 }
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 黑名单存储类型
+const (
+	BlacklistStoreRedis    = "redis"    // Redis（默认），依赖键过期自动清理
+	BlacklistStoreDatabase = "database" // 数据库表，适用于未部署 Redis 的环境，需定期清理过期记录
+)
+
+// BlacklistStore 黑名单存储后端
+type BlacklistStore interface {
+	// Set 写入黑名单条目，ttl 到期后失效
+	Set(ctx context.Context, key string, ttl time.Duration) error
+	// Exists 检查条目是否存在且未过期
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除条目
+	Delete(ctx context.Context, key string) error
+}
+
+// redisBlacklistStore 基于 Redis 的黑名单存储
+type redisBlacklistStore struct{}
+
+// NewRedisBlacklistStore 创建基于 Redis 的黑名单存储
+func NewRedisBlacklistStore() BlacklistStore {
+	return redisBlacklistStore{}
+}
+
+func (redisBlacklistStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	return cache.Set(ctx, key, "1", ttl)
+}
+
+func (redisBlacklistStore) Exists(ctx context.Context, key string) (bool, error) {
+	count, err := cache.Exists(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (redisBlacklistStore) Delete(ctx context.Context, key string) error {
+	return cache.Del(ctx, key)
+}
+
+// BlacklistEntry 数据库黑名单条目
+// 键可能包含完整 token，以 SHA-256 摘要作为主键控制长度
+type BlacklistEntry struct {
+	KeyHash   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// TableName 指定表名
+func (BlacklistEntry) TableName() string {
+	return "token_blacklist"
+}
+
+// DBBlacklistStore 基于数据库的黑名单存储
+// 过期记录不会自动删除，查询时按 expires_at 过滤，并由 CleanupExpired 定期清理
+type DBBlacklistStore struct {
+	db *gorm.DB
+}
+
+// NewDBBlacklistStore 创建基于数据库的黑名单存储
+func NewDBBlacklistStore(db *gorm.DB) *DBBlacklistStore {
+	return &DBBlacklistStore{db: db}
+}
+
+// Set 写入条目，已存在时刷新过期时间
+func (s *DBBlacklistStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	entry := BlacklistEntry{
+		KeyHash:   hashToken(key),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&entry).Error
+}
+
+// Exists 检查条目是否存在且未过期
+func (s *DBBlacklistStore) Exists(ctx context.Context, key string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&BlacklistEntry{}).
+		Where("key_hash = ? AND expires_at > ?", hashToken(key), time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Delete 删除条目
+func (s *DBBlacklistStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).
+		Where("key_hash = ?", hashToken(key)).
+		Delete(&BlacklistEntry{}).Error
+}
+
+// CleanupExpired 删除已过期的条目，返回删除数量
+func (s *DBBlacklistStore) CleanupExpired(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("expires_at <= ?", time.Now()).
+		Delete(&BlacklistEntry{})
+	return result.RowsAffected, result.Error
+}
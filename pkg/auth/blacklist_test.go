@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/testutil"
+)
+
+func TestTokenBlacklist_RedisUnavailable(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{BlacklistFailClosed, true},
+		{BlacklistFailOpen, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			mr := testutil.NewRedis(t)
+			jwtAuth := newTestJWTAuth()
+			blacklist := NewTokenBlacklist(jwtAuth, nil, tt.mode)
+			pair, err := jwtAuth.GenerateTokenPair(1, "alice", nil)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+
+			mr.Close() // Redis 不可用
+			ctx := context.Background()
+			writes := map[string]error{
+				"token":   blacklist.AddToBlacklist(ctx, pair.AccessToken),
+				"session": blacklist.AddSessionToBlacklist(ctx, pair.SessionID, time.Hour),
+				"user":    blacklist.AddUserToBlacklist(ctx, 1, time.Hour),
+			}
+			for name, err := range writes {
+				if tt.wantErr && err == nil {
+					t.Errorf("%s: write succeeded with Redis down, want error (fail-closed)", name)
+				}
+				if !tt.wantErr && err != nil {
+					t.Errorf("%s: %v, want nil (fail-open)", name, err)
+				}
+			}
+
+			// 读取失败在两种策略下都返回错误，由调用方决定是否放行
+			if _, err := blacklist.IsSessionInBlacklist(ctx, pair.SessionID); err == nil {
+				t.Error("IsSessionInBlacklist succeeded with Redis down, want error")
+			}
+		})
+	}
+}
+
+func TestTokenBlacklist_Redis(t *testing.T) {
+	mr := testutil.NewRedis(t)
+	jwtAuth := newTestJWTAuth()
+	blacklist := NewTokenBlacklist(jwtAuth, nil, BlacklistFailClosed)
+	ctx := context.Background()
+
+	pair, err := jwtAuth.GenerateTokenPair(1, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if err := blacklist.AddToBlacklist(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("AddToBlacklist: %v", err)
+	}
+	if ok, err := blacklist.IsInBlacklist(ctx, pair.AccessToken); err != nil || !ok {
+		t.Fatalf("IsInBlacklist = %v, %v, want true", ok, err)
+	}
+
+	// 黑名单条目随令牌剩余有效期过期
+	mr.FastForward(time.Hour + time.Second)
+	if ok, err := blacklist.IsInBlacklist(ctx, pair.AccessToken); err != nil || ok {
+		t.Fatalf("IsInBlacklist after expiry = %v, %v, want false", ok, err)
+	}
+}
+
+// newTestDBStore 创建基于临时 SQLite 文件的数据库黑名单存储
+func newTestDBStore(t *testing.T) *DBBlacklistStore {
+	t.Helper()
+
+	return NewDBBlacklistStore(testutil.NewDB(t, &BlacklistEntry{}))
+}
+
+func TestDBBlacklistStore(t *testing.T) {
+	store := newTestDBStore(t)
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "token:blacklist:a", time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set(ctx, "token:blacklist:expired", -time.Second); err != nil {
+		t.Fatalf("Set expired: %v", err)
+	}
+
+	if ok, err := store.Exists(ctx, "token:blacklist:a"); err != nil || !ok {
+		t.Fatalf("Exists(a) = %v, %v, want true", ok, err)
+	}
+	if ok, err := store.Exists(ctx, "token:blacklist:expired"); err != nil || ok {
+		t.Fatalf("Exists(expired) = %v, %v, want false", ok, err)
+	}
+	if ok, err := store.Exists(ctx, "token:blacklist:missing"); err != nil || ok {
+		t.Fatalf("Exists(missing) = %v, %v, want false", ok, err)
+	}
+
+	// 重复写入刷新过期时间
+	if err := store.Set(ctx, "token:blacklist:expired", time.Hour); err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+	if ok, _ := store.Exists(ctx, "token:blacklist:expired"); !ok {
+		t.Fatal("re-set entry does not exist, want expiry refreshed")
+	}
+
+	if err := store.Delete(ctx, "token:blacklist:a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := store.Exists(ctx, "token:blacklist:a"); ok {
+		t.Fatal("deleted entry still exists")
+	}
+}
+
+func TestDBBlacklistStore_CleanupExpired(t *testing.T) {
+	store := newTestDBStore(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.Set(ctx, key, -time.Minute); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	if err := store.Set(ctx, "live", time.Hour); err != nil {
+		t.Fatalf("Set(live): %v", err)
+	}
+
+	n, err := store.CleanupExpired(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("CleanupExpired = %d, %v, want 3", n, err)
+	}
+	var remaining int64
+	store.db.Model(&BlacklistEntry{}).Count(&remaining)
+	if remaining != 1 {
+		t.Fatalf("%d entries remain, want only the live one", remaining)
+	}
+}
+
+func TestTokenBlacklist_DatabaseStore(t *testing.T) {
+	jwtAuth := newTestJWTAuth()
+	blacklist := NewTokenBlacklist(jwtAuth, newTestDBStore(t), BlacklistFailClosed)
+	ctx := context.Background()
+
+	// 数据库存储不依赖 Redis
+	pair, err := jwtAuth.GenerateTokenPair(1, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if err := blacklist.AddSessionToBlacklist(ctx, pair.SessionID, time.Hour); err != nil {
+		t.Fatalf("AddSessionToBlacklist: %v", err)
+	}
+	if ok, err := blacklist.IsSessionInBlacklist(ctx, pair.SessionID); err != nil || !ok {
+		t.Fatalf("IsSessionInBlacklist = %v, %v, want true", ok, err)
+	}
+	if err := blacklist.AddToBlacklist(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("AddToBlacklist: %v", err)
+	}
+	if ok, err := blacklist.IsInBlacklist(ctx, pair.AccessToken); err != nil || !ok {
+		t.Fatalf("IsInBlacklist = %v, %v, want true", ok, err)
+	}
+}
+
+// failingStore 写入总是失败的黑名单存储
+type failingStore struct{ BlacklistStore }
+
+var errStoreDown = errors.New("store unavailable")
+
+func (failingStore) Set(context.Context, string, time.Duration) error { return errStoreDown }
+
+func TestTokenBlacklist_FailureModeWrapsStoreError(t *testing.T) {
+	closed := NewTokenBlacklist(newTestJWTAuth(), failingStore{}, BlacklistFailClosed)
+	if err := closed.AddUserToBlacklist(context.Background(), 1, time.Hour); !errors.Is(err, errStoreDown) {
+		t.Fatalf("fail-closed err = %v, want store error", err)
+	}
+
+	// 未知取值按默认的 fail-closed 处理
+	unknown := NewTokenBlacklist(newTestJWTAuth(), failingStore{}, "")
+	if err := unknown.AddUserToBlacklist(context.Background(), 1, time.Hour); !errors.Is(err, errStoreDown) {
+		t.Fatalf("empty mode err = %v, want store error", err)
+	}
+
+	open := NewTokenBlacklist(newTestJWTAuth(), failingStore{}, BlacklistFailOpen)
+	if err := open.AddUserToBlacklist(context.Background(), 1, time.Hour); err != nil {
+		t.Fatalf("fail-open err = %v, want nil", err)
+	}
+}
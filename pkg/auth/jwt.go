@@ -1,18 +1,26 @@
 package auth
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token expired")
 	ErrTokenClaims  = errors.New("invalid token claims")
+
+	ErrExtraClaimsTooLarge = errors.New("extra claims too large")
 )
 
+// MaxExtraClaimsSize 自定义声明序列化后的最大字节数
+// 令牌随每个请求发送，声明过大会显著增加请求头体积（部分代理限制请求头为 8KB）
+const MaxExtraClaimsSize = 1024
+
 type TokenType string
 
 const (
@@ -25,23 +33,53 @@ type Config struct {
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
 	Issuer               string
+
+	// Keys 带ID的签名密钥集合，用于不停机轮换密钥；为空时只使用 SecretKey
+	// 第一个启用的密钥用于签名（令牌头部写入 kid），所有启用的密钥都可用于验证；
+	// 配置了 Keys 时 SecretKey 只用于验证不带 kid 的旧令牌
+	Keys []SigningKey
+}
+
+// SigningKey JWT 签名密钥
+type SigningKey struct {
+	ID     string // 密钥ID，写入令牌头部的 kid
+	Secret string // HMAC 密钥
+	Active bool   // 是否启用：未启用的密钥签发的令牌验证失败（密钥泄露或轮换完成后停用）
 }
 
 type Claims struct {
 	UserID   uint      `json:"user_id"`
 	Username string    `json:"username"`
 	Type     TokenType `json:"type"`
+	// Extra 自定义声明（如租户、scope），统一放在 ext 下，避免与标准声明冲突。
+	// JWT 仅签名不加密，任何持有令牌的人都能读取，不要放入密钥、密码等敏感信息
+	Extra map[string]any `json:"ext,omitempty"`
+	// Family 令牌族ID：首次登录时等于会话ID，轮换刷新令牌后新令牌沿用，用于检测已轮换令牌的重放
+	Family string `json:"fam,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// FamilyID 令牌族ID，未携带 fam 声明的旧令牌以会话ID作为族ID
+func (c *Claims) FamilyID() string {
+	if c.Family != "" {
+		return c.Family
+	}
+	return c.ID
+}
+
 type TokenPair struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`  // Cookie 模式下不在响应体中返回
+	RefreshToken string `json:"refresh_token,omitempty"` // Cookie 模式下不在响应体中返回
 	ExpiresIn    int64  `json:"expires_in"`
+	SessionID    string `json:"session_id"` // 会话ID（JTI），同一次登录签发的令牌共享
 }
 
 type JWTAuth struct {
 	config *Config
+
+	signingKID    string            // 签名使用的密钥ID，为空时使用 SecretKey 且不写入 kid
+	signingSecret []byte            // 签名使用的密钥
+	verifyKeys    map[string][]byte // 启用的密钥：kid -> 密钥
 }
 
 func NewJWTAuth(config *Config) *JWTAuth {
@@ -54,16 +92,65 @@ func NewJWTAuth(config *Config) *JWTAuth {
 	if config.Issuer == "" {
 		config.Issuer = "nova"
 	}
-	return &JWTAuth{config: config}
+
+	j := &JWTAuth{
+		config:        config,
+		signingSecret: []byte(config.SecretKey),
+		verifyKeys:    make(map[string][]byte, len(config.Keys)),
+	}
+	for _, key := range config.Keys {
+		if !key.Active {
+			continue
+		}
+		if j.signingKID == "" {
+			j.signingKID = key.ID
+			j.signingSecret = []byte(key.Secret)
+		}
+		j.verifyKeys[key.ID] = []byte(key.Secret)
+	}
+	return j
+}
+
+// SigningKeyID 当前签名使用的密钥ID，未配置密钥集合时为空
+func (j *JWTAuth) SigningKeyID() string {
+	return j.signingKID
+}
+
+// GenerateTokenPair 签发访问令牌与刷新令牌
+// extra 为可选的自定义声明，序列化后不能超过 MaxExtraClaimsSize；刷新令牌同样携带，刷新后的访问令牌沿用
+func (j *JWTAuth) GenerateTokenPair(userID uint, username string, extra map[string]any) (*TokenPair, error) {
+	if len(extra) > 0 {
+		data, err := json.Marshal(extra)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxExtraClaimsSize {
+			return nil, ErrExtraClaimsTooLarge
+		}
+	}
+
+	return j.generatePair(userID, username, extra, "")
 }
 
-func (j *JWTAuth) GenerateTokenPair(userID uint, username string) (*TokenPair, error) {
-	accessToken, err := j.generateToken(userID, username, AccessToken, j.config.AccessTokenDuration)
+// RotateTokenPair 以刷新令牌的声明签发新的令牌对（刷新令牌轮换）
+// 新令牌使用新的会话ID，沿用用户、自定义声明与令牌族ID
+func (j *JWTAuth) RotateTokenPair(claims *Claims) (*TokenPair, error) {
+	return j.generatePair(claims.UserID, claims.Username, claims.Extra, claims.FamilyID())
+}
+
+// generatePair 签发共享会话ID的访问令牌与刷新令牌，family 为空时以新会话ID作为族ID
+func (j *JWTAuth) generatePair(userID uint, username string, extra map[string]any, family string) (*TokenPair, error) {
+	sessionID := uuid.New().String()
+	if family == "" {
+		family = sessionID
+	}
+
+	accessToken, err := j.generateToken(userID, username, sessionID, family, extra, AccessToken, j.config.AccessTokenDuration)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := j.generateToken(userID, username, RefreshToken, j.config.RefreshTokenDuration)
+	refreshToken, err := j.generateToken(userID, username, sessionID, family, extra, RefreshToken, j.config.RefreshTokenDuration)
 	if err != nil {
 		return nil, err
 	}
@@ -72,16 +159,25 @@ func (j *JWTAuth) GenerateTokenPair(userID uint, username string) (*TokenPair, e
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresIn:    int64(j.config.AccessTokenDuration.Seconds()),
+		SessionID:    sessionID,
 	}, nil
 }
 
-func (j *JWTAuth) generateToken(userID uint, username string, tokenType TokenType, duration time.Duration) (string, error) {
+// RefreshTokenDuration 刷新令牌有效期（即会话最长有效期）
+func (j *JWTAuth) RefreshTokenDuration() time.Duration {
+	return j.config.RefreshTokenDuration
+}
+
+func (j *JWTAuth) generateToken(userID uint, username, sessionID, family string, extra map[string]any, tokenType TokenType, duration time.Duration) (string, error) {
 	now := time.Now()
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
 		Type:     tokenType,
+		Extra:    extra,
+		Family:   family,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
 			Issuer:    j.config.Issuer,
 			Subject:   username,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -91,16 +187,36 @@ func (j *JWTAuth) generateToken(userID uint, username string, tokenType TokenTyp
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.config.SecretKey))
+	if j.signingKID != "" {
+		token.Header["kid"] = j.signingKID
+	}
+	return token.SignedString(j.signingSecret)
 }
 
-func (j *JWTAuth) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// verifyKey 按令牌头部的 kid 选择验证密钥
+// 不带 kid 的令牌（启用密钥集合前签发）使用 SecretKey 验证；kid 未知或对应的密钥已停用时拒绝
+func (j *JWTAuth) verifyKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, ErrInvalidToken
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if j.config.SecretKey == "" {
 			return nil, ErrInvalidToken
 		}
 		return []byte(j.config.SecretKey), nil
-	})
+	}
+
+	key, ok := j.verifyKeys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+func (j *JWTAuth) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.verifyKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -127,7 +243,8 @@ func (j *JWTAuth) RefreshAccessToken(refreshTokenString string) (string, error)
 		return "", ErrInvalidToken
 	}
 
-	return j.generateToken(claims.UserID, claims.Username, AccessToken, j.config.AccessTokenDuration)
+	// 刷新后的访问令牌沿用原会话ID与自定义声明
+	return j.generateToken(claims.UserID, claims.Username, claims.ID, claims.Family, claims.Extra, AccessToken, j.config.AccessTokenDuration)
 }
 
 func (j *JWTAuth) GetUserIDFromToken(tokenString string) (uint, error) {
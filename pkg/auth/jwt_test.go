@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenKID 读取令牌头部的 kid（不验证签名）
+func tokenKID(t *testing.T, tokenString string) string {
+	t.Helper()
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestValidateToken_KeyRotation(t *testing.T) {
+	before := NewJWTAuth(&Config{AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v1", Secret: "secret-v1", Active: true},
+	}})
+	oldPair, err := before.GenerateTokenPair(7, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if kid := tokenKID(t, oldPair.AccessToken); kid != "v1" {
+		t.Fatalf("kid = %q, want v1", kid)
+	}
+
+	// 轮换：新密钥放在首位负责签名，旧密钥保留用于验证
+	after := NewJWTAuth(&Config{AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v2", Secret: "secret-v2", Active: true},
+		{ID: "v1", Secret: "secret-v1", Active: true},
+	}})
+	if after.SigningKeyID() != "v2" {
+		t.Fatalf("SigningKeyID = %q, want v2", after.SigningKeyID())
+	}
+
+	claims, err := after.ValidateToken(oldPair.AccessToken)
+	if err != nil {
+		t.Fatalf("token signed by non-primary key: %v", err)
+	}
+	if claims.UserID != 7 {
+		t.Fatalf("UserID = %d, want 7", claims.UserID)
+	}
+
+	// 旧刷新令牌换取的访问令牌使用新密钥签名
+	access, err := after.RefreshAccessToken(oldPair.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+	if kid := tokenKID(t, access); kid != "v2" {
+		t.Fatalf("refreshed token kid = %q, want v2", kid)
+	}
+	if _, err := after.ValidateToken(access); err != nil {
+		t.Fatalf("ValidateToken(refreshed): %v", err)
+	}
+	// 轮换前的实例不认识新密钥
+	if _, err := before.ValidateToken(access); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unknown kid err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateToken_InactiveKeyRejected(t *testing.T) {
+	before := NewJWTAuth(&Config{AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v1", Secret: "secret-v1", Active: true},
+	}})
+	pair, err := before.GenerateTokenPair(7, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	after := NewJWTAuth(&Config{AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v2", Secret: "secret-v2", Active: true},
+		{ID: "v1", Secret: "secret-v1", Active: false},
+	}})
+	if _, err := after.ValidateToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of deactivated key err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateToken_LegacySecretWithoutKID(t *testing.T) {
+	legacy := NewJWTAuth(&Config{SecretKey: "legacy", AccessTokenDuration: time.Hour})
+	pair, err := legacy.GenerateTokenPair(7, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if kid := tokenKID(t, pair.AccessToken); kid != "" {
+		t.Fatalf("legacy token kid = %q, want none", kid)
+	}
+
+	// 启用密钥集合后，不带 kid 的旧令牌仍按 jwt_secret 验证
+	keyed := NewJWTAuth(&Config{SecretKey: "legacy", AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v1", Secret: "secret-v1", Active: true},
+	}})
+	if _, err := keyed.ValidateToken(pair.AccessToken); err != nil {
+		t.Fatalf("legacy token: %v", err)
+	}
+
+	// 未配置 jwt_secret 时拒绝不带 kid 的令牌
+	keyOnly := NewJWTAuth(&Config{AccessTokenDuration: time.Hour, Keys: []SigningKey{
+		{ID: "v1", Secret: "secret-v1", Active: true},
+	}})
+	if _, err := keyOnly.ValidateToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token without kid err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+const loginThrottlePrefix = "auth:login_throttle"
+
+// LoginThrottle 按用户名的渐进式登录延迟
+// 每次登录失败后在响应前等待一段逐次递增的时间（base * multiplier^(n-1)，不超过 max），
+// 只放慢而不锁定账户；失败次数记录在 Redis 中，登录成功或超过统计窗口后清零
+type LoginThrottle struct {
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	multiplier float64
+	window     time.Duration
+}
+
+// NewLoginThrottle 创建登录延迟器
+func NewLoginThrottle(baseDelay, maxDelay time.Duration, multiplier float64, window time.Duration) *LoginThrottle {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	return &LoginThrottle{
+		baseDelay:  baseDelay,
+		maxDelay:   maxDelay,
+		multiplier: multiplier,
+		window:     window,
+	}
+}
+
+// Failure 记录一次登录失败并按累计失败次数等待
+// 等待期间请求被取消时立即返回上下文错误；Redis 不可用时不延迟
+func (t *LoginThrottle) Failure(ctx context.Context, username string) error {
+	key := t.buildKey(username)
+	count, err := cache.Incr(ctx, key)
+	if err != nil {
+		return nil
+	}
+	// 每次失败都刷新窗口，持续尝试的用户名不会因窗口到期而回到初始延迟
+	_ = cache.Expire(ctx, key, t.window)
+
+	delay := t.Delay(count)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Delay 第 failures 次失败对应的延迟
+func (t *LoginThrottle) Delay(failures int64) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := float64(t.baseDelay) * math.Pow(t.multiplier, float64(failures-1))
+	if delay > float64(t.maxDelay) {
+		return t.maxDelay
+	}
+	return time.Duration(delay)
+}
+
+// Reset 登录成功后清除失败计数
+func (t *LoginThrottle) Reset(ctx context.Context, username string) {
+	_ = cache.Del(ctx, t.buildKey(username))
+}
+
+// buildKey 用户名忽略大小写与首尾空白，以摘要作为键避免在 Redis 中保存明文用户名
+func (t *LoginThrottle) buildKey(username string) string {
+	return fmt.Sprintf("%s:%s", loginThrottlePrefix, hashToken(strings.ToLower(strings.TrimSpace(username))))
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	magicLinkPrefix     = "auth:magic_link"
+	magicLinkRatePrefix = "auth:magic_link:rate"
+)
+
+// MagicLinkStore 免密登录链接令牌管理
+// 令牌只以 SHA-256 摘要作为 Redis 键保存，值为用户ID；校验时原子地读取并删除，保证单次有效
+type MagicLinkStore struct {
+	ttl        time.Duration
+	rateLimit  int
+	rateWindow time.Duration
+}
+
+// NewMagicLinkStore 创建免密登录链接管理器
+// ttl 为链接有效期；同一邮箱在 rateWindow 内最多申请 rateLimit 次
+func NewMagicLinkStore(ttl time.Duration, rateLimit int, rateWindow time.Duration) *MagicLinkStore {
+	return &MagicLinkStore{
+		ttl:        ttl,
+		rateLimit:  rateLimit,
+		rateWindow: rateWindow,
+	}
+}
+
+// TTL 链接有效期
+func (s *MagicLinkStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Allow 记录一次申请并判断该邮箱是否仍在频率限制内
+func (s *MagicLinkStore) Allow(ctx context.Context, email string) (bool, error) {
+	key := fmt.Sprintf("%s:%s", magicLinkRatePrefix, hashToken(strings.ToLower(strings.TrimSpace(email))))
+	count, err := cache.Incr(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		_ = cache.Expire(ctx, key, s.rateWindow)
+	}
+	return count <= int64(s.rateLimit), nil
+}
+
+// Issue 为用户生成一次性登录令牌
+func (s *MagicLinkStore) Issue(ctx context.Context, userID uint) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	key := fmt.Sprintf("%s:%s", magicLinkPrefix, hashToken(token))
+	if err := cache.Set(ctx, key, userID, s.ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume 校验并作废令牌，返回对应的用户ID；令牌不存在、已过期或已使用时 ok=false
+func (s *MagicLinkStore) Consume(ctx context.Context, token string) (userID uint, ok bool, err error) {
+	if token == "" {
+		return 0, false, nil
+	}
+
+	key := fmt.Sprintf("%s:%s", magicLinkPrefix, hashToken(token))
+	val, err := cache.GetDel(ctx, key)
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	id, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, false, nil
+	}
+	return uint(id), true, nil
+}
+
+// hashToken 计算令牌的 SHA-256 摘要（十六进制），避免在存储中保留令牌原文
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
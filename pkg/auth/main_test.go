@@ -0,0 +1,17 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// newTestJWTAuth 创建测试用的 JWT 签发器
+func newTestJWTAuth() *JWTAuth {
+	return NewJWTAuth(&Config{SecretKey: "test-secret", AccessTokenDuration: time.Hour})
+}
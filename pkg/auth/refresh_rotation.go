@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshRotatedPrefix = "auth:refresh:rotated" // 已轮换的会话ID -> 轮换时间戳
+	refreshFamilyPrefix  = "auth:refresh:family"  // 令牌族ID -> 族内全部会话ID（Set）
+	refreshRevokedPrefix = "auth:refresh:revoked" // 已因重放被撤销的令牌族
+)
+
+var (
+	// ErrRefreshTokenReused 已轮换的刷新令牌被再次使用，整个令牌族已撤销，客户端需重新登录
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrRefreshTokenRotated 刷新令牌刚被轮换（宽限期内的重复提交），拒绝但不撤销令牌族
+	ErrRefreshTokenRotated = errors.New("refresh token already rotated")
+	// ErrSessionRevoked 刷新令牌所属会话已被撤销（登出、强制下线）
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// RefreshRotator 刷新令牌轮换与重放检测
+// 每次刷新都签发新的令牌对并作废旧会话；已轮换的刷新令牌再次出现视为令牌被盗，
+// 撤销同一令牌族（由同一次登录轮换派生的全部会话）并记录安全告警。
+// grace 内的重复提交（如客户端并发刷新）只拒绝，不撤销令牌族
+type RefreshRotator struct {
+	jwtAuth   *JWTAuth
+	blacklist *TokenBlacklist
+	sessions  *SessionManager
+	grace     time.Duration
+}
+
+// NewRefreshRotator 创建刷新令牌轮换器
+func NewRefreshRotator(jwtAuth *JWTAuth, blacklist *TokenBlacklist, sessions *SessionManager, grace time.Duration) *RefreshRotator {
+	return &RefreshRotator{
+		jwtAuth:   jwtAuth,
+		blacklist: blacklist,
+		sessions:  sessions,
+		grace:     grace,
+	}
+}
+
+// Rotate 校验刷新令牌并签发新的令牌对，旧会话随即失效
+// 新会话的设备信息由调用方记录（SessionManager.Record）
+func (r *RefreshRotator) Rotate(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := r.jwtAuth.ValidateToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != RefreshToken {
+		return nil, ErrInvalidToken
+	}
+	family := claims.FamilyID()
+
+	revoked, err := cache.Exists(ctx, r.revokedKey(family))
+	if err != nil {
+		return nil, err
+	}
+	if revoked > 0 {
+		return nil, ErrRefreshTokenReused
+	}
+
+	// 先检查是否为已轮换的令牌：轮换后的旧会话同样在黑名单中，需与普通撤销区分
+	rotatedAt, err := cache.Get(ctx, r.rotatedKey(claims.ID))
+	if err == nil {
+		return nil, r.reused(ctx, claims, rotatedAt)
+	}
+	if err != redis.Nil {
+		return nil, err
+	}
+
+	inBlacklist, err := r.blacklist.IsSessionInBlacklist(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inBlacklist {
+		return nil, ErrSessionRevoked
+	}
+
+	// 原子地标记为已轮换，并发提交同一令牌时只有一个请求成功
+	ttl := time.Until(claims.ExpiresAt.Time)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	ok, err := cache.SetNX(ctx, r.rotatedKey(claims.ID), now, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, r.reused(ctx, claims, now)
+	}
+
+	pair, err := r.jwtAuth.RotateTokenPair(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	familyKey := r.familyKey(family)
+	if err := cache.SAdd(ctx, familyKey, claims.ID, pair.SessionID); err != nil {
+		return nil, err
+	}
+	_ = cache.Expire(ctx, familyKey, r.jwtAuth.RefreshTokenDuration())
+
+	// 旧会话失效（含其访问令牌），从会话列表中移除
+	if err := r.blacklist.AddSessionToBlacklist(ctx, claims.ID, ttl); err != nil {
+		return nil, err
+	}
+	_ = r.sessions.Remove(ctx, claims.UserID, claims.ID)
+
+	return pair, nil
+}
+
+// reused 处理已轮换令牌的再次使用：宽限期内只拒绝，否则撤销整个令牌族
+func (r *RefreshRotator) reused(ctx context.Context, claims *Claims, rotatedAt string) error {
+	if ts, err := strconv.ParseInt(rotatedAt, 10, 64); err == nil && time.Since(time.Unix(ts, 0)) < r.grace {
+		return ErrRefreshTokenRotated
+	}
+
+	family := claims.FamilyID()
+	count, err := r.RevokeFamily(ctx, claims.UserID, family)
+	logger.Warn("security alert: refresh token reuse detected, token family revoked",
+		"user_id", claims.UserID,
+		"session_id", claims.ID,
+		"family", family,
+		"revoked_sessions", count,
+		"error", err,
+	)
+	return ErrRefreshTokenReused
+}
+
+// RevokeFamily 撤销令牌族内的全部会话，返回撤销数量
+// 令牌族同时被标记为已撤销，族内尚未记录的令牌（如正在轮换中签发的）也无法再刷新
+func (r *RefreshRotator) RevokeFamily(ctx context.Context, userID uint, family string) (int, error) {
+	duration := r.jwtAuth.RefreshTokenDuration()
+	if err := cache.Set(ctx, r.revokedKey(family), 1, duration); err != nil {
+		return 0, err
+	}
+
+	sessionIDs, err := cache.SMembers(ctx, r.familyKey(family))
+	if err != nil {
+		return 0, err
+	}
+	if len(sessionIDs) == 0 {
+		// 尚未轮换过的令牌族只有首个会话，其ID即族ID
+		sessionIDs = []string{family}
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := r.blacklist.AddSessionToBlacklist(ctx, sessionID, duration); err != nil {
+			return 0, err
+		}
+		_ = r.sessions.Remove(ctx, userID, sessionID)
+	}
+	return len(sessionIDs), nil
+}
+
+func (r *RefreshRotator) rotatedKey(sessionID string) string {
+	return fmt.Sprintf("%s:%s", refreshRotatedPrefix, sessionID)
+}
+
+func (r *RefreshRotator) familyKey(family string) string {
+	return fmt.Sprintf("%s:%s", refreshFamilyPrefix, family)
+}
+
+func (r *RefreshRotator) revokedKey(family string) string {
+	return fmt.Sprintf("%s:%s", refreshRevokedPrefix, family)
+}
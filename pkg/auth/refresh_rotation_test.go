@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+// rotationTestEnv 刷新令牌轮换测试环境
+type rotationTestEnv struct {
+	jwtAuth   *JWTAuth
+	blacklist *TokenBlacklist
+	sessions  *SessionManager
+	rotator   *RefreshRotator
+}
+
+func newRotationTestEnv(t *testing.T, grace time.Duration) *rotationTestEnv {
+	t.Helper()
+
+	testutil.NewRedis(t)
+	jwtAuth := newTestJWTAuth()
+	blacklist := NewTokenBlacklist(jwtAuth, nil, BlacklistFailClosed)
+	sessions := NewSessionManager(jwtAuth, blacklist)
+	return &rotationTestEnv{
+		jwtAuth:   jwtAuth,
+		blacklist: blacklist,
+		sessions:  sessions,
+		rotator:   NewRefreshRotator(jwtAuth, blacklist, sessions, grace),
+	}
+}
+
+// login 模拟登录：签发令牌对并记录会话
+func (env *rotationTestEnv) login(t *testing.T, userID uint) *TokenPair {
+	t.Helper()
+
+	pair, err := env.jwtAuth.GenerateTokenPair(userID, "alice", nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	env.record(t, userID, pair)
+	return pair
+}
+
+func (env *rotationTestEnv) record(t *testing.T, userID uint, pair *TokenPair) {
+	t.Helper()
+
+	if err := env.sessions.Record(context.Background(), userID, pair.SessionID, "test", "127.0.0.1"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+}
+
+// rotate 模拟客户端刷新，成功时记录新会话
+func (env *rotationTestEnv) rotate(t *testing.T, userID uint, pair *TokenPair) *TokenPair {
+	t.Helper()
+
+	next, err := env.rotator.Rotate(context.Background(), pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	env.record(t, userID, next)
+	return next
+}
+
+// revoked 判断会话是否已被撤销
+func (env *rotationTestEnv) revoked(t *testing.T, sessionID string) bool {
+	t.Helper()
+
+	ok, err := env.blacklist.IsSessionInBlacklist(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("IsSessionInBlacklist: %v", err)
+	}
+	return ok
+}
+
+func TestRotate_IssuesNewPairInSameFamily(t *testing.T) {
+	env := newRotationTestEnv(t, 0)
+	first := env.login(t, 1)
+	second := env.rotate(t, 1, first)
+
+	if second.SessionID == first.SessionID {
+		t.Fatal("rotated pair reuses the session ID")
+	}
+	claims, err := env.jwtAuth.ValidateToken(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.FamilyID() != first.SessionID {
+		t.Fatalf("family = %s, want first session %s", claims.FamilyID(), first.SessionID)
+	}
+	// 旧会话随轮换失效，新会话有效
+	if !env.revoked(t, first.SessionID) || env.revoked(t, second.SessionID) {
+		t.Fatal("want old session revoked and new session active")
+	}
+}
+
+func TestRotate_StolenTokenReplayRevokesFamily(t *testing.T) {
+	env := newRotationTestEnv(t, 0)
+	ctx := context.Background()
+
+	// 攻击者窃取了首个刷新令牌，合法客户端随后继续轮换了两次
+	stolen := env.login(t, 1)
+	second := env.rotate(t, 1, stolen)
+	current := env.rotate(t, 1, second)
+	other := env.login(t, 1) // 同一用户在另一设备的独立登录
+
+	if _, err := env.rotator.Rotate(ctx, stolen.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// 整个令牌族被撤销：合法客户端的最新令牌也需要重新登录
+	for _, pair := range []*TokenPair{stolen, second, current} {
+		if !env.revoked(t, pair.SessionID) {
+			t.Fatalf("session %s in the family is still active", pair.SessionID)
+		}
+	}
+	if _, err := env.rotator.Rotate(ctx, current.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("rotate after revocation err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// 其他令牌族不受影响
+	if env.revoked(t, other.SessionID) {
+		t.Fatal("unrelated login was revoked")
+	}
+	active, err := env.sessions.ListActiveSessions(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListActiveSessions: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != other.SessionID {
+		t.Fatalf("active sessions = %+v, want only the unrelated login", active)
+	}
+	if _, err := env.rotator.Rotate(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("rotate unrelated login: %v", err)
+	}
+}
+
+func TestRotate_AttackerRotatesFirst(t *testing.T) {
+	env := newRotationTestEnv(t, 0)
+
+	// 攻击者先用窃取的令牌刷新，合法客户端随后提交同一令牌
+	original := env.login(t, 1)
+	attacker := env.rotate(t, 1, original)
+
+	if _, err := env.rotator.Rotate(context.Background(), original.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay err = %v, want ErrRefreshTokenReused", err)
+	}
+	if !env.revoked(t, attacker.SessionID) {
+		t.Fatal("attacker's rotated session is still active")
+	}
+}
+
+func TestRotate_GraceWindow(t *testing.T) {
+	env := newRotationTestEnv(t, 10*time.Second)
+	ctx := context.Background()
+
+	first := env.login(t, 1)
+	second := env.rotate(t, 1, first)
+
+	// 宽限期内的重复提交（如客户端并发刷新）只拒绝，不撤销令牌族
+	if _, err := env.rotator.Rotate(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenRotated) {
+		t.Fatalf("duplicate within grace err = %v, want ErrRefreshTokenRotated", err)
+	}
+	if env.revoked(t, second.SessionID) {
+		t.Fatal("duplicate within grace revoked the family")
+	}
+
+	// 超过宽限期后视为重放
+	old := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if err := cache.Set(ctx, env.rotator.rotatedKey(first.SessionID), old, time.Hour); err != nil {
+		t.Fatalf("set rotated time: %v", err)
+	}
+	if _, err := env.rotator.Rotate(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay after grace err = %v, want ErrRefreshTokenReused", err)
+	}
+	if !env.revoked(t, second.SessionID) {
+		t.Fatal("replay after grace did not revoke the family")
+	}
+}
+
+func TestRotate_RejectsRevokedSessionAndAccessToken(t *testing.T) {
+	env := newRotationTestEnv(t, 0)
+	ctx := context.Background()
+
+	pair := env.login(t, 1)
+	if _, err := env.rotator.Rotate(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("rotate with access token err = %v, want ErrInvalidToken", err)
+	}
+
+	// 登出后的刷新令牌不是重放，只返回会话已撤销
+	if err := env.blacklist.AddSessionToBlacklist(ctx, pair.SessionID, time.Hour); err != nil {
+		t.Fatalf("AddSessionToBlacklist: %v", err)
+	}
+	if _, err := env.rotator.Rotate(ctx, pair.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("rotate revoked session err = %v, want ErrSessionRevoked", err)
+	}
+}
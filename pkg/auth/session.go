@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+const (
+	sessionPrefix = "auth:sessions"
+)
+
+// Session 活跃会话信息
+type Session struct {
+	ID        string    `json:"id"`         // 会话ID（JTI）
+	UserAgent string    `json:"user_agent"` // 登录设备（User-Agent）
+	IP        string    `json:"ip"`         // 登录IP
+	IssuedAt  time.Time `json:"issued_at"`  // 签发时间
+	ExpiresAt time.Time `json:"expires_at"` // 过期时间（刷新令牌过期时间）
+}
+
+// SessionManager 会话管理
+// 每个用户的会话以 Hash 形式存储在 Redis 中：field 为 JTI，value 为会话信息
+type SessionManager struct {
+	jwtAuth   *JWTAuth
+	blacklist *TokenBlacklist
+}
+
+// NewSessionManager 创建会话管理器
+func NewSessionManager(jwtAuth *JWTAuth, blacklist *TokenBlacklist) *SessionManager {
+	return &SessionManager{
+		jwtAuth:   jwtAuth,
+		blacklist: blacklist,
+	}
+}
+
+// Record 记录新签发的会话
+func (sm *SessionManager) Record(ctx context.Context, userID uint, sessionID, userAgent, ip string) error {
+	now := time.Now()
+	ttl := sm.jwtAuth.RefreshTokenDuration()
+	session := Session{
+		ID:        sessionID,
+		UserAgent: userAgent,
+		IP:        ip,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	key := sm.buildKey(userID)
+	if err := cache.HSet(ctx, key, sessionID, string(data)); err != nil {
+		return err
+	}
+	// 整个 Hash 的过期时间随最新会话延长
+	return cache.Expire(ctx, key, ttl)
+}
+
+// ListActiveSessions 列出用户的活跃会话（按签发时间倒序），同时清理已过期的会话
+func (sm *SessionManager) ListActiveSessions(ctx context.Context, userID uint) ([]Session, error) {
+	key := sm.buildKey(userID)
+	values, err := cache.HGetAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]Session, 0, len(values))
+	var expired []string
+	for field, value := range values {
+		var session Session
+		if err := json.Unmarshal([]byte(value), &session); err != nil || !session.ExpiresAt.After(now) {
+			expired = append(expired, field)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		_ = cache.HDel(ctx, key, expired...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt.After(sessions[j].IssuedAt)
+	})
+
+	return sessions, nil
+}
+
+// CountActiveSessions 统计用户的活跃会话数量
+func (sm *SessionManager) CountActiveSessions(ctx context.Context, userID uint) (int, error) {
+	sessions, err := sm.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return len(sessions), nil
+}
+
+// RevokeSession 撤销用户的指定会话
+// 会话从列表中移除，并将 JTI 加入黑名单，使该会话的访问令牌和刷新令牌同时失效
+func (sm *SessionManager) RevokeSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	key := sm.buildKey(userID)
+	exists, err := cache.HExists(ctx, key, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+
+	if err := sm.blacklist.AddSessionToBlacklist(ctx, sessionID, sm.jwtAuth.RefreshTokenDuration()); err != nil {
+		return false, err
+	}
+
+	if err := cache.HDel(ctx, key, sessionID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RevokeAll 撤销用户的全部会话（含已过期但尚未清理的记录），返回撤销数量
+// 用于删除/禁用用户等需要强制下线的场景
+func (sm *SessionManager) RevokeAll(ctx context.Context, userID uint) (int, error) {
+	key := sm.buildKey(userID)
+	values, err := cache.HGetAll(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	for sessionID := range values {
+		if err := sm.blacklist.AddSessionToBlacklist(ctx, sessionID, sm.jwtAuth.RefreshTokenDuration()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := cache.Del(ctx, key); err != nil {
+		return 0, err
+	}
+
+	return len(values), nil
+}
+
+// IsTokenRevoked 检查令牌所属会话是否已被撤销（用于刷新令牌校验）
+func (sm *SessionManager) IsTokenRevoked(ctx context.Context, tokenString string) (bool, error) {
+	claims, err := sm.jwtAuth.ValidateToken(tokenString)
+	if err != nil {
+		// 无效令牌交由后续校验处理
+		return false, nil
+	}
+	return sm.blacklist.IsSessionInBlacklist(ctx, claims.ID)
+}
+
+// Remove 从活跃会话列表中移除会话（登出时使用）
+func (sm *SessionManager) Remove(ctx context.Context, userID uint, sessionID string) error {
+	return cache.HDel(ctx, sm.buildKey(userID), sessionID)
+}
+
+// ParseClaims 解析令牌声明
+func (sm *SessionManager) ParseClaims(tokenString string) (*Claims, error) {
+	return sm.jwtAuth.ValidateToken(tokenString)
+}
+
+// buildKey 构建会话缓存键
+func (sm *SessionManager) buildKey(userID uint) string {
+	return fmt.Sprintf("%s:%d", sessionPrefix, userID)
+}
@@ -32,16 +32,24 @@ const (
 	LockMaxRetries = 20
 )
 
+// DomainMembershipKey 用户域成员关系缓存键，由域成员校验中间件写入，用户角色变更时清理
+func DomainMembershipKey(userID uint, domain string) string {
+	return fmt.Sprintf("domain:member:%d:%s", userID, domain)
+}
+
 // CacheManager 缓存管理器
 type CacheManager struct {
-	rdb *redis.Client
-	sg  singleflight.Group
+	rdb   *redis.Client
+	sg    singleflight.Group
+	stats *hitCounter
 }
 
 // NewCacheManager 创建缓存管理器
-func NewCacheManager() *CacheManager {
+// name 为逻辑缓存名（如 rbac、repo:user），GetObject/GetWithCacheAside 的命中情况按该名称统计，见 Stats
+func NewCacheManager(name string) *CacheManager {
 	return &CacheManager{
-		rdb: GetClient(),
+		rdb:   GetClient(),
+		stats: counterFor(name),
 	}
 }
 
@@ -56,6 +64,7 @@ func (cm *CacheManager) GetWithCacheAside(ctx context.Context, key string, dest
 	// 1. 尝试从缓存获取
 	val, err := Get(ctx, key)
 	if err == nil {
+		cm.stats.hits.Add(1)
 		// 检查是否为空值标记（防穿透）
 		if val == "nil" {
 			return ErrCacheNil
@@ -63,6 +72,8 @@ func (cm *CacheManager) GetWithCacheAside(ctx context.Context, key string, dest
 		// 反序列化到目标对象
 		return json.Unmarshal([]byte(val), dest)
 	}
+	// Redis 不可用同样视为未命中：缓存没有起作用
+	cm.stats.misses.Add(1)
 
 	if err != redis.Nil {
 		// Redis 错误，从数据库加载
@@ -145,11 +156,13 @@ func (cm *CacheManager) SetObject(ctx context.Context, key string, value interfa
 func (cm *CacheManager) GetObject(ctx context.Context, key string, dest interface{}) error {
 	val, err := Get(ctx, key)
 	if err != nil {
+		cm.stats.misses.Add(1)
 		if err == redis.Nil {
 			return ErrCacheMiss
 		}
 		return err
 	}
+	cm.stats.hits.Add(1)
 
 	if val == "nil" {
 		return ErrCacheNil
@@ -160,11 +173,17 @@ func (cm *CacheManager) GetObject(ctx context.Context, key string, dest interfac
 
 // DeleteByPattern 根据模式删除缓存
 func (cm *CacheManager) DeleteByPattern(ctx context.Context, pattern string) error {
+	_, err := cm.DeleteByPatternCount(ctx, pattern)
+	return err
+}
+
+// DeleteByPatternCount 根据模式删除缓存，返回删除的键数量
+func (cm *CacheManager) DeleteByPatternCount(ctx context.Context, pattern string) (int64, error) {
 	fullPattern := BuildKey(pattern)
 	iter := cm.rdb.Scan(ctx, 0, fullPattern, 0).Iterator()
 
 	pipe := cm.rdb.Pipeline()
-	count := 0
+	var count int64
 
 	for iter.Next(ctx) {
 		pipe.Del(ctx, iter.Val())
@@ -173,23 +192,24 @@ func (cm *CacheManager) DeleteByPattern(ctx context.Context, pattern string) err
 		// 批量提交，避免阻塞
 		if count%100 == 0 {
 			if _, err := pipe.Exec(ctx); err != nil {
-				return err
+				return count, err
 			}
 			pipe = cm.rdb.Pipeline()
 		}
 	}
 
 	if err := iter.Err(); err != nil {
-		return err
+		return count, err
 	}
 
 	// 提交剩余的
 	if count%100 != 0 {
-		_, err := pipe.Exec(ctx)
-		return err
+		if _, err := pipe.Exec(ctx); err != nil {
+			return count, err
+		}
 	}
 
-	return nil
+	return count, nil
 }
 
 // Lock 分布式锁
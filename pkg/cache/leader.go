@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Leader 基于 Redis 租约的简单主节点选举
+// 多实例部署时只有持有租约的实例执行定时任务；持有者每次检查时续约，
+// 持有者宕机后租约到期，其他实例在下一次检查时接管
+type Leader struct {
+	key   string
+	id    string
+	lease time.Duration
+}
+
+// leaderAcquireScript 租约空闲时抢占，已由自己持有时续约
+var leaderAcquireScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// leaderResignScript 仅当租约由自己持有时释放
+var leaderResignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// NewLeader 创建选举器
+// name 为选举名称（同名的实例参与同一选举），lease 应大于检查间隔，避免正常运行时租约过期
+func NewLeader(name string, lease time.Duration) *Leader {
+	return &Leader{
+		key:   BuildKey("leader", name),
+		id:    uuid.New().String(),
+		lease: lease,
+	}
+}
+
+// IsLeader 尝试获取或续约租约，返回当前实例是否为主节点
+func (l *Leader) IsLeader(ctx context.Context) (bool, error) {
+	ok, err := leaderAcquireScript.Run(ctx, rdb, []string{l.key}, l.id, l.lease.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+// Resign 主动释放租约（实例退出时调用），其他实例可立即接管
+func (l *Leader) Resign(ctx context.Context) error {
+	return leaderResignScript.Run(ctx, rdb, []string{l.key}, l.id).Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/cccvno1/nova/pkg/config"
@@ -31,9 +32,11 @@ func Init(cfg *config.RedisConfig) error {
 		WriteTimeout: 3 * time.Second,
 		PoolTimeout:  4 * time.Second,
 	})
+	rdb.AddHook(timingHook{})
 
 	// 测试连接
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
 		return fmt.Errorf("failed to connect redis: %w", err)
 	}
 
@@ -173,6 +176,11 @@ func HExists(ctx context.Context, key, field string) (bool, error) {
 	return rdb.HExists(ctx, BuildKey(key), field).Result()
 }
 
+// HIncrBy 哈希字段自增
+func HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	return rdb.HIncrBy(ctx, BuildKey(key), field, incr).Result()
+}
+
 // SAdd 添加集合成员
 func SAdd(ctx context.Context, key string, members ...interface{}) error {
 	return rdb.SAdd(ctx, BuildKey(key), members...).Err()
@@ -213,6 +221,32 @@ func ZRange(ctx context.Context, key string, start, stop int64) ([]string, error
 	return rdb.ZRange(ctx, BuildKey(key), start, stop).Result()
 }
 
+// ZRangeByScore 按分数区间获取有序集合成员，limit <= 0 表示不限制数量
+func ZRangeByScore(ctx context.Context, key, min, max string, limit int64) ([]string, error) {
+	opt := &redis.ZRangeBy{Min: min, Max: max}
+	if limit > 0 {
+		opt.Count = limit
+	}
+	return rdb.ZRangeByScore(ctx, BuildKey(key), opt).Result()
+}
+
+// zMoveByScoreScript 取出分数不超过 max 的前 limit 个成员，从有序集合删除后推入列表左侧
+var zMoveByScoreScript = redis.NewScript(`
+local members = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, member in ipairs(members) do
+	redis.call("ZREM", KEYS[1], member)
+	redis.call("LPUSH", KEYS[2], member)
+end
+return members
+`)
+
+// ZMoveByScoreToList 原子地将有序集合中分数不超过 max 的成员（最多 limit 个）移入列表左侧
+// 返回被移动的成员；多个实例同时调用时每个成员只会被移动一次
+func ZMoveByScoreToList(ctx context.Context, zsetKey, listKey string, max float64, limit int64) ([]string, error) {
+	return zMoveByScoreScript.Run(ctx, rdb, []string{BuildKey(zsetKey), BuildKey(listKey)},
+		strconv.FormatFloat(max, 'f', -1, 64), limit).StringSlice()
+}
+
 // Pipeline 创建管道
 func Pipeline() redis.Pipeliner {
 	return rdb.Pipeline()
@@ -257,6 +291,11 @@ func LLen(ctx context.Context, key string) (int64, error) {
 	return rdb.LLen(ctx, BuildKey(key)).Result()
 }
 
+// LTrim 保留列表指定区间元素
+func LTrim(ctx context.Context, key string, start, stop int64) error {
+	return rdb.LTrim(ctx, BuildKey(key), start, stop).Err()
+}
+
 // LRange 获取列表指定区间元素
 func LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
 	return rdb.LRange(ctx, BuildKey(key), start, stop).Result()
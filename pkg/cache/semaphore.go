@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Semaphore 基于 Redis 有序集合的分布式信号量
+// 每个持有者以随机令牌为成员、租约到期时间为分数登记，获取时先清理过期租约，
+// 进程崩溃或连接中断未能释放的槽位会在租约到期后自动回收
+type Semaphore struct {
+	prefix string
+	limit  int64
+	lease  time.Duration
+}
+
+// semaphoreAcquireScript 清理过期租约后，未达上限则登记新的持有者
+var semaphoreAcquireScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return 1
+`)
+
+// NewSemaphore 创建分布式信号量
+// prefix 为键前缀，实际键为 prefix:name；limit 为每个键的最大并发数；lease 为单个槽位的最长持有时间
+func NewSemaphore(prefix string, limit int, lease time.Duration) *Semaphore {
+	return &Semaphore{
+		prefix: prefix,
+		limit:  int64(limit),
+		lease:  lease,
+	}
+}
+
+// Acquire 尝试获取槽位，不阻塞
+// 成功时返回持有者令牌，用于 Release；槽位已满时返回 ok=false
+// 令牌随机生成，同一时刻获取的多个持有者不会共用成员而互相覆盖或误释放
+func (s *Semaphore) Acquire(ctx context.Context, name string) (token string, ok bool, err error) {
+	now := time.Now()
+	token = uuid.New().String()
+
+	acquired, err := semaphoreAcquireScript.Run(ctx, rdb, []string{BuildKey(s.prefix, name)},
+		now.UnixMilli(), s.limit, now.Add(s.lease).UnixMilli(), token, s.lease.Milliseconds()).Int()
+	if err != nil {
+		return "", false, err
+	}
+	return token, acquired == 1, nil
+}
+
+// Release 释放槽位
+func (s *Semaphore) Release(ctx context.Context, name, token string) error {
+	return rdb.ZRem(ctx, BuildKey(s.prefix, name), token).Err()
+}
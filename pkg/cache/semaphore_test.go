@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+func TestSemaphore_Acquire_Concurrent(t *testing.T) {
+	testutil.NewRedis(t)
+	ctx := context.Background()
+
+	const (
+		limit   = 3
+		workers = 20
+	)
+	sem := cache.NewSemaphore("test:sem", limit, time.Minute)
+
+	var (
+		wg     sync.WaitGroup
+		start  = make(chan struct{})
+		tokens = make([]string, workers)
+		oks    = make([]bool, workers)
+		errs   = make([]error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			tokens[i], oks[i], errs[i] = sem.Acquire(ctx, "job")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	held := make(map[string]bool)
+	for i := 0; i < workers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("worker %d: %v", i, errs[i])
+		}
+		if !oks[i] {
+			continue
+		}
+		if held[tokens[i]] {
+			t.Fatalf("token %q handed out twice", tokens[i])
+		}
+		held[tokens[i]] = true
+	}
+	if len(held) != limit {
+		t.Fatalf("acquired %d slots, want %d", len(held), limit)
+	}
+	if n := cache.GetClient().ZCard(ctx, cache.BuildKey("test:sem", "job")).Val(); n != limit {
+		t.Fatalf("semaphore members = %d, want %d", n, limit)
+	}
+
+	// 释放一个持有者只归还它自己的槽位
+	for token := range held {
+		if err := sem.Release(ctx, "job", token); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		break
+	}
+	if n := cache.GetClient().ZCard(ctx, cache.BuildKey("test:sem", "job")).Val(); n != limit-1 {
+		t.Fatalf("semaphore members after release = %d, want %d", n, limit-1)
+	}
+	if _, ok, err := sem.Acquire(ctx, "job"); err != nil || !ok {
+		t.Fatalf("Acquire after release: ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := sem.Acquire(ctx, "job"); err != nil || ok {
+		t.Fatalf("Acquire over limit: ok=%v err=%v", ok, err)
+	}
+}
+
+// 同一时刻获取的持有者各自登记，槽位数与成功次数一致，逐个释放后全部归还
+func TestSemaphore_Acquire_DistinctHolders(t *testing.T) {
+	testutil.NewRedis(t)
+	ctx := context.Background()
+
+	const workers = 64
+	sem := cache.NewSemaphore("test:sem", workers, time.Minute)
+	key := cache.BuildKey("test:sem", "job")
+
+	var (
+		wg     sync.WaitGroup
+		start  = make(chan struct{})
+		tokens = make([]string, workers)
+		errs   = make([]error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			var ok bool
+			tokens[i], ok, errs[i] = sem.Acquire(ctx, "job")
+			if errs[i] == nil && !ok {
+				errs[i] = errors.New("slot not acquired")
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < workers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("worker %d: %v", i, errs[i])
+		}
+	}
+	if n := cache.GetClient().ZCard(ctx, key).Val(); n != workers {
+		t.Fatalf("semaphore members = %d, want %d", n, workers)
+	}
+	for _, token := range tokens {
+		if err := sem.Release(ctx, "job", token); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+	}
+	if n := cache.GetClient().ZCard(ctx, key).Val(); n != 0 {
+		t.Fatalf("semaphore members after release = %d, want 0", n)
+	}
+}
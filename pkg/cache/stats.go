@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// hitCounter 单个逻辑缓存的命中计数（原子操作，热路径无锁）
+type hitCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// counters 逻辑缓存名 -> *hitCounter，只在创建 CacheManager 时写入
+var counters sync.Map
+
+// counterFor 获取（或创建）逻辑缓存的计数器
+func counterFor(name string) *hitCounter {
+	if c, ok := counters.Load(name); ok {
+		return c.(*hitCounter)
+	}
+	c, _ := counters.LoadOrStore(name, &hitCounter{})
+	return c.(*hitCounter)
+}
+
+// HitStats 单个逻辑缓存的命中统计
+type HitStats struct {
+	Name     string  `json:"name"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"` // hits / (hits + misses)，尚无请求时为 0
+}
+
+// Stats 返回本进程内各逻辑缓存的命中统计（按名称排序）
+// 计数自进程启动起累计，多实例部署时需分别查询
+func Stats() []HitStats {
+	stats := make([]HitStats, 0)
+	counters.Range(func(key, value interface{}) bool {
+		c := value.(*hitCounter)
+		s := HitStats{
+			Name:   key.(string),
+			Hits:   c.hits.Load(),
+			Misses: c.misses.Load(),
+		}
+		if total := s.Hits + s.Misses; total > 0 {
+			s.HitRatio = float64(s.Hits) / float64(total)
+		}
+		stats = append(stats, s)
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
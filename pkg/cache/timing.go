@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/cccvno1/nova/pkg/timing"
+	"github.com/redis/go-redis/v9"
+)
+
+// timingHook 将 Redis 命令耗时计入请求的 cache 耗时（请求上下文中没有记录器时直接跳过）
+type timingHook struct{}
+
+func (timingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (timingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		defer timing.Start(ctx, timing.Cache)()
+		return next(ctx, cmd)
+	}
+}
+
+func (timingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		defer timing.Start(ctx, timing.Cache)()
+		return next(ctx, cmds)
+	}
+}
+
+var _ redis.Hook = timingHook{}
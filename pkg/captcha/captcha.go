@@ -0,0 +1,87 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/config"
+)
+
+const (
+	hCaptchaVerifyURL  = "https://hcaptcha.com/siteverify"
+	reCaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+)
+
+// Verifier 验证码校验接口
+type Verifier interface {
+	// Verify 校验客户端提交的验证码令牌
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// NewVerifier 根据配置创建验证码校验器
+func NewVerifier(cfg *config.CaptchaConfig) (Verifier, error) {
+	switch cfg.Provider {
+	case "", "hcaptcha":
+		return NewSiteVerifyVerifier(hCaptchaVerifyURL, cfg.SecretKey), nil
+	case "recaptcha":
+		return NewSiteVerifyVerifier(reCaptchaVerifyURL, cfg.SecretKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported captcha provider: %s", cfg.Provider)
+	}
+}
+
+// SiteVerifyVerifier 基于 siteverify 接口的校验器（hCaptcha 与 reCAPTCHA 协议一致）
+type SiteVerifyVerifier struct {
+	verifyURL string
+	secretKey string
+	client    *http.Client
+}
+
+// NewSiteVerifyVerifier 创建 siteverify 校验器
+func NewSiteVerifyVerifier(verifyURL, secretKey string) *SiteVerifyVerifier {
+	return &SiteVerifyVerifier{
+		verifyURL: verifyURL,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify 调用验证码服务校验令牌
+func (v *SiteVerifyVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{}
+	form.Set("secret", v.secretKey)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+
+	return result.Success, nil
+}
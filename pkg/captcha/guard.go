@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/config"
+)
+
+const loginFailurePrefix = "captcha:login_failures"
+
+// Guard 登录失败计数与验证码要求
+// 失败次数按 IP 记录在 Redis 中，达到阈值后要求验证码
+type Guard struct {
+	verifier  Verifier
+	threshold int
+	window    time.Duration
+}
+
+// NewGuard 创建登录验证码守卫
+func NewGuard(cfg *config.CaptchaConfig, verifier Verifier) *Guard {
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	window := time.Duration(cfg.Window) * time.Second
+	if window <= 0 {
+		window = 15 * time.Minute
+	}
+
+	return &Guard{
+		verifier:  verifier,
+		threshold: threshold,
+		window:    window,
+	}
+}
+
+// Required 判断该 IP 是否需要验证码
+func (g *Guard) Required(ctx context.Context, ip string) bool {
+	val, err := cache.Get(ctx, g.buildKey(ip))
+	if err != nil {
+		return false
+	}
+	count, _ := strconv.Atoi(val)
+	return count >= g.threshold
+}
+
+// Verify 校验验证码令牌
+func (g *Guard) Verify(ctx context.Context, token, ip string) (bool, error) {
+	return g.verifier.Verify(ctx, token, ip)
+}
+
+// RecordFailure 记录一次登录失败
+func (g *Guard) RecordFailure(ctx context.Context, ip string) {
+	key := g.buildKey(ip)
+	count, err := cache.Incr(ctx, key)
+	if err != nil {
+		return
+	}
+	if count == 1 {
+		_ = cache.Expire(ctx, key, g.window)
+	}
+}
+
+// Reset 登录成功后清除失败计数
+func (g *Guard) Reset(ctx context.Context, ip string) {
+	_ = cache.Del(ctx, g.buildKey(ip))
+}
+
+func (g *Guard) buildKey(ip string) string {
+	return fmt.Sprintf("%s:%s", loginFailurePrefix, ip)
+}
@@ -2,46 +2,111 @@ package casbin
 
 import (
 	"context"
+	_ "embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"os"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/cccvno1/nova/pkg/timing"
 	"gorm.io/gorm"
 )
 
+// defaultModel 内置的 RBAC with Domains 模型（与 configs/rbac_model.conf 保持一致）
+// 未配置模型或模型文件缺失时使用，便于容器化部署
+//
+//go:embed rbac_model.conf
+var defaultModel string
+
+// DefaultTableName 未配置表名时使用的策略表（与 gorm-adapter 默认一致）
+const DefaultTableName = "casbin_rule"
+
+// tableNamePattern 策略表名：小写字母或下划线开头，只含小写字母、数字、下划线，不超过 63 个字符（PostgreSQL 标识符上限）
+// 表名会直接拼入建索引的 SQL，不允许引号、点号等字符
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// ValidateTableName 校验策略表名
+func ValidateTableName(name string) error {
+	if !tableNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid policy table name %q: must match %s", name, tableNamePattern)
+	}
+	return nil
+}
+
 // Enforcer 是 Casbin enforcer 的企业级封装
 type Enforcer struct {
-	enforcer     *casbin.Enforcer
-	adapter      *gormadapter.Adapter
-	mu           sync.RWMutex
-	autoSave     bool
-	autoLoad     bool
-	autoLoadTick time.Duration
-	stopAutoLoad chan struct{}
-	logger       *slog.Logger
+	enforcer       *casbin.Enforcer
+	adapter        *gormadapter.Adapter
+	db             *gorm.DB
+	tableName      string
+	mu             sync.RWMutex
+	autoSave       bool
+	autoLoad       bool
+	autoLoadTick   time.Duration
+	reloadOnChange bool
+	reload         reloadState
+	stopAutoLoad   chan struct{}
+	logger         *slog.Logger
 }
 
 // Config Casbin 配置
 type Config struct {
 	ModelPath    string        // 模型文件路径
+	ModelText    string        // 内联模型定义，优先于 ModelPath
+	TableName    string        // 策略表名，为空时使用 DefaultTableName；同库部署多套策略时各自使用独立的表
 	AutoSave     bool          // 是否自动保存策略
 	AutoLoad     bool          // 是否自动加载策略（多实例同步）
 	AutoLoadTick time.Duration // 自动加载间隔
+
+	// ReloadOnChange 自动加载时先检查策略版本，只有版本变化（有策略修改）才全量重新加载；
+	// 为 false 时每次都全量重新加载
+	ReloadOnChange bool
+}
+
+// ensurePolicyIndex 创建策略表的唯一索引
+// 自定义表名时适配器只按结构体迁移表，不会像默认表那样建立 (ptype,v0..v5) 唯一索引，这里补齐以防写入重复规则；
+// 索引名与适配器一致（idx_<表名>），已存在时跳过
+func ensurePolicyIndex(db *gorm.DB, tableName string) error {
+	index := "idx_" + tableName
+	if db.Migrator().HasIndex(tableName, index) {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (ptype,v0,v1,v2,v3,v4,v5)", index, tableName)).Error
 }
 
 // NewEnforcer 创建新的 Casbin enforcer
 func NewEnforcer(db *gorm.DB, cfg Config, logger *slog.Logger) (*Enforcer, error) {
 	// 初始化 GORM 适配器
-	adapter, err := gormadapter.NewAdapterByDB(db)
+	tableName := cfg.TableName
+	if tableName == "" {
+		tableName = DefaultTableName
+	}
+	if err := ValidateTableName(tableName); err != nil {
+		return nil, err
+	}
+	adapter, err := gormadapter.NewAdapterByDBWithCustomTable(db, &gormadapter.CasbinRule{}, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create casbin adapter: %w", err)
 	}
+	if err := ensurePolicyIndex(db, tableName); err != nil {
+		return nil, fmt.Errorf("failed to create casbin policy index: %w", err)
+	}
+
+	// 加载并校验模型
+	m, source, err := loadModel(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建 enforcer
-	e, err := casbin.NewEnforcer(cfg.ModelPath, adapter)
+	e, err := casbin.NewEnforcer(m, adapter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create casbin enforcer: %w", err)
 	}
@@ -49,19 +114,23 @@ func NewEnforcer(db *gorm.DB, cfg Config, logger *slog.Logger) (*Enforcer, error
 	// 设置自动保存
 	e.EnableAutoSave(cfg.AutoSave)
 
-	// 加载策略
-	if err := e.LoadPolicy(); err != nil {
-		return nil, fmt.Errorf("failed to load policy: %w", err)
+	enforcer := &Enforcer{
+		enforcer:       e,
+		adapter:        adapter,
+		db:             db,
+		tableName:      tableName,
+		autoSave:       cfg.AutoSave,
+		autoLoad:       cfg.AutoLoad,
+		autoLoadTick:   cfg.AutoLoadTick,
+		reloadOnChange: cfg.ReloadOnChange,
+		stopAutoLoad:   make(chan struct{}),
+		logger:         logger,
 	}
+	enforcer.reload.stats.AutoLoad = cfg.AutoLoad && cfg.AutoLoadTick > 0
 
-	enforcer := &Enforcer{
-		enforcer:     e,
-		adapter:      adapter,
-		autoSave:     cfg.AutoSave,
-		autoLoad:     cfg.AutoLoad,
-		autoLoadTick: cfg.AutoLoadTick,
-		stopAutoLoad: make(chan struct{}),
-		logger:       logger,
+	// 加载策略（策略版本表由数据库迁移创建，需先完成迁移）
+	if err := enforcer.LoadPolicy(); err != nil {
+		return nil, fmt.Errorf("failed to load policy: %w", err)
 	}
 
 	// 启动自动加载（用于多实例部署时的策略同步）
@@ -70,14 +139,51 @@ func NewEnforcer(db *gorm.DB, cfg Config, logger *slog.Logger) (*Enforcer, error
 	}
 
 	logger.Info("casbin enforcer initialized successfully",
-		"model", cfg.ModelPath,
+		"model", source,
+		"table", tableName,
 		"autoSave", cfg.AutoSave,
 		"autoLoad", cfg.AutoLoad,
+		"reloadOnChange", cfg.ReloadOnChange,
 	)
 
 	return enforcer, nil
 }
 
+// loadModel 按优先级加载 Casbin 模型：内联定义 > 模型文件 > 内置默认模型
+// 返回模型及其来源描述（用于日志）
+func loadModel(cfg Config, logger *slog.Logger) (model.Model, string, error) {
+	if cfg.ModelText != "" {
+		m, err := model.NewModelFromString(cfg.ModelText)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load inline casbin model: %w", err)
+		}
+		return m, "inline", nil
+	}
+
+	if cfg.ModelPath != "" {
+		_, statErr := os.Stat(cfg.ModelPath)
+		if statErr == nil {
+			m, err := model.NewModelFromFile(cfg.ModelPath)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to load casbin model %s: %w", cfg.ModelPath, err)
+			}
+			return m, cfg.ModelPath, nil
+		}
+		if !errors.Is(statErr, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to stat casbin model %s: %w", cfg.ModelPath, statErr)
+		}
+		logger.Warn("casbin model file not found, falling back to embedded default model",
+			"model_path", cfg.ModelPath,
+		)
+	}
+
+	m, err := model.NewModelFromString(defaultModel)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load embedded casbin model: %w", err)
+	}
+	return m, "embedded", nil
+}
+
 // startAutoLoad 定期从数据库重新加载策略（多实例同步）
 func (e *Enforcer) startAutoLoad() {
 	ticker := time.NewTicker(e.autoLoadTick)
@@ -86,11 +192,7 @@ func (e *Enforcer) startAutoLoad() {
 	for {
 		select {
 		case <-ticker.C:
-			if err := e.LoadPolicy(); err != nil {
-				e.logger.Error("failed to auto-load policy", "error", err)
-			} else {
-				e.logger.Debug("policy auto-loaded successfully")
-			}
+			e.autoReload()
 		case <-e.stopAutoLoad:
 			e.logger.Info("auto-load stopped")
 			return
@@ -98,6 +200,29 @@ func (e *Enforcer) startAutoLoad() {
 	}
 }
 
+// autoReload 执行一次自动加载：启用 ReloadOnChange 时先读取策略版本（单行查询），
+// 版本未变化则跳过，避免每个周期都在写锁下全量加载、阻塞鉴权
+func (e *Enforcer) autoReload() {
+	if e.reloadOnChange {
+		version, err := readPolicyVersion(e.db)
+		if err != nil {
+			e.reload.failed(err)
+			e.logger.Error("failed to read casbin policy version", "error", err)
+			return
+		}
+		if version == e.reload.version() {
+			e.reload.skipped()
+			return
+		}
+	}
+
+	if err := e.LoadPolicy(); err != nil {
+		e.logger.Error("failed to auto-load policy", "error", err)
+	} else {
+		e.logger.Debug("policy auto-loaded successfully", "version", e.reload.version())
+	}
+}
+
 // Close 关闭 enforcer
 func (e *Enforcer) Close() error {
 	if e.autoLoad {
@@ -107,17 +232,60 @@ func (e *Enforcer) Close() error {
 }
 
 // LoadPolicy 从数据库重新加载所有策略
+// 加载前读取策略版本，加载期间发生的修改会使版本继续变化，下一次自动加载时再次加载
 func (e *Enforcer) LoadPolicy() error {
+	version, err := readPolicyVersion(e.db)
+	if err != nil {
+		e.reload.failed(err)
+		return fmt.Errorf("failed to read policy version: %w", err)
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.LoadPolicy()
+
+	start := time.Now()
+	if err := e.enforcer.LoadPolicy(); err != nil {
+		e.reload.failed(err)
+		return err
+	}
+	e.reload.reloaded(version, time.Since(start))
+	return nil
+}
+
+// Stats 返回策略版本与自动加载统计
+func (e *Enforcer) Stats() ReloadStats {
+	return e.reload.snapshot()
+}
+
+// changed 策略修改成功后递增策略版本，通知其他实例重新加载
+// 递增失败只记录日志：策略已写入，其他实例最迟在下一次版本变化时同步
+func (e *Enforcer) changed(ok bool, err error) (bool, error) {
+	if err != nil || !ok {
+		return ok, err
+	}
+	e.bumpVersion()
+	return ok, nil
+}
+
+// bumpVersion 递增策略版本，并在没有其他实例并发修改时推进本实例的已知版本
+func (e *Enforcer) bumpVersion() {
+	version, err := BumpPolicyVersion(e.db)
+	if err != nil {
+		e.logger.Warn("failed to bump casbin policy version", "error", err)
+		return
+	}
+	e.reload.advance(version)
 }
 
 // SavePolicy 保存所有策略到数据库
 func (e *Enforcer) SavePolicy() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.SavePolicy()
+	if err := e.enforcer.SavePolicy(); err != nil {
+		return err
+	}
+	e.bumpVersion()
+	return nil
 }
 
 // ============================
@@ -132,9 +300,10 @@ func (e *Enforcer) Enforce(sub, dom, obj, act string) (bool, error) {
 	return e.enforcer.Enforce(sub, dom, obj, act)
 }
 
-// EnforceWithContext 带上下文的权限验证
+// EnforceWithContext 带上下文的权限验证，耗时（含等待读锁）计入请求的 enforcer 耗时
 func (e *Enforcer) EnforceWithContext(ctx context.Context, sub, dom, obj, act string) (bool, error) {
 	// TODO: 可以在这里添加上下文超时控制
+	defer timing.Start(ctx, timing.Enforcer)()
 	return e.Enforce(sub, dom, obj, act)
 }
 
@@ -153,28 +322,28 @@ func (e *Enforcer) BatchEnforce(requests [][]interface{}) ([]bool, error) {
 func (e *Enforcer) AddPolicy(sub, dom, obj, act string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.AddPolicy(sub, dom, obj, act)
+	return e.changed(e.enforcer.AddPolicy(sub, dom, obj, act))
 }
 
 // AddPolicies 批量添加权限策略
 func (e *Enforcer) AddPolicies(rules [][]string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.AddPolicies(rules)
+	return e.changed(e.enforcer.AddPolicies(rules))
 }
 
 // RemovePolicy 删除权限策略
 func (e *Enforcer) RemovePolicy(sub, dom, obj, act string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.RemovePolicy(sub, dom, obj, act)
+	return e.changed(e.enforcer.RemovePolicy(sub, dom, obj, act))
 }
 
 // RemovePolicies 批量删除权限策略
 func (e *Enforcer) RemovePolicies(rules [][]string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.RemovePolicies(rules)
+	return e.changed(e.enforcer.RemovePolicies(rules))
 }
 
 // RemoveFilteredPolicy 根据过滤条件删除策略
@@ -182,7 +351,7 @@ func (e *Enforcer) RemovePolicies(rules [][]string) (bool, error) {
 func (e *Enforcer) RemoveFilteredPolicy(fieldIndex int, fieldValues ...string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.RemoveFilteredPolicy(fieldIndex, fieldValues...)
+	return e.changed(e.enforcer.RemoveFilteredPolicy(fieldIndex, fieldValues...))
 }
 
 // GetPolicy 获取所有权限策略
@@ -214,7 +383,7 @@ func (e *Enforcer) HasPolicy(params ...interface{}) (bool, error) {
 func (e *Enforcer) AddRoleForUser(user, role, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.AddRoleForUser(user, role, domain)
+	return e.changed(e.enforcer.AddRoleForUser(user, role, domain))
 }
 
 // AddRolesForUser 给用户批量分配角色
@@ -226,21 +395,42 @@ func (e *Enforcer) AddRolesForUser(user string, roles []string, domain string) (
 	for _, role := range roles {
 		rules = append(rules, []string{user, role, domain})
 	}
-	return e.enforcer.AddGroupingPolicies(rules)
+	return e.changed(e.enforcer.AddGroupingPolicies(rules))
 }
 
 // DeleteRoleForUser 删除用户的角色
 func (e *Enforcer) DeleteRoleForUser(user, role, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.DeleteRoleForUser(user, role, domain)
+	return e.changed(e.enforcer.DeleteRoleForUser(user, role, domain))
 }
 
 // DeleteRolesForUser 删除用户的所有角色
 func (e *Enforcer) DeleteRolesForUser(user, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.DeleteRolesForUser(user, domain)
+	return e.changed(e.enforcer.DeleteRolesForUser(user, domain))
+}
+
+// GetGroupingPolicy 获取所有用户-角色分配规则（g 表），每项为 [用户, 角色, 域]
+func (e *Enforcer) GetGroupingPolicy() ([][]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.enforcer.GetGroupingPolicy()
+}
+
+// AddGroupingPolicies 批量添加用户-角色分配规则
+func (e *Enforcer) AddGroupingPolicies(rules [][]string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.changed(e.enforcer.AddGroupingPolicies(rules))
+}
+
+// RemoveGroupingPolicies 批量删除用户-角色分配规则
+func (e *Enforcer) RemoveGroupingPolicies(rules [][]string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.changed(e.enforcer.RemoveGroupingPolicies(rules))
 }
 
 // GetRolesForUser 获取用户的所有角色
@@ -272,14 +462,14 @@ func (e *Enforcer) HasRoleForUser(user, role, domain string) (bool, error) {
 func (e *Enforcer) AddRoleInheritance(role1, role2, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.AddNamedGroupingPolicy("g2", role1, role2, domain)
+	return e.changed(e.enforcer.AddNamedGroupingPolicy("g2", role1, role2, domain))
 }
 
 // DeleteRoleInheritance 删除角色继承关系
 func (e *Enforcer) DeleteRoleInheritance(role1, role2, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.RemoveNamedGroupingPolicy("g2", role1, role2, domain)
+	return e.changed(e.enforcer.RemoveNamedGroupingPolicy("g2", role1, role2, domain))
 }
 
 // GetRoleInheritance 获取角色继承的所有父角色
@@ -301,6 +491,25 @@ func (e *Enforcer) GetRoleInheritance(role, domain string) ([]string, error) {
 	return parentRoles, nil
 }
 
+// GetRoleInheritanceEdges 获取域内所有角色继承关系，每项为 [子角色, 父角色]
+func (e *Enforcer) GetRoleInheritanceEdges(domain string) ([][2]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	policies, err := e.enforcer.GetNamedGroupingPolicy("g2")
+	if err != nil {
+		return nil, err
+	}
+
+	var edges [][2]string
+	for _, policy := range policies {
+		if len(policy) >= 3 && policy[2] == domain {
+			edges = append(edges, [2]string{policy[0], policy[1]})
+		}
+	}
+	return edges, nil
+}
+
 // ============================
 // 高级查询方法
 // ============================
@@ -385,6 +594,7 @@ func (e *Enforcer) DeleteDomain(domain string) error {
 		return fmt.Errorf("failed to remove role inheritance for domain: %w", err)
 	}
 
+	e.bumpVersion()
 	return nil
 }
 
@@ -410,12 +620,61 @@ func (e *Enforcer) AddPoliciesForRole(role, domain string, permissions [][]strin
 			rules = append(rules, []string{role, domain, perm[0], perm[1]})
 		}
 	}
-	return e.enforcer.AddPolicies(rules)
+	return e.changed(e.enforcer.AddPolicies(rules))
+}
+
+// ReplacePoliciesForRole 在一个数据库事务中替换角色在该域下的全部权限策略，提交后重新加载内存策略
+// 事务失败时数据库与内存中的策略都保持原样；持有写锁期间鉴权会短暂等待
+func (e *Enforcer) ReplacePoliciesForRole(role, domain string, permissions [][]string) error {
+	rules := make([]gormadapter.CasbinRule, 0, len(permissions))
+	seen := make(map[[2]string]struct{}, len(permissions))
+	for _, perm := range permissions {
+		if len(perm) < 2 {
+			continue
+		}
+		key := [2]string{perm[0], perm[1]}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		rules = append(rules, gormadapter.CasbinRule{Ptype: "p", V0: role, V1: domain, V2: perm[0], V3: perm[1]})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err := e.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(e.tableName).Where("ptype = ? AND v0 = ? AND v1 = ?", "p", role, domain).
+			Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Table(e.tableName).Create(&rules).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace role policies: %w", err)
+	}
+
+	// 数据库已提交：无论重新加载是否成功都递增版本，加载失败时由自动加载补齐
+	start := time.Now()
+	loadErr := e.enforcer.LoadPolicy()
+	if loadErr != nil {
+		e.reload.failed(loadErr)
+	} else {
+		e.reload.reloaded(e.reload.version(), time.Since(start))
+	}
+	e.bumpVersion()
+	if loadErr != nil {
+		return fmt.Errorf("role policies replaced but failed to reload policy: %w", loadErr)
+	}
+	return nil
 }
 
 // RemoveAllPoliciesForRole 删除角色的所有权限
 func (e *Enforcer) RemoveAllPoliciesForRole(role, domain string) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	return e.enforcer.RemoveFilteredPolicy(0, role, domain)
+	return e.changed(e.enforcer.RemoveFilteredPolicy(0, role, domain))
 }
@@ -0,0 +1,218 @@
+package casbin
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestEnforcer 创建基于临时 SQLite 文件的 enforcer
+func newTestEnforcer(t *testing.T) (*Enforcer, *gorm.DB) {
+	t.Helper()
+	return newTestEnforcerWithConfig(t, Config{AutoSave: true})
+}
+
+// newTestEnforcerWithConfig 按指定配置创建基于临时 SQLite 文件的 enforcer
+func newTestEnforcerWithConfig(t *testing.T, cfg Config) (*Enforcer, *gorm.DB) {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "casbin.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 策略版本表在生产环境由数据库迁移创建
+	if err := db.AutoMigrate(&PolicyVersion{}); err != nil {
+		t.Fatalf("migrate policy version: %v", err)
+	}
+	if err := db.Create(&PolicyVersion{ID: policyVersionID, UpdatedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("create policy version: %v", err)
+	}
+
+	e, err := NewEnforcer(db, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewEnforcer: %v", err)
+	}
+	return e, db
+}
+
+// storedPolicies 读取数据库中角色在域下的 [资源, 操作] 策略（排序后）
+func storedPolicies(t *testing.T, db *gorm.DB, role, domain string) [][2]string {
+	t.Helper()
+
+	var rows []gormadapter.CasbinRule
+	if err := db.Where("ptype = ? AND v0 = ? AND v1 = ?", "p", role, domain).Find(&rows).Error; err != nil {
+		t.Fatalf("query casbin_rule: %v", err)
+	}
+	out := make([][2]string, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, [2]string{r.V2, r.V3})
+	}
+	sortPairs(out)
+	return out
+}
+
+// memoryPolicies 读取内存中角色在域下的 [资源, 操作] 策略（排序后）
+func memoryPolicies(t *testing.T, e *Enforcer, role, domain string) [][2]string {
+	t.Helper()
+
+	policies, err := e.GetPoliciesForRole(role, domain)
+	if err != nil {
+		t.Fatalf("GetPoliciesForRole: %v", err)
+	}
+	out := make([][2]string, 0, len(policies))
+	for _, p := range policies {
+		out = append(out, [2]string{p[2], p[3]})
+	}
+	sortPairs(out)
+	return out
+}
+
+func sortPairs(pairs [][2]string) {
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0]+"\x00"+pairs[i][1] < pairs[j][0]+"\x00"+pairs[j][1]
+	})
+}
+
+func TestReplacePoliciesForRole(t *testing.T) {
+	e, db := newTestEnforcer(t)
+
+	if _, err := e.AddPoliciesForRole("1", "default", [][]string{{"user", "read"}, {"user", "write"}}); err != nil {
+		t.Fatalf("AddPoliciesForRole: %v", err)
+	}
+	if _, err := e.AddPoliciesForRole("2", "default", [][]string{{"file", "read"}}); err != nil {
+		t.Fatalf("AddPoliciesForRole: %v", err)
+	}
+	before := e.Stats().Version
+
+	err := e.ReplacePoliciesForRole("1", "default", [][]string{{"role", "read"}, {"role", "read"}, {"menu", "write"}})
+	if err != nil {
+		t.Fatalf("ReplacePoliciesForRole: %v", err)
+	}
+
+	want := [][2]string{{"menu", "write"}, {"role", "read"}}
+	if got := storedPolicies(t, db, "1", "default"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored policies = %v, want %v", got, want)
+	}
+	if got := memoryPolicies(t, e, "1", "default"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("memory policies = %v, want %v", got, want)
+	}
+	// 其他角色不受影响
+	if got := storedPolicies(t, db, "2", "default"); !reflect.DeepEqual(got, [][2]string{{"file", "read"}}) {
+		t.Fatalf("other role policies = %v", got)
+	}
+	if after := e.Stats().Version; after != before+1 {
+		t.Fatalf("policy version = %d, want %d", after, before+1)
+	}
+}
+
+func TestReplacePoliciesForRole_ClearsWithEmptyList(t *testing.T) {
+	e, db := newTestEnforcer(t)
+
+	if _, err := e.AddPoliciesForRole("1", "default", [][]string{{"user", "read"}}); err != nil {
+		t.Fatalf("AddPoliciesForRole: %v", err)
+	}
+	if err := e.ReplacePoliciesForRole("1", "default", nil); err != nil {
+		t.Fatalf("ReplacePoliciesForRole: %v", err)
+	}
+	if got := storedPolicies(t, db, "1", "default"); len(got) != 0 {
+		t.Fatalf("stored policies = %v, want none", got)
+	}
+	if got := memoryPolicies(t, e, "1", "default"); len(got) != 0 {
+		t.Fatalf("memory policies = %v, want none", got)
+	}
+}
+
+func TestReplacePoliciesForRole_RollsBackOnFailure(t *testing.T) {
+	e, db := newTestEnforcer(t)
+
+	if _, err := e.AddPoliciesForRole("1", "default", [][]string{{"user", "read"}, {"user", "write"}}); err != nil {
+		t.Fatalf("AddPoliciesForRole: %v", err)
+	}
+	// 让新策略的写入在删除旧策略之后失败
+	if err := db.Exec(`CREATE TRIGGER reject_boom BEFORE INSERT ON casbin_rule
+		WHEN NEW.v2 = 'boom' BEGIN SELECT RAISE(ABORT, 'boom rejected'); END`).Error; err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	before := e.Stats().Version
+
+	err := e.ReplacePoliciesForRole("1", "default", [][]string{{"role", "read"}, {"boom", "read"}})
+	if err == nil || !strings.Contains(err.Error(), "boom rejected") {
+		t.Fatalf("ReplacePoliciesForRole error = %v, want trigger failure", err)
+	}
+
+	want := [][2]string{{"user", "read"}, {"user", "write"}}
+	if got := storedPolicies(t, db, "1", "default"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored policies = %v, want %v", got, want)
+	}
+	if got := memoryPolicies(t, e, "1", "default"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("memory policies = %v, want %v", got, want)
+	}
+	if after := e.Stats().Version; after != before {
+		t.Fatalf("policy version changed on failure: %d -> %d", before, after)
+	}
+}
+
+func TestNewEnforcer_CustomTableName(t *testing.T) {
+	const table = "casbin_rule_tenant"
+	e, db := newTestEnforcerWithConfig(t, Config{AutoSave: true, TableName: table})
+
+	if _, err := e.AddPolicy("1", "default", "user", "read"); err != nil {
+		t.Fatalf("AddPolicy: %v", err)
+	}
+	if err := e.ReplacePoliciesForRole("2", "default", [][]string{{"role", "read"}}); err != nil {
+		t.Fatalf("ReplacePoliciesForRole: %v", err)
+	}
+
+	var count int64
+	if err := db.Table(table).Where("ptype = ?", "p").Count(&count).Error; err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	if count != 2 {
+		t.Fatalf("%s has %d policies, want 2", table, count)
+	}
+	if !db.Migrator().HasIndex(table, "idx_"+table) {
+		t.Fatalf("unique index idx_%s not created", table)
+	}
+	if db.Migrator().HasTable(DefaultTableName) {
+		t.Fatalf("default table %s should not be created", DefaultTableName)
+	}
+
+	// 重新创建的 enforcer 从同一张表加载策略
+	reloaded, err := NewEnforcer(db, Config{TableName: table}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewEnforcer: %v", err)
+	}
+	if ok, err := reloaded.Enforce("2", "default", "role", "read"); err != nil || !ok {
+		t.Fatalf("Enforce after reload = %v, %v; want true", ok, err)
+	}
+}
+
+func TestValidateTableName(t *testing.T) {
+	for _, name := range []string{"casbin_rule", "casbin_rule_tenant2", "_policies"} {
+		if err := ValidateTableName(name); err != nil {
+			t.Errorf("ValidateTableName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "Casbin", "1rules", "public.casbin_rule", "rules; DROP TABLE users", strings.Repeat("a", 64)} {
+		if err := ValidateTableName(name); err == nil {
+			t.Errorf("ValidateTableName(%q) = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package casbin
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// policyVersionID 策略版本表中唯一一行的主键
+const policyVersionID = 1
+
+// PolicyVersion 策略变更版本（单行表，表及其唯一的一行由数据库迁移 0004 创建）
+// 每次通过 Enforcer 修改策略时递增，自动加载时只有版本变化才全量重新加载；
+// 绕过 Enforcer 直接修改策略表（默认 casbin_rule）后需调用 BumpPolicyVersion，否则其他实例不会重新加载
+type PolicyVersion struct {
+	ID        uint      `gorm:"primaryKey"`
+	Version   int64     `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (PolicyVersion) TableName() string {
+	return "casbin_policy_version"
+}
+
+// ReloadStats 策略自动加载统计
+type ReloadStats struct {
+	AutoLoad       bool      `json:"auto_load"`            // 是否启用自动加载
+	Version        int64     `json:"version"`              // 当前内存中策略对应的版本
+	LastReloadAt   time.Time `json:"last_reload_at"`       // 最近一次全量加载的时间
+	LastReloadMs   int64     `json:"last_reload_ms"`       // 最近一次全量加载的耗时（毫秒）
+	LastCheckAt    time.Time `json:"last_check_at"`        // 最近一次检查版本的时间
+	Reloads        int64     `json:"reloads"`              // 自启动以来的全量加载次数
+	SkippedReloads int64     `json:"skipped_reloads"`      // 版本未变化而跳过的次数
+	LastError      string    `json:"last_error,omitempty"` // 最近一次检查或加载失败的错误
+	LastErrorAt    time.Time `json:"last_error_at"`        // 最近一次失败的时间
+}
+
+// reloadState 版本与加载统计（与策略读写锁分离，读取统计不阻塞鉴权）
+type reloadState struct {
+	mu    sync.Mutex
+	stats ReloadStats
+}
+
+func (s *reloadState) snapshot() ReloadStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *reloadState) version() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats.Version
+}
+
+func (s *reloadState) reloaded(version int64, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.stats.Version = version
+	s.stats.LastReloadAt = now
+	s.stats.LastCheckAt = now
+	s.stats.LastReloadMs = duration.Milliseconds()
+	s.stats.Reloads++
+}
+
+func (s *reloadState) skipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastCheckAt = time.Now()
+	s.stats.SkippedReloads++
+}
+
+func (s *reloadState) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastError = err.Error()
+	s.stats.LastErrorAt = time.Now()
+}
+
+// advance 本实例修改策略后推进已知版本：新版本恰为已知版本+1 时说明期间没有其他实例修改，
+// 内存中的策略已是最新，下一次自动加载无需重新加载
+func (s *reloadState) advance(version int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version == s.stats.Version+1 {
+		s.stats.Version = version
+	}
+}
+
+// readPolicyVersion 读取当前策略版本
+func readPolicyVersion(db *gorm.DB) (int64, error) {
+	var pv PolicyVersion
+	if err := db.Select("version").First(&pv, policyVersionID).Error; err != nil {
+		return 0, err
+	}
+	return pv.Version, nil
+}
+
+// BumpPolicyVersion 递增策略版本并返回新版本，使所有实例在下一次自动加载时全量重新加载
+func BumpPolicyVersion(db *gorm.DB) (int64, error) {
+	var pv PolicyVersion
+	err := db.Model(&pv).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}}).
+		Where("id = ?", policyVersionID).
+		Updates(map[string]interface{}{
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		}).Error
+	return pv.Version, err
+}
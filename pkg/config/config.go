@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -11,16 +14,23 @@ import (
 // 包含服务器、日志、数据库、Redis、认证、限流、权限、上传、队列、审计日志等模块配置
 // 支持通过 NOVA_ 前缀的环境变量覆盖配置项
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`    // 服务器配置
-	Logger    LoggerConfig    `mapstructure:"logger"`    // 日志配置
-	DB        DBConfig        `mapstructure:"database"`  // 数据库配置
-	Redis     RedisConfig     `mapstructure:"redis"`     // Redis配置
-	Auth      AuthConfig      `mapstructure:"auth"`      // 认证配置
-	RateLimit RateLimitConfig `mapstructure:"ratelimit"` // 限流配置
-	Casbin    CasbinConfig    `mapstructure:"casbin"`    // Casbin权限配置
-	Upload    UploadConfig    `mapstructure:"upload"`    // 文件上传配置
-	Queue     QueueConfig     `mapstructure:"queue"`     // 队列配置
-	AuditLog  AuditLogConfig  `mapstructure:"audit_log"` // 审计日志配置
+	Server      ServerConfig      `mapstructure:"server"`      // 服务器配置
+	Logger      LoggerConfig      `mapstructure:"logger"`      // 日志配置
+	DB          DBConfig          `mapstructure:"database"`    // 数据库配置
+	Redis       RedisConfig       `mapstructure:"redis"`       // Redis配置
+	Auth        AuthConfig        `mapstructure:"auth"`        // 认证配置
+	User        UserConfig        `mapstructure:"user"`        // 用户资料配置
+	RateLimit   RateLimitConfig   `mapstructure:"ratelimit"`   // 限流配置
+	Casbin      CasbinConfig      `mapstructure:"casbin"`      // Casbin权限配置
+	Permission  PermissionConfig  `mapstructure:"permission"`  // 权限查询配置
+	RBAC        RBACConfig        `mapstructure:"rbac"`        // 角色等级等RBAC规则配置
+	Upload      UploadConfig      `mapstructure:"upload"`      // 文件上传配置
+	Queue       QueueConfig       `mapstructure:"queue"`       // 队列配置
+	AuditLog    AuditLogConfig    `mapstructure:"audit_log"`   // 审计日志配置
+	Captcha     CaptchaConfig     `mapstructure:"captcha"`     // 验证码配置
+	Mail        MailConfig        `mapstructure:"mail"`        // 邮件发送配置
+	Security    SecurityConfig    `mapstructure:"security"`    // 数据加密配置
+	Maintenance MaintenanceConfig `mapstructure:"maintenance"` // 维护模式配置
 }
 
 // ServerConfig 服务器配置
@@ -28,17 +38,52 @@ type ServerConfig struct {
 	Host string `mapstructure:"host"` // 监听地址，如 "0.0.0.0" 或 "127.0.0.1"
 	Port int    `mapstructure:"port"` // 监听端口，默认8080
 	Mode string `mapstructure:"mode"` // 运行模式：debug/release/test
+
+	SlowRequestMs int `mapstructure:"slow_request_ms"` // 慢请求阈值（毫秒，默认1000），超过时记录 warn 日志，0 表示关闭
+
+	// 客户端 IP：仅当 TCP 对端位于可信代理网段时才采信 X-Forwarded-For，未配置时直接使用 TCP 对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // 可信反向代理 IP 或 CIDR，如 10.0.0.0/8
+
+	// 依赖耗时：按数据库、缓存、鉴权汇总单个请求的耗时，以 Server-Timing 响应头返回（会向客户端暴露内部耗时，建议仅在诊断时开启）
+	TimingHeader bool `mapstructure:"timing_header"` // 是否返回 Server-Timing 响应头
+	TimingLog    bool `mapstructure:"timing_log"`    // 开启 timing_header 时是否同时以 debug 级别记录各依赖耗时
+
+	// 全局并发限制：处理中的请求达到上限后，新请求短暂排队，超时返回 503（健康检查不受限制）
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"` // 最大并发请求数，0 表示不限制
+	ConcurrencyQueueMs    int `mapstructure:"concurrency_queue_ms"`    // 并发已满时的最长排队时间（毫秒，默认100），0 表示立即拒绝
+	ConcurrencyRetryAfter int `mapstructure:"concurrency_retry_after"` // 拒绝时 Retry-After 响应头的秒数（默认1）
+
+	// TLS：证书与私钥均配置时以 HTTPS 方式监听
+	TLSCertFile   string `mapstructure:"tls_cert_file"`   // 证书文件路径
+	TLSKeyFile    string `mapstructure:"tls_key_file"`    // 私钥文件路径
+	TLSMinVersion string `mapstructure:"tls_min_version"` // 最低 TLS 版本：1.2/1.3（默认1.2）
+
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"` // 安全响应头
+}
+
+// SecurityHeadersConfig 安全响应头配置，各响应头取值为空表示不设置
+type SecurityHeadersConfig struct {
+	Enabled               bool   `mapstructure:"enabled"`                 // 是否启用（默认 true）
+	ContentTypeOptions    string `mapstructure:"content_type_options"`    // X-Content-Type-Options（默认 nosniff）
+	FrameOptions          string `mapstructure:"frame_options"`           // X-Frame-Options（默认 DENY）
+	ReferrerPolicy        string `mapstructure:"referrer_policy"`         // Referrer-Policy（默认 strict-origin-when-cross-origin）
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"` // Content-Security-Policy（默认不设置，Swagger UI 依赖内联脚本）
+	HSTSMaxAge            int    `mapstructure:"hsts_max_age"`            // Strict-Transport-Security 的 max-age（秒，默认31536000），0 表示不下发
+	HSTSIncludeSubdomains bool   `mapstructure:"hsts_include_subdomains"` // HSTS 是否包含子域名
+	HSTSPreload           bool   `mapstructure:"hsts_preload"`            // HSTS 是否声明 preload
 }
 
 // LoggerConfig 日志配置
 type LoggerConfig struct {
-	Level      string `mapstructure:"level"`       // 日志级别：debug/info/warn/error
-	Format     string `mapstructure:"format"`      // 日志格式：json/text
-	Output     string `mapstructure:"output"`      // 输出目标：stdout/file/both
-	FilePath   string `mapstructure:"file_path"`   // 日志文件路径
-	MaxSize    int    `mapstructure:"max_size"`    // 单个日志文件最大大小（MB）
-	MaxBackups int    `mapstructure:"max_backups"` // 保留的旧日志文件数量
-	MaxAge     int    `mapstructure:"max_age"`     // 日志文件保留天数
+	Level        string `mapstructure:"level"`         // 日志级别：debug/info/warn/error
+	Format       string `mapstructure:"format"`        // 日志格式：json/text
+	StdoutFormat string `mapstructure:"stdout_format"` // 标准输出的日志格式（可选，为空时使用 format）
+	FileFormat   string `mapstructure:"file_format"`   // 日志文件的日志格式（可选，为空时使用 format）
+	Output       string `mapstructure:"output"`        // 输出目标：stdout/file/both
+	FilePath     string `mapstructure:"file_path"`     // 日志文件路径
+	MaxSize      int    `mapstructure:"max_size"`      // 单个日志文件最大大小（MB）
+	MaxBackups   int    `mapstructure:"max_backups"`   // 保留的旧日志文件数量
+	MaxAge       int    `mapstructure:"max_age"`       // 日志文件保留天数
 }
 
 // DBConfig 数据库配置
@@ -52,6 +97,9 @@ type DBConfig struct {
 	Charset  string `mapstructure:"charset"`  // 字符集
 	MaxIdle  int    `mapstructure:"max_idle"` // 最大空闲连接数
 	MaxOpen  int    `mapstructure:"max_open"` // 最大打开连接数
+
+	AutoMigrate  bool `mapstructure:"auto_migrate"`  // 启动时自动执行待执行的迁移（默认开启）；关闭后由 migrate 命令执行，未完成前 /ready 返回 503
+	CheckIndexes bool `mapstructure:"check_indexes"` // 启动时检查热点查询依赖的索引（默认开启），缺失时记录 warn 日志
 }
 
 // AuthConfig 认证配置
@@ -59,7 +107,49 @@ type AuthConfig struct {
 	JWTSecret            string `mapstructure:"jwt_secret"`             // JWT密钥，生产环境必须修改
 	AccessTokenDuration  int    `mapstructure:"access_token_duration"`  // 访问令牌有效期（秒），默认2小时
 	RefreshTokenDuration int    `mapstructure:"refresh_token_duration"` // 刷新令牌有效期（秒），默认7天
+	RefreshTokenRotation bool   `mapstructure:"refresh_token_rotation"` // 刷新时轮换刷新令牌，并将已轮换令牌的重放视为令牌被盗
+	RefreshReuseGrace    int    `mapstructure:"refresh_reuse_grace"`    // 轮换后的宽限期（秒，默认10），期内重复提交旧令牌只拒绝不撤销令牌族
 	Issuer               string `mapstructure:"issuer"`                 // JWT签发者标识
+
+	// JWT 密钥轮换：第一个启用的密钥签名，所有启用的密钥都可验证；配置后 jwt_secret 只用于验证不带 kid 的旧令牌（可为空）
+	Keys []JWTKeyConfig `mapstructure:"keys"`
+
+	// Cookie 模式：令牌写入 HttpOnly Cookie，响应体不再返回令牌，防止 XSS 窃取
+	UseCookies        bool   `mapstructure:"use_cookies"`         // 是否通过 HttpOnly Cookie 下发令牌
+	CookieDomain      string `mapstructure:"cookie_domain"`       // Cookie 域（可选）
+	CookieSecure      bool   `mapstructure:"cookie_secure"`       // 是否仅 HTTPS 发送（默认 true，本地 HTTP 调试时可关闭）
+	CookieSameSite    string `mapstructure:"cookie_same_site"`    // SameSite 策略：strict/lax/none（默认 strict）
+	RefreshCookiePath string `mapstructure:"refresh_cookie_path"` // 刷新令牌 Cookie 的路径（默认 /api/v1/auth，需与认证路由前缀一致）
+
+	// 令牌校验接口（/auth/introspect）访问控制，供网关等内部服务使用
+	IntrospectToken      string   `mapstructure:"introspect_token"`       // 服务间调用凭证（X-Service-Token）
+	IntrospectAllowedIPs []string `mapstructure:"introspect_allowed_ips"` // 来源 IP 白名单，支持 CIDR
+
+	// 令牌黑名单（登出/强制下线）
+	BlacklistStore           string `mapstructure:"blacklist_store"`            // 存储：redis/database（默认 redis）
+	BlacklistFailureMode     string `mapstructure:"blacklist_failure_mode"`     // 写入失败策略：closed 拒绝登出/open 记录日志后放行（默认 closed）
+	BlacklistCleanupInterval int    `mapstructure:"blacklist_cleanup_interval"` // database 存储的过期记录清理间隔（秒，默认3600）
+
+	// 免密登录链接（需同时启用队列，邮件由队列任务发送）
+	MagicLinkEnabled    bool   `mapstructure:"magic_link_enabled"`     // 是否启用免密登录链接
+	MagicLinkURL        string `mapstructure:"magic_link_url"`         // 邮件中的链接地址，令牌以 token 查询参数附加
+	MagicLinkTTL        int    `mapstructure:"magic_link_ttl"`         // 链接有效期（秒，默认900）
+	MagicLinkRateLimit  int    `mapstructure:"magic_link_rate_limit"`  // 同一邮箱在窗口内最多申请次数（默认3）
+	MagicLinkRateWindow int    `mapstructure:"magic_link_rate_window"` // 申请频率统计窗口（秒，默认3600）
+
+	// 按用户名的渐进式登录延迟：每次失败后延迟 base * multiplier^(n-1) 再响应，不超过 max
+	LoginThrottleEnabled    bool    `mapstructure:"login_throttle_enabled"`    // 是否启用登录失败延迟
+	LoginThrottleBaseDelay  int     `mapstructure:"login_throttle_base_delay"` // 首次失败的延迟（毫秒，默认500）
+	LoginThrottleMaxDelay   int     `mapstructure:"login_throttle_max_delay"`  // 延迟上限（毫秒，默认10000）
+	LoginThrottleMultiplier float64 `mapstructure:"login_throttle_multiplier"` // 每次失败的延迟倍数（默认2）
+	LoginThrottleWindow     int     `mapstructure:"login_throttle_window"`     // 失败计数保留时长（秒，默认900），期间无失败则清零
+}
+
+// JWTKeyConfig JWT 签名密钥
+type JWTKeyConfig struct {
+	ID     string `mapstructure:"id"`     // 密钥ID，写入令牌头部的 kid
+	Secret string `mapstructure:"secret"` // HMAC 密钥
+	Active bool   `mapstructure:"active"` // 是否启用，停用后该密钥签发的令牌立即失效
 }
 
 // RedisConfig Redis配置
@@ -75,20 +165,82 @@ type RedisConfig struct {
 
 // RateLimitConfig 限流配置
 type RateLimitConfig struct {
-	Enabled    bool   `mapstructure:"enabled"`     // 是否启用限流
-	Algorithm  string `mapstructure:"algorithm"`   // 限流算法：token_bucket（令牌桶）或 sliding_window（滑动窗口）
-	IPLimit    int    `mapstructure:"ip_limit"`    // 每个IP的请求限制（次数）
-	IPWindow   int    `mapstructure:"ip_window"`   // IP限流时间窗口（秒）
-	UserLimit  int    `mapstructure:"user_limit"`  // 每个用户的请求限制（次数）
-	UserWindow int    `mapstructure:"user_window"` // 用户限流时间窗口（秒）
+	Enabled    bool            `mapstructure:"enabled"`     // 是否启用限流
+	Algorithm  string          `mapstructure:"algorithm"`   // 限流算法：token_bucket（令牌桶）或 sliding_window（滑动窗口）
+	IPLimit    int             `mapstructure:"ip_limit"`    // 每个IP的请求限制（次数）
+	IPWindow   int             `mapstructure:"ip_window"`   // IP限流时间窗口（秒）
+	UserLimit  int             `mapstructure:"user_limit"`  // 每个用户的请求限制（次数）
+	UserWindow int             `mapstructure:"user_window"` // 用户限流时间窗口（秒）
+	Rules      []RateLimitRule `mapstructure:"rules"`       // 路由级限流规则（在全局用户限流之外单独计数）
+}
+
+// RateLimitRule 路由级限流规则
+type RateLimitRule struct {
+	Method    string `mapstructure:"method"`    // HTTP 方法，为空匹配所有方法
+	Path      string `mapstructure:"path"`      // 路由模板，如 /api/v1/files/:id/download
+	Limit     int    `mapstructure:"limit"`     // 窗口内允许的请求次数
+	Window    int    `mapstructure:"window"`    // 时间窗口（秒）
+	Dimension string `mapstructure:"dimension"` // 限流维度：ip/user/api/user_api，默认 user_api
 }
 
 // CasbinConfig Casbin权限配置
 type CasbinConfig struct {
-	ModelPath    string `mapstructure:"model_path"`     // RBAC 模型文件路径（rbac_model.conf）
+	ModelPath    string `mapstructure:"model_path"`     // RBAC 模型文件路径（rbac_model.conf），为空或文件不存在时使用内置默认模型
+	Model        string `mapstructure:"model"`          // 内联模型定义（可选），优先于 model_path
+	TableName    string `mapstructure:"table_name"`     // 策略表名（默认 casbin_rule），同一数据库部署多套策略（不同模型或实例组）时各自配置独立的表
 	AutoSave     bool   `mapstructure:"auto_save"`      // 是否自动保存策略到数据库
 	AutoLoad     bool   `mapstructure:"auto_load"`      // 是否定期从数据库重新加载策略（用于多实例同步）
 	AutoLoadTick int    `mapstructure:"auto_load_tick"` // 自动加载策略的间隔时间（秒）
+
+	ReloadOnChange bool `mapstructure:"reload_on_change"` // 自动加载时仅在策略版本变化后才全量加载（默认开启），关闭后每个周期都全量加载
+
+	ReconcileOnStart string `mapstructure:"reconcile_on_start"` // 启动时核对策略与权限表：off（默认）、log 只记录差异、repair 记录并修复
+}
+
+// PermissionConfig 权限查询配置
+// 限制不分页的权限列表接口返回的数据量，避免权限过多时响应过大
+type PermissionConfig struct {
+	MaxListSize  int `mapstructure:"max_list_size"`  // 按类型查询等不分页接口的最大返回条数，超出时截断并返回 X-Result-Truncated 响应头
+	MaxTreeDepth int `mapstructure:"max_tree_depth"` // 权限树的最大深度，depth 查询参数不能超过该值
+}
+
+// RBACConfig RBAC 规则配置
+type RBACConfig struct {
+	MaxRoleLevel        int            `mapstructure:"max_role_level"`         // 角色等级上限（默认100），创建/更新角色时 level 不能超过该值
+	DomainMaxRoleLevels map[string]int `mapstructure:"domain_max_role_levels"` // 按域覆盖的角色等级上限（如租户域限制为 50），未配置的域使用 max_role_level
+	CascadeUserDelete   bool           `mapstructure:"cascade_user_delete"`    // 删除用户时是否级联撤销其在所有域的角色、会话与权限缓存（默认 true）
+	SuperAdminUserIDs   []uint         `mapstructure:"super_admin_user_ids"`   // 指定的超级管理员用户ID，不受角色等级过滤（用于初始化阶段尚未分配角色的管理员）
+	DefaultRoles        []string       `mapstructure:"default_roles"`          // 注册时自动分配的 default 域角色名，启动时校验角色是否存在
+
+	// 角色权限数量上限：权限过多会拖慢权限解析与前端展示，分配后的权限数超过上限时拒绝，0 表示不限制
+	MaxPermissionsPerRole       int            `mapstructure:"max_permissions_per_role"`        // 单个角色的权限数上限（默认0，不限制）
+	DomainMaxPermissionsPerRole map[string]int `mapstructure:"domain_max_permissions_per_role"` // 按域覆盖的权限数上限，未配置的域使用 max_permissions_per_role
+
+	ExpiredRolePurgeInterval int `mapstructure:"expired_role_purge_interval"` // 清理过期临时角色分配的间隔（秒，默认60）
+
+	RejectPartialAssignment bool `mapstructure:"reject_partial_assignment"` // 批量分配角色/权限时，部分ID不存在或不属于目标域则拒绝整个请求（默认 false：跳过这些ID并在结果中列出）
+}
+
+// IsSuperAdminUser 判断用户是否为配置指定的超级管理员
+func (c *RBACConfig) IsSuperAdminUser(userID uint) bool {
+	return slices.Contains(c.SuperAdminUserIDs, userID)
+}
+
+// MaxRoleLevelFor 返回指定域的角色等级上限
+func (c *RBACConfig) MaxRoleLevelFor(domain string) int {
+	// viper 读取的 map 键统一为小写，按小写域名查找
+	if level, ok := c.DomainMaxRoleLevels[strings.ToLower(domain)]; ok {
+		return level
+	}
+	return c.MaxRoleLevel
+}
+
+// MaxPermissionsPerRoleFor 返回指定域的角色权限数上限，0 表示不限制；域名不区分大小写
+func (c *RBACConfig) MaxPermissionsPerRoleFor(domain string) int {
+	if limit, ok := c.DomainMaxPermissionsPerRole[strings.ToLower(domain)]; ok {
+		return limit
+	}
+	return c.MaxPermissionsPerRole
 }
 
 // UploadConfig 文件上传配置
@@ -99,15 +251,50 @@ type UploadConfig struct {
 	AllowedTypes []string `mapstructure:"allowed_types"` // 允许的 MIME 类型列表（如 image/jpeg）
 	AllowedExts  []string `mapstructure:"allowed_exts"`  // 允许的文件扩展名列表（如 .jpg）
 
+	MaxFilenameLength int `mapstructure:"max_filename_length"` // 原始文件名最大字符数（默认255，与 files.original_name 列宽一致），超出时截断并保留扩展名
+
+	ChunkUploadTTL int `mapstructure:"chunk_upload_ttl"` // 分片上传进度保留时间（秒，默认86400），每收到一个分片重新计时，过期后分片被清理
+
+	MaxUploadBPS int64 `mapstructure:"max_upload_bps"` // 单个上传的最大读取速率（字节/秒），超出时放慢读取而不拒绝，0 表示不限制
+
+	ContentAddressed bool `mapstructure:"content_addressed"` // 按内容 Hash 存储物理文件（objects/ab/cd/<hash>），相同内容只保存一份，最后一个引用删除时删除对象
+
+	// 存储配额（MB，0 表示不限制）
+	UserQuota            int64            `mapstructure:"user_quota"`             // 单用户全部文件的总配额
+	CategoryQuotas       map[string]int64 `mapstructure:"category_quotas"`        // 按分类的单用户配额（如 video: 5120、document: 500）
+	DefaultCategoryQuota int64            `mapstructure:"default_category_quota"` // 未在 category_quotas 中列出的分类（含 other）的配额
+
 	// 本地存储配置
 	LocalPath string `mapstructure:"local_path"` // 本地存储路径（相对于项目根目录）
 	LocalURL  string `mapstructure:"local_url"`  // 本地访问 URL 前缀（用于生成下载链接）
+	TempDir   string `mapstructure:"temp_dir"`   // 临时文件目录（缩略图等中间文件），默认使用系统临时目录
+
+	// 压缩存储配置
+	CompressTypes []string `mapstructure:"compress_types"` // 需要 gzip 压缩存储的 MIME 前缀（如 text/、application/json），为空则不压缩
 
 	// 缩略图配置
-	EnableThumbnail  bool `mapstructure:"enable_thumbnail"`  // 是否为图片生成缩略图
-	ThumbnailWidth   int  `mapstructure:"thumbnail_width"`   // 缩略图宽度（像素）
-	ThumbnailHeight  int  `mapstructure:"thumbnail_height"`  // 缩略图高度（像素）
-	ThumbnailQuality int  `mapstructure:"thumbnail_quality"` // 缩略图质量（1-100）
+	EnableThumbnail      bool   `mapstructure:"enable_thumbnail"`       // 是否为图片生成缩略图
+	ThumbnailWidth       int    `mapstructure:"thumbnail_width"`        // 缩略图最大宽度（像素）
+	ThumbnailHeight      int    `mapstructure:"thumbnail_height"`       // 缩略图最大高度（像素）
+	ThumbnailQuality     int    `mapstructure:"thumbnail_quality"`      // 缩略图质量（1-100）
+	ThumbnailMode        string `mapstructure:"thumbnail_mode"`         // 缩略图模式：fit（等比缩放）、fill（等比缩放并留白补齐）、crop（缩放后居中裁剪）
+	ThumbnailWorkers     int    `mapstructure:"thumbnail_workers"`      // 同时生成缩略图的最大数量（默认 CPU 核数）
+	ThumbnailWaitTimeout int    `mapstructure:"thumbnail_wait_timeout"` // 等待缩略图处理槽位的超时（毫秒，默认2000），超时跳过缩略图
+
+	// 下载限制
+	MaxConcurrentDownloadsPerUser int `mapstructure:"max_concurrent_downloads_per_user"` // 单用户同时进行的下载数上限（0 表示不限制）
+	DownloadSlotTimeout           int `mapstructure:"download_slot_timeout"`             // 下载槽位最长持有时间（秒，默认3600），异常未释放的槽位到期自动回收
+
+	// 公开分享链接：HMAC 签名的限时令牌，无需登录即可下载（GET /api/v1/public/files/:token）
+	PublicLinkEnabled    bool   `mapstructure:"public_link_enabled"`     // 是否启用公开分享链接
+	PublicLinkSecret     string `mapstructure:"public_link_secret"`      // 签名密钥，更换后已发出的链接全部失效
+	PublicLinkBaseURL    string `mapstructure:"public_link_base_url"`    // 分享链接的对外访问地址，如 https://files.example.com（不使用请求的 Host 头拼接）
+	PublicLinkMaxTTL     int    `mapstructure:"public_link_max_ttl"`     // 链接最长有效期（秒，默认604800）
+	PublicLinkRateLimit  int    `mapstructure:"public_link_rate_limit"`  // 同一 IP 在窗口内最多访问次数（默认60）
+	PublicLinkRateWindow int    `mapstructure:"public_link_rate_window"` // 访问频率统计窗口（秒，默认60）
+
+	// 默认头像配置
+	GenerateDefaultAvatar bool `mapstructure:"generate_default_avatar"` // 创建用户时未提供头像则按用户名生成 identicon 默认头像
 
 	// OSS 配置（阿里云对象存储）
 	OSSEndpoint        string `mapstructure:"oss_endpoint"`          // OSS访问端点（如 oss-cn-hangzhou.aliyuncs.com）
@@ -125,32 +312,135 @@ type UploadConfig struct {
 	S3UseSSL          bool   `mapstructure:"s3_use_ssl"`           // 是否使用SSL连接
 }
 
+// CategoryQuotaFor 返回指定分类的单用户配额（MB），未单独配置的分类使用默认配额
+func (c *UploadConfig) CategoryQuotaFor(category string) int64 {
+	if quota, ok := c.CategoryQuotas[category]; ok {
+		return quota
+	}
+	return c.DefaultCategoryQuota
+}
+
 // QueueConfig 队列配置
 type QueueConfig struct {
 	Enabled      bool   `mapstructure:"enabled"`       // 是否启用异步任务队列
 	Workers      int    `mapstructure:"workers"`       // Worker 并发数量
 	MaxRetry     int    `mapstructure:"max_retry"`     // 任务失败后的最大重试次数
-	RetryDelay   int    `mapstructure:"retry_delay"`   // 重试延迟时间（秒）
+	RetryDelay   int    `mapstructure:"retry_delay"`   // 重试延迟时间（秒），指数退避时为首次重试的等待时间
 	RedisPrefix  string `mapstructure:"redis_prefix"`  // Redis 键前缀（用于命名空间隔离）
 	PollInterval int    `mapstructure:"poll_interval"` // 队列轮询间隔（秒）
+
+	BackoffStrategy string `mapstructure:"backoff_strategy"` // 重试退避策略：fixed（固定 retry_delay）、exponential（retry_delay 起按 2 倍递增），任务可单独指定
+	RetryMaxDelay   int    `mapstructure:"retry_max_delay"`  // 指数退避单次等待上限（秒，默认3600）
+
+	DelayedBatchSize  int `mapstructure:"delayed_batch_size"`   // 每批从延迟队列移出的到期任务数（默认100）
+	DelayedMaxPerTick int `mapstructure:"delayed_max_per_tick"` // 每次扫描最多移出的到期任务数，剩余留待下次扫描（默认1000）
+
+	// 事务性发件箱：业务事务内写入 outbox_events，由后台轮询器投递到队列
+	OutboxPollInterval int `mapstructure:"outbox_poll_interval"` // 发件箱轮询间隔（秒，默认5）
+	OutboxBatchSize    int `mapstructure:"outbox_batch_size"`    // 每轮最多投递的事件数（默认100）
+	OutboxMaxAttempts  int `mapstructure:"outbox_max_attempts"`  // 单个事件最大投递尝试次数，超出后标记为 failed（默认10）
+
+	// 积压告警：队列长度持续超过阈值时，健康检查中的 queue 组件报告 degraded
+	AlertThreshold     int `mapstructure:"alert_threshold"`      // 告警阈值（待处理任务数，默认1000，0 表示关闭告警）
+	AlertDuration      int `mapstructure:"alert_duration"`       // 超过阈值持续多久后告警（秒，默认300）
+	AlertCheckInterval int `mapstructure:"alert_check_interval"` // 后台采样间隔（秒，默认30）
+
+	// 异步导入（POST /users/import/async）
+	ImportBatchSize int `mapstructure:"import_batch_size"` // 每个事务提交的行数（默认500）
+	ImportMaxErrors int `mapstructure:"import_max_errors"` // 失败行数超过该值时中止导入（默认0 不中止），请求参数 max_errors 可覆盖
 }
 
 // AuditLogConfig 审计日志配置
 type AuditLogConfig struct {
-	Enabled         bool     `mapstructure:"enabled"`          // 是否启用审计日志
-	LogRequest      bool     `mapstructure:"log_request"`      // 是否记录请求体内容
-	LogResponse     bool     `mapstructure:"log_response"`     // 是否记录响应体内容（通常较大，建议关闭）
-	MaxBodySize     int      `mapstructure:"max_body_size"`    // 请求/响应体最大记录大小（字节）
-	ExcludePaths    []string `mapstructure:"exclude_paths"`    // 排除的路径列表（这些路径不记录审计日志）
-	IncludeActions  []string `mapstructure:"include_actions"`  // 只记录指定动作（为空则全部记录）【注：当前中间件暂未实现此过滤】
-	SensitiveFields []string `mapstructure:"sensitive_fields"` // 敏感字段名称列表（需要脱敏处理，如 password、token）
+	Enabled             bool     `mapstructure:"enabled"`               // 是否启用审计日志
+	LogRequest          bool     `mapstructure:"log_request"`           // 是否记录请求体内容
+	LogResponse         bool     `mapstructure:"log_response"`          // 是否记录响应体内容（通常较大，建议关闭）
+	MaxBodySize         int      `mapstructure:"max_body_size"`         // 请求/响应体最大记录大小（字节）
+	CaptureContentTypes []string `mapstructure:"capture_content_types"` // 允许记录请求/响应体的内容类型前缀（为空时默认 application/json 和 text/）
+	ExcludePaths        []string `mapstructure:"exclude_paths"`         // 排除的路径列表（这些路径不记录审计日志）
+	IncludeActions      []string `mapstructure:"include_actions"`       // 只记录指定动作（为空则全部记录）【注：当前中间件暂未实现此过滤】
+	SensitiveFields     []string `mapstructure:"sensitive_fields"`      // 敏感字段名称列表（需要脱敏处理，如 password、token）
+	StatsConcurrency    int      `mapstructure:"stats_concurrency"`     // 统计接口并发查询数上限（默认3）
+	EncryptBodies       bool     `mapstructure:"encrypt_bodies"`        // 是否加密存储请求/响应体（AES-GCM，需配置 security.encryption_key）
+
+	// 导出：按批读取并以分块传输流式写出 JSON 数组
+	ExportBatchSize int `mapstructure:"export_batch_size"` // 每批读取并刷新输出的记录数（默认500）
+	ExportMaxRows   int `mapstructure:"export_max_rows"`   // 单次导出的最大行数（默认100000），0 表示不限制
+
+	// 自动清理（物理删除）：按保留天数与最大行数清理，任一条件触发即删除
+	RetentionDays  int `mapstructure:"retention_days"`   // 保留天数，0 表示不按时间清理
+	MaxRows        int `mapstructure:"max_rows"`         // 最大行数，超出时从最旧的记录删除到上限，0 表示不限制
+	PruneInterval  int `mapstructure:"prune_interval"`   // 清理任务执行间隔（秒，默认3600）
+	PruneBatchSize int `mapstructure:"prune_batch_size"` // 每批删除的行数（默认1000）
+
+	ActionOverrides []AuditActionOverride `mapstructure:"action_overrides"` // 按路由覆盖动作/资源，优先于按方法和路径推断
+}
+
+// AuditActionOverride 审计动作覆盖规则
+type AuditActionOverride struct {
+	Method   string `mapstructure:"method"`   // HTTP 方法，为空或 "*" 表示任意方法
+	Route    string `mapstructure:"route"`    // Echo 路由模板，如 /api/v1/roles/:id/permissions/update
+	Action   string `mapstructure:"action"`   // 记录的动作
+	Resource string `mapstructure:"resource"` // 记录的资源类型，为空时沿用推断结果
+}
+
+// UserConfig 用户资料配置
+type UserConfig struct {
+	ProfileFields  []string `mapstructure:"profile_fields"`   // 自定义资料（profile）允许的字段名，为空表示不允许写入自定义资料
+	ProfileMaxSize int      `mapstructure:"profile_max_size"` // 自定义资料序列化后的最大字节数（默认4096）
+
+	// 修改邮箱需通过发送到新邮箱的确认链接生效（依赖队列投递邮件任务）
+	EmailChangeURL string `mapstructure:"email_change_url"` // 确认链接地址，令牌以 token 查询参数附加；为空时不允许修改邮箱
+	EmailChangeTTL int    `mapstructure:"email_change_ttl"` // 确认链接有效期（秒，默认86400）
+}
+
+// CaptchaConfig 登录验证码配置
+// 同一 IP 登录失败次数达到阈值后，登录请求必须携带验证码令牌
+type CaptchaConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`    // 是否启用验证码
+	Provider  string `mapstructure:"provider"`   // 验证码服务：hcaptcha 或 recaptcha
+	SecretKey string `mapstructure:"secret_key"` // 服务端校验密钥
+	Threshold int    `mapstructure:"threshold"`  // 触发验证码的登录失败次数
+	Window    int    `mapstructure:"window"`     // 失败次数统计窗口（秒）
+}
+
+// MailConfig 邮件发送配置（SMTP）
+// 免密登录链接、邮箱修改确认等邮件由队列 Worker 通过该配置发送
+type MailConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`  // 是否启用邮件发送
+	Host     string `mapstructure:"host"`     // SMTP 服务器地址
+	Port     int    `mapstructure:"port"`     // SMTP 端口（默认587）
+	Username string `mapstructure:"username"` // 认证用户名，为空时不认证
+	Password string `mapstructure:"password"` // 认证密码（建议通过 NOVA_MAIL_PASSWORD 设置）
+	From     string `mapstructure:"from"`     // 发件人，如 "Nova <no-reply@example.com>"
+	TLSMode  string `mapstructure:"tls_mode"` // 加密方式：starttls（默认）、tls（隐式 TLS，通常为 465 端口）、none（仅用于本地调试）
+	Timeout  int    `mapstructure:"timeout"`  // 连接与发送超时（秒，默认10）
+}
+
+// SecurityConfig 数据加密配置
+// 带 serializer:encrypted 标签的列（目前为审计日志请求/响应体）使用 AES-256-GCM 加密，密文格式为 enc:1:<密钥ID>:<base64>
+type SecurityConfig struct {
+	EncryptionKey     string            `mapstructure:"encryption_key"`      // 当前加密密钥（base64 编码的 32 字节，建议通过 NOVA_SECURITY_ENCRYPTION_KEY 设置）
+	EncryptionKeyID   string            `mapstructure:"encryption_key_id"`   // 当前密钥ID（默认 v1，小写字母、数字、_ 或 -），写入密文前缀
+	OldEncryptionKeys map[string]string `mapstructure:"old_encryption_keys"` // 轮换前的旧密钥（密钥ID -> 密钥），仅用于解密已有数据
+}
+
+// MaintenanceConfig 维护模式配置
+// 开启后除健康检查、维护模式管理接口与放行名单外的请求返回 503；管理接口（PUT /api/v1/admin/maintenance）的运行时设置优先于此处的 enabled
+type MaintenanceConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`        // 启动时是否处于维护模式
+	Message       string   `mapstructure:"message"`        // 默认提示信息，运行时开启未指定提示时使用
+	RetryAfter    int      `mapstructure:"retry_after"`    // 503 响应 Retry-After 的秒数（默认300）
+	AllowIPs      []string `mapstructure:"allow_ips"`      // 维护期间放行的来源 IP 或 CIDR（如运维网段）
+	AllowPaths    []string `mapstructure:"allow_paths"`    // 维护期间放行的请求路径，以 * 结尾时按前缀匹配（默认放行登录与刷新令牌，管理员可登录后关闭维护模式）
+	CheckInterval int      `mapstructure:"check_interval"` // 各实例读取运行时设置的间隔（毫秒，默认2000），0 表示每个请求都读取
 }
 
 var globalConfig *Config
 
 // Load 加载配置文件
 // 支持从指定路径加载配置文件，或自动搜索多个目录
-// 支持通过 NOVA_ 前缀的环境变量覆盖配置项（如 NOVA_SERVER_PORT）
+// 合并顺序（后者覆盖前者）：默认值 < 基础配置 config.yaml < 环境覆盖 config.{NOVA_ENV}.yaml < NOVA_ 前缀的环境变量（如 NOVA_SERVER_PORT）
 func Load(configPath string) (*Config, error) {
 	v := viper.New()
 
@@ -171,10 +461,85 @@ func Load(configPath string) (*Config, error) {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
+	// 默认值
+	v.SetDefault("server.slow_request_ms", 1000)
+	v.SetDefault("server.concurrency_queue_ms", 100)
+	v.SetDefault("server.concurrency_retry_after", 1)
+	v.SetDefault("server.tls_min_version", "1.2")
+	v.SetDefault("server.security_headers.enabled", true)
+	v.SetDefault("server.security_headers.content_type_options", "nosniff")
+	v.SetDefault("server.security_headers.frame_options", "DENY")
+	v.SetDefault("server.security_headers.referrer_policy", "strict-origin-when-cross-origin")
+	v.SetDefault("server.security_headers.hsts_max_age", 31536000)
+	v.SetDefault("database.auto_migrate", true)
+	v.SetDefault("database.check_indexes", true)
+	v.SetDefault("upload.temp_dir", os.TempDir())
+	v.SetDefault("auth.cookie_secure", true)
+	v.SetDefault("auth.refresh_reuse_grace", 10)
+	v.SetDefault("auth.cookie_same_site", "strict")
+	v.SetDefault("auth.refresh_cookie_path", "/api/v1/auth")
+	v.SetDefault("auth.blacklist_store", "redis")
+	v.SetDefault("auth.blacklist_failure_mode", "closed")
+	v.SetDefault("auth.blacklist_cleanup_interval", 3600)
+	v.SetDefault("auth.magic_link_ttl", 900)
+	v.SetDefault("auth.magic_link_rate_limit", 3)
+	v.SetDefault("auth.magic_link_rate_window", 3600)
+	v.SetDefault("auth.login_throttle_base_delay", 500)
+	v.SetDefault("auth.login_throttle_max_delay", 10000)
+	v.SetDefault("auth.login_throttle_multiplier", 2.0)
+	v.SetDefault("auth.login_throttle_window", 900)
+	v.SetDefault("mail.port", 587)
+	v.SetDefault("mail.tls_mode", "starttls")
+	v.SetDefault("mail.timeout", 10)
+	v.SetDefault("user.profile_max_size", 4096)
+	v.SetDefault("user.email_change_ttl", 86400)
+	v.SetDefault("queue.backoff_strategy", "fixed")
+	v.SetDefault("queue.retry_max_delay", 3600)
+	v.SetDefault("queue.delayed_batch_size", 100)
+	v.SetDefault("queue.delayed_max_per_tick", 1000)
+	v.SetDefault("queue.outbox_poll_interval", 5)
+	v.SetDefault("queue.outbox_batch_size", 100)
+	v.SetDefault("queue.outbox_max_attempts", 10)
+	v.SetDefault("queue.alert_threshold", 1000)
+	v.SetDefault("queue.alert_duration", 300)
+	v.SetDefault("queue.alert_check_interval", 30)
+	v.SetDefault("queue.import_batch_size", 500)
+	v.SetDefault("upload.download_slot_timeout", 3600)
+	v.SetDefault("upload.max_filename_length", 255)
+	v.SetDefault("upload.chunk_upload_ttl", 86400)
+	v.SetDefault("upload.public_link_max_ttl", 604800)
+	v.SetDefault("upload.public_link_rate_limit", 60)
+	v.SetDefault("upload.public_link_rate_window", 60)
+	v.SetDefault("audit_log.stats_concurrency", 3)
+	v.SetDefault("audit_log.export_batch_size", 500)
+	v.SetDefault("audit_log.export_max_rows", 100000)
+	v.SetDefault("audit_log.prune_interval", 3600)
+	v.SetDefault("audit_log.prune_batch_size", 1000)
+	v.SetDefault("security.encryption_key_id", "v1")
+	v.SetDefault("maintenance.message", "系统维护中，请稍后再试")
+	v.SetDefault("maintenance.retry_after", 300)
+	v.SetDefault("maintenance.allow_paths", []string{"/api/v1/auth/login", "/api/v1/auth/refresh"})
+	v.SetDefault("maintenance.check_interval", 2000)
+	v.SetDefault("permission.max_list_size", 500)
+	v.SetDefault("permission.max_tree_depth", 10)
+	v.SetDefault("casbin.table_name", "casbin_rule")
+	v.SetDefault("casbin.reconcile_on_start", "off")
+	v.SetDefault("casbin.reload_on_change", true)
+	v.SetDefault("rbac.max_role_level", 100)
+	v.SetDefault("rbac.cascade_user_delete", true)
+	v.SetDefault("rbac.max_permissions_per_role", 0)
+	v.SetDefault("rbac.expired_role_purge_interval", 60)
+	v.SetDefault("rbac.reject_partial_assignment", false)
+
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// 环境覆盖文件：NOVA_ENV=prod 时在基础配置同目录查找 config.prod.yaml 并合并
+	if err := mergeEnvOverlay(v); err != nil {
+		return nil, err
+	}
+
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -184,6 +549,35 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// mergeEnvOverlay 合并环境覆盖配置
+// 覆盖文件与基础配置同目录同扩展名，如 configs/config.yaml + NOVA_ENV=prod -> configs/config.prod.yaml；
+// 未设置 NOVA_ENV 或覆盖文件不存在时跳过
+func mergeEnvOverlay(v *viper.Viper) error {
+	env := strings.TrimSpace(os.Getenv("NOVA_ENV"))
+	if env == "" {
+		return nil
+	}
+
+	base := v.ConfigFileUsed()
+	ext := filepath.Ext(base)
+	overlay := strings.TrimSuffix(base, ext) + "." + env + ext
+	if overlay == base {
+		return nil
+	}
+	if _, err := os.Stat(overlay); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat config overlay %s: %w", overlay, err)
+	}
+
+	v.SetConfigFile(overlay)
+	if err := v.MergeInConfig(); err != nil {
+		return fmt.Errorf("failed to merge config overlay %s: %w", overlay, err)
+	}
+	return nil
+}
+
 // Get 获取全局配置实例
 func Get() *Config {
 	return globalConfig
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const baseConfig = `
+server:
+  host: "0.0.0.0"
+  port: 8080
+  mode: "debug"
+database:
+  host: "localhost"
+  port: 5432
+  dbname: "nova"
+ratelimit:
+  enabled: true
+  ip_limit: 100
+`
+
+const prodOverlay = `
+server:
+  port: 9090
+  mode: "release"
+database:
+  host: "db.internal"
+ratelimit:
+  ip_limit: 500
+`
+
+// writeConfigs 在临时目录写入配置文件，返回基础配置路径
+func writeConfigs(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+func TestLoad_EnvOverlay(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig, "config.prod.yaml": prodOverlay})
+	t.Setenv("NOVA_ENV", "prod")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// 覆盖文件中的值覆盖基础配置
+	if cfg.Server.Port != 9090 || cfg.Server.Mode != "release" || cfg.DB.Host != "db.internal" {
+		t.Fatalf("overlay not applied: server=%+v database.host=%s", cfg.Server, cfg.DB.Host)
+	}
+	// 覆盖文件未涉及的值保留基础配置
+	if cfg.Server.Host != "0.0.0.0" || cfg.DB.Port != 5432 || cfg.DB.DBName != "nova" {
+		t.Fatalf("base values lost: server.host=%s database=%+v", cfg.Server.Host, cfg.DB)
+	}
+	// 嵌套配置按字段深度合并
+	if !cfg.RateLimit.Enabled || cfg.RateLimit.IPLimit != 500 {
+		t.Fatalf("ratelimit = %+v, want enabled with overlay ip_limit 500", cfg.RateLimit)
+	}
+	// 默认值仍然生效
+	if cfg.Permission.MaxTreeDepth != 10 {
+		t.Fatalf("permission.max_tree_depth = %d, want default 10", cfg.Permission.MaxTreeDepth)
+	}
+}
+
+func TestLoad_EnvVarsOverrideOverlay(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig, "config.prod.yaml": prodOverlay})
+	t.Setenv("NOVA_ENV", "prod")
+	t.Setenv("NOVA_SERVER_PORT", "7070")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 7070 {
+		t.Fatalf("server.port = %d, want env var 7070", cfg.Server.Port)
+	}
+	if cfg.DB.Host != "db.internal" {
+		t.Fatalf("database.host = %s, want overlay value", cfg.DB.Host)
+	}
+}
+
+func TestLoad_WithoutOverlay(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+	}{
+		{"NOVA_ENV unset", ""},
+		{"overlay file missing", "staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigs(t, map[string]string{"config.yaml": baseConfig, "config.prod.yaml": prodOverlay})
+			t.Setenv("NOVA_ENV", tt.env)
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != 8080 || cfg.DB.Host != "localhost" {
+				t.Fatalf("got port %d host %s, want base values", cfg.Server.Port, cfg.DB.Host)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidOverlay(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig, "config.prod.yaml": "server: [unclosed"})
+	t.Setenv("NOVA_ENV", "prod")
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("Load succeeded with a malformed overlay, want error")
+	}
+}
+
+// viper 读取的 map 键为小写，按域覆盖的上限按小写域名查找
+func TestLoad_DomainOverridesCaseInsensitive(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig + `
+rbac:
+  domain_max_role_levels:
+    Tenant_A: 50
+  domain_max_permissions_per_role:
+    Tenant_A: 200
+`})
+	t.Setenv("NOVA_ENV", "")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, domain := range []string{"Tenant_A", "tenant_a"} {
+		if got := cfg.RBAC.MaxPermissionsPerRoleFor(domain); got != 200 {
+			t.Fatalf("MaxPermissionsPerRoleFor(%q) = %d, want 200", domain, got)
+		}
+		if got := cfg.RBAC.MaxRoleLevelFor(domain); got != 50 {
+			t.Fatalf("MaxRoleLevelFor(%q) = %d, want 50", domain, got)
+		}
+	}
+	// 未覆盖的域使用全局值，权限数上限默认不限制
+	if got := cfg.RBAC.MaxPermissionsPerRoleFor("default"); got != 0 {
+		t.Fatalf("MaxPermissionsPerRoleFor(default) = %d, want 0", got)
+	}
+}
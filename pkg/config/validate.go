@@ -0,0 +1,398 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/cccvno1/nova/pkg/casbin"
+	"github.com/cccvno1/nova/pkg/encrypt"
+)
+
+// ValidationError 配置校验错误，汇总所有问题一次性返回
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// validator 收集校验问题
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// require 必填字符串
+func (v *validator) require(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s is required", field)
+	}
+}
+
+// oneOf 枚举值校验，空值视为使用默认值
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf("%s must be one of [%s], got %q", field, strings.Join(allowed, ", "), value)
+}
+
+// port 端口范围校验
+func (v *validator) port(field string, value int) {
+	if value < 1 || value > 65535 {
+		v.addf("%s must be between 1 and 65535, got %d", field, value)
+	}
+}
+
+// positive 正整数校验
+func (v *validator) positive(field string, value int) {
+	if value <= 0 {
+		v.addf("%s must be greater than 0, got %d", field, value)
+	}
+}
+
+// nonNegative 非负整数校验
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.addf("%s must not be negative, got %d", field, value)
+	}
+}
+
+// validIPOrCIDR 检查是否为合法的 IP 或 CIDR
+func validIPOrCIDR(value string) bool {
+	if strings.Contains(value, "/") {
+		_, _, err := net.ParseCIDR(value)
+		return err == nil
+	}
+	return net.ParseIP(value) != nil
+}
+
+// Validate 校验配置
+// 检查必填项、取值范围及字段间约束，返回包含全部问题的 *ValidationError
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	// 服务器
+	v.port("server.port", c.Server.Port)
+	v.oneOf("server.mode", c.Server.Mode, "debug", "release", "test")
+	v.nonNegative("server.slow_request_ms", c.Server.SlowRequestMs)
+	for _, entry := range c.Server.TrustedProxies {
+		if !validIPOrCIDR(entry) {
+			v.addf("server.trusted_proxies contains invalid IP or CIDR %q", entry)
+		}
+	}
+	v.nonNegative("server.max_concurrent_requests", c.Server.MaxConcurrentRequests)
+	if c.Server.MaxConcurrentRequests > 0 {
+		v.nonNegative("server.concurrency_queue_ms", c.Server.ConcurrencyQueueMs)
+		v.positive("server.concurrency_retry_after", c.Server.ConcurrencyRetryAfter)
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		v.addf("server.tls_cert_file and server.tls_key_file must be set together")
+	}
+	v.oneOf("server.tls_min_version", c.Server.TLSMinVersion, "1.2", "1.3")
+	v.nonNegative("server.security_headers.hsts_max_age", c.Server.SecurityHeaders.HSTSMaxAge)
+
+	// 日志
+	v.oneOf("logger.level", c.Logger.Level, "debug", "info", "warn", "error")
+	v.oneOf("logger.format", c.Logger.Format, "json", "text")
+	v.oneOf("logger.output", c.Logger.Output, "stdout", "file", "both")
+	if c.Logger.Output == "file" || c.Logger.Output == "both" {
+		v.require("logger.file_path", c.Logger.FilePath)
+	}
+
+	// 数据库
+	v.oneOf("database.driver", c.DB.Driver, "postgres")
+	v.require("database.host", c.DB.Host)
+	v.port("database.port", c.DB.Port)
+	v.require("database.dbname", c.DB.DBName)
+	v.nonNegative("database.max_idle", c.DB.MaxIdle)
+	v.nonNegative("database.max_open", c.DB.MaxOpen)
+
+	// Redis
+	v.require("redis.host", c.Redis.Host)
+	v.port("redis.port", c.Redis.Port)
+	if c.Redis.DB < 0 || c.Redis.DB > 15 {
+		v.addf("redis.db must be between 0 and 15, got %d", c.Redis.DB)
+	}
+
+	// 认证
+	if len(c.Auth.Keys) == 0 {
+		v.require("auth.jwt_secret", c.Auth.JWTSecret)
+	} else {
+		seen := make(map[string]bool, len(c.Auth.Keys))
+		active := 0
+		for i, key := range c.Auth.Keys {
+			v.require(fmt.Sprintf("auth.keys[%d].id", i), key.ID)
+			v.require(fmt.Sprintf("auth.keys[%d].secret", i), key.Secret)
+			if key.ID != "" && seen[key.ID] {
+				v.addf("auth.keys[%d].id %q is duplicated", i, key.ID)
+			}
+			seen[key.ID] = true
+			if key.Active {
+				active++
+			}
+		}
+		if active == 0 {
+			v.addf("auth.keys requires at least one active key")
+		}
+	}
+	v.positive("auth.access_token_duration", c.Auth.AccessTokenDuration)
+	v.positive("auth.refresh_token_duration", c.Auth.RefreshTokenDuration)
+	if c.Auth.RefreshTokenDuration > 0 && c.Auth.RefreshTokenDuration < c.Auth.AccessTokenDuration {
+		v.addf("auth.refresh_token_duration (%d) must not be shorter than auth.access_token_duration (%d)",
+			c.Auth.RefreshTokenDuration, c.Auth.AccessTokenDuration)
+	}
+	if c.Auth.RefreshTokenRotation {
+		v.nonNegative("auth.refresh_reuse_grace", c.Auth.RefreshReuseGrace)
+	}
+	v.oneOf("auth.cookie_same_site", c.Auth.CookieSameSite, "strict", "lax", "none")
+	if c.Auth.UseCookies && c.Auth.CookieSameSite == "none" && !c.Auth.CookieSecure {
+		v.addf("auth.cookie_same_site=none requires auth.cookie_secure=true")
+	}
+	v.oneOf("auth.blacklist_store", c.Auth.BlacklistStore, "redis", "database")
+	v.oneOf("auth.blacklist_failure_mode", c.Auth.BlacklistFailureMode, "closed", "open")
+	if c.Auth.BlacklistStore == "database" {
+		v.positive("auth.blacklist_cleanup_interval", c.Auth.BlacklistCleanupInterval)
+	}
+	if c.Auth.MagicLinkEnabled {
+		v.require("auth.magic_link_url", c.Auth.MagicLinkURL)
+		v.positive("auth.magic_link_ttl", c.Auth.MagicLinkTTL)
+		v.positive("auth.magic_link_rate_limit", c.Auth.MagicLinkRateLimit)
+		v.positive("auth.magic_link_rate_window", c.Auth.MagicLinkRateWindow)
+		if !c.Queue.Enabled {
+			v.addf("auth.magic_link_enabled requires queue.enabled=true")
+		}
+		if !c.Mail.Enabled {
+			v.addf("auth.magic_link_enabled requires mail.enabled=true")
+		}
+	}
+	if c.Auth.LoginThrottleEnabled {
+		v.positive("auth.login_throttle_base_delay", c.Auth.LoginThrottleBaseDelay)
+		v.positive("auth.login_throttle_max_delay", c.Auth.LoginThrottleMaxDelay)
+		v.positive("auth.login_throttle_window", c.Auth.LoginThrottleWindow)
+		if c.Auth.LoginThrottleMaxDelay > 0 && c.Auth.LoginThrottleMaxDelay < c.Auth.LoginThrottleBaseDelay {
+			v.addf("auth.login_throttle_max_delay (%d) must not be less than auth.login_throttle_base_delay (%d)",
+				c.Auth.LoginThrottleMaxDelay, c.Auth.LoginThrottleBaseDelay)
+		}
+		if c.Auth.LoginThrottleMultiplier < 1 {
+			v.addf("auth.login_throttle_multiplier must be at least 1, got %g", c.Auth.LoginThrottleMultiplier)
+		}
+	}
+
+	// 用户资料
+	v.positive("user.profile_max_size", c.User.ProfileMaxSize)
+	for i, field := range c.User.ProfileFields {
+		v.require(fmt.Sprintf("user.profile_fields[%d]", i), strings.TrimSpace(field))
+	}
+	if c.User.EmailChangeURL != "" {
+		v.positive("user.email_change_ttl", c.User.EmailChangeTTL)
+		if !c.Queue.Enabled {
+			v.addf("user.email_change_url requires queue.enabled=true")
+		}
+		if !c.Mail.Enabled {
+			v.addf("user.email_change_url requires mail.enabled=true")
+		}
+	}
+
+	// 限流
+	if c.RateLimit.Enabled {
+		v.oneOf("ratelimit.algorithm", c.RateLimit.Algorithm, "token_bucket", "sliding_window")
+		v.positive("ratelimit.ip_limit", c.RateLimit.IPLimit)
+		v.positive("ratelimit.ip_window", c.RateLimit.IPWindow)
+		v.positive("ratelimit.user_limit", c.RateLimit.UserLimit)
+		v.positive("ratelimit.user_window", c.RateLimit.UserWindow)
+		for i, rule := range c.RateLimit.Rules {
+			field := fmt.Sprintf("ratelimit.rules[%d]", i)
+			v.require(field+".path", rule.Path)
+			v.positive(field+".limit", rule.Limit)
+			v.positive(field+".window", rule.Window)
+			v.oneOf(field+".dimension", rule.Dimension, "ip", "user", "api", "user_api")
+		}
+	}
+
+	// Casbin
+	if c.Casbin.AutoLoad {
+		v.positive("casbin.auto_load_tick", c.Casbin.AutoLoadTick)
+	}
+	v.oneOf("casbin.reconcile_on_start", c.Casbin.ReconcileOnStart, "off", "log", "repair")
+	if c.Casbin.TableName != "" {
+		if err := casbin.ValidateTableName(c.Casbin.TableName); err != nil {
+			v.addf("casbin.table_name: %v", err)
+		}
+	}
+
+	// RBAC
+	v.positive("rbac.max_role_level", c.RBAC.MaxRoleLevel)
+	for domain, level := range c.RBAC.DomainMaxRoleLevels {
+		v.positive(fmt.Sprintf("rbac.domain_max_role_levels[%s]", domain), level)
+	}
+	for i, id := range c.RBAC.SuperAdminUserIDs {
+		v.positive(fmt.Sprintf("rbac.super_admin_user_ids[%d]", i), int(id))
+	}
+	for i, name := range c.RBAC.DefaultRoles {
+		v.require(fmt.Sprintf("rbac.default_roles[%d]", i), name)
+	}
+	v.nonNegative("rbac.max_permissions_per_role", c.RBAC.MaxPermissionsPerRole)
+	v.positive("rbac.expired_role_purge_interval", c.RBAC.ExpiredRolePurgeInterval)
+	for domain, limit := range c.RBAC.DomainMaxPermissionsPerRole {
+		v.nonNegative(fmt.Sprintf("rbac.domain_max_permissions_per_role[%s]", domain), limit)
+	}
+
+	// 文件上传
+	v.oneOf("upload.storage_type", c.Upload.StorageType, "local", "oss", "s3")
+	switch c.Upload.StorageType {
+	case "", "local":
+		v.require("upload.local_path", c.Upload.LocalPath)
+	case "oss":
+		v.require("upload.oss_endpoint", c.Upload.OSSEndpoint)
+		v.require("upload.oss_access_key_id", c.Upload.OSSAccessKeyID)
+		v.require("upload.oss_access_key_secret", c.Upload.OSSAccessKeySecret)
+		v.require("upload.oss_bucket_name", c.Upload.OSSBucketName)
+	case "s3":
+		v.require("upload.s3_endpoint", c.Upload.S3Endpoint)
+		v.require("upload.s3_access_key_id", c.Upload.S3AccessKeyID)
+		v.require("upload.s3_access_key_secret", c.Upload.S3AccessKeySecret)
+		v.require("upload.s3_bucket_name", c.Upload.S3BucketName)
+	}
+	if c.Upload.MaxFilenameLength < 1 || c.Upload.MaxFilenameLength > 255 {
+		v.addf("upload.max_filename_length must be between 1 and 255, got %d", c.Upload.MaxFilenameLength)
+	}
+	if c.Upload.MaxSize < 0 {
+		v.addf("upload.max_size must not be negative, got %d", c.Upload.MaxSize)
+	}
+	if c.Upload.EnableThumbnail {
+		v.positive("upload.thumbnail_width", c.Upload.ThumbnailWidth)
+		v.positive("upload.thumbnail_height", c.Upload.ThumbnailHeight)
+		if c.Upload.ThumbnailQuality < 1 || c.Upload.ThumbnailQuality > 100 {
+			v.addf("upload.thumbnail_quality must be between 1 and 100, got %d", c.Upload.ThumbnailQuality)
+		}
+		v.oneOf("upload.thumbnail_mode", c.Upload.ThumbnailMode, "fit", "fill", "crop")
+		v.nonNegative("upload.thumbnail_workers", c.Upload.ThumbnailWorkers)
+	}
+	v.nonNegative("upload.max_concurrent_downloads_per_user", c.Upload.MaxConcurrentDownloadsPerUser)
+	v.positive("upload.chunk_upload_ttl", c.Upload.ChunkUploadTTL)
+	if c.Upload.PublicLinkEnabled {
+		v.require("upload.public_link_secret", c.Upload.PublicLinkSecret)
+		if n := len(c.Upload.PublicLinkSecret); n > 0 && n < 32 {
+			v.addf("upload.public_link_secret must be at least 32 bytes, got %d", n)
+		}
+		v.require("upload.public_link_base_url", c.Upload.PublicLinkBaseURL)
+		if u, err := url.Parse(c.Upload.PublicLinkBaseURL); c.Upload.PublicLinkBaseURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			v.addf("upload.public_link_base_url must be an absolute http(s) URL, got %q", c.Upload.PublicLinkBaseURL)
+		}
+		v.positive("upload.public_link_max_ttl", c.Upload.PublicLinkMaxTTL)
+		v.positive("upload.public_link_rate_limit", c.Upload.PublicLinkRateLimit)
+		v.positive("upload.public_link_rate_window", c.Upload.PublicLinkRateWindow)
+	}
+	if c.Upload.MaxUploadBPS < 0 {
+		v.addf("upload.max_upload_bps must not be negative, got %d", c.Upload.MaxUploadBPS)
+	}
+	if c.Upload.UserQuota < 0 {
+		v.addf("upload.user_quota must not be negative, got %d", c.Upload.UserQuota)
+	}
+	if c.Upload.DefaultCategoryQuota < 0 {
+		v.addf("upload.default_category_quota must not be negative, got %d", c.Upload.DefaultCategoryQuota)
+	}
+	for category, quota := range c.Upload.CategoryQuotas {
+		if quota < 0 {
+			v.addf("upload.category_quotas.%s must not be negative, got %d", category, quota)
+		}
+	}
+
+	// 队列
+	if c.Queue.Enabled {
+		v.positive("queue.workers", c.Queue.Workers)
+		v.nonNegative("queue.max_retry", c.Queue.MaxRetry)
+		v.nonNegative("queue.retry_delay", c.Queue.RetryDelay)
+		v.oneOf("queue.backoff_strategy", c.Queue.BackoffStrategy, "fixed", "exponential")
+		v.nonNegative("queue.retry_max_delay", c.Queue.RetryMaxDelay)
+		v.positive("queue.outbox_poll_interval", c.Queue.OutboxPollInterval)
+		v.positive("queue.outbox_batch_size", c.Queue.OutboxBatchSize)
+		v.positive("queue.outbox_max_attempts", c.Queue.OutboxMaxAttempts)
+		v.nonNegative("queue.alert_threshold", c.Queue.AlertThreshold)
+		v.nonNegative("queue.alert_duration", c.Queue.AlertDuration)
+		v.positive("queue.alert_check_interval", c.Queue.AlertCheckInterval)
+		v.positive("queue.import_batch_size", c.Queue.ImportBatchSize)
+		v.nonNegative("queue.import_max_errors", c.Queue.ImportMaxErrors)
+	}
+
+	// 数据加密
+	if c.Security.EncryptionKey != "" {
+		if _, err := encrypt.NewKeyRing(c.Security.EncryptionKeyID, c.Security.EncryptionKey, c.Security.OldEncryptionKeys); err != nil {
+			v.addf("security: %v", err)
+		}
+	}
+
+	// 审计日志
+	if c.AuditLog.EncryptBodies && c.Security.EncryptionKey == "" {
+		v.addf("audit_log.encrypt_bodies requires security.encryption_key")
+	}
+	if c.AuditLog.Enabled {
+		v.nonNegative("audit_log.max_body_size", c.AuditLog.MaxBodySize)
+		v.positive("audit_log.export_batch_size", c.AuditLog.ExportBatchSize)
+		v.nonNegative("audit_log.export_max_rows", c.AuditLog.ExportMaxRows)
+		for i, override := range c.AuditLog.ActionOverrides {
+			field := fmt.Sprintf("audit_log.action_overrides[%d]", i)
+			v.require(field+".route", override.Route)
+			v.require(field+".action", override.Action)
+		}
+	}
+	// 审计日志清理（与是否继续记录无关，关闭记录后仍可清理历史数据）
+	v.nonNegative("audit_log.retention_days", c.AuditLog.RetentionDays)
+	v.nonNegative("audit_log.max_rows", c.AuditLog.MaxRows)
+	if c.AuditLog.RetentionDays > 0 || c.AuditLog.MaxRows > 0 {
+		v.positive("audit_log.prune_interval", c.AuditLog.PruneInterval)
+		v.positive("audit_log.prune_batch_size", c.AuditLog.PruneBatchSize)
+	}
+
+	// 验证码
+	if c.Captcha.Enabled {
+		v.require("captcha.provider", c.Captcha.Provider)
+		v.oneOf("captcha.provider", c.Captcha.Provider, "hcaptcha", "recaptcha")
+		v.require("captcha.secret_key", c.Captcha.SecretKey)
+		v.positive("captcha.threshold", c.Captcha.Threshold)
+		v.positive("captcha.window", c.Captcha.Window)
+	}
+
+	// 邮件
+	if c.Mail.Enabled {
+		v.require("mail.host", c.Mail.Host)
+		v.port("mail.port", c.Mail.Port)
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			v.addf("mail.from must be a valid address, got %q", c.Mail.From)
+		}
+		v.oneOf("mail.tls_mode", c.Mail.TLSMode, "starttls", "tls", "none")
+		v.positive("mail.timeout", c.Mail.Timeout)
+	}
+
+	// 维护模式
+	v.positive("maintenance.retry_after", c.Maintenance.RetryAfter)
+	v.nonNegative("maintenance.check_interval", c.Maintenance.CheckInterval)
+	for _, entry := range c.Maintenance.AllowIPs {
+		if !validIPOrCIDR(entry) {
+			v.addf("maintenance.allow_ips contains invalid IP or CIDR %q", entry)
+		}
+	}
+	for _, path := range c.Maintenance.AllowPaths {
+		if !strings.HasPrefix(path, "/") {
+			v.addf("maintenance.allow_paths entries must start with /, got %q", path)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// problemsMatching 返回校验问题中包含 substr 的条目
+func problemsMatching(t *testing.T, cfg *Config, substr string) []string {
+	t.Helper()
+
+	var matched []string
+	var ve *ValidationError
+	if err := cfg.Validate(); errors.As(err, &ve) {
+		for _, p := range ve.Problems {
+			if strings.Contains(p, substr) {
+				matched = append(matched, p)
+			}
+		}
+	}
+	return matched
+}
+
+func TestValidate_Security(t *testing.T) {
+	const key = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 字节
+	tests := []struct {
+		name    string
+		cfg     Config
+		substr  string
+		problem bool
+	}{
+		{"no key", Config{}, "security", false},
+		{"valid key", Config{Security: SecurityConfig{EncryptionKey: key, EncryptionKeyID: "v1"}}, "security", false},
+		{"short key", Config{Security: SecurityConfig{EncryptionKey: "c2hvcnQ=", EncryptionKeyID: "v1"}}, "security", true},
+		{"bad key id", Config{Security: SecurityConfig{EncryptionKey: key, EncryptionKeyID: "v:1"}}, "security", true},
+		{"bad old key", Config{Security: SecurityConfig{EncryptionKey: key, EncryptionKeyID: "v2", OldEncryptionKeys: map[string]string{"v1": "x"}}}, "security", true},
+		{"encrypt bodies without key", Config{AuditLog: AuditLogConfig{EncryptBodies: true}}, "encrypt_bodies", true},
+		{"encrypt bodies with key", Config{AuditLog: AuditLogConfig{EncryptBodies: true}, Security: SecurityConfig{EncryptionKey: key, EncryptionKeyID: "v1"}}, "encrypt_bodies", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := problemsMatching(t, &tt.cfg, tt.substr)
+			if tt.problem && len(got) == 0 {
+				t.Fatalf("no %q problem reported", tt.substr)
+			}
+			if !tt.problem && len(got) > 0 {
+				t.Fatalf("unexpected problems: %v", got)
+			}
+		})
+	}
+}
+
+func TestValidate_CasbinTableName(t *testing.T) {
+	for _, name := range []string{"", "casbin_rule", "casbin_rule_tenant"} {
+		cfg := Config{Casbin: CasbinConfig{TableName: name}}
+		if got := problemsMatching(t, &cfg, "casbin.table_name"); len(got) > 0 {
+			t.Errorf("table %q: unexpected problems: %v", name, got)
+		}
+	}
+	for _, name := range []string{"Casbin_Rule", "public.casbin_rule", "rules;drop"} {
+		cfg := Config{Casbin: CasbinConfig{TableName: name}}
+		if got := problemsMatching(t, &cfg, "casbin.table_name"); len(got) == 0 {
+			t.Errorf("table %q: no problem reported", name)
+		}
+	}
+}
+
+func TestValidate_JWTKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		problem bool
+	}{
+		{"secret only", AuthConfig{JWTSecret: "s"}, false},
+		{"neither", AuthConfig{}, true},
+		{"keys without secret", AuthConfig{Keys: []JWTKeyConfig{{ID: "v2", Secret: "b", Active: true}, {ID: "v1", Secret: "a"}}}, false},
+		{"no active key", AuthConfig{Keys: []JWTKeyConfig{{ID: "v1", Secret: "a"}}}, true},
+		{"duplicate id", AuthConfig{Keys: []JWTKeyConfig{{ID: "v1", Secret: "a", Active: true}, {ID: "v1", Secret: "b", Active: true}}}, true},
+		{"missing secret", AuthConfig{Keys: []JWTKeyConfig{{ID: "v1", Active: true}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Auth: tt.auth}
+			got := append(problemsMatching(t, &cfg, "auth.keys"), problemsMatching(t, &cfg, "auth.jwt_secret")...)
+			if tt.problem && len(got) == 0 {
+				t.Fatal("no problem reported")
+			}
+			if !tt.problem && len(got) > 0 {
+				t.Fatalf("unexpected problems: %v", got)
+			}
+		})
+	}
+}
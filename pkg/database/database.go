@@ -36,6 +36,10 @@ func Init(cfg *config.DBConfig) error {
 		return fmt.Errorf("failed to connect database: %w", err)
 	}
 
+	if err := registerTiming(gormDB); err != nil {
+		return fmt.Errorf("failed to register timing callbacks: %w", err)
+	}
+
 	sqlDB, err := gormDB.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database instance: %w", err)
@@ -47,6 +51,7 @@ func Init(cfg *config.DBConfig) error {
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
 
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/cccvno1/nova/pkg/encrypt"
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// fieldEncryption 字段加密状态
+type fieldEncryption struct {
+	keyRing       *encrypt.KeyRing
+	encryptWrites bool
+}
+
+var fieldCipher atomic.Pointer[fieldEncryption]
+
+// SetFieldEncryption 设置 serializer:encrypted 列使用的密钥环
+// encryptWrites 为 false 时写入明文，但仍可解密已加密的数据（关闭加密后历史数据可读）；
+// keyRing 为 nil 时不加密也不解密
+func SetFieldEncryption(keyRing *encrypt.KeyRing, encryptWrites bool) {
+	if keyRing == nil {
+		fieldCipher.Store(nil)
+		return
+	}
+	fieldCipher.Store(&fieldEncryption{keyRing: keyRing, encryptWrites: encryptWrites})
+}
+
+// EncryptedSerializer 字符串列的透明加密序列化器（标签 serializer:encrypted）
+// 写入时按 SetFieldEncryption 的配置加密，读取时只解密符合密文信封格式（encrypt.Prefix + 密钥ID + base64）的值，
+// 其余值（未加密的历史数据、以 "enc:" 开头的明文）原样返回；空字符串不加密
+type EncryptedSerializer struct{}
+
+// Scan 读取列值，密文按信封中的密钥ID解密
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("encrypted column %s: unsupported value type %T", field.DBName, dbValue)
+	}
+
+	if encrypt.IsEncrypted(value) {
+		state := fieldCipher.Load()
+		if state == nil {
+			return fmt.Errorf("encrypted column %s: no encryption key configured", field.DBName)
+		}
+		plaintext, err := state.keyRing.Decrypt(value)
+		if err != nil {
+			return fmt.Errorf("encrypted column %s: %w", field.DBName, err)
+		}
+		value = string(plaintext)
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+// Value 写入列值，启用加密时返回密文
+func (EncryptedSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted column %s: unsupported field type %T", field.DBName, fieldValue)
+	}
+
+	state := fieldCipher.Load()
+	if value == "" || state == nil || !state.encryptWrites {
+		return value, nil
+	}
+	return state.keyRing.Encrypt([]byte(value))
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// IndexSpec 查询依赖的索引
+type IndexSpec struct {
+	Table   string // 表名
+	Name    string // 索引名
+	Purpose string // 依赖该索引的查询，缺失时随告警输出
+}
+
+// MissingIndexes 返回 specs 中数据库里不存在的索引
+// 表不存在（迁移尚未执行）时跳过该表的索引，由迁移状态反映
+func MissingIndexes(ctx context.Context, db *gorm.DB, specs []IndexSpec) []IndexSpec {
+	migrator := db.WithContext(ctx).Migrator()
+
+	var missing []IndexSpec
+	for _, spec := range specs {
+		if !migrator.HasTable(spec.Table) {
+			continue
+		}
+		if !migrator.HasIndex(spec.Table, spec.Name) {
+			missing = append(missing, spec)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// migrationLockKey 执行迁移时的 PostgreSQL 事务级咨询锁，多实例同时启动时串行执行
+const migrationLockKey = "nova_schema_migrations"
+
+// Migration 版本化的数据库迁移
+// Version 按字典序决定执行顺序（建议使用 0001、0002 ... 形式），已发布的迁移不可修改，结构变化需追加新版本
+type Migration struct {
+	Version     string
+	Description string
+	Up          func(tx *gorm.DB) error
+	// NoTransaction 为 true 时不在事务中执行，用于不能在事务块中执行的语句（如 CREATE INDEX CONCURRENTLY）
+	// 这类迁移的语句必须可重复执行：中途失败时已执行的语句不会回滚，也不写入执行记录，下次启动重新执行
+	NoTransaction bool
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version     string    `gorm:"primaryKey;size:64" json:"version"`
+	Description string    `gorm:"size:255" json:"description"`
+	AppliedAt   time.Time `json:"applied_at"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Current string   `json:"current"`           // 最近执行的版本，尚未执行任何迁移时为空
+	Applied int      `json:"applied"`           // 已执行的迁移数量
+	Pending []string `json:"pending"`           // 代码中定义但尚未执行的版本（升序）
+	Unknown []string `json:"unknown,omitempty"` // 数据库中已执行但代码中不存在的版本（如回滚到旧版本程序）
+}
+
+// UpToDate 是否没有待执行的迁移
+func (s *MigrationStatus) UpToDate() bool {
+	return len(s.Pending) == 0
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator 创建迁移执行器，migrations 按版本升序执行
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// Status 查询迁移状态（只读，schema_migrations 表不存在时全部视为待执行）
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Pending: []string{}}
+	known := make(map[string]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if !applied[mig.Version] {
+			status.Pending = append(status.Pending, mig.Version)
+		}
+	}
+	for version := range applied {
+		status.Applied++
+		if version > status.Current {
+			status.Current = version
+		}
+		if !known[version] {
+			status.Unknown = append(status.Unknown, version)
+		}
+	}
+	sort.Strings(status.Unknown)
+
+	return status, nil
+}
+
+// Up 依次执行待执行的迁移，返回本次执行的版本
+// 每个迁移与其执行记录在同一事务中提交（NoTransaction 的迁移除外），失败时停止并返回已成功执行的版本
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	if err := m.db.WithContext(ctx).AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var done []string
+	for _, mig := range m.migrations {
+		applied, err := m.apply(ctx, mig)
+		if err != nil {
+			return done, fmt.Errorf("migration %s failed: %w", mig.Version, err)
+		}
+		if applied {
+			done = append(done, mig.Version)
+			logger.Info("migration applied",
+				slog.String("version", mig.Version),
+				slog.String("description", mig.Description))
+		}
+	}
+	return done, nil
+}
+
+// apply 在事务中执行单个迁移；持有咨询锁后再次确认未执行，避免多实例重复执行
+func (m *Migrator) apply(ctx context.Context, mig Migration) (bool, error) {
+	if mig.NoTransaction {
+		return m.applyWithoutTx(ctx, mig)
+	}
+
+	applied := false
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", migrationLockKey).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&SchemaMigration{}).Where("version = ?", mig.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if err := mig.Up(tx); err != nil {
+			return err
+		}
+		applied = true
+		return tx.Create(&SchemaMigration{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now(),
+		}).Error
+	})
+	return applied, err
+}
+
+// applyWithoutTx 在事务外执行单个迁移
+// 在同一连接上持有会话级咨询锁（与事务级锁使用同一个键，互相排斥），执行成功后写入执行记录
+func (m *Migrator) applyWithoutTx(ctx context.Context, mig Migration) (bool, error) {
+	applied := false
+	err := m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(hashtext(?))", migrationLockKey).Error; err != nil {
+			return err
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(hashtext(?))", migrationLockKey).Error; err != nil {
+				logger.Warn("failed to release migration lock", slog.Any("error", err))
+			}
+		}()
+
+		var count int64
+		if err := conn.Model(&SchemaMigration{}).Where("version = ?", mig.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if err := mig.Up(conn); err != nil {
+			return err
+		}
+		applied = true
+		return conn.Create(&SchemaMigration{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now(),
+		}).Error
+	})
+	return applied, err
+}
+
+// applied 查询已执行的版本
+func (m *Migrator) applied(ctx context.Context) (map[string]bool, error) {
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[string]bool{}, nil
+	}
+
+	var versions []string
+	if err := db.Model(&SchemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// QueryBuilder 列表查询构建器
+// 组合等值条件、关键词模糊匹配、附加 scope、排序与列选择；所有条件在计数前应用，pagination.Total 与各页数据一致
+type QueryBuilder struct {
+	conditions     map[string]interface{}
+	keyword        string
+	keywordColumns []string
+	scopes         []Scope
+	order          string
+	columns        []string
+}
+
+// NewQueryBuilder 创建列表查询构建器
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{conditions: make(map[string]interface{})}
+}
+
+// Where 添加等值条件（列名 -> 值），值按原样比较，零值同样参与过滤
+func (q *QueryBuilder) Where(conditions map[string]interface{}) *QueryBuilder {
+	for column, value := range conditions {
+		q.conditions[column] = value
+	}
+	return q
+}
+
+// WhereIfSet 添加可选的等值条件，值为 nil 或零值（空字符串、0）表示未设置，忽略该条件
+// 用于直接传入可选的过滤参数（如 domain 为空表示所有域）
+func (q *QueryBuilder) WhereIfSet(conditions map[string]interface{}) *QueryBuilder {
+	for column, value := range conditions {
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		q.conditions[column] = value
+	}
+	return q
+}
+
+// Keyword 在指定列上做关键词模糊匹配，规则同 SearchKeyword；keyword 为空时不过滤
+func (q *QueryBuilder) Keyword(keyword string, columns ...string) *QueryBuilder {
+	q.keyword = keyword
+	q.keywordColumns = columns
+	return q
+}
+
+// Scopes 添加等值条件无法表达的过滤（范围、LIKE、子查询等），在计数前应用
+func (q *QueryBuilder) Scopes(scopes ...Scope) *QueryBuilder {
+	q.scopes = append(q.scopes, scopes...)
+	return q
+}
+
+// Order 设置排序，只作用于分页查询
+func (q *QueryBuilder) Order(order string) *QueryBuilder {
+	q.order = order
+	return q
+}
+
+// Select 设置查询的列，只作用于分页查询；未设置时查询全部列
+func (q *QueryBuilder) Select(columns ...string) *QueryBuilder {
+	q.columns = columns
+	return q
+}
+
+// Build 在 db 上应用过滤条件（不含排序、列选择与分页）
+// 等值条件按列名排序后应用，生成的 SQL 稳定
+func (q *QueryBuilder) Build(db *gorm.DB) *gorm.DB {
+	columns := make([]string, 0, len(q.conditions))
+	for column := range q.conditions {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		db = db.Where(column+" = ?", q.conditions[column])
+	}
+
+	db = db.Scopes(SearchKeyword(q.keyword, q.keywordColumns...))
+	for _, scope := range q.scopes {
+		db = scope(db)
+	}
+	return db
+}
+
+// Find 应用过滤条件后统计总数，再按排序、列选择分页查询到 dest
+// db 需已通过 Model 指定模型
+func (q *QueryBuilder) Find(db *gorm.DB, pagination *Pagination, dest interface{}) error {
+	db = q.Build(db)
+	if err := db.Count(&pagination.Total).Error; err != nil {
+		return err
+	}
+
+	if len(q.columns) > 0 {
+		db = db.Select(q.columns)
+	}
+	if q.order != "" {
+		db = db.Order(q.order)
+	}
+	return db.Scopes(Paginate(pagination)).Find(dest).Error
+}
@@ -2,10 +2,15 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 )
 
+// ErrUnknownColumn 请求的列不属于模型
+var ErrUnknownColumn = errors.New("unknown column")
+
 type Repository[T any] struct {
 	db *gorm.DB
 }
@@ -15,36 +20,46 @@ func NewRepository[T any](db *gorm.DB) *Repository[T] {
 }
 
 func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Create(entity).Error
+	return r.Conn(ctx).Create(entity).Error
 }
 
 func (r *Repository[T]) CreateBatch(ctx context.Context, entities []T) error {
-	return r.db.WithContext(ctx).Create(&entities).Error
+	return r.Conn(ctx).Create(&entities).Error
 }
 
 func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Save(entity).Error
+	return r.Conn(ctx).Save(entity).Error
 }
 
 func (r *Repository[T]) UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error {
-	return r.db.WithContext(ctx).Model(new(T)).Where("id = ?", id).Updates(fields).Error
+	return r.Conn(ctx).Model(new(T)).Where("id = ?", id).Updates(fields).Error
 }
 
 func (r *Repository[T]) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(new(T), id).Error
+	return r.Conn(ctx).Delete(new(T), id).Error
 }
 
 func (r *Repository[T]) DeleteBatch(ctx context.Context, ids []uint) error {
-	return r.db.WithContext(ctx).Delete(new(T), ids).Error
+	return r.Conn(ctx).Delete(new(T), ids).Error
 }
 
 func (r *Repository[T]) HardDelete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Unscoped().Delete(new(T), id).Error
+	return r.Conn(ctx).Unscoped().Delete(new(T), id).Error
 }
 
 func (r *Repository[T]) FindByID(ctx context.Context, id uint) (*T, error) {
 	var entity T
-	err := r.db.WithContext(ctx).First(&entity, id).Error
+	err := r.Conn(ctx).First(&entity, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// FindByIDUnscoped 根据ID查找记录，包含已软删除的记录
+func (r *Repository[T]) FindByIDUnscoped(ctx context.Context, id uint) (*T, error) {
+	var entity T
+	err := r.Conn(ctx).Unscoped().First(&entity, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +68,7 @@ func (r *Repository[T]) FindByID(ctx context.Context, id uint) (*T, error) {
 
 func (r *Repository[T]) FindOne(ctx context.Context, query interface{}, args ...interface{}) (*T, error) {
 	var entity T
-	err := r.db.WithContext(ctx).Where(query, args...).First(&entity).Error
+	err := r.Conn(ctx).Where(query, args...).First(&entity).Error
 	if err != nil {
 		return nil, err
 	}
@@ -62,20 +77,20 @@ func (r *Repository[T]) FindOne(ctx context.Context, query interface{}, args ...
 
 func (r *Repository[T]) FindAll(ctx context.Context) ([]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Find(&entities).Error
+	err := r.Conn(ctx).Find(&entities).Error
 	return entities, err
 }
 
 func (r *Repository[T]) FindByCondition(ctx context.Context, query interface{}, args ...interface{}) ([]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Where(query, args...).Find(&entities).Error
+	err := r.Conn(ctx).Where(query, args...).Find(&entities).Error
 	return entities, err
 }
 
 func (r *Repository[T]) FindWithPagination(ctx context.Context, pagination *Pagination, query interface{}, args ...interface{}) ([]T, error) {
 	var entities []T
 
-	db := r.db.WithContext(ctx).Model(new(T))
+	db := r.Conn(ctx).Model(new(T))
 	if query != nil {
 		db = db.Where(query, args...)
 	}
@@ -88,9 +103,74 @@ func (r *Repository[T]) FindWithPagination(ctx context.Context, pagination *Pagi
 	return entities, err
 }
 
+// FindQuery 按 QueryBuilder 的条件统计总数并分页查询，在事务上下文中使用事务连接
+// 过滤条件同时作用于计数与分页，pagination.Total 与各页数据一致；需要按条件过滤的分页列表应通过 Scopes 把条件下推到 SQL，
+// 而不是分页后在内存中过滤（分页后过滤会使总数错误并漏掉后续页的数据）
+func (r *Repository[T]) FindQuery(ctx context.Context, query *QueryBuilder, pagination *Pagination) ([]T, error) {
+	var entities []T
+	err := query.Find(r.Conn(ctx).Model(new(T)), pagination, &entities)
+	return entities, err
+}
+
+// FindColumns 只查询指定列，返回的实体中未选择的字段为零值
+// columns 可使用数据库列名或结构体字段名，包含不属于模型的列时返回 ErrUnknownColumn
+func (r *Repository[T]) FindColumns(ctx context.Context, columns []string, query interface{}, args ...interface{}) ([]T, error) {
+	selected, err := r.ResolveColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []T
+	db := r.Conn(ctx).Model(new(T)).Select(selected)
+	if query != nil {
+		db = db.Where(query, args...)
+	}
+	err = db.Find(&entities).Error
+	return entities, err
+}
+
+// FindColumnsWithPagination 分页查询指定列，列校验规则同 FindColumns
+func (r *Repository[T]) FindColumnsWithPagination(ctx context.Context, columns []string, pagination *Pagination, query interface{}, args ...interface{}) ([]T, error) {
+	selected, err := r.ResolveColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []T
+	db := r.Conn(ctx).Model(new(T))
+	if query != nil {
+		db = db.Where(query, args...)
+	}
+
+	if err := db.Count(&pagination.Total).Error; err != nil {
+		return nil, err
+	}
+
+	err = db.Select(selected).Scopes(Paginate(pagination)).Find(&entities).Error
+	return entities, err
+}
+
+// ResolveColumns 将列名或字段名解析为模型的数据库列名，用于校验外部传入的列
+func (r *Repository[T]) ResolveColumns(columns []string) ([]string, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+
+	resolved := make([]string, 0, len(columns))
+	for _, column := range columns {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+		resolved = append(resolved, field.DBName)
+	}
+	return resolved, nil
+}
+
 func (r *Repository[T]) Count(ctx context.Context, query interface{}, args ...interface{}) (int64, error) {
 	var count int64
-	db := r.db.WithContext(ctx).Model(new(T))
+	db := r.Conn(ctx).Model(new(T))
 	if query != nil {
 		db = db.Where(query, args...)
 	}
@@ -100,7 +180,7 @@ func (r *Repository[T]) Count(ctx context.Context, query interface{}, args ...in
 
 func (r *Repository[T]) Exists(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(new(T)).Where(query, args...).Count(&count).Error
+	err := r.Conn(ctx).Model(new(T)).Where(query, args...).Count(&count).Error
 	return count > 0, err
 }
 
@@ -108,6 +188,15 @@ func (r *Repository[T]) Transaction(fn func(*gorm.DB) error) error {
 	return r.db.Transaction(fn)
 }
 
+// Conn 返回 context 中绑定的事务（见 WithTx），不存在时返回仓储自身的连接
+// context 中的事务优先于 WithDB 指定的连接
+func (r *Repository[T]) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return r.db.WithContext(ctx)
+}
+
 func (r *Repository[T]) DB() *gorm.DB {
 	return r.db
 }
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 )
 
@@ -52,3 +54,29 @@ func OnlyDeleted() Scope {
 		return db.Unscoped().Where("deleted_at IS NOT NULL")
 	}
 }
+
+// SearchKeyword 在多个字段上做不区分大小写的模糊匹配
+// 关键字与字段统一转为小写后比较（LOWER(col) LIKE ?），不依赖数据库排序规则；
+// 关键字中的 % 和 _ 会被转义为普通字符（显式声明 ESCAPE，SQLite 没有默认转义符）。重音不敏感匹配需数据库启用 unaccent 等扩展，这里不处理
+func SearchKeyword(keyword string, columns ...string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if keyword == "" || len(columns) == 0 {
+			return db
+		}
+
+		pattern := "%" + escapeLike(strings.ToLower(keyword)) + "%"
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = "LOWER(" + column + `) LIKE ? ESCAPE '\'`
+			args[i] = pattern
+		}
+		return db.Where(strings.Join(conditions, " OR "), args...)
+	}
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"github.com/cccvno1/nova/pkg/timing"
+	"gorm.io/gorm"
+)
+
+// timingStopKey 语句开始时保存的计时结束函数
+const timingStopKey = "timing:stop"
+
+// registerTiming 注册 GORM 回调，将每条 SQL 的耗时计入请求的 db 耗时
+// 语句上下文中没有记录器（未启用 server.timing_header）时回调只做一次 context 查找
+func registerTiming(gormDB *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		if timing.FromContext(tx.Statement.Context) == nil {
+			return
+		}
+		tx.InstanceSet(timingStopKey, timing.Start(tx.Statement.Context, timing.DB))
+	}
+	after := func(tx *gorm.DB) {
+		if stop, ok := tx.InstanceGet(timingStopKey); ok {
+			stop.(func())()
+		}
+	}
+
+	cb := gormDB.Callback()
+	registrations := []error{
+		cb.Create().Before("gorm:create").Register("timing:before_create", before),
+		cb.Create().After("gorm:create").Register("timing:after_create", after),
+		cb.Query().Before("gorm:query").Register("timing:before_query", before),
+		cb.Query().After("gorm:query").Register("timing:after_query", after),
+		cb.Update().Before("gorm:update").Register("timing:before_update", before),
+		cb.Update().After("gorm:update").Register("timing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("timing:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("timing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("timing:before_row", before),
+		cb.Row().After("gorm:row").Register("timing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("timing:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("timing:after_raw", after),
+	}
+	for _, err := range registrations {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// txContextKey 事务在 context 中的键
+type txContextKey struct{}
+
+// afterCommitKey 提交后回调在 context 中的键
+type afterCommitKey struct{}
+
+// afterCommitHooks 事务提交后待执行的回调
+type afterCommitHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// WithTx 将事务绑定到 context，之后通过 FromContext / Conn 获取的连接都会使用该事务
+// 事务的持有者提交成功后需调用 RunAfterCommit 执行通过 AfterCommit 注册的回调
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	ctx = context.WithValue(ctx, txContextKey{}, tx)
+	return context.WithValue(ctx, afterCommitKey{}, &afterCommitHooks{})
+}
+
+// AfterCommit 在 context 中的事务提交后执行 fn，事务回滚时不执行；没有事务时立即执行
+// 用于缓存失效等副作用，避免并发读取在提交前用旧数据重新填充缓存
+func AfterCommit(ctx context.Context, fn func()) {
+	h, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	if !ok {
+		fn()
+		return
+	}
+	h.mu.Lock()
+	h.hooks = append(h.hooks, fn)
+	h.mu.Unlock()
+}
+
+// RunAfterCommit 执行并清空 context 中注册的提交后回调
+func RunAfterCommit(ctx context.Context) {
+	h, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// TxFromContext 获取 context 中绑定的事务
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// FromContext 返回 context 中的事务，不存在时返回全局数据库连接
+func FromContext(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return GetDB().WithContext(ctx)
+}
+
+// Conn 返回 context 中的事务，不存在时返回当前实例的连接
+func (d *Database) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return d.DB.WithContext(ctx)
+}
+
+// RunInTx 在事务中执行 fn：context 中已有事务时直接复用，由外层持有者负责提交；
+// 否则开启新事务并绑定到传给 fn 的 context，fn 返回错误时回滚，提交成功后执行通过 AfterCommit 注册的回调
+func (d *Database) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	var txCtx context.Context
+	if err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txCtx = WithTx(ctx, tx)
+		return fn(txCtx)
+	}); err != nil {
+		return err
+	}
+	RunAfterCommit(txCtx)
+	return nil
+}
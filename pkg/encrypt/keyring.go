@@ -0,0 +1,138 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix 密文信封前缀（含格式版本），完整格式为 enc:1:<密钥ID>:<base64(nonce+密文)>
+// 读取时按整个信封区分密文与明文：前缀、密钥ID与 base64 数据都合法才视为密文，
+// 以 "enc:" 开头但不符合信封格式的明文（如启用加密前写入的数据）原样返回
+const Prefix = "enc:1:"
+
+// minSealedSize 最短的密文数据：12 字节 nonce + 16 字节 GCM 认证标签
+const minSealedSize = 12 + 16
+
+var (
+	// ErrUnknownKey 密文使用的密钥ID不在密钥环中（旧密钥已被移除）
+	ErrUnknownKey = errors.New("encryption key not found")
+	// ErrMalformed 密文格式错误或认证失败（被篡改、密钥不匹配）
+	ErrMalformed = errors.New("malformed ciphertext")
+)
+
+// keyIDPattern 密钥ID只允许小写字母、数字、下划线和连字符，不能包含分隔符 ":"
+// （配置中的 map 键会被转为小写，限制为小写避免大小写不一致）
+var keyIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// KeyRing AES-256-GCM 密钥环
+// 使用当前密钥加密，按密文中的密钥ID选择密钥解密；轮换时将旧密钥保留在密钥环中，已有数据仍可读取
+type KeyRing struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyRing 创建密钥环
+// primaryKey 为 base64 编码的 32 字节密钥，oldKeys 为轮换前的旧密钥（密钥ID -> base64 密钥），仅用于解密
+func NewKeyRing(primaryID, primaryKey string, oldKeys map[string]string) (*KeyRing, error) {
+	kr := &KeyRing{primary: primaryID, aeads: make(map[string]cipher.AEAD, len(oldKeys)+1)}
+	if err := kr.add(primaryID, primaryKey); err != nil {
+		return nil, err
+	}
+	for id, key := range oldKeys {
+		if id == primaryID {
+			return nil, fmt.Errorf("key id %q is both primary and old", id)
+		}
+		if err := kr.add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// add 解析并加入一个密钥
+func (kr *KeyRing) add(id, encoded string) error {
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid key id %q: must match %s", id, keyIDPattern)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("key %q is not valid base64: %w", id, err)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	kr.aeads[id] = aead
+	return nil
+}
+
+// PrimaryID 当前用于加密的密钥ID
+func (kr *KeyRing) PrimaryID() string {
+	return kr.primary
+}
+
+// Encrypt 使用当前密钥加密，返回带前缀与密钥ID的文本
+func (kr *KeyRing) Encrypt(plaintext []byte) (string, error) {
+	aead := kr.aeads[kr.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(kr.primary))
+	return Prefix + kr.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 生成的文本，按其中的密钥ID选择密钥
+func (kr *KeyRing) Decrypt(value string) ([]byte, error) {
+	id, sealed, ok := parseEnvelope(value)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	aead, ok := kr.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	// 密钥ID作为附加数据参与认证，防止篡改前缀
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted 判断值是否为符合信封格式的密文
+func IsEncrypted(value string) bool {
+	_, _, ok := parseEnvelope(value)
+	return ok
+}
+
+// parseEnvelope 解析密文信封，返回密钥ID与解码后的 nonce+密文
+func parseEnvelope(value string) (string, []byte, bool) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return "", nil, false
+	}
+	id, payload, ok := strings.Cut(rest, ":")
+	if !ok || !keyIDPattern.MatchString(id) {
+		return "", nil, false
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < minSealedSize {
+		return "", nil, false
+	}
+	return id, sealed, true
+}
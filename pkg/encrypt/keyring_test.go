@@ -0,0 +1,172 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newKey 生成 base64 编码的随机 32 字节密钥
+func newKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestKeyRing_RoundTrip(t *testing.T) {
+	kr, err := NewKeyRing("v1", newKey(t), nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+
+	plaintext := []byte(`{"password":"***","name":"alice"}`)
+	ciphertext, err := kr.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, "enc:1:v1:") {
+		t.Fatalf("ciphertext %q lacks enc:1:v1: prefix", ciphertext)
+	}
+	if strings.Contains(ciphertext, "alice") {
+		t.Fatal("ciphertext contains plaintext")
+	}
+
+	again, _ := kr.Encrypt(plaintext)
+	if again == ciphertext {
+		t.Fatal("two encryptions produced identical output, nonce reused")
+	}
+
+	got, err := kr.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("Decrypt = %q, want %q", got, plaintext)
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	oldKey := newKey(t)
+	before, err := NewKeyRing("v1", oldKey, nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing v1: %v", err)
+	}
+	legacy, _ := before.Encrypt([]byte("old record"))
+
+	after, err := NewKeyRing("v2", newKey(t), map[string]string{"v1": oldKey})
+	if err != nil {
+		t.Fatalf("NewKeyRing v2: %v", err)
+	}
+	if after.PrimaryID() != "v2" {
+		t.Fatalf("PrimaryID = %q, want v2", after.PrimaryID())
+	}
+
+	got, err := after.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt legacy: %v", err)
+	}
+	if string(got) != "old record" {
+		t.Fatalf("Decrypt legacy = %q", got)
+	}
+
+	fresh, _ := after.Encrypt([]byte("new record"))
+	if !strings.HasPrefix(fresh, "enc:1:v2:") {
+		t.Fatalf("new ciphertext %q not encrypted with primary key", fresh)
+	}
+	if _, err := before.Decrypt(fresh); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("old ring decrypting v2 data: err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyRing_DecryptRejectsTampering(t *testing.T) {
+	kr, err := NewKeyRing("v1", newKey(t), map[string]string{"v0": newKey(t)})
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	ciphertext, _ := kr.Encrypt([]byte("payload"))
+	payload := strings.TrimPrefix(ciphertext, "enc:1:v1:")
+
+	flipped := []byte(payload)
+	flipped[len(flipped)/2] ^= 'A' ^ 'B'
+	if flipped[len(flipped)/2] == payload[len(payload)/2] {
+		flipped[len(flipped)/2] = 'A'
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"flipped byte", "enc:1:v1:" + string(flipped)},
+		{"swapped key id", "enc:1:v0:" + payload},
+		{"truncated", "enc:1:v1:" + payload[:8]},
+		{"no key id", "enc:1:" + payload},
+		{"bad base64", "enc:1:v1:!!!"},
+		{"plaintext", "payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kr.Decrypt(tt.value); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("Decrypt(%q) err = %v, want ErrMalformed", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	kr, err := NewKeyRing("v1", newKey(t), nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	ciphertext, _ := kr.Encrypt([]byte("payload"))
+	if !IsEncrypted(ciphertext) {
+		t.Fatalf("IsEncrypted(%q) = false", ciphertext)
+	}
+
+	// 以 "enc:" 开头但不符合信封格式的明文
+	for _, value := range []string{
+		"enc:",
+		"enc:v1:hello world",
+		"enc:1:v1:",
+		"enc:1:v1:not base64!",
+		"enc:1:v1:c2hvcnQ",
+		"enc:1:V1:" + strings.TrimPrefix(ciphertext, "enc:1:v1:"),
+		"enc:1:" + strings.TrimPrefix(ciphertext, "enc:1:v1:"),
+		"payload",
+	} {
+		if IsEncrypted(value) {
+			t.Errorf("IsEncrypted(%q) = true, want false", value)
+		}
+	}
+}
+
+func TestNewKeyRing_Invalid(t *testing.T) {
+	valid := newKey(t)
+	tests := []struct {
+		name    string
+		id      string
+		key     string
+		oldKeys map[string]string
+	}{
+		{"empty id", "", valid, nil},
+		{"id with colon", "v:1", valid, nil},
+		{"uppercase id", "V1", valid, nil},
+		{"not base64", "v1", "not-base64!", nil},
+		{"short key", "v1", base64.StdEncoding.EncodeToString(make([]byte, 16)), nil},
+		{"bad old key", "v1", valid, map[string]string{"v0": "short"}},
+		{"old id equals primary", "v1", valid, map[string]string{"v1": valid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyRing(tt.id, tt.key, tt.oldKeys); err == nil {
+				t.Fatal("NewKeyRing succeeded, want error")
+			}
+		})
+	}
+}
@@ -6,14 +6,16 @@ const (
 	Success Code = 0
 
 	// 通用错误 1xxx
-	ErrBadRequest       Code = 1001
-	ErrUnauthorized     Code = 1002
-	ErrForbidden        Code = 1003
-	ErrNotFound         Code = 1004
-	ErrMethodNotAllowed Code = 1005
-	ErrConflict         Code = 1006
-	ErrTooManyRequests  Code = 1007
-	ErrInternalServer   Code = 1008
+	ErrBadRequest         Code = 1001
+	ErrUnauthorized       Code = 1002
+	ErrForbidden          Code = 1003
+	ErrNotFound           Code = 1004
+	ErrMethodNotAllowed   Code = 1005
+	ErrConflict           Code = 1006
+	ErrTooManyRequests    Code = 1007
+	ErrInternalServer     Code = 1008
+	ErrServiceDisabled    Code = 1009
+	ErrServiceUnavailable Code = 1010
 
 	// 参数验证错误 2xxx
 	ErrInvalidParams Code = 2001
@@ -32,19 +34,24 @@ const (
 	ErrTokenExpired     Code = 4002
 	ErrTokenMissing     Code = 4003
 	ErrPermissionDenied Code = 4004
+	ErrCaptchaRequired  Code = 4005
+	ErrCaptchaInvalid   Code = 4006
+	ErrTokenReused      Code = 4007
 )
 
 var codeText = map[Code]string{
 	Success: "success",
 
-	ErrBadRequest:       "bad request",
-	ErrUnauthorized:     "unauthorized",
-	ErrForbidden:        "forbidden",
-	ErrNotFound:         "not found",
-	ErrMethodNotAllowed: "method not allowed",
-	ErrConflict:         "conflict",
-	ErrTooManyRequests:  "too many requests",
-	ErrInternalServer:   "internal server error",
+	ErrBadRequest:         "bad request",
+	ErrUnauthorized:       "unauthorized",
+	ErrForbidden:          "forbidden",
+	ErrNotFound:           "not found",
+	ErrMethodNotAllowed:   "method not allowed",
+	ErrConflict:           "conflict",
+	ErrTooManyRequests:    "too many requests",
+	ErrInternalServer:     "internal server error",
+	ErrServiceDisabled:    "service disabled",
+	ErrServiceUnavailable: "service unavailable",
 
 	ErrInvalidParams: "invalid parameters",
 	ErrBindJSON:      "failed to bind json",
@@ -60,6 +67,9 @@ var codeText = map[Code]string{
 	ErrTokenExpired:     "token is expired",
 	ErrTokenMissing:     "token is missing",
 	ErrPermissionDenied: "permission denied",
+	ErrCaptchaRequired:  "captcha required",
+	ErrCaptchaInvalid:   "captcha is invalid",
+	ErrTokenReused:      "refresh token reuse detected",
 }
 
 func (c Code) String() string {
@@ -73,9 +83,9 @@ func (c Code) HTTPStatus() int {
 	switch c {
 	case Success:
 		return 200
-	case ErrBadRequest, ErrInvalidParams, ErrBindJSON, ErrBindQuery, ErrBindForm:
+	case ErrBadRequest, ErrInvalidParams, ErrBindJSON, ErrBindQuery, ErrBindForm, ErrCaptchaRequired, ErrCaptchaInvalid:
 		return 400
-	case ErrUnauthorized, ErrTokenInvalid, ErrTokenExpired, ErrTokenMissing:
+	case ErrUnauthorized, ErrTokenInvalid, ErrTokenExpired, ErrTokenMissing, ErrTokenReused:
 		return 401
 	case ErrForbidden, ErrPermissionDenied:
 		return 403
@@ -87,6 +97,10 @@ func (c Code) HTTPStatus() int {
 		return 409
 	case ErrTooManyRequests:
 		return 429
+	case ErrServiceDisabled:
+		return 501
+	case ErrServiceUnavailable:
+		return 503
 	default:
 		return 500
 	}
@@ -10,13 +10,15 @@ import (
 )
 
 type Config struct {
-	Level      string `mapstructure:"level"`
-	Format     string `mapstructure:"format"`
-	Output     string `mapstructure:"output"`
-	FilePath   string `mapstructure:"file_path"`
-	MaxSize    int    `mapstructure:"max_size"`
-	MaxBackups int    `mapstructure:"max_backups"`
-	MaxAge     int    `mapstructure:"max_age"`
+	Level        string `mapstructure:"level"`
+	Format       string `mapstructure:"format"`
+	StdoutFormat string `mapstructure:"stdout_format"` // 标准输出格式，为空时使用 Format
+	FileFormat   string `mapstructure:"file_format"`   // 文件输出格式，为空时使用 Format
+	Output       string `mapstructure:"output"`
+	FilePath     string `mapstructure:"file_path"`
+	MaxSize      int    `mapstructure:"max_size"`
+	MaxBackups   int    `mapstructure:"max_backups"`
+	MaxAge       int    `mapstructure:"max_age"`
 }
 
 var defaultLogger *slog.Logger
@@ -24,21 +26,6 @@ var defaultLogger *slog.Logger
 func Init(cfg *Config) error {
 	level := parseLevel(cfg.Level)
 
-	var writer io.Writer
-	if cfg.Output == "file" {
-		if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
-			return err
-		}
-		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return err
-		}
-		writer = file
-	} else {
-		writer = os.Stdout
-	}
-
-	var handler slog.Handler
 	opts := &slog.HandlerOptions{
 		Level: level,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
@@ -49,10 +36,25 @@ func Init(cfg *Config) error {
 		},
 	}
 
-	if cfg.Format == "json" {
-		handler = slog.NewJSONHandler(writer, opts)
-	} else {
-		handler = slog.NewTextHandler(writer, opts)
+	var handler slog.Handler
+	switch cfg.Output {
+	case "file":
+		file, err := openLogFile(cfg.FilePath)
+		if err != nil {
+			return err
+		}
+		handler = newHandler(file, formatOrDefault(cfg.FileFormat, cfg.Format), opts)
+	case "both":
+		file, err := openLogFile(cfg.FilePath)
+		if err != nil {
+			return err
+		}
+		handler = newMultiHandler(
+			newHandler(os.Stdout, formatOrDefault(cfg.StdoutFormat, cfg.Format), opts),
+			newHandler(file, formatOrDefault(cfg.FileFormat, cfg.Format), opts),
+		)
+	default:
+		handler = newHandler(os.Stdout, formatOrDefault(cfg.StdoutFormat, cfg.Format), opts)
 	}
 
 	defaultLogger = slog.New(handler)
@@ -66,6 +68,29 @@ func Init(cfg *Config) error {
 	return nil
 }
 
+// openLogFile 打开（必要时创建）日志文件
+func openLogFile(path string) (io.Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+}
+
+// newHandler 根据格式创建 JSON 或文本 Handler
+func newHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+func formatOrDefault(format, fallback string) string {
+	if format != "" {
+		return format
+	}
+	return fallback
+}
+
 func parseLevel(level string) slog.Level {
 	switch level {
 	case "debug":
@@ -0,0 +1,220 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/config"
+)
+
+// fakeSMTP 极简 SMTP 服务器，记录收到的信封与邮件内容
+type fakeSMTP struct {
+	ln       net.Listener
+	from     string
+	rcpt     []string
+	data     string
+	received chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{ln: ln, received: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 end with .")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.data = b.String()
+			reply("250 queued")
+			close(s.received)
+		case upper == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newFakeSMTP(t)
+	m, err := NewSMTPMailer(&config.MailConfig{
+		Host:    "127.0.0.1",
+		Port:    server.port(),
+		From:    "Nova <no-reply@example.com>",
+		TLSMode: TLSModeNone,
+		Timeout: 5,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	body := "点击链接登录：\nhttps://app.example.com/login?token=abc\n"
+	if err := m.Send(context.Background(), &Message{To: "alice@example.com", Subject: "登录链接", Body: body}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case <-server.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	if server.from != "no-reply@example.com" {
+		t.Errorf("MAIL FROM = %q", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v", server.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "登录链接" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if got, want := string(decoded), strings.ReplaceAll(body, "\n", "\r\n"); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestSMTPMailerRequiresStartTLS(t *testing.T) {
+	server := newFakeSMTP(t)
+	m, err := NewSMTPMailer(&config.MailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "no-reply@example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	err = m.Send(context.Background(), &Message{To: "alice@example.com", Subject: "hi", Body: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send error = %v, want STARTTLS refusal", err)
+	}
+}
+
+func TestSendRejectsHeaderInjection(t *testing.T) {
+	m, err := NewSMTPMailer(&config.MailConfig{Host: "127.0.0.1", Port: 1, From: "no-reply@example.com"})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	tests := []*Message{
+		{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "hi"},
+		{To: "alice@example.com", Subject: "hi\r\nBcc: eve@example.com"},
+	}
+	for _, msg := range tests {
+		if err := m.Send(context.Background(), msg); err == nil {
+			t.Errorf("Send(%q, %q) succeeded, want error", msg.To, msg.Subject)
+		}
+	}
+}
+
+func TestSendHonorsTimeout(t *testing.T) {
+	// 接受连接但从不响应的服务器
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	m, err := NewSMTPMailer(&config.MailConfig{
+		Host:    "127.0.0.1",
+		Port:    ln.Addr().(*net.TCPAddr).Port,
+		From:    "no-reply@example.com",
+		TLSMode: TLSModeNone,
+		Timeout: 10,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Send(ctx, &Message{To: "alice@example.com", Subject: "hi", Body: "hi"}); err == nil {
+		t.Fatal("Send succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Send took %v, want it bounded by the context", elapsed)
+	}
+}
+
+func TestNewSMTPMailerRejectsInvalidFrom(t *testing.T) {
+	if _, err := NewSMTPMailer(&config.MailConfig{Host: "localhost", Port: 25, From: "not an address"}); err == nil {
+		t.Fatal("NewSMTPMailer accepted an invalid from address")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -14,14 +15,24 @@ import (
 	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/errors"
 	"github.com/labstack/echo/v4"
 )
 
 // AuditLogMiddleware 审计日志中间件配置
 type AuditLogMiddleware struct {
-	config   *config.AuditLogConfig
-	repo     repository.AuditLogRepository
-	disabled bool
+	config    *config.AuditLogConfig
+	repo      repository.AuditLogRepository
+	disabled  bool
+	overrides map[string]auditAction // 路由器通过 Override 注册的覆盖，key: "METHOD route"，method 为 "*" 表示任意方法
+	// 配置文件中的覆盖，优先于 overrides，便于不改代码修正内置规则
+	configOverrides map[string]auditAction
+}
+
+// auditAction 覆盖后的动作与资源
+type auditAction struct {
+	action   string
+	resource string
 }
 
 // NewAuditLogMiddleware 创建审计日志中间件
@@ -30,11 +41,50 @@ func NewAuditLogMiddleware(cfg *config.AuditLogConfig, db *database.Database) *A
 		return &AuditLogMiddleware{disabled: true}
 	}
 
+	configOverrides := make(map[string]auditAction, len(cfg.ActionOverrides))
+	for _, o := range cfg.ActionOverrides {
+		configOverrides[overrideKey(o.Method, o.Route)] = auditAction{action: o.Action, resource: o.Resource}
+	}
+
 	return &AuditLogMiddleware{
-		config:   cfg,
-		repo:     repository.NewAuditLogRepository(db),
-		disabled: false,
+		config:          cfg,
+		repo:            repository.NewAuditLogRepository(db),
+		disabled:        false,
+		overrides:       make(map[string]auditAction),
+		configOverrides: configOverrides,
+	}
+}
+
+// Override 为路由注册动作/资源覆盖，审计时优先于按方法和路径推断
+// route 为 Echo 路由模板（如 /api/v1/roles/:id/permissions/update），method 为空或 "*" 表示任意方法；
+// resource 为空时沿用推断结果；配置文件 action_overrides 中的同一路由优先。需在服务启动前调用（非并发安全）
+func (m *AuditLogMiddleware) Override(method, route, action, resource string) {
+	if m.disabled {
+		return
+	}
+	m.overrides[overrideKey(method, route)] = auditAction{action: action, resource: resource}
+}
+
+// overrideKey 构建覆盖表的键，method 为空时视为任意方法
+func overrideKey(method, route string) string {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = "*"
 	}
+	return method + " " + route
+}
+
+// lookupOverride 查找路由覆盖：配置优先于代码注册，精确方法优先于任意方法
+func (m *AuditLogMiddleware) lookupOverride(method, route string) (auditAction, bool) {
+	for _, table := range []map[string]auditAction{m.configOverrides, m.overrides} {
+		if o, ok := table[method+" "+route]; ok {
+			return o, true
+		}
+		if o, ok := table["* "+route]; ok {
+			return o, true
+		}
+	}
+	return auditAction{}, false
 }
 
 // Handler 审计日志中间件处理函数
@@ -46,20 +96,32 @@ func (m *AuditLogMiddleware) Handler() echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			// 检查是否在排除路径中
-			if m.isExcluded(c.Request().URL.Path) {
-				return next(c)
-			}
-
 			// 记录开始时间
 			startTime := time.Now()
 
+			// 检查是否在排除路径中（导出操作仍单独记录）
+			if m.isExcluded(c.Request().URL.Path) {
+				err := next(c)
+				if export := getExportRecord(c); export != nil {
+					auditLog := m.buildExportLog(c, export, time.Since(startTime), err)
+					if saveErr := m.save(c, auditLog); saveErr != nil && err == nil {
+						return errors.Wrap(errors.ErrDatabase, fmt.Errorf("failed to save audit log: %w", saveErr))
+					}
+				}
+				return err
+			}
+
 			// 捕获请求体（如果需要）
+			// 仅捕获允许的内容类型（如 JSON/文本），multipart 上传等二进制内容直接跳过，避免整体读入内存
 			var requestBody string
-			if m.config.LogRequest && c.Request().Body != nil {
-				bodyBytes, _ := io.ReadAll(c.Request().Body)
-				// 恢复请求体供后续使用
-				c.Request().Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+			if m.config.LogRequest && c.Request().Body != nil && m.isCapturable(c.Request().Header.Get(echo.HeaderContentType)) {
+				original := c.Request().Body
+				bodyBytes, _ := io.ReadAll(io.LimitReader(original, int64(m.config.MaxBodySize)+1))
+				// 恢复请求体供后续使用（已读取部分 + 剩余未读取部分）
+				c.Request().Body = &multiReadCloser{
+					Reader: io.MultiReader(bytes.NewReader(bodyBytes), original),
+					Closer: original,
+				}
 
 				// 限制记录大小
 				if len(bodyBytes) > m.config.MaxBodySize {
@@ -72,11 +134,14 @@ func (m *AuditLogMiddleware) Handler() echo.MiddlewareFunc {
 				requestBody = m.maskSensitiveData(requestBody)
 			}
 
-			// 创建自定义响应写入器以捕获响应
-			resBody := new(bytes.Buffer)
-			mw := io.MultiWriter(c.Response().Writer, resBody)
-			writer := &bodyDumpResponseWriter{Writer: mw, ResponseWriter: c.Response().Writer}
-			c.Response().Writer = writer
+			// 创建自定义响应写入器以捕获响应（最多缓存 max_body_size+1 字节）
+			var resBody *limitedBuffer
+			if m.config.LogResponse {
+				resBody = &limitedBuffer{limit: m.config.MaxBodySize + 1}
+				mw := io.MultiWriter(c.Response().Writer, resBody)
+				writer := &bodyDumpResponseWriter{Writer: mw, ResponseWriter: c.Response().Writer}
+				c.Response().Writer = writer
+			}
 
 			// 执行实际的处理函数
 			err := next(c)
@@ -86,7 +151,7 @@ func (m *AuditLogMiddleware) Handler() echo.MiddlewareFunc {
 
 			// 获取响应体（如果需要）
 			var responseBody string
-			if m.config.LogResponse {
+			if resBody != nil && m.isCapturable(c.Response().Header().Get(echo.HeaderContentType)) {
 				respBytes := resBody.Bytes()
 				if len(respBytes) > m.config.MaxBodySize {
 					responseBody = string(respBytes[:m.config.MaxBodySize]) + "...(truncated)"
@@ -127,20 +192,107 @@ func (m *AuditLogMiddleware) Handler() echo.MiddlewareFunc {
 				Duration:   int64(duration),
 				Error:      errorMsg,
 			}
-
-			// 异步保存审计日志（不阻塞主流程）
-			go func() {
-				if saveErr := m.repo.Create(c.Request().Context(), auditLog); saveErr != nil {
-					// 记录日志保存失败，但不影响主流程
-					// 可以在这里添加日志记录
+			if changes := c.Get(auditChangesKey); changes != nil {
+				if extra, err := json.Marshal(map[string]interface{}{"changes": changes}); err == nil {
+					auditLog.Extra = string(extra)
 				}
-			}()
+			}
+
+			// 导出操作记录为 export 动作，并附带查询参数与导出行数
+			if export := getExportRecord(c); export != nil {
+				exportLog := m.buildExportLog(c, export, duration, err)
+				exportLog.Request = requestBody
+				exportLog.Response = responseBody
+				auditLog = exportLog
+			}
+
+			if saveErr := m.save(c, auditLog); saveErr != nil && err == nil {
+				return errors.Wrap(errors.ErrDatabase, fmt.Errorf("failed to save audit log: %w", saveErr))
+			}
 
 			return err
 		}
 	}
 }
 
+// save 保存审计日志
+// 请求处于事务中（见 Transactional）时同步写入同一事务并返回错误，以便与业务写入一起提交或回滚；
+// 否则异步保存，不阻塞主流程
+func (m *AuditLogMiddleware) save(c echo.Context, auditLog *model.AuditLog) error {
+	if _, ok := database.TxFromContext(c.Request().Context()); ok {
+		return m.repo.Create(c.Request().Context(), auditLog)
+	}
+
+	go func() {
+		if saveErr := m.repo.Create(c.Request().Context(), auditLog); saveErr != nil {
+			// 记录日志保存失败，但不影响主流程
+			// 可以在这里添加日志记录
+		}
+	}()
+	return nil
+}
+
+// auditExportKey 导出记录在请求上下文中的键
+const auditExportKey = "audit_export"
+
+// exportRecord 处理器登记的导出信息
+type exportRecord struct {
+	Resource string
+	Count    int
+}
+
+// RecordExport 登记一次数据导出
+// 导出类接口（如审计日志 CSV、RBAC 导出、文件批量下载）在写出数据后调用，
+// 审计中间件会将本次请求记录为 export 动作，附带查询参数、导出行数和请求用户；
+// 即使请求路径在排除列表中也会记录
+func RecordExport(c echo.Context, resource string, count int) {
+	c.Set(auditExportKey, &exportRecord{Resource: resource, Count: count})
+}
+
+// auditChangesKey 变更明细在请求上下文中的键
+const auditChangesKey = "audit_changes"
+
+// RecordChanges 登记本次请求修改的字段及新旧值
+// 审计中间件将其以 {"changes": ...} 写入审计日志的 extra 字段，便于追溯资料变更；
+// extra 不做敏感字段脱敏，调用方不应登记密码等敏感值
+func RecordChanges(c echo.Context, changes interface{}) {
+	c.Set(auditChangesKey, changes)
+}
+
+// getExportRecord 获取处理器登记的导出信息
+func getExportRecord(c echo.Context) *exportRecord {
+	export, _ := c.Get(auditExportKey).(*exportRecord)
+	return export
+}
+
+// buildExportLog 构建导出审计日志
+func (m *AuditLogMiddleware) buildExportLog(c echo.Context, export *exportRecord, duration time.Duration, err error) *model.AuditLog {
+	extra, _ := json.Marshal(map[string]interface{}{
+		"query": c.QueryParams(),
+		"count": export.Count,
+	})
+
+	var errorMsg string
+	if err != nil {
+		errorMsg = err.Error()
+	}
+
+	return &model.AuditLog{
+		UserID:     GetUserID(c),
+		Username:   GetUsername(c),
+		Action:     model.AuditActionExport,
+		Resource:   export.Resource,
+		Method:     c.Request().Method,
+		Path:       c.Request().URL.Path,
+		IP:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		StatusCode: c.Response().Status,
+		Duration:   int64(duration),
+		Error:      errorMsg,
+		Extra:      string(extra),
+	}
+}
+
 // isExcluded 检查路径是否在排除列表中
 func (m *AuditLogMiddleware) isExcluded(path string) bool {
 	for _, excludePath := range m.config.ExcludePaths {
@@ -151,6 +303,31 @@ func (m *AuditLogMiddleware) isExcluded(path string) bool {
 	return false
 }
 
+// defaultCaptureContentTypes 未配置 capture_content_types 时允许捕获的内容类型前缀
+var defaultCaptureContentTypes = []string{"application/json", "text/"}
+
+// isCapturable 检查内容类型是否允许记录请求/响应体
+func (m *AuditLogMiddleware) isCapturable(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+
+	allowed := m.config.CaptureContentTypes
+	if len(allowed) == 0 {
+		allowed = defaultCaptureContentTypes
+	}
+	for _, prefix := range allowed {
+		if strings.HasPrefix(contentType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
 // extractActionInfo 从路径和方法提取操作信息
 func (m *AuditLogMiddleware) extractActionInfo(c echo.Context) (action, resource, resourceID string) {
 	method := c.Request().Method
@@ -192,6 +369,14 @@ func (m *AuditLogMiddleware) extractActionInfo(c echo.Context) (action, resource
 		resource = resource[:len(resource)-1]
 	}
 
+	// 路由覆盖优先于上述推断
+	if override, ok := m.lookupOverride(method, c.Path()); ok {
+		action = override.action
+		if override.resource != "" {
+			resource = override.resource
+		}
+	}
+
 	return action, resource, resourceID
 }
 
@@ -224,6 +409,29 @@ func (m *AuditLogMiddleware) maskSensitiveData(data string) string {
 	return string(maskedData)
 }
 
+// multiReadCloser 组合读取器与原始请求体的关闭方法
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// limitedBuffer 只保留前 limit 字节的缓冲区，超出部分丢弃但不报错
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Buffer.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.Buffer.Write(p[:remaining])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
 // bodyDumpResponseWriter 自定义响应写入器
 type bodyDumpResponseWriter struct {
 	io.Writer
@@ -13,21 +13,18 @@ const (
 	BearerPrefix        = "Bearer "
 	UserIDKey           = "user_id"
 	UsernameKey         = "username"
+	SessionIDKey        = "session_id"
+	ExtraClaimsKey      = "extra_claims"
+
+	// Cookie 模式下的令牌 Cookie 名称
+	AccessTokenCookie  = "nova_access_token"
+	RefreshTokenCookie = "nova_refresh_token"
 )
 
 func Auth(jwtAuth *auth.JWTAuth, blacklist *auth.TokenBlacklist) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			authHeader := c.Request().Header.Get(AuthorizationHeader)
-			if authHeader == "" {
-				return errors.New(errors.ErrUnauthorized, "")
-			}
-
-			if !strings.HasPrefix(authHeader, BearerPrefix) {
-				return errors.New(errors.ErrUnauthorized, "")
-			}
-
-			tokenString := strings.TrimPrefix(authHeader, BearerPrefix)
+			tokenString := ExtractToken(c)
 			if tokenString == "" {
 				return errors.New(errors.ErrUnauthorized, "")
 			}
@@ -56,16 +53,42 @@ func Auth(jwtAuth *auth.JWTAuth, blacklist *auth.TokenBlacklist) echo.Middleware
 				if err == nil && userBlacklisted {
 					return errors.New(errors.ErrUnauthorized, "user has been logged out")
 				}
+
+				// 检查会话是否已被撤销
+				sessionRevoked, err := blacklist.IsSessionInBlacklist(c.Request().Context(), claims.ID)
+				if err == nil && sessionRevoked {
+					return errors.New(errors.ErrUnauthorized, "session has been revoked")
+				}
 			}
 
 			c.Set(UserIDKey, claims.UserID)
 			c.Set(UsernameKey, claims.Username)
+			c.Set(SessionIDKey, claims.ID)
+			if len(claims.Extra) > 0 {
+				c.Set(ExtraClaimsKey, claims.Extra)
+			}
 
 			return next(c)
 		}
 	}
 }
 
+// ExtractToken 提取访问令牌
+// 优先读取 Authorization 头，缺失时读取 Cookie（auth.use_cookies 模式）
+func ExtractToken(c echo.Context) string {
+	if authHeader := c.Request().Header.Get(AuthorizationHeader); authHeader != "" {
+		if !strings.HasPrefix(authHeader, BearerPrefix) {
+			return ""
+		}
+		return strings.TrimPrefix(authHeader, BearerPrefix)
+	}
+
+	if cookie, err := c.Cookie(AccessTokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
 func GetUserID(c echo.Context) uint {
 	userID, ok := c.Get(UserIDKey).(uint)
 	if !ok {
@@ -81,3 +104,22 @@ func GetUsername(c echo.Context) string {
 	}
 	return username
 }
+
+func GetSessionID(c echo.Context) string {
+	sessionID, ok := c.Get(SessionIDKey).(string)
+	if !ok {
+		return ""
+	}
+	return sessionID
+}
+
+// GetClaim 获取令牌中的自定义声明（Claims.Extra），不存在时 ok=false
+// 数值类型经 JSON 解码后为 float64
+func GetClaim(c echo.Context, key string) (any, bool) {
+	extra, ok := c.Get(ExtraClaimsKey).(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	val, ok := extra[key]
+	return val, ok
+}
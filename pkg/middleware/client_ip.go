@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor 根据可信代理列表构造客户端 IP 提取器
+// 未配置可信代理时直接使用 TCP 对端地址；配置后仅当请求经由这些代理时才采信 X-Forwarded-For，
+// 避免客户端伪造转发头绕过 IP 白名单或按 IP 限流
+func IPExtractor(trustedProxies []string) echo.IPExtractor {
+	nets := parseAllowedIPs(trustedProxies)
+	if len(nets) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, n := range nets {
+		options = append(options, echo.TrustIPRange(n))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// clientIP 获取客户端 IP
+// 使用服务器配置的 IPExtractor；未配置时退回 TCP 对端地址，而不是 Echo 默认的信任转发头
+func clientIP(c echo.Context) string {
+	if c.Echo().IPExtractor != nil {
+		return c.RealIP()
+	}
+	return echo.ExtractIPDirect()(c.Request())
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// DomainMembershipConfig 域成员校验中间件配置
+type DomainMembershipConfig struct {
+	// IsMember 判断用户在指定域下是否拥有角色（必填）
+	IsMember func(ctx context.Context, userID uint, domain string) (bool, error)
+	// CanBypass 判断用户是否可访问其不拥有角色的域（如超级管理员），为 nil 时不允许跳过
+	CanBypass func(ctx context.Context, userID uint, domain string) (bool, error)
+	// CacheTTL 成员关系缓存时间，为 0 时默认 5 分钟；用户角色分配或撤销时缓存会被清理
+	CacheTTL time.Duration
+	Skipper  func(c echo.Context) bool // 跳过某些路径
+	Logger   *slog.Logger              // 日志记录器
+}
+
+// DomainMembership 校验请求指定的域（X-Domain 头或 domain 查询参数）是否为当前用户所属的域
+// 未显式指定域的请求使用默认域，不做校验；校验通过后将域写入上下文供后续中间件使用
+func DomainMembership(config DomainMembershipConfig) echo.MiddlewareFunc {
+	if config.IsMember == nil {
+		panic("domain membership checker is required")
+	}
+
+	if config.CacheTTL == 0 {
+		config.CacheTTL = 5 * time.Minute
+	}
+
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	if config.Skipper == nil {
+		config.Skipper = func(c echo.Context) bool { return false }
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			domain := c.Request().Header.Get("X-Domain")
+			if domain == "" {
+				domain = c.QueryParam("domain")
+			}
+			if domain == "" {
+				return next(c)
+			}
+
+			userID := GetUserID(c)
+			if userID == 0 {
+				return errors.New(errors.ErrUnauthorized, "")
+			}
+
+			ctx := c.Request().Context()
+			allowed, err := checkDomainMembership(ctx, config, userID, domain)
+			if err != nil {
+				config.Logger.Error("domain membership check failed",
+					"user_id", userID,
+					"domain", domain,
+					"error", err,
+				)
+				return errors.Wrap(errors.ErrInternalServer, err)
+			}
+
+			if !allowed {
+				config.Logger.Warn("domain access denied",
+					"user_id", userID,
+					"domain", domain,
+				)
+				return errors.New(errors.ErrForbidden, "no access to domain")
+			}
+
+			c.Set("domain", domain)
+			return next(c)
+		}
+	}
+}
+
+// userDefaultDomainKey 用户默认域在请求上下文中的键
+const userDefaultDomainKey = "user_default_domain"
+
+// UserDefaultDomainConfig 用户默认域中间件配置
+type UserDefaultDomainConfig struct {
+	// Lookup 查询用户的默认域（必填），未设置时返回空字符串；实现方负责缓存
+	Lookup  func(ctx context.Context, userID uint) (string, error)
+	Skipper func(c echo.Context) bool // 跳过某些路径
+	Logger  *slog.Logger              // 日志记录器
+}
+
+// UserDefaultDomain 为未显式指定域（X-Domain 头或 domain 查询参数）的请求加载当前用户的默认域
+// 加载结果写入上下文，GetDomain / 权限中间件在没有显式域时使用它，之后才回退到配置的默认域和 "default"。
+// 查询失败只记录日志，按未设置默认域处理
+func UserDefaultDomain(config UserDefaultDomainConfig) echo.MiddlewareFunc {
+	if config.Lookup == nil {
+		panic("default domain lookup is required")
+	}
+
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	if config.Skipper == nil {
+		config.Skipper = func(c echo.Context) bool { return false }
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			if c.Request().Header.Get("X-Domain") != "" || c.QueryParam("domain") != "" {
+				return next(c)
+			}
+
+			userID := GetUserID(c)
+			if userID == 0 {
+				return next(c)
+			}
+
+			domain, err := config.Lookup(c.Request().Context(), userID)
+			if err != nil {
+				config.Logger.Warn("failed to load user default domain",
+					"user_id", userID,
+					"error", err,
+				)
+				return next(c)
+			}
+			if domain != "" {
+				c.Set(userDefaultDomainKey, domain)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetDomain 获取当前请求的域
+// 优先级：中间件写入的域 > X-Domain 头 > domain 查询参数 > 用户默认域 > "default"
+func GetDomain(c echo.Context) string {
+	return getDomain(c, "")
+}
+
+// checkDomainMembership 检查域成员关系（带缓存）
+func checkDomainMembership(ctx context.Context, config DomainMembershipConfig, userID uint, domain string) (bool, error) {
+	key := cache.DomainMembershipKey(userID, domain)
+	if val, err := cache.Get(ctx, key); err == nil {
+		return val == "1", nil
+	}
+
+	allowed, err := config.IsMember(ctx, userID, domain)
+	if err != nil {
+		return false, err
+	}
+
+	if !allowed && config.CanBypass != nil {
+		allowed, err = config.CanBypass(ctx, userID, domain)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	val := "0"
+	if allowed {
+		val = "1"
+	}
+	_ = cache.Set(ctx, key, val, config.CacheTTL)
+
+	return allowed, nil
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// RequireFeature 功能开关中间件
+// 功能未启用时直接返回 501，避免客户端收到看似正常的空数据
+func RequireFeature(enabled bool, feature string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !enabled {
+				return errors.New(errors.ErrServiceDisabled, feature+" service disabled")
+			}
+			return next(c)
+		}
+	}
+}
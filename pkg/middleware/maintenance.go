@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// maintenanceKey 维护模式的运行时设置（Redis，多实例共享）
+const maintenanceKey = "maintenance:state"
+
+// MaintenanceState 维护模式状态
+type MaintenanceState struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message"`
+	Source    string     `json:"source"`               // config：配置文件；runtime：管理接口设置
+	UpdatedBy uint       `json:"updated_by,omitempty"` // 运行时设置的操作人
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // 运行时设置的时间
+}
+
+// MaintenanceConfig 维护模式配置
+type MaintenanceConfig struct {
+	Enabled       bool          // 启动时是否处于维护模式，Redis 中的运行时设置优先
+	Message       string        // 默认提示信息，运行时设置未指定提示时使用
+	RetryAfter    int           // 503 响应 Retry-After 的秒数
+	AllowIPs      []string      // 维护期间放行的来源 IP 或 CIDR（如运维网段）
+	AllowPaths    []string      // 维护期间放行的请求路径，以 * 结尾时按前缀匹配
+	CheckInterval time.Duration // 读取 Redis 运行时设置的最短间隔，期间使用本实例缓存的状态
+	Logger        *slog.Logger  // 日志记录器
+}
+
+// Maintenance 维护模式
+// 开启后除健康检查与放行名单外的请求一律返回 503 与提示信息；状态可由配置给出，也可通过管理接口在运行时切换（写入 Redis，各实例在 CheckInterval 内生效）
+type Maintenance struct {
+	config MaintenanceConfig
+	nets   []*net.IPNet
+
+	current atomic.Pointer[maintenanceSnapshot] // 本实例缓存的状态，请求路径上无锁读取
+	refresh singleflight.Group                  // 缓存过期时合并并发的 Redis 读取
+}
+
+// maintenanceSnapshot 缓存的状态及其读取时间
+type maintenanceSnapshot struct {
+	state     MaintenanceState
+	checkedAt time.Time
+}
+
+// fresh 是否仍在 CheckInterval 内
+func (s *maintenanceSnapshot) fresh(interval time.Duration) bool {
+	return !s.checkedAt.IsZero() && time.Since(s.checkedAt) < interval
+}
+
+// NewMaintenance 创建维护模式
+func NewMaintenance(config MaintenanceConfig) *Maintenance {
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.RetryAfter < 1 {
+		config.RetryAfter = 1
+	}
+	m := &Maintenance{
+		config: config,
+		nets:   parseAllowedIPs(config.AllowIPs),
+	}
+	m.current.Store(&maintenanceSnapshot{
+		state: MaintenanceState{Enabled: config.Enabled, Message: config.Message, Source: "config"},
+	})
+	return m
+}
+
+// State 返回当前状态
+// 优先使用 Redis 中的运行时设置，没有时使用配置；结果在本实例缓存 CheckInterval，Redis 不可用时沿用上一次的状态。
+// 缓存的状态无锁读取，过期后只有一个请求访问 Redis，其余并发请求等待同一次读取的结果，不持有锁等待网络 IO
+func (m *Maintenance) State(ctx context.Context) MaintenanceState {
+	if snap := m.current.Load(); snap.fresh(m.config.CheckInterval) {
+		return snap.state
+	}
+
+	v, _, _ := m.refresh.Do(maintenanceKey, func() (interface{}, error) {
+		last := m.current.Load()
+		if last.fresh(m.config.CheckInterval) {
+			return last.state, nil
+		}
+
+		next := &maintenanceSnapshot{state: last.state}
+		state, err := m.load(ctx)
+		if err != nil {
+			m.config.Logger.Warn("failed to load maintenance state, keeping last state", "error", err)
+		} else {
+			next.state = state
+		}
+		next.checkedAt = time.Now()
+
+		// 读取期间 Set 已更新本实例状态时以 Set 为准，避免用更早读到的值覆盖
+		if !m.current.CompareAndSwap(last, next) {
+			return m.current.Load().state, nil
+		}
+		return next.state, nil
+	})
+	return v.(MaintenanceState)
+}
+
+// load 读取运行时设置，未设置时返回配置中的状态
+func (m *Maintenance) load(ctx context.Context) (MaintenanceState, error) {
+	val, err := cache.Get(ctx, maintenanceKey)
+	if err == redis.Nil {
+		return MaintenanceState{Enabled: m.config.Enabled, Message: m.config.Message, Source: "config"}, nil
+	}
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+
+	var state MaintenanceState
+	if err := json.Unmarshal([]byte(val), &state); err != nil {
+		return MaintenanceState{}, fmt.Errorf("invalid maintenance state: %w", err)
+	}
+	return state, nil
+}
+
+// Set 在运行时开启或关闭维护模式，覆盖配置中的状态；message 为空时使用配置的默认提示
+// 本实例立即生效，其他实例在 CheckInterval 内生效
+func (m *Maintenance) Set(ctx context.Context, enabled bool, message string, operatorID uint) (MaintenanceState, error) {
+	if message == "" {
+		message = m.config.Message
+	}
+	now := time.Now()
+	state := MaintenanceState{
+		Enabled:   enabled,
+		Message:   message,
+		Source:    "runtime",
+		UpdatedBy: operatorID,
+		UpdatedAt: &now,
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+	if err := cache.Set(ctx, maintenanceKey, data, 0); err != nil {
+		return MaintenanceState{}, err
+	}
+
+	m.current.Store(&maintenanceSnapshot{state: state, checkedAt: now})
+	return state, nil
+}
+
+// Middleware 返回维护模式中间件，skipPaths 中的路由（健康检查、维护模式管理接口）始终放行
+// 应在限流、认证等中间件之前注册，维护期间被拒绝的请求不占用其他资源
+func (m *Maintenance) Middleware(skipPaths ...string) echo.MiddlewareFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skip[c.Path()] {
+				return next(c)
+			}
+
+			state := m.State(c.Request().Context())
+			if !state.Enabled || m.allowed(c) {
+				return next(c)
+			}
+
+			c.Response().Header().Set("Retry-After", fmt.Sprint(m.config.RetryAfter))
+			return errors.NewWithDetails(errors.ErrServiceUnavailable, state.Message, map[string]interface{}{
+				"maintenance": true,
+				"retry_after": m.config.RetryAfter,
+			})
+		}
+	}
+}
+
+// allowed 请求路径或来源 IP 是否在放行名单中
+func (m *Maintenance) allowed(c echo.Context) bool {
+	path := c.Request().URL.Path
+	for _, p := range m.config.AllowPaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+
+	if ip := net.ParseIP(clientIP(c)); ip != nil {
+		for _, n := range m.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMaintenance_Middleware(t *testing.T) {
+	testutil.NewRedis(t)
+
+	maintenance := NewMaintenance(MaintenanceConfig{
+		Message:    "升级中",
+		RetryAfter: 120,
+		AllowIPs:   []string{"10.0.0.0/8"},
+		AllowPaths: []string{"/api/v1/auth/login", "/api/v1/public/*"},
+	})
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	e.Use(maintenance.Middleware("/api/v1/health"))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	for _, path := range []string{"/api/v1/health", "/api/v1/users", "/api/v1/auth/login", "/api/v1/auth/register", "/api/v1/public/files/:token"} {
+		e.GET(path, ok)
+	}
+
+	do := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("/api/v1/users", "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+		t.Fatalf("disabled: status = %d, want 204", rec.Code)
+	}
+
+	if _, err := maintenance.Set(context.Background(), true, "", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		path, remoteAddr string
+		want             int
+	}{
+		{"/api/v1/users", "192.0.2.1:1234", http.StatusServiceUnavailable},
+		{"/api/v1/auth/register", "192.0.2.1:1234", http.StatusServiceUnavailable},
+		{"/api/v1/health", "192.0.2.1:1234", http.StatusNoContent},
+		{"/api/v1/auth/login", "192.0.2.1:1234", http.StatusNoContent},
+		{"/api/v1/public/files/abc", "192.0.2.1:1234", http.StatusNoContent},
+		{"/api/v1/users", "10.1.2.3:1234", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rec := do(tt.path, tt.remoteAddr)
+		if rec.Code != tt.want {
+			t.Fatalf("%s from %s: status = %d, want %d", tt.path, tt.remoteAddr, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusServiceUnavailable {
+			if got := rec.Header().Get("Retry-After"); got != "120" {
+				t.Fatalf("Retry-After = %q, want 120", got)
+			}
+			if !strings.Contains(rec.Body.String(), "升级中") {
+				t.Fatalf("body %s does not contain the default message", rec.Body.String())
+			}
+		}
+	}
+
+	if _, err := maintenance.Set(context.Background(), false, "", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if rec := do("/api/v1/users", "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+		t.Fatalf("after disable: status = %d, want 204", rec.Code)
+	}
+}
+
+// 运行时设置写入 Redis，其他实例读取后生效
+func TestMaintenance_StateSharedAcrossInstances(t *testing.T) {
+	testutil.NewRedis(t)
+	ctx := context.Background()
+
+	a := NewMaintenance(MaintenanceConfig{Message: "default"})
+	b := NewMaintenance(MaintenanceConfig{Message: "default"})
+
+	if state := b.State(ctx); state.Enabled || state.Source != "config" {
+		t.Fatalf("initial state = %+v, want disabled from config", state)
+	}
+	if _, err := a.Set(ctx, true, "数据库迁移", 7); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	state := b.State(ctx)
+	if !state.Enabled || state.Message != "数据库迁移" || state.Source != "runtime" || state.UpdatedBy != 7 {
+		t.Fatalf("state = %+v, want runtime enabled by 7", state)
+	}
+}
+
+// 缓存过期后的并发读取共享一次 Redis 读取；Redis 不可用时沿用上一次的状态
+func TestMaintenance_StateConcurrentAndRedisDown(t *testing.T) {
+	mr := testutil.NewRedis(t)
+	ctx := context.Background()
+
+	a := NewMaintenance(MaintenanceConfig{Message: "default"})
+	b := NewMaintenance(MaintenanceConfig{Message: "default"})
+	if _, err := a.Set(ctx, true, "升级中", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 50)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if state := b.State(ctx); !state.Enabled || state.Message != "升级中" {
+				errs <- fmt.Sprintf("state = %+v, want enabled runtime state", state)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Fatal(msg)
+	}
+
+	mr.Close()
+	if state := b.State(ctx); !state.Enabled || state.Message != "升级中" {
+		t.Fatalf("state with redis down = %+v, want last known state", state)
+	}
+}
@@ -62,7 +62,7 @@ func Permission(config PermissionConfig) echo.MiddlewareFunc {
 
 			// 验证权限
 			userIDStr := strconv.FormatUint(uint64(userID), 10)
-			allowed, err := config.Enforcer.Enforce(userIDStr, domain, resource, action)
+			allowed, err := config.Enforcer.EnforceWithContext(c.Request().Context(), userIDStr, domain, resource, action)
 			if err != nil {
 				config.Logger.Error("permission check failed",
 					"user_id", userID,
@@ -125,7 +125,7 @@ func RequirePermission(config PermissionConfig, resource, action string) echo.Mi
 
 			// 验证权限
 			userIDStr := strconv.FormatUint(uint64(userID), 10)
-			allowed, err := config.Enforcer.Enforce(userIDStr, domain, resource, action)
+			allowed, err := config.Enforcer.EnforceWithContext(c.Request().Context(), userIDStr, domain, resource, action)
 			if err != nil {
 				config.Logger.Error("permission check failed",
 					"user_id", userID,
@@ -171,6 +171,9 @@ func CheckPermission(enforcer *casbin.Enforcer, logger *slog.Logger) func(c echo
 		}
 
 		domain := c.Get("domain")
+		if domain == nil {
+			domain = c.Get(userDefaultDomainKey)
+		}
 		if domain == nil {
 			domain = "default"
 		}
@@ -180,7 +183,7 @@ func CheckPermission(enforcer *casbin.Enforcer, logger *slog.Logger) func(c echo
 		}
 
 		userIDStr := strconv.FormatUint(uint64(userID), 10)
-		allowed, err := enforcer.Enforce(userIDStr, domainStr, resource, action)
+		allowed, err := enforcer.EnforceWithContext(c.Request().Context(), userIDStr, domainStr, resource, action)
 		if err != nil {
 			logger.Error("permission check failed",
 				"user_id", userID,
@@ -230,7 +233,7 @@ func RequireAnyPermission(config PermissionConfig, permissions [][2]string) echo
 			// 检查是否有任意一个权限
 			for _, perm := range permissions {
 				resource, action := perm[0], perm[1]
-				allowed, err := config.Enforcer.Enforce(userIDStr, domain, resource, action)
+				allowed, err := config.Enforcer.EnforceWithContext(c.Request().Context(), userIDStr, domain, resource, action)
 				if err != nil {
 					config.Logger.Error("permission check failed", "error", err)
 					continue
@@ -275,7 +278,7 @@ func RequireAllPermissions(config PermissionConfig, permissions [][2]string) ech
 			// 检查是否拥有所有权限
 			for _, perm := range permissions {
 				resource, action := perm[0], perm[1]
-				allowed, err := config.Enforcer.Enforce(userIDStr, domain, resource, action)
+				allowed, err := config.Enforcer.EnforceWithContext(c.Request().Context(), userIDStr, domain, resource, action)
 				if err != nil {
 					config.Logger.Error("permission check failed", "error", err)
 					return echo.NewHTTPError(http.StatusInternalServerError, "权限验证失败")
@@ -337,6 +340,11 @@ func getDomain(c echo.Context, defaultDomain string) string {
 		return domain
 	}
 
+	// 使用用户默认域（UserDefaultDomain 中间件加载）
+	if domain, ok := c.Get(userDefaultDomainKey).(string); ok && domain != "" {
+		return domain
+	}
+
 	// 使用默认域
 	if defaultDomain != "" {
 		return defaultDomain
@@ -3,9 +3,11 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
+	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/errors"
 	"github.com/cccvno1/nova/pkg/ratelimit"
 	"github.com/labstack/echo/v4"
@@ -17,7 +19,8 @@ type RateLimitConfig struct {
 	Algorithm string                    // 算法：token_bucket, sliding_window
 	Limit     int                       // 限制数量
 	Window    int                       // 时间窗口（秒）
-	Dimension string                    // 限流维度：ip, user, api
+	Dimension string                    // 限流维度：ip, user, api, user_api
+	KeyPrefix string                    // 限流键前缀，用于隔离不同规则的计数（可选）
 	Skipper   func(c echo.Context) bool // 跳过规则
 }
 
@@ -43,6 +46,7 @@ func RateLimit(config *RateLimitConfig) echo.MiddlewareFunc {
 	var limiter interface {
 		Allow(ctx context.Context, key string) (bool, int, error)
 		GetRemaining(ctx context.Context, key string) (int, error)
+		RetryAfter(ctx context.Context, key string) (time.Duration, error)
 	}
 
 	switch config.Algorithm {
@@ -77,6 +81,9 @@ func RateLimit(config *RateLimitConfig) echo.MiddlewareFunc {
 
 			// 构建限流键
 			key := buildRateLimitKey(c, config.Dimension)
+			if config.KeyPrefix != "" {
+				key = config.KeyPrefix + ":" + key
+			}
 
 			// 检查限流
 			allowed, current, err := limiter.Allow(c.Request().Context(), key)
@@ -91,7 +98,7 @@ func RateLimit(config *RateLimitConfig) echo.MiddlewareFunc {
 			c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Duration(config.Window)*time.Second).Unix()))
 
 			if !allowed {
-				return errors.New(errors.ErrTooManyRequests, "rate limit exceeded")
+				return rateLimitExceeded(c, limiter.RetryAfter, key, config.Window)
 			}
 
 			return next(c)
@@ -99,50 +106,57 @@ func RateLimit(config *RateLimitConfig) echo.MiddlewareFunc {
 	}
 }
 
+// rateLimitExceeded 设置 Retry-After 并返回带重置时间的限流错误
+// 重试时间由限流器根据窗口或令牌缺口计算，计算失败时退回整个窗口时长
+func rateLimitExceeded(c echo.Context, retryAfter func(context.Context, string) (time.Duration, error), key string, window int) error {
+	wait, err := retryAfter(c.Request().Context(), key)
+	if err != nil {
+		wait = time.Duration(window) * time.Second
+	}
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	resetAt := time.Now().Add(time.Duration(seconds) * time.Second)
+
+	c.Response().Header().Set("Retry-After", fmt.Sprint(seconds))
+	c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprint(resetAt.Unix()))
+
+	return errors.NewWithDetails(errors.ErrTooManyRequests, "rate limit exceeded", map[string]interface{}{
+		"retry_after": seconds,
+		"reset_at":    resetAt.Unix(),
+	})
+}
+
 // buildRateLimitKey 构建限流键
 func buildRateLimitKey(c echo.Context, dimension string) string {
 	switch dimension {
 	case "ip":
-		return getRealIP(c)
+		return clientIP(c)
 	case "user":
 		// 从上下文获取用户 ID
 		userID := GetUserID(c)
 		if userID == 0 {
-			return getRealIP(c) // 未登录用户使用 IP
+			return clientIP(c) // 未登录用户使用 IP
 		}
 		return fmt.Sprintf("user:%d", userID)
 	case "api":
 		// API 路径 + IP
-		return fmt.Sprintf("%s:%s", c.Request().URL.Path, getRealIP(c))
-	default:
-		return getRealIP(c)
-	}
-}
-
-// getRealIP 获取真实 IP
-func getRealIP(c echo.Context) string {
-	// 优先从 X-Forwarded-For 获取
-	xff := c.Request().Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+		return fmt.Sprintf("%s:%s", c.Request().URL.Path, clientIP(c))
+	case "user_api":
+		// 用户 + 路由模板（如 /api/v1/files/:id），同一接口不同资源ID共享计数
+		subject := fmt.Sprintf("user:%d", GetUserID(c))
+		if GetUserID(c) == 0 {
+			subject = clientIP(c) // 未登录用户使用 IP
 		}
+		route := c.Path()
+		if route == "" {
+			route = c.Request().URL.Path
+		}
+		return fmt.Sprintf("%s:%s %s", subject, c.Request().Method, route)
+	default:
+		return clientIP(c)
 	}
-
-	// 其次从 X-Real-IP 获取
-	xri := c.Request().Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// 最后使用 RemoteAddr
-	ip := c.Request().RemoteAddr
-	// 移除端口号
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	return ip
 }
 
 // RateLimitByIP IP 限流（快捷方式）
@@ -167,6 +181,51 @@ func RateLimitByUser(limit, window int) echo.MiddlewareFunc {
 	})
 }
 
+// RateLimitByUserAPI 用户 + 接口限流（快捷方式）
+func RateLimitByUserAPI(limit, window int) echo.MiddlewareFunc {
+	return RateLimit(&RateLimitConfig{
+		Enabled:   true,
+		Algorithm: "sliding_window",
+		Limit:     limit,
+		Window:    window,
+		Dimension: "user_api",
+	})
+}
+
+// RouteRateLimit 按路由规则限流
+// 每条规则匹配方法与路由模板（如 GET /api/v1/audit-logs/export），命中的请求按规则维度单独计数，
+// 未命中任何规则的请求直接放行；应放在 Auth 之后以便 user/user_api 维度获取用户ID
+func RouteRateLimit(enabled bool, algorithm string, rules []config.RateLimitRule) echo.MiddlewareFunc {
+	chain := make([]echo.MiddlewareFunc, 0, len(rules))
+	for _, rule := range rules {
+		dimension := rule.Dimension
+		if dimension == "" {
+			dimension = "user_api"
+		}
+		chain = append(chain, RateLimit(&RateLimitConfig{
+			Enabled:   enabled,
+			Algorithm: algorithm,
+			Limit:     rule.Limit,
+			Window:    rule.Window,
+			Dimension: dimension,
+			KeyPrefix: fmt.Sprintf("rule:%s %s", strings.ToUpper(rule.Method), rule.Path),
+			Skipper: func(c echo.Context) bool {
+				if rule.Method != "" && !strings.EqualFold(rule.Method, c.Request().Method) {
+					return true
+				}
+				return c.Path() != rule.Path
+			},
+		}))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		for i := len(chain) - 1; i >= 0; i-- {
+			next = chain[i](next)
+		}
+		return next
+	}
+}
+
 // RateLimitByAPI API 限流（快捷方式）
 func RateLimitByAPI(limit, window int) echo.MiddlewareFunc {
 	return RateLimit(&RateLimitConfig{
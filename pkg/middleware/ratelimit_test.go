@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/labstack/echo/v4"
+)
+
+// withUser 模拟认证中间件：从 X-Test-User 请求头读取用户ID
+func withUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if v := c.Request().Header.Get("X-Test-User"); v != "" {
+			id, _ := strconv.ParseUint(v, 10, 32)
+			c.Set(UserIDKey, uint(id))
+		}
+		return next(c)
+	}
+}
+
+// doRequest 以指定用户发送请求，返回状态码
+func doRequest(e *echo.Echo, method, path string, userID uint) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if userID != 0 {
+		req.Header.Set("X-Test-User", strconv.FormatUint(uint64(userID), 10))
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBuildRateLimitKey_UserAPI(t *testing.T) {
+	e := echo.New()
+	key := func(method, target string, userID uint) string {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = "192.0.2.10:1234"
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetPath("/api/v1/files/:id")
+		if userID != 0 {
+			c.Set(UserIDKey, userID)
+		}
+		return buildRateLimitKey(c, "user_api")
+	}
+
+	if got, want := key(http.MethodGet, "/api/v1/files/1", 7), "user:7:GET /api/v1/files/:id"; got != want {
+		t.Fatalf("key = %q, want %q", got, want)
+	}
+	// 同一用户访问同一路由模板的不同资源共享计数
+	if key(http.MethodGet, "/api/v1/files/1", 7) != key(http.MethodGet, "/api/v1/files/2", 7) {
+		t.Fatal("different resource IDs produced different keys")
+	}
+	// 不同用户、不同方法分别计数
+	if key(http.MethodGet, "/api/v1/files/1", 7) == key(http.MethodGet, "/api/v1/files/1", 8) {
+		t.Fatal("different users share a key")
+	}
+	if key(http.MethodGet, "/api/v1/files/1", 7) == key(http.MethodDelete, "/api/v1/files/1", 7) {
+		t.Fatal("different methods share a key")
+	}
+	// 未登录时以 IP 代替用户
+	if got, want := key(http.MethodGet, "/api/v1/files/1", 0), "192.0.2.10:GET /api/v1/files/:id"; got != want {
+		t.Fatalf("anonymous key = %q, want %q", got, want)
+	}
+}
+
+func TestRouteRateLimit_UserAPIRule(t *testing.T) {
+	testutil.NewRedis(t)
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	e.Use(withUser)
+	e.Use(RouteRateLimit(true, "sliding_window", []config.RateLimitRule{
+		{Method: http.MethodGet, Path: "/export/:kind", Limit: 2, Window: 3600},
+	}))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/export/:kind", ok)
+	e.GET("/other", ok)
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest(e, http.MethodGet, "/export/users", 1); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	// 同一用户在同一路由模板下超出限额，不论路径参数
+	if rec := doRequest(e, http.MethodGet, "/export/files", 1); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request status = %d, want 429", rec.Code)
+	}
+	// 其他用户不受影响
+	if rec := doRequest(e, http.MethodGet, "/export/users", 2); rec.Code != http.StatusOK {
+		t.Fatalf("other user status = %d, want 200", rec.Code)
+	}
+	// 未命中规则的路由不限流
+	for i := 0; i < 5; i++ {
+		if rec := doRequest(e, http.MethodGet, "/other", 1); rec.Code != http.StatusOK {
+			t.Fatalf("unmatched route status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+// limitedEcho 创建只有一个受限路由的 Echo 实例
+func limitedEcho(cfg *RateLimitConfig) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	e.GET("/limited", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, RateLimit(cfg))
+	return e
+}
+
+// assertRetryAfter 校验 429 响应的 Retry-After 与响应体中的重试信息
+func assertRetryAfter(t *testing.T, rec *httptest.ResponseRecorder, minSeconds, maxSeconds int64) {
+	t.Helper()
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	retryAfter, err := strconv.ParseInt(rec.Header().Get("Retry-After"), 10, 64)
+	if err != nil {
+		t.Fatalf("Retry-After = %q: %v", rec.Header().Get("Retry-After"), err)
+	}
+	if retryAfter < minSeconds || retryAfter > maxSeconds {
+		t.Fatalf("Retry-After = %d, want between %d and %d", retryAfter, minSeconds, maxSeconds)
+	}
+
+	var body struct {
+		Data struct {
+			RetryAfter int64 `json:"retry_after"`
+			ResetAt    int64 `json:"reset_at"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Data.RetryAfter != retryAfter {
+		t.Fatalf("body retry_after = %d, header = %d", body.Data.RetryAfter, retryAfter)
+	}
+	if delta := body.Data.ResetAt - time.Now().Unix() - retryAfter; delta < -1 || delta > 1 {
+		t.Fatalf("reset_at = %d is not about now + %ds", body.Data.ResetAt, retryAfter)
+	}
+	if rec.Header().Get("X-RateLimit-Reset") != strconv.FormatInt(body.Data.ResetAt, 10) {
+		t.Fatalf("X-RateLimit-Reset = %q, want %d", rec.Header().Get("X-RateLimit-Reset"), body.Data.ResetAt)
+	}
+}
+
+func TestRateLimit_SlidingWindowRetryAfter(t *testing.T) {
+	testutil.NewRedis(t)
+	e := limitedEcho(&RateLimitConfig{Enabled: true, Algorithm: "sliding_window", Limit: 2, Window: 60, Dimension: "ip"})
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest(e, http.MethodGet, "/limited", 0); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	// 窗口内最早的请求约 60 秒后过期
+	assertRetryAfter(t, doRequest(e, http.MethodGet, "/limited", 0), 59, 60)
+}
+
+func TestRateLimit_TokenBucketRetryAfter(t *testing.T) {
+	testutil.NewRedis(t)
+	// 容量 10，每秒生成 1 个令牌
+	e := limitedEcho(&RateLimitConfig{Enabled: true, Algorithm: "token_bucket", Limit: 10, Window: 10, Dimension: "ip"})
+
+	for i := 0; i < 10; i++ {
+		if rec := doRequest(e, http.MethodGet, "/limited", 0); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	// 缺 1 个令牌，按速率约 1 秒后可用
+	assertRetryAfter(t, doRequest(e, http.MethodGet, "/limited", 0), 1, 1)
+}
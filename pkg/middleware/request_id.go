@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RequestID 请求ID中间件
+// 沿用客户端传入的 X-Request-ID，未传入时生成新ID，并写入响应头
+func RequestID() echo.MiddlewareFunc {
+	return middleware.RequestID()
+}
+
+// GetRequestID 获取当前请求ID
+func GetRequestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/cccvno1/nova/pkg/config"
+	"github.com/labstack/echo/v4"
+)
+
+// SecureHeaders 安全响应头中间件
+// 各响应头取值为空时不设置；HSTS 仅在 HTTPS 请求（直连 TLS 或反向代理 X-Forwarded-Proto=https）时下发
+func SecureHeaders(cfg *config.SecurityHeadersConfig) echo.MiddlewareFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !cfg.Enabled {
+			return next
+		}
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			if cfg.ContentTypeOptions != "" {
+				h.Set(echo.HeaderXContentTypeOptions, cfg.ContentTypeOptions)
+			}
+			if cfg.FrameOptions != "" {
+				h.Set(echo.HeaderXFrameOptions, cfg.FrameOptions)
+			}
+			if cfg.ReferrerPolicy != "" {
+				h.Set(echo.HeaderReferrerPolicy, cfg.ReferrerPolicy)
+			}
+			if cfg.ContentSecurityPolicy != "" {
+				h.Set(echo.HeaderContentSecurityPolicy, cfg.ContentSecurityPolicy)
+			}
+			if hsts != "" && c.Scheme() == "https" {
+				h.Set(echo.HeaderStrictTransportSecurity, hsts)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/timing"
+	"github.com/labstack/echo/v4"
+)
+
+// ServerTiming 按依赖汇总请求耗时的中间件
+// 在请求上下文中挂载 timing.Recorder，数据库（GORM 回调）、Redis（客户端 hook）与 Casbin 鉴权
+// 的调用耗时累加到对应指标，响应写出前以 Server-Timing 头返回；logSpans 为 true 时同时以 debug 级别记录。
+// enabled 为 false 时直接返回 next，各调用点只多一次 context 查找
+func ServerTiming(enabled, logSpans bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !enabled {
+			return next
+		}
+		return func(c echo.Context) error {
+			start := time.Now()
+			ctx, recorder := timing.WithRecorder(c.Request().Context())
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			c.Response().Before(func() {
+				if header := recorder.Header(time.Since(start)); header != "" {
+					c.Response().Header().Set("Server-Timing", header)
+				}
+			})
+
+			err := next(c)
+
+			if logSpans {
+				attrs := []any{
+					slog.String("request_id", GetRequestID(c)),
+					slog.String("method", c.Request().Method),
+					slog.String("route", c.Path()),
+					slog.Duration("total", time.Since(start)),
+				}
+				for _, span := range recorder.Spans() {
+					attrs = append(attrs, slog.Group(span.Name,
+						slog.Int("count", span.Count),
+						slog.Duration("duration", span.Duration),
+					))
+				}
+				logger.Debug("request timing", attrs...)
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"strings"
+
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// ServiceTokenHeader 服务间调用凭证请求头
+const ServiceTokenHeader = "X-Service-Token"
+
+// ServiceAuth 服务间调用认证中间件
+// 请求携带匹配的 X-Service-Token，或来源 IP 命中白名单（支持 CIDR）时放行；
+// 两者都未配置时拒绝所有请求，避免内部接口意外暴露；
+// 来源 IP 只采信可信代理转发的 X-Forwarded-For（见 IPExtractor），直连请求使用 TCP 对端地址
+func ServiceAuth(token string, allowedIPs []string) echo.MiddlewareFunc {
+	nets := parseAllowedIPs(allowedIPs)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token != "" {
+				provided := c.Request().Header.Get(ServiceTokenHeader)
+				if provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+					return next(c)
+				}
+			}
+
+			if ip := net.ParseIP(clientIP(c)); ip != nil {
+				for _, n := range nets {
+					if n.Contains(ip) {
+						return next(c)
+					}
+				}
+			}
+
+			return errors.New(errors.ErrForbidden, "service access denied")
+		}
+	}
+}
+
+// parseAllowedIPs 解析 IP 白名单，单个 IP 视为 /32（IPv6 为 /128）
+func parseAllowedIPs(entries []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					bits = 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func serveIntrospect(e *echo.Echo, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodPost, "/auth/introspect", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func newIntrospectServer(trustedProxies []string) *echo.Echo {
+	e := echo.New()
+	e.IPExtractor = IPExtractor(trustedProxies)
+	e.HTTPErrorHandler = ErrorHandler()
+	e.POST("/auth/introspect", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, ServiceAuth("", []string{"10.0.0.0/8"}))
+	return e
+}
+
+func TestServiceAuth_IgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	e := newIntrospectServer(nil)
+
+	if code := serveIntrospect(e, "203.0.113.7:4000", "10.0.0.1"); code != http.StatusForbidden {
+		t.Fatalf("spoofed X-Forwarded-For: got %d, want %d", code, http.StatusForbidden)
+	}
+	if code := serveIntrospect(e, "10.1.2.3:4000", ""); code != http.StatusOK {
+		t.Fatalf("allowlisted peer: got %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestServiceAuth_TrustsForwardedForFromTrustedProxy(t *testing.T) {
+	e := newIntrospectServer([]string{"192.0.2.1"})
+
+	if code := serveIntrospect(e, "192.0.2.1:4000", "10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("forwarded by trusted proxy: got %d, want %d", code, http.StatusOK)
+	}
+	if code := serveIntrospect(e, "192.0.2.1:4000", "203.0.113.7"); code != http.StatusForbidden {
+		t.Fatalf("non-allowlisted client behind proxy: got %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestServiceAuth_DefaultsToPeerAddressWithoutExtractor(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	e.POST("/auth/introspect", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, ServiceAuth("", []string{"10.0.0.0/8"}))
+
+	if code := serveIntrospect(e, "203.0.113.7:4000", "10.0.0.1"); code != http.StatusForbidden {
+		t.Fatalf("spoofed X-Forwarded-For without extractor: got %d, want %d", code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/labstack/echo/v4"
+)
+
+// SlowRequest 慢请求日志中间件
+// 请求耗时超过 threshold 时以 warn 级别记录方法、路径、用户、状态码、耗时和请求ID；
+// 与审计日志相互独立，开销很小，threshold <= 0 时不启用
+func SlowRequest(threshold time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if threshold <= 0 {
+			return next
+		}
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			duration := time.Since(start)
+			if duration > threshold {
+				logger.Warn("slow request",
+					slog.String("request_id", GetRequestID(c)),
+					slog.String("method", c.Request().Method),
+					slog.String("path", c.Request().URL.Path),
+					slog.String("route", c.Path()),
+					slog.Uint64("user_id", uint64(GetUserID(c))),
+					slog.Int("status", c.Response().Status),
+					slog.Duration("duration", duration),
+					slog.Duration("threshold", threshold),
+				)
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/cccvno1/nova/pkg/errors"
+	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/labstack/echo/v4"
+)
+
+// Transactional 请求级事务中间件
+// 为请求开启数据库事务并通过 database.WithTx 绑定到请求 context，仓储通过 Conn / database.FromContext 自动使用该事务；
+// 处理链返回错误、响应状态码 >= 400 或发生 panic 时回滚，否则提交。
+//
+// routes 为 "METHOD 路由模板" 形式（如 "POST /api/v1/user-roles"，METHOD 为 * 表示任意方法），
+// 为空时对所有请求生效。需要让审计日志写入同一事务时，将本中间件放在审计中间件之前并通过 routes 选择路由；
+// 直接挂在单个路由上时审计中间件在事务提交后才执行，不参与事务。
+//
+// 事务内的响应先写入缓冲区，提交成功后才发送给客户端；提交失败时丢弃缓冲的响应并返回数据库错误，
+// 客户端不会在数据已回滚的情况下收到成功响应。因此事务路由不支持流式输出。
+func Transactional(db *database.Database, routes ...string) echo.MiddlewareFunc {
+	selected := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		method, path, found := strings.Cut(strings.TrimSpace(route), " ")
+		if !found {
+			method, path = "*", method
+		}
+		selected[overrideKey(method, strings.TrimSpace(path))] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			if len(selected) > 0 &&
+				!selected[c.Request().Method+" "+c.Path()] && !selected["* "+c.Path()] {
+				return next(c)
+			}
+
+			// 已处于事务中（如中间件重复挂载）时直接复用
+			ctx := c.Request().Context()
+			if _, ok := database.TxFromContext(ctx); ok {
+				return next(c)
+			}
+
+			tx := db.DB.WithContext(ctx).Begin()
+			if tx.Error != nil {
+				return errors.Wrap(errors.ErrDatabase, fmt.Errorf("failed to begin transaction: %w", tx.Error))
+			}
+			c.SetRequest(c.Request().WithContext(database.WithTx(ctx, tx)))
+
+			res := c.Response()
+			original := res.Writer
+			buffered := newBufferedResponseWriter(original)
+			res.Writer = buffered
+
+			defer func() {
+				if r := recover(); r != nil {
+					tx.Rollback()
+					resetResponse(res, original)
+					panic(r)
+				}
+			}()
+
+			err = next(c)
+			if err != nil || res.Status >= http.StatusBadRequest {
+				if rbErr := tx.Rollback().Error; rbErr != nil {
+					logger.Error("failed to rollback request transaction",
+						slog.Any("error", rbErr),
+						slog.String("path", c.Request().URL.Path))
+				}
+				if err != nil {
+					// 错误由全局错误处理器写出，缓冲中可能的部分输出一并丢弃
+					resetResponse(res, original)
+					return err
+				}
+				res.Writer = original
+				return buffered.flush()
+			}
+
+			if commitErr := tx.Commit().Error; commitErr != nil {
+				logger.Error("failed to commit request transaction",
+					slog.Any("error", commitErr),
+					slog.String("method", c.Request().Method),
+					slog.String("path", c.Request().URL.Path))
+				resetResponse(res, original)
+				return errors.Wrap(errors.ErrDatabase, fmt.Errorf("failed to commit transaction: %w", commitErr))
+			}
+
+			// 缓存失效等提交后回调在响应发出前执行，客户端随后的读取不会命中旧缓存
+			database.RunAfterCommit(c.Request().Context())
+
+			res.Writer = original
+			return buffered.flush()
+		}
+	}
+}
+
+// bufferedResponseWriter 缓冲响应头、状态码和响应体，直到 flush 时才写入底层 ResponseWriter
+type bufferedResponseWriter struct {
+	target http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter(target http.ResponseWriter) *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		target: target,
+		header: target.Header().Clone(),
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Flush 缓冲期间忽略刷新请求，响应在事务结束后一次性写出
+func (w *bufferedResponseWriter) Flush() {}
+
+// flush 将缓冲的响应写入底层 ResponseWriter
+func (w *bufferedResponseWriter) flush() error {
+	if w.status == 0 {
+		return nil
+	}
+	header := w.target.Header()
+	for key := range header {
+		if _, ok := w.header[key]; !ok {
+			header.Del(key)
+		}
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+	w.target.WriteHeader(w.status)
+	_, err := w.target.Write(w.body.Bytes())
+	return err
+}
+
+// resetResponse 丢弃缓冲的响应，恢复底层 ResponseWriter，使错误处理器可以重新写出响应
+func resetResponse(res *echo.Response, original http.ResponseWriter) {
+	res.Writer = original
+	res.Status = http.StatusOK
+	res.Size = 0
+	res.Committed = false
+}
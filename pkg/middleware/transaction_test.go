@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/cccvno1/nova/pkg/database"
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTxTestDatabase 创建带延迟外键约束的 SQLite 数据库：违反约束的写入在提交时才失败
+func newTxTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "tx.db") + "?_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents(id) DEFERRABLE INITIALLY DEFERRED)",
+		"INSERT INTO parents (id) VALUES (1)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("prepare schema: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return &database.Database{DB: db}
+}
+
+func newTxTestServer(db *database.Database, handler echo.HandlerFunc) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	e.POST("/children", handler, Transactional(db))
+	return e
+}
+
+func countChildren(t *testing.T, db *database.Database) int64 {
+	t.Helper()
+	var n int64
+	if err := db.DB.Table("children").Count(&n).Error; err != nil {
+		t.Fatalf("count children: %v", err)
+	}
+	return n
+}
+
+func TestTransactional_CommitFailureReplacesSuccessResponse(t *testing.T) {
+	db := newTxTestDatabase(t)
+	hookRan := false
+	e := newTxTestServer(db, func(c echo.Context) error {
+		ctx := c.Request().Context()
+		// parent 2 不存在，延迟外键在提交时才报错
+		if err := database.FromContext(ctx).Exec("INSERT INTO children (parent_id) VALUES (2)").Error; err != nil {
+			return err
+		}
+		database.AfterCommit(ctx, func() { hookRan = true })
+		c.Response().Header().Set("X-Created", "1")
+		return c.JSON(http.StatusCreated, map[string]string{"status": "created"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/children", nil))
+
+	if rec.Code < http.StatusInternalServerError {
+		t.Fatalf("status = %d, want a 5xx after failed commit; body=%s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Created") != "" {
+		t.Fatal("handler header leaked into the error response")
+	}
+	if hookRan {
+		t.Fatal("after-commit hook ran although the commit failed")
+	}
+}
+
+func TestTransactional_CommitsBeforeResponding(t *testing.T) {
+	db := newTxTestDatabase(t)
+	hookRan := false
+	e := newTxTestServer(db, func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if err := database.FromContext(ctx).Exec("INSERT INTO children (parent_id) VALUES (1)").Error; err != nil {
+			return err
+		}
+		database.AfterCommit(ctx, func() { hookRan = true })
+		c.Response().Header().Set("X-Created", "1")
+		return c.JSON(http.StatusCreated, map[string]string{"status": "created"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/children", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body=%s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	if rec.Header().Get("X-Created") != "1" || rec.Body.Len() == 0 {
+		t.Fatalf("buffered response not flushed: headers=%v body=%q", rec.Header(), rec.Body.String())
+	}
+	if !hookRan {
+		t.Fatal("after-commit hook did not run")
+	}
+	if n := countChildren(t, db); n != 1 {
+		t.Fatalf("children = %d, want 1", n)
+	}
+}
+
+func TestTransactional_RollsBackClientErrors(t *testing.T) {
+	db := newTxTestDatabase(t)
+	e := newTxTestServer(db, func(c echo.Context) error {
+		if err := database.FromContext(c.Request().Context()).Exec("INSERT INTO children (parent_id) VALUES (1)").Error; err != nil {
+			return err
+		}
+		return c.JSON(http.StatusConflict, map[string]string{"status": "conflict"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/children", nil))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if n := countChildren(t, db); n != 0 {
+		t.Fatalf("children = %d, want 0", n)
+	}
+}
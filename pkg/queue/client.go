@@ -13,20 +13,22 @@ import (
 
 // Client 队列客户端
 type Client struct {
-	queueKey string
-	delayKey string
+	queueKey   string
+	delayKey   string
+	metricsKey string
 }
 
 // NewClient 创建队列客户端
 func NewClient(prefix string) *Client {
 	return &Client{
-		queueKey: prefix + ":tasks",
-		delayKey: prefix + ":delayed_tasks",
+		queueKey:   prefix + ":tasks",
+		delayKey:   prefix + ":delayed_tasks",
+		metricsKey: prefix + ":metrics",
 	}
 }
 
 // Submit 提交任务到队列
-func (c *Client) Submit(ctx context.Context, name string, payload map[string]interface{}, maxRetry int) (string, error) {
+func (c *Client) Submit(ctx context.Context, name string, payload map[string]interface{}, maxRetry int, opts ...SubmitOption) (string, error) {
 	// 生成任务 ID
 	taskID := uuid.New().String()
 
@@ -40,6 +42,9 @@ func (c *Client) Submit(ctx context.Context, name string, payload map[string]int
 		CreatedAt:  time.Now(),
 		ExecuteAt:  time.Now(),
 	}
+	for _, opt := range opts {
+		opt(task)
+	}
 
 	// 序列化任务
 	data, err := task.Marshal()
@@ -55,8 +60,24 @@ func (c *Client) Submit(ctx context.Context, name string, payload map[string]int
 	return taskID, nil
 }
 
+// Enqueue 将已有任务重新推入主队列，保留任务 ID、名称和载荷
+// 用于将数据库中记录的任务重新排队（如管理员批量重置失败任务），重试计数从 0 开始
+func (c *Client) Enqueue(ctx context.Context, task *Task) error {
+	task.RetryCount = 0
+	task.ExecuteAt = time.Now()
+
+	data, err := task.Marshal()
+	if err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	if err := cache.LPush(ctx, c.queueKey, data); err != nil {
+		return errors.Wrap(errors.ErrInternalServer, err)
+	}
+	return nil
+}
+
 // SubmitIn 延迟提交任务（在指定时间后执行）
-func (c *Client) SubmitIn(ctx context.Context, name string, payload map[string]interface{}, maxRetry int, delay time.Duration) (string, error) {
+func (c *Client) SubmitIn(ctx context.Context, name string, payload map[string]interface{}, maxRetry int, delay time.Duration, opts ...SubmitOption) (string, error) {
 	// 生成任务 ID
 	taskID := uuid.New().String()
 
@@ -71,24 +92,36 @@ func (c *Client) SubmitIn(ctx context.Context, name string, payload map[string]i
 		CreatedAt:  time.Now(),
 		ExecuteAt:  executeAt,
 	}
+	for _, opt := range opts {
+		opt(task)
+	}
+
+	if err := c.scheduleAt(ctx, task, executeAt); err != nil {
+		return "", err
+	}
+
+	return taskID, nil
+}
+
+// scheduleAt 将任务写入延迟队列，到期后由 Worker 迁移至主队列
+func (c *Client) scheduleAt(ctx context.Context, task *Task, executeAt time.Time) error {
+	task.ExecuteAt = executeAt
 
 	// 序列化任务
 	data, err := task.Marshal()
 	if err != nil {
-		return "", errors.Wrap(errors.ErrInternalServer, err)
+		return errors.Wrap(errors.ErrInternalServer, err)
 	}
 
 	// 使用 ZSet 存储延迟任务（以执行时间为分数）
-	score := float64(executeAt.Unix())
 	z := redis.Z{
-		Score:  score,
+		Score:  float64(executeAt.Unix()),
 		Member: string(data),
 	}
 	if err := cache.ZAdd(ctx, c.delayKey, z); err != nil {
-		return "", errors.Wrap(errors.ErrInternalServer, err)
+		return errors.Wrap(errors.ErrInternalServer, err)
 	}
-
-	return taskID, nil
+	return nil
 }
 
 // GetQueueLength 获取队列长度
@@ -110,6 +143,11 @@ func (c *Client) GetDelayKey() string {
 	return c.delayKey
 }
 
+// GetMetricsKey 获取任务指标键前缀
+func (c *Client) GetMetricsKey() string {
+	return c.metricsKey
+}
+
 // ClearQueue 清空队列（用于测试）
 func (c *Client) ClearQueue(ctx context.Context) error {
 	if err := cache.Del(ctx, c.queueKey); err != nil {
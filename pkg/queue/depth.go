@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DepthStatusOK       = "ok"       // 队列长度正常
+	DepthStatusDegraded = "degraded" // 队列长度持续超过告警阈值
+)
+
+// DepthStatus 队列积压状态
+type DepthStatus struct {
+	Status               string     `json:"status"`                         // ok / degraded
+	Length               int64      `json:"length"`                         // 当前待处理任务数
+	Threshold            int64      `json:"threshold"`                      // 告警阈值（0 表示不告警）
+	HighWater            int64      `json:"high_water"`                     // 观测到的最大队列长度
+	HighWaterAt          *time.Time `json:"high_water_at,omitempty"`        // 最大队列长度出现的时间
+	OverThresholdSince   *time.Time `json:"over_threshold_since,omitempty"` // 本次持续超过阈值的起始时间
+	OverThresholdSeconds int64      `json:"over_threshold_seconds"`         // 已持续超过阈值的秒数
+}
+
+// depthObserveScript 读取队列长度并更新最高水位与超阈值起始时间
+// 返回 {长度, 最高水位, 最高水位时间, 超阈值起始时间}，不存在的字段返回空字符串
+var depthObserveScript = redis.NewScript(`
+local length = redis.call("LLEN", KEYS[1])
+local high = tonumber(redis.call("HGET", KEYS[2], "high_water") or "0")
+if length > high then
+	redis.call("HSET", KEYS[2], "high_water", length, "high_water_at", ARGV[1])
+end
+local threshold = tonumber(ARGV[2])
+if threshold > 0 and length > threshold then
+	redis.call("HSETNX", KEYS[2], "over_since", ARGV[1])
+else
+	redis.call("HDEL", KEYS[2], "over_since")
+end
+local state = redis.call("HMGET", KEYS[2], "high_water", "high_water_at", "over_since")
+return {tostring(length), state[1] or "", state[2] or "", state[3] or ""}
+`)
+
+// DepthMonitor 队列积压监控
+// 每次 Observe 读取队列长度并记录最高水位；长度超过阈值的持续时间达到 duration 后报告 degraded。
+// 状态保存在 Redis 中，多实例共享同一份观测结果
+type DepthMonitor struct {
+	queueKey  string
+	stateKey  string
+	threshold int64
+	duration  time.Duration
+}
+
+// NewDepthMonitor 创建队列积压监控
+// threshold 为告警阈值（<= 0 时只记录最高水位，不会报告 degraded），duration 为超过阈值的容忍时长
+func NewDepthMonitor(client *Client, threshold int, duration time.Duration) *DepthMonitor {
+	return &DepthMonitor{
+		queueKey:  client.GetQueueKey(),
+		stateKey:  client.GetQueueKey() + ":depth",
+		threshold: int64(threshold),
+		duration:  duration,
+	}
+}
+
+// Observe 采样当前队列长度并返回积压状态
+func (m *DepthMonitor) Observe(ctx context.Context) (*DepthStatus, error) {
+	now := time.Now()
+	values, err := depthObserveScript.Run(ctx, cache.GetClient(),
+		[]string{cache.BuildKey(m.queueKey), cache.BuildKey(m.stateKey)},
+		now.Unix(), m.threshold).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &DepthStatus{
+		Status:    DepthStatusOK,
+		Length:    parseInt(values[0]),
+		Threshold: m.threshold,
+		HighWater: parseInt(values[1]),
+	}
+	if at, ok := parseUnix(values[2]); ok {
+		status.HighWaterAt = &at
+	}
+	if since, ok := parseUnix(values[3]); ok {
+		status.OverThresholdSince = &since
+		over := now.Sub(since)
+		status.OverThresholdSeconds = int64(over.Seconds())
+		if over >= m.duration {
+			status.Status = DepthStatusDegraded
+		}
+	}
+
+	return status, nil
+}
+
+// parseUnix 解析 Unix 秒级时间戳，空字符串返回 false
+func parseUnix(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
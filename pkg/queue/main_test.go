@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/cccvno1/nova/internal/testutil"
+	"github.com/cccvno1/nova/pkg/config"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// newTestWorker 创建指向测试 Redis 的 Worker
+func newTestWorker(t *testing.T, workers int) *Worker {
+	t.Helper()
+
+	testutil.NewRedis(t)
+	return NewWorker(&config.QueueConfig{
+		Workers:           workers,
+		MaxRetry:          3,
+		RetryDelay:        1,
+		RedisPrefix:       "test_queue",
+		BackoffStrategy:   "fixed",
+		RetryMaxDelay:     60,
+		DelayedBatchSize:  100,
+		DelayedMaxPerTick: 1000,
+	})
+}
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+)
+
+const (
+	// metricsDurationWindow 计算平均耗时的滚动窗口大小（最近 N 次执行）
+	metricsDurationWindow = 100
+
+	metricProcessed    = "processed"     // 执行成功
+	metricFailed       = "failed"        // 执行失败（每次失败都计数）
+	metricRetried      = "retried"       // 重新入队重试
+	metricDeadLettered = "dead_lettered" // 超过最大重试次数后放弃
+)
+
+// TaskMetrics 单个任务类型的执行指标
+type TaskMetrics struct {
+	Processed     int64   `json:"processed"`
+	Failed        int64   `json:"failed"`
+	Retried       int64   `json:"retried"`
+	DeadLettered  int64   `json:"dead_lettered"`
+	AvgDurationMs float64 `json:"avg_duration_ms"` // 最近 metricsDurationWindow 次执行的平均耗时
+}
+
+// Metrics 按任务名统计的队列指标（存储在 Redis 中，多实例共享）
+type Metrics struct {
+	prefix string
+}
+
+// NewMetrics 创建任务指标统计
+func NewMetrics(prefix string) *Metrics {
+	return &Metrics{prefix: prefix}
+}
+
+// Incr 增加任务计数
+func (m *Metrics) Incr(ctx context.Context, taskName, metric string) {
+	_ = cache.SAdd(ctx, m.namesKey(), taskName)
+	_, _ = cache.HIncrBy(ctx, m.countersKey(taskName), metric, 1)
+}
+
+// ObserveDuration 记录任务执行耗时（只保留最近 metricsDurationWindow 条）
+func (m *Metrics) ObserveDuration(ctx context.Context, taskName string, duration time.Duration) {
+	key := m.durationsKey(taskName)
+	if err := cache.LPush(ctx, key, duration.Milliseconds()); err != nil {
+		return
+	}
+	_ = cache.LTrim(ctx, key, 0, metricsDurationWindow-1)
+}
+
+// Get 获取单个任务类型的指标
+func (m *Metrics) Get(ctx context.Context, taskName string) (*TaskMetrics, error) {
+	counters, err := cache.HGetAll(ctx, m.countersKey(taskName))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &TaskMetrics{
+		Processed:    parseInt(counters[metricProcessed]),
+		Failed:       parseInt(counters[metricFailed]),
+		Retried:      parseInt(counters[metricRetried]),
+		DeadLettered: parseInt(counters[metricDeadLettered]),
+	}
+
+	durations, err := cache.LRange(ctx, m.durationsKey(taskName), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(durations) > 0 {
+		var total int64
+		for _, d := range durations {
+			total += parseInt(d)
+		}
+		metrics.AvgDurationMs = float64(total) / float64(len(durations))
+	}
+
+	return metrics, nil
+}
+
+// All 获取所有任务类型的指标
+func (m *Metrics) All(ctx context.Context) (map[string]*TaskMetrics, error) {
+	names, err := cache.SMembers(ctx, m.namesKey())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*TaskMetrics, len(names))
+	for _, name := range names {
+		metrics, err := m.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = metrics
+	}
+	return result, nil
+}
+
+func (m *Metrics) namesKey() string {
+	return m.prefix + ":names"
+}
+
+func (m *Metrics) countersKey(taskName string) string {
+	return m.prefix + ":" + taskName + ":counters"
+}
+
+func (m *Metrics) durationsKey(taskName string) string {
+	return m.prefix + ":" + taskName + ":durations"
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
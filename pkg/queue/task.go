@@ -2,7 +2,10 @@ package queue
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/cccvno1/nova/pkg/retry"
 )
 
 // TaskStatus 任务状态
@@ -24,11 +27,37 @@ type Task struct {
 	MaxRetry   int                    `json:"max_retry"`   // 最大重试次数
 	CreatedAt  time.Time              `json:"created_at"`  // 创建时间
 	ExecuteAt  time.Time              `json:"execute_at"`  // 执行时间
+
+	// 重试退避策略（fixed/exponential），为空时使用 queue.backoff_strategy
+	BackoffStrategy retry.Strategy `json:"backoff_strategy,omitempty"`
+}
+
+// SubmitOption 投递任务的可选参数
+type SubmitOption func(*Task)
+
+// WithBackoff 指定任务失败重试时的退避策略
+func WithBackoff(strategy retry.Strategy) SubmitOption {
+	return func(t *Task) {
+		t.BackoffStrategy = strategy
+	}
 }
 
 // HandlerFunc 任务处理函数
 type HandlerFunc func(task *Task) error
 
+// DecodePayload 将任务载荷解码到类型 T（通过 JSON 中转，字段按 json 标签匹配）
+func DecodePayload[T any](task *Task) (T, error) {
+	var payload T
+	data, err := json.Marshal(task.Payload)
+	if err != nil {
+		return payload, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("failed to decode payload for task %s: %w", task.Name, err)
+	}
+	return payload, nil
+}
+
 // Marshal 序列化任务
 func (t *Task) Marshal() ([]byte, error) {
 	return json.Marshal(t)
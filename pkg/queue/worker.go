@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -10,34 +11,54 @@ import (
 	"github.com/cccvno1/nova/pkg/cache"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/logger"
+	"github.com/cccvno1/nova/pkg/retry"
+	"github.com/redis/go-redis/v9"
 )
 
+// retryJitter 重试等待时间的随机抖动比例，避免同时失败的任务集中重试
+const retryJitter = 0.1
+
 // Worker 队列 Worker
 type Worker struct {
 	client     *Client
+	metrics    *Metrics
 	handlers   map[string]HandlerFunc
 	workerNum  int
 	maxRetry   int
 	retryDelay time.Duration
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
-	mu         sync.RWMutex
+	backoff    retry.Policy // 失败重试的退避策略，任务可通过 BackoffStrategy 覆盖策略类型
+	// 延迟任务扫描的批大小与单次上限，避免到期任务堆积时单次扫描阻塞过久
+	delayedBatchSize  int64
+	delayedMaxPerTick int64
+	ctx               context.Context
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+	mu                sync.RWMutex
 }
 
 // NewWorker 创建 Worker
 func NewWorker(cfg *config.QueueConfig) *Worker {
 	client := NewClient(cfg.RedisPrefix)
 	ctx, cancel := context.WithCancel(context.Background())
+	backoff := retry.Policy{
+		Strategy:     retry.Strategy(cfg.BackoffStrategy),
+		InitialDelay: time.Duration(cfg.RetryDelay) * time.Second,
+		MaxDelay:     time.Duration(cfg.RetryMaxDelay) * time.Second,
+		Jitter:       retryJitter,
+	}
 
 	return &Worker{
-		client:     client,
-		handlers:   make(map[string]HandlerFunc),
-		workerNum:  cfg.Workers,
-		maxRetry:   cfg.MaxRetry,
-		retryDelay: time.Duration(cfg.RetryDelay) * time.Second,
-		ctx:        ctx,
-		cancel:     cancel,
+		client:            client,
+		metrics:           NewMetrics(client.GetMetricsKey()),
+		handlers:          make(map[string]HandlerFunc),
+		workerNum:         cfg.Workers,
+		maxRetry:          cfg.MaxRetry,
+		retryDelay:        time.Duration(cfg.RetryDelay) * time.Second,
+		backoff:           backoff,
+		delayedBatchSize:  int64(cfg.DelayedBatchSize),
+		delayedMaxPerTick: int64(cfg.DelayedMaxPerTick),
+		ctx:               ctx,
+		cancel:            cancel,
 	}
 }
 
@@ -49,6 +70,19 @@ func (w *Worker) Register(name string, handler HandlerFunc) {
 	logger.Info("registered task handler", slog.String("task", name))
 }
 
+// RegisterTyped 注册类型化的任务处理器
+// 自动将任务载荷解码为 T 并传入 Worker 的上下文（Stop 时取消），省去处理器中手动解析 map 的样板代码。
+// 需要访问任务元信息（ID、重试次数等）时仍使用 Register。
+func RegisterTyped[T any](w *Worker, name string, fn func(ctx context.Context, payload T) error) {
+	w.Register(name, func(task *Task) error {
+		payload, err := DecodePayload[T](task)
+		if err != nil {
+			return err
+		}
+		return fn(w.ctx, payload)
+	})
+}
+
 // Start 启动 Worker
 func (w *Worker) Start() error {
 	logger.Info("starting queue workers", slog.Int("workers", w.workerNum))
@@ -87,20 +121,13 @@ func (w *Worker) work(id int) {
 			logger.Info("worker stopped", slog.Int("worker_id", id))
 			return
 		default:
-			// 从队列中弹出任务（阻塞 5 秒）
-			result, err := cache.BRPop(w.ctx, 5*time.Second, w.client.GetQueueKey())
-			if err != nil {
-				// 超时或其他错误，继续循环
+			// 从队列中弹出任务（阻塞等待，Stop 时立即返回）
+			taskData, ok := w.popTask()
+			if !ok {
+				// 超时、出错或已停止，继续循环（停止时由上方 ctx.Done 分支退出）
 				continue
 			}
 
-			if len(result) < 2 {
-				continue
-			}
-
-			// result[0] 是队列名，result[1] 是任务数据
-			taskData := result[1]
-
 			// 反序列化任务
 			task, err := UnmarshalTask([]byte(taskData))
 			if err != nil {
@@ -116,6 +143,35 @@ func (w *Worker) work(id int) {
 	}
 }
 
+// popTimeout 单次阻塞弹出的最长等待时间
+const popTimeout = 1 * time.Second
+
+// popTask 阻塞弹出一个任务，Worker 停止时返回 false
+// BRPop 使用 Worker 的 ctx：停止后不会再发起新的弹出；已发出的 BRPop 最多等待 popTimeout 返回，
+// 期间弹出的任务照常交给调用方处理（Stop 会等待其完成），不会丢失
+func (w *Worker) popTask() (string, bool) {
+	result, err := cache.BRPop(w.ctx, popTimeout, w.client.GetQueueKey())
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, redis.Nil) {
+			// 停止或等待超时
+			return "", false
+		}
+		logger.Error("failed to pop task", slog.String("error", err.Error()))
+		// Redis 不可用时稍后重试，避免空转
+		select {
+		case <-w.ctx.Done():
+		case <-time.After(popTimeout):
+		}
+		return "", false
+	}
+
+	// result[0] 是队列名，result[1] 是任务数据
+	if len(result) < 2 {
+		return "", false
+	}
+	return result[1], true
+}
+
 // processTask 处理任务
 func (w *Worker) processTask(task *Task, workerID int) {
 	logger.Info("processing task",
@@ -139,8 +195,11 @@ func (w *Worker) processTask(task *Task, workerID int) {
 	startTime := time.Now()
 	err := handler(task)
 	duration := time.Since(startTime)
+	w.metrics.ObserveDuration(w.ctx, task.Name, duration)
 
 	if err != nil {
+		w.metrics.Incr(w.ctx, task.Name, metricFailed)
+
 		logger.Error("task failed",
 			slog.String("task_id", task.ID),
 			slog.String("task_name", task.Name),
@@ -148,31 +207,36 @@ func (w *Worker) processTask(task *Task, workerID int) {
 			slog.Duration("duration", duration),
 			slog.Int("retry_count", task.RetryCount))
 
-		// 重试逻辑
+		// 重试逻辑：按退避策略计算等待时间后写入延迟队列，进程重启也不会丢失待重试任务
 		if task.RetryCount < task.MaxRetry {
 			task.RetryCount++
+			w.metrics.Incr(w.ctx, task.Name, metricRetried)
+
+			policy := w.backoff
+			if task.BackoffStrategy != "" {
+				policy.Strategy = task.BackoffStrategy
+			}
+			delay := policy.Delay(task.RetryCount)
 			logger.Info("retrying task",
 				slog.String("task_id", task.ID),
 				slog.Int("retry_count", task.RetryCount),
-				slog.Duration("delay", w.retryDelay))
-
-			// 延迟重新提交任务
-			go func() {
-				time.Sleep(w.retryDelay)
-				data, _ := task.Marshal()
-				if err := cache.LPush(w.ctx, w.client.GetQueueKey(), data); err != nil {
-					logger.Error("failed to retry task",
-						slog.String("task_id", task.ID),
-						slog.String("error", err.Error()))
-				}
-			}()
+				slog.String("backoff", string(policy.Strategy)),
+				slog.Duration("delay", delay))
+
+			if err := w.client.scheduleAt(w.ctx, task, time.Now().Add(delay)); err != nil {
+				logger.Error("failed to retry task",
+					slog.String("task_id", task.ID),
+					slog.String("error", err.Error()))
+			}
 		} else {
+			w.metrics.Incr(w.ctx, task.Name, metricDeadLettered)
 			logger.Error("task failed after max retries",
 				slog.String("task_id", task.ID),
 				slog.String("task_name", task.Name),
 				slog.Int("max_retry", task.MaxRetry))
 		}
 	} else {
+		w.metrics.Incr(w.ctx, task.Name, metricProcessed)
 		logger.Info("task completed",
 			slog.String("task_id", task.ID),
 			slog.String("task_name", task.Name),
@@ -201,43 +265,58 @@ func (w *Worker) scheduleDelayedTasks() {
 }
 
 // checkDelayedTasks 检查并移动到期的延迟任务
+// 只按分数（执行时间）取出已到期的任务，分批原子移入主队列；
+// 单次扫描达到 delayedMaxPerTick 后停止，剩余任务留待下次扫描
 func (w *Worker) checkDelayedTasks() {
+	batchSize := w.delayedBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	maxPerTick := w.delayedMaxPerTick
+	if maxPerTick <= 0 {
+		maxPerTick = 1000
+	}
+
 	now := float64(time.Now().Unix())
+	var moved int64
 
-	// 获取分数小于当前时间的任务（即已到期的任务）
-	tasks, err := cache.ZRange(w.ctx, w.client.GetDelayKey(), 0, -1)
-	if err != nil {
-		return
-	}
+	for moved < maxPerTick && w.ctx.Err() == nil {
+		limit := min(batchSize, maxPerTick-moved)
 
-	for _, taskData := range tasks {
-		task, err := UnmarshalTask([]byte(taskData))
+		tasks, err := cache.ZMoveByScoreToList(w.ctx, w.client.GetDelayKey(), w.client.GetQueueKey(), now, limit)
 		if err != nil {
-			continue
+			logger.Error("failed to move delayed tasks to queue", slog.String("error", err.Error()))
+			return
 		}
 
-		// 检查是否到期
-		if float64(task.ExecuteAt.Unix()) <= now {
-			// 移动到主队列
-			if err := cache.LPush(w.ctx, w.client.GetQueueKey(), taskData); err != nil {
-				logger.Error("failed to move delayed task to queue",
-					slog.String("task_id", task.ID),
-					slog.String("error", err.Error()))
+		for _, taskData := range tasks {
+			task, err := UnmarshalTask([]byte(taskData))
+			if err != nil {
 				continue
 			}
-
-			// 从延迟队列中移除
-			if err := cache.ZRem(w.ctx, w.client.GetDelayKey(), taskData); err != nil {
-				logger.Error("failed to remove delayed task",
-					slog.String("task_id", task.ID),
-					slog.String("error", err.Error()))
-			}
-
-			logger.Info("moved delayed task to queue",
+			logger.Debug("moved delayed task to queue",
 				slog.String("task_id", task.ID),
 				slog.String("task_name", task.Name))
 		}
+
+		moved += int64(len(tasks))
+		if int64(len(tasks)) < limit {
+			break
+		}
+	}
+
+	if moved > 0 {
+		logger.Info("moved delayed tasks to queue", slog.Int64("count", moved))
 	}
+	if moved >= maxPerTick {
+		logger.Warn("delayed task backlog exceeds per-tick limit, remaining tasks deferred",
+			slog.Int64("limit", maxPerTick))
+	}
+}
+
+// GetMetrics 获取任务指标统计
+func (w *Worker) GetMetrics() *Metrics {
+	return w.metrics
 }
 
 // GetClient 获取队列客户端
@@ -257,11 +336,18 @@ func (w *Worker) Stats() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to get queue length: %w", err)
 	}
 
+	taskMetrics, err := w.metrics.All(w.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task metrics: %w", err)
+	}
+
 	stats := map[string]interface{}{
 		"workers":     w.workerNum,
 		"queue_len":   queueLen,
 		"max_retry":   w.maxRetry,
 		"retry_delay": w.retryDelay.Seconds(),
+		"backoff":     w.backoff.Strategy,
+		"tasks":       taskMetrics, // 按任务名统计的指标
 	}
 
 	return stats, nil
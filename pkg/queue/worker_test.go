@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestWorkerProcessesSubmittedTask(t *testing.T) {
+	w := newTestWorker(t, 2)
+
+	done := make(chan string, 1)
+	w.Register("echo", func(task *Task) error {
+		done <- task.ID
+		return nil
+	})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer w.Stop()
+
+	id, err := w.client.Submit(context.Background(), "echo", map[string]interface{}{"k": "v"}, 0)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	select {
+	case got := <-done:
+		if got != id {
+			t.Fatalf("processed task %q, want %q", got, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not processed")
+	}
+}
+
+func TestWorkerStopReturnsWithinPopTimeout(t *testing.T) {
+	w := newTestWorker(t, 4)
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// 等待所有 Worker 进入阻塞弹出
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		_ = w.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(popTimeout + 2*time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if elapsed := time.Since(start); elapsed > popTimeout+time.Second {
+		t.Fatalf("Stop took %v, want at most ~%v", elapsed, popTimeout)
+	}
+}
+
+func TestWorkerDoesNotConsumeTasksAfterStop(t *testing.T) {
+	w := newTestWorker(t, 2)
+	processed := make(chan struct{}, 1)
+	w.Register("echo", func(task *Task) error {
+		processed <- struct{}{}
+		return nil
+	})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := w.client.Submit(ctx, "echo", nil, 0); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	// 停止后提交的任务保留在队列中，不会被已退出的 Worker 弹出后丢弃
+	time.Sleep(popTimeout + 200*time.Millisecond)
+	n, err := cache.LLen(ctx, w.client.GetQueueKey())
+	if err != nil {
+		t.Fatalf("LLen: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("queue length = %d, want 1", n)
+	}
+	select {
+	case <-processed:
+		t.Fatal("task processed after Stop")
+	default:
+	}
+}
+
+func TestPopTaskReturnsFalseOnCanceledContext(t *testing.T) {
+	w := newTestWorker(t, 1)
+	w.cancel()
+
+	start := time.Now()
+	if _, ok := w.popTask(); ok {
+		t.Fatal("popTask returned a task after cancel")
+	}
+	if elapsed := time.Since(start); elapsed >= popTimeout {
+		t.Fatalf("popTask blocked %v after cancel", elapsed)
+	}
+}
+
+// seedDelayedTasks 直接写入延迟任务：due 个已到期，pending 个一小时后到期
+func seedDelayedTasks(t *testing.T, c *Client, due, pending int) {
+	t.Helper()
+
+	now := time.Now()
+	members := make([]redis.Z, 0, due+pending)
+	for i := 0; i < due+pending; i++ {
+		executeAt := now.Add(time.Hour)
+		if i < due {
+			executeAt = now.Add(-time.Minute)
+		}
+		task := &Task{ID: fmt.Sprintf("task-%d", i), Name: "echo", CreatedAt: now, ExecuteAt: executeAt}
+		data, err := task.Marshal()
+		if err != nil {
+			t.Fatalf("marshal task: %v", err)
+		}
+		members = append(members, redis.Z{Score: float64(executeAt.Unix()), Member: string(data)})
+	}
+	if err := cache.ZAdd(context.Background(), c.GetDelayKey(), members...); err != nil {
+		t.Fatalf("ZAdd: %v", err)
+	}
+}
+
+func TestCheckDelayedTasks_MovesOnlyDueTasks(t *testing.T) {
+	w := newTestWorker(t, 1)
+	ctx := context.Background()
+
+	const due, pending = 250, 9750
+	seedDelayedTasks(t, w.client, due, pending)
+
+	w.checkDelayedTasks()
+
+	queued, err := cache.LRange(ctx, w.client.GetQueueKey(), 0, -1)
+	if err != nil {
+		t.Fatalf("LRange: %v", err)
+	}
+	if len(queued) != due {
+		t.Fatalf("queue length = %d, want %d due tasks", len(queued), due)
+	}
+	for _, data := range queued {
+		task, err := UnmarshalTask([]byte(data))
+		if err != nil {
+			t.Fatalf("unmarshal queued task: %v", err)
+		}
+		if task.ExecuteAt.After(time.Now()) {
+			t.Fatalf("task %s moved before it was due", task.ID)
+		}
+	}
+	remaining, err := cache.ZRange(ctx, w.client.GetDelayKey(), 0, -1)
+	if err != nil {
+		t.Fatalf("ZRange: %v", err)
+	}
+	if len(remaining) != pending {
+		t.Fatalf("delayed set size = %d, want %d", len(remaining), pending)
+	}
+}
+
+func TestCheckDelayedTasks_RespectsPerTickLimit(t *testing.T) {
+	w := newTestWorker(t, 1)
+	ctx := context.Background()
+
+	// 到期任务超过单次上限时，每次扫描只移动 delayedMaxPerTick 个
+	seedDelayedTasks(t, w.client, 2500, 0)
+
+	w.checkDelayedTasks()
+	if n, _ := cache.LLen(ctx, w.client.GetQueueKey()); n != w.delayedMaxPerTick {
+		t.Fatalf("first tick moved %d tasks, want %d", n, w.delayedMaxPerTick)
+	}
+	w.checkDelayedTasks()
+	w.checkDelayedTasks()
+	if n, _ := cache.LLen(ctx, w.client.GetQueueKey()); n != 2500 {
+		t.Fatalf("after three ticks moved %d tasks, want 2500", n)
+	}
+}
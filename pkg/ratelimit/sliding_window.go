@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/cccvno1/nova/pkg/cache"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -45,6 +46,7 @@ func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (bool, int
 		local window_start = tonumber(ARGV[2])
 		local limit = tonumber(ARGV[3])
 		local window_ms = tonumber(ARGV[4])
+		local member = ARGV[5]
 		
 		-- 删除窗口外的记录
 		redis.call('zremrangebyscore', key, 0, window_start)
@@ -55,7 +57,7 @@ func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (bool, int
 		local allowed = 0
 		if count < limit then
 			-- 添加当前请求
-			redis.call('zadd', key, now, now)
+			redis.call('zadd', key, now, member)
 			allowed = 1
 			count = count + 1
 		end
@@ -66,9 +68,11 @@ func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (bool, int
 		return {allowed, count}
 	`
 
+	// 成员需唯一：以时间戳作成员时，同一毫秒内的多个请求只记一次
+	member := fmt.Sprintf("%d-%s", now, uuid.NewString())
 	result, err := l.redisClient.Eval(ctx, script,
 		[]string{fullKey},
-		now, windowStart, l.limit, l.window.Milliseconds()).Result()
+		now, windowStart, l.limit, l.window.Milliseconds(), member).Result()
 
 	if err != nil {
 		return false, 0, err
@@ -120,3 +124,32 @@ func (l *SlidingWindowLimiter) GetRemaining(ctx context.Context, key string) (in
 	}
 	return remaining, nil
 }
+
+// RetryAfter 计算距离窗口内最早一条请求过期（即释放出一个名额）的剩余时间
+// 窗口未满时返回 0
+func (l *SlidingWindowLimiter) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	fullKey := fmt.Sprintf("%s:%s", l.keyPrefix, key)
+	now := time.Now().UnixMilli()
+
+	count, err := l.GetCount(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if count < l.limit {
+		return 0, nil
+	}
+
+	oldest, err := l.redisClient.ZRangeWithScores(ctx, fullKey, 0, 0).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(oldest) == 0 {
+		return 0, nil
+	}
+
+	wait := int64(oldest[0].Score) + l.window.Milliseconds() - now
+	if wait < 0 {
+		wait = 0
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/cccvno1/nova/pkg/cache"
@@ -184,3 +185,36 @@ func (l *TokenBucketLimiter) Reset(ctx context.Context, key string) error {
 	fullKey := fmt.Sprintf("%s:%s", l.keyPrefix, key)
 	return l.redisClient.Del(ctx, fullKey).Err()
 }
+
+// RetryAfter 根据令牌缺口与生成速率计算下一个令牌可用的等待时间
+// 桶内已有可用令牌时返回 0
+func (l *TokenBucketLimiter) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	fullKey := fmt.Sprintf("%s:%s", l.keyPrefix, key)
+
+	bucket, err := l.redisClient.HMGet(ctx, fullKey, "tokens", "last_time").Result()
+	if err != nil {
+		return 0, err
+	}
+	if bucket[0] == nil {
+		return 0, nil
+	}
+
+	tokens, _ := bucket[0].(string)
+	lastTime, _ := bucket[1].(string)
+
+	var tokensFloat float64
+	var lastTimeInt int64
+	fmt.Sscanf(tokens, "%f", &tokensFloat)
+	fmt.Sscanf(lastTime, "%d", &lastTimeInt)
+
+	// 令牌按整秒补充（与 Allow 中的 Unix 秒时间戳一致），缺口向上取整到秒
+	elapsed := time.Now().Unix() - lastTimeInt
+	current := tokensFloat + float64(elapsed*int64(l.rate))
+	deficit := 1 - current
+	if deficit <= 0 {
+		return 0, nil
+	}
+
+	seconds := int64(math.Ceil(deficit / float64(l.rate)))
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,74 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderStreamError 流式输出中途出错时携带错误信息的 HTTP trailer
+const HeaderStreamError = "X-Stream-Error"
+
+// DefaultStreamFlushEvery 流式输出默认每写出多少个元素刷新一次
+const DefaultStreamFlushEvery = 100
+
+// StreamJSONArray 以分块传输逐个写出 JSON 数组元素（[ ... ]），不在内存中构建完整列表，返回写出的元素数
+// items 产出元素或错误；flushEvery 为每写出多少个元素刷新一次，<=0 时使用 DefaultStreamFlushEvery。
+//
+// 第一个元素就绪前出错时尚未写出任何内容，直接返回错误，由错误处理器返回普通的错误响应；
+// 开始写出后出错时不再写出结尾的 "]"，使客户端解析失败，同时通过 X-Stream-Error trailer 给出错误信息，并返回该错误
+func StreamJSONArray[T any](c echo.Context, items iter.Seq2[T, error], flushEvery int) (int, error) {
+	if flushEvery <= 0 {
+		flushEvery = DefaultStreamFlushEvery
+	}
+
+	next, stop := iter.Pull2(items)
+	defer stop()
+
+	// 先取第一个元素，出错时仍可返回普通错误响应
+	item, err, ok := next()
+	if ok && err != nil {
+		return 0, err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	res.Header().Set("Trailer", HeaderStreamError)
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := res.Write([]byte{'['}); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for ; ok; item, err, ok = next() {
+		if err == nil {
+			var data []byte
+			if data, err = json.Marshal(item); err == nil {
+				if count > 0 {
+					data = append([]byte{','}, data...)
+				}
+				_, err = res.Write(data)
+			}
+		}
+		if err != nil {
+			res.Header().Set(HeaderStreamError, err.Error())
+			res.Flush()
+			return count, fmt.Errorf("stream aborted after %d items: %w", count, err)
+		}
+
+		count++
+		if count%flushEvery == 0 {
+			res.Flush()
+		}
+	}
+
+	if _, err := res.Write([]byte{']'}); err != nil {
+		return count, err
+	}
+	res.Flush()
+	return count, nil
+}
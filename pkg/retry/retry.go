@@ -0,0 +1,79 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Strategy 退避策略
+type Strategy string
+
+const (
+	StrategyFixed       Strategy = "fixed"       // 固定间隔
+	StrategyExponential Strategy = "exponential" // 指数退避：InitialDelay * Multiplier^(n-1)
+)
+
+// Policy 重试策略
+type Policy struct {
+	MaxAttempts  int           // 最大尝试次数（含首次），<= 0 时只执行一次
+	Strategy     Strategy      // 退避策略，为空时按 fixed 处理
+	InitialDelay time.Duration // 首次重试前的等待时间
+	MaxDelay     time.Duration // 单次等待上限（0 表示不限制）
+	Multiplier   float64       // 指数退避倍数（<= 1 时取 2）
+	Jitter       float64       // 随机抖动比例（0-1），实际等待时间在 [d*(1-Jitter), d*(1+Jitter)] 内均匀分布
+}
+
+// Delay 计算第 n 次失败后（n 从 1 开始）到下一次尝试前的等待时间
+func (p Policy) Delay(n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+
+	d := float64(p.InitialDelay)
+	if p.Strategy == StrategyExponential {
+		multiplier := p.Multiplier
+		if multiplier <= 1 {
+			multiplier = 2
+		}
+		d *= math.Pow(multiplier, float64(n-1))
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		d *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	// 指数增长可能溢出 int64，统一按上限截断
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// Do 按策略执行 fn，直到成功、达到最大尝试次数或 ctx 结束
+// fn 的参数为当前尝试序号（从 1 开始）；返回最后一次的错误，ctx 结束时返回 ctx.Err()
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context, attempt int) error) error {
+	attempts := max(policy.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(ctx, attempt); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		timer := time.NewTimer(policy.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFail = errors.New("fail")
+
+func TestDo_StopsOnSuccess(t *testing.T) {
+	var calls []int
+	err := Do(context.Background(), Policy{MaxAttempts: 5}, func(_ context.Context, attempt int) error {
+		calls = append(calls, attempt)
+		if attempt < 3 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if len(calls) != 3 || calls[0] != 1 || calls[2] != 3 {
+		t.Fatalf("attempts = %v, want [1 2 3]", calls)
+	}
+}
+
+func TestDo_MaxAttempts(t *testing.T) {
+	tests := []struct {
+		maxAttempts int
+		want        int
+	}{
+		{maxAttempts: 0, want: 1},
+		{maxAttempts: -1, want: 1},
+		{maxAttempts: 1, want: 1},
+		{maxAttempts: 4, want: 4},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := Do(context.Background(), Policy{MaxAttempts: tt.maxAttempts}, func(context.Context, int) error {
+			calls++
+			return errFail
+		})
+		if !errors.Is(err, errFail) {
+			t.Errorf("MaxAttempts=%d: err = %v, want last error", tt.maxAttempts, err)
+		}
+		if calls != tt.want {
+			t.Errorf("MaxAttempts=%d: calls = %d, want %d", tt.maxAttempts, calls, tt.want)
+		}
+	}
+}
+
+func TestDo_WaitsBetweenAttempts(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	policy := Policy{MaxAttempts: 4, Strategy: StrategyFixed, InitialDelay: delay}
+
+	var times []time.Time
+	_ = Do(context.Background(), policy, func(context.Context, int) error {
+		times = append(times, time.Now())
+		return errFail
+	})
+	if len(times) != 4 {
+		t.Fatalf("calls = %d, want 4", len(times))
+	}
+	// 每次重试前至少等待 InitialDelay，最后一次失败后不再等待
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < delay {
+			t.Errorf("gap before attempt %d = %v, want >= %v", i+1, gap, delay)
+		}
+	}
+}
+
+func TestDo_ReturnsContextErrorWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{MaxAttempts: 3, InitialDelay: time.Hour}, func(context.Context, int) error {
+		calls++
+		return errFail
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Do took %v after ctx ended", elapsed)
+	}
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		n      int
+		want   time.Duration
+	}{
+		{"fixed", Policy{Strategy: StrategyFixed, InitialDelay: time.Second}, 5, time.Second},
+		{"empty strategy is fixed", Policy{InitialDelay: time.Second}, 3, time.Second},
+		{"exponential first", Policy{Strategy: StrategyExponential, InitialDelay: time.Second}, 1, time.Second},
+		{"exponential default multiplier", Policy{Strategy: StrategyExponential, InitialDelay: time.Second}, 4, 8 * time.Second},
+		{"exponential custom multiplier", Policy{Strategy: StrategyExponential, InitialDelay: time.Second, Multiplier: 3}, 3, 9 * time.Second},
+		{"capped by max delay", Policy{Strategy: StrategyExponential, InitialDelay: time.Second, MaxDelay: 5 * time.Second}, 10, 5 * time.Second},
+		{"n below one", Policy{Strategy: StrategyExponential, InitialDelay: time.Second}, 0, time.Second},
+		{"overflow saturates", Policy{Strategy: StrategyExponential, InitialDelay: time.Second}, 200, time.Duration(1<<63 - 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.n); got != tt.want {
+				t.Fatalf("Delay(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_DelayJitterBounds(t *testing.T) {
+	p := Policy{Strategy: StrategyFixed, InitialDelay: time.Second, Jitter: 0.1}
+	for i := 0; i < 1000; i++ {
+		d := p.Delay(1)
+		if d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("Delay = %v, want within ±10%% of 1s", d)
+		}
+	}
+}
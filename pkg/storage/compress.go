@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// EncodingGzip gzip 压缩存储的内容编码
+const EncodingGzip = "gzip"
+
+// Compress 将 src 的内容以 gzip 格式写入 dst
+func Compress(dst io.Writer, src io.Reader) error {
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+// gzipReadCloser 解压读取器，关闭时同时关闭底层读取器
+type gzipReadCloser struct {
+	*gzip.Reader
+	underlying io.ReadCloser
+}
+
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.underlying.Close()
+}
+
+// NewDecompressReader 包装 gzip 压缩内容的读取器，读取时自动解压
+func NewDecompressReader(rc io.ReadCloser) (io.ReadCloser, error) {
+	gr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: gr, underlying: rc}, nil
+}
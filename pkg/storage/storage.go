@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"os"
 )
 
 // Storage 文件存储接口
@@ -38,3 +40,18 @@ type UploadOptions struct {
 	Metadata    map[string]string // 元数据
 	ACL         string            // 访问控制（public-read, private 等）
 }
+
+// CheckWritableDir 检查目录是否存在且可写（不存在时自动创建）
+func CheckWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".nova_write_check_*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
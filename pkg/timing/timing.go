@@ -0,0 +1,108 @@
+// Package timing 记录单个请求内各依赖（数据库、缓存、权限）的耗时
+// 记录器存放在请求上下文中；上下文中没有记录器时 Start 只做一次 context.Value 查找，开销可忽略
+package timing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 依赖名称，同时作为 Server-Timing 的指标名
+const (
+	DB       = "db"
+	Cache    = "cache"
+	Enforcer = "enforcer"
+)
+
+type contextKey struct{}
+
+// Span 某个依赖在本次请求中的累计耗时
+type Span struct {
+	Name     string        `json:"name"`
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Recorder 请求级的耗时记录器（并发安全，请求内可能并发访问依赖）
+type Recorder struct {
+	mu    sync.Mutex
+	spans map[string]*Span
+}
+
+// WithRecorder 在上下文中挂载新的记录器
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{spans: make(map[string]*Span)}
+	return context.WithValue(ctx, contextKey{}, r), r
+}
+
+// FromContext 获取上下文中的记录器，未启用时返回 nil
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(contextKey{}).(*Recorder)
+	return r
+}
+
+// noop 未启用时返回的结束函数
+func noop() {}
+
+// Start 开始计时，返回的函数结束计时并累加到 name 对应的耗时
+// 用法：defer timing.Start(ctx, timing.Cache)()
+func Start(ctx context.Context, name string) func() {
+	r := FromContext(ctx)
+	if r == nil {
+		return noop
+	}
+	start := time.Now()
+	return func() {
+		r.Add(name, time.Since(start))
+	}
+}
+
+// Add 累加一次调用的耗时
+func (r *Recorder) Add(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	span, ok := r.spans[name]
+	if !ok {
+		span = &Span{Name: name}
+		r.spans[name] = span
+	}
+	span.Count++
+	span.Duration += d
+}
+
+// Spans 按名称排序的耗时汇总
+func (r *Recorder) Spans() []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]Span, 0, len(r.spans))
+	for _, span := range r.spans {
+		spans = append(spans, *span)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Name < spans[j].Name })
+	return spans
+}
+
+// Header 生成 Server-Timing 响应头，如 cache;dur=1.2;desc="3 calls", db;dur=8.5;desc="2 calls"
+// total 为整个请求的处理耗时（<= 0 时不输出）
+func (r *Recorder) Header(total time.Duration) string {
+	spans := r.Spans()
+	parts := make([]string, 0, len(spans)+1)
+	for _, span := range spans {
+		parts = append(parts, fmt.Sprintf(`%s;dur=%.1f;desc="%d calls"`, span.Name, durationMs(span.Duration), span.Count))
+	}
+	if total > 0 {
+		parts = append(parts, fmt.Sprintf("total;dur=%.1f", durationMs(total)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
 	"github.com/cccvno1/nova/pkg/logger"
@@ -203,7 +204,7 @@ func seedMenuPermissions(_ context.Context, domain string) error {
 }
 
 // assignMenuPermissionsToAdmin 分配菜单权限给admin用户
-func assignMenuPermissionsToAdmin(_ context.Context, domain string) error {
+func assignMenuPermissionsToAdmin(ctx context.Context, domain string) error {
 	db := database.GetDB()
 
 	// 获取admin用户
@@ -212,24 +213,21 @@ func assignMenuPermissionsToAdmin(_ context.Context, domain string) error {
 		return fmt.Errorf("find admin user failed: %w", err)
 	}
 
-	// 获取或创建admin角色
-	var adminRole model.Role
-	err := db.Where("name = ? AND domain = ?", "admin", domain).First(&adminRole).Error
+	// 获取或创建admin角色（upsert，避免检查与创建之间的竞争）
+	adminRole, created, err := repository.NewRoleRepository(database.DB()).GetOrCreate(ctx, &model.Role{
+		Name:        "admin",
+		DisplayName: "管理员",
+		Description: "系统管理员，拥有所有菜单权限",
+		Domain:      domain,
+		Category:    "system",
+		IsSystem:    true,
+		Sort:        90,
+		Status:      1,
+	})
 	if err != nil {
-		// 如果admin角色不存在，创建它
-		adminRole = model.Role{
-			Name:        "admin",
-			DisplayName: "管理员",
-			Description: "系统管理员，拥有所有菜单权限",
-			Domain:      domain,
-			Category:    "system",
-			IsSystem:    true,
-			Sort:        90,
-			Status:      1,
-		}
-		if err := db.Create(&adminRole).Error; err != nil {
-			return fmt.Errorf("create admin role failed: %w", err)
-		}
+		return fmt.Errorf("create admin role failed: %w", err)
+	}
+	if created {
 		log.Printf("创建admin角色")
 	}
 
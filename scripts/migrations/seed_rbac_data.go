@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"github.com/cccvno1/nova/internal/model"
+	"github.com/cccvno1/nova/internal/repository"
 	"github.com/cccvno1/nova/pkg/casbin"
 	"github.com/cccvno1/nova/pkg/config"
 	"github.com/cccvno1/nova/pkg/database"
@@ -45,6 +46,7 @@ func main() {
 		database.GetDB(),
 		casbin.Config{
 			ModelPath:    cfg.Casbin.ModelPath,
+			ModelText:    cfg.Casbin.Model,
 			AutoSave:     cfg.Casbin.AutoSave,
 			AutoLoad:     false, // 初始化时不需要自动加载
 			AutoLoadTick: 0,
@@ -82,9 +84,7 @@ func main() {
 }
 
 // seedRoles 初始化默认角色
-func seedRoles(_ context.Context, domain string) error {
-	db := database.GetDB()
-
+func seedRoles(ctx context.Context, domain string) error {
 	roles := []model.Role{
 		{
 			Name:        "super_admin",
@@ -128,22 +128,17 @@ func seedRoles(_ context.Context, domain string) error {
 		},
 	}
 
+	roleRepo := repository.NewRoleRepository(database.DB())
 	for _, role := range roles {
-		// 检查角色是否已存在
-		var count int64
-		if err := db.Model(&model.Role{}).
-			Where("name = ? AND domain = ?", role.Name, domain).
-			Count(&count).Error; err != nil {
-			return fmt.Errorf("check role existence failed: %w", err)
+		// upsert：多次执行或并发执行都不会重复创建
+		result, created, err := roleRepo.GetOrCreate(ctx, &role)
+		if err != nil {
+			return fmt.Errorf("create role %s failed: %w", role.Name, err)
 		}
-
-		if count == 0 {
-			if err := db.Create(&role).Error; err != nil {
-				return fmt.Errorf("create role %s failed: %w", role.Name, err)
-			}
-			log.Printf("创建角色: %s (%s)", role.Name, role.DisplayName)
+		if created {
+			log.Printf("创建角色: %s (%s)", result.Name, result.DisplayName)
 		} else {
-			log.Printf("角色已存在: %s", role.Name)
+			log.Printf("角色已存在: %s", result.Name)
 		}
 	}
 
@@ -208,6 +203,19 @@ func seedPermissions(_ context.Context, domain string) error {
 			Sort:        70,
 			Status:      1,
 		},
+		{
+			Name:        "user:read_pii",
+			DisplayName: "查看用户敏感信息",
+			Description: "搜索时按邮箱匹配，并查看未脱敏的邮箱与电话",
+			Type:        model.PermissionTypeField,
+			Domain:      domain,
+			Resource:    "user",
+			Action:      "read_pii",
+			Category:    "用户管理",
+			IsSystem:    true,
+			Sort:        60,
+			Status:      1,
+		},
 
 		// 角色管理权限
 		{